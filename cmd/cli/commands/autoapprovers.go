@@ -0,0 +1,170 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/binrclab/headcni/cmd/daemon/config"
+	"github.com/binrclab/headcni/pkg/backend/tailscale"
+	"github.com/binrclab/headcni/pkg/headscale"
+	"github.com/tailscale/hujson"
+)
+
+// autoApproverCheckTimeout 读取 Headscale 策略和本地通告路由的超时时间
+const autoApproverCheckTimeout = 30 * time.Second
+
+// aclPolicy Headscale ACL 策略中与路由自动批准相关的部分
+type aclPolicy struct {
+	AutoApprovers struct {
+		Routes map[string][]string `json:"routes"`
+	} `json:"autoApprovers"`
+}
+
+// parseAutoApproverRoutes 解析 HuJSON 格式的 ACL 策略，返回 autoApprovers.routes 中的前缀及其批准者
+func parseAutoApproverRoutes(policy string) (map[netip.Prefix][]string, error) {
+	data, err := hujson.Standardize([]byte(policy))
+	if err != nil {
+		return nil, fmt.Errorf("invalid policy: %v", err)
+	}
+	var parsed aclPolicy
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return nil, fmt.Errorf("invalid policy: %v", err)
+	}
+
+	routes := make(map[netip.Prefix][]string, len(parsed.AutoApprovers.Routes))
+	for value, approvers := range parsed.AutoApprovers.Routes {
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid autoApprovers route %q: %v", value, err)
+		}
+		routes[prefix.Masked()] = approvers
+	}
+	return routes, nil
+}
+
+// checkAutoApproverRoutes 检查每个前缀是否被 autoApprovers.routes 中包含 tag 的条目覆盖
+// 与 Headscale 的匹配方式一致：条目前缀包含该前缀且不比它更具体时，该前缀的路由由条目的批准者自动批准
+func checkAutoApproverRoutes(routes map[netip.Prefix][]string, tag string, prefixes []netip.Prefix) []FirewallCheck {
+	entries := make([]netip.Prefix, 0, len(routes))
+	for entry := range routes {
+		entries = append(entries, entry)
+	}
+	// 优先报告最具体的匹配条目
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Bits() > entries[j].Bits() })
+
+	checks := make([]FirewallCheck, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		check := FirewallCheck{Name: fmt.Sprintf("autoApprovers for %s", prefix)}
+		var covering []string
+		for _, entry := range entries {
+			if entry.Bits() > prefix.Bits() || !entry.Contains(prefix.Addr()) {
+				continue
+			}
+			covering = append(covering, entry.String())
+			if slices.Contains(routes[entry], tag) {
+				check.Status = "PASSED"
+				check.Detail = fmt.Sprintf("approved for %s by autoApprovers.routes[%q]", tag, entry)
+				break
+			}
+		}
+		if check.Status == "" {
+			check.Status = "FAILED"
+			if len(covering) == 0 {
+				check.Detail = "no autoApprovers.routes entry covers this prefix"
+			} else {
+				check.Detail = fmt.Sprintf("covering entries %s do not list %s", strings.Join(covering, ", "), tag)
+			}
+			check.Hint = fmt.Sprintf("add %q: [%q] to autoApprovers.routes in the Headscale ACL policy", prefix, tag)
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// autoApproverPrefixes 返回需要检查的前缀：本地 tailscaled 通告的路由和集群 Pod CIDR（network.podCIDR.base）
+// 本地 tailscaled 不可达时只检查集群 Pod CIDR
+func autoApproverPrefixes(ctx context.Context, cfg *config.Config) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	seen := make(map[netip.Prefix]bool)
+	add := func(prefix netip.Prefix) {
+		prefix = prefix.Masked()
+		if !seen[prefix] {
+			seen[prefix] = true
+			prefixes = append(prefixes, prefix)
+		}
+	}
+
+	socketPath := drainSocketPath(cfg)
+	prefs, err := tailscale.NewSimpleClient(socketPath).GetPrefs(ctx)
+	if err != nil {
+		fmt.Printf("⚠️  Warning: Failed to read advertised routes from %s: %v\n", socketPath, err)
+	} else {
+		for _, route := range prefs.AdvertiseRoutes {
+			add(route)
+		}
+	}
+
+	if base := cfg.Network.PodCIDR.Base; base != "" {
+		prefix, err := netip.ParsePrefix(base)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network.podCIDR.base %q: %v", base, err)
+		}
+		add(prefix)
+	}
+
+	if len(prefixes) == 0 {
+		return nil, fmt.Errorf("no advertised routes or network.podCIDR.base to check")
+	}
+	return prefixes, nil
+}
+
+// runAutoApproverDiagnostics 检查 Headscale ACL 策略的 autoApprovers 是否为 headscale.autoApproverTag 批准本节点通告的路由
+func runAutoApproverDiagnostics(opts *DiagnosticsOptions) error {
+	cfg, err := config.LoadConfig(opts.ConfigPath)
+	if err != nil {
+		return fmt.Errorf("failed to load daemon config: %v", err)
+	}
+	tag := cfg.Headscale.AutoApproverTag
+	if tag == "" {
+		return fmt.Errorf("headscale.autoApproverTag is not set in the daemon config")
+	}
+	if cfg.Headscale.URL == "" || cfg.Headscale.AuthKey == "" {
+		return fmt.Errorf("headscale URL and API key are required in the daemon config")
+	}
+
+	headscaleClient, err := headscale.NewClient(&cfg.Headscale)
+	if err != nil {
+		return fmt.Errorf("failed to create headscale client: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), autoApproverCheckTimeout)
+	defer cancel()
+
+	fmt.Printf("🏷️  Checking Headscale autoApprovers for %s...\n\n", tag)
+
+	policy, err := headscaleClient.GetPolicy(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get headscale policy: %v", err)
+	}
+	routes, err := parseAutoApproverRoutes(policy.Policy)
+	if err != nil {
+		return err
+	}
+	prefixes, err := autoApproverPrefixes(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	failed := printFirewallChecks(checkAutoApproverRoutes(routes, tag, prefixes))
+	if failed > 0 {
+		return fmt.Errorf("%d prefix(es) are not auto-approved for %s", failed, tag)
+	}
+	fmt.Println("\n✅ autoApprovers checks completed")
+	return nil
+}
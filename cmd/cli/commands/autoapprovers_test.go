@@ -0,0 +1,91 @@
+package commands
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestParseAutoApproverRoutes(t *testing.T) {
+	// Headscale 策略是 HuJSON，允许注释和结尾逗号
+	routes, err := parseAutoApproverRoutes(`{
+		// Pod CIDR 由路由节点标签批准
+		"autoApprovers": {
+			"routes": {
+				"10.42.1.5/16": ["tag:headcni-router", "group:admins"],
+				"fd00:42::/48": ["tag:headcni-router"],
+			},
+		},
+	}`)
+	if err != nil {
+		t.Fatalf("parseAutoApproverRoutes failed: %v", err)
+	}
+	if len(routes) != 2 {
+		t.Fatalf("expected 2 routes, got %v", routes)
+	}
+	// 前缀统一为网络地址
+	if approvers := routes[netip.MustParsePrefix("10.42.0.0/16")]; len(approvers) != 2 || approvers[0] != "tag:headcni-router" {
+		t.Errorf("expected the masked IPv4 prefix with its approvers, got %v", routes)
+	}
+	if _, ok := routes[netip.MustParsePrefix("fd00:42::/48")]; !ok {
+		t.Errorf("expected the IPv6 prefix, got %v", routes)
+	}
+
+	// 没有 autoApprovers 的策略不是错误
+	if routes, err := parseAutoApproverRoutes(`{"acls": []}`); err != nil || len(routes) != 0 {
+		t.Errorf("expected no routes for a policy without autoApprovers, got %v, %v", routes, err)
+	}
+
+	for _, policy := range []string{
+		`{"autoApprovers": {`,
+		`{"autoApprovers": {"routes": {"10.42.0.0": ["tag:headcni-router"]}}}`,
+	} {
+		if _, err := parseAutoApproverRoutes(policy); err == nil {
+			t.Errorf("expected an error for policy %s", policy)
+		}
+	}
+}
+
+func TestCheckAutoApproverRoutes(t *testing.T) {
+	tag := "tag:headcni-router"
+	routes := map[netip.Prefix][]string{
+		netip.MustParsePrefix("10.0.0.0/8"):   {tag},
+		netip.MustParsePrefix("10.42.0.0/16"): {"group:admins"},
+		netip.MustParsePrefix("10.43.1.0/24"): {tag},
+	}
+
+	tests := []struct {
+		prefix string
+		status string
+		detail string
+	}{
+		// 更具体的条目不批准该标签时，继续使用覆盖它的更大条目
+		{prefix: "10.42.1.0/24", status: "PASSED", detail: `autoApprovers.routes["10.0.0.0/8"]`},
+		{prefix: "10.43.1.0/24", status: "PASSED", detail: `autoApprovers.routes["10.43.1.0/24"]`},
+		// 条目比前缀更具体时不覆盖该前缀
+		{prefix: "10.43.0.0/16", status: "PASSED", detail: `autoApprovers.routes["10.0.0.0/8"]`},
+		{prefix: "192.168.0.0/24", status: "FAILED", detail: "no autoApprovers.routes entry covers this prefix"},
+	}
+
+	prefixes := make([]netip.Prefix, 0, len(tests))
+	for _, tt := range tests {
+		prefixes = append(prefixes, netip.MustParsePrefix(tt.prefix))
+	}
+	checks := checkAutoApproverRoutes(routes, tag, prefixes)
+	if len(checks) != len(tests) {
+		t.Fatalf("expected %d checks, got %+v", len(tests), checks)
+	}
+	for i, tt := range tests {
+		if checks[i].Status != tt.status || !strings.Contains(checks[i].Detail, tt.detail) {
+			t.Errorf("%s: expected %s with %q, got %+v", tt.prefix, tt.status, tt.detail, checks[i])
+		}
+	}
+
+	// 覆盖的条目都不包含该标签
+	checks = checkAutoApproverRoutes(map[netip.Prefix][]string{
+		netip.MustParsePrefix("10.42.0.0/16"): {"group:admins"},
+	}, tag, []netip.Prefix{netip.MustParsePrefix("10.42.1.0/24")})
+	if checks[0].Status != "FAILED" || !strings.Contains(checks[0].Detail, "10.42.0.0/16 do not list "+tag) || checks[0].Hint == "" {
+		t.Errorf("expected a failure naming the covering entry, got %+v", checks[0])
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/binrclab/headcni/pkg/utils/fsutil"
 	"github.com/spf13/cobra"
 )
 
@@ -444,7 +445,7 @@ func restoreCNIConfig(cniConfig interface{}) error {
 	}
 
 	// 写入CNI配置文件
-	if err := os.WriteFile("/etc/cni/net.d/10-headcni.conflist", jsonData, 0644); err != nil {
+	if err := fsutil.WriteFileAtomic("/etc/cni/net.d/10-headcni.conflist", jsonData, 0644); err != nil {
 		return fmt.Errorf("failed to write CNI config: %v", err)
 	}
 
@@ -0,0 +1,116 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/binrclab/headcni/pkg/cni"
+)
+
+// maxCollectedInvocations diagnostics 收集的最近 CNI 调用记录数
+const maxCollectedInvocations = 20
+
+// defaultCNIBinDir 调用记录中没有 CNI_PATH 时查找插件的目录
+const defaultCNIBinDir = "/opt/cni/bin"
+
+// collectCNIInvocations 读取 dir 中最近的 limit 条 CNI 调用记录，目录不存在时返回 nil
+func collectCNIInvocations(dir string, limit int) ([]*cni.Invocation, error) {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return nil, nil
+	}
+	paths, err := cni.ListInvocations(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) > limit {
+		paths = paths[:limit]
+	}
+
+	invocations := make([]*cni.Invocation, 0, len(paths))
+	for _, path := range paths {
+		inv, err := cni.ReadInvocation(path)
+		if err != nil {
+			fmt.Printf("⚠️  Warning: Skipping %v\n", err)
+			continue
+		}
+		invocations = append(invocations, inv)
+	}
+	return invocations, nil
+}
+
+// findCNIPlugin 在 binDir（冒号分隔的目录列表）中查找插件二进制
+func findCNIPlugin(binDir, pluginType string) (string, error) {
+	for _, dir := range filepath.SplitList(binDir) {
+		path := filepath.Join(dir, pluginType)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("CNI plugin %s not found in %s", pluginType, binDir)
+}
+
+// replayEnv 返回重放使用的环境变量：当前环境去掉 CNI_* 和 HEADCNI_CNI_DUMP_DIR 后加上记录的 CNI_* 变量，
+// 重放不会产生新的调用记录
+func replayEnv(inv *cni.Invocation) []string {
+	var env []string
+	for _, kv := range os.Environ() {
+		if strings.HasPrefix(kv, "CNI_") || strings.HasPrefix(kv, cni.InvocationDumpEnv+"=") {
+			continue
+		}
+		env = append(env, kv)
+	}
+	return append(env, inv.Env()...)
+}
+
+// runCNIReplay 以调用记录中的 stdin 配置和 CNI_* 环境变量重新执行 CNI 插件
+// 重放是一次真实的调用：ADD 会在记录的 netns 中配置网络，DEL 会释放资源
+func runCNIReplay(opts *DiagnosticsOptions) error {
+	inv, err := cni.ReadInvocation(opts.ReplayCNI)
+	if err != nil {
+		return fmt.Errorf("failed to read CNI invocation: %v", err)
+	}
+	pluginType, err := inv.PluginType()
+	if err != nil {
+		return err
+	}
+
+	binDir := opts.CNIBinDir
+	if binDir == "" {
+		binDir = inv.Path
+	}
+	if binDir == "" {
+		binDir = defaultCNIBinDir
+	}
+	plugin, err := findCNIPlugin(binDir, pluginType)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("🔁 Replaying CNI %s of container %s recorded at %s\n", inv.Command, inv.ContainerID, inv.Time.Format("2006-01-02 15:04:05"))
+	fmt.Printf("Plugin: %s\n", plugin)
+	fmt.Printf("Netns: %s, interface: %s\n", inv.Netns, inv.IfName)
+	if inv.Masked {
+		fmt.Printf("⚠️  Warning: the recorded config has masked keys (%q), fill them in before replaying if the plugin needs them\n", "***")
+	}
+	fmt.Println()
+
+	var stdout bytes.Buffer
+	cmd := exec.Command(plugin)
+	cmd.Env = replayEnv(inv)
+	cmd.Stdin = strings.NewReader(inv.StdinData)
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	runErr := cmd.Run()
+
+	fmt.Println("Plugin output:")
+	fmt.Println(stdout.String())
+	if runErr != nil {
+		return fmt.Errorf("CNI %s failed: %v", inv.Command, runErr)
+	}
+	fmt.Printf("✅ CNI %s succeeded\n", inv.Command)
+	return nil
+}
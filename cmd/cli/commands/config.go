@@ -3,9 +3,17 @@ package commands
 import (
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/binrclab/headcni/cmd/daemon/config"
+	"github.com/binrclab/headcni/pkg/cni"
+	"github.com/binrclab/headcni/pkg/constants"
+	"github.com/binrclab/headcni/pkg/utils"
 	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
 )
@@ -15,8 +23,15 @@ type ConfigOptions struct {
 	ReleaseName string
 	Output      string
 	Show        bool
+	Effective   bool
 	Validate    bool
 	Action      string
+
+	// validate 相关参数
+	ConfigFile  string
+	CNIConfFile string
+	Online      bool
+	Cluster     bool
 }
 
 func NewConfigCommand() *cobra.Command {
@@ -36,13 +51,19 @@ Examples:
   # Show current configuration
   headcni config --show
 
-  # Validate configuration
-  headcni config --validate
+  # Show the configuration the daemon on this node actually runs with
+  headcni config show --effective --config /opt/headcni/config/daemon.yaml
+
+  # Validate daemon config and CNI conf on this node
+  headcni config validate --config /opt/headcni/config/daemon.yaml
+
+  # Also check that the Headscale server is reachable
+  headcni config validate --config /opt/headcni/config/daemon.yaml --online
 
   # Export configuration as JSON
   headcni config --show --output json`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runConfig(cmd, args)
+			return runConfig(cmd, opts, args)
 		},
 	}
 
@@ -50,26 +71,36 @@ Examples:
 	cmd.Flags().StringVar(&opts.ReleaseName, "release-name", "headcni", "Helm release name")
 	cmd.Flags().StringVar(&opts.Output, "output", "table", "Output format (table, json, yaml)")
 	cmd.Flags().BoolVar(&opts.Show, "show", false, "Show current configuration")
+	cmd.Flags().BoolVar(&opts.Effective, "effective", false, "Show the fully-resolved daemon configuration and the source of each value (show)")
 	cmd.Flags().BoolVar(&opts.Validate, "validate", false, "Validate configuration")
+	cmd.Flags().StringVar(&opts.ConfigFile, "config", "", "Path to daemon configuration file (validate, show --effective)")
+	cmd.Flags().StringVar(&opts.CNIConfFile, "cni-conf", filepath.Join(constants.DefaultCNIConfigDir, constants.DefaultHeadCNIConfigFile), "Path to CNI conflist (validate)")
+	cmd.Flags().BoolVar(&opts.Online, "online", false, "Check that the Headscale server is reachable (validate)")
+	cmd.Flags().BoolVar(&opts.Cluster, "cluster", false, "Also validate the in-cluster ConfigMap and DaemonSet (validate)")
 
 	return cmd
 }
 
-func runConfig(cmd *cobra.Command, args []string) error {
-	showLogo()
-
-	opts := &ConfigOptions{
-		Namespace:   "kube-system",
-		ReleaseName: "headcni",
-	}
-
+func runConfig(cmd *cobra.Command, opts *ConfigOptions, args []string) error {
 	// 解析命令行参数
 	if len(args) > 0 {
 		opts.Action = args[0]
+	} else if opts.Show {
+		opts.Action = "show"
+	} else if opts.Validate {
+		opts.Action = "validate"
+	}
+
+	// validate 和 show --effective 的输出需要便于在脚本和问题报告中使用，不显示 logo
+	if opts.Action != "validate" && !(opts.Action == "show" && opts.Effective) {
+		showLogo()
 	}
 
 	switch opts.Action {
 	case "show":
+		if opts.Effective {
+			return showEffectiveConfig(cmd, opts)
+		}
 		return showConfig(opts)
 	case "validate":
 		return validateConfig(opts)
@@ -139,9 +170,236 @@ func showConfig(opts *ConfigOptions) error {
 	return nil
 }
 
+// showEffectiveConfig 显示本机 daemon 实际生效的配置及每个配置项的来源（flag/env/file/default），敏感值已隐藏
+// 在 CLI 中运行时 env 为当前进程的环境变量，daemon 的命令行参数可通过 headcni-daemon config show --effective 查看
+func showEffectiveConfig(cmd *cobra.Command, opts *ConfigOptions) error {
+	effective, err := config.LoadEffectiveConfig(cmd)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %v", err)
+	}
+
+	switch opts.Output {
+	case "json":
+		data, err := effective.JSON()
+		if err != nil {
+			return fmt.Errorf("failed to marshal config to JSON: %v", err)
+		}
+		fmt.Printf("%s\n", string(data))
+
+	case "yaml":
+		data, err := effective.YAML()
+		if err != nil {
+			return fmt.Errorf("failed to marshal config to YAML: %v", err)
+		}
+		fmt.Printf("%s", string(data))
+
+	default:
+		fields, err := effective.Fields()
+		if err != nil {
+			return fmt.Errorf("failed to render config: %v", err)
+		}
+		tableData := pterm.TableData{{"Field", "Value", "Source"}}
+		for _, field := range fields {
+			tableData = append(tableData, []string{field.Path, field.Value, string(field.Source)})
+		}
+		pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+	}
+
+	return nil
+}
+
+// validateConfig 校验本机 daemon 配置和 CNI conflist，一次性输出所有问题
 func validateConfig(opts *ConfigOptions) error {
 	fmt.Printf("🔍 Validating Configuration...\n")
 
+	result := &config.ValidationResult{}
+
+	// daemon 配置
+	daemonFile := opts.ConfigFile
+	if daemonFile == "" {
+		daemonFile = "<defaults>"
+	}
+	cfg, err := config.LoadConfig(opts.ConfigFile)
+	if err != nil {
+		result.Issues = append(result.Issues, config.ValidationIssue{
+			Severity: config.SeverityError,
+			File:     daemonFile,
+			Field:    "-",
+			Message:  fmt.Sprintf("failed to load config: %v", err),
+		})
+	} else {
+		result.Merge(cfg.Validate(daemonFile))
+		// 在节点上运行时同时检查集群网段是否与节点主网卡子网重叠
+		if hostNets, err := utils.GetDefaultGatewaySubnets(); err == nil {
+			result.Merge(cfg.ValidateHostSubnetOverlaps(daemonFile, hostNets))
+		}
+		if opts.Online {
+			result.Merge(checkHeadscaleReachable(cfg, daemonFile))
+		}
+	}
+
+	// CNI conflist
+	result.Merge(validateCNIConfList(opts.CNIConfFile, cfg))
+
+	if opts.Cluster {
+		if err := validateClusterConfig(opts); err != nil {
+			result.Issues = append(result.Issues, config.ValidationIssue{
+				Severity: config.SeverityError,
+				File:     fmt.Sprintf("configmap/%s-config", opts.ReleaseName),
+				Field:    "-",
+				Message:  err.Error(),
+			})
+		}
+	}
+
+	return reportValidationResult(result, opts.Output)
+}
+
+// reportValidationResult 输出校验结果，存在错误级别问题时返回错误
+func reportValidationResult(result *config.ValidationResult, output string) error {
+	if output == "json" {
+		jsonOutput, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal validation result to JSON: %v", err)
+		}
+		fmt.Printf("%s\n", string(jsonOutput))
+	} else if len(result.Issues) > 0 {
+		tableData := pterm.TableData{{"Severity", "File", "Field", "Message"}}
+		for _, issue := range result.Issues {
+			severity := "⚠️  " + issue.Severity
+			if issue.Severity == config.SeverityError {
+				severity = "❌ " + issue.Severity
+			}
+			tableData = append(tableData, []string{severity, issue.File, issue.Field, issue.Message})
+		}
+		pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+	}
+
+	if errs := result.Errors(); len(errs) > 0 {
+		return fmt.Errorf("configuration validation failed with %d error(s)", len(errs))
+	}
+
+	if output != "json" {
+		fmt.Printf("\n✅ Configuration validation passed with %d warning(s)!\n", len(result.Issues))
+	}
+	return nil
+}
+
+// validateCNIConfList 校验 CNI conflist 文件
+func validateCNIConfList(path string, cfg *config.Config) *config.ValidationResult {
+	result := &config.ValidationResult{}
+	addIssue := func(severity, field, format string, args ...interface{}) {
+		result.Issues = append(result.Issues, config.ValidationIssue{
+			Severity: severity,
+			File:     path,
+			Field:    field,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			addIssue(config.SeverityWarning, "-", "CNI conflist not found (it is generated by the daemon on first start)")
+		} else {
+			addIssue(config.SeverityError, "-", "failed to read CNI conflist: %v", err)
+		}
+		return result
+	}
+
+	var confList map[string]interface{}
+	if err := json.Unmarshal(data, &confList); err != nil {
+		addIssue(config.SeverityError, "-", "invalid JSON: %v", err)
+		return result
+	}
+
+	for _, field := range []string{"cniVersion", "name"} {
+		if v, ok := confList[field].(string); !ok || v == "" {
+			addIssue(config.SeverityError, field, "required field is missing")
+		}
+	}
+
+	plugins, ok := confList["plugins"].([]interface{})
+	if !ok || len(plugins) == 0 {
+		addIssue(config.SeverityError, "plugins", "conflist must contain at least one plugin")
+		return result
+	}
+
+	// preservePlugins 保留的外部插件排在 headcni 之前
+	var preserved map[string]bool
+	if cfg != nil {
+		preserved = cni.PreservedPluginTypes(filepath.Dir(path), cfg.PreservePlugins)
+	}
+
+	hasHeadCNI := false
+	leading := true
+	for i, p := range plugins {
+		plugin, ok := p.(map[string]interface{})
+		if !ok {
+			addIssue(config.SeverityError, fmt.Sprintf("plugins[%d]", i), "plugin must be an object")
+			continue
+		}
+		pluginType, _ := plugin["type"].(string)
+		if pluginType == "" {
+			addIssue(config.SeverityError, fmt.Sprintf("plugins[%d].type", i), "required field is missing")
+			continue
+		}
+		if pluginType != "headcni" && !preserved[pluginType] {
+			leading = false
+		}
+		if pluginType == "headcni" {
+			hasHeadCNI = true
+			if !leading {
+				addIssue(config.SeverityError, fmt.Sprintf("plugins[%d].type", i), "headcni must be the first plugin in the chain after preserved plugins")
+			}
+			if mtu, ok := plugin["mtu"].(float64); ok && cfg != nil && int(mtu) > cfg.TailnetMTU() {
+				addIssue(config.SeverityError, fmt.Sprintf("plugins[%d].mtu", i), "pod MTU %d exceeds tailnet MTU %d", int(mtu), cfg.TailnetMTU())
+			}
+		}
+	}
+	if !hasHeadCNI {
+		addIssue(config.SeverityError, "plugins", "no headcni plugin found in chain")
+	}
+
+	return result
+}
+
+// checkHeadscaleReachable 检查 Headscale 服务是否可达
+func checkHeadscaleReachable(cfg *config.Config, file string) *config.ValidationResult {
+	result := &config.ValidationResult{}
+	if cfg.Headscale.URL == "" {
+		return result
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(strings.TrimSuffix(cfg.Headscale.URL, "/") + "/health")
+	if err != nil {
+		result.Issues = append(result.Issues, config.ValidationIssue{
+			Severity: config.SeverityError,
+			File:     file,
+			Field:    "headscale.url",
+			Message:  fmt.Sprintf("headscale server is not reachable: %v", err),
+		})
+		return result
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		result.Issues = append(result.Issues, config.ValidationIssue{
+			Severity: config.SeverityWarning,
+			File:     file,
+			Field:    "headscale.url",
+			Message:  fmt.Sprintf("headscale health endpoint returned status %d", resp.StatusCode),
+		})
+	}
+
+	return result
+}
+
+// validateClusterConfig 校验集群中的 ConfigMap 和 DaemonSet
+func validateClusterConfig(opts *ConfigOptions) error {
+	fmt.Printf("🔍 Validating cluster configuration...\n")
+
 	// 获取 ConfigMap
 	cmd := exec.Command("kubectl", "get", "configmap", opts.ReleaseName+"-config", "-n", opts.Namespace, "-o", "json")
 	output, err := cmd.Output()
@@ -172,24 +430,6 @@ func validateConfig(opts *ConfigOptions) error {
 			return fmt.Errorf("invalid CNI configuration format: %v", err)
 		}
 
-		// 检查必需字段
-		requiredFields := []string{"cniVersion", "name", "type", "ipam"}
-		for _, field := range requiredFields {
-			if _, ok := cniConfig[field]; !ok {
-				return fmt.Errorf("missing required field: %s", field)
-			}
-		}
-
-		// 检查 IPAM 配置
-		if ipam, ok := cniConfig["ipam"].(map[string]interface{}); ok {
-			if ipamType, ok := ipam["type"].(string); ok {
-				if ipamType != "host-local" {
-					return fmt.Errorf("unsupported IPAM type: %s", ipamType)
-				}
-				fmt.Printf("   ✅ IPAM type: %s\n", ipamType)
-			}
-		}
-
 		fmt.Printf("   ✅ CNI configuration format is valid\n")
 	}
 
@@ -215,7 +455,6 @@ func validateConfig(opts *ConfigOptions) error {
 	}
 
 	fmt.Printf("   ✅ DaemonSet is ready (%d/%d pods)\n", ready, desired)
-	fmt.Printf("\n✅ Configuration validation passed!\n")
 
 	return nil
 }
@@ -240,8 +479,8 @@ func showConfigHelp() error {
 	pterm.DefaultSection.Println("HeadCNI Configuration Management")
 
 	help := `Available commands:
-  show     - Show current configuration
-  validate - Validate configuration
+  show     - Show current configuration (--effective for the resolved daemon config)
+  validate - Validate daemon config and CNI conflist (--config, --cni-conf, --online, --cluster)
   export   - Export configuration as JSON
   explain  - Explain configuration parameters
 
@@ -0,0 +1,45 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/binrclab/headcni/cmd/daemon/config"
+	"github.com/binrclab/headcni/pkg/cni"
+)
+
+func TestValidateCNIConfListAcceptsPreservedPlugins(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"20-calico.conflist": `{"cniVersion":"0.3.1","name":"k8s-pod-network","plugins":[{"type":"calico"},{"type":"bandwidth"}]}`,
+		"30-cilium.conf":     `{"cniVersion":"0.3.1","name":"cilium","type":"cilium-cni"}`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := &config.Config{PreservePlugins: []string{"calico", "30-cilium.conf"}}
+	cm := cni.NewCNIConfigManager(dir, "10-headcni.conflist", filepath.Join(dir, "env.yaml"), nil)
+	cm.SetPreservePlugins(cfg.PreservePlugins)
+	configList, _, err := cm.GenerateConfigList("10.244.1.0/24", cfg, "10.96.0.10", "cluster.local")
+	if err != nil {
+		t.Fatalf("GenerateConfigList failed: %v", err)
+	}
+	if err := cm.WriteConfigList(configList); err != nil {
+		t.Fatalf("WriteConfigList failed: %v", err)
+	}
+
+	result := validateCNIConfList(cm.GetConfigPath(), cfg)
+	if errs := result.Errors(); len(errs) != 0 {
+		t.Fatalf("expected the preserve conflist to validate, got %v", errs)
+	}
+
+	// 未保留的插件排在 headcni 之前仍然是错误
+	result = validateCNIConfList(cm.GetConfigPath(), &config.Config{PreservePlugins: []string{"calico"}})
+	if errs := result.Errors(); len(errs) != 1 || errs[0].Field != "plugins[2].type" {
+		t.Fatalf("expected headcni after an unpreserved plugin to be rejected, got %v", errs)
+	}
+}
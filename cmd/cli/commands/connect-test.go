@@ -3,8 +3,10 @@ package commands
 import (
 	"fmt"
 	"os/exec"
+	"strings"
 	"time"
 
+	"github.com/binrclab/headcni/pkg/backend/tailscale"
 	"github.com/spf13/cobra"
 )
 
@@ -13,6 +15,8 @@ type ConnectTestOptions struct {
 	ReleaseName string
 	Timeout     int
 	Verbose     bool
+	// HandshakeThreshold 该时间内向在线节点发送过流量但没有握手时测试失败
+	HandshakeThreshold time.Duration
 }
 
 type TestResult struct {
@@ -35,6 +39,8 @@ This command will perform various connectivity tests:
 - Pod-to-service communication
 - External network access
 - Tailscale mesh connectivity
+- DERP region latency and direct/relayed peer paths
+- Last WireGuard handshake with each peer
 
 Examples:
   # Basic connectivity test
@@ -54,6 +60,7 @@ Examples:
 	cmd.Flags().StringVar(&opts.ReleaseName, "release-name", "headcni", "Helm release name")
 	cmd.Flags().IntVar(&opts.Timeout, "timeout", 30, "Test timeout in seconds")
 	cmd.Flags().BoolVar(&opts.Verbose, "verbose", false, "Verbose output")
+	cmd.Flags().DurationVar(&opts.HandshakeThreshold, "handshake-threshold", 5*time.Minute, "Fail when an online peer was sent traffic within this time but has not handshaked in it")
 
 	return cmd
 }
@@ -109,6 +116,18 @@ func runConnectTest(opts *ConnectTestOptions) error {
 	results = append(results, result)
 	printTestResult(result, opts.Verbose)
 
+	// 测试7: 检查 DERP 中继情况
+	fmt.Printf("📦 Test 7: DERP Relay Path...\n")
+	result = testDERPRelayPath(opts)
+	results = append(results, result)
+	printTestResult(result, opts.Verbose)
+
+	// 测试8: 检查各节点最近一次握手
+	fmt.Printf("📦 Test 8: Peer Handshakes...\n")
+	result = testPeerHandshakes(opts)
+	results = append(results, result)
+	printTestResult(result, opts.Verbose)
+
 	// 输出总结
 	printTestSummary(results)
 
@@ -398,6 +417,78 @@ func testTailscaleConnectivity(opts *ConnectTestOptions) TestResult {
 	return result
 }
 
+func testDERPRelayPath(opts *ConnectTestOptions) TestResult {
+	start := time.Now()
+	result := TestResult{Name: "DERP Relay Path"}
+
+	info, err := collectDERPInfo(time.Duration(opts.Timeout) * time.Second)
+	if err != nil {
+		// 不在节点上运行时无法访问 tailscaled socket
+		result.Status = "SKIPPED"
+		result.Error = err.Error()
+		result.Duration = time.Since(start).String()
+		return result
+	}
+
+	if opts.Verbose {
+		fmt.Print(formatDERPInfo(info, "      "))
+	}
+
+	result.Status, result.Error = derpRelayStatus(info, opts.HandshakeThreshold, time.Now())
+	result.Duration = time.Since(start).String()
+	return result
+}
+
+// derpRelayStatus 评估 DERP 中继情况：经 DERP 中继的连接仍然可用，只给出警告；
+// 中继节点在 threshold 内发送过流量却没有握手时不可达，测试失败
+func derpRelayStatus(info *tailscale.DERPInfo, threshold time.Duration, now time.Time) (string, string) {
+	relayed := relayedPeers(info)
+	if len(relayed) == 0 {
+		return "PASSED", ""
+	}
+
+	var unreachable []string
+	for _, peer := range relayed {
+		if peer.StaleHandshake(threshold, now) {
+			unreachable = append(unreachable, peer.HostName)
+		}
+	}
+	if len(unreachable) > 0 {
+		return "FAILED", fmt.Sprintf("%d relayed peer(s) unreachable through DERP (preferred region %s): %s",
+			len(unreachable), info.PreferredRegionCode, strings.Join(unreachable, ", "))
+	}
+	return "WARNING", fmt.Sprintf("%d peer(s) relayed through DERP (preferred region %s)", len(relayed), info.PreferredRegionCode)
+}
+
+func testPeerHandshakes(opts *ConnectTestOptions) TestResult {
+	start := time.Now()
+	result := TestResult{Name: "Peer Handshakes"}
+
+	info, err := collectDERPInfo(time.Duration(opts.Timeout) * time.Second)
+	if err != nil {
+		result.Status = "SKIPPED"
+		result.Error = err.Error()
+		result.Duration = time.Since(start).String()
+		return result
+	}
+
+	// 阈值内发送过流量但没有握手的在线节点视为失败，并给出每个节点的路径
+	now := time.Now()
+	var details []string
+	for _, peer := range staleHandshakePeers(info, opts.HandshakeThreshold, now) {
+		details = append(details, fmt.Sprintf("%s (%s, last handshake %s)", peer.HostName, peerPathString(peer), handshakeAge(peer, now)))
+	}
+	if len(details) > 0 {
+		result.Status = "FAILED"
+		result.Error = fmt.Sprintf("%d peer(s) sent traffic without a handshake in %v: %s", len(details), opts.HandshakeThreshold, strings.Join(details, ", "))
+	} else {
+		result.Status = "PASSED"
+	}
+
+	result.Duration = time.Since(start).String()
+	return result
+}
+
 func testCNIPluginFunctionality(opts *ConnectTestOptions) TestResult {
 	start := time.Now()
 	result := TestResult{Name: "CNI Plugin Functionality"}
@@ -428,9 +519,12 @@ func testCNIPluginFunctionality(opts *ConnectTestOptions) TestResult {
 
 func printTestResult(result TestResult, verbose bool) {
 	statusIcon := "❌"
-	if result.Status == "PASSED" {
+	switch result.Status {
+	case "PASSED":
 		statusIcon = "✅"
-	} else if result.Status == "SKIPPED" {
+	case "WARNING":
+		statusIcon = "⚠️"
+	case "SKIPPED":
 		statusIcon = "⏭️"
 	}
 
@@ -446,6 +540,7 @@ func printTestSummary(results []TestResult) {
 
 	passed := 0
 	failed := 0
+	warnings := 0
 	skipped := 0
 
 	for _, result := range results {
@@ -454,6 +549,8 @@ func printTestSummary(results []TestResult) {
 			passed++
 		case "FAILED":
 			failed++
+		case "WARNING":
+			warnings++
 		case "SKIPPED":
 			skipped++
 		}
@@ -461,6 +558,9 @@ func printTestSummary(results []TestResult) {
 
 	fmt.Printf("   ✅ Passed: %d\n", passed)
 	fmt.Printf("   ❌ Failed: %d\n", failed)
+	if warnings > 0 {
+		fmt.Printf("   ⚠️  Warnings: %d\n", warnings)
+	}
 	if skipped > 0 {
 		fmt.Printf("   ⏭️  Skipped: %d\n", skipped)
 	}
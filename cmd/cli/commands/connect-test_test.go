@@ -0,0 +1,40 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/binrclab/headcni/pkg/backend/tailscale"
+)
+
+func TestDERPRelayStatus(t *testing.T) {
+	now := time.Now()
+	direct := tailscale.PeerPath{HostName: "node-1", Online: true, Direct: true, LastWrite: now, LastHandshake: now}
+	relayed := tailscale.PeerPath{HostName: "node-2", Online: true, Relay: "fra", LastWrite: now, LastHandshake: now.Add(-time.Minute)}
+	idle := tailscale.PeerPath{HostName: "node-3", Online: true, Relay: "fra"}
+	unreachable := tailscale.PeerPath{HostName: "node-4", Online: true, Relay: "fra", LastWrite: now.Add(-time.Minute)}
+	offline := tailscale.PeerPath{HostName: "node-5", Relay: "fra", LastWrite: now}
+
+	tests := []struct {
+		name   string
+		peers  []tailscale.PeerPath
+		status string
+	}{
+		{"direct peers", []tailscale.PeerPath{direct, offline}, "PASSED"},
+		{"relayed peers still reachable", []tailscale.PeerPath{direct, relayed, idle}, "WARNING"},
+		{"relayed peer not answering", []tailscale.PeerPath{direct, relayed, unreachable}, "FAILED"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := &tailscale.DERPInfo{PreferredRegionCode: "fra", Peers: tt.peers}
+			status, message := derpRelayStatus(info, 5*time.Minute, now)
+			if status != tt.status {
+				t.Fatalf("expected %s, got %s (%s)", tt.status, status, message)
+			}
+			if status == "FAILED" && !strings.Contains(message, "node-4") {
+				t.Errorf("expected the unreachable peer in the message, got %q", message)
+			}
+		})
+	}
+}
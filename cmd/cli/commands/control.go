@@ -0,0 +1,168 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/binrclab/headcni/pkg/backend/tailscale"
+)
+
+// controlQueryTimeout 查询本地 daemon 状态的超时时间
+const controlQueryTimeout = 5 * time.Second
+
+// controlClient 通过本地控制 socket 访问本节点的 HeadCNI daemon
+type controlClient struct {
+	socketPath string
+	client     *http.Client
+}
+
+func newControlClient(socketPath string, timeout time.Duration) *controlClient {
+	return &controlClient{
+		socketPath: socketPath,
+		client: &http.Client{
+			Timeout: timeout,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var dialer net.Dialer
+					return dialer.DialContext(ctx, "unix", socketPath)
+				},
+			},
+		},
+	}
+}
+
+// available 控制 socket 是否存在，不存在时说明不在 daemon 所在节点上运行
+func (c *controlClient) available() bool {
+	info, err := os.Stat(c.socketPath)
+	return err == nil && info.Mode()&os.ModeSocket != 0
+}
+
+// get 发送 GET 请求并解析 JSON 响应
+func (c *controlClient) get(path string, out interface{}) error {
+	return c.do(http.MethodGet, path, nil, out)
+}
+
+// post 发送 POST 请求，in 不为 nil 时以 JSON 作为请求体
+func (c *controlClient) post(path string, in, out interface{}) error {
+	return c.do(http.MethodPost, path, in, out)
+}
+
+func (c *controlClient) do(method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %v", err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, "http://unix"+path, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to contact daemon: %v", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read daemon response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("daemon returned %s: %s", resp.Status, string(bytes.TrimSpace(data)))
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("unexpected daemon response: %s", string(data))
+	}
+	return nil
+}
+
+// controlInfo 与 daemon 控制接口 /info 的响应对应
+type controlInfo struct {
+	Version     string                      `json:"version"`
+	NodeName    string                      `json:"node_name,omitempty"`
+	Mode        string                      `json:"mode,omitempty"`
+	DryRun      bool                        `json:"dry_run"`
+	TailscaleIP string                      `json:"tailscale_ip,omitempty"`
+	Tailscale   map[string]interface{}      `json:"tailscale,omitempty"`
+	Services    []*tailscale.ServiceDetails `json:"services,omitempty"`
+}
+
+// controlHealth 与 daemon 控制接口 /health 的响应对应
+type controlHealth struct {
+	Status   string `json:"status"`
+	Services map[string]struct {
+		Running bool   `json:"running"`
+		Error   string `json:"error,omitempty"`
+	} `json:"services"`
+}
+
+// LocalDaemonStatus 通过控制 socket 读取的本节点 daemon 状态
+type LocalDaemonStatus struct {
+	Version        string                      `json:"version"`
+	NodeName       string                      `json:"node_name,omitempty"`
+	Mode           string                      `json:"mode,omitempty"`
+	DryRun         bool                        `json:"dry_run"`
+	TailscaleIP    string                      `json:"tailscale_ip,omitempty"`
+	TailscaleState string                      `json:"tailscale_state,omitempty"`
+	Health         string                      `json:"health"`
+	ServiceErrors  map[string]string           `json:"service_errors,omitempty"`
+	Services       []*tailscale.ServiceDetails `json:"services,omitempty"`
+}
+
+// queryLocalDaemon 通过控制 socket 查询本节点 daemon 的状态和健康快照
+// socket 不存在时返回 nil 和 nil，调用方回退到原有的查询方式
+func queryLocalDaemon(socketPath string) (*LocalDaemonStatus, error) {
+	client := newControlClient(socketPath, controlQueryTimeout)
+	if !client.available() {
+		return nil, nil
+	}
+
+	var info controlInfo
+	if err := client.get("/info", &info); err != nil {
+		return nil, err
+	}
+	var health controlHealth
+	if err := client.get("/health", &health); err != nil {
+		return nil, err
+	}
+
+	status := &LocalDaemonStatus{
+		Version:     info.Version,
+		NodeName:    info.NodeName,
+		Mode:        info.Mode,
+		DryRun:      info.DryRun,
+		TailscaleIP: info.TailscaleIP,
+		Health:      health.Status,
+		Services:    info.Services,
+	}
+	if state, ok := info.Tailscale["state"].(string); ok {
+		status.TailscaleState = state
+	}
+	for name, svc := range health.Services {
+		if svc.Error != "" {
+			if status.ServiceErrors == nil {
+				status.ServiceErrors = make(map[string]string)
+			}
+			status.ServiceErrors[name] = svc.Error
+		}
+	}
+	return status, nil
+}
@@ -0,0 +1,92 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/binrclab/headcni/pkg/backend/tailscale"
+)
+
+// collectDERPInfo 通过本机 tailscaled socket 获取 DERP 信息
+// 优先使用 HeadCNI 自带的 tailscaled，其次使用宿主机 tailscaled
+func collectDERPInfo(timeout time.Duration) (*tailscale.DERPInfo, error) {
+	socketPath, err := findTailscaleSocket()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	info, err := tailscale.NewSimpleClient(socketPath).GetDERPInfo(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get DERP info from %s: %v", socketPath, err)
+	}
+	return info, nil
+}
+
+// relayedPeers 返回在线但没有直连路径的节点
+func relayedPeers(info *tailscale.DERPInfo) []tailscale.PeerPath {
+	var peers []tailscale.PeerPath
+	for _, peer := range info.Peers {
+		if peer.Online && !peer.Direct {
+			peers = append(peers, peer)
+		}
+	}
+	return peers
+}
+
+// formatDERPInfo 格式化 DERP 信息用于输出
+func formatDERPInfo(info *tailscale.DERPInfo, indent string) string {
+	out := fmt.Sprintf("%sPreferred DERP: %s (id %d)\n", indent, info.PreferredRegionCode, info.PreferredRegionID)
+
+	out += fmt.Sprintf("%sRegion latency:\n", indent)
+	for _, region := range info.Regions {
+		if region.Error != "" {
+			out += fmt.Sprintf("%s  %-4d %-8s %-24s unreachable: %s\n", indent, region.RegionID, region.RegionCode, region.RegionName, region.Error)
+			continue
+		}
+		out += fmt.Sprintf("%s  %-4d %-8s %-24s %v\n", indent, region.RegionID, region.RegionCode, region.RegionName, region.Latency.Round(time.Millisecond))
+	}
+
+	out += fmt.Sprintf("%sPeers:\n", indent)
+	now := time.Now()
+	for _, peer := range info.Peers {
+		out += fmt.Sprintf("%s  %-24s %-16s %-32s handshake %s\n", indent, peer.HostName, peer.TailscaleIP, peerPathString(peer), handshakeAge(peer, now))
+	}
+
+	return out
+}
+
+// peerPathString 描述到节点的数据路径：直连、peer relay 或 DERP 中继
+func peerPathString(peer tailscale.PeerPath) string {
+	switch {
+	case peer.Online && peer.Direct:
+		return "direct " + peer.CurAddr
+	case peer.Online && peer.PeerRelay != "":
+		return "peer-relay " + peer.PeerRelay
+	case peer.Online:
+		return "relay " + peer.Relay
+	default:
+		return "offline"
+	}
+}
+
+// handshakeAge 返回距最近一次 WireGuard 握手的时间
+func handshakeAge(peer tailscale.PeerPath, now time.Time) string {
+	if peer.LastHandshake.IsZero() {
+		return "never"
+	}
+	return now.Sub(peer.LastHandshake).Truncate(time.Second).String() + " ago"
+}
+
+// staleHandshakePeers 返回 threshold 内发送过流量但没有握手的在线节点，这些节点多半已不可达
+func staleHandshakePeers(info *tailscale.DERPInfo, threshold time.Duration, now time.Time) []tailscale.PeerPath {
+	var peers []tailscale.PeerPath
+	for _, peer := range info.Peers {
+		if peer.StaleHandshake(threshold, now) {
+			peers = append(peers, peer)
+		}
+	}
+	return peers
+}
@@ -9,16 +9,29 @@ import (
 	"strings"
 	"time"
 
+	"github.com/binrclab/headcni/pkg/backend/tailscale"
+	"github.com/binrclab/headcni/pkg/cni"
+	"github.com/binrclab/headcni/pkg/constants"
 	"github.com/spf13/cobra"
 )
 
 type DiagnosticsOptions struct {
-	Namespace   string
-	ReleaseName string
-	OutputDir   string
-	IncludeLogs bool
-	IncludeYAML bool
-	Verbose     bool
+	Namespace     string
+	ReleaseName   string
+	OutputDir     string
+	IncludeLogs   bool
+	IncludeYAML   bool
+	Verbose       bool
+	ControlSocket string
+	CheckFirewall bool
+	WireGuardPort int
+	// CheckAutoApprovers 检查 Headscale 策略的 autoApprovers 是否批准 headscale.autoApproverTag
+	CheckAutoApprovers bool
+	ConfigPath         string
+	// CNIDumpDir CNI 插件调用记录目录；ReplayCNI 为要重放的调用记录文件
+	CNIDumpDir string
+	ReplayCNI  string
+	CNIBinDir  string
 }
 
 type DiagnosticInfo struct {
@@ -27,9 +40,12 @@ type DiagnosticInfo struct {
 	Cluster   ClusterInfo            `json:"cluster"`
 	HeadCNI   HeadCNIInfo            `json:"headcni"`
 	Tailscale TailscaleInfo          `json:"tailscale"`
+	Daemon    *LocalDaemonStatus     `json:"daemon,omitempty"`
 	Network   NetworkInfo            `json:"network"`
 	Resources map[string]interface{} `json:"resources"`
 	Logs      map[string]string      `json:"logs,omitempty"`
+	// CNIInvocations 本节点最近的 CNI 插件调用输入，插件设置了 HEADCNI_CNI_DUMP_DIR 时才有
+	CNIInvocations []*cni.Invocation `json:"cni_invocations,omitempty"`
 }
 
 type ClusterInfo struct {
@@ -49,9 +65,11 @@ type HeadCNIInfo struct {
 }
 
 type TailscaleInfo struct {
-	Connected bool   `json:"connected"`
-	IP        string `json:"ip"`
-	Status    string `json:"status"`
+	Connected bool                `json:"connected"`
+	IP        string              `json:"ip"`
+	Status    string              `json:"status"`
+	DERP      *tailscale.DERPInfo `json:"derp,omitempty"`
+	DERPError string              `json:"derp_error,omitempty"`
 }
 
 type NetworkInfo struct {
@@ -77,6 +95,23 @@ This command will gather:
 - Resource manifests
 - Logs (optional)
 
+With --check-firewall, only node-local firewall checks are run: outbound
+reachability of the control server and DERP servers, an outbound UDP (STUN)
+probe, whether tailscaled listens on its WireGuard UDP port, and local
+iptables/nftables rules that would drop WireGuard traffic.
+
+With --check-auto-approvers, only the Headscale ACL policy is checked: every
+route advertised by the local tailscaled and the cluster Pod CIDR must be
+covered by an autoApprovers.routes entry that lists headscale.autoApproverTag.
+
+When run on a node with a HeadCNI daemon, the daemon's state and health
+snapshot are collected from its control socket.
+
+When the CNI plugins run with HEADCNI_CNI_DUMP_DIR set, the most recent
+invocations (network config and CNI_* environment, with embedded keys masked)
+are collected from --cni-dump-dir. --replay-cni runs the plugin again with the
+exact inputs of one recorded invocation.
+
 Examples:
   # Basic diagnostics
   headcni diagnostics
@@ -88,7 +123,16 @@ Examples:
   headcni diagnostics --output-dir ./diagnostics
 
   # Verbose output
-  headcni diagnostics --verbose`,
+  headcni diagnostics --verbose
+
+  # Check that the node firewall allows control, DERP and WireGuard traffic
+  headcni diagnostics --check-firewall
+
+  # Check that Headscale auto-approves the Pod CIDR routes for our tag
+  headcni diagnostics --check-auto-approvers --config /opt/headcni/config/daemon.yaml
+
+  # Re-run a recorded CNI invocation with the inputs kubelet passed
+  headcni diagnostics --replay-cni /var/log/headcni/cni-invocations/20260101T120000.000000000-add-0123456789ab.json`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return runDiagnostics(opts)
 		},
@@ -100,6 +144,14 @@ Examples:
 	cmd.Flags().BoolVar(&opts.IncludeLogs, "include-logs", false, "Include pod logs in diagnostics")
 	cmd.Flags().BoolVar(&opts.IncludeYAML, "include-yaml", false, "Include YAML manifests in diagnostics")
 	cmd.Flags().BoolVar(&opts.Verbose, "verbose", false, "Verbose output")
+	cmd.Flags().StringVar(&opts.ControlSocket, "control-socket", constants.DefaultControlSocketPath, "Local HeadCNI daemon control socket path")
+	cmd.Flags().BoolVar(&opts.CheckFirewall, "check-firewall", false, "Only check that the node firewall allows control, DERP and WireGuard traffic")
+	cmd.Flags().BoolVar(&opts.CheckAutoApprovers, "check-auto-approvers", false, "Only check that the Headscale policy auto-approves routes for headscale.autoApproverTag")
+	cmd.Flags().StringVar(&opts.ConfigPath, "config", "", "Path to daemon configuration file for --check-auto-approvers")
+	cmd.Flags().StringVar(&opts.CNIDumpDir, "cni-dump-dir", constants.DefaultCNIInvocationDir, "Directory the CNI plugins record their invocations to (HEADCNI_CNI_DUMP_DIR)")
+	cmd.Flags().StringVar(&opts.ReplayCNI, "replay-cni", "", "Only re-run the CNI plugin with the inputs of a recorded invocation file")
+	cmd.Flags().StringVar(&opts.CNIBinDir, "cni-bin-dir", "", "CNI plugin directory for --replay-cni (default: CNI_PATH of the recorded invocation)")
+	cmd.Flags().IntVar(&opts.WireGuardPort, "wireguard-port", 0, "tailscaled WireGuard UDP port for --check-firewall (default: read from the running tailscaled)")
 
 	return cmd
 }
@@ -108,6 +160,16 @@ func runDiagnostics(opts *DiagnosticsOptions) error {
 	// 显示 ASCII logo
 	showLogo()
 
+	if opts.CheckFirewall {
+		return runFirewallDiagnostics(opts)
+	}
+	if opts.CheckAutoApprovers {
+		return runAutoApproverDiagnostics(opts)
+	}
+	if opts.ReplayCNI != "" {
+		return runCNIReplay(opts)
+	}
+
 	fmt.Printf("🔍 Collecting HeadCNI diagnostics...\n")
 	fmt.Printf("Namespace: %s\n", opts.Namespace)
 	fmt.Printf("Release Name: %s\n", opts.ReleaseName)
@@ -145,9 +207,18 @@ func runDiagnostics(opts *DiagnosticsOptions) error {
 		diagnostics.HeadCNI = headcniInfo
 	}
 
+	// 本节点运行 daemon 时通过控制 socket 收集状态
+	daemon, err := queryLocalDaemon(opts.ControlSocket)
+	if err != nil {
+		fmt.Printf("⚠️  Warning: Failed to query local daemon: %v\n", err)
+	} else if daemon != nil {
+		fmt.Println("🩺 Collected local daemon status from control socket")
+		diagnostics.Daemon = daemon
+	}
+
 	// 收集Tailscale信息
 	fmt.Println("🔗 Collecting Tailscale information...")
-	tailscaleInfo, err := collectTailscaleInfo()
+	tailscaleInfo, err := collectTailscaleInfo(diagnostics.Daemon)
 	if err != nil {
 		fmt.Printf("⚠️  Warning: Failed to collect Tailscale info: %v\n", err)
 	} else {
@@ -163,6 +234,15 @@ func runDiagnostics(opts *DiagnosticsOptions) error {
 		diagnostics.Network = networkInfo
 	}
 
+	// 收集 CNI 插件调用记录
+	invocations, err := collectCNIInvocations(opts.CNIDumpDir, maxCollectedInvocations)
+	if err != nil {
+		fmt.Printf("⚠️  Warning: Failed to collect CNI invocations: %v\n", err)
+	} else if len(invocations) > 0 {
+		fmt.Printf("🧾 Collected %d CNI invocation(s) from %s\n", len(invocations), opts.CNIDumpDir)
+		diagnostics.CNIInvocations = invocations
+	}
+
 	// 收集资源清单
 	if opts.IncludeYAML {
 		fmt.Println("📄 Collecting resource manifests...")
@@ -188,6 +268,18 @@ func runDiagnostics(opts *DiagnosticsOptions) error {
 	return nil
 }
 
+// runFirewallDiagnostics 只运行本节点的防火墙检查，不需要集群连接
+func runFirewallDiagnostics(opts *DiagnosticsOptions) error {
+	fmt.Printf("🧱 Checking node firewall for Tailscale traffic...\n\n")
+
+	failed := printFirewallChecks(runFirewallChecks(opts.WireGuardPort))
+	if failed > 0 {
+		return fmt.Errorf("%d firewall check(s) failed", failed)
+	}
+	fmt.Println("\n✅ Firewall checks completed")
+	return nil
+}
+
 func collectClusterInfo() (ClusterInfo, error) {
 	info := ClusterInfo{}
 
@@ -284,24 +376,42 @@ func collectHeadCNIInfo(opts *DiagnosticsOptions) (HeadCNIInfo, error) {
 	return info, nil
 }
 
-func collectTailscaleInfo() (TailscaleInfo, error) {
+func collectTailscaleInfo(daemon *LocalDaemonStatus) (TailscaleInfo, error) {
 	info := TailscaleInfo{}
 
-	// 检查Tailscale状态
-	cmd := exec.Command("tailscale", "status")
-	if err := cmd.Run(); err == nil {
-		info.Connected = true
-		info.Status = "Connected"
+	if daemon != nil {
+		// 优先使用 daemon 控制接口报告的状态
+		info.Connected = daemon.TailscaleIP != ""
+		info.IP = daemon.TailscaleIP
+		info.Status = daemon.TailscaleState
+		if !info.Connected {
+			return info, nil
+		}
 	} else {
-		info.Connected = false
-		info.Status = "Disconnected"
-		return info, nil
+		// 检查Tailscale状态
+		cmd := exec.Command("tailscale", "status")
+		if err := cmd.Run(); err == nil {
+			info.Connected = true
+			info.Status = "Connected"
+		} else {
+			info.Connected = false
+			info.Status = "Disconnected"
+			return info, nil
+		}
+
+		// 获取Tailscale IP
+		cmd = exec.Command("tailscale", "ip")
+		if output, err := cmd.Output(); err == nil {
+			info.IP = strings.TrimSpace(string(output))
+		}
 	}
 
-	// 获取Tailscale IP
-	cmd = exec.Command("tailscale", "ip")
-	if output, err := cmd.Output(); err == nil {
-		info.IP = strings.TrimSpace(string(output))
+	// 获取 DERP 区域延迟和节点直连情况
+	derpInfo, err := collectDERPInfo(30 * time.Second)
+	if err != nil {
+		info.DERPError = err.Error()
+	} else {
+		info.DERP = derpInfo
 	}
 
 	return info, nil
@@ -465,8 +575,27 @@ func generateSummary(diagnostics *DiagnosticInfo) string {
 		summary.WriteString(fmt.Sprintf("  IP: %s\n", diagnostics.Tailscale.IP))
 		summary.WriteString(fmt.Sprintf("  Status: %s\n", diagnostics.Tailscale.Status))
 	}
+	if diagnostics.Tailscale.DERP != nil {
+		summary.WriteString(formatDERPInfo(diagnostics.Tailscale.DERP, "  "))
+	} else if diagnostics.Tailscale.DERPError != "" {
+		summary.WriteString(fmt.Sprintf("  DERP: %s\n", diagnostics.Tailscale.DERPError))
+	}
 	summary.WriteString("\n")
 
+	// 本节点 daemon 信息
+	if daemon := diagnostics.Daemon; daemon != nil {
+		summary.WriteString("Local Daemon:\n")
+		summary.WriteString(fmt.Sprintf("  Node: %s\n", daemon.NodeName))
+		summary.WriteString(fmt.Sprintf("  Version: %s\n", daemon.Version))
+		summary.WriteString(fmt.Sprintf("  Mode: %s\n", daemon.Mode))
+		summary.WriteString(fmt.Sprintf("  Health: %s\n", daemon.Health))
+		summary.WriteString(fmt.Sprintf("  Dry-run: %v\n", daemon.DryRun))
+		for name, serviceErr := range daemon.ServiceErrors {
+			summary.WriteString(fmt.Sprintf("  %s: %s\n", name, serviceErr))
+		}
+		summary.WriteString("\n")
+	}
+
 	// 网络信息
 	summary.WriteString("Network Information:\n")
 	summary.WriteString(fmt.Sprintf("  CNI Installed: %v\n", diagnostics.Network.CNIInstalled))
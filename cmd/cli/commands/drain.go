@@ -0,0 +1,293 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"os"
+	"time"
+
+	"github.com/binrclab/headcni/cmd/daemon/config"
+	"github.com/binrclab/headcni/pkg/backend/tailscale"
+	"github.com/binrclab/headcni/pkg/constants"
+	"github.com/binrclab/headcni/pkg/daemon"
+	"github.com/binrclab/headcni/pkg/headscale"
+	"github.com/binrclab/headcni/pkg/k8s"
+	"github.com/spf13/cobra"
+	"github.com/vishvananda/netlink"
+)
+
+// drainWithdrawalInterval 等待路由撤销时查询 Headscale 的间隔
+const drainWithdrawalInterval = 2 * time.Second
+
+type DrainOptions struct {
+	ConfigPath   string
+	SocketPath   string
+	HeadscaleURL string
+	APIKey       string
+	Timeout      time.Duration
+	CleanRules   bool
+	DeleteNode   bool
+	// RemoveAnnotations 删除 Kubernetes 节点上的 HeadCNI 注解
+	RemoveAnnotations bool
+	NodeName          string
+	Kubeconfig        string
+}
+
+func NewDrainCommand() *cobra.Command {
+	opts := &DrainOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "drain",
+		Short: "Withdraw this node from the tailnet before maintenance",
+		Long: `Withdraw the node this command runs on from cross-node tailnet routing.
+
+This command will:
+1. Withdraw the routes advertised by the local tailscaled
+2. Wait for Headscale to confirm the withdrawal
+3. Disable the node's routes in Headscale
+4. Expire the Headscale node so it is no longer trusted
+5. Optionally remove the HeadCNI ip rules on the host (--clean-rules)
+6. Optionally delete the Headscale node (--delete-node)
+7. Optionally remove the HeadCNI annotations from the Kubernetes node (--remove-annotations)
+
+Pods on the node keep running and local pod traffic is not affected; only
+traffic from other nodes over the tailnet stops. Stop the HeadCNI daemon on the
+node first, otherwise it re-advertises the Pod CIDR on its next reconcile.
+
+Examples:
+  # Drain using the daemon configuration
+  headcni drain --config /opt/headcni/config/daemon.yaml
+
+  # Drain, remove local ip rules and delete the node from Headscale
+  headcni drain --config /opt/headcni/config/daemon.yaml --clean-rules --delete-node
+
+  # Drain before removing HeadCNI from the node for good
+  headcni drain --config /opt/headcni/config/daemon.yaml --delete-node --remove-annotations`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDrain(opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.ConfigPath, "config", "", "Path to daemon configuration file (used to resolve Headscale and tailscaled settings)")
+	cmd.Flags().StringVar(&opts.SocketPath, "socket", "", "tailscaled socket path (overrides daemon config)")
+	cmd.Flags().StringVar(&opts.HeadscaleURL, "headscale-url", "", "Headscale server URL (overrides daemon config)")
+	cmd.Flags().StringVar(&opts.APIKey, "api-key", "", "Headscale API key (overrides daemon config)")
+	cmd.Flags().DurationVar(&opts.Timeout, "timeout", 2*time.Minute, "Timeout for waiting on route withdrawal")
+	cmd.Flags().BoolVar(&opts.CleanRules, "clean-rules", false, "Remove HeadCNI ip rules on this host")
+	cmd.Flags().BoolVar(&opts.DeleteNode, "delete-node", false, "Delete the node from Headscale after expiring it")
+	cmd.Flags().BoolVar(&opts.RemoveAnnotations, "remove-annotations", false, "Remove the HeadCNI annotations from the Kubernetes node")
+	cmd.Flags().StringVar(&opts.NodeName, "node", "", "Kubernetes node name for --remove-annotations (default: $NODE_NAME or hostname)")
+	cmd.Flags().StringVar(&opts.Kubeconfig, "kubeconfig", os.Getenv("KUBECONFIG"), "Path to kubeconfig for --remove-annotations and headscale.authKeySecretRef, in-cluster config is used when empty")
+
+	return cmd
+}
+
+func runDrain(opts *DrainOptions) error {
+	cfg, err := config.LoadConfig(opts.ConfigPath)
+	if err != nil {
+		return fmt.Errorf("failed to load daemon config: %v", err)
+	}
+	if opts.HeadscaleURL != "" {
+		cfg.Headscale.URL = opts.HeadscaleURL
+	}
+	if opts.APIKey != "" {
+		cfg.Headscale.AuthKey = opts.APIKey
+	} else if cfg.Headscale.AuthKeySecretRef.IsSet() {
+		if err := resolveDrainAuthKey(opts.Kubeconfig, cfg); err != nil {
+			return err
+		}
+	}
+	if cfg.Headscale.URL == "" || cfg.Headscale.AuthKey == "" {
+		return fmt.Errorf("headscale URL and API key are required, use --config or --headscale-url/--api-key")
+	}
+
+	headscaleClient, err := headscale.NewClient(&cfg.Headscale)
+	if err != nil {
+		return fmt.Errorf("failed to create headscale client: %v", err)
+	}
+
+	socketPath := opts.SocketPath
+	if socketPath == "" {
+		socketPath = drainSocketPath(cfg)
+	}
+	tsClient := tailscale.NewSimpleClient(socketPath)
+
+	ctx, cancel := context.WithTimeout(context.Background(), opts.Timeout+time.Minute)
+	defer cancel()
+
+	fmt.Printf("🚧 Draining node via %s\n", socketPath)
+
+	// 1. 找到本节点在 Headscale 中的记录
+	status, err := tsClient.GetStatus(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get tailscale status: %v", err)
+	}
+	if status.Self == nil || len(status.Self.TailscaleIPs) == 0 {
+		return fmt.Errorf("local tailscaled has no tailnet address")
+	}
+	tailscaleIP := status.Self.TailscaleIPs[0].String()
+
+	node, err := findHeadscaleNodeByIP(ctx, headscaleClient, tailscaleIP)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Node: %s (id %s, ip %s)\n", node.GivenName, node.ID, tailscaleIP)
+
+	// 2. 撤销本地通告的路由
+	prefs, err := tsClient.GetPrefs(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get tailscale preferences: %v", err)
+	}
+	routes := prefs.AdvertiseRoutes
+	if len(routes) == 0 {
+		fmt.Printf("ℹ️  No advertised routes to withdraw\n")
+	} else {
+		if err := tsClient.RemoveRoutes(ctx, routes...); err != nil {
+			return fmt.Errorf("failed to withdraw advertised routes: %v", err)
+		}
+		fmt.Printf("✅ Withdrew advertised routes: %v\n", routes)
+
+		// 3. 等待 Headscale 确认撤销
+		if err := waitForDrainWithdrawal(ctx, headscaleClient, node.ID, routes, opts.Timeout); err != nil {
+			showWarningMessage(fmt.Sprintf("Route withdrawal not confirmed: %v", err))
+		} else {
+			fmt.Printf("✅ Headscale confirmed route withdrawal\n")
+		}
+	}
+
+	// 4. 在 Headscale 中禁用本节点的路由
+	nodeRoutes, err := headscaleClient.GetNodeRoutes(ctx, node.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get routes of node %s: %v", node.ID, err)
+	}
+	for _, route := range nodeRoutes.Routes {
+		if !route.Enabled {
+			continue
+		}
+		if err := headscaleClient.DisableRoute(ctx, route.ID); err != nil {
+			return fmt.Errorf("failed to disable route %s: %v", route.Prefix, err)
+		}
+		fmt.Printf("✅ Disabled route %s in Headscale\n", route.Prefix)
+	}
+
+	// 5. 使节点过期，其他节点不再信任它
+	if _, err := headscaleClient.ExpireNode(ctx, node.ID); err != nil {
+		return fmt.Errorf("failed to expire node %s: %v", node.ID, err)
+	}
+	fmt.Printf("✅ Expired Headscale node %s\n", node.ID)
+
+	// 6. 可选：清理宿主机上的 ip rule
+	if opts.CleanRules {
+		removed, err := cleanupHeadcniIPRules()
+		if err != nil {
+			showWarningMessage(fmt.Sprintf("Failed to clean ip rules: %v", err))
+		} else {
+			fmt.Printf("✅ Removed %d HeadCNI ip rules\n", removed)
+		}
+	}
+
+	// 7. 可选：从 Headscale 删除节点
+	if opts.DeleteNode {
+		if err := headscaleClient.DeleteNode(ctx, node.ID); err != nil {
+			return fmt.Errorf("failed to delete node %s: %v", node.ID, err)
+		}
+		fmt.Printf("✅ Deleted Headscale node %s\n", node.ID)
+	}
+
+	// 8. 可选：删除 Kubernetes 节点上的 HeadCNI 注解
+	if opts.RemoveAnnotations {
+		nodeName := opts.NodeName
+		if nodeName == "" {
+			nodeName = os.Getenv("NODE_NAME")
+		}
+		if nodeName == "" {
+			if nodeName, err = os.Hostname(); err != nil {
+				return fmt.Errorf("failed to determine node name, use --node: %v", err)
+			}
+		}
+		if _, err := removeNodeAnnotations(opts.Kubeconfig, nodeName, false); err != nil {
+			showWarningMessage(fmt.Sprintf("Failed to remove node annotations: %v", err))
+		} else {
+			fmt.Printf("✅ Removed HeadCNI annotations from node %s\n", nodeName)
+		}
+	}
+
+	showSuccessMessage("Node drained")
+	return nil
+}
+
+// drainSocketPath 按 daemon 配置的模式确定 tailscaled socket 路径
+func drainSocketPath(cfg *config.Config) string {
+	if cfg.Tailscale.Mode == "host" {
+		return constants.DefaultTailscaleHostSocketPath
+	}
+	if cfg.Tailscale.Socket.Path != "" {
+		return cfg.Tailscale.Socket.Path
+	}
+	return constants.DefaultTailscaleDaemonSocketPath
+}
+
+// findHeadscaleNodeByIP 按 tailnet 地址查找 Headscale 节点
+func findHeadscaleNodeByIP(ctx context.Context, client headscale.HeadscaleAPI, ip string) (*headscale.Node, error) {
+	nodes, err := client.ListNodes(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list headscale nodes: %v", err)
+	}
+	for i := range nodes.Nodes {
+		for _, nodeIP := range nodes.Nodes[i].IPAddresses {
+			if nodeIP == ip {
+				return &nodes.Nodes[i], nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("no headscale node has address %s", ip)
+}
+
+// resolveDrainAuthKey 与 daemon 相同，从 headscale.authKeySecretRef 引用的 Secret 读取 API 密钥
+func resolveDrainAuthKey(kubeconfig string, cfg *config.Config) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	k8sClient := k8s.NewClient(&k8s.ClientConfig{KubeconfigPath: kubeconfig, Timeout: 15 * time.Second})
+	if err := k8sClient.Connect(ctx); err != nil {
+		return fmt.Errorf("failed to connect to kubernetes to read headscale.authKeySecretRef: %v", err)
+	}
+	defer k8sClient.Disconnect()
+
+	return daemon.ResolveAuthKey(ctx, k8sClient, cfg)
+}
+
+// waitForDrainWithdrawal 等待 Headscale 中的路由不再处于通告且启用的状态
+func waitForDrainWithdrawal(ctx context.Context, client headscale.HeadscaleAPI, nodeID string, routes []netip.Prefix, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	prefixes := make([]string, 0, len(routes))
+	for _, route := range routes {
+		prefixes = append(prefixes, route.String())
+	}
+	return headscale.WaitForRoutesWithdrawn(ctx, client, nodeID, prefixes, drainWithdrawalInterval, timeout)
+}
+
+// cleanupHeadcniIPRules 删除 HeadCNI daemon 添加的 ip rule，返回删除的数量
+func cleanupHeadcniIPRules() (int, error) {
+	rules, err := netlink.RuleList(netlink.FAMILY_V4)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list ip rules: %v", err)
+	}
+
+	removed := 0
+	for _, rule := range rules {
+		for _, priority := range constants.HeadcniRulePriorities {
+			if rule.Priority != priority {
+				continue
+			}
+			if err := netlink.RuleDel(&rule); err != nil {
+				return removed, fmt.Errorf("failed to delete rule with priority %d: %v", priority, err)
+			}
+			removed++
+		}
+	}
+	return removed, nil
+}
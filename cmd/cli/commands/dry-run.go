@@ -0,0 +1,88 @@
+package commands
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/binrclab/headcni/pkg/constants"
+	"github.com/spf13/cobra"
+)
+
+type DryRunOptions struct {
+	SocketPath string
+	Timeout    time.Duration
+}
+
+// dryRunState 与 daemon 只读模式接口的请求和响应对应
+type dryRunState struct {
+	Enabled bool `json:"enabled"`
+}
+
+func NewDryRunCommand() *cobra.Command {
+	opts := &DryRunOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "dry-run [on|off]",
+		Short: "Show or toggle the daemon's dry-run mode",
+		Long: `Show or toggle dry-run mode of the HeadCNI daemon on this node.
+
+In dry-run mode the route reconciler keeps running and logs every change it
+would make (advertised routes, Headscale route approvals, host IP rules),
+but does not apply any of them. Use it to observe reconcile decisions on a
+misbehaving node without the health loop mutating state.
+
+The setting lasts until it is toggled again or the daemon restarts; set
+daemon.dryRun in the config file to start in dry-run mode.
+
+Examples:
+  # Show the current mode
+  headcni dry-run
+
+  # Stop applying route changes
+  headcni dry-run on
+
+  # Resume normal operation
+  headcni dry-run off`,
+		Args:      cobra.MaximumNArgs(1),
+		ValidArgs: []string{"on", "off"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDryRun(opts, args)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.SocketPath, "socket", constants.DefaultControlSocketPath, "HeadCNI daemon control socket path")
+	cmd.Flags().DurationVar(&opts.Timeout, "timeout", 10*time.Second, "Timeout for the daemon request")
+
+	return cmd
+}
+
+func runDryRun(opts *DryRunOptions, args []string) error {
+	client := newControlClient(opts.SocketPath, opts.Timeout)
+
+	var state dryRunState
+	if len(args) == 0 {
+		if err := client.get("/dry-run", &state); err != nil {
+			return err
+		}
+	} else {
+		var enabled bool
+		switch args[0] {
+		case "on":
+			enabled = true
+		case "off":
+			enabled = false
+		default:
+			return fmt.Errorf("invalid argument %q, expected on or off", args[0])
+		}
+		if err := client.post("/dry-run", dryRunState{Enabled: enabled}, &state); err != nil {
+			return err
+		}
+	}
+
+	if state.Enabled {
+		showInfoMessage("Dry-run mode is ON, route changes are only logged")
+	} else {
+		showInfoMessage("Dry-run mode is OFF, route changes are applied")
+	}
+	return nil
+}
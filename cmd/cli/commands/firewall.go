@@ -0,0 +1,591 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/binrclab/headcni/pkg/backend/tailscale"
+	"github.com/binrclab/headcni/pkg/constants"
+)
+
+// firewallCheckTimeout 单项防火墙检查的超时时间
+const firewallCheckTimeout = 15 * time.Second
+
+// defaultWireGuardPort tailscaled 未指定 --port 时监听的 UDP 端口
+const defaultWireGuardPort = 41641
+
+// FirewallCheck 单项防火墙检查结果
+type FirewallCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"` // PASSED、WARNING、FAILED 或 SKIPPED
+	Detail string `json:"detail,omitempty"`
+	Hint   string `json:"hint,omitempty"` // 未通过时的修复建议
+}
+
+// runFirewallChecks 检查本节点防火墙是否放行控制服务器、DERP 和 WireGuard 流量
+// port 为 0 时从运行中的 tailscaled 进程参数中读取端口
+func runFirewallChecks(port int) []FirewallCheck {
+	socketPath, socketErr := findTailscaleSocket()
+	var client *tailscale.SimpleClient
+	if socketErr == nil {
+		client = tailscale.NewSimpleClient(socketPath)
+	}
+
+	ports := []int{port}
+	if port == 0 {
+		ports = tailscaledPorts()
+	}
+
+	checks := []FirewallCheck{
+		checkControlReachability(client, socketErr),
+		checkDERPReachability(client, socketErr),
+		checkOutboundUDP(client, socketErr),
+		checkWireGuardListening(ports),
+	}
+	return append(checks, checkFirewallRules(ports)...)
+}
+
+// findTailscaleSocket 返回本节点上第一个存在的 tailscaled socket，优先 daemon 模式的 socket
+func findTailscaleSocket() (string, error) {
+	for _, socketPath := range []string{constants.DefaultTailscaleDaemonSocketPath, constants.DefaultTailscaleHostSocketPath} {
+		if _, err := os.Stat(socketPath); err == nil {
+			return socketPath, nil
+		}
+	}
+	return "", fmt.Errorf("no tailscaled socket found on this node")
+}
+
+// checkControlReachability 检查到控制服务器的出站连接
+func checkControlReachability(client *tailscale.SimpleClient, socketErr error) FirewallCheck {
+	check := FirewallCheck{Name: "Control Server Reachability"}
+	if client == nil {
+		check.Status = "SKIPPED"
+		check.Detail = socketErr.Error()
+		return check
+	}
+
+	if err := client.CheckHeadscaleReachability(); err != nil {
+		check.Status = "FAILED"
+		check.Detail = err.Error()
+		check.Hint = "allow outbound TCP to the control URL port (usually 443) and check any HTTP proxy on the node"
+		return check
+	}
+	check.Status = "PASSED"
+	return check
+}
+
+// checkDERPReachability 检查到 DERP 区域的出站 TCP 连接（通常为 443）
+func checkDERPReachability(client *tailscale.SimpleClient, socketErr error) FirewallCheck {
+	check := FirewallCheck{Name: "DERP Reachability"}
+	if client == nil {
+		check.Status = "SKIPPED"
+		check.Detail = socketErr.Error()
+		return check
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), firewallCheckTimeout)
+	defer cancel()
+	info, err := client.GetDERPInfo(ctx)
+	if err != nil {
+		check.Status = "SKIPPED"
+		check.Detail = err.Error()
+		return check
+	}
+
+	var reachable, unreachable []string
+	for _, region := range info.Regions {
+		if region.Error == "" {
+			reachable = append(reachable, region.RegionCode)
+		} else {
+			unreachable = append(unreachable, region.RegionCode)
+		}
+	}
+	switch {
+	case len(info.Regions) == 0:
+		check.Status = "WARNING"
+		check.Detail = "DERP map has no regions"
+		check.Hint = "check the DERP configuration of the control server"
+	case len(reachable) == 0:
+		check.Status = "FAILED"
+		check.Detail = fmt.Sprintf("no DERP region reachable (%s)", strings.Join(unreachable, ", "))
+		check.Hint = "allow outbound TCP 443 to the DERP servers; without DERP, peers behind NAT cannot connect"
+	case len(unreachable) > 0:
+		check.Status = "WARNING"
+		check.Detail = fmt.Sprintf("unreachable DERP regions: %s", strings.Join(unreachable, ", "))
+		check.Hint = "allow outbound TCP 443 to all DERP servers, or restrict the DERP map to reachable regions"
+	default:
+		check.Status = "PASSED"
+		check.Detail = fmt.Sprintf("%d DERP region(s) reachable", len(reachable))
+	}
+	return check
+}
+
+// checkOutboundUDP 向 DERP 的 STUN 服务发送 UDP 探测，检查出站 UDP 是否被阻断
+func checkOutboundUDP(client *tailscale.SimpleClient, socketErr error) FirewallCheck {
+	check := FirewallCheck{Name: "Outbound UDP (STUN)"}
+	if client == nil {
+		check.Status = "SKIPPED"
+		check.Detail = socketErr.Error()
+		return check
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), firewallCheckTimeout)
+	defer cancel()
+	addr, err := client.ProbeSTUN(ctx)
+	if err != nil {
+		check.Status = "FAILED"
+		check.Detail = err.Error()
+		check.Hint = "allow outbound UDP (STUN 3478 and WireGuard); otherwise all traffic is relayed through DERP"
+		return check
+	}
+	check.Status = "PASSED"
+	check.Detail = fmt.Sprintf("public address %s", addr)
+	return check
+}
+
+// checkWireGuardListening 检查 tailscaled 是否已绑定 WireGuard UDP 端口
+func checkWireGuardListening(ports []int) FirewallCheck {
+	check := FirewallCheck{Name: "WireGuard UDP Port"}
+	if len(ports) == 0 {
+		check.Status = "SKIPPED"
+		check.Detail = "no tailscaled process found, use --wireguard-port"
+		return check
+	}
+
+	bound, err := boundUDPPorts()
+	if err != nil {
+		check.Status = "SKIPPED"
+		check.Detail = err.Error()
+		return check
+	}
+	var missing []string
+	for _, port := range ports {
+		if !bound[port] {
+			missing = append(missing, strconv.Itoa(port))
+		}
+	}
+	if len(missing) > 0 {
+		check.Status = "FAILED"
+		check.Detail = fmt.Sprintf("nothing listens on UDP %s", strings.Join(missing, ", "))
+		check.Hint = "check that tailscaled is running and that --port/--wireguard-port matches its --port argument"
+		return check
+	}
+	check.Status = "PASSED"
+	check.Detail = fmt.Sprintf("tailscaled listens on UDP %s", joinPorts(ports))
+	return check
+}
+
+// checkFirewallRules 检查本机 iptables 和 nftables 规则中会丢弃 WireGuard 或出站 443 流量的规则
+func checkFirewallRules(ports []int) []FirewallCheck {
+	if len(ports) == 0 {
+		ports = []int{defaultWireGuardPort}
+	}
+
+	var checks []FirewallCheck
+	for _, backend := range []struct {
+		name, command string
+		args          []string
+		find          func(string, []int) []string
+	}{
+		{"iptables Rules", "iptables-save", nil, findBlockingIptablesRules},
+		{"nftables Rules", "nft", []string{"list", "ruleset"}, findBlockingNftRules},
+	} {
+		check := FirewallCheck{Name: backend.name}
+		if _, err := exec.LookPath(backend.command); err != nil {
+			check.Status = "SKIPPED"
+			check.Detail = fmt.Sprintf("%s not installed", backend.command)
+			checks = append(checks, check)
+			continue
+		}
+
+		output, err := exec.Command(backend.command, backend.args...).Output()
+		if err != nil {
+			check.Status = "SKIPPED"
+			check.Detail = fmt.Sprintf("failed to run %s (root required): %v", backend.command, err)
+			checks = append(checks, check)
+			continue
+		}
+
+		if blocking := backend.find(string(output), ports); len(blocking) > 0 {
+			check.Status = "WARNING"
+			check.Detail = "rules that may drop Tailscale traffic:\n" + strings.Join(blocking, "\n")
+			check.Hint = fmt.Sprintf("accept UDP %s inbound and TCP 443 outbound before these rules", joinPorts(ports))
+		} else {
+			check.Status = "PASSED"
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// findBlockingIptablesRules 从 iptables-save 输出中找出丢弃 WireGuard UDP 端口或出站 TCP 443 的规则
+// 规则按出现顺序检查，不跟踪自定义链的跳转关系，结果只作为排查线索
+// 过滤表 INPUT/OUTPUT 默认策略为 DROP 且没有放行 WireGuard 端口的规则时也会报告
+// 不限定协议的兜底丢弃规则（如 "-A INPUT -i eth0 -j DROP"）同样会丢弃 WireGuard 流量，在此之前没有放行时报告
+func findBlockingIptablesRules(rules string, ports []int) []string {
+	var blocking, dropPolicies []string
+	table := ""
+	accepted := make(map[int]bool)
+	outboundAccepted := false
+
+	for _, line := range strings.Split(rules, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "*"):
+			table = strings.TrimPrefix(line, "*")
+			continue
+		case table != "filter" && table != "raw":
+			continue
+		case strings.HasPrefix(line, ":"):
+			fields := strings.Fields(line)
+			if len(fields) >= 2 && fields[1] == "DROP" && (fields[0] == ":INPUT" || fields[0] == ":OUTPUT") {
+				dropPolicies = append(dropPolicies, fmt.Sprintf("%s policy DROP", strings.TrimPrefix(fields[0], ":")))
+			}
+			continue
+		case !strings.HasPrefix(line, "-A "):
+			continue
+		}
+
+		fields := strings.Fields(line)
+		chain := fields[1]
+		target := iptablesArg(fields, "-j")
+		proto := iptablesArg(fields, "-p")
+		dports := parsePortList(iptablesArg(fields, "--dport") + "," + iptablesArg(fields, "--dports"))
+
+		if target == "ACCEPT" && proto == "udp" {
+			for _, port := range ports {
+				if portListContains(dports, port) {
+					accepted[port] = true
+				}
+			}
+			continue
+		}
+		if target == "ACCEPT" && proto == "tcp" && chain == "OUTPUT" && portListContains(dports, 443) {
+			outboundAccepted = true
+			continue
+		}
+		if target != "DROP" && target != "REJECT" {
+			continue
+		}
+		pending := unacceptedPorts(ports, accepted)
+		if proto == "" && isCatchAllIptablesRule(fields) {
+			if (chain == "INPUT" && len(pending) > 0) || (chain == "OUTPUT" && !outboundAccepted) {
+				blocking = append(blocking, line)
+			}
+		} else if proto == "udp" && len(pending) > 0 && (dports == nil || anyPortInList(dports, pending)) && chain != "FORWARD" {
+			blocking = append(blocking, line)
+		} else if proto == "tcp" && chain == "OUTPUT" && portListContains(dports, 443) {
+			blocking = append(blocking, line)
+		}
+	}
+
+	if len(unacceptedPorts(ports, accepted)) > 0 {
+		blocking = append(blocking, dropPolicies...)
+	}
+	return blocking
+}
+
+// findBlockingNftRules 从 nft list ruleset 输出中找出丢弃 WireGuard UDP 端口或出站 TCP 443 的规则
+// input 钩子链默认策略为 drop 且没有放行 WireGuard 端口的规则时也会报告
+// input/output 钩子链中不限定协议的兜底丢弃规则（如 "iifname "eth0" drop"）在此之前没有放行时同样报告
+func findBlockingNftRules(ruleset string, ports []int) []string {
+	var blocking, dropPolicies []string
+	chain, inputHook, outputHook := "", false, false
+	accepted := make(map[int]bool)
+	outboundAccepted := false
+
+	for _, line := range strings.Split(ruleset, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "chain ") {
+			chain = strings.TrimSuffix(strings.TrimSpace(strings.TrimPrefix(line, "chain ")), " {")
+			inputHook, outputHook = false, false
+			continue
+		}
+		if strings.Contains(line, "hook input") {
+			inputHook = true
+		}
+		if strings.Contains(line, "hook output") {
+			outputHook = true
+		}
+		if strings.Contains(line, "hook input") && strings.Contains(line, "policy drop") {
+			dropPolicies = append(dropPolicies, fmt.Sprintf("chain %s: %s", chain, line))
+			continue
+		}
+
+		verdict := ""
+		for _, v := range []string{"accept", "drop", "reject"} {
+			if strings.HasSuffix(line, v) || strings.HasPrefix(line, v+" ") || strings.Contains(line, " "+v+" ") {
+				verdict = v
+			}
+		}
+		if verdict == "" {
+			continue
+		}
+
+		udpPorts, udpAll := nftPorts(line, "udp")
+		tcpPorts, _ := nftPorts(line, "tcp")
+		if verdict == "accept" {
+			for _, port := range ports {
+				if portListContains(udpPorts, port) {
+					accepted[port] = true
+				}
+			}
+			if outputHook && portListContains(tcpPorts, 443) {
+				outboundAccepted = true
+			}
+			continue
+		}
+		pending := unacceptedPorts(ports, accepted)
+		if isCatchAllNftRule(line) {
+			if (inputHook && len(pending) > 0) || (outputHook && !outboundAccepted) {
+				blocking = append(blocking, fmt.Sprintf("chain %s: %s", chain, line))
+			}
+		} else if len(pending) > 0 && (udpAll || anyPortInList(udpPorts, pending)) {
+			blocking = append(blocking, fmt.Sprintf("chain %s: %s", chain, line))
+		} else if outputHook && portListContains(tcpPorts, 443) {
+			blocking = append(blocking, fmt.Sprintf("chain %s: %s", chain, line))
+		}
+	}
+
+	if len(unacceptedPorts(ports, accepted)) > 0 {
+		blocking = append(blocking, dropPolicies...)
+	}
+	return blocking
+}
+
+// nftPorts 解析 nft 规则中 proto 的目标端口，all 表示规则匹配该协议的所有端口
+func nftPorts(line, proto string) (ports [][2]int, all bool) {
+	marker := proto + " dport "
+	idx := strings.Index(line, marker)
+	if idx < 0 {
+		all = strings.Contains(line, "l4proto "+proto) || strings.Contains(line, "protocol "+proto)
+		return nil, all
+	}
+
+	rest := strings.TrimSpace(line[idx+len(marker):])
+	if strings.HasPrefix(rest, "{") {
+		if end := strings.Index(rest, "}"); end > 0 {
+			rest = rest[1:end]
+		}
+	} else if fields := strings.Fields(rest); len(fields) > 0 {
+		rest = fields[0]
+	}
+	return parsePortList(strings.ReplaceAll(strings.ReplaceAll(rest, " ", ""), "-", ":")), false
+}
+
+// isCatchAllIptablesRule 判断 iptables 规则是否匹配所有流量，只允许按接口和注释限定
+// 限定地址、连接状态或其他匹配模块的规则只丢弃部分流量，不算兜底规则
+func isCatchAllIptablesRule(fields []string) bool {
+	for i := 2; i < len(fields); i++ {
+		switch fields[i] {
+		case "-i", "-o", "-j", "--reject-with":
+			i++
+		case "-m":
+			if i+1 >= len(fields) || fields[i+1] != "comment" {
+				return false
+			}
+			i++
+		case "--comment":
+			// 带空格的注释被引号包围，跳到引号结束处
+			i++
+			quoted := i < len(fields) && strings.HasPrefix(fields[i], `"`)
+			for quoted && i+1 < len(fields) && !(len(fields[i]) > 1 && strings.HasSuffix(fields[i], `"`)) {
+				i++
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// isCatchAllNftRule 判断 nft 规则是否匹配所有流量，只允许按接口限定，计数、日志、注释和 reject 参数不影响匹配
+func isCatchAllNftRule(line string) bool {
+	fields := strings.Fields(line)
+	for i := 0; i < len(fields); i++ {
+		switch fields[i] {
+		case "counter", "accept", "drop", "reject":
+		case "packets", "bytes", "iifname", "oifname", "iif", "oif":
+			i++
+		case "log", "with", "comment":
+			// 之后只有日志参数、reject 类型或注释
+			return true
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// unacceptedPorts 返回 ports 中尚未被前面的规则放行的端口，放行之后的丢弃规则不影响这些端口
+func unacceptedPorts(ports []int, accepted map[int]bool) []int {
+	var pending []int
+	for _, port := range ports {
+		if !accepted[port] {
+			pending = append(pending, port)
+		}
+	}
+	return pending
+}
+
+// iptablesArg 返回 iptables 规则中选项 name 的值
+func iptablesArg(fields []string, name string) string {
+	for i, field := range fields {
+		if field == name && i+1 < len(fields) {
+			return fields[i+1]
+		}
+	}
+	return ""
+}
+
+// parsePortList 解析 "80,443,1000:2000" 形式的端口列表，为空时返回 nil
+func parsePortList(spec string) [][2]int {
+	var ranges [][2]int
+	for _, part := range strings.Split(spec, ",") {
+		if part == "" {
+			continue
+		}
+		low, high, isRange := strings.Cut(part, ":")
+		start, err := strconv.Atoi(low)
+		if err != nil {
+			continue
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(high); err != nil {
+				continue
+			}
+		}
+		ranges = append(ranges, [2]int{start, end})
+	}
+	return ranges
+}
+
+// portListContains 判断端口是否在端口列表中
+func portListContains(ranges [][2]int, port int) bool {
+	for _, r := range ranges {
+		if port >= r[0] && port <= r[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// anyPortInList 判断 ports 中是否有端口在端口列表中
+func anyPortInList(ranges [][2]int, ports []int) bool {
+	for _, port := range ports {
+		if portListContains(ranges, port) {
+			return true
+		}
+	}
+	return false
+}
+
+// tailscaledPorts 从 /proc 中运行的 tailscaled 进程参数读取 WireGuard 端口，未指定 --port 时为默认端口
+func tailscaledPorts() []int {
+	comms, _ := filepath.Glob("/proc/[0-9]*/comm")
+	seen := make(map[int]bool)
+	var ports []int
+	for _, comm := range comms {
+		name, err := os.ReadFile(comm)
+		if err != nil || strings.TrimSpace(string(name)) != "tailscaled" {
+			continue
+		}
+		cmdline, err := os.ReadFile(filepath.Join(filepath.Dir(comm), "cmdline"))
+		if err != nil {
+			continue
+		}
+
+		port := defaultWireGuardPort
+		args := strings.Split(strings.TrimRight(string(cmdline), "\x00"), "\x00")
+		for i, arg := range args {
+			value := ""
+			switch {
+			case (arg == "--port" || arg == "-port") && i+1 < len(args):
+				value = args[i+1]
+			case strings.HasPrefix(arg, "--port="), strings.HasPrefix(arg, "-port="):
+				value = arg[strings.Index(arg, "=")+1:]
+			}
+			if p, err := strconv.Atoi(value); err == nil && p > 0 {
+				port = p
+			}
+		}
+		if !seen[port] {
+			seen[port] = true
+			ports = append(ports, port)
+		}
+	}
+	return ports
+}
+
+// boundUDPPorts 读取 /proc/net/udp 和 /proc/net/udp6 中已绑定的本地 UDP 端口
+func boundUDPPorts() (map[int]bool, error) {
+	bound := make(map[int]bool)
+	found := false
+	for _, file := range []string{"/proc/net/udp", "/proc/net/udp6"} {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		found = true
+		for _, line := range strings.Split(string(data), "\n")[1:] {
+			fields := strings.Fields(line)
+			if len(fields) < 2 {
+				continue
+			}
+			_, hexPort, ok := strings.Cut(fields[1], ":")
+			if !ok {
+				continue
+			}
+			if port, err := strconv.ParseUint(hexPort, 16, 16); err == nil {
+				bound[int(port)] = true
+			}
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("/proc/net/udp not available")
+	}
+	return bound, nil
+}
+
+// joinPorts 格式化端口列表
+func joinPorts(ports []int) string {
+	parts := make([]string, len(ports))
+	for i, port := range ports {
+		parts[i] = strconv.Itoa(port)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// printFirewallChecks 输出防火墙检查结果和修复建议，返回未通过的检查数
+func printFirewallChecks(checks []FirewallCheck) int {
+	failed := 0
+	for _, check := range checks {
+		icon := "✅"
+		switch check.Status {
+		case "FAILED":
+			icon = "❌"
+			failed++
+		case "WARNING":
+			icon = "⚠️ "
+		case "SKIPPED":
+			icon = "⏭️ "
+		}
+		fmt.Printf("   %s %s\n", icon, check.Name)
+		if check.Detail != "" {
+			for _, line := range strings.Split(check.Detail, "\n") {
+				fmt.Printf("      %s\n", line)
+			}
+		}
+		if check.Hint != "" {
+			fmt.Printf("      💡 %s\n", check.Hint)
+		}
+	}
+	return failed
+}
@@ -0,0 +1,230 @@
+package commands
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// iptablesFilter 生成只包含 filter 表的 iptables-save 输出
+func iptablesFilter(inputPolicy string, rules ...string) string {
+	lines := []string{
+		"# Generated by iptables-save v1.8.7",
+		"*filter",
+		":INPUT " + inputPolicy + " [0:0]",
+		":FORWARD ACCEPT [0:0]",
+		":OUTPUT ACCEPT [0:0]",
+	}
+	lines = append(lines, rules...)
+	return strings.Join(append(lines, "COMMIT"), "\n")
+}
+
+func TestFindBlockingIptablesRules(t *testing.T) {
+	ports := []int{41641}
+	tests := []struct {
+		name  string
+		rules string
+		want  []string
+	}{
+		{
+			name:  "accept policy",
+			rules: iptablesFilter("ACCEPT", "-A INPUT -p tcp -m tcp --dport 22 -j ACCEPT"),
+		},
+		{
+			name:  "drop policy without accept",
+			rules: iptablesFilter("DROP", "-A INPUT -p tcp -m tcp --dport 22 -j ACCEPT"),
+			want:  []string{"INPUT policy DROP"},
+		},
+		{
+			name:  "drop policy with wireguard accepted",
+			rules: iptablesFilter("DROP", "-A INPUT -p udp -m udp --dport 41641 -j ACCEPT"),
+		},
+		{
+			name:  "udp port range dropped",
+			rules: iptablesFilter("ACCEPT", "-A INPUT -p udp -m multiport --dports 53,41000:42000 -j DROP"),
+			want:  []string{"-A INPUT -p udp -m multiport --dports 53,41000:42000 -j DROP"},
+		},
+		{
+			name: "udp drop after accept",
+			rules: iptablesFilter("ACCEPT",
+				"-A INPUT -p udp -m udp --dport 41641 -j ACCEPT",
+				"-A INPUT -p udp -j DROP"),
+		},
+		{
+			name:  "catch-all drop on interface",
+			rules: iptablesFilter("ACCEPT", "-A INPUT -i eth0 -j DROP"),
+			want:  []string{"-A INPUT -i eth0 -j DROP"},
+		},
+		{
+			name:  "catch-all reject with comment",
+			rules: iptablesFilter("ACCEPT", `-A INPUT -m comment --comment "default deny" -j REJECT --reject-with icmp-port-unreachable`),
+			want:  []string{`-A INPUT -m comment --comment "default deny" -j REJECT --reject-with icmp-port-unreachable`},
+		},
+		{
+			name: "catch-all drop after accept",
+			rules: iptablesFilter("ACCEPT",
+				"-A INPUT -p udp -m udp --dport 41641 -j ACCEPT",
+				"-A INPUT -j DROP"),
+		},
+		{
+			// 接受规则在兜底规则之后不起作用
+			name: "accept after catch-all drop",
+			rules: iptablesFilter("ACCEPT",
+				"-A INPUT -j DROP",
+				"-A INPUT -p udp -m udp --dport 41641 -j ACCEPT"),
+			want: []string{"-A INPUT -j DROP"},
+		},
+		{
+			name: "partial drops",
+			rules: iptablesFilter("ACCEPT",
+				"-A INPUT -s 203.0.113.0/24 -j DROP",
+				"-A INPUT -m conntrack --ctstate INVALID -j DROP",
+				"-A FORWARD -p udp -j DROP"),
+		},
+		{
+			name:  "outbound https dropped",
+			rules: iptablesFilter("ACCEPT", "-A OUTPUT -p tcp -m tcp --dport 443 -j REJECT --reject-with tcp-reset"),
+			want:  []string{"-A OUTPUT -p tcp -m tcp --dport 443 -j REJECT --reject-with tcp-reset"},
+		},
+		{
+			name: "outbound catch-all drop",
+			rules: iptablesFilter("ACCEPT",
+				"-A INPUT -p udp -m udp --dport 41641 -j ACCEPT",
+				"-A OUTPUT -o eth0 -j DROP"),
+			want: []string{"-A OUTPUT -o eth0 -j DROP"},
+		},
+		{
+			name: "outbound catch-all drop after https accepted",
+			rules: iptablesFilter("ACCEPT",
+				"-A OUTPUT -p tcp -m tcp --dport 443 -j ACCEPT",
+				"-A OUTPUT -j DROP"),
+		},
+		{
+			name: "nat table ignored",
+			rules: "*nat\n:PREROUTING ACCEPT [0:0]\n-A PREROUTING -j DROP\nCOMMIT\n" +
+				iptablesFilter("ACCEPT"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := findBlockingIptablesRules(tt.rules, ports); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+// nftRuleset 生成 inet filter 表中一条链的 nft list ruleset 输出
+func nftRuleset(chain, hook, policy string, rules ...string) string {
+	lines := []string{
+		"table inet filter {",
+		"\tchain " + chain + " {",
+		"\t\ttype filter hook " + hook + " priority filter; policy " + policy + ";",
+	}
+	for _, rule := range rules {
+		lines = append(lines, "\t\t"+rule)
+	}
+	return strings.Join(append(lines, "\t}", "}"), "\n")
+}
+
+func TestFindBlockingNftRules(t *testing.T) {
+	ports := []int{41641}
+	tests := []struct {
+		name    string
+		ruleset string
+		want    []string
+	}{
+		{
+			name:    "accept policy",
+			ruleset: nftRuleset("input", "input", "accept", "tcp dport 22 accept"),
+		},
+		{
+			name:    "drop policy without accept",
+			ruleset: nftRuleset("input", "input", "drop", "ct state established,related accept"),
+			want:    []string{"chain input: type filter hook input priority filter; policy drop;"},
+		},
+		{
+			name:    "drop policy with wireguard accepted",
+			ruleset: nftRuleset("input", "input", "drop", `udp dport 41641 accept comment "wireguard"`),
+		},
+		{
+			name:    "udp port range dropped",
+			ruleset: nftRuleset("input", "input", "accept", "udp dport { 53, 41000-42000 } counter packets 0 bytes 0 drop"),
+			want:    []string{"chain input: udp dport { 53, 41000-42000 } counter packets 0 bytes 0 drop"},
+		},
+		{
+			name:    "all udp dropped",
+			ruleset: nftRuleset("input", "input", "accept", "meta l4proto udp drop"),
+			want:    []string{"chain input: meta l4proto udp drop"},
+		},
+		{
+			name:    "catch-all drop on interface",
+			ruleset: nftRuleset("input", "input", "accept", `iifname "eth0" counter packets 12 bytes 960 drop`),
+			want:    []string{`chain input: iifname "eth0" counter packets 12 bytes 960 drop`},
+		},
+		{
+			name:    "catch-all reject",
+			ruleset: nftRuleset("input", "input", "accept", "reject with icmpx type port-unreachable"),
+			want:    []string{"chain input: reject with icmpx type port-unreachable"},
+		},
+		{
+			name:    "catch-all drop after accept",
+			ruleset: nftRuleset("input", "input", "accept", "udp dport 41641 accept", "counter drop"),
+		},
+		{
+			name: "partial drops",
+			ruleset: nftRuleset("input", "input", "accept",
+				"ip saddr 203.0.113.0/24 drop",
+				"ct state invalid drop"),
+		},
+		{
+			name:    "catch-all drop in forward chain",
+			ruleset: nftRuleset("forward", "forward", "accept", "drop"),
+		},
+		{
+			name:    "outbound https dropped",
+			ruleset: nftRuleset("output", "output", "accept", "tcp dport 443 reject with tcp reset"),
+			want:    []string{"chain output: tcp dport 443 reject with tcp reset"},
+		},
+		{
+			name:    "outbound catch-all drop",
+			ruleset: nftRuleset("output", "output", "accept", `oifname "eth0" drop`),
+			want:    []string{`chain output: oifname "eth0" drop`},
+		},
+		{
+			name:    "outbound catch-all drop after https accepted",
+			ruleset: nftRuleset("output", "output", "accept", "tcp dport 443 accept", "drop"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := findBlockingNftRules(tt.ruleset, ports); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestNftPorts(t *testing.T) {
+	tests := []struct {
+		line, proto string
+		ports       [][2]int
+		all         bool
+	}{
+		{line: "udp dport 41641 accept", proto: "udp", ports: [][2]int{{41641, 41641}}},
+		{line: "udp dport { 53, 41000-42000 } drop", proto: "udp", ports: [][2]int{{53, 53}, {41000, 42000}}},
+		{line: "meta l4proto udp drop", proto: "udp", all: true},
+		{line: "ip protocol udp drop", proto: "udp", all: true},
+		{line: "tcp dport 443 drop", proto: "udp"},
+		{line: "tcp dport 443 drop", proto: "tcp", ports: [][2]int{{443, 443}}},
+	}
+
+	for _, tt := range tests {
+		ports, all := nftPorts(tt.line, tt.proto)
+		if !reflect.DeepEqual(ports, tt.ports) || all != tt.all {
+			t.Errorf("nftPorts(%q, %q): expected %v, %v, got %v, %v", tt.line, tt.proto, tt.ports, tt.all, ports, all)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"os/exec"
 
+	"github.com/binrclab/headcni/pkg/constants"
 	"github.com/spf13/cobra"
 )
 
@@ -19,6 +20,14 @@ type InstallOptions struct {
 	ImageRepo    string
 	ImageTag     string
 	DryRun       bool
+	// Mode tailscale 模式：auto 按节点上是否有系统 tailscaled 选择 host 或 daemon
+	Mode string
+	// NodeSelector 本次安装的 DaemonSet 只调度到匹配的节点，异构集群按节点组分别安装
+	NodeSelector map[string]string
+	// PrepareNode 在当前节点上创建目录并写入 configlist 和 env.yaml
+	PrepareNode bool
+	NodeName    string
+	Kubeconfig  string
 }
 
 func NewInstallCommand() *cobra.Command {
@@ -43,6 +52,13 @@ Examples:
   headcni install --headscale-url https://headscale.company.com --auth-key YOUR_KEY \
     --pod-cidr 10.42.0.0/16 --ipam-type host-local
 
+  # Nodes that already run tailscaled, in host mode
+  headcni install --headscale-url https://headscale.company.com --auth-key YOUR_KEY \
+    --mode host --node-selector headcni.io/mode=host --release-name headcni-host
+
+  # Detect the mode on this node and write its CNI config
+  headcni install --headscale-url https://headscale.company.com --auth-key YOUR_KEY --prepare-node
+
   # Dry run
   headcni install --headscale-url https://headscale.company.com --auth-key YOUR_KEY --dry-run`,
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -61,10 +77,15 @@ Examples:
 	cmd.Flags().StringVar(&opts.ReleaseName, "release-name", "headcni", "Helm release name")
 	cmd.Flags().StringVar(&opts.PodCIDR, "pod-cidr", "10.244.0.0/16", "Pod CIDR")
 	cmd.Flags().StringVar(&opts.ServiceCIDR, "service-cidr", "10.96.0.0/16", "Service CIDR")
-	cmd.Flags().StringVar(&opts.IPAMType, "ipam-type", "host-local", "IPAM type (host-local or host-local)")
+	cmd.Flags().StringVar(&opts.IPAMType, "ipam-type", "host-local", "IPAM type (host-local, kube-backed or host-local-delegate)")
 	cmd.Flags().StringVar(&opts.ImageRepo, "image-repo", "binrc/headcni", "Docker image repository")
 	cmd.Flags().StringVar(&opts.ImageTag, "image-tag", "latest", "Docker image tag")
 	cmd.Flags().BoolVar(&opts.DryRun, "dry-run", false, "Show what would be installed without actually installing")
+	cmd.Flags().StringVar(&opts.Mode, "mode", installModeAuto, "Tailscale mode: auto (host if a system tailscaled socket exists on this node, otherwise daemon), host or daemon")
+	cmd.Flags().StringToStringVar(&opts.NodeSelector, "node-selector", nil, "Only schedule the DaemonSet on nodes with these labels (key=value), e.g. to install host and daemon mode as separate releases")
+	cmd.Flags().BoolVar(&opts.PrepareNode, "prepare-node", false, "Also prepare this node: create the state, socket and CNI directories and write the conflist and env for the chosen mode")
+	cmd.Flags().StringVar(&opts.NodeName, "node", "", "Node name used by --prepare-node (defaults to $NODE_NAME, then the hostname)")
+	cmd.Flags().StringVar(&opts.Kubeconfig, "kubeconfig", os.Getenv("KUBECONFIG"), "Path to kubeconfig used by --prepare-node, in-cluster config is used when empty")
 
 	return cmd
 }
@@ -81,13 +102,31 @@ func runInstall(opts *InstallOptions) error {
 	fmt.Printf("Service CIDR: %s\n", opts.ServiceCIDR)
 	fmt.Printf("IPAM Type: %s\n", opts.IPAMType)
 	fmt.Printf("Image: %s:%s\n", opts.ImageRepo, opts.ImageTag)
-	fmt.Printf("Dry Run: %v\n\n", opts.DryRun)
+	fmt.Printf("Dry Run: %v\n", opts.DryRun)
+
+	mode, reason, err := detectInstallMode(opts.Mode, constants.DefaultTailscaleHostSocketPath)
+	if err != nil {
+		return err
+	}
+	opts.Mode = mode
+	fmt.Printf("Mode: %s (%s)\n", mode, reason)
+	if len(opts.NodeSelector) > 0 {
+		fmt.Printf("Node Selector: %s\n", helmNodeSelectorArgs(opts.NodeSelector))
+	}
+	fmt.Println()
 
 	// 检查前置条件
 	if err := checkPrerequisites(); err != nil {
 		return fmt.Errorf("prerequisites check failed: %v", err)
 	}
 
+	// 准备当前节点
+	if opts.PrepareNode {
+		if err := prepareNode(opts, mode); err != nil {
+			return fmt.Errorf("failed to prepare node: %v", err)
+		}
+	}
+
 	// 检查集群连接
 	if err := checkClusterConnection(); err != nil {
 		return fmt.Errorf("cluster connection failed: %v", err)
@@ -202,6 +241,7 @@ func buildHelmCommand(opts *InstallOptions) string {
 		"--set config.network.podCIDRBase=%s "+
 		"--set config.network.serviceCIDR=%s "+
 		"--set config.ipam.type=%s "+
+		"--set config.tailscale.mode=%s "+
 		"--set image.repository=%s "+
 		"--set image.tag=%s",
 		opts.ReleaseName, chartPath,
@@ -211,9 +251,14 @@ func buildHelmCommand(opts *InstallOptions) string {
 		opts.PodCIDR,
 		opts.ServiceCIDR,
 		opts.IPAMType,
+		opts.Mode,
 		opts.ImageRepo,
 		opts.ImageTag)
 
+	if len(opts.NodeSelector) > 0 {
+		cmd += " " + helmNodeSelectorArgs(opts.NodeSelector)
+	}
+
 	return cmd
 }
 
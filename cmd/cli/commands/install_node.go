@@ -0,0 +1,169 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/binrclab/headcni/cmd/daemon/config"
+	"github.com/binrclab/headcni/pkg/cni"
+	"github.com/binrclab/headcni/pkg/constants"
+	"github.com/binrclab/headcni/pkg/daemon"
+	"github.com/binrclab/headcni/pkg/k8s"
+	"github.com/binrclab/headcni/pkg/logging"
+)
+
+// install --mode 的取值
+const (
+	installModeAuto   = "auto"
+	installModeHost   = "host"
+	installModeDaemon = "daemon"
+)
+
+// nodeDirectory 节点上需要存在的目录及其权限
+type nodeDirectory struct {
+	path string
+	perm os.FileMode
+}
+
+// detectInstallMode 确定安装模式：显式指定时直接使用，auto 时节点上存在系统 tailscaled 的 socket 则使用 host 模式，否则使用 daemon 模式
+// 返回选择的模式及原因
+func detectInstallMode(requested, hostSocketPath string) (string, string, error) {
+	switch requested {
+	case installModeHost, installModeDaemon:
+		return requested, "set by --mode", nil
+	case "", installModeAuto:
+	default:
+		return "", "", fmt.Errorf("invalid --mode %q, expected %s, %s or %s", requested, installModeAuto, installModeHost, installModeDaemon)
+	}
+
+	info, err := os.Stat(hostSocketPath)
+	switch {
+	case err == nil && info.Mode()&os.ModeSocket != 0:
+		return installModeHost, fmt.Sprintf("found a system tailscaled socket at %s", hostSocketPath), nil
+	case err == nil:
+		return installModeDaemon, fmt.Sprintf("%s exists but is not a socket", hostSocketPath), nil
+	case os.IsNotExist(err):
+		return installModeDaemon, fmt.Sprintf("no system tailscaled socket at %s", hostSocketPath), nil
+	default:
+		return installModeDaemon, fmt.Sprintf("cannot access %s: %v", hostSocketPath, err), nil
+	}
+}
+
+// nodeDirectories 各模式在节点上需要的目录
+// 两种模式都需要 CNI 配置目录和保存 env.yaml 的状态目录；daemon 模式的状态目录还保存 tailscaled 的节点密钥，仅 root 可读
+func nodeDirectories(mode string) []nodeDirectory {
+	dirs := []nodeDirectory{
+		{path: constants.DefaultCNIConfigDir, perm: 0755},
+		{path: constants.DefaultLogDir, perm: 0755},
+		{path: constants.DefaultTailscaleDaemonStateDir, perm: 0755},
+	}
+	if mode == installModeDaemon {
+		dirs[2].perm = 0700
+		dirs = append(dirs, nodeDirectory{path: constants.DefaultTailscaleDaemonDir, perm: 0755})
+	}
+	return dirs
+}
+
+// ensureNodeDirectories 创建目录并修正权限
+func ensureNodeDirectories(dirs []nodeDirectory, dryRun bool) error {
+	for _, dir := range dirs {
+		if dryRun {
+			fmt.Printf("Would ensure directory %s (%04o)\n", dir.path, dir.perm)
+			continue
+		}
+		if err := os.MkdirAll(dir.path, dir.perm); err != nil {
+			return fmt.Errorf("failed to create %s: %v", dir.path, err)
+		}
+		// MkdirAll 不修改已存在目录的权限
+		if err := os.Chmod(dir.path, dir.perm); err != nil {
+			return fmt.Errorf("failed to set permissions of %s: %v", dir.path, err)
+		}
+		fmt.Printf("✅ Directory %s ready (%04o)\n", dir.path, dir.perm)
+	}
+	return nil
+}
+
+// prepareNode 在当前节点上按模式准备目录，并写入 configlist 和 env.yaml
+func prepareNode(opts *InstallOptions, mode string) error {
+	fmt.Printf("🖥️  Preparing this node for %s mode...\n", mode)
+
+	if err := ensureNodeDirectories(nodeDirectories(mode), opts.DryRun); err != nil {
+		return err
+	}
+
+	cfg, err := config.DefaultConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load default config: %v", err)
+	}
+	cfg.Tailscale.Mode = mode
+	cfg.Headscale.URL = opts.HeadscaleURL
+	cfg.Network.PodCIDR.Base = opts.PodCIDR
+	cfg.Network.ServiceCIDR = opts.ServiceCIDR
+	cfg.IPAM.Type = opts.IPAMType
+
+	nodeName := opts.NodeName
+	if nodeName == "" {
+		nodeName = os.Getenv("NODE_NAME")
+	}
+	if nodeName == "" {
+		if nodeName, err = os.Hostname(); err != nil {
+			return fmt.Errorf("failed to determine node name, use --node: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	k8sClient := k8s.NewClient(&k8s.ClientConfig{KubeconfigPath: opts.Kubeconfig, Timeout: 15 * time.Second, DNSNamespaces: cfg.DNS.ServiceNamespaces})
+	if err := k8sClient.Connect(ctx); err != nil {
+		return err
+	}
+	defer k8sClient.Disconnect()
+
+	node, err := k8sClient.Nodes().Get(ctx, nodeName)
+	if err != nil {
+		return fmt.Errorf("failed to get node %s: %v", nodeName, err)
+	}
+	if len(k8s.NodePodCIDRs(node)) == 0 && node.Annotations[constants.HeadcniPodCIDROverrideAnnotationKey] == "" {
+		// daemon 启动后按 network.podCIDR.fallback 等规则确定子网并写入配置
+		fmt.Printf("⚠️  Node %s has no Pod CIDR yet, the daemon writes the CNI config when it starts\n", nodeName)
+		return nil
+	}
+	if opts.DryRun {
+		// 节点 Pod CIDR 等于聚合网段时生成配置会认领节点切片，dry-run 不生成
+		fmt.Printf("Would write %s and %s for node %s\n", filepath.Join(constants.DefaultCNIConfigDir, constants.DefaultHeadCNIConfigFile), constants.DefaultCNIEnvFile, nodeName)
+		return nil
+	}
+
+	// 与 daemon 启动时相同的规则确定节点网段和集群 DNS，确定不了时交给 daemon
+	cniConfigManager := cni.NewCNIConfigManager(constants.DefaultCNIConfigDir, constants.DefaultHeadCNIConfigFile, constants.DefaultCNIEnvFile, logging.NewSimpleLogger())
+	configList, cniEnv, err := daemon.GenerateNodeCNIConfig(cfg, k8sClient, cniConfigManager, nodeName)
+	if err != nil {
+		fmt.Printf("⚠️  Cannot generate the CNI config for node %s yet (%v), the daemon writes it when it starts\n", nodeName, err)
+		return nil
+	}
+	if err := cniConfigManager.WriteConfigListAndEnv(configList, cniEnv); err != nil {
+		return err
+	}
+	fmt.Printf("✅ Wrote %s and %s for node %s (subnet %s)\n", cniConfigManager.GetConfigPath(), constants.DefaultCNIEnvFile, nodeName, cniEnv.Subnet)
+	return nil
+}
+
+// helmNodeSelectorArgs 把 --node-selector 转换为 Helm 的 --set-string 参数，键中的点需要转义
+func helmNodeSelectorArgs(selector map[string]string) string {
+	keys := make([]string, 0, len(selector))
+	for key := range selector {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var args []string
+	for _, key := range keys {
+		args = append(args, fmt.Sprintf("--set-string 'nodeSelector.%s=%s'", strings.ReplaceAll(key, ".", `\.`), selector[key]))
+	}
+	return strings.Join(args, " ")
+}
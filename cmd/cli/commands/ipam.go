@@ -0,0 +1,344 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/binrclab/headcni/cmd/daemon/config"
+	"github.com/binrclab/headcni/pkg/cni"
+	"github.com/binrclab/headcni/pkg/constants"
+	"github.com/binrclab/headcni/pkg/ipam"
+	"github.com/binrclab/headcni/pkg/k8s"
+	"github.com/binrclab/headcni/pkg/logging"
+	"github.com/spf13/cobra"
+)
+
+type IPAMListOptions struct {
+	SocketPath string
+	Timeout    time.Duration
+	JSON       bool
+}
+
+type IPAMCheckOptions struct {
+	ConfigPath string
+	EnvFile    string
+	Kubeconfig string
+	NodeName   string
+	DataDir    string
+	Timeout    time.Duration
+	JSON       bool
+}
+
+// IPAMCheckResult CNI 环境与节点 Pod CIDR 的比对结果
+type IPAMCheckResult struct {
+	Node          string `json:"node"`
+	EnvSubnet     string `json:"envSubnet"`
+	NodePodCIDR   string `json:"nodePodCIDR"`
+	SubnetMatches bool   `json:"subnetMatches"`
+	Gateway       string `json:"gateway"`
+	GatewayInside bool   `json:"gatewayInside"`
+	IPAMType      string `json:"ipamType"`
+	Used          int    `json:"used"`
+	Free          int    `json:"free"`
+	Total         int    `json:"total"`
+	// OutsideSubnet 存储中不在节点 Pod CIDR 内的分配数
+	OutsideSubnet int `json:"outsideSubnet"`
+}
+
+func NewIPAMCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "ipam",
+		Short: "Inspect IP address allocations on this node",
+		Long: `Inspect the IPAM state of the HeadCNI daemon on this node.
+
+Use the subcommands to look at the allocation table when debugging IP leaks
+or address exhaustion, or to verify the CNI environment against the node.`,
+	}
+
+	cmd.AddCommand(newIPAMListCommand())
+	cmd.AddCommand(newIPAMCheckCommand())
+	return cmd
+}
+
+func newIPAMListCommand() *cobra.Command {
+	opts := &IPAMListOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the current IP allocations on this node",
+		Long: `List the IP allocations of the HeadCNI daemon on this node.
+
+For each allocation the container ID, pod namespace/name (when the daemon
+recorded them), IP and allocation time are shown, followed by the used and
+free address counts of the node subnet. With host-local IPAM the table is
+read from the host-local store; with kube-backed IPAM it is read from the
+node's allocation ConfigMap.
+
+The command talks to the daemon through its local control socket and must
+run as root on the node.
+
+Examples:
+  # Show the allocation table
+  headcni ipam list
+
+  # Machine-readable output
+  headcni ipam list --json`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runIPAMList(opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.SocketPath, "socket", constants.DefaultControlSocketPath, "HeadCNI daemon control socket path")
+	cmd.Flags().DurationVar(&opts.Timeout, "timeout", 15*time.Second, "Timeout for the daemon request")
+	cmd.Flags().BoolVar(&opts.JSON, "json", false, "Print the allocation table as JSON")
+
+	return cmd
+}
+
+func runIPAMList(opts *IPAMListOptions) error {
+	client := newControlClient(opts.SocketPath, opts.Timeout)
+	if !client.available() {
+		return fmt.Errorf("control socket %s not found, run this command on a node with the HeadCNI daemon", opts.SocketPath)
+	}
+
+	var table ipam.AllocationTable
+	if err := client.get("/ipam", &table); err != nil {
+		return err
+	}
+
+	if opts.JSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(table)
+	}
+
+	if len(table.Allocations) == 0 {
+		showInfoMessage("No IP allocations on this node")
+	} else {
+		rows := make([][]string, 0, len(table.Allocations))
+		for _, allocation := range table.Allocations {
+			pod := "-"
+			if allocation.PodName != "" {
+				pod = allocation.PodNamespace + "/" + allocation.PodName
+			}
+			allocatedAt := "-"
+			if !allocation.AllocatedAt.IsZero() {
+				allocatedAt = allocation.AllocatedAt.Local().Format(time.RFC3339)
+			}
+			rows = append(rows, []string{allocation.ContainerID, pod, allocation.IP, allocatedAt})
+		}
+		showTable([]string{"Container ID", "Pod", "IP", "Allocated At"}, rows)
+	}
+
+	subnet := table.Subnet
+	if subnet == "" {
+		subnet = "unknown"
+	}
+	if table.Total < 0 {
+		showInfoMessage(fmt.Sprintf("IPAM %s, subnet %s: %d used", table.Type, subnet, table.Used))
+	} else {
+		showInfoMessage(fmt.Sprintf("IPAM %s, subnet %s: %d used, %d free, %d total",
+			table.Type, subnet, table.Used, table.Free, table.Total))
+	}
+	return nil
+}
+
+func newIPAMCheckCommand() *cobra.Command {
+	opts := &IPAMCheckOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "check",
+		Short: "Verify the CNI environment subnet against the node's Pod CIDR",
+		Long: `Verify that the CNI environment written by the daemon still matches the node.
+
+The command reads the CNI environment file (env.yaml), fetches the node's
+live Pod CIDR from the Kubernetes API and reports:
+  - whether the environment subnet matches the node Pod CIDR
+  - whether the Pod gateway is inside the node Pod CIDR
+  - how many addresses are allocated and free in the host-local store
+
+It does not need a running daemon, and exits non-zero when the subnet or
+gateway does not match, so it can be used in node validation jobs. Run it on
+the node as root; outside the cluster pass --kubeconfig and --node.
+
+Examples:
+  # Check this node using the in-cluster service account
+  headcni ipam check
+
+  # Check from a node shell with an explicit kubeconfig
+  headcni ipam check --kubeconfig /etc/kubernetes/kubelet.conf --node worker-1`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runIPAMCheck(opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.ConfigPath, "config", "", "Path to daemon configuration file (used to resolve the IPAM data directory)")
+	cmd.Flags().StringVar(&opts.EnvFile, "env", constants.DefaultCNIEnvFile, "CNI environment file written by the daemon")
+	cmd.Flags().StringVar(&opts.Kubeconfig, "kubeconfig", os.Getenv("KUBECONFIG"), "Path to kubeconfig, in-cluster config is used when empty")
+	cmd.Flags().StringVar(&opts.NodeName, "node", "", "Node name (defaults to $NODE_NAME, then the hostname)")
+	cmd.Flags().StringVar(&opts.DataDir, "data-dir", "", "host-local data directory (overrides daemon config)")
+	cmd.Flags().DurationVar(&opts.Timeout, "timeout", 15*time.Second, "Timeout for the Kubernetes API request")
+	cmd.Flags().BoolVar(&opts.JSON, "json", false, "Print the check result as JSON")
+
+	return cmd
+}
+
+func runIPAMCheck(opts *IPAMCheckOptions) error {
+	cfg, err := config.LoadConfig(opts.ConfigPath)
+	if err != nil {
+		return fmt.Errorf("failed to load daemon config: %v", err)
+	}
+	dataDir := cfg.IPAM.LeakReconcile.DataDir
+	if opts.DataDir != "" {
+		dataDir = opts.DataDir
+	}
+
+	nodeName := opts.NodeName
+	if nodeName == "" {
+		nodeName = os.Getenv("NODE_NAME")
+	}
+	if nodeName == "" {
+		if nodeName, err = os.Hostname(); err != nil {
+			return fmt.Errorf("failed to determine node name, use --node: %v", err)
+		}
+	}
+
+	// 1. 读取 daemon 写入的 CNI 环境
+	cniConfigManager := cni.NewCNIConfigManager(constants.DefaultCNIConfigDir, constants.DefaultHeadCNIConfigFile, opts.EnvFile, logging.NewSimpleLogger())
+	cniEnv, err := cniConfigManager.ReadCniEnv()
+	if err != nil {
+		return err
+	}
+	_, envSubnet, err := net.ParseCIDR(cniEnv.Subnet)
+	if err != nil {
+		return fmt.Errorf("invalid subnet %q in %s: %v", cniEnv.Subnet, opts.EnvFile, err)
+	}
+
+	// 2. 从 API 读取节点当前的 Pod CIDR
+	ctx, cancel := context.WithTimeout(context.Background(), opts.Timeout)
+	defer cancel()
+
+	k8sClient := k8s.NewClient(&k8s.ClientConfig{KubeconfigPath: opts.Kubeconfig, Timeout: opts.Timeout})
+	if err := k8sClient.Connect(ctx); err != nil {
+		return err
+	}
+	defer k8sClient.Disconnect()
+
+	node, err := k8sClient.Nodes().Get(ctx, nodeName)
+	if err != nil {
+		return fmt.Errorf("failed to get node %s: %v", nodeName, err)
+	}
+	podCIDRs := k8s.NodePodCIDRs(node)
+	// Spec.PodCIDR 为空时 daemon 依次使用 headcni.io/pod-cidr 注解和 network.podCIDR.fallback，
+	// 后者由所有节点共用，daemon 从中派生的节点切片记录在 headcni.pod.cidr 注解中
+	if len(podCIDRs) == 0 {
+		if override := node.Annotations[constants.HeadcniPodCIDROverrideAnnotationKey]; override != "" {
+			podCIDRs = []string{override}
+		} else if cfg.Network.PodCIDR.Fallback != "" {
+			if slice, _, _ := strings.Cut(node.Annotations[constants.HeadcniPodCIDRAnnotationKey], ","); slice != "" {
+				podCIDRs = []string{slice}
+			}
+		}
+	}
+	if len(podCIDRs) == 0 {
+		return fmt.Errorf("node %s has no Pod CIDR assigned", nodeName)
+	}
+	_, nodeSubnet, err := net.ParseCIDR(podCIDRs[0])
+	if err != nil {
+		return fmt.Errorf("invalid Pod CIDR %q on node %s: %v", podCIDRs[0], nodeName, err)
+	}
+
+	// 3. 比较子网和网关；插件使用环境子网的第一个地址作为网关，路由显式指定时以其为准
+	gateway := ipam.SubnetGateway(envSubnet)
+	for _, route := range cniEnv.Routes {
+		if ip := net.ParseIP(route.GW); ip != nil {
+			gateway = ip
+			break
+		}
+	}
+
+	ipamType := cniEnv.IPAM
+	if ipamType == "" {
+		ipamType = ipam.TypeHostLocal
+	}
+	result := &IPAMCheckResult{
+		Node:          nodeName,
+		EnvSubnet:     envSubnet.String(),
+		NodePodCIDR:   nodeSubnet.String(),
+		SubnetMatches: envSubnet.String() == nodeSubnet.String(),
+		Gateway:       gateway.String(),
+		GatewayInside: nodeSubnet.Contains(gateway),
+		IPAMType:      ipamType,
+		Total:         -1,
+		Free:          -1,
+	}
+
+	// 4. 统计 host-local 存储中的分配；kube-backed 的分配保存在 ConfigMap，由 ipam list 查看
+	if ipam.UsesHostLocalStore(ipamType) {
+		networkName := "cbr0"
+		if configList, err := cniConfigManager.ReadConfigList(); err == nil && configList.Name != "" {
+			networkName = configList.Name
+		}
+		table, err := ipam.HostLocalAllocationTable(ipam.HostLocalStoreDir(dataDir, networkName), ipam.DefaultPodMetadataDir, nodeSubnet, cniEnv.Reserved)
+		if err != nil {
+			return err
+		}
+		result.Used, result.Free, result.Total = table.Used, table.Free, table.Total
+		for _, allocation := range table.Allocations {
+			if ip := net.ParseIP(allocation.IP); ip != nil && !nodeSubnet.Contains(ip) {
+				result.OutsideSubnet++
+			}
+		}
+	}
+
+	if opts.JSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(result); err != nil {
+			return err
+		}
+	} else {
+		printIPAMCheck(result)
+	}
+
+	if !result.SubnetMatches || !result.GatewayInside {
+		return fmt.Errorf("CNI environment of node %s does not match its Pod CIDR %s", nodeName, result.NodePodCIDR)
+	}
+	return nil
+}
+
+func printIPAMCheck(result *IPAMCheckResult) {
+	showSectionHeader(fmt.Sprintf("IPAM check for node %s", result.Node))
+
+	if result.SubnetMatches {
+		showSuccessMessage(fmt.Sprintf("env subnet %s matches node Pod CIDR", result.EnvSubnet))
+	} else {
+		showErrorMessage(fmt.Sprintf("env subnet %s does not match node Pod CIDR %s", result.EnvSubnet, result.NodePodCIDR))
+	}
+
+	if result.GatewayInside {
+		showSuccessMessage(fmt.Sprintf("gateway %s is inside %s", result.Gateway, result.NodePodCIDR))
+	} else {
+		showErrorMessage(fmt.Sprintf("gateway %s is outside %s", result.Gateway, result.NodePodCIDR))
+	}
+
+	if !ipam.UsesHostLocalStore(result.IPAMType) {
+		showInfoMessage(fmt.Sprintf("IPAM %s keeps allocations in a ConfigMap, use 'headcni ipam list' to inspect them", result.IPAMType))
+		return
+	}
+	if result.Total < 0 {
+		showInfoMessage(fmt.Sprintf("IPAM %s: %d used", result.IPAMType, result.Used))
+	} else {
+		showInfoMessage(fmt.Sprintf("IPAM %s: %d used, %d free, %d total", result.IPAMType, result.Used, result.Free, result.Total))
+	}
+	if result.OutsideSubnet > 0 {
+		showWarningMessage(fmt.Sprintf("%d allocations are outside the node Pod CIDR and will not be reused", result.OutsideSubnet))
+	}
+}
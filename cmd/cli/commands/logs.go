@@ -1,23 +1,40 @@
 package commands
 
 import (
+	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"os"
 	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
+	"github.com/binrclab/headcni/pkg/cni"
+	"github.com/binrclab/headcni/pkg/constants"
 	"github.com/spf13/cobra"
 )
 
 type LogsOptions struct {
-	Namespace   string
-	ReleaseName string
-	Follow      bool
-	Tail        int
-	Since       string
-	Container   string
-	Previous    bool
-	Timestamps  bool
+	Namespace     string
+	ReleaseName   string
+	Follow        bool
+	Tail          int
+	Since         string
+	Container     string
+	Previous      bool
+	Timestamps    bool
+	ControlSocket string
+	LogDir        string
+	Components    []string
+	Pods          bool
+	NoColor       bool
 }
 
 func NewLogsCommand() *cobra.Command {
@@ -33,6 +50,15 @@ This command allows you to view logs from:
 - HeadCNI IPAM pods
 - Specific containers within pods
 
+Without a pod name on a node running HeadCNI, the daemon, tailscaled and
+CNI plugin logs under --log-dir are merged by timestamp and prefixed with
+their component. tailscaled logs are only available in daemon and router
+mode; in host mode they are in the host journal (journalctl -u tailscaled).
+Use --pods to view the daemon pod logs through kubectl instead.
+
+Otherwise, if the daemon's control socket is reachable, the node name is
+read from it and the logs of the daemon pod on this node are shown.
+
 Examples:
   # View logs from all HeadCNI pods
   headcni logs
@@ -47,7 +73,10 @@ Examples:
   headcni logs --container headcni-daemon
 
   # View logs since a specific time
-  headcni logs --since 1h`,
+  headcni logs --since 1h
+
+  # Follow the merged daemon and CNI plugin logs on this node
+  headcni logs --since 10m --follow --component daemon,cni`,
 		Args: cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return runLogs(opts, args)
@@ -62,11 +91,27 @@ Examples:
 	cmd.Flags().StringVar(&opts.Container, "container", "", "Container name within the pod")
 	cmd.Flags().BoolVar(&opts.Previous, "previous", false, "Show previous container logs")
 	cmd.Flags().BoolVar(&opts.Timestamps, "timestamps", false, "Include timestamps on each line")
+	cmd.Flags().StringVar(&opts.ControlSocket, "control-socket", constants.DefaultControlSocketPath, "Local HeadCNI daemon control socket path")
+	cmd.Flags().StringVar(&opts.LogDir, "log-dir", constants.DefaultLogDir, "Directory of the node-local HeadCNI log files")
+	cmd.Flags().StringSliceVar(&opts.Components, "component", nil, "Only merge logs of these components (daemon, tailscaled, cni)")
+	cmd.Flags().BoolVar(&opts.Pods, "pods", false, "View pod logs through kubectl even when node-local logs are available")
+	cmd.Flags().BoolVar(&opts.NoColor, "no-color", false, "Disable colored component prefixes in merged logs")
 
 	return cmd
 }
 
 func runLogs(opts *LogsOptions, args []string) error {
+	// 在节点上运行时合并本地日志文件，输出保持纯文本便于 grep
+	if len(args) == 0 && !opts.Pods {
+		sources, err := localLogSources(opts)
+		if err != nil {
+			return err
+		}
+		if len(sources) > 0 {
+			return viewMergedLogs(opts, sources)
+		}
+	}
+
 	// 显示 ASCII logo
 	showLogo()
 
@@ -95,6 +140,16 @@ func runLogs(opts *LogsOptions, args []string) error {
 		return nil
 	}
 
+	// 在 daemon 所在节点上运行时直接显示本节点 daemon pod 的日志
+	if daemon, err := queryLocalDaemon(opts.ControlSocket); err == nil && daemon != nil && daemon.NodeName != "" {
+		for _, pod := range pods {
+			if pod.Node == daemon.NodeName {
+				fmt.Printf("Showing logs of %s on local node %s\n\n", pod.Name, daemon.NodeName)
+				return viewPodLogs(opts, pod.Name)
+			}
+		}
+	}
+
 	fmt.Printf("Found %d HeadCNI pods:\n", len(pods))
 	for i, pod := range pods {
 		fmt.Printf("  %d. %s (%s)\n", i+1, pod.Name, pod.Status)
@@ -160,8 +215,8 @@ func viewPodLogs(opts *LogsOptions, podName string) error {
 	}
 
 	cmd := exec.Command("kubectl", args...)
-	cmd.Stdout = cmd.Stdout
-	cmd.Stderr = cmd.Stderr
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
 
 	fmt.Printf("📋 Viewing logs for pod: %s\n", podName)
 	fmt.Printf("Command: kubectl %s\n\n", strings.Join(args, " "))
@@ -239,6 +294,9 @@ func getHeadCNIPods(namespace, releaseName string) ([]PodStatus, error) {
 			Name:   metadata["name"].(string),
 			Status: status["phase"].(string),
 		}
+		if spec, ok := pod["spec"].(map[string]interface{}); ok {
+			podStatus.Node, _ = spec["nodeName"].(string)
+		}
 
 		pods = append(pods, podStatus)
 	}
@@ -255,3 +313,276 @@ func parsePodSelection(selection string, maxPods int) int {
 	}
 	return -1
 }
+
+// logSource 节点上的一个日志文件及其时间戳解析方式
+type logSource struct {
+	component string
+	path      string
+	color     string
+	parse     func(line string) (time.Time, string, bool)
+
+	// rotated 按大小轮转的旧文件（<path>.N），从最旧到最新，只在第一次读取时读取
+	rotated []string
+
+	// offset 已读取到的位置，follow 时从这里继续
+	offset int64
+	// last 上一行的时间，无法解析时间的续行（如堆栈）沿用它
+	last time.Time
+}
+
+// logLine 合并后的一行日志
+type logLine struct {
+	time   time.Time
+	source *logSource
+	text   string
+}
+
+// logComponents 可合并的日志组件及其文件名、颜色和解析函数
+var logComponents = []struct {
+	name  string
+	file  string
+	color string
+	parse func(line string) (time.Time, string, bool)
+}{
+	{"daemon", filepath.Base(constants.DefaultDaemonLogFile), "36", parseDaemonLogLine},
+	{"tailscaled", filepath.Base(constants.DefaultTailscaledLogFile), "35", parseTailscaledLogLine},
+	{"cni", filepath.Base(constants.DefaultCNIPluginLogFile), "33", parseCNIPluginLogLine},
+}
+
+// localLogSources 返回 --log-dir 下存在的日志文件，--component 指定时只返回对应组件
+func localLogSources(opts *LogsOptions) ([]*logSource, error) {
+	selected := make(map[string]bool)
+	for _, component := range opts.Components {
+		selected[strings.TrimSpace(component)] = true
+	}
+	for component := range selected {
+		known := false
+		for _, c := range logComponents {
+			known = known || c.name == component
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown log component %q (must be daemon, tailscaled or cni)", component)
+		}
+	}
+
+	var sources []*logSource
+	for _, c := range logComponents {
+		if len(selected) > 0 && !selected[c.name] {
+			continue
+		}
+		path := filepath.Join(opts.LogDir, c.file)
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		sources = append(sources, &logSource{component: c.name, path: path, color: c.color, parse: c.parse, rotated: rotatedLogFiles(path)})
+	}
+	return sources, nil
+}
+
+// rotatedLogFiles 返回 logging.RotatingFile 轮转出的 <path>.N，N 越大越旧
+func rotatedLogFiles(path string) []string {
+	matches, _ := filepath.Glob(path + ".*")
+	indexes := make(map[string]int)
+	var rotated []string
+	for _, match := range matches {
+		index, err := strconv.Atoi(strings.TrimPrefix(match, path+"."))
+		if err != nil || index <= 0 {
+			continue
+		}
+		indexes[match] = index
+		rotated = append(rotated, match)
+	}
+	sort.Slice(rotated, func(i, j int) bool {
+		return indexes[rotated[i]] > indexes[rotated[j]]
+	})
+	return rotated
+}
+
+// parseDaemonLogLine 解析 daemon 的 zap 控制台格式：时间\t级别\t调用位置\t消息
+func parseDaemonLogLine(line string) (time.Time, string, bool) {
+	timestamp, rest, ok := strings.Cut(line, "\t")
+	if !ok {
+		return time.Time{}, line, false
+	}
+	t, err := time.ParseInLocation("2006-01-02 15:04:05.000", timestamp, time.Local)
+	if err != nil {
+		return time.Time{}, line, false
+	}
+	return t, strings.ReplaceAll(rest, "\t", " "), true
+}
+
+// parseTailscaledLogLine 解析 tailscaled 标准库 log 格式：2006/01/02 15:04:05[.000000] 消息
+func parseTailscaledLogLine(line string) (time.Time, string, bool) {
+	fields := strings.SplitN(line, " ", 3)
+	if len(fields) < 3 {
+		return time.Time{}, line, false
+	}
+	for _, layout := range []string{"2006/01/02 15:04:05.000000", "2006/01/02 15:04:05"} {
+		if t, err := time.ParseInLocation(layout, fields[0]+" "+fields[1], time.Local); err == nil {
+			return t, fields[2], true
+		}
+	}
+	return time.Time{}, line, false
+}
+
+// parseCNIPluginLogLine 解析 CNI 插件写入的 JSON 行
+func parseCNIPluginLogLine(line string) (time.Time, string, bool) {
+	var entry cni.PluginLogEntry
+	if err := json.Unmarshal([]byte(line), &entry); err != nil || entry.Time.IsZero() {
+		return time.Time{}, line, false
+	}
+	text := entry.Level + " " + entry.Plugin
+	if entry.Command != "" {
+		text += " " + entry.Command
+	}
+	if entry.ContainerID != "" {
+		containerID := entry.ContainerID
+		if len(containerID) > 12 {
+			containerID = containerID[:12]
+		}
+		text += " " + containerID
+	}
+	return entry.Time, text + ": " + entry.Message, true
+}
+
+// readNewLines 先读取轮转的旧文件，再从上次读取的位置读取当前文件的完整新行
+// 文件变小（被截断或轮转）时从头读取
+func (s *logSource) readNewLines() ([]logLine, error) {
+	var lines []logLine
+	for _, path := range s.rotated {
+		if _, err := s.readFile(path, 0, &lines); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	s.rotated = nil
+
+	offset, err := s.readFile(s.path, s.offset, &lines)
+	if err != nil {
+		return nil, err
+	}
+	s.offset = offset
+	return lines, nil
+}
+
+// readFile 从 offset 开始读取 path 中的完整行追加到 lines，返回读取到的位置
+func (s *logSource) readFile(path string, offset int64, lines *[]logLine) (int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return offset, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return offset, err
+	}
+	if info.Size() < offset {
+		offset = 0
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return offset, err
+	}
+
+	reader := bufio.NewReaderSize(file, 64*1024)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			// 不完整的行留到下次读取
+			break
+		}
+		offset += int64(len(line))
+
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			continue
+		}
+		t, text, ok := s.parse(line)
+		if ok {
+			s.last = t
+		} else if s.last.IsZero() {
+			continue
+		}
+		*lines = append(*lines, logLine{time: s.last, source: s, text: text})
+	}
+	return offset, nil
+}
+
+// collectLogLines 读取所有来源的新行并按时间排序，同一时间保持各来源内的顺序
+func collectLogLines(sources []*logSource, since time.Time) []logLine {
+	var lines []logLine
+	for _, source := range sources {
+		sourceLines, err := source.readNewLines()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to read %s logs: %v\n", source.component, err)
+			continue
+		}
+		for _, line := range sourceLines {
+			if !since.IsZero() && line.time.Before(since) {
+				continue
+			}
+			lines = append(lines, line)
+		}
+	}
+	sort.SliceStable(lines, func(i, j int) bool {
+		return lines[i].time.Before(lines[j].time)
+	})
+	return lines
+}
+
+// viewMergedLogs 合并显示本节点的 daemon、tailscaled 和 CNI 插件日志
+func viewMergedLogs(opts *LogsOptions, sources []*logSource) error {
+	var since time.Time
+	if opts.Since != "" {
+		duration, err := time.ParseDuration(opts.Since)
+		if err != nil {
+			return fmt.Errorf("invalid --since %q: %v", opts.Since, err)
+		}
+		since = time.Now().Add(-duration)
+	}
+
+	color := !opts.NoColor && isTerminal(os.Stdout)
+	width := 0
+	for _, source := range sources {
+		if len(source.component) > width {
+			width = len(source.component)
+		}
+	}
+	printLines := func(lines []logLine) {
+		for _, line := range lines {
+			prefix := fmt.Sprintf("%-*s", width, line.source.component)
+			if color {
+				prefix = "\033[" + line.source.color + "m" + prefix + "\033[0m"
+			}
+			fmt.Printf("%s %s | %s\n", line.time.Local().Format("2006-01-02 15:04:05.000"), prefix, line.text)
+		}
+	}
+
+	lines := collectLogLines(sources, since)
+	if opts.Tail > 0 && len(lines) > opts.Tail {
+		lines = lines[len(lines)-opts.Tail:]
+	}
+	printLines(lines)
+	if !opts.Follow {
+		return nil
+	}
+
+	// 轮询文件追加的内容，每轮内按时间排序后输出
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			printLines(collectLogLines(sources, time.Time{}))
+		}
+	}
+}
+
+// isTerminal 判断输出是否为终端，重定向到文件或管道时不输出颜色
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
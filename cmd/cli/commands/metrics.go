@@ -2,27 +2,48 @@ package commands
 
 import (
 	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
-	"os/exec"
+	"os"
+	"os/signal"
+	"sort"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
+	"github.com/binrclab/headcni/cmd/daemon/config"
 	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
 )
 
 type MetricsOptions struct {
-	Namespace   string
-	ReleaseName string
-	Port        int
-	Host        string
-	Timeout     int
-	Output      string
-	Filter      string
+	ConfigPath string
+	Port       int
+	Path       string
+	Host       string
+	Timeout    int
+	Output     string
+	Filter     string
+	All        bool
+	Raw        bool
+	Watch      bool
+	Interval   time.Duration
+	// CAFile 校验 HTTPS 指标端点证书的 CA 文件
+	CAFile string
+	// InsecureSkipVerify 不校验 HTTPS 指标端点的证书
+	InsecureSkipVerify bool
+	// Token 访问指标端点的 bearer token，未指定时读取 monitoring.auth.bearerTokenFile
+	Token string
+
+	// useTLS daemon 配置了 monitoring.tls.certFile 或指定了 TLS 参数时以 HTTPS 访问
+	useTLS bool
 }
 
 type MetricData struct {
@@ -33,106 +54,208 @@ type MetricData struct {
 	Help   string            `json:"help"`
 }
 
+// headcniMetricPrefixes HeadCNI 自身导出的指标前缀，默认只展示这些指标
+var headcniMetricPrefixes = []string{"headcni_", "tailscale_cni_"}
+
 func NewMetricsCommand() *cobra.Command {
 	opts := &MetricsOptions{}
 
 	cmd := &cobra.Command{
 		Use:   "metrics",
-		Short: "View Prometheus metrics from HeadCNI",
-		Long: `View Prometheus metrics from HeadCNI components.
+		Short: "View Prometheus metrics from the local HeadCNI daemon",
+		Long: `View Prometheus metrics exposed by the HeadCNI daemon running on this node.
+
+The command scrapes http://127.0.0.1:<metricsPort><metricsPath> directly, so no
+kubectl port-forward or external scraper is required. Port and path are taken
+from the daemon configuration file when --config is given, otherwise the
+built-in defaults are used.
+
+When the daemon serves metrics over TLS (monitoring.tls.certFile) the command
+uses HTTPS; --ca-file or --insecure-skip-verify also switch to HTTPS. The
+bearer token is read from monitoring.auth.bearerTokenFile unless --token is
+given.
 
-This command allows you to:
-- View real-time metrics from HeadCNI daemon
-- Filter metrics by name or labels
-- Export metrics in different formats
-- Monitor HeadCNI performance and health
+By default only HeadCNI metrics (connection state, routes, IPAM pool usage,
+auth key expiry, ...) are shown; use --all to include Go runtime and process
+metrics as well.
 
 Examples:
-  # View all metrics
+  # View HeadCNI metrics
   headcni metrics
 
+  # Read port and path from the daemon config
+  headcni metrics --config /opt/headcni/config/daemon.yaml
+
   # View specific metrics
-  headcni metrics --filter "headcni_ip"
+  headcni metrics --filter "ipam"
+
+  # Dump the raw exposition output
+  headcni metrics --raw
+
+  # Refresh every 5 seconds
+  headcni metrics --watch --interval 5s
 
   # Export metrics to JSON
   headcni metrics --output json
 
-  # Use custom port
-  headcni metrics --port 9090`,
+  # Scrape a TLS endpoint protected by a bearer token
+  headcni metrics --ca-file /etc/headcni/tls/ca.crt --token "$(cat /etc/headcni/metrics-token)"`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return runMetrics(opts)
 		},
 	}
 
-	cmd.Flags().StringVar(&opts.Namespace, "namespace", "kube-system", "Kubernetes namespace")
-	cmd.Flags().StringVar(&opts.ReleaseName, "release-name", "headcni", "Helm release name")
-	cmd.Flags().IntVar(&opts.Port, "port", 9090, "Metrics port")
-	cmd.Flags().StringVar(&opts.Host, "host", "localhost", "Metrics host")
+	cmd.Flags().StringVar(&opts.ConfigPath, "config", "", "Path to daemon configuration file (used to resolve metrics port and path)")
+	cmd.Flags().IntVar(&opts.Port, "port", 0, "Metrics port (overrides daemon config)")
+	cmd.Flags().StringVar(&opts.Path, "path", "", "Metrics path (overrides daemon config)")
+	cmd.Flags().StringVar(&opts.Host, "host", "127.0.0.1", "Metrics host")
 	cmd.Flags().IntVar(&opts.Timeout, "timeout", 10, "Request timeout in seconds")
 	cmd.Flags().StringVar(&opts.Output, "output", "table", "Output format (table, json, prometheus)")
 	cmd.Flags().StringVar(&opts.Filter, "filter", "", "Filter metrics by name or labels")
+	cmd.Flags().BoolVar(&opts.All, "all", false, "Show all metrics, not only HeadCNI ones")
+	cmd.Flags().BoolVar(&opts.Raw, "raw", false, "Print the unparsed metrics output")
+	cmd.Flags().BoolVar(&opts.Watch, "watch", false, "Refresh metrics periodically")
+	cmd.Flags().DurationVar(&opts.Interval, "interval", 2*time.Second, "Refresh interval for --watch")
+	cmd.Flags().StringVar(&opts.CAFile, "ca-file", "", "CA file used to verify the metrics endpoint certificate (implies HTTPS)")
+	cmd.Flags().BoolVar(&opts.InsecureSkipVerify, "insecure-skip-verify", false, "Skip verification of the metrics endpoint certificate (implies HTTPS)")
+	cmd.Flags().StringVar(&opts.Token, "token", "", "Bearer token for the metrics endpoint (overrides monitoring.auth.bearerTokenFile)")
 
 	return cmd
 }
 
 func runMetrics(opts *MetricsOptions) error {
-	// 显示 ASCII logo
-	showLogo()
+	if err := resolveMetricsEndpoint(opts); err != nil {
+		return err
+	}
 
-	fmt.Printf("📊 Viewing HeadCNI metrics...\n")
-	fmt.Printf("Namespace: %s\n", opts.Namespace)
-	fmt.Printf("Release Name: %s\n", opts.ReleaseName)
-	fmt.Printf("Metrics Endpoint: %s:%d\n\n", opts.Host, opts.Port)
+	if !opts.Watch {
+		return showMetricsOnce(opts)
+	}
 
-	// 检查集群连接
-	if err := checkClusterConnection(); err != nil {
-		return fmt.Errorf("cluster connection failed: %v", err)
+	if opts.Interval <= 0 {
+		return fmt.Errorf("invalid --interval %s: must be positive", opts.Interval)
 	}
 
-	// 获取HeadCNI pods
-	pods, err := getHeadCNIPods(opts.Namespace, opts.ReleaseName)
-	if err != nil {
-		return fmt.Errorf("failed to get HeadCNI pods: %v", err)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		// 清屏后重新渲染
+		fmt.Print("\033[H\033[2J")
+		fmt.Printf("Every %s: %s    %s\n\n", opts.Interval, metricsURL(opts), time.Now().Format(time.RFC3339))
+		if err := showMetricsOnce(opts); err != nil {
+			// watch 模式下不退出，等待 daemon 恢复
+			pterm.Error.Println(err.Error())
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
 	}
+}
 
-	if len(pods) == 0 {
-		fmt.Println("❌ No HeadCNI pods found in the cluster")
+// resolveMetricsEndpoint 根据 daemon 配置和命令行参数确定指标端口、路径、是否使用 HTTPS 和 bearer token
+func resolveMetricsEndpoint(opts *MetricsOptions) error {
+	if opts.CAFile != "" || opts.InsecureSkipVerify {
+		opts.useTLS = true
+	}
+	if opts.Port != 0 && opts.Path != "" && opts.ConfigPath == "" {
 		return nil
 	}
 
-	// 尝试端口转发
-	fmt.Printf("🔗 Setting up port forward to access metrics...\n")
+	cfg, err := config.LoadConfig(opts.ConfigPath)
+	if err != nil {
+		return fmt.Errorf("failed to load daemon config: %v", err)
+	}
+
+	if cfg.Monitoring.TLS.CertFile != "" {
+		opts.useTLS = true
+	}
+	if opts.Token == "" && cfg.Monitoring.Auth.BearerTokenFile != "" {
+		token, err := os.ReadFile(cfg.Monitoring.Auth.BearerTokenFile)
+		if err != nil {
+			return fmt.Errorf("failed to read metrics bearer token: %v", err)
+		}
+		opts.Token = strings.TrimSpace(string(token))
+	}
 
-	// 选择第一个可用的pod
-	targetPod := pods[0].Name
+	if opts.Port == 0 {
+		opts.Port = cfg.Monitoring.Port
+	}
+	if opts.Path == "" {
+		opts.Path = cfg.Monitoring.Path
+	}
+	if opts.Port == 0 {
+		return fmt.Errorf("metrics port is not configured, use --port")
+	}
+	if opts.Path == "" {
+		opts.Path = "/metrics"
+	}
+	if !strings.HasPrefix(opts.Path, "/") {
+		opts.Path = "/" + opts.Path
+	}
 
-	// 启动端口转发
-	portForwardCmd := exec.Command("kubectl", "port-forward",
-		fmt.Sprintf("pod/%s", targetPod),
-		fmt.Sprintf("%d:%d", opts.Port, opts.Port),
-		"-n", opts.Namespace)
+	return nil
+}
 
-	// 在后台启动端口转发
-	if err := portForwardCmd.Start(); err != nil {
-		return fmt.Errorf("failed to start port forward: %v", err)
+func metricsURL(opts *MetricsOptions) string {
+	scheme := "http"
+	if opts.useTLS {
+		scheme = "https"
 	}
+	return fmt.Sprintf("%s://%s:%d%s", scheme, opts.Host, opts.Port, opts.Path)
+}
 
-	// 等待端口转发建立
-	time.Sleep(2 * time.Second)
+// metricsHTTPClient 按 --ca-file 和 --insecure-skip-verify 创建访问指标端点的 HTTP 客户端
+func metricsHTTPClient(opts *MetricsOptions) (*http.Client, error) {
+	client := &http.Client{
+		Timeout: time.Duration(opts.Timeout) * time.Second,
+	}
+	if !opts.useTLS {
+		return client, nil
+	}
 
-	// 确保在函数结束时停止端口转发
-	defer func() {
-		if portForwardCmd.Process != nil {
-			portForwardCmd.Process.Kill()
+	tlsConfig := &tls.Config{InsecureSkipVerify: opts.InsecureSkipVerify}
+	if opts.CAFile != "" {
+		caPEM, err := os.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %v", err)
 		}
-	}()
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", opts.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	client.Transport = &http.Transport{TLSClientConfig: tlsConfig}
+	return client, nil
+}
 
-	// 获取指标
-	metrics, err := fetchMetrics(opts)
+func showMetricsOnce(opts *MetricsOptions) error {
+	body, err := scrapeMetrics(opts)
 	if err != nil {
 		return fmt.Errorf("failed to fetch metrics: %v", err)
 	}
 
+	if opts.Raw {
+		fmt.Print(string(body))
+		return nil
+	}
+
+	metrics, err := parsePrometheusMetrics(strings.NewReader(string(body)))
+	if err != nil {
+		return fmt.Errorf("failed to parse metrics: %v", err)
+	}
+
+	if !opts.All {
+		metrics = filterHeadCNIMetrics(metrics)
+	}
+
 	// 过滤指标
 	if opts.Filter != "" {
 		metrics = filterMetrics(metrics, opts.Filter)
@@ -142,41 +265,69 @@ func runMetrics(opts *MetricsOptions) error {
 	return displayMetrics(metrics, opts.Output)
 }
 
-func fetchMetrics(opts *MetricsOptions) ([]MetricData, error) {
-	client := &http.Client{
-		Timeout: time.Duration(opts.Timeout) * time.Second,
+func scrapeMetrics(opts *MetricsOptions) ([]byte, error) {
+	client, err := metricsHTTPClient(opts)
+	if err != nil {
+		return nil, err
 	}
 
-	url := fmt.Sprintf("http://%s:%d/metrics", opts.Host, opts.Port)
+	url := metricsURL(opts)
 
-	resp, err := client.Get(url)
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request for %s: %v", url, err)
+	}
+	if opts.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+opts.Token)
+	}
+
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch metrics from %s: %v", url, err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("metrics endpoint returned status %d", resp.StatusCode)
+		return nil, fmt.Errorf("metrics endpoint %s returned status %d", url, resp.StatusCode)
 	}
 
-	// 解析Prometheus格式的指标
-	metrics, err := parsePrometheusMetrics(resp.Body)
+	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse metrics: %v", err)
+		return nil, fmt.Errorf("failed to read metrics from %s: %v", url, err)
 	}
 
-	return metrics, nil
+	return body, nil
 }
 
 func parsePrometheusMetrics(body io.Reader) ([]MetricData, error) {
 	scanner := bufio.NewScanner(body)
 	var metrics []MetricData
 
+	helps := make(map[string]string)
+	types := make(map[string]string)
+
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
 
-		// 跳过注释和空行
-		if line == "" || strings.HasPrefix(line, "#") {
+		if line == "" {
+			continue
+		}
+
+		// 记录 HELP / TYPE 注释，其余注释跳过
+		if strings.HasPrefix(line, "#") {
+			fields := strings.SplitN(line, " ", 4)
+			if len(fields) >= 3 {
+				switch fields[1] {
+				case "HELP":
+					if len(fields) == 4 {
+						helps[fields[2]] = fields[3]
+					}
+				case "TYPE":
+					if len(fields) == 4 {
+						types[fields[2]] = fields[3]
+					}
+				}
+			}
 			continue
 		}
 
@@ -186,23 +337,55 @@ func parsePrometheusMetrics(body io.Reader) ([]MetricData, error) {
 			continue // 跳过无法解析的行
 		}
 
+		family := metricFamilyName(metric.Name, types)
+		if t, ok := types[family]; ok {
+			metric.Type = t
+		}
+		metric.Help = helps[family]
+
 		metrics = append(metrics, metric)
 	}
 
 	return metrics, scanner.Err()
 }
 
+// metricFamilyName 返回样本所属的指标族名称（histogram/summary 的 _bucket/_sum/_count 样本归入同一族）
+func metricFamilyName(name string, types map[string]string) string {
+	if _, ok := types[name]; ok {
+		return name
+	}
+	for _, suffix := range []string{"_bucket", "_sum", "_count"} {
+		if base := strings.TrimSuffix(name, suffix); base != name {
+			if _, ok := types[base]; ok {
+				return base
+			}
+		}
+	}
+	return name
+}
+
 func parseMetricLine(line string) (MetricData, error) {
 	// 简单的Prometheus指标解析
-	// 格式: metric_name{label="value"} value
+	// 格式: metric_name{label="value"} value [timestamp]
 
-	parts := strings.Split(line, " ")
-	if len(parts) != 2 {
-		return MetricData{}, fmt.Errorf("invalid metric format")
+	var metricPart, rest string
+	if labelEnd := strings.LastIndex(line, "}"); labelEnd != -1 {
+		metricPart = line[:labelEnd+1]
+		rest = line[labelEnd+1:]
+	} else {
+		idx := strings.IndexAny(line, " \t")
+		if idx == -1 {
+			return MetricData{}, fmt.Errorf("invalid metric format")
+		}
+		metricPart = line[:idx]
+		rest = line[idx:]
 	}
 
-	metricPart := parts[0]
-	valuePart := parts[1]
+	valueFields := strings.Fields(rest)
+	if len(valueFields) < 1 || len(valueFields) > 2 {
+		return MetricData{}, fmt.Errorf("invalid metric format")
+	}
+	valuePart := valueFields[0]
 
 	// 解析指标名称和标签
 	var name string
@@ -250,10 +433,26 @@ func parseMetricLine(line string) (MetricData, error) {
 		Name:   name,
 		Value:  value,
 		Labels: labels,
-		Type:   "gauge", // 默认类型
+		Type:   "untyped", // 默认类型，由 TYPE 注释覆盖
 	}, nil
 }
 
+// filterHeadCNIMetrics 只保留 HeadCNI 自身的指标，去掉 Go runtime / process 等通用指标
+func filterHeadCNIMetrics(metrics []MetricData) []MetricData {
+	var filtered []MetricData
+
+	for _, metric := range metrics {
+		for _, prefix := range headcniMetricPrefixes {
+			if strings.HasPrefix(metric.Name, prefix) {
+				filtered = append(filtered, metric)
+				break
+			}
+		}
+	}
+
+	return filtered
+}
+
 func filterMetrics(metrics []MetricData, filter string) []MetricData {
 	var filtered []MetricData
 
@@ -297,7 +496,7 @@ func displayMetricsTable(metrics []MetricData) error {
 
 	// 创建表格数据
 	tableData := [][]string{
-		{"Metric Name", "Value", "Labels"},
+		{"Metric Name", "Type", "Value", "Labels"},
 	}
 
 	for _, metric := range metrics {
@@ -307,12 +506,14 @@ func displayMetricsTable(metrics []MetricData) error {
 			for k, v := range metric.Labels {
 				labelPairs = append(labelPairs, fmt.Sprintf("%s=%s", k, v))
 			}
+			sort.Strings(labelPairs)
 			labelsStr = strings.Join(labelPairs, ", ")
 		}
 
 		tableData = append(tableData, []string{
 			metric.Name,
-			fmt.Sprintf("%.2f", metric.Value),
+			metric.Type,
+			formatMetricValue(metric.Value),
 			labelsStr,
 		})
 	}
@@ -323,6 +524,17 @@ func displayMetricsTable(metrics []MetricData) error {
 	return nil
 }
 
+// formatMetricValue 格式化指标值，整数值不显示小数部分
+func formatMetricValue(value float64) string {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	}
+	if math.Abs(value) < 1e15 && value == math.Trunc(value) {
+		return strconv.FormatInt(int64(value), 10)
+	}
+	return strconv.FormatFloat(value, 'f', 2, 64)
+}
+
 func displayMetricsJSON(metrics []MetricData) error {
 	jsonData, err := json.MarshalIndent(metrics, "", "  ")
 	if err != nil {
@@ -340,6 +552,7 @@ func displayMetricsPrometheus(metrics []MetricData) error {
 			for k, v := range metric.Labels {
 				labelPairs = append(labelPairs, fmt.Sprintf(`%s="%s"`, k, v))
 			}
+			sort.Strings(labelPairs)
 			fmt.Printf("%s{%s} %f\n", metric.Name, strings.Join(labelPairs, ","), metric.Value)
 		} else {
 			fmt.Printf("%s %f\n", metric.Name, metric.Value)
@@ -0,0 +1,111 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/binrclab/headcni/pkg/constants"
+	"github.com/spf13/cobra"
+)
+
+type RotateIdentityOptions struct {
+	SocketPath string
+	Timeout    time.Duration
+}
+
+// identityRotationResult 与 daemon 返回的身份轮换结果对应
+type identityRotationResult struct {
+	OldNodeID   string `json:"old_node_id"`
+	NewNodeID   string `json:"new_node_id"`
+	OldNodeKey  string `json:"old_node_key"`
+	NewNodeKey  string `json:"new_node_key"`
+	TailscaleIP string `json:"tailscale_ip"`
+	OldExpired  bool   `json:"old_expired"`
+	Error       string `json:"error"`
+}
+
+func NewRotateIdentityCommand() *cobra.Command {
+	opts := &RotateIdentityOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "rotate-identity",
+		Short: "Rotate this node's Tailscale identity",
+		Long: `Ask the HeadCNI daemon on this node to rotate its Tailscale identity.
+
+The daemon will:
+1. Create a fresh pre-auth key in Headscale
+2. Log out and discard the local node key (interface and config are kept)
+3. Re-authenticate with the new key and wait for Running
+4. Re-advertise and re-approve the node's routes
+5. Expire the old Headscale node
+
+If the rotation is interrupted after the old key was discarded, the daemon
+finishes it after it logs in again, so the node ends up either fully on the
+old identity or fully on the new one.
+
+The command talks to the daemon through its local control socket and must
+run as root on the node.
+
+Examples:
+  # Rotate the identity of this node
+  headcni rotate-identity`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runRotateIdentity(opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.SocketPath, "socket", constants.DefaultControlSocketPath, "HeadCNI daemon control socket path")
+	cmd.Flags().DurationVar(&opts.Timeout, "timeout", 6*time.Minute, "Timeout for the whole rotation")
+
+	return cmd
+}
+
+func runRotateIdentity(opts *RotateIdentityOptions) error {
+	client := &http.Client{
+		Timeout: opts.Timeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", opts.SocketPath)
+			},
+		},
+	}
+
+	fmt.Printf("🔑 Rotating Tailscale identity via %s...\n", opts.SocketPath)
+
+	resp, err := client.Post("http://unix/identity/rotate", "application/json", nil)
+	if err != nil {
+		return fmt.Errorf("failed to contact daemon: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read daemon response: %v", err)
+	}
+
+	var result identityRotationResult
+	if err := json.Unmarshal(body, &result); err != nil {
+		return fmt.Errorf("unexpected daemon response (%s): %s", resp.Status, string(body))
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("identity rotation failed: %s", result.Error)
+	}
+
+	fmt.Printf("Old node: %s (%s)\n", result.OldNodeID, result.OldNodeKey)
+	fmt.Printf("New node: %s (%s)\n", result.NewNodeID, result.NewNodeKey)
+	fmt.Printf("Tailscale IP: %s\n", result.TailscaleIP)
+	if result.OldExpired {
+		fmt.Printf("✅ Expired old Headscale node %s\n", result.OldNodeID)
+	} else {
+		showInfoMessage("Headscale kept the same node record for the new key, nothing to expire")
+	}
+
+	showSuccessMessage("Identity rotated")
+	return nil
+}
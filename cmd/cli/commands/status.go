@@ -6,30 +6,47 @@ import (
 	"os"
 	"os/exec"
 	"strings"
+	"time"
 
+	"github.com/binrclab/headcni/pkg/backend/tailscale"
+	"github.com/binrclab/headcni/pkg/constants"
 	"github.com/spf13/cobra"
 )
 
 type StatusOptions struct {
-	Namespace   string
-	ReleaseName string
-	Output      string
-	ShowLogs    bool
+	Namespace     string
+	ReleaseName   string
+	Output        string
+	ShowLogs      bool
+	ControlSocket string
+	// HandshakeThreshold 该时间内向在线节点发送过流量但没有握手时标记为可能不可达
+	HandshakeThreshold time.Duration
 }
 
 type ClusterStatus struct {
-	Nodes     []NodeStatus    `json:"nodes"`
-	DaemonSet DaemonSetStatus `json:"daemonset"`
-	Pods      []PodStatus     `json:"pods"`
-	CNI       CNIStatus       `json:"cni"`
-	Tailscale TailscaleStatus `json:"tailscale"`
+	Nodes       []NodeStatus       `json:"nodes"`
+	DaemonSet   DaemonSetStatus    `json:"daemonset"`
+	Pods        []PodStatus        `json:"pods"`
+	CNI         CNIStatus          `json:"cni"`
+	Tailscale   TailscaleStatus    `json:"tailscale"`
+	LocalDaemon *LocalDaemonStatus `json:"local_daemon,omitempty"`
+	Peers       []PeerHandshake    `json:"peers,omitempty"`
+}
+
+// PeerHandshake 本节点到某个节点的路径和最近一次握手
+type PeerHandshake struct {
+	tailscale.PeerPath
+	Stale bool `json:"stale"`
 }
 
 type NodeStatus struct {
-	Name     string `json:"name"`
-	Ready    bool   `json:"ready"`
-	CNIReady bool   `json:"cni_ready"`
-	IP       string `json:"ip"`
+	Name       string `json:"name"`
+	Ready      bool   `json:"ready"`
+	CNIReady   bool   `json:"cni_ready"`
+	IP         string `json:"ip"`
+	Version    string `json:"version,omitempty"`
+	ConfigHash string `json:"config_hash,omitempty"`
+	Mode       string `json:"mode,omitempty"`
 }
 
 type DaemonSetStatus struct {
@@ -77,6 +94,10 @@ This command will show:
 - CNI plugin status
 - Tailscale connectivity status
 
+When run on a node with a HeadCNI daemon, the local daemon's state, health
+and Tailscale address are read from its control socket instead of the
+tailscale CLI.
+
 Examples:
   # Basic status check
   headcni status
@@ -98,6 +119,8 @@ Examples:
 	cmd.Flags().StringVar(&opts.ReleaseName, "release-name", "headcni", "Helm release name")
 	cmd.Flags().StringVar(&opts.Output, "output", "table", "Output format (table, json, yaml)")
 	cmd.Flags().BoolVar(&opts.ShowLogs, "show-logs", false, "Show recent logs from pods")
+	cmd.Flags().StringVar(&opts.ControlSocket, "control-socket", constants.DefaultControlSocketPath, "Local HeadCNI daemon control socket path")
+	cmd.Flags().DurationVar(&opts.HandshakeThreshold, "handshake-threshold", 5*time.Minute, "Flag online peers that were sent traffic within this time but have not handshaked in it")
 
 	return cmd
 }
@@ -135,11 +158,19 @@ func runStatus(opts *StatusOptions) error {
 		return fmt.Errorf("failed to get CNI status: %v", err)
 	}
 
+	// 本节点运行 daemon 时通过控制 socket 获取状态
+	if err := getLocalDaemonStatus(opts, status); err != nil {
+		fmt.Printf("⚠️  Warning: Failed to query local daemon: %v\n", err)
+	}
+
 	// 检查 Tailscale 状态
 	if err := getTailscaleStatus(status); err != nil {
 		return fmt.Errorf("failed to get Tailscale status: %v", err)
 	}
 
+	// 本节点上有 tailscaled 时显示到各节点的路径和握手时间
+	getPeerHandshakes(opts, status)
+
 	// 输出结果
 	if err := outputStatus(status, opts); err != nil {
 		return fmt.Errorf("failed to output status: %v", err)
@@ -301,8 +332,10 @@ func getNodeStatus(status *ClusterStatus) error {
 	nodes := nodeList["items"].([]interface{})
 
 	// 准备表格数据
-	headers := []string{"Name", "IP", "Ready", "CNI Ready"}
+	headers := []string{"Name", "IP", "Ready", "CNI Ready", "Version", "Config Hash", "Mode"}
 	var rows [][]string
+	versions := make(map[string]bool)
+	configHashes := make(map[string]bool)
 
 	for _, node := range nodes {
 		nodeObj := node.(map[string]interface{})
@@ -313,6 +346,19 @@ func getNodeStatus(status *ClusterStatus) error {
 			Name: metadata["name"].(string),
 		}
 
+		// 守护进程上报的版本、配置哈希和模式
+		if annotations, ok := metadata["annotations"].(map[string]interface{}); ok {
+			nodeInfo.Version, _ = annotations[constants.HeadcniVersionAnnotationKey].(string)
+			nodeInfo.ConfigHash, _ = annotations[constants.HeadcniConfigHashAnnotationKey].(string)
+			nodeInfo.Mode, _ = annotations[constants.HeadcniModeAnnotationKey].(string)
+		}
+		if nodeInfo.Version != "" {
+			versions[nodeInfo.Version] = true
+		}
+		if nodeInfo.ConfigHash != "" {
+			configHashes[nodeInfo.ConfigHash] = true
+		}
+
 		// 检查节点就绪状态
 		conditions := nodeStatus["conditions"].([]interface{})
 		for _, condition := range conditions {
@@ -350,6 +396,9 @@ func getNodeStatus(status *ClusterStatus) error {
 			nodeInfo.IP,
 			readyStatus,
 			cniReadyStatus,
+			valueOrUnknown(nodeInfo.Version),
+			valueOrUnknown(nodeInfo.ConfigHash),
+			valueOrUnknown(nodeInfo.Mode),
 		})
 	}
 
@@ -360,6 +409,14 @@ func getNodeStatus(status *ClusterStatus) error {
 		showWarningMessage("No nodes found")
 	}
 
+	// 升级过程中节点版本或配置不一致
+	if len(versions) > 1 {
+		showWarningMessage(fmt.Sprintf("Version skew detected: %d different HeadCNI versions across nodes", len(versions)))
+	}
+	if len(configHashes) > 1 {
+		showWarningMessage(fmt.Sprintf("Config drift detected: %d different config hashes across nodes", len(configHashes)))
+	}
+
 	return nil
 }
 
@@ -397,9 +454,70 @@ func getCNIStatus(status *ClusterStatus) error {
 	return nil
 }
 
+// getLocalDaemonStatus 通过控制 socket 读取本节点 daemon 的状态，socket 不存在时跳过
+func getLocalDaemonStatus(opts *StatusOptions, status *ClusterStatus) error {
+	daemon, err := queryLocalDaemon(opts.ControlSocket)
+	if err != nil || daemon == nil {
+		return err
+	}
+	status.LocalDaemon = daemon
+
+	showSubSectionHeader("Local Daemon Status")
+	statusItems := map[string]string{
+		"Node":    valueOrUnknown(daemon.NodeName),
+		"Version": valueOrUnknown(daemon.Version),
+		"Mode":    valueOrUnknown(daemon.Mode),
+		"Health":  valueOrUnknown(daemon.Health),
+		"DryRun":  fmt.Sprintf("%v", daemon.DryRun),
+	}
+	for name, serviceErr := range daemon.ServiceErrors {
+		statusItems[name] = serviceErr
+	}
+	showStatusCard("Daemon", statusItems)
+	showTailscaleServices(daemon.Services)
+	return nil
+}
+
+// showTailscaleServices 以表格展示 daemon 管理的 tailscaled 服务
+func showTailscaleServices(services []*tailscale.ServiceDetails) {
+	rows := make([][]string, 0, len(services))
+	for _, svc := range services {
+		pid := "-"
+		if svc.PID > 0 {
+			pid = fmt.Sprintf("%d", svc.PID)
+		}
+		rows = append(rows, []string{
+			svc.Name,
+			svc.Mode,
+			svc.Status,
+			valueOrUnknown(svc.BackendState),
+			pid,
+			svc.SocketPath,
+			svc.Uptime.String(),
+			svc.LastError,
+		})
+	}
+	showTable([]string{"SERVICE", "MODE", "STATUS", "BACKEND", "PID", "SOCKET", "UPTIME", "LAST ERROR"}, rows)
+}
+
 func getTailscaleStatus(status *ClusterStatus) error {
 	showSubSectionHeader("Tailscale Status")
 
+	// daemon 管理的 tailscaled 使用独立的 socket，tailscale CLI 默认连接不到，优先使用控制接口的结果
+	if daemon := status.LocalDaemon; daemon != nil {
+		status.Tailscale.Connected = daemon.TailscaleIP != ""
+		status.Tailscale.IP = daemon.TailscaleIP
+		status.Tailscale.Status = valueOrUnknown(daemon.TailscaleState)
+		if status.Tailscale.IP == "" {
+			status.Tailscale.IP = "N/A"
+		}
+		showStatusCard("Tailscale", map[string]string{
+			"Connected": status.Tailscale.Status,
+			"IP":        status.Tailscale.IP,
+		})
+		return nil
+	}
+
 	// 尝试获取 Tailscale 状态
 	cmd := exec.Command("tailscale", "status", "--json")
 	output, err := cmd.Output()
@@ -442,6 +560,35 @@ func getTailscaleStatus(status *ClusterStatus) error {
 	return nil
 }
 
+// getPeerHandshakes 通过本机 tailscaled 读取各节点的路径和最近一次握手，不在节点上运行时跳过
+func getPeerHandshakes(opts *StatusOptions, status *ClusterStatus) {
+	info, err := collectDERPInfo(10 * time.Second)
+	if err != nil {
+		return
+	}
+
+	showSubSectionHeader("Peer Handshakes")
+	now := time.Now()
+	rows := make([][]string, 0, len(info.Peers))
+	var stale []string
+	for _, peer := range info.Peers {
+		entry := PeerHandshake{PeerPath: peer, Stale: peer.StaleHandshake(opts.HandshakeThreshold, now)}
+		status.Peers = append(status.Peers, entry)
+
+		mark := ""
+		if entry.Stale {
+			mark = "⚠️ stale"
+			stale = append(stale, peer.HostName)
+		}
+		rows = append(rows, []string{peer.HostName, peer.TailscaleIP, peerPathString(peer), handshakeAge(peer, now), mark})
+	}
+	showTable([]string{"PEER", "IP", "PATH", "LAST HANDSHAKE", ""}, rows)
+
+	for _, name := range stale {
+		fmt.Printf("⚠️  %s was sent traffic but has not handshaked in over %v, check its DERP region and NAT\n", name, opts.HandshakeThreshold)
+	}
+}
+
 func outputStatus(status *ClusterStatus, opts *StatusOptions) error {
 	switch opts.Output {
 	case "json":
@@ -485,3 +632,11 @@ func showLogs(opts *StatusOptions) error {
 
 	return nil
 }
+
+// valueOrUnknown 空值显示为 Unknown
+func valueOrUnknown(value string) string {
+	if value == "" {
+		return "Unknown"
+	}
+	return value
+}
@@ -1,11 +1,16 @@
 package commands
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
+	"time"
 
+	"github.com/binrclab/headcni/pkg/constants"
+	"github.com/binrclab/headcni/pkg/k8s"
 	"github.com/spf13/cobra"
+	coreV1 "k8s.io/api/core/v1"
 )
 
 type UninstallOptions struct {
@@ -13,6 +18,7 @@ type UninstallOptions struct {
 	ReleaseName string
 	Force       bool
 	DryRun      bool
+	Kubeconfig  string
 }
 
 func NewUninstallCommand() *cobra.Command {
@@ -27,6 +33,7 @@ This command will:
 1. Remove HeadCNI DaemonSet
 2. Clean up CNI configuration
 3. Remove related resources
+4. Remove the HeadCNI annotations the daemon wrote on the nodes
 
 Examples:
   # Basic uninstall
@@ -46,6 +53,7 @@ Examples:
 	cmd.Flags().StringVar(&opts.ReleaseName, "release-name", "headcni", "Helm release name")
 	cmd.Flags().BoolVar(&opts.Force, "force", false, "Force uninstall without confirmation")
 	cmd.Flags().BoolVar(&opts.DryRun, "dry-run", false, "Show what would be uninstalled without actually uninstalling")
+	cmd.Flags().StringVar(&opts.Kubeconfig, "kubeconfig", os.Getenv("KUBECONFIG"), "Path to kubeconfig used to remove node annotations, in-cluster config is used when empty")
 
 	return cmd
 }
@@ -98,6 +106,18 @@ func runUninstall(opts *UninstallOptions) error {
 		return fmt.Errorf("secret cleanup failed: %v", err)
 	}
 
+	// 清理节点注解，DaemonSet 已删除，失败不影响卸载结果
+	fmt.Printf("🏷️  Removing HeadCNI node annotations...\n")
+	nodes, err := removeNodeAnnotations(opts.Kubeconfig, "", opts.DryRun)
+	switch {
+	case err != nil:
+		showWarningMessage(fmt.Sprintf("Failed to remove node annotations: %v", err))
+	case opts.DryRun:
+		fmt.Printf("Would remove HeadCNI annotations from %d node(s): %v\n", len(nodes), nodes)
+	default:
+		fmt.Printf("✅ Removed HeadCNI annotations from %d node(s)\n", len(nodes))
+	}
+
 	fmt.Printf("\n✅ HeadCNI uninstalled successfully!\n")
 	fmt.Printf("\nNote: You may need to restart kubelet on your nodes to fully clean up CNI configuration.\n")
 
@@ -162,3 +182,51 @@ func cleanupSecret(opts *UninstallOptions) error {
 	fmt.Printf("✅ Secrets cleaned up\n")
 	return nil
 }
+
+// removeNodeAnnotations 删除节点上 daemon 写入的 HeadCNI 注解，返回有注解需要删除的节点
+// nodeName 为空时处理所有节点；dryRun 时只返回节点，不做修改
+func removeNodeAnnotations(kubeconfig, nodeName string, dryRun bool) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	k8sClient := k8s.NewClient(&k8s.ClientConfig{KubeconfigPath: kubeconfig, Timeout: 15 * time.Second})
+	if err := k8sClient.Connect(ctx); err != nil {
+		return nil, err
+	}
+	defer k8sClient.Disconnect()
+
+	var nodes []*coreV1.Node
+	if nodeName != "" {
+		node, err := k8sClient.Nodes().Get(ctx, nodeName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get node %s: %v", nodeName, err)
+		}
+		nodes = append(nodes, node)
+	} else {
+		list, err := k8sClient.Nodes().List(ctx, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list nodes: %v", err)
+		}
+		nodes = list
+	}
+
+	var cleaned []string
+	for _, node := range nodes {
+		var keys []string
+		for _, key := range constants.HeadcniManagedNodeAnnotationKeys {
+			if _, ok := node.Annotations[key]; ok {
+				keys = append(keys, key)
+			}
+		}
+		if len(keys) == 0 {
+			continue
+		}
+		if !dryRun {
+			if err := k8sClient.Nodes().RemoveAnnotations(node.Name, keys); err != nil {
+				return cleaned, fmt.Errorf("failed to remove annotations from node %s: %v", node.Name, err)
+			}
+		}
+		cleaned = append(cleaned, node.Name)
+	}
+	return cleaned, nil
+}
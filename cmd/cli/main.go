@@ -49,6 +49,10 @@ For more information, visit: https://github.com/binrclab/headcni`,
 	rootCmd.AddCommand(commands.NewMetricsCommand())
 	rootCmd.AddCommand(commands.NewUpgradeCommand())
 	rootCmd.AddCommand(commands.NewDiagnosticsCommand())
+	rootCmd.AddCommand(commands.NewDrainCommand())
+	rootCmd.AddCommand(commands.NewRotateIdentityCommand())
+	rootCmd.AddCommand(commands.NewDryRunCommand())
+	rootCmd.AddCommand(commands.NewIPAMCommand())
 	rootCmd.AddCommand(commands.NewBackupCommand())
 	rootCmd.AddCommand(commands.NewRestoreCommand())
 	rootCmd.AddCommand(commands.NewCompletionCommand())
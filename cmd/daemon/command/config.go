@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/binrclab/headcni/cmd/daemon/config"
 	"github.com/pkg/errors"
@@ -17,6 +18,10 @@ func init() {
 		configShowCmd,
 	)
 	rootCmd.AddCommand(configCmd)
+
+	addConfigFlags(configShowCmd)
+	configShowCmd.Flags().Bool("effective", false, "Print the fully-resolved configuration with the source of each value")
+	configShowCmd.Flags().String("output", "yaml", "Output format for --effective (yaml, json)")
 }
 
 var configCmd = &cobra.Command{
@@ -55,6 +60,11 @@ var configShowCmd = &cobra.Command{
 	Short: "Show current configuration",
 	Long:  "Display the current configuration with all overrides applied",
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if effective, _ := cmd.Flags().GetBool("effective"); effective {
+			output, _ := cmd.Flags().GetString("output")
+			return printEffectiveConfig(cmd, output)
+		}
+
 		// Load and display configuration
 		cfg, err := config.LoadConfigWithPriority(cmd)
 		if err != nil {
@@ -64,7 +74,7 @@ var configShowCmd = &cobra.Command{
 		cmd.Println("Current configuration:")
 		cmd.Printf("  - Tailscale URL: %s\n", cfg.Tailscale.URL)
 		cmd.Printf("  - Tailscale Socket: %s\n", cfg.Tailscale.Socket.Path)
-		cmd.Printf("  - Tailscale MTU: %d\n", cfg.Tailscale.MTU)
+		cmd.Printf("  - Tailscale MTU: %d\n", cfg.TailnetMTU())
 		cmd.Printf("  - Pod CIDR: %s\n", cfg.Network.PodCIDR.Base)
 		cmd.Printf("  - Service CIDR: %s\n", cfg.Network.ServiceCIDR)
 		cmd.Printf("  - Log Level: %s\n", cfg.Daemon.LogLevel)
@@ -81,6 +91,30 @@ var configShowCmd = &cobra.Command{
 	},
 }
 
+// printEffectiveConfig 输出实际生效的配置及每个配置项的来源，敏感值已隐藏
+func printEffectiveConfig(cmd *cobra.Command, output string) error {
+	effective, err := config.LoadEffectiveConfig(cmd)
+	if err != nil {
+		return errors.Wrap(err, "failed to load config")
+	}
+
+	var data []byte
+	switch output {
+	case "json":
+		data, err = effective.JSON()
+	case "yaml", "":
+		data, err = effective.YAML()
+	default:
+		return errors.Errorf("unsupported output format: %s (yaml, json)", output)
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to render effective config")
+	}
+
+	cmd.Println(strings.TrimRight(string(data), "\n"))
+	return nil
+}
+
 // validateConfigFile 验证配置文件
 func validateConfigFile(configFile string) error {
 	// 检查文件扩展名
@@ -120,8 +154,8 @@ func validateConfigFields(cfg *config.Config) error {
 		return fmt.Errorf("tailscale socket path is required")
 	}
 
-	if cfg.Tailscale.MTU <= 0 {
-		return fmt.Errorf("tailscale MTU must be greater than 0")
+	if cfg.Tailscale.MTU < 0 {
+		return fmt.Errorf("tailscale MTU must not be negative")
 	}
 
 	// 验证网络配置
@@ -142,8 +176,8 @@ func validateConfigFields(cfg *config.Config) error {
 		return fmt.Errorf("headscale URL is required")
 	}
 
-	if cfg.Headscale.AuthKey == "" {
-		return fmt.Errorf("headscale auth key is required")
+	if cfg.Headscale.AuthKey == "" && !cfg.Headscale.AuthKeySecretRef.IsSet() {
+		return fmt.Errorf("headscale auth key or auth key secret ref is required")
 	}
 
 	// 验证 IPAM 配置
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -12,8 +13,10 @@ import (
 	"k8s.io/klog/v2"
 
 	"github.com/binrclab/headcni/cmd/daemon/config"
+	"github.com/binrclab/headcni/pkg/constants"
 	"github.com/binrclab/headcni/pkg/daemon"
 	"github.com/binrclab/headcni/pkg/logging"
+	"github.com/binrclab/headcni/pkg/utils"
 )
 
 // CommandStats 命令执行统计
@@ -59,37 +62,64 @@ Configuration priority (highest to lowest):
 
 // init initializes the command structure
 func init() {
+	addConfigFlags(rootCmd)
+
+	// 添加超时参数
+	rootCmd.Flags().Duration("timeout", 0, "Command timeout (0 = no timeout)")
+}
+
+// addConfigFlags registers the configuration file and override flags read by LoadConfigWithPriority
+func addConfigFlags(cmd *cobra.Command) {
 	// Configuration file path
-	rootCmd.Flags().String("config", "", "Path to configuration file (YAML format)")
+	cmd.Flags().String("config", "", "Path to configuration file (YAML format)")
 
 	// Core configuration parameters
-	rootCmd.Flags().String("tailscale-url", "", "Tailscale server URL (debug override)")
-	rootCmd.Flags().String("tailscale-socket", "", "Tailscale socket path (debug override)")
-	rootCmd.Flags().Int("tailscale-mtu", 0, "Tailscale MTU (debug override)")
-	rootCmd.Flags().String("pod-cidr", "", "Pod CIDR (debug override)")
-	rootCmd.Flags().String("service-cidr", "", "Service CIDR (debug override)")
-	rootCmd.Flags().String("log-level", "", "Log level (debug override)")
+	cmd.Flags().String("tailscale-url", "", "Tailscale server URL (debug override)")
+	cmd.Flags().String("tailscale-socket", "", "Tailscale socket path (debug override)")
+	cmd.Flags().Int("tailscale-mtu", 0, "Tailscale MTU (debug override)")
+	cmd.Flags().String("pod-cidr", "", "Pod CIDR (debug override)")
+	cmd.Flags().String("service-cidr", "", "Service CIDR (debug override)")
+	cmd.Flags().String("log-level", "", "Log level (debug override)")
+	cmd.Flags().Bool("dry-run", false, "Only log planned route changes without applying them (debug override)")
 
 	// Monitoring parameters
-	rootCmd.Flags().Bool("monitoring-enabled", false, "Enable monitoring (debug override)")
-	rootCmd.Flags().Int("metrics-port", 0, "Metrics server port (debug override)")
-	rootCmd.Flags().String("metrics-path", "", "Metrics server path (debug override)")
+	cmd.Flags().Bool("monitoring-enabled", false, "Enable monitoring (debug override)")
+	cmd.Flags().Int("metrics-port", 0, "Metrics server port (debug override)")
+	cmd.Flags().String("metrics-path", "", "Metrics server path (debug override)")
 
 	// Advanced parameters
-	rootCmd.Flags().String("headscale-url", "", "Headscale server URL (advanced debug)")
-	rootCmd.Flags().String("headscale-auth-key", "", "Headscale API key (advanced debug)")
-	rootCmd.Flags().String("tailscale-mode", "", "Tailscale mode (advanced debug)")
-	rootCmd.Flags().String("tailscale-user", "", "Tailscale user (advanced debug)")
-	rootCmd.Flags().String("tailscale-tags", "", "Tailscale tags (advanced debug)")
-	rootCmd.Flags().Int("network-mtu", 0, "Network MTU (advanced debug)")
-	rootCmd.Flags().Bool("enable-ipv6", false, "Enable IPv6 (advanced debug)")
-	rootCmd.Flags().Bool("enable-network-policy", false, "Enable network policy (advanced debug)")
-	rootCmd.Flags().String("ipam-type", "", "IPAM type (advanced debug)")
-	rootCmd.Flags().String("ipam-strategy", "", "IP allocation strategy (advanced debug)")
-	rootCmd.Flags().Bool("magic-dns-enabled", false, "Enable Magic DNS (advanced debug)")
+	cmd.Flags().String("headscale-url", "", "Headscale server URL (advanced debug)")
+	cmd.Flags().String("headscale-auth-key", "", "Headscale API key (advanced debug)")
+	cmd.Flags().String("tailscale-mode", "", "Tailscale mode (advanced debug)")
+	cmd.Flags().String("tailscale-user", "", "Tailscale user (advanced debug)")
+	cmd.Flags().String("tailscale-tags", "", "Tailscale tags (advanced debug)")
+	cmd.Flags().Int("network-mtu", 0, "Network MTU (advanced debug)")
+	cmd.Flags().Bool("enable-ipv6", false, "Enable IPv6 (advanced debug)")
+	cmd.Flags().Bool("enable-network-policy", false, "Enable network policy (advanced debug)")
+	cmd.Flags().String("ipam-type", "", "IPAM type (advanced debug)")
+	cmd.Flags().String("ipam-strategy", "", "IP allocation strategy (advanced debug)")
+	cmd.Flags().Bool("magic-dns-enabled", false, "Enable Magic DNS (advanced debug)")
+}
 
-	// 添加超时参数
-	rootCmd.Flags().Duration("timeout", 0, "Command timeout (0 = no timeout)")
+// validateNetworkOverlaps 校验集群网段、额外路由、tailnet 地址段和节点主网卡子网互不重叠
+func validateNetworkOverlaps(cfg *config.Config) error {
+	result := cfg.ValidateCIDROverlaps(cfg.ConfigPath)
+
+	hostNets, err := utils.GetDefaultGatewaySubnets()
+	if err != nil {
+		logging.Warnf("Failed to get node subnets, skipping overlap check against them: %v", err)
+	}
+	result.Merge(cfg.ValidateHostSubnetOverlaps(cfg.ConfigPath, hostNets))
+
+	errs := result.Errors()
+	if len(errs) == 0 {
+		return nil
+	}
+	messages := make([]string, 0, len(errs))
+	for _, issue := range errs {
+		messages = append(messages, issue.String())
+	}
+	return fmt.Errorf("overlapping network CIDRs:\n  %s", strings.Join(messages, "\n  "))
 }
 
 // NewHeadCNIDaemonCommand creates the main HeadCNI daemon command
@@ -122,6 +152,14 @@ func runDaemon(cmd *cobra.Command) error {
 		return fmt.Errorf("failed to load config with priority: %v", err)
 	}
 
+	// 网段重叠时跨节点流量会静默失败，启动前直接拒绝
+	if err := validateNetworkOverlaps(cfg); err != nil {
+		return err
+	}
+
+	// 节点注解中上报的版本
+	daemon.Version = Version
+
 	// 直接使用 daemon.New 初始化
 	d, cleanup, err := daemon.InitDaemon(cfg)
 	if err != nil {
@@ -175,7 +213,7 @@ func setupLogLevel(cmd *cobra.Command) error {
 	// 初始化日志配置
 	logFile := os.Getenv("LOG_FILE")
 	if logFile == "" {
-		logFile = "/var/log/headcni/headcni-daemon.log"
+		logFile = constants.DefaultDaemonLogFile
 	}
 
 	// 确保日志目录存在
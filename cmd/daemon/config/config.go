@@ -1,9 +1,25 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultUserspaceProxyAddr userspace 模式下 tailscaled SOCKS5/HTTP 代理的默认监听地址
+const DefaultUserspaceProxyAddr = "localhost:1055"
+
+// tailscale.routeConflictPolicy 的取值
+const (
+	// RouteConflictPolicyWarn 其他节点也通告本节点 Pod CIDR 时只记录告警，照常启用本节点的路由
+	RouteConflictPolicyWarn = "warn"
+	// RouteConflictPolicyYield 其他节点已承载本节点 Pod CIDR 时不启用本节点的路由，本节点已是主路由时保持不变
+	RouteConflictPolicyYield = "yield"
 )
 
 // Config 表示 HeadCNI 的完整配置
@@ -19,13 +35,21 @@ type Config struct {
 	Security    SecurityConfig     `yaml:"security"`
 	Performance PerformanceConfig  `yaml:"performance"`
 	CNIPlugins  []CNIPluginsConfig `yaml:"cniPlugins"`
-	ConfigPath  string             `yaml:"configPath"`
+	// PreservePlugins 接管 CNI 配置目录时保留的外部 CNI：配置文件名（如 10-calico.conflist）或插件类型（如 calico），
+	// 匹配的文件不备份、保持原样，其中的插件排在 headcni 之前加入生成的 configlist
+	PreservePlugins []string `yaml:"preservePlugins"`
+	ConfigPath      string   `yaml:"configPath"`
 }
 
 // DaemonConfig 基础配置
 type DaemonConfig struct {
 	LogLevel    string `yaml:"logLevel"`
 	HostNetwork bool   `yaml:"hostNetwork"`
+	// DryRun 只读模式：路由收敛只记录将要执行的操作，不修改 Tailscale 偏好、Headscale 路由和主机 IP 规则
+	DryRun bool `yaml:"dryRun"`
+	// RemoveAnnotationsOnStop 停止时删除 daemon 写入的节点注解（headcni.tailscale.ip、headcni.io/version 等）
+	// 滚动升级时 daemon 也会停止，默认保留注解，节点不再运行 HeadCNI 时再开启
+	RemoveAnnotationsOnStop bool `yaml:"removeAnnotationsOnStop"`
 }
 
 // HeadscaleConfig HeadScale 配置
@@ -34,6 +58,53 @@ type HeadscaleConfig struct {
 	AuthKey string `yaml:"authKey"`
 	Timeout string `yaml:"timeout"`
 	Retries int    `yaml:"retries"`
+	// 连接成功后删除同主机名的离线陈旧注册，默认关闭
+	ReconcileDuplicates bool `yaml:"reconcileDuplicates"`
+	// Housekeeping 集群级 Headscale 清理，由选主产生的单个节点执行
+	Housekeeping HousekeepingConfig `yaml:"housekeeping"`
+	// CircuitBreaker Headscale 请求熔断
+	CircuitBreaker CircuitBreakerConfig `yaml:"circuitBreaker"`
+	// AuthKeySecretRef 从 Kubernetes Secret 读取 API 密钥，设置后优先于 authKey，密钥轮换无需重启
+	AuthKeySecretRef SecretKeyRef `yaml:"authKeySecretRef"`
+	// StartupJitter 首次访问 Headscale 前的最大启动延迟，实际延迟由节点名哈希在 [0, startupJitter) 内确定，为空或 0 时不延迟
+	StartupJitter string `yaml:"startupJitter"`
+	// AutoApproveRoutes 是否在 Headscale 中自动批准本节点通告的路由，未设置时为 true
+	// 设为 false 时只通告路由，由管理员在 Headscale 中批准（或由 autoApprovers 策略批准）
+	AutoApproveRoutes *bool `yaml:"autoApproveRoutes"`
+	// AutoApproverTag Headscale ACL autoApprovers 中批准 Pod CIDR 路由的标签（如 tag:headcni-router）
+	// 设置后 daemon 确保节点带有该标签，路由由 Headscale 服务端自动批准，不再调用路由启用接口
+	AutoApproverTag string `yaml:"autoApproverTag"`
+}
+
+// SecretKeyRef Kubernetes Secret 中某个 key 的引用
+type SecretKeyRef struct {
+	// Namespace 为空时使用 daemon 所在的命名空间（POD_NAMESPACE）
+	Namespace string `yaml:"namespace"`
+	Name      string `yaml:"name"`
+	Key       string `yaml:"key"`
+}
+
+// IsSet 是否配置了 Secret 引用
+func (r SecretKeyRef) IsSet() bool {
+	return r.Name != ""
+}
+
+// CircuitBreakerConfig Headscale 请求熔断配置
+type CircuitBreakerConfig struct {
+	// FailureThreshold 连续失败多少次后打开熔断器，冷却期内请求直接失败
+	FailureThreshold int `yaml:"failureThreshold"`
+	// Cooldown 熔断器打开后放行单个探测请求前的等待时间
+	Cooldown string `yaml:"cooldown"`
+}
+
+// HousekeepingConfig Headscale 清理配置
+type HousekeepingConfig struct {
+	// Enabled 删除 HeadCNI 注册的过期节点及其孤立路由，默认关闭
+	Enabled bool `yaml:"enabled"`
+	// Interval 清理间隔，默认 10m
+	Interval string `yaml:"interval"`
+	// LeaseName 选主使用的 Lease 名称，默认 headcni-headscale-housekeeping
+	LeaseName string `yaml:"leaseName"`
 }
 
 // TailscaleConfig Tailscale 配置
@@ -47,6 +118,46 @@ type TailscaleConfig struct {
 	User          string         `yaml:"user"`
 	Tags          []string       `yaml:"tags"`
 	InterfaceName string         `yaml:"interfaceName"`
+	// 单次 Headscale/Tailscale/K8s 调用的超时时间
+	CallTimeout string `yaml:"callTimeout"`
+	// WatchdogTimeout 看门狗探测 tailscaled 时 GetStatus 的硬超时，可热加载
+	WatchdogTimeout string `yaml:"watchdogTimeout"`
+	// WatchdogThreshold 连续超时达到该次数时认为 tailscaled 卡死：daemon 模式重启 tailscaled，host 模式标记为不健康；为 0 时使用默认值，可热加载
+	WatchdogThreshold int `yaml:"watchdogThreshold"`
+	// OrphanInterfaceGracePeriod 只剩网卡、没有 socket/state/PID 文件时，删除网卡前等待并重新检查的时间，为空时使用默认值，0 表示不等待，可热加载
+	OrphanInterfaceGracePeriod string `yaml:"orphanInterfaceGracePeriod"`
+	// HealthCheckInterval 健康检查（含 Headscale 路由检查）间隔，可热加载
+	HealthCheckInterval string `yaml:"healthCheckInterval"`
+	// KeepAliveInterval daemon 模式下 tailscaled 进程保活检查间隔，可热加载
+	KeepAliveInterval string `yaml:"keepAliveInterval"`
+	// RuleSyncInterval 主机 IP 规则维护间隔，可热加载
+	RuleSyncInterval string `yaml:"ruleSyncInterval"`
+	// StatusCacheTTL tailscaled 状态缓存时间，期间的状态查询复用最近一次结果；为空或 0 时不缓存，可热加载
+	StatusCacheTTL string `yaml:"statusCacheTTL"`
+	// AdvertiseExtraRoutes 除本节点 Pod CIDR 外额外通告的路由，使节点充当子网路由器
+	AdvertiseExtraRoutes []string `yaml:"advertiseExtraRoutes"`
+	// AdvertiseOnConnect 登录时在首次偏好中直接通告 Pod CIDR 和额外路由，缩短节点在线但未通告路由的窗口
+	AdvertiseOnConnect bool `yaml:"advertiseOnConnect"`
+	// RouteConflictPolicy 其他节点也通告本节点 Pod CIDR 时的处理方式：warn 记录告警后照常启用，yield 在其他节点已承载该前缀时不启用本节点的路由
+	RouteConflictPolicy string `yaml:"routeConflictPolicy"`
+	// PreferredDERP 固定本节点的 DERP home 区域 ID，无法直连时经同机房的中继转发以降低延迟；为 0 时由 tailscaled 自动选择，可热加载
+	PreferredDERP int `yaml:"preferredDERP"`
+	// FallbackURLs 按顺序排列的备用控制服务器，当前控制服务器持续不可用时依次切换
+	FallbackURLs []string `yaml:"fallbackURLs"`
+	// ManagedTagPrefix HeadCNI 管理的 ACL 标签前缀，以此开头但不在 tags 中的节点标签会被移除
+	ManagedTagPrefix string `yaml:"managedTagPrefix"`
+	// AcceptRoutesFromTags 只保留带有其中任一标签的节点通告的子网路由，其余节点的路由从主机路由表删除；为空时接受全部路由
+	AcceptRoutesFromTags []string `yaml:"acceptRoutesFromTags"`
+	// ProtectedInterfacePrefixes 追加到内置列表的受保护接口名前缀，清理接口时永远不会删除匹配的接口
+	ProtectedInterfacePrefixes []string `yaml:"protectedInterfacePrefixes"`
+	// Userspace daemon 模式下以 userspace-networking 启动 tailscaled，不创建 TUN 接口，用于无法获得 CAP_NET_ADMIN 或 /dev/net/tun 的节点
+	Userspace bool `yaml:"userspace"`
+	// UserspaceProxyAddr userspace 模式下 tailscaled 提供的 SOCKS5/HTTP 代理监听地址，Pod 通过该代理访问 tailnet
+	UserspaceProxyAddr string `yaml:"userspaceProxyAddr"`
+	// BindInterface daemon 模式下 tailscaled 流量（WireGuard、DERP、控制连接）的出口接口，用于多网卡节点指定数据网卡；为空时按主路由表选择
+	BindInterface string `yaml:"bindInterface"`
+	// ImportStateFrom daemon 模式首次启动（还没有受管状态文件）时从该路径复制已有的 tailscaled 状态文件，沿用原节点身份而不是重新注册
+	ImportStateFrom string `yaml:"importStateFrom"`
 }
 
 // SocketConfig Socket 配置
@@ -68,12 +179,36 @@ type NetworkConfig struct {
 	MTU                 int           `yaml:"mtu"`
 	EnableIPv6          bool          `yaml:"enableIPv6"`
 	EnableNetworkPolicy bool          `yaml:"enableNetworkPolicy"`
+	// Policy enableNetworkPolicy 开启时的 Pod 隔离策略
+	Policy PodIsolationConfig `yaml:"policy"`
+	// PodRoutes 在 Pod 网络命名空间中额外安装的路由，经 Pod 网关转发到节点，用于访问 tailnet 子网路由器通告的网段
+	PodRoutes []string `yaml:"podRoutes"`
+}
+
+// PodIsolationConfig Pod 隔离策略配置
+// 同命名空间的 Pod 始终互通，其余流量按以下开关放行，未放行的流量被丢弃
+type PodIsolationConfig struct {
+	// Enforce 在宿主机上下发 iptables 隔离规则，默认关闭；只开启 enableNetworkPolicy 时不丢弃任何流量
+	Enforce             bool  `yaml:"enforce"`
+	AllowHostAccess     *bool `yaml:"allowHostAccess"`     // 允许 Pod 访问本节点，默认开启
+	AllowServiceAccess  *bool `yaml:"allowServiceAccess"`  // 允许 Pod 访问 Service CIDR，默认开启
+	AllowExternalAccess *bool `yaml:"allowExternalAccess"` // 允许 Pod 访问集群和 tailnet 以外的地址，默认开启
+	EgressAllowed       bool  `yaml:"egressAllowed"`       // 允许 Pod 的所有出向流量
+	// AllowedTags 带有这些 ACL tag 的 tailnet 节点可以与 Pod 双向互通
+	AllowedTags []string `yaml:"allowedTags"`
+	// OpenNamespaces 这些命名空间中的 Pod 接受来自任意 Pod 的入向流量，默认 kube-system
+	OpenNamespaces []string `yaml:"openNamespaces"`
+	// ReconcileInterval 规则校验周期，规则被清空时在下个周期恢复
+	ReconcileInterval string `yaml:"reconcileInterval"`
 }
 
 // PodCIDRConfig Pod CIDR 配置
 type PodCIDRConfig struct {
 	Base    string `yaml:"base"`
 	PerNode string `yaml:"perNode"`
+	// Fallback 节点 Spec.PodCIDR 为空且没有 headcni.io/pod-cidr 注解时使用的网段，必须位于 base 内
+	// 所有节点共用该网段，每个节点从中派生 perNode 大小的切片
+	Fallback string `yaml:"fallback"`
 }
 
 // IPAMConfig IPAM 配置
@@ -82,6 +217,21 @@ type IPAMConfig struct {
 	Strategy   string         `yaml:"strategy"`
 	GCInterval string         `yaml:"gcInterval"`
 	Subnets    []SubnetConfig `yaml:"subnets"`
+	// Reserved 不分配给 Pod 的地址：单个 IP、范围（a-b）或 CIDR，必须位于节点子网内
+	Reserved []string `yaml:"reserved"`
+
+	LeakReconcile LeakReconcileConfig `yaml:"leakReconcile"`
+	// PodAnnotations daemon 观察到 Pod IP 后写入 headcni.io/pod-ip 和 headcni.io/host-ts-ip 注解
+	PodAnnotations bool `yaml:"podAnnotations"`
+}
+
+// LeakReconcileConfig IPAM 泄漏回收配置
+// 定期对比 host-local 存储（kube-backed 时为分配 ConfigMap）与节点上运行中的 Pod，回收没有对应 Pod 的分配
+type LeakReconcileConfig struct {
+	Enabled     bool   `yaml:"enabled"`
+	Interval    string `yaml:"interval"`
+	GracePeriod string `yaml:"gracePeriod"`
+	DataDir     string `yaml:"dataDir"`
 }
 
 // SubnetConfig 子网配置
@@ -94,6 +244,17 @@ type SubnetConfig struct {
 type DNSConfig struct {
 	MagicDNS MagicDNSConfig  `yaml:"magicDNS"`
 	Custom   CustomDNSConfig `yaml:"custom"`
+	// ServiceIP 集群 DNS 服务 IP，设置后不再从 kube-dns Service 或 kubelet 配置推断
+	ServiceIP string `yaml:"serviceIP"`
+	// ClusterDomain 集群域名，设置后不再从 Corefile 或 kubelet 配置推断
+	ClusterDomain string `yaml:"clusterDomain"`
+	// ServiceNamespaces 推断 DNS 服务 IP 时查找 DNS 服务和 NodeLocal DNSCache 的命名空间，为空时使用内置列表，重启后生效
+	ServiceNamespaces []string `yaml:"serviceNamespaces"`
+	// SearchTemplate 启用 magicDNS 时 Pod 的完整 search 列表（按顺序），支持 {cluster_domain}、{namespace}、{tailnet_domain}，
+	// 设置后替代默认的集群域名和 magicDNS.searchDomains
+	SearchTemplate []string `yaml:"searchTemplate"`
+	// TailnetDomain tailnet 的 MagicDNS 基础域名（Headscale 的 dns.base_domain），用于展开 {tailnet_domain}
+	TailnetDomain string `yaml:"tailnetDomain"`
 }
 
 // MagicDNSConfig Magic DNS 配置
@@ -117,6 +278,25 @@ type MonitoringConfig struct {
 	Enabled bool   `yaml:"enabled"`
 	Port    int    `yaml:"port"`
 	Path    string `yaml:"path"`
+	// TLS 配置证书后以 HTTPS 提供服务
+	TLS MonitoringTLSConfig `yaml:"tls"`
+	// Auth 指标端点的访问控制，/health 始终不需要认证
+	Auth MonitoringAuthConfig `yaml:"auth"`
+}
+
+// MonitoringTLSConfig 监控端点 TLS 配置
+type MonitoringTLSConfig struct {
+	CertFile string `yaml:"certFile"`
+	KeyFile  string `yaml:"keyFile"`
+	// ClientCAFile 设置后校验客户端证书，持有有效证书的请求无需 bearer token
+	ClientCAFile string `yaml:"clientCAFile"`
+}
+
+// MonitoringAuthConfig 监控端点认证配置
+// 未配置 token 和客户端 CA 时端点不做认证
+type MonitoringAuthConfig struct {
+	// BearerTokenFile 保存 bearer token 的文件，通常挂载自 Secret
+	BearerTokenFile string `yaml:"bearerTokenFile"`
 }
 
 // CustomMetricsConfig 自定义指标配置
@@ -207,6 +387,15 @@ type CNIPluginsConfig struct {
 	Config   string `yaml:"config"`
 }
 
+// 以下配置项为空时由使用处套用的默认值，config show --effective 按这些值输出
+const (
+	DefaultHousekeepingInterval       = 10 * time.Minute
+	DefaultHousekeepingLeaseName      = "headcni-headscale-housekeeping"
+	DefaultOrphanInterfaceGracePeriod = 15 * time.Second
+	DefaultWatchdogTimeout            = 10 * time.Second
+	DefaultWatchdogThreshold          = 3
+)
+
 // LoadDefaultConfig 加载默认配置
 func DefaultConfig() (*Config, error) {
 	// 获取当前可执行文件所在目录
@@ -234,6 +423,10 @@ func DefaultConfig() (*Config, error) {
 			AuthKey: "",
 			Timeout: "30s",
 			Retries: 3,
+			CircuitBreaker: CircuitBreakerConfig{
+				FailureThreshold: 5,
+				Cooldown:         "30s",
+			},
 		},
 		Tailscale: TailscaleConfig{
 			Mode: "daemon",
@@ -251,6 +444,14 @@ func DefaultConfig() (*Config, error) {
 			User:          "server",
 			Tags:          []string{"tag:control-server", "tag:headcni"},
 			InterfaceName: "headcni01",
+			CallTimeout:   "30s",
+
+			HealthCheckInterval: "30s",
+			KeepAliveInterval:   "15s",
+			RuleSyncInterval:    "30s",
+			ManagedTagPrefix:    "tag:headcni",
+			RouteConflictPolicy: RouteConflictPolicyWarn,
+			UserspaceProxyAddr:  DefaultUserspaceProxyAddr,
 		},
 		Network: NetworkConfig{
 			PodCIDR: PodCIDRConfig{
@@ -261,12 +462,22 @@ func DefaultConfig() (*Config, error) {
 			MTU:                 1280,
 			EnableIPv6:          false,
 			EnableNetworkPolicy: true,
+			Policy: PodIsolationConfig{
+				OpenNamespaces:    []string{"kube-system"},
+				ReconcileInterval: "30s",
+			},
 		},
 		IPAM: IPAMConfig{
 			Type:       "host-local",
 			Strategy:   "sequential",
 			GCInterval: "1h",
 			Subnets:    []SubnetConfig{}, // 将根据 podCIDR 动态生成
+			LeakReconcile: LeakReconcileConfig{
+				Enabled:     false,
+				Interval:    "10m",
+				GracePeriod: "10m",
+				DataDir:     "/var/lib/cni/networks",
+			},
 		},
 		DNS: DNSConfig{
 			MagicDNS: MagicDNSConfig{
@@ -352,3 +563,96 @@ func DefaultConfig() (*Config, error) {
 		},
 	}, nil
 }
+
+// UsesUserspaceNetworking 是否以 userspace-networking 运行 HeadCNI 管理的 tailscaled
+// host 模式复用主机 tailscaled，该选项不生效
+func (c *Config) UsesUserspaceNetworking() bool {
+	return c != nil && c.Tailscale.Userspace && c.Tailscale.Mode != "host"
+}
+
+// StatusCacheDuration 返回 tailscaled 状态缓存时间（tailscale.statusCacheTTL），未配置或无效时为 0，即不缓存
+func (c *Config) StatusCacheDuration() time.Duration {
+	if c == nil || c.Tailscale.StatusCacheTTL == "" {
+		return 0
+	}
+	ttl, err := time.ParseDuration(c.Tailscale.StatusCacheTTL)
+	if err != nil || ttl < 0 {
+		return 0
+	}
+	return ttl
+}
+
+// AutoApproveRoutesEnabled 是否由 daemon 在 Headscale 中批准本节点通告的路由（headscale.autoApproveRoutes，默认开启）
+// 设置 headscale.autoApproverTag 时路由由服务端的 autoApprovers 批准，始终返回 false
+func (c *Config) AutoApproveRoutesEnabled() bool {
+	if c != nil && c.Headscale.AutoApproverTag != "" {
+		return false
+	}
+	return c == nil || c.Headscale.AutoApproveRoutes == nil || *c.Headscale.AutoApproveRoutes
+}
+
+// IsRouterMode 是否为 router 模式：节点只作为子网路由器通告额外路由，不承载 Pod
+func (c *Config) IsRouterMode() bool {
+	return c != nil && c.Tailscale.Mode == "router"
+}
+
+// TailnetMTU 返回 tailscale 接口的期望 MTU（tailscale.mtu），未设置时为 tailscaled 默认的 TUN MTU（即 MinTailnetMTU）
+func (c *Config) TailnetMTU() int {
+	if c == nil || c.Tailscale.MTU <= 0 {
+		return MinTailnetMTU
+	}
+	return c.Tailscale.MTU
+}
+
+// MetricsPath 返回指标端点路径（monitoring.path），未设置时为 /metrics，缺少前导 / 时自动补上
+func (c *Config) MetricsPath() string {
+	if c == nil || c.Monitoring.Path == "" {
+		return "/metrics"
+	}
+	if !strings.HasPrefix(c.Monitoring.Path, "/") {
+		return "/" + c.Monitoring.Path
+	}
+	return c.Monitoring.Path
+}
+
+// HostAccessAllowed 是否允许 Pod 访问本节点（network.policy.allowHostAccess，默认开启）
+func (p PodIsolationConfig) HostAccessAllowed() bool {
+	return p.AllowHostAccess == nil || *p.AllowHostAccess
+}
+
+// ServiceAccessAllowed 是否允许 Pod 访问 Service CIDR（network.policy.allowServiceAccess，默认开启）
+func (p PodIsolationConfig) ServiceAccessAllowed() bool {
+	return p.AllowServiceAccess == nil || *p.AllowServiceAccess
+}
+
+// ExternalAccessAllowed 是否允许 Pod 访问集群和 tailnet 以外的地址（network.policy.allowExternalAccess，默认开启）
+func (p PodIsolationConfig) ExternalAccessAllowed() bool {
+	return p.AllowExternalAccess == nil || *p.AllowExternalAccess
+}
+
+// Hash 返回生效配置的哈希，用于在节点注解中标识配置版本
+// 结构体按字段顺序序列化，插件配置中的 JSON 按键排序后参与计算，保证与原始文本的键顺序无关
+func (c *Config) Hash() (string, error) {
+	normalized := *c
+	// 配置文件路径不影响生效配置
+	normalized.ConfigPath = ""
+
+	normalized.CNIPlugins = make([]CNIPluginsConfig, len(c.CNIPlugins))
+	for i, plugin := range c.CNIPlugins {
+		var raw interface{}
+		if err := json.Unmarshal([]byte(plugin.Config), &raw); err == nil {
+			if canonical, err := json.Marshal(raw); err == nil {
+				plugin.Config = string(canonical)
+			}
+		}
+		normalized.CNIPlugins[i] = plugin
+	}
+
+	data, err := json.Marshal(&normalized)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal config: %v", err)
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:16], nil
+}
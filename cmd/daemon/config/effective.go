@@ -0,0 +1,239 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Source 配置项的来源
+type Source string
+
+const (
+	SourceDefault Source = "default"
+	SourceFile    Source = "file"
+	SourceEnv     Source = "env"
+	SourceFlag    Source = "flag"
+)
+
+// redactedValue 敏感配置项输出时的替代值
+const redactedValue = "<redacted>"
+
+// EffectiveConfig 合并命令行参数、环境变量、配置文件和默认值后实际生效的配置
+// Sources 以 YAML 路径（如 headscale.url）为键，记录每个配置项的来源
+type EffectiveConfig struct {
+	Config  *Config
+	Sources map[string]Source
+}
+
+// EffectiveField 单个配置项的生效值及来源
+type EffectiveField struct {
+	Path   string `json:"path"`
+	Value  string `json:"value"`
+	Source Source `json:"source"`
+}
+
+// Redacted 返回隐藏了 API 密钥和 token 的配置副本，用于输出
+func (e *EffectiveConfig) Redacted() *Config {
+	redacted := *e.Config
+	if redacted.Headscale.AuthKey != "" {
+		redacted.Headscale.AuthKey = redactedValue
+	}
+	if redacted.Security.Auth.Token != "" {
+		redacted.Security.Auth.Token = redactedValue
+	}
+	return &redacted
+}
+
+// resolved 返回用于输出的配置副本：敏感值已隐藏，留空的配置项替换为使用处实际套用的默认值
+func (e *EffectiveConfig) resolved() *Config {
+	cfg := e.Redacted()
+
+	if cfg.Headscale.AutoApproveRoutes == nil {
+		cfg.Headscale.AutoApproveRoutes = boolPtr(true)
+	}
+	if cfg.Headscale.Housekeeping.Interval == "" {
+		cfg.Headscale.Housekeeping.Interval = DefaultHousekeepingInterval.String()
+	}
+	if cfg.Headscale.Housekeeping.LeaseName == "" {
+		cfg.Headscale.Housekeeping.LeaseName = DefaultHousekeepingLeaseName
+	}
+	if cfg.Tailscale.OrphanInterfaceGracePeriod == "" {
+		cfg.Tailscale.OrphanInterfaceGracePeriod = DefaultOrphanInterfaceGracePeriod.String()
+	}
+	if cfg.Tailscale.WatchdogTimeout == "" {
+		cfg.Tailscale.WatchdogTimeout = DefaultWatchdogTimeout.String()
+	}
+	if cfg.Tailscale.WatchdogThreshold <= 0 {
+		cfg.Tailscale.WatchdogThreshold = DefaultWatchdogThreshold
+	}
+	if cfg.Tailscale.StatusCacheTTL == "" {
+		cfg.Tailscale.StatusCacheTTL = cfg.StatusCacheDuration().String()
+	}
+
+	policy := &cfg.Network.Policy
+	policy.AllowHostAccess = boolPtr(policy.HostAccessAllowed())
+	policy.AllowServiceAccess = boolPtr(policy.ServiceAccessAllowed())
+	policy.AllowExternalAccess = boolPtr(policy.ExternalAccessAllowed())
+	return cfg
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+// Fields 返回按路径排序的全部配置项，敏感值已隐藏
+func (e *EffectiveConfig) Fields() ([]EffectiveField, error) {
+	values, err := flattenConfig(e.resolved())
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make([]EffectiveField, 0, len(values))
+	for path, value := range values {
+		fields = append(fields, EffectiveField{Path: path, Value: fmt.Sprint(value), Source: e.source(path)})
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Path < fields[j].Path })
+	return fields, nil
+}
+
+// YAML 以 YAML 输出生效配置，每个配置项以行尾注释标注来源
+func (e *EffectiveConfig) YAML() ([]byte, error) {
+	var node yaml.Node
+	if err := node.Encode(e.resolved()); err != nil {
+		return nil, fmt.Errorf("failed to encode config: %v", err)
+	}
+	e.annotate(&node, "")
+	return yaml.Marshal(&node)
+}
+
+// JSON 以 JSON 输出生效配置，来源单独列在 sources 中
+func (e *EffectiveConfig) JSON() ([]byte, error) {
+	values, err := configToMap(e.resolved())
+	if err != nil {
+		return nil, err
+	}
+	fields, err := e.Fields()
+	if err != nil {
+		return nil, err
+	}
+
+	sources := make(map[string]Source, len(fields))
+	for _, field := range fields {
+		sources[field.Path] = field.Source
+	}
+	return json.MarshalIndent(map[string]interface{}{
+		"config":  values,
+		"sources": sources,
+	}, "", "  ")
+}
+
+// annotate 为映射节点下的每个配置项添加来源注释
+func (e *EffectiveConfig) annotate(node *yaml.Node, prefix string) {
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		path := key.Value
+		if prefix != "" {
+			path = prefix + "." + key.Value
+		}
+
+		switch value.Kind {
+		case yaml.MappingNode:
+			e.annotate(value, path)
+		case yaml.ScalarNode:
+			value.LineComment = string(e.source(path))
+		case yaml.SequenceNode:
+			// 空列表以 [] 输出，注释只能跟在值后面
+			if len(value.Content) == 0 {
+				value.LineComment = string(e.source(path))
+			} else {
+				key.LineComment = string(e.source(path))
+			}
+		default:
+			key.LineComment = string(e.source(path))
+		}
+	}
+}
+
+// source 返回配置项来源，未记录的配置项视为默认值
+func (e *EffectiveConfig) source(path string) Source {
+	if source, ok := e.Sources[path]; ok {
+		return source
+	}
+	return SourceDefault
+}
+
+// sourceTracker 在加载的每个阶段之后比较配置，把发生变化的配置项归属到该阶段的来源
+// 后续阶段设置了与前一阶段相同的值时来源保持不变
+type sourceTracker struct {
+	last    map[string]interface{}
+	sources map[string]Source
+}
+
+func newSourceTracker(cfg *Config) (*sourceTracker, error) {
+	values, err := flattenConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &sourceTracker{last: values, sources: make(map[string]Source)}, nil
+}
+
+// record 记录本阶段修改过的配置项
+func (t *sourceTracker) record(cfg *Config, source Source) error {
+	values, err := flattenConfig(cfg)
+	if err != nil {
+		return err
+	}
+	for path, value := range values {
+		if !reflect.DeepEqual(t.last[path], value) {
+			t.sources[path] = source
+		}
+	}
+	t.last = values
+	return nil
+}
+
+// configToMap 按 YAML 字段名把配置转换为嵌套 map
+func configToMap(cfg *Config) (map[string]interface{}, error) {
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode config: %v", err)
+	}
+	values := make(map[string]interface{})
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("failed to decode config: %v", err)
+	}
+	return values, nil
+}
+
+// flattenConfig 把配置展开为 YAML 路径到值的映射，列表作为单个值
+func flattenConfig(cfg *Config) (map[string]interface{}, error) {
+	values, err := configToMap(cfg)
+	if err != nil {
+		return nil, err
+	}
+	flat := make(map[string]interface{})
+	flattenInto(flat, "", values)
+	return flat, nil
+}
+
+func flattenInto(flat map[string]interface{}, prefix string, values map[string]interface{}) {
+	for key, value := range values {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		if nested, ok := value.(map[string]interface{}); ok {
+			flattenInto(flat, path, nested)
+			continue
+		}
+		flat[path] = value
+	}
+}
@@ -14,25 +14,55 @@ import (
 // LoadConfigWithPriority loads configuration with priority order
 // Priority: command line args > environment variables > config file > default constants
 func LoadConfigWithPriority(cmd *cobra.Command) (*Config, error) {
+	cfg, _, err := loadConfigWithSources(cmd)
+	return cfg, err
+}
+
+// LoadEffectiveConfig loads configuration like LoadConfigWithPriority and records
+// which source (flag/env/file/default) each value came from
+func LoadEffectiveConfig(cmd *cobra.Command) (*EffectiveConfig, error) {
+	cfg, sources, err := loadConfigWithSources(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return &EffectiveConfig{Config: cfg, Sources: sources}, nil
+}
+
+// loadConfigWithSources applies all configuration sources in priority order;
+// after each stage the values it changed are attributed to that source
+func loadConfigWithSources(cmd *cobra.Command) (*Config, map[string]Source, error) {
 	// 1. Create default configuration (lowest priority)
 	cfg := &Config{}
+	tracker, err := newSourceTracker(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	// 2. Load configuration file (medium priority - persistent settings)
 	configFile, _ := cmd.Flags().GetString("config")
-	cfg.ConfigPath = configFile
 	if configFile != "" {
 		if err := loadConfigFile(cfg, configFile); err != nil {
-			return nil, errors.Wrap(err, "failed to load config file")
+			return nil, nil, errors.Wrap(err, "failed to load config file")
 		}
 	}
+	if err := tracker.record(cfg, SourceFile); err != nil {
+		return nil, nil, err
+	}
 
 	// 3. Apply environment variable overrides (high priority - container deployment)
 	applyEnvironmentOverrides(cfg)
+	if err := tracker.record(cfg, SourceEnv); err != nil {
+		return nil, nil, err
+	}
 
 	// 4. Apply command line argument overrides (highest priority - debug/temporary)
 	applyCommandLineOverrides(cfg, cmd)
+	cfg.ConfigPath = configFile
+	if err := tracker.record(cfg, SourceFlag); err != nil {
+		return nil, nil, err
+	}
 
-	return cfg, nil
+	return cfg, tracker.sources, nil
 }
 
 // loadConfigFile loads configuration from file and merges with existing config
@@ -142,6 +172,9 @@ func applyCommandLineOverrides(cfg *Config, cmd *cobra.Command) {
 	if logLevel, _ := cmd.Flags().GetString("log-level"); logLevel != "" {
 		cfg.Daemon.LogLevel = logLevel
 	}
+	if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+		cfg.Daemon.DryRun = dryRun
+	}
 
 	// Monitoring parameters
 	if monitoringEnabled, _ := cmd.Flags().GetBool("monitoring-enabled"); monitoringEnabled {
@@ -190,6 +223,43 @@ func mergeConfig(target, source *Config) {
 	if source.Headscale.AuthKey != "" {
 		target.Headscale.AuthKey = source.Headscale.AuthKey
 	}
+	if source.Headscale.Housekeeping.Enabled {
+		target.Headscale.Housekeeping.Enabled = source.Headscale.Housekeeping.Enabled
+	}
+	if source.Headscale.Housekeeping.Interval != "" {
+		target.Headscale.Housekeeping.Interval = source.Headscale.Housekeeping.Interval
+	}
+	if source.Headscale.Housekeeping.LeaseName != "" {
+		target.Headscale.Housekeeping.LeaseName = source.Headscale.Housekeeping.LeaseName
+	}
+	if source.Headscale.ReconcileDuplicates {
+		target.Headscale.ReconcileDuplicates = source.Headscale.ReconcileDuplicates
+	}
+	if source.Headscale.CircuitBreaker.FailureThreshold != 0 {
+		target.Headscale.CircuitBreaker.FailureThreshold = source.Headscale.CircuitBreaker.FailureThreshold
+	}
+	if source.Headscale.CircuitBreaker.Cooldown != "" {
+		target.Headscale.CircuitBreaker.Cooldown = source.Headscale.CircuitBreaker.Cooldown
+	}
+	if source.Headscale.AuthKeySecretRef.Namespace != "" {
+		target.Headscale.AuthKeySecretRef.Namespace = source.Headscale.AuthKeySecretRef.Namespace
+	}
+	if source.Headscale.AuthKeySecretRef.Name != "" {
+		target.Headscale.AuthKeySecretRef.Name = source.Headscale.AuthKeySecretRef.Name
+	}
+	if source.Headscale.AuthKeySecretRef.Key != "" {
+		target.Headscale.AuthKeySecretRef.Key = source.Headscale.AuthKeySecretRef.Key
+	}
+	if source.Headscale.StartupJitter != "" {
+		target.Headscale.StartupJitter = source.Headscale.StartupJitter
+	}
+	if source.Headscale.AutoApproveRoutes != nil {
+		autoApprove := *source.Headscale.AutoApproveRoutes
+		target.Headscale.AutoApproveRoutes = &autoApprove
+	}
+	if source.Headscale.AutoApproverTag != "" {
+		target.Headscale.AutoApproverTag = source.Headscale.AutoApproverTag
+	}
 
 	// Tailscale configuration
 	if source.Tailscale.Mode != "" {
@@ -198,6 +268,9 @@ func mergeConfig(target, source *Config) {
 	if source.Tailscale.URL != "" {
 		target.Tailscale.URL = source.Tailscale.URL
 	}
+	if len(source.Tailscale.FallbackURLs) > 0 {
+		target.Tailscale.FallbackURLs = source.Tailscale.FallbackURLs
+	}
 	if source.Tailscale.Socket.Path != "" {
 		target.Tailscale.Socket.Path = source.Tailscale.Socket.Path
 	}
@@ -219,11 +292,71 @@ func mergeConfig(target, source *Config) {
 	if len(source.Tailscale.Tags) > 0 {
 		target.Tailscale.Tags = source.Tailscale.Tags
 	}
+	if source.Tailscale.CallTimeout != "" {
+		target.Tailscale.CallTimeout = source.Tailscale.CallTimeout
+	}
+	if source.Tailscale.WatchdogTimeout != "" {
+		target.Tailscale.WatchdogTimeout = source.Tailscale.WatchdogTimeout
+	}
+	if source.Tailscale.WatchdogThreshold != 0 {
+		target.Tailscale.WatchdogThreshold = source.Tailscale.WatchdogThreshold
+	}
+	if source.Tailscale.OrphanInterfaceGracePeriod != "" {
+		target.Tailscale.OrphanInterfaceGracePeriod = source.Tailscale.OrphanInterfaceGracePeriod
+	}
+	if source.Tailscale.HealthCheckInterval != "" {
+		target.Tailscale.HealthCheckInterval = source.Tailscale.HealthCheckInterval
+	}
+	if source.Tailscale.KeepAliveInterval != "" {
+		target.Tailscale.KeepAliveInterval = source.Tailscale.KeepAliveInterval
+	}
+	if source.Tailscale.RuleSyncInterval != "" {
+		target.Tailscale.RuleSyncInterval = source.Tailscale.RuleSyncInterval
+	}
+	if source.Tailscale.StatusCacheTTL != "" {
+		target.Tailscale.StatusCacheTTL = source.Tailscale.StatusCacheTTL
+	}
+	if len(source.Tailscale.AdvertiseExtraRoutes) > 0 {
+		target.Tailscale.AdvertiseExtraRoutes = source.Tailscale.AdvertiseExtraRoutes
+	}
+	if source.Tailscale.AdvertiseOnConnect {
+		target.Tailscale.AdvertiseOnConnect = source.Tailscale.AdvertiseOnConnect
+	}
+	if source.Tailscale.RouteConflictPolicy != "" {
+		target.Tailscale.RouteConflictPolicy = source.Tailscale.RouteConflictPolicy
+	}
+	if source.Tailscale.PreferredDERP != 0 {
+		target.Tailscale.PreferredDERP = source.Tailscale.PreferredDERP
+	}
+	if source.Tailscale.ManagedTagPrefix != "" {
+		target.Tailscale.ManagedTagPrefix = source.Tailscale.ManagedTagPrefix
+	}
+	if len(source.Tailscale.AcceptRoutesFromTags) > 0 {
+		target.Tailscale.AcceptRoutesFromTags = source.Tailscale.AcceptRoutesFromTags
+	}
+	if len(source.Tailscale.ProtectedInterfacePrefixes) > 0 {
+		target.Tailscale.ProtectedInterfacePrefixes = source.Tailscale.ProtectedInterfacePrefixes
+	}
+	if source.Tailscale.Userspace {
+		target.Tailscale.Userspace = source.Tailscale.Userspace
+	}
+	if source.Tailscale.UserspaceProxyAddr != "" {
+		target.Tailscale.UserspaceProxyAddr = source.Tailscale.UserspaceProxyAddr
+	}
+	if source.Tailscale.BindInterface != "" {
+		target.Tailscale.BindInterface = source.Tailscale.BindInterface
+	}
+	if source.Tailscale.ImportStateFrom != "" {
+		target.Tailscale.ImportStateFrom = source.Tailscale.ImportStateFrom
+	}
 
 	// Network configuration
 	if source.Network.PodCIDR.Base != "" {
 		target.Network.PodCIDR.Base = source.Network.PodCIDR.Base
 	}
+	if source.Network.PodCIDR.Fallback != "" {
+		target.Network.PodCIDR.Fallback = source.Network.PodCIDR.Fallback
+	}
 	if source.Network.ServiceCIDR != "" {
 		target.Network.ServiceCIDR = source.Network.ServiceCIDR
 	}
@@ -236,6 +369,36 @@ func mergeConfig(target, source *Config) {
 	if source.Network.EnableNetworkPolicy {
 		target.Network.EnableNetworkPolicy = source.Network.EnableNetworkPolicy
 	}
+	if len(source.Network.PodRoutes) > 0 {
+		target.Network.PodRoutes = source.Network.PodRoutes
+	}
+	if source.Network.Policy.Enforce {
+		target.Network.Policy.Enforce = source.Network.Policy.Enforce
+	}
+	if source.Network.Policy.AllowHostAccess != nil {
+		allowHost := *source.Network.Policy.AllowHostAccess
+		target.Network.Policy.AllowHostAccess = &allowHost
+	}
+	if source.Network.Policy.AllowServiceAccess != nil {
+		allowService := *source.Network.Policy.AllowServiceAccess
+		target.Network.Policy.AllowServiceAccess = &allowService
+	}
+	if source.Network.Policy.AllowExternalAccess != nil {
+		allowExternal := *source.Network.Policy.AllowExternalAccess
+		target.Network.Policy.AllowExternalAccess = &allowExternal
+	}
+	if source.Network.Policy.EgressAllowed {
+		target.Network.Policy.EgressAllowed = source.Network.Policy.EgressAllowed
+	}
+	if len(source.Network.Policy.AllowedTags) > 0 {
+		target.Network.Policy.AllowedTags = source.Network.Policy.AllowedTags
+	}
+	if len(source.Network.Policy.OpenNamespaces) > 0 {
+		target.Network.Policy.OpenNamespaces = source.Network.Policy.OpenNamespaces
+	}
+	if source.Network.Policy.ReconcileInterval != "" {
+		target.Network.Policy.ReconcileInterval = source.Network.Policy.ReconcileInterval
+	}
 
 	// IPAM configuration
 	if source.IPAM.Type != "" {
@@ -247,8 +410,44 @@ func mergeConfig(target, source *Config) {
 	if source.IPAM.GCInterval != "" {
 		target.IPAM.GCInterval = source.IPAM.GCInterval
 	}
+	if len(source.IPAM.Reserved) > 0 {
+		target.IPAM.Reserved = source.IPAM.Reserved
+	}
+	if len(source.PreservePlugins) > 0 {
+		target.PreservePlugins = source.PreservePlugins
+	}
+	if source.IPAM.LeakReconcile.Enabled {
+		target.IPAM.LeakReconcile.Enabled = source.IPAM.LeakReconcile.Enabled
+	}
+	if source.IPAM.LeakReconcile.Interval != "" {
+		target.IPAM.LeakReconcile.Interval = source.IPAM.LeakReconcile.Interval
+	}
+	if source.IPAM.LeakReconcile.GracePeriod != "" {
+		target.IPAM.LeakReconcile.GracePeriod = source.IPAM.LeakReconcile.GracePeriod
+	}
+	if source.IPAM.LeakReconcile.DataDir != "" {
+		target.IPAM.LeakReconcile.DataDir = source.IPAM.LeakReconcile.DataDir
+	}
+	if source.IPAM.PodAnnotations {
+		target.IPAM.PodAnnotations = source.IPAM.PodAnnotations
+	}
 
 	// DNS configuration
+	if source.DNS.ServiceIP != "" {
+		target.DNS.ServiceIP = source.DNS.ServiceIP
+	}
+	if source.DNS.ClusterDomain != "" {
+		target.DNS.ClusterDomain = source.DNS.ClusterDomain
+	}
+	if len(source.DNS.ServiceNamespaces) > 0 {
+		target.DNS.ServiceNamespaces = source.DNS.ServiceNamespaces
+	}
+	if len(source.DNS.SearchTemplate) > 0 {
+		target.DNS.SearchTemplate = source.DNS.SearchTemplate
+	}
+	if source.DNS.TailnetDomain != "" {
+		target.DNS.TailnetDomain = source.DNS.TailnetDomain
+	}
 	if source.DNS.MagicDNS.Enabled {
 		target.DNS.MagicDNS.Enabled = source.DNS.MagicDNS.Enabled
 	}
@@ -272,9 +471,27 @@ func mergeConfig(target, source *Config) {
 	if source.Monitoring.Path != "" {
 		target.Monitoring.Path = source.Monitoring.Path
 	}
+	if source.Monitoring.TLS.CertFile != "" {
+		target.Monitoring.TLS.CertFile = source.Monitoring.TLS.CertFile
+	}
+	if source.Monitoring.TLS.KeyFile != "" {
+		target.Monitoring.TLS.KeyFile = source.Monitoring.TLS.KeyFile
+	}
+	if source.Monitoring.TLS.ClientCAFile != "" {
+		target.Monitoring.TLS.ClientCAFile = source.Monitoring.TLS.ClientCAFile
+	}
+	if source.Monitoring.Auth.BearerTokenFile != "" {
+		target.Monitoring.Auth.BearerTokenFile = source.Monitoring.Auth.BearerTokenFile
+	}
 
 	// Logging configuration
 	if source.Daemon.LogLevel != "" {
 		target.Daemon.LogLevel = source.Daemon.LogLevel
 	}
+	if source.Daemon.DryRun {
+		target.Daemon.DryRun = source.Daemon.DryRun
+	}
+	if source.Daemon.RemoveAnnotationsOnStop {
+		target.Daemon.RemoveAnnotationsOnStop = source.Daemon.RemoveAnnotationsOnStop
+	}
 }
@@ -0,0 +1,836 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/binrclab/headcni/pkg/constants"
+	"github.com/binrclab/headcni/pkg/ipam"
+)
+
+// 校验问题级别
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// MTU 范围
+// Tailscale 要求 TUN 设备 MTU 不低于 IPv6 最小 MTU (1280)；
+// WireGuard 在 1500 的物理链路上额外占用 80 字节 (IPv6 外层头 40 + UDP 8 + WireGuard 32)
+const (
+	MinTailnetMTU     = 1280
+	MaxWireGuardMTU   = 1420
+	DefaultPathMTU    = 1500
+	wireGuardOverhead = DefaultPathMTU - MaxWireGuardMTU
+)
+
+// MaxHostnamePrefixLength 主机名前缀上限，为 "-" 和随机后缀留出空间，主机名不超过 63 个字符
+const MaxHostnamePrefixLength = 50
+
+// tailnet 地址段，Tailscale 从中为节点分配地址，集群网段不能与之重叠
+const (
+	TailnetIPv4Range = "100.64.0.0/10"
+	TailnetIPv6Range = "fd7a:115c:a1e0::/48"
+)
+
+// MinCheckInterval 周期性检查间隔的下限，避免过短的间隔压垮 Headscale API
+const MinCheckInterval = 5 * time.Second
+
+// MaxStatusCacheTTL tailscale.statusCacheTTL 的建议上限，缓存过久会让健康检查和路由同步看到过期状态
+const MaxStatusCacheTTL = 5 * time.Second
+
+// MaxStartupJitter headscale.startupJitter 的上限，过长的延迟会明显推迟节点加入 tailnet
+const MaxStartupJitter = 5 * time.Minute
+
+// tagPattern ACL tag 格式：tag:<name>，name 仅允许字母、数字和 -
+var tagPattern = regexp.MustCompile(`^tag:[a-zA-Z][a-zA-Z0-9-]*$`)
+
+// namespacePattern Kubernetes 命名空间名称格式（DNS-1123 label）
+var namespacePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// clusterDomainPattern 集群域名格式：由点分隔的小写 DNS 标签，不带首尾的点
+var clusterDomainPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)*$`)
+
+// searchTokenPattern dns.searchTemplate 中的占位符
+var searchTokenPattern = regexp.MustCompile(`\{[^{}]*\}`)
+
+// maxDNSSearchDomains resolv.conf 中 search 列表的域名数上限，与 cni.MaxDNSSearchDomains 一致
+const maxDNSSearchDomains = 6
+
+// ValidationIssue 配置校验问题
+type ValidationIssue struct {
+	Severity string `json:"severity"`
+	File     string `json:"file,omitempty"`
+	Field    string `json:"field"`
+	Message  string `json:"message"`
+}
+
+// String 返回 file:field: message 形式的描述
+func (i ValidationIssue) String() string {
+	location := i.Field
+	if i.File != "" {
+		location = i.File + ": " + i.Field
+	}
+	return fmt.Sprintf("[%s] %s: %s", i.Severity, location, i.Message)
+}
+
+// ValidationResult 配置校验结果
+type ValidationResult struct {
+	Issues []ValidationIssue `json:"issues"`
+}
+
+// addError 记录错误级别问题
+func (r *ValidationResult) addError(file, field, format string, args ...interface{}) {
+	r.Issues = append(r.Issues, ValidationIssue{
+		Severity: SeverityError,
+		File:     file,
+		Field:    field,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// addWarning 记录警告级别问题
+func (r *ValidationResult) addWarning(file, field, format string, args ...interface{}) {
+	r.Issues = append(r.Issues, ValidationIssue{
+		Severity: SeverityWarning,
+		File:     file,
+		Field:    field,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// Merge 合并另一个校验结果
+func (r *ValidationResult) Merge(other *ValidationResult) {
+	if other == nil {
+		return
+	}
+	r.Issues = append(r.Issues, other.Issues...)
+}
+
+// HasErrors 是否存在错误级别问题
+func (r *ValidationResult) HasErrors() bool {
+	for _, issue := range r.Issues {
+		if issue.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Errors 返回所有错误级别问题
+func (r *ValidationResult) Errors() []ValidationIssue {
+	var errs []ValidationIssue
+	for _, issue := range r.Issues {
+		if issue.Severity == SeverityError {
+			errs = append(errs, issue)
+		}
+	}
+	return errs
+}
+
+// Validate 对配置进行语义校验，返回所有发现的问题而不是遇到第一个错误就退出
+// file 仅用于在问题中标注来源文件
+func (c *Config) Validate(file string) *ValidationResult {
+	result := &ValidationResult{}
+
+	c.validateTailscale(file, result)
+	c.validateNetwork(file, result)
+	c.validateHeadscale(file, result)
+	c.validateIPAM(file, result)
+	c.validateDNS(file, result)
+	c.validateMonitoring(file, result)
+	c.validatePreservePlugins(file, result)
+
+	return result
+}
+
+// validateTailscale 校验 Tailscale 配置
+func (c *Config) validateTailscale(file string, result *ValidationResult) {
+	switch c.Tailscale.Mode {
+	case "host", "daemon":
+	case "router":
+		// router 模式只通告额外路由，没有可通告的路由时节点没有作用
+		if len(c.Tailscale.AdvertiseExtraRoutes) == 0 {
+			result.addError(file, "tailscale.advertiseExtraRoutes", "at least one route is required in router mode")
+		}
+	case "":
+		result.addError(file, "tailscale.mode", "mode is required (host, daemon or router)")
+	default:
+		result.addError(file, "tailscale.mode", "unsupported mode %q (must be host, daemon or router)", c.Tailscale.Mode)
+	}
+
+	if c.Tailscale.URL == "" {
+		result.addError(file, "tailscale.url", "control server URL is required")
+	} else if err := validateHTTPURL(c.Tailscale.URL); err != nil {
+		result.addError(file, "tailscale.url", "%v", err)
+	}
+
+	for i, fallbackURL := range c.Tailscale.FallbackURLs {
+		field := fmt.Sprintf("tailscale.fallbackURLs[%d]", i)
+		if err := validateHTTPURL(fallbackURL); err != nil {
+			result.addError(file, field, "%v", err)
+		} else if fallbackURL == c.Tailscale.URL {
+			result.addWarning(file, field, "fallback URL is the same as tailscale.url")
+		}
+	}
+
+	if len(c.Tailscale.Hostname.Prefix) > MaxHostnamePrefixLength {
+		result.addError(file, "tailscale.hostname.prefix", "prefix is %d characters long, at most %d are allowed",
+			len(c.Tailscale.Hostname.Prefix), MaxHostnamePrefixLength)
+	}
+
+	if c.Tailscale.Socket.Path == "" {
+		result.addError(file, "tailscale.socket.path", "socket path is required")
+	}
+
+	// 0 表示未设置，使用 tailscaled 默认的 TUN MTU（见 TailnetMTU）
+	switch {
+	case c.Tailscale.MTU < 0:
+		result.addError(file, "tailscale.mtu", "MTU must not be negative (0 means the default %d)", MinTailnetMTU)
+	case c.Tailscale.MTU == 0:
+	case c.Tailscale.MTU < MinTailnetMTU:
+		result.addError(file, "tailscale.mtu", "MTU %d is below the minimum tailnet MTU %d", c.Tailscale.MTU, MinTailnetMTU)
+	case c.Tailscale.MTU > DefaultPathMTU-wireGuardOverhead:
+		result.addWarning(file, "tailscale.mtu", "MTU %d exceeds %d (path MTU %d minus WireGuard overhead %d), packets may be fragmented or dropped",
+			c.Tailscale.MTU, MaxWireGuardMTU, DefaultPathMTU, wireGuardOverhead)
+	}
+
+	if c.Tailscale.CallTimeout != "" {
+		if timeout, err := time.ParseDuration(c.Tailscale.CallTimeout); err != nil {
+			result.addError(file, "tailscale.callTimeout", "invalid duration %q: %v", c.Tailscale.CallTimeout, err)
+		} else if timeout <= 0 {
+			result.addError(file, "tailscale.callTimeout", "timeout must be greater than 0")
+		}
+	}
+
+	if c.Tailscale.WatchdogTimeout != "" {
+		if timeout, err := time.ParseDuration(c.Tailscale.WatchdogTimeout); err != nil {
+			result.addError(file, "tailscale.watchdogTimeout", "invalid duration %q: %v", c.Tailscale.WatchdogTimeout, err)
+		} else if timeout <= 0 {
+			result.addError(file, "tailscale.watchdogTimeout", "timeout must be greater than 0")
+		}
+	}
+	if c.Tailscale.WatchdogThreshold < 0 {
+		result.addError(file, "tailscale.watchdogThreshold", "threshold must not be negative")
+	}
+	if grace := c.Tailscale.OrphanInterfaceGracePeriod; grace != "" {
+		if d, err := time.ParseDuration(grace); err != nil {
+			result.addError(file, "tailscale.orphanInterfaceGracePeriod", "invalid duration %q: %v", grace, err)
+		} else if d < 0 {
+			result.addError(file, "tailscale.orphanInterfaceGracePeriod", "grace period must not be negative")
+		}
+	}
+
+	if c.Tailscale.StatusCacheTTL != "" {
+		if ttl, err := time.ParseDuration(c.Tailscale.StatusCacheTTL); err != nil {
+			result.addError(file, "tailscale.statusCacheTTL", "invalid duration %q: %v", c.Tailscale.StatusCacheTTL, err)
+		} else if ttl < 0 {
+			result.addError(file, "tailscale.statusCacheTTL", "TTL must not be negative")
+		} else if ttl > MaxStatusCacheTTL {
+			result.addWarning(file, "tailscale.statusCacheTTL", "TTL %v exceeds %v, status checks may act on stale tailscaled state", ttl, MaxStatusCacheTTL)
+		}
+	}
+
+	for _, item := range []struct{ field, value string }{
+		{"tailscale.healthCheckInterval", c.Tailscale.HealthCheckInterval},
+		{"tailscale.keepAliveInterval", c.Tailscale.KeepAliveInterval},
+		{"tailscale.ruleSyncInterval", c.Tailscale.RuleSyncInterval},
+	} {
+		field, value := item.field, item.value
+		if value == "" {
+			continue
+		}
+		if interval, err := time.ParseDuration(value); err != nil {
+			result.addError(file, field, "invalid duration %q: %v", value, err)
+		} else if interval <= 0 {
+			result.addError(file, field, "interval must be greater than 0")
+		} else if interval < MinCheckInterval {
+			result.addWarning(file, field, "interval %v is below the minimum %v, %v will be used", interval, MinCheckInterval, MinCheckInterval)
+		}
+	}
+
+	// Linux 接口名最长 15 个字符，且不能包含空白和 /
+	if name := c.Tailscale.InterfaceName; name != "" {
+		if len(name) > 15 || strings.ContainsAny(name, "/ \t") {
+			result.addError(file, "tailscale.interfaceName", "invalid interface name %q (at most 15 characters, no spaces or '/')", name)
+		} else if !strings.HasPrefix(name, constants.ManagedInterfacePrefix) {
+			result.addWarning(file, "tailscale.interfaceName", "interface %q does not start with %q and will never be removed during cleanup", name, constants.ManagedInterfacePrefix)
+		}
+	}
+
+	if name := c.Tailscale.BindInterface; name != "" {
+		if len(name) > 15 || strings.ContainsAny(name, "/ \t") {
+			result.addError(file, "tailscale.bindInterface", "invalid interface name %q (at most 15 characters, no spaces or '/')", name)
+		} else if c.Tailscale.Mode == "host" {
+			result.addWarning(file, "tailscale.bindInterface", "bindInterface only applies to the daemon-managed tailscaled and is ignored in host mode")
+		}
+	}
+
+	if path := c.Tailscale.ImportStateFrom; path != "" {
+		if !filepath.IsAbs(path) {
+			result.addError(file, "tailscale.importStateFrom", "state file path %q must be absolute", path)
+		} else if filepath.Clean(path) == constants.DefaultTailscaleDaemonStateFile {
+			result.addError(file, "tailscale.importStateFrom", "state file path %q is the managed state file itself", path)
+		} else if c.Tailscale.Mode == "host" {
+			result.addWarning(file, "tailscale.importStateFrom", "importStateFrom only applies to the daemon-managed tailscaled and is ignored in host mode")
+		}
+	}
+
+	for i, prefix := range c.Tailscale.ProtectedInterfacePrefixes {
+		if prefix == "" || len(prefix) > 15 || strings.ContainsAny(prefix, "/ \t") {
+			result.addError(file, fmt.Sprintf("tailscale.protectedInterfacePrefixes[%d]", i), "invalid interface prefix %q (1-15 characters, no spaces or '/')", prefix)
+		}
+	}
+
+	if c.Tailscale.Userspace {
+		if c.Tailscale.Mode == "host" {
+			result.addWarning(file, "tailscale.userspace", "userspace networking only applies to the daemon-managed tailscaled and is ignored in host mode")
+		}
+		if _, _, err := net.SplitHostPort(c.Tailscale.UserspaceProxyAddr); err != nil {
+			result.addError(file, "tailscale.userspaceProxyAddr", "invalid proxy address %q: %v", c.Tailscale.UserspaceProxyAddr, err)
+		}
+	}
+
+	for i, tag := range c.Tailscale.Tags {
+		if !tagPattern.MatchString(tag) {
+			result.addError(file, fmt.Sprintf("tailscale.tags[%d]", i), "invalid tag %q (must look like tag:<name>)", tag)
+		}
+	}
+
+	switch c.Tailscale.RouteConflictPolicy {
+	case "", RouteConflictPolicyWarn, RouteConflictPolicyYield:
+	default:
+		result.addError(file, "tailscale.routeConflictPolicy", "unsupported policy %q (must be %s or %s)",
+			c.Tailscale.RouteConflictPolicy, RouteConflictPolicyWarn, RouteConflictPolicyYield)
+	}
+
+	// 区域是否存在取决于控制服务器下发的 DERP 地图，只能在连接后检查
+	if c.Tailscale.PreferredDERP < 0 {
+		result.addError(file, "tailscale.preferredDERP", "invalid DERP region ID %d (must be 0 for automatic selection or a positive region ID)", c.Tailscale.PreferredDERP)
+	}
+
+	if prefix := c.Tailscale.ManagedTagPrefix; prefix != "" && !strings.HasPrefix(prefix, "tag:") {
+		result.addError(file, "tailscale.managedTagPrefix", "prefix %q must start with tag:", prefix)
+	}
+
+	for i, tag := range c.Tailscale.AcceptRoutesFromTags {
+		if !tagPattern.MatchString(tag) {
+			result.addError(file, fmt.Sprintf("tailscale.acceptRoutesFromTags[%d]", i), "invalid tag %q (must look like tag:<name>)", tag)
+		}
+	}
+}
+
+// validateNetwork 校验网络配置
+// podCIDR.base 和 serviceCIDR 支持以逗号分隔的双栈写法
+func (c *Config) validateNetwork(file string, result *ValidationResult) {
+	var podNets []*net.IPNet
+
+	if c.Network.PodCIDR.Base == "" {
+		result.addError(file, "network.podCIDR.base", "pod CIDR is required")
+	} else {
+		podNets = parseCIDRList(file, "network.podCIDR.base", c.Network.PodCIDR.Base, result)
+	}
+
+	if perNode := c.Network.PodCIDR.PerNode; perNode != "" {
+		size, err := strconv.Atoi(strings.TrimPrefix(perNode, "/"))
+		if err != nil || size <= 0 || size > 128 {
+			result.addError(file, "network.podCIDR.perNode", "invalid prefix length %q (expected e.g. /24)", perNode)
+		} else if len(podNets) > 0 && podNets[0].IP.To4() != nil {
+			// perNode 只针对 IPv4 Pod CIDR
+			ones, bits := podNets[0].Mask.Size()
+			if size > bits {
+				result.addError(file, "network.podCIDR.perNode", "prefix length /%d is too long for %s", size, podNets[0])
+			} else if size < ones {
+				result.addError(file, "network.podCIDR.perNode", "per-node prefix /%d is larger than pod CIDR %s", size, podNets[0])
+			}
+		}
+	}
+
+	if fallback := c.Network.PodCIDR.Fallback; fallback != "" {
+		_, fallbackNet, err := net.ParseCIDR(strings.TrimSpace(fallback))
+		if err != nil {
+			result.addError(file, "network.podCIDR.fallback", "invalid CIDR %q: %v", fallback, err)
+		} else if len(podNets) > 0 && !cidrWithin(fallbackNet, podNets) {
+			result.addError(file, "network.podCIDR.fallback", "%s is outside pod CIDR %s", fallback, c.Network.PodCIDR.Base)
+		} else {
+			// 回退网段由所有节点共用，daemon 从中为每个节点派生 perNode 大小的切片
+			perNode := 24
+			if size, err := strconv.Atoi(strings.TrimPrefix(c.Network.PodCIDR.PerNode, "/")); err == nil {
+				perNode = size
+			}
+			if ones, _ := fallbackNet.Mask.Size(); fallbackNet.IP.To4() == nil || ones >= perNode {
+				result.addError(file, "network.podCIDR.fallback", "%s is shared by all nodes and must be an IPv4 CIDR larger than the per-node prefix /%d", fallback, perNode)
+			}
+		}
+	}
+
+	if c.Network.ServiceCIDR == "" {
+		result.addError(file, "network.serviceCIDR", "service CIDR is required")
+	} else {
+		parseCIDRList(file, "network.serviceCIDR", c.Network.ServiceCIDR, result)
+	}
+
+	for i, route := range c.Tailscale.AdvertiseExtraRoutes {
+		if _, _, err := net.ParseCIDR(strings.TrimSpace(route)); err != nil {
+			result.addError(file, fmt.Sprintf("tailscale.advertiseExtraRoutes[%d]", i), "invalid CIDR %q: %v", route, err)
+		}
+	}
+	for i, route := range c.Network.PodRoutes {
+		field := fmt.Sprintf("network.podRoutes[%d]", i)
+		_, ipNet, err := net.ParseCIDR(strings.TrimSpace(route))
+		if err != nil {
+			result.addError(file, field, "invalid CIDR %q: %v", route, err)
+		} else if ones, _ := ipNet.Mask.Size(); ones == 0 {
+			result.addWarning(file, field, "%s duplicates the pod default route and is ignored", route)
+		}
+	}
+	result.Merge(c.ValidateCIDROverlaps(file))
+
+	// 0 表示自动：按 tailscale 接口 MTU 计算
+	if c.Network.MTU < 0 {
+		result.addError(file, "network.mtu", "MTU must not be negative (0 means auto)")
+	} else if c.Network.MTU > 0 && c.Network.MTU < MinTailnetMTU {
+		result.addWarning(file, "network.mtu", "pod MTU %d is below the minimum tailnet MTU %d", c.Network.MTU, MinTailnetMTU)
+	} else if c.Network.MTU > c.TailnetMTU() {
+		// Pod 流量经由 tailnet 转发，Pod MTU 不能超过 tailnet MTU
+		result.addError(file, "network.mtu", "pod MTU %d exceeds tailnet MTU %d (tailscale.mtu)", c.Network.MTU, c.TailnetMTU())
+	}
+
+	if !c.Network.EnableNetworkPolicy {
+		return
+	}
+
+	policy := c.Network.Policy
+	for i, tag := range policy.AllowedTags {
+		if !tagPattern.MatchString(tag) {
+			result.addError(file, fmt.Sprintf("network.policy.allowedTags[%d]", i), "invalid tag %q (must look like tag:<name>)", tag)
+		}
+	}
+	if policy.ReconcileInterval != "" {
+		if interval, err := time.ParseDuration(policy.ReconcileInterval); err != nil {
+			result.addError(file, "network.policy.reconcileInterval", "invalid duration %q: %v", policy.ReconcileInterval, err)
+		} else if interval <= 0 {
+			result.addError(file, "network.policy.reconcileInterval", "interval must be greater than 0")
+		}
+	}
+	if policy.Enforce && !policy.HostAccessAllowed() && !policy.ServiceAccessAllowed() && !policy.EgressAllowed {
+		result.addWarning(file, "network.policy", "pods can only reach pods in their own namespace, cluster DNS via service IP is blocked")
+	}
+}
+
+// parseCIDRList 解析逗号分隔的 CIDR 列表，无法解析的条目记录为错误
+func parseCIDRList(file, field, value string, result *ValidationResult) []*net.IPNet {
+	var nets []*net.IPNet
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		_, ipNet, err := net.ParseCIDR(part)
+		if err != nil {
+			result.addError(file, field, "invalid CIDR %q: %v", part, err)
+			continue
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets
+}
+
+// ValidateCIDROverlaps 校验 Pod CIDR、Service CIDR 和额外通告的路由互不重叠，且不与 tailnet 地址段重叠
+// 重叠时路由存在歧义，跨节点流量会静默失败；daemon 启动时也会执行该校验
+// 无法解析的网段由 Validate 报告，这里跳过
+func (c *Config) ValidateCIDROverlaps(file string) *ValidationResult {
+	result := &ValidationResult{}
+
+	podNets := parseValidCIDRs(strings.Split(c.Network.PodCIDR.Base, ","))
+	serviceNets := parseValidCIDRs(strings.Split(c.Network.ServiceCIDR, ","))
+
+	tailnetNets := parseValidCIDRs([]string{TailnetIPv4Range, TailnetIPv6Range})
+	checkTailnet := func(field, kind string, nets []*net.IPNet) {
+		for _, ipNet := range nets {
+			for _, tailnetNet := range tailnetNets {
+				if CIDROverlap(ipNet, tailnetNet) {
+					result.addError(file, field, "%s %s overlaps tailnet range %s", kind, ipNet, tailnetNet)
+				}
+			}
+		}
+	}
+	checkTailnet("network.podCIDR.base", "pod CIDR", podNets)
+	checkTailnet("network.serviceCIDR", "service CIDR", serviceNets)
+
+	for _, podNet := range podNets {
+		for _, serviceNet := range serviceNets {
+			if CIDROverlap(podNet, serviceNet) {
+				result.addError(file, "network.serviceCIDR", "service CIDR %s overlaps pod CIDR %s", serviceNet, podNet)
+			}
+		}
+	}
+
+	// 额外通告的路由与集群网段重叠会劫持 Pod 或 Service 流量
+	var routeNets []*net.IPNet
+	for i, route := range c.Tailscale.AdvertiseExtraRoutes {
+		field := fmt.Sprintf("tailscale.advertiseExtraRoutes[%d]", i)
+		_, routeNet, err := net.ParseCIDR(strings.TrimSpace(route))
+		if err != nil {
+			continue
+		}
+
+		checkTailnet(field, "route", []*net.IPNet{routeNet})
+		for _, clusterNet := range append(append([]*net.IPNet{}, podNets...), serviceNets...) {
+			if CIDROverlap(routeNet, clusterNet) {
+				result.addError(file, field, "route %s overlaps cluster CIDR %s", routeNet, clusterNet)
+			}
+		}
+		for _, other := range routeNets {
+			if CIDROverlap(routeNet, other) {
+				result.addError(file, field, "route %s overlaps route %s", routeNet, other)
+			}
+		}
+		routeNets = append(routeNets, routeNet)
+	}
+
+	return result
+}
+
+// ValidateHostSubnetOverlaps 校验 Pod CIDR 和 Service CIDR 不与节点主网卡的子网重叠，常见于误用机房网段
+// hostNets 由调用方在节点上获取；额外通告的路由允许包含节点网段（子网路由器）
+func (c *Config) ValidateHostSubnetOverlaps(file string, hostNets []*net.IPNet) *ValidationResult {
+	result := &ValidationResult{}
+
+	clusterCIDRs := []struct {
+		field, kind, value string
+	}{
+		{"network.podCIDR.base", "pod CIDR", c.Network.PodCIDR.Base},
+		{"network.serviceCIDR", "service CIDR", c.Network.ServiceCIDR},
+	}
+	for _, cidr := range clusterCIDRs {
+		for _, ipNet := range parseValidCIDRs(strings.Split(cidr.value, ",")) {
+			for _, hostNet := range hostNets {
+				if CIDROverlap(ipNet, hostNet) {
+					result.addError(file, cidr.field, "%s %s overlaps node subnet %s", cidr.kind, ipNet, hostNet)
+				}
+			}
+		}
+	}
+
+	return result
+}
+
+// parseValidCIDRs 解析 CIDR 列表，忽略空白和无法解析的条目
+func parseValidCIDRs(values []string) []*net.IPNet {
+	var nets []*net.IPNet
+	for _, value := range values {
+		if _, ipNet, err := net.ParseCIDR(strings.TrimSpace(value)); err == nil {
+			nets = append(nets, ipNet)
+		}
+	}
+	return nets
+}
+
+// containsIP 判断 ip 是否落在任一网段内
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, ipNet := range nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// cidrWithin 判断 inner 是否完整落在任一网段内
+func cidrWithin(inner *net.IPNet, nets []*net.IPNet) bool {
+	innerOnes, innerBits := inner.Mask.Size()
+	for _, ipNet := range nets {
+		ones, bits := ipNet.Mask.Size()
+		if bits == innerBits && ones <= innerOnes && ipNet.Contains(inner.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateHeadscale 校验 Headscale 配置
+func (c *Config) validateHeadscale(file string, result *ValidationResult) {
+	if c.Headscale.URL == "" {
+		result.addError(file, "headscale.url", "headscale URL is required")
+	} else if err := validateHTTPURL(c.Headscale.URL); err != nil {
+		result.addError(file, "headscale.url", "%v", err)
+	}
+
+	if ref := c.Headscale.AuthKeySecretRef; ref.IsSet() {
+		if ref.Key == "" {
+			result.addError(file, "headscale.authKeySecretRef.key", "secret key is required when authKeySecretRef.name is set")
+		}
+		if c.Headscale.AuthKey != "" {
+			result.addWarning(file, "headscale.authKey", "API key is ignored, secret %s is used instead", ref.Name)
+		}
+	} else {
+		if ref.Namespace != "" || ref.Key != "" {
+			result.addError(file, "headscale.authKeySecretRef.name", "secret name is required when namespace or key is set")
+		}
+		if c.Headscale.AuthKey == "" {
+			result.addWarning(file, "headscale.authKey", "API key is empty, expected to be provided via HEADSCALE_AUTH_KEY or authKeySecretRef")
+		}
+	}
+
+	if c.Headscale.Timeout != "" {
+		if _, err := time.ParseDuration(c.Headscale.Timeout); err != nil {
+			result.addError(file, "headscale.timeout", "invalid duration %q: %v", c.Headscale.Timeout, err)
+		}
+	}
+
+	if jitter := c.Headscale.StartupJitter; jitter != "" {
+		if d, err := time.ParseDuration(jitter); err != nil {
+			result.addError(file, "headscale.startupJitter", "invalid duration %q: %v", jitter, err)
+		} else if d < 0 {
+			result.addError(file, "headscale.startupJitter", "startup jitter must not be negative")
+		} else if d > MaxStartupJitter {
+			result.addError(file, "headscale.startupJitter", "startup jitter %v exceeds the maximum %v", d, MaxStartupJitter)
+		}
+	}
+
+	if c.Headscale.Retries < 0 {
+		result.addError(file, "headscale.retries", "retries must not be negative")
+	}
+
+	if c.Headscale.CircuitBreaker.FailureThreshold < 0 {
+		result.addError(file, "headscale.circuitBreaker.failureThreshold", "failure threshold must not be negative")
+	}
+	if cooldown := c.Headscale.CircuitBreaker.Cooldown; cooldown != "" {
+		if d, err := time.ParseDuration(cooldown); err != nil {
+			result.addError(file, "headscale.circuitBreaker.cooldown", "invalid duration %q: %v", cooldown, err)
+		} else if d <= 0 {
+			result.addError(file, "headscale.circuitBreaker.cooldown", "cooldown must be greater than 0")
+		}
+	}
+
+	if tag := c.Headscale.AutoApproverTag; tag != "" {
+		if !tagPattern.MatchString(tag) {
+			result.addError(file, "headscale.autoApproverTag", "invalid tag %q (must look like tag:<name>)", tag)
+		}
+		if c.Headscale.AutoApproveRoutes != nil && *c.Headscale.AutoApproveRoutes {
+			result.addWarning(file, "headscale.autoApproveRoutes", "ignored when headscale.autoApproverTag is set, routes are approved by the Headscale autoApprovers policy")
+		}
+	}
+
+	if interval := c.Headscale.Housekeeping.Interval; c.Headscale.Housekeeping.Enabled && interval != "" {
+		if d, err := time.ParseDuration(interval); err != nil {
+			result.addError(file, "headscale.housekeeping.interval", "invalid duration %q: %v", interval, err)
+		} else if d <= 0 {
+			result.addError(file, "headscale.housekeeping.interval", "interval must be greater than 0")
+		}
+	}
+}
+
+// validateIPAM 校验 IPAM 配置
+func (c *Config) validateIPAM(file string, result *ValidationResult) {
+	switch c.IPAM.Type {
+	case "":
+		result.addError(file, "ipam.type", "IPAM type is required")
+	case ipam.TypeHostLocal, ipam.TypeKubeBacked:
+	case ipam.TypeHostLocalDelegate:
+		// 上游 host-local 按自身逻辑顺序分配，不支持排除地址
+		if len(c.IPAM.Reserved) > 0 {
+			result.addWarning(file, "ipam.reserved", "reserved addresses are not honored by %s", ipam.TypeHostLocalDelegate)
+		}
+	default:
+		result.addError(file, "ipam.type", "unsupported IPAM type %q, expected %s, %s or %s", c.IPAM.Type, ipam.TypeHostLocal, ipam.TypeKubeBacked, ipam.TypeHostLocalDelegate)
+	}
+
+	if c.IPAM.Strategy == "" {
+		result.addError(file, "ipam.strategy", "IPAM strategy is required")
+	}
+
+	if c.IPAM.GCInterval != "" {
+		if _, err := time.ParseDuration(c.IPAM.GCInterval); err != nil {
+			result.addError(file, "ipam.gcInterval", "invalid duration %q: %v", c.IPAM.GCInterval, err)
+		}
+	}
+
+	// 保留地址在节点子网确定前只能对照集群 Pod CIDR 校验
+	var podNets []*net.IPNet
+	for _, part := range strings.Split(c.Network.PodCIDR.Base, ",") {
+		if _, ipNet, err := net.ParseCIDR(strings.TrimSpace(part)); err == nil {
+			podNets = append(podNets, ipNet)
+		}
+	}
+	for i, entry := range c.IPAM.Reserved {
+		field := fmt.Sprintf("ipam.reserved[%d]", i)
+		if len(podNets) == 0 {
+			break
+		}
+		var lastErr error
+		for _, podNet := range podNets {
+			if _, lastErr = ipam.ParseReservedRanges(podNet, []string{entry}); lastErr == nil {
+				break
+			}
+		}
+		if lastErr != nil {
+			result.addError(file, field, "%q is invalid or outside pod CIDR %s", entry, c.Network.PodCIDR.Base)
+		}
+	}
+
+	for i, subnet := range c.IPAM.Subnets {
+		field := fmt.Sprintf("ipam.subnets[%d]", i)
+		_, ipNet, err := net.ParseCIDR(subnet.Subnet)
+		if err != nil {
+			result.addError(file, field+".subnet", "invalid CIDR %q: %v", subnet.Subnet, err)
+			continue
+		}
+		if subnet.Gateway != "" {
+			gw := net.ParseIP(subnet.Gateway)
+			if gw == nil {
+				result.addError(file, field+".gateway", "invalid IP %q", subnet.Gateway)
+			} else if !ipNet.Contains(gw) {
+				result.addError(file, field+".gateway", "gateway %s is outside subnet %s", gw, ipNet)
+			}
+		}
+	}
+}
+
+// validateDNS 校验集群 DNS 覆盖配置
+func (c *Config) validateDNS(file string, result *ValidationResult) {
+	if c.DNS.ServiceIP != "" {
+		ip := net.ParseIP(c.DNS.ServiceIP)
+		if ip == nil {
+			result.addError(file, "dns.serviceIP", "invalid IP address %q", c.DNS.ServiceIP)
+		} else if serviceNets := parseValidCIDRs(strings.Split(c.Network.ServiceCIDR, ",")); len(serviceNets) > 0 && !containsIP(serviceNets, ip) {
+			result.addWarning(file, "dns.serviceIP", "%s is outside service CIDR %s", c.DNS.ServiceIP, c.Network.ServiceCIDR)
+		}
+	}
+
+	if c.DNS.ClusterDomain != "" && !clusterDomainPattern.MatchString(c.DNS.ClusterDomain) {
+		result.addError(file, "dns.clusterDomain", "invalid cluster domain %q (must look like cluster.local, without leading or trailing dots)", c.DNS.ClusterDomain)
+	}
+
+	for i, namespace := range c.DNS.ServiceNamespaces {
+		if !namespacePattern.MatchString(namespace) {
+			result.addError(file, fmt.Sprintf("dns.serviceNamespaces[%d]", i), "invalid namespace %q", namespace)
+		}
+	}
+	if c.DNS.ServiceIP != "" && len(c.DNS.ServiceNamespaces) > 0 {
+		result.addWarning(file, "dns.serviceNamespaces", "ignored because dns.serviceIP is set")
+	}
+
+	if c.DNS.TailnetDomain != "" && !clusterDomainPattern.MatchString(c.DNS.TailnetDomain) {
+		result.addError(file, "dns.tailnetDomain", "invalid tailnet domain %q (must look like tailnet.example.com, without leading or trailing dots)", c.DNS.TailnetDomain)
+	}
+	c.validateSearchTemplate(file, result)
+}
+
+// validateSearchTemplate 校验 Pod 搜索域模板：只能使用已知的占位符，用示例值展开后必须是合法域名
+func (c *Config) validateSearchTemplate(file string, result *ValidationResult) {
+	if len(c.DNS.SearchTemplate) == 0 {
+		return
+	}
+	if !c.DNS.MagicDNS.Enabled {
+		result.addWarning(file, "dns.searchTemplate", "ignored because dns.magicDNS.enabled is false")
+	}
+	if len(c.DNS.SearchTemplate) > maxDNSSearchDomains {
+		result.addWarning(file, "dns.searchTemplate", "%d entries exceed the resolv.conf limit of %d, later entries will be dropped", len(c.DNS.SearchTemplate), maxDNSSearchDomains)
+	}
+
+	clusterDomain := c.DNS.ClusterDomain
+	if clusterDomain == "" {
+		clusterDomain = "cluster.local"
+	}
+	tailnetDomain := c.DNS.TailnetDomain
+	if tailnetDomain == "" {
+		tailnetDomain = "tailnet.example.com"
+	}
+	replacer := strings.NewReplacer("{cluster_domain}", clusterDomain, "{namespace}", "default", "{tailnet_domain}", tailnetDomain)
+
+	for i, entry := range c.DNS.SearchTemplate {
+		field := fmt.Sprintf("dns.searchTemplate[%d]", i)
+		if token := unknownSearchToken(entry); token != "" {
+			result.addError(file, field, "unknown token %s in %q (supported: {cluster_domain}, {namespace}, {tailnet_domain})", token, entry)
+			continue
+		}
+		if strings.Contains(entry, "{tailnet_domain}") && c.DNS.TailnetDomain == "" {
+			result.addError(file, field, "%q uses {tailnet_domain} but dns.tailnetDomain is not set", entry)
+			continue
+		}
+		if domain := replacer.Replace(entry); !clusterDomainPattern.MatchString(domain) {
+			result.addError(file, field, "%q expands to invalid domain %q", entry, domain)
+		}
+	}
+}
+
+// unknownSearchToken 返回模板中第一个不支持的占位符
+func unknownSearchToken(entry string) string {
+	for _, token := range searchTokenPattern.FindAllString(entry, -1) {
+		switch token {
+		case "{cluster_domain}", "{namespace}", "{tailnet_domain}":
+		default:
+			return token
+		}
+	}
+	return ""
+}
+
+// validatePreservePlugins 校验保留的外部 CNI，每项是配置目录中的文件名或插件类型
+func (c *Config) validatePreservePlugins(file string, result *ValidationResult) {
+	for _, name := range c.PreservePlugins {
+		switch {
+		case strings.TrimSpace(name) == "":
+			result.addError(file, "preservePlugins", "entries must not be empty")
+		case strings.ContainsRune(name, '/'):
+			result.addError(file, "preservePlugins", "%q must be a file name in the CNI config directory or a plugin type, not a path", name)
+		case name == "headcni" || name == constants.DefaultHeadCNIConfigFile:
+			result.addError(file, "preservePlugins", "%q is headcni itself", name)
+		}
+	}
+	if len(c.PreservePlugins) > 0 && c.IsRouterMode() {
+		result.addWarning(file, "preservePlugins", "router mode does not generate a CNI config, preservePlugins has no effect")
+	}
+}
+
+// validateMonitoring 校验监控配置
+func (c *Config) validateMonitoring(file string, result *ValidationResult) {
+	if !c.Monitoring.Enabled {
+		return
+	}
+
+	if c.Monitoring.Port <= 0 || c.Monitoring.Port > 65535 {
+		result.addError(file, "monitoring.port", "invalid port %d", c.Monitoring.Port)
+	}
+
+	// 与 daemon 相同，缺少前导 / 的路径自动补上；/health 由健康检查占用
+	if path := c.MetricsPath(); path == "/health" {
+		result.addError(file, "monitoring.path", "path %q conflicts with the health endpoint", c.Monitoring.Path)
+	}
+
+	tlsConfig := c.Monitoring.TLS
+	if (tlsConfig.CertFile == "") != (tlsConfig.KeyFile == "") {
+		result.addError(file, "monitoring.tls", "certFile and keyFile must be set together")
+	}
+	if tlsConfig.ClientCAFile != "" && tlsConfig.CertFile == "" {
+		result.addError(file, "monitoring.tls.clientCAFile", "client certificate verification requires certFile and keyFile")
+	}
+	if c.Monitoring.Auth.BearerTokenFile != "" && tlsConfig.CertFile == "" {
+		result.addWarning(file, "monitoring.auth.bearerTokenFile", "bearer token is sent over plain HTTP, configure monitoring.tls")
+	}
+}
+
+// validateHTTPURL 校验 http/https URL
+func validateHTTPURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid URL %q: %v", raw, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid URL %q: scheme must be http or https", raw)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("invalid URL %q: host is empty", raw)
+	}
+	return nil
+}
+
+// CIDROverlap 判断两个网段是否重叠
+func CIDROverlap(a, b *net.IPNet) bool {
+	return a.Contains(b.IP) || b.Contains(a.IP)
+}
@@ -0,0 +1,142 @@
+package tailscale
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/binrclab/headcni/pkg/logging"
+	"github.com/vishvananda/netlink"
+	"tailscale.com/util/linuxfw"
+)
+
+const (
+	// BindRouteTable 绑定出口接口时 tailscaled 自身流量（WireGuard、DERP、控制连接）使用的路由表
+	BindRouteTable = 5270
+	// BindRulePriority 绑定规则的优先级，需先于 tailscaled 自己的 fwmark 规则（5210，查 main 表）匹配
+	BindRulePriority = 5200
+)
+
+// tailscaled 在 Linux 上为自己的套接字设置 SO_MARK=0x80000（linuxfw.TailscaleBypassMarkNum），
+// 没有绑定源地址或出口接口的参数。绑定出口接口的方式是：按该标记把 tailscaled 的流量导入 BindRouteTable，
+// 表中只有经指定接口的默认路由和直连网段路由，源地址固定为接口地址
+
+// resolveBindInterface 检查绑定接口存在、已启用并且有 IPv4 地址，返回接口和所用的地址
+func resolveBindInterface(name string) (netlink.Link, *netlink.Addr, error) {
+	link, err := netlink.LinkByName(name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("bind interface %s not found: %v", name, err)
+	}
+	if link.Attrs().Flags&net.FlagUp == 0 {
+		return nil, nil, fmt.Errorf("bind interface %s is down", name)
+	}
+
+	addrs, err := netlink.AddrList(link, netlink.FAMILY_V4)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list addresses of %s: %v", name, err)
+	}
+	for i := range addrs {
+		if addrs[i].IP.IsGlobalUnicast() {
+			return link, &addrs[i], nil
+		}
+	}
+	return nil, nil, fmt.Errorf("bind interface %s has no IPv4 address", name)
+}
+
+// bindRoutes 生成 BindRouteTable 中的路由：经接口的默认路由（main 表中该接口有默认网关时使用该网关）和直连网段路由
+func bindRoutes(link netlink.Link, addr *netlink.Addr) ([]netlink.Route, error) {
+	index := link.Attrs().Index
+	subnet := &net.IPNet{IP: addr.IP.Mask(addr.Mask), Mask: addr.Mask}
+	routes := []netlink.Route{{
+		LinkIndex: index,
+		Dst:       subnet,
+		Src:       addr.IP,
+		Scope:     netlink.SCOPE_LINK,
+		Table:     BindRouteTable,
+	}}
+
+	defaultRoute := netlink.Route{
+		LinkIndex: index,
+		Dst:       &net.IPNet{IP: net.IPv4zero, Mask: net.CIDRMask(0, 32)},
+		Src:       addr.IP,
+		Scope:     netlink.SCOPE_LINK,
+		Table:     BindRouteTable,
+	}
+	mainRoutes, err := netlink.RouteListFiltered(netlink.FAMILY_V4, &netlink.Route{LinkIndex: index}, netlink.RT_FILTER_OIF)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list routes of %s: %v", link.Attrs().Name, err)
+	}
+	for _, route := range mainRoutes {
+		if route.Gw != nil && (route.Dst == nil || route.Dst.IP.IsUnspecified()) {
+			defaultRoute.Gw = route.Gw
+			defaultRoute.Scope = netlink.SCOPE_UNIVERSE
+			break
+		}
+	}
+	return append(routes, defaultRoute), nil
+}
+
+// setupBindInterface 校验 Options.BindInterface 并让 tailscaled 的流量经该接口发出，需在启动 tailscaled 之前调用
+func (s *Service) setupBindInterface() error {
+	name := s.Options.BindInterface
+	link, addr, err := resolveBindInterface(name)
+	if err != nil {
+		return err
+	}
+	routes, err := bindRoutes(link, addr)
+	if err != nil {
+		return err
+	}
+
+	for i := range routes {
+		if err := netlink.RouteReplace(&routes[i]); err != nil {
+			return fmt.Errorf("failed to add route %s to table %d: %v", routes[i].Dst, BindRouteTable, err)
+		}
+	}
+
+	// 先删除上次留下的规则，重复启动时不会累积
+	deleteBindRules()
+	rule := netlink.NewRule()
+	rule.Mark = linuxfw.TailscaleBypassMarkNum
+	mask := uint32(linuxfw.TailscaleFwmarkMaskNum)
+	rule.Mask = &mask
+	rule.Table = BindRouteTable
+	rule.Priority = BindRulePriority
+	if err := netlink.RuleAdd(rule); err != nil {
+		return fmt.Errorf("failed to add bind rule: %v", err)
+	}
+
+	logging.Infof("tailscaled traffic bound to %s (source %s, table %d)", name, addr.IP, BindRouteTable)
+	return nil
+}
+
+// cleanupBindInterface 删除绑定规则和 BindRouteTable 中的路由
+func (s *Service) cleanupBindInterface() {
+	deleteBindRules()
+
+	routes, err := netlink.RouteListFiltered(netlink.FAMILY_V4, &netlink.Route{Table: BindRouteTable}, netlink.RT_FILTER_TABLE)
+	if err != nil {
+		logging.Warnf("Failed to list routes in table %d: %v", BindRouteTable, err)
+		return
+	}
+	for i := range routes {
+		if err := netlink.RouteDel(&routes[i]); err != nil {
+			logging.Warnf("Failed to delete route %s from table %d: %v", routes[i].Dst, BindRouteTable, err)
+		}
+	}
+}
+
+// deleteBindRules 删除所有指向 BindRouteTable 的绑定规则
+func deleteBindRules() {
+	rules, err := netlink.RuleList(netlink.FAMILY_V4)
+	if err != nil {
+		logging.Warnf("Failed to list rules: %v", err)
+		return
+	}
+	for i := range rules {
+		if rules[i].Priority == BindRulePriority && rules[i].Table == BindRouteTable {
+			if err := netlink.RuleDel(&rules[i]); err != nil {
+				logging.Warnf("Failed to delete bind rule: %v", err)
+			}
+		}
+	}
+}
@@ -4,17 +4,19 @@ package tailscale
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
 	"net"
 	"net/http"
 	"net/netip"
 	"net/url"
 	"os"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/binrclab/headcni/pkg/constants"
+	"github.com/binrclab/headcni/pkg/logging"
 	"tailscale.com/client/local"
 	"tailscale.com/ipn"
 	"tailscale.com/ipn/ipnstate"
@@ -27,12 +29,29 @@ type ClientOptions struct {
 	AcceptDNS       bool     // Whether to accept DNS from other nodes
 	Hostname        string   // Hostname for this node
 	ControlURL      string   // Control server URL
+	ControlURLs     []string // Ordered fallback control server URLs, tried after ControlURL
 	AdvertiseRoutes []string // Routes to advertise
 	AcceptRoutes    bool     // Whether to accept routes from other nodes
 	ShieldsUp       bool     // Whether to enable Shields Up mode
 	Ephemeral       bool     // Whether this is an ephemeral node
+	PreferredDERP   int      // DERP home region to pin after login, 0 for automatic selection
+
+	// UpWithOptionsWithRetry only
+	MaxAttempts int           // Connection attempts, 0 for DefaultConnectAttempts
+	RetryWait   time.Duration // Wait before the first retry, doubled after each further failure up to MaxConnectRetryWait; 0 for DefaultConnectRetryWait
 }
 
+const (
+	// DefaultConnectAttempts is the number of attempts UpWithOptionsWithRetry makes when MaxAttempts is unset
+	DefaultConnectAttempts = 2
+	// DefaultConnectRetryWait is the wait before the first retry when RetryWait is unset
+	DefaultConnectRetryWait = 15 * time.Second
+	// MaxConnectRetryWait caps the backoff between attempts
+	MaxConnectRetryWait = 2 * time.Minute
+)
+
+var _ TailscaleClient = (*SimpleClient)(nil)
+
 // SimpleClient is a unified Tailscale client that focuses on socket communication
 // with tailscaled daemon for managing Tailscale connections.
 type SimpleClient struct {
@@ -41,6 +60,24 @@ type SimpleClient struct {
 	socketPath  string
 	mu          sync.RWMutex
 	timeout     time.Duration
+
+	// 状态变化事件
+	eventsMu    sync.Mutex
+	lastState   string
+	subscribers []chan<- StateEvent
+
+	// Control server failover state
+	controlMu        sync.Mutex
+	pinnedControlURL string
+	controlFailures  int
+
+	// Status cache, disabled unless SetStatusCacheTTL is called (see statuscache.go)
+	statusMu         sync.Mutex
+	statusTTL        time.Duration
+	cachedStatus     *ipnstate.Status
+	cachedAt         time.Time
+	statusCall       *statusCall
+	statusGeneration uint64
 }
 
 // =============================================================================
@@ -181,24 +218,71 @@ func (c *SimpleClient) createRoutePrefs(routes []netip.Prefix, routeAll *bool, h
 
 // waitForStateChange waits for state change to target state with timeout
 func (c *SimpleClient) waitForStateChange(ctx context.Context, targetState string, maxWait int) error {
+	ctx = withStateReason(ctx, "wait for "+targetState)
 	for i := 0; i < maxWait; i++ {
-		time.Sleep(1 * time.Second)
-		if status, err := c.GetStatus(ctx); err == nil && status.BackendState == targetState {
+		if err := sleepContext(ctx, 1*time.Second); err != nil {
+			return err
+		}
+		if status, err := c.GetStatusFresh(ctx); err == nil && status.BackendState == targetState {
 			return nil
 		}
 	}
 	return fmt.Errorf("timeout waiting for state change to %s", targetState)
 }
 
+// sleepContext waits for d or until ctx is done, returning ctx.Err() on cancellation
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
 // =============================================================================
 // Status and Connection Management Methods
 // =============================================================================
 
 // GetStatus retrieves the current Tailscale status
+// When the status cache is enabled a recent status may be returned; it is shared and must not be modified
+// Backend state changes observed here are published to subscribers (see Subscribe)
 func (c *SimpleClient) GetStatus(ctx context.Context) (*ipnstate.Status, error) {
-	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	if c.cachedStatusEnabled() {
+		return c.getStatusCached(ctx)
+	}
+	return c.fetchStatus(ctx)
+}
+
+// GetStatusFresh retrieves the current Tailscale status from tailscaled, bypassing the status cache
+// Use it in loops that wait for a state change and need real-time data
+func (c *SimpleClient) GetStatusFresh(ctx context.Context) (*ipnstate.Status, error) {
+	c.statusMu.Lock()
+	generation := c.statusGeneration
+	c.statusMu.Unlock()
+
+	status, err := c.fetchStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.storeStatus(status, generation)
+	return status, nil
+}
+
+// fetchStatus queries tailscaled for the current status
+func (c *SimpleClient) fetchStatus(ctx context.Context) (*ipnstate.Status, error) {
+	statusCtx, cancel := context.WithTimeout(ctx, c.timeout)
 	defer cancel()
-	return c.localClient.Status(ctx)
+
+	status, err := c.localClient.Status(statusCtx)
+	if err != nil {
+		return nil, err
+	}
+	c.observeState(ctx, status.BackendState)
+	return status, nil
 }
 
 // CheckSocketExists checks if the socket is accessible
@@ -212,52 +296,78 @@ func (c *SimpleClient) CheckSocketExists() error {
 
 // Down disconnects the Tailscale connection
 func (c *SimpleClient) Down(ctx context.Context) error {
-	log.Println("Disconnecting Tailscale...")
+	defer c.invalidateStatus()
 
-	status, err := c.GetStatus(ctx)
+	logging.Infof("Disconnecting Tailscale...")
+
+	status, err := c.GetStatusFresh(ctx)
 	if err != nil {
-		log.Printf("Failed to get status: %v", err)
+		logging.Errorf("Failed to get status: %v", err)
 	} else if status.BackendState == "Stopped" {
-		log.Println("Connection already stopped")
+		logging.Debugf("Connection already stopped")
 		return nil
 	}
 
 	maskedPrefs := c.createWantRunningPrefs(false)
-	_, err = c.localClient.EditPrefs(ctx, maskedPrefs)
+	_, err = c.editPrefs(ctx, "down", maskedPrefs)
 	if err != nil {
 		return fmt.Errorf("failed to stop connection: %v", err)
 	}
 
 	// Wait for connection to stop
 	if err := c.waitForStateChange(ctx, "Stopped", 10); err == nil {
-		log.Println("Connection successfully stopped")
+		logging.Infof("Connection successfully stopped")
 		return nil
 	}
 
-	log.Println("Stop command sent")
+	logging.Debugf("Stop command sent")
 	return nil
 }
 
 // UpWithOptionsWithRetry attempts to connect with retry mechanism
+// It makes options.MaxAttempts attempts, waiting options.RetryWait before the first retry and
+// doubling the wait after each further failure. Invalid options (ErrInvalidOptions) fail
+// immediately since retrying cannot fix them.
 func (c *SimpleClient) UpWithOptionsWithRetry(ctx context.Context, options ClientOptions) error {
-	maxRetries := 2
+	return retryUp(ctx, options, c.UpWithOptions)
+}
+
+// retryUp runs up with the retry policy of UpWithOptionsWithRetry
+func retryUp(ctx context.Context, options ClientOptions, up func(context.Context, ClientOptions) error) error {
+	maxRetries := options.MaxAttempts
+	if maxRetries <= 0 {
+		maxRetries = DefaultConnectAttempts
+	}
+	wait := options.RetryWait
+	if wait <= 0 {
+		wait = DefaultConnectRetryWait
+	}
 	var lastErr error
 
 	for attempt := 1; attempt <= maxRetries; attempt++ {
-		log.Printf("Attempt %d/%d", attempt, maxRetries)
+		logging.Debugf("Attempt %d/%d", attempt, maxRetries)
 
-		err := c.UpWithOptions(ctx, options)
+		err := up(ctx, options)
 		if err == nil {
-			log.Printf("✅ Attempt %d successful!", attempt)
+			logging.Debugf("✅ Attempt %d successful!", attempt)
 			return nil
 		}
 
-		log.Printf("❌ Attempt %d failed: %v", attempt, err)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if errors.Is(err, ErrInvalidOptions) {
+			return err
+		}
+		logging.Errorf("❌ Attempt %d failed: %v", attempt, err)
 		lastErr = err
 
 		if attempt < maxRetries {
-			log.Printf("Waiting 15 seconds before retry...")
-			time.Sleep(15 * time.Second)
+			logging.Debugf("Waiting %v before retry...", wait)
+			if err := sleepContext(ctx, wait); err != nil {
+				return err
+			}
+			wait = min(2*wait, MaxConnectRetryWait)
 		}
 	}
 
@@ -265,57 +375,218 @@ func (c *SimpleClient) UpWithOptionsWithRetry(ctx context.Context, options Clien
 }
 
 // UpWithOptions connects to Tailscale with the given options
+// All internal waits honor ctx: once it is cancelled the remaining steps are skipped and ctx.Err() is returned
+// When ControlURLs are set, the control servers are tried in order; see upWithFailover
+// A PreferredDERP region is pinned once connected; failing to pin it is logged and does not fail the login
 func (c *SimpleClient) UpWithOptions(ctx context.Context, options ClientOptions) error {
-	log.Printf("Starting Tailscale connection process")
-	log.Printf("Control URL: %s", options.ControlURL)
-	log.Printf("Hostname: %s", options.Hostname)
-	log.Printf("Auth key: %s...", c.maskAuthKey(options.AuthKey))
-	log.Printf("Socket path: %s", c.socketPath)
+	defer c.invalidateStatus()
+
+	var err error
+	candidates := controlURLCandidates(options)
+	if len(candidates) <= 1 {
+		err = c.upWithControlURL(ctx, options)
+	} else {
+		err = c.upWithFailover(ctx, options, candidates)
+	}
+	if err != nil {
+		return err
+	}
+
+	if options.PreferredDERP != 0 {
+		if err := c.SetPreferredDERP(ctx, options.PreferredDERP); err != nil {
+			logging.Warnf("Failed to pin DERP region %d: %v", options.PreferredDERP, err)
+		} else {
+			logging.Infof("Pinned DERP home region %d", options.PreferredDERP)
+		}
+	}
+	return nil
+}
+
+// controlFailoverThreshold is the number of consecutive failed attempts against the
+// pinned control server before the client fails over to the next one
+const controlFailoverThreshold = 3
+
+// upWithFailover connects using the pinned control server, or the first reachable one
+// in order when nothing is pinned yet. A failing pinned server is kept until it has failed
+// controlFailoverThreshold times in a row, so a transient outage does not move the node
+// between control servers. Before the first successful connection there is nothing to
+// protect, so every server is tried in order right away.
+func (c *SimpleClient) upWithFailover(ctx context.Context, options ClientOptions, candidates []string) error {
+	connected := c.ActiveControlURL() != ""
+	pinned := c.currentControlURL(ctx, candidates)
+	ordered := []string{pinned}
+	for _, candidate := range candidates {
+		if candidate != pinned {
+			ordered = append(ordered, candidate)
+		}
+	}
+
+	var lastErr error
+	for _, controlURL := range ordered {
+		if err := c.checkControlURLReachability(controlURL); err != nil {
+			lastErr = fmt.Errorf("control server %s unreachable: %v", controlURL, err)
+		} else {
+			attempt := options
+			attempt.ControlURL = controlURL
+			attempt.ControlURLs = nil
+			err := c.upWithControlURL(ctx, attempt)
+			if err == nil {
+				c.pinControlURL(controlURL)
+				return nil
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			// Invalid options fail the same way against every control server
+			if errors.Is(err, ErrInvalidOptions) {
+				return fmt.Errorf("control server %s: %w", controlURL, err)
+			}
+			lastErr = fmt.Errorf("control server %s: %v", controlURL, err)
+		}
+
+		if controlURL == pinned && connected {
+			if failures := c.recordControlFailure(); failures < controlFailoverThreshold {
+				return fmt.Errorf("%v (failure %d/%d before failover)", lastErr, failures, controlFailoverThreshold)
+			}
+		}
+		logging.Warnf("⚠️ %v, trying next control server", lastErr)
+	}
+
+	return fmt.Errorf("all %d control servers failed, last error: %v", len(ordered), lastErr)
+}
+
+// controlURLCandidates returns ControlURL followed by ControlURLs, without blanks or duplicates
+func controlURLCandidates(options ClientOptions) []string {
+	var candidates []string
+	seen := make(map[string]bool)
+	for _, controlURL := range append([]string{options.ControlURL}, options.ControlURLs...) {
+		if controlURL == "" || seen[controlURL] {
+			continue
+		}
+		seen[controlURL] = true
+		candidates = append(candidates, controlURL)
+	}
+	return candidates
+}
+
+// currentControlURL returns the pinned control server. Before the first successful
+// connection it adopts the server tailscaled is already using, so a restarted daemon
+// does not move a node that had failed over back to the primary.
+func (c *SimpleClient) currentControlURL(ctx context.Context, candidates []string) string {
+	c.controlMu.Lock()
+	pinned := c.pinnedControlURL
+	c.controlMu.Unlock()
+
+	if pinned == "" {
+		if prefs, err := c.localClient.GetPrefs(ctx); err == nil {
+			pinned = prefs.ControlURL
+		}
+	}
+	for _, candidate := range candidates {
+		if candidate == pinned {
+			return pinned
+		}
+	}
+	return candidates[0]
+}
+
+// pinControlURL records the control server the client connected to and resets the failure count
+func (c *SimpleClient) pinControlURL(controlURL string) {
+	c.controlMu.Lock()
+	defer c.controlMu.Unlock()
+
+	if c.pinnedControlURL != "" && c.pinnedControlURL != controlURL {
+		logging.Warnf("Failed over from control server %s to %s", c.pinnedControlURL, controlURL)
+	}
+	c.pinnedControlURL = controlURL
+	c.controlFailures = 0
+}
+
+// recordControlFailure increments and returns the consecutive failure count of the pinned control server
+func (c *SimpleClient) recordControlFailure() int {
+	c.controlMu.Lock()
+	defer c.controlMu.Unlock()
+
+	c.controlFailures++
+	return c.controlFailures
+}
+
+// ActiveControlURL returns the control server the client is pinned to, empty before the first connection
+func (c *SimpleClient) ActiveControlURL() string {
+	c.controlMu.Lock()
+	defer c.controlMu.Unlock()
+	return c.pinnedControlURL
+}
+
+// upWithControlURL runs the connection process against options.ControlURL
+func (c *SimpleClient) upWithControlURL(ctx context.Context, options ClientOptions) error {
+	logging.Infof("Starting Tailscale connection process")
+	logging.Debugf("Control URL: %s", options.ControlURL)
+	logging.Debugf("Hostname: %s", options.Hostname)
+	logging.Debugf("Auth key: %s...", c.maskAuthKey(options.AuthKey))
+	logging.Debugf("Socket path: %s", c.socketPath)
 
 	// Validate required parameters
 	if err := c.validateOptions(options); err != nil {
-		return fmt.Errorf("parameter validation failed: %v", err)
+		return fmt.Errorf("parameter validation failed: %w", err)
 	}
 
 	if err := c.waitForDaemonReady(ctx); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
 		return fmt.Errorf("waitForDaemonReady failed: %w", err)
 	}
 
 	// Step 2: Check and reuse existing state
 	if err := c.checkAndReuseExistingState(ctx, options); err == nil {
-		log.Println("Reusing existing state, connection process complete")
+		logging.Debugf("Reusing existing state, connection process complete")
 		return nil
+	} else if ctx.Err() != nil {
+		return ctx.Err()
 	}
 
 	if err := c.completeReset(ctx); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
 		return fmt.Errorf("completeReset failed: %w", err)
 	}
-	log.Printf("completeReset completed")
+	logging.Debugf("completeReset completed")
 
 	// Key fix 2: Step-by-step precise setup
 	if err := c.preciseSetup(ctx, options); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
 		return fmt.Errorf("precise setup failed: %v", err)
 	}
 
 	// Key fix 3: Improved authentication process
 	if err := c.improvedAuthentication(ctx, options); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
 		return fmt.Errorf("authentication failed: %v", err)
 	}
 
 	// Step 4: Wait for full connection establishment
 	if err := c.waitForFullConnection(ctx); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
 		return fmt.Errorf("waiting for connection completion failed: %v", err)
 	}
 
 	// Step 5: Configure DNS preferences to prevent overwriting /etc/resolv.conf
 	if !options.AcceptDNS {
 		if err := c.disableTailscaleDNS(ctx); err != nil {
-			log.Printf("Warning: Failed to disable Tailscale DNS: %v", err)
+			logging.Warnf("Warning: Failed to disable Tailscale DNS: %v", err)
 			// Don't fail the connection if DNS setting fails
 		}
 	}
 
-	log.Println("Fixed version connection process completed")
+	logging.Infof("Fixed version connection process completed")
 	return nil
 }
 
@@ -325,27 +596,27 @@ func (c *SimpleClient) UpWithOptions(ctx context.Context, options ClientOptions)
 
 // checkAndReuseExistingState checks and reuses existing connection state if possible
 func (c *SimpleClient) checkAndReuseExistingState(ctx context.Context, options ClientOptions) error {
-	log.Println("Checking existing state, attempting to reuse...")
+	logging.Debugf("Checking existing state, attempting to reuse...")
 
-	status, err := c.GetStatus(ctx)
+	status, err := c.GetStatusFresh(ctx)
 	if err != nil {
-		log.Printf("Unable to get status: %v", err)
+		logging.Errorf("Unable to get status: %v", err)
 		return fmt.Errorf("unable to get status")
 	}
 
-	log.Printf("Current state: %s", status.BackendState)
+	logging.Debugf("Current state: %s", status.BackendState)
 
 	// If already running, check if configuration matches
 	if status.BackendState == "Running" {
-		log.Println("✓ Client already in running state")
+		logging.Debugf("✓ Client already in running state")
 
 		if status.Self != nil && len(status.Self.TailscaleIPs) > 0 {
-			log.Printf("✓ Has valid IP: %v", status.Self.TailscaleIPs)
+			logging.Debugf("✓ Has valid IP: %v", status.Self.TailscaleIPs)
 
 			// Get current preferences
 			prefs, err := c.localClient.GetPrefs(ctx)
 			if err != nil {
-				log.Printf("Unable to get preferences: %v", err)
+				logging.Errorf("Unable to get preferences: %v", err)
 				return fmt.Errorf("unable to get preferences")
 			}
 
@@ -389,62 +660,69 @@ func (c *SimpleClient) checkAndReuseExistingState(ctx context.Context, options C
 
 			// If configuration hasn't changed, can reuse
 			if !configChanged {
-				log.Println("✓ Configuration completely matches, can reuse existing state")
+				logging.Debugf("✓ Configuration completely matches, can reuse existing state")
 
 				// Enable running state
 				maskedPrefs := c.createWantRunningPrefs(true)
 
-				_, err = c.localClient.EditPrefs(ctx, maskedPrefs)
+				_, err = c.editPrefs(ctx, "reuse existing state", maskedPrefs)
 				if err == nil {
-					log.Println("✓ Successfully reused existing state")
+					logging.Infof("✓ Successfully reused existing state")
 
 					// Configure DNS preferences when reusing existing state
 					if !options.AcceptDNS {
 						if dnsErr := c.disableTailscaleDNS(ctx); dnsErr != nil {
-							log.Printf("Warning: Failed to disable Tailscale DNS when reusing state: %v", dnsErr)
+							logging.Warnf("Warning: Failed to disable Tailscale DNS when reusing state: %v", dnsErr)
 						}
 					}
 
 					return nil
 				}
 			} else {
-				log.Println("⚠️ Configuration has changed, need to re-authenticate:")
+				logging.Warnf("⚠️ Configuration has changed, need to re-authenticate:")
 				for _, reason := range changeReasons {
-					log.Printf("  - %s", reason)
+					logging.Warnf("  - %s", reason)
 				}
 				return fmt.Errorf("configuration change requires re-authentication")
 			}
 		}
 	}
 
-	log.Println("Cannot reuse existing state, need to re-authenticate")
+	logging.Debugf("Cannot reuse existing state, need to re-authenticate")
 	return fmt.Errorf("need to re-authenticate")
 }
 
 // waitForDaemonReady waits for the Tailscale daemon to be ready
 func (c *SimpleClient) waitForDaemonReady(ctx context.Context) error {
-	log.Println("Waiting for Tailscale daemon to be ready...")
+	ctx = withStateReason(ctx, "wait for daemon ready")
+	logging.Debugf("Waiting for Tailscale daemon to be ready...")
 
 	for i := 0; i < 30; i++ {
-		status, err := c.GetStatus(ctx)
+		status, err := c.GetStatusFresh(ctx)
 		if err != nil {
-			log.Printf("Daemon check %d/30: connection failed - %v", i+1, err)
-			time.Sleep(1 * time.Second)
+			logging.Warnf("Daemon check %d/30: connection failed - %v", i+1, err)
+			if err := sleepContext(ctx, 1*time.Second); err != nil {
+				return err
+			}
 			continue
 		}
 
 		// Check if daemon is in stable state
 		if status.BackendState == "Stopped" || status.BackendState == "NeedsLogin" {
-			log.Printf("Daemon ready: %s", status.BackendState)
+			logging.Debugf("Daemon ready: %s", status.BackendState)
 			// Wait additional 2 seconds for stability
-			time.Sleep(2 * time.Second)
+			if err := sleepContext(ctx, 2*time.Second); err != nil {
+				return err
+			}
 			return nil
 		}
 
 		if i%10 == 0 || i < 3 {
-			log.Printf("Daemon check %d/30: %s", i+1, status.BackendState)
+			logging.Debugf("Daemon check %d/30: %s", i+1, status.BackendState)
+		}
+		if err := sleepContext(ctx, 1*time.Second); err != nil {
+			return err
 		}
-		time.Sleep(1 * time.Second)
 	}
 
 	return fmt.Errorf("daemon not ready within 30 seconds")
@@ -452,36 +730,37 @@ func (c *SimpleClient) waitForDaemonReady(ctx context.Context) error {
 
 // completeReset intelligently resets connection state (optimized version)
 func (c *SimpleClient) completeReset(ctx context.Context) error {
-	log.Println("Intelligently resetting connection state")
+	ctx = withStateReason(ctx, "reset")
+	logging.Debugf("Intelligently resetting connection state")
 
 	// Get current status
-	status, err := c.GetStatus(ctx)
+	status, err := c.GetStatusFresh(ctx)
 	if err != nil {
-		log.Printf("Unable to get status: %v", err)
+		logging.Errorf("Unable to get status: %v", err)
 		return nil
 	}
 
-	log.Printf("State before reset: %s", status.BackendState)
+	logging.Debugf("State before reset: %s", status.BackendState)
 
 	// Intelligently determine if reset is needed
 	switch status.BackendState {
 	case "Stopped":
-		log.Println("Already stopped, skipping reset")
+		logging.Debugf("Already stopped, skipping reset")
 		return nil
 	case "NeedsLogin":
 		// Check if there are residual authentication states
 		if status.Self != nil && len(status.Self.TailscaleIPs) > 0 {
-			log.Println("NeedsLogin state but has residual IPs, need complete reset")
+			logging.Debugf("NeedsLogin state but has residual IPs, need complete reset")
 		} else {
-			log.Println("Clean NeedsLogin state, skipping reset")
+			logging.Debugf("Clean NeedsLogin state, skipping reset")
 			return nil
 		}
 	case "Running":
-		log.Println("Currently running, need to reset")
+		logging.Debugf("Currently running, need to reset")
 	case "Starting":
-		log.Println("Starting up, waiting for completion or reset")
+		logging.Debugf("Starting up, waiting for completion or reset")
 	default:
-		log.Printf("Unknown state %s, attempting reset", status.BackendState)
+		logging.Debugf("Unknown state %s, attempting reset", status.BackendState)
 	}
 
 	// Execute reset
@@ -495,9 +774,9 @@ func (c *SimpleClient) completeReset(ctx context.Context) error {
 		LoggedOutSet:   true,
 	}
 
-	_, err = c.localClient.EditPrefs(ctx, maskedPrefs)
+	_, err = c.editPrefs(ctx, "reset", maskedPrefs)
 	if err != nil {
-		log.Printf("Failed to stop connection: %v", err)
+		logging.Errorf("Failed to stop connection: %v", err)
 		return err
 	}
 
@@ -507,55 +786,61 @@ func (c *SimpleClient) completeReset(ctx context.Context) error {
 		maxWait = 15 // Running state needs more time to stop
 	}
 
-	log.Printf("Waiting for state reset (max %d seconds)...", maxWait)
+	logging.Debugf("Waiting for state reset (max %d seconds)...", maxWait)
 
 	// Wait for state to become Stopped or NeedsLogin
 	for i := 0; i < maxWait; i++ {
-		time.Sleep(1 * time.Second)
-		if status, err := c.GetStatus(ctx); err == nil {
+		if err := sleepContext(ctx, 1*time.Second); err != nil {
+			return err
+		}
+		if status, err := c.GetStatusFresh(ctx); err == nil {
 			if i%5 == 0 || status.BackendState != "Stopping" {
-				log.Printf("Reset progress %d/%d: %s", i+1, maxWait, status.BackendState)
+				logging.Debugf("Reset progress %d/%d: %s", i+1, maxWait, status.BackendState)
 			}
 
 			if status.BackendState == "Stopped" || status.BackendState == "NeedsLogin" {
-				log.Printf("✅ State reset completed: %s", status.BackendState)
-				time.Sleep(1 * time.Second) // Brief wait for state stability
+				logging.Debugf("✅ State reset completed: %s", status.BackendState)
+				if err := sleepContext(ctx, 1*time.Second); err != nil { // Brief wait for state stability
+					return err
+				}
 				return nil
 			}
 		}
 	}
 
 	// Check final state
-	if finalStatus, err := c.GetStatus(ctx); err == nil {
+	if finalStatus, err := c.GetStatusFresh(ctx); err == nil {
 		if finalStatus.BackendState == "NeedsLogin" || finalStatus.BackendState == "Stopped" {
-			log.Printf("✅ Reset completed: %s", finalStatus.BackendState)
+			logging.Debugf("✅ Reset completed: %s", finalStatus.BackendState)
 			return nil
 		}
-		log.Printf("⚠️ Reset may be incomplete, current state: %s", finalStatus.BackendState)
+		logging.Warnf("⚠️ Reset may be incomplete, current state: %s", finalStatus.BackendState)
 	}
 
-	log.Println("State reset completed")
+	logging.Debugf("State reset completed")
 	return nil
 }
 
 // preciseSetup performs precise configuration setup (enhanced version)
 func (c *SimpleClient) preciseSetup(ctx context.Context, options ClientOptions) error {
-	log.Println("Precise configuration setup")
+	logging.Debugf("Precise configuration setup")
 
 	// Use helper method to create configuration directly, no need to get current config
 
 	// Use helper method to create basic configuration
 	maskedPrefs := c.createBasicPrefs(options)
 
-	log.Printf("Applying precise configuration...")
-	_, err := c.localClient.EditPrefs(ctx, maskedPrefs)
+	logging.Debugf("Applying precise configuration...")
+	_, err := c.editPrefs(ctx, "precise setup", maskedPrefs)
 	if err != nil {
 		return fmt.Errorf("precise configuration failed: %v", err)
 	}
 
 	// Increase wait time to ensure configuration takes effect
-	log.Println("Waiting for configuration to take effect...")
-	time.Sleep(5 * time.Second) // Increased from 3 to 5 seconds
+	logging.Debugf("Waiting for configuration to take effect...")
+	if err := sleepContext(ctx, 5*time.Second); err != nil { // Increased from 3 to 5 seconds
+		return err
+	}
 
 	// Verify configuration
 	updatedPrefs, err := c.localClient.GetPrefs(ctx)
@@ -566,36 +851,37 @@ func (c *SimpleClient) preciseSetup(ctx context.Context, options ClientOptions)
 		}
 	}
 
-	log.Println("Precise configuration completed")
+	logging.Debugf("Precise configuration completed")
 	return nil
 }
 
 // improvedAuthentication performs optimized authentication process
 func (c *SimpleClient) improvedAuthentication(ctx context.Context, options ClientOptions) error {
-	log.Println("Optimized authentication process")
+	ctx = withStateReason(ctx, "authentication")
+	logging.Debugf("Optimized authentication process")
 	// If in "auto" mode, handle existing state
 	if options.AuthKey == "auto" {
 		return c.handleAutoModeAPI(ctx, options)
 	}
 	// 3.1 Check current state
-	status, err := c.GetStatus(ctx)
+	status, err := c.GetStatusFresh(ctx)
 	if err != nil {
 		return fmt.Errorf("unable to get current state: %v", err)
 	}
 
-	log.Printf("State before authentication: %s", status.BackendState)
+	logging.Debugf("State before authentication: %s", status.BackendState)
 
 	// If already running, check if re-authentication is needed
 	if status.BackendState == "Running" {
 		if c.isLoginComplete(status) {
-			log.Println("✅ Already logged in, skipping authentication")
+			logging.Debugf("✅ Already logged in, skipping authentication")
 			return nil
 		}
-		log.Println("Running but login incomplete, continuing authentication process")
+		logging.Debugf("Running but login incomplete, continuing authentication process")
 	}
 
 	// 3.2 Enable running state
-	log.Println("Enabling running state")
+	logging.Debugf("Enabling running state")
 	prefs := ipn.NewPrefs()
 	prefs.WantRunning = true
 
@@ -604,46 +890,52 @@ func (c *SimpleClient) improvedAuthentication(ctx context.Context, options Clien
 		WantRunningSet: true,
 	}
 
-	_, err = c.localClient.EditPrefs(ctx, maskedPrefs)
+	_, err = c.editPrefs(ctx, "enable running", maskedPrefs)
 	if err != nil {
 		return fmt.Errorf("failed to enable running state: %v", err)
 	}
 
 	// 3.3 Quick state change check
-	log.Println("Checking state changes...")
-	time.Sleep(2 * time.Second)
+	logging.Debugf("Checking state changes...")
+	if err := sleepContext(ctx, 2*time.Second); err != nil {
+		return err
+	}
 
 	var finalState string
 	for i := 0; i < 60; i++ { // Reduced to 10 checks
-		status, err := c.GetStatus(ctx)
+		status, err := c.GetStatusFresh(ctx)
 		if err != nil {
-			log.Printf("State check failed %d: %v", i+1, err)
-			time.Sleep(500 * time.Millisecond)
+			logging.Warnf("State check failed %d: %v", i+1, err)
+			if err := sleepContext(ctx, 500*time.Millisecond); err != nil {
+				return err
+			}
 			continue
 		}
 
 		finalState = status.BackendState
-		log.Printf("State check %d/10: %s", i+1, status.BackendState)
+		logging.Debugf("State check %d/10: %s", i+1, status.BackendState)
 
 		if status.BackendState == "Running" {
 			if c.isLoginComplete(status) {
-				log.Println("✅ Directly entered complete Running state")
+				logging.Debugf("✅ Directly entered complete Running state")
 				return nil
 			}
-			log.Println("Running but incomplete, continuing authentication")
+			logging.Debugf("Running but incomplete, continuing authentication")
 		}
 
 		if status.BackendState == "NeedsLogin" {
-			log.Println("✅ Entered NeedsLogin state, starting authentication")
+			logging.Debugf("✅ Entered NeedsLogin state, starting authentication")
 			break
 		}
 
-		time.Sleep(500 * time.Millisecond)
+		if err := sleepContext(ctx, 500*time.Millisecond); err != nil {
+			return err
+		}
 	}
 
 	// 3.4 Send authentication request and wait for initial response
 	if finalState == "NeedsLogin" {
-		log.Println("Sending authentication request")
+		logging.Debugf("Sending authentication request")
 		startOptions := ipn.Options{
 			AuthKey: options.AuthKey,
 		}
@@ -653,7 +945,7 @@ func (c *SimpleClient) improvedAuthentication(ctx context.Context, options Clien
 		prefs.LoggedOut = true
 		prefs.WantRunning = false
 
-		_, err := c.localClient.EditPrefs(ctx, &ipn.MaskedPrefs{
+		_, err := c.editPrefs(ctx, "pre-auth cleanup", &ipn.MaskedPrefs{
 			Prefs:          *prefs,
 			ControlURLSet:  true,
 			LoggedOutSet:   true,
@@ -663,7 +955,7 @@ func (c *SimpleClient) improvedAuthentication(ctx context.Context, options Clien
 			return fmt.Errorf("pre-cleanup failed: %w", err)
 		}
 
-		log.Printf("Using authentication key: %s...", c.maskAuthKey(options.AuthKey))
+		logging.Debugf("Using authentication key: %s...", c.maskAuthKey(options.AuthKey))
 		err = c.localClient.Start(ctx, startOptions)
 		if err != nil {
 			return fmt.Errorf("Start command failed: %v", err)
@@ -676,7 +968,7 @@ func (c *SimpleClient) improvedAuthentication(ctx context.Context, options Clien
 		}
 		// Check if authentication was successful
 		if err := c.waitForAuthCompletion(ctx); err != nil {
-			log.Printf("Authentication method completion failed: %v", err)
+			logging.Errorf("Authentication method completion failed: %v", err)
 			return err
 		}
 	}
@@ -686,7 +978,8 @@ func (c *SimpleClient) improvedAuthentication(ctx context.Context, options Clien
 
 // waitForAuthCompletion waits for authentication completion - enhanced version
 func (c *SimpleClient) waitForAuthCompletion(ctx context.Context) error {
-	log.Println("Waiting for authentication completion...")
+	ctx = withStateReason(ctx, "wait for authentication")
+	logging.Debugf("Waiting for authentication completion...")
 
 	maxWaitSeconds := 30 // Reduced to 30 seconds, focusing on authentication phase
 	checkInterval := 1 * time.Second
@@ -698,34 +991,36 @@ func (c *SimpleClient) waitForAuthCompletion(ctx context.Context) error {
 		default:
 		}
 
-		time.Sleep(checkInterval)
+		if err := sleepContext(ctx, checkInterval); err != nil {
+			return err
+		}
 
-		status, err := c.GetStatus(ctx)
+		status, err := c.GetStatusFresh(ctx)
 		if err != nil {
-			log.Printf("Status check failed %d: %v", i+1, err)
+			logging.Warnf("Status check failed %d: %v", i+1, err)
 			continue
 		}
 
 		// Print detailed status every 10 seconds
 		if i%10 == 0 {
-			log.Printf("Authentication progress %d/%ds - State: %s, NodeKey: %v, AuthURL: %s",
+			logging.Debugf("Authentication progress %d/%ds - State: %s, NodeKey: %v, AuthURL: %s",
 				i+1, maxWaitSeconds, status.BackendState, status.HaveNodeKey, status.AuthURL)
 		}
 
 		// Success conditions
 		if status.HaveNodeKey {
-			log.Println("✅ NodeKey obtained, authentication successful")
+			logging.Debugf("✅ NodeKey obtained, authentication successful")
 			return nil
 		}
 
 		if status.BackendState == "Starting" || status.BackendState == "Running" {
-			log.Printf("✅ State changed to %s, authentication successful", status.BackendState)
+			logging.Debugf("✅ State changed to %s, authentication successful", status.BackendState)
 			return nil
 		}
 
 		// If there's AuthURL, manual authentication is needed (shouldn't happen with authkey)
 		if status.AuthURL != "" {
-			log.Printf("⚠️ Manual authentication required: %s", status.AuthURL)
+			logging.Warnf("⚠️ Manual authentication required: %s", status.AuthURL)
 			return fmt.Errorf("manual authentication required, AuthKey may be invalid")
 		}
 	}
@@ -735,26 +1030,26 @@ func (c *SimpleClient) waitForAuthCompletion(ctx context.Context) error {
 
 // handleAutoModeAPI handles auto mode using API approach
 func (c *SimpleClient) handleAutoModeAPI(ctx context.Context, options ClientOptions) error {
-	log.Println("Auto mode: API approach processing...")
+	logging.Debugf("Auto mode: API approach processing...")
 
-	status, err := c.GetStatus(ctx)
+	status, err := c.GetStatusFresh(ctx)
 	if err != nil {
 		return fmt.Errorf("unable to get status: %v", err)
 	}
 
 	// If already running and has IP, directly enable
 	if status.BackendState == "Running" && status.Self != nil && len(status.Self.TailscaleIPs) > 0 {
-		log.Println("Auto mode: Already connected, enabling running state")
+		logging.Debugf("Auto mode: Already connected, enabling running state")
 		return c.enableRunningAfterAuth(ctx)
 	}
 
 	// If has NodeKey but not running, previously authenticated, just need to enable running
 	if status.HaveNodeKey {
-		log.Println("Auto mode: Has NodeKey, just need to enable running state")
+		logging.Debugf("Auto mode: Has NodeKey, just need to enable running state")
 
 		// First update configuration to match current options
 		if err := c.updatePrefsForAuto(ctx, options); err != nil {
-			log.Printf("Configuration update failed: %v", err)
+			logging.Errorf("Configuration update failed: %v", err)
 		}
 
 		return c.enableRunningAfterAuth(ctx)
@@ -765,7 +1060,7 @@ func (c *SimpleClient) handleAutoModeAPI(ctx context.Context, options ClientOpti
 
 // updatePrefsForAuto updates preferences for auto mode
 func (c *SimpleClient) updatePrefsForAuto(ctx context.Context, options ClientOptions) error {
-	log.Println("Updating auto mode configuration...")
+	logging.Debugf("Updating auto mode configuration...")
 
 	currentPrefs, err := c.localClient.GetPrefs(ctx)
 	if err != nil {
@@ -779,7 +1074,7 @@ func (c *SimpleClient) updatePrefsForAuto(ctx context.Context, options ClientOpt
 	if currentPrefs.ControlURL != options.ControlURL {
 		updatePrefs.ControlURL = options.ControlURL
 		needUpdate = true
-		log.Printf("Updating ControlURL: %s -> %s", currentPrefs.ControlURL, options.ControlURL)
+		logging.Debugf("Updating ControlURL: %s -> %s", currentPrefs.ControlURL, options.ControlURL)
 	}
 
 	if currentPrefs.Hostname != options.Hostname {
@@ -805,7 +1100,7 @@ func (c *SimpleClient) updatePrefsForAuto(ctx context.Context, options ClientOpt
 		if !routesEqual(currentPrefs.AdvertiseRoutes, newRoutes) {
 			updatePrefs.AdvertiseRoutes = newRoutes
 			needUpdate = true
-			log.Printf("Updating AdvertiseRoutes")
+			logging.Debugf("Updating AdvertiseRoutes")
 		}
 	}
 
@@ -818,14 +1113,14 @@ func (c *SimpleClient) updatePrefsForAuto(ctx context.Context, options ClientOpt
 			AdvertiseRoutesSet: len(options.AdvertiseRoutes) > 0,
 		}
 
-		_, err = c.localClient.EditPrefs(ctx, maskedPrefs)
+		_, err = c.editPrefs(ctx, "update prefs", maskedPrefs)
 		if err != nil {
 			return fmt.Errorf("failed to update preferences: %v", err)
 		}
 
-		log.Println("Configuration update completed")
+		logging.Debugf("Configuration update completed")
 	} else {
-		log.Println("Configuration update not needed")
+		logging.Debugf("Configuration update not needed")
 	}
 
 	return nil
@@ -853,7 +1148,7 @@ func routesEqual(a, b []netip.Prefix) bool {
 
 // enableRunningAfterAuth enables running state after authentication
 func (c *SimpleClient) enableRunningAfterAuth(ctx context.Context) error {
-	log.Println("Authentication completed, enabling running state...")
+	logging.Debugf("Authentication completed, enabling running state...")
 
 	// Get current preferences
 	currentPrefs, err := c.localClient.GetPrefs(ctx)
@@ -870,18 +1165,19 @@ func (c *SimpleClient) enableRunningAfterAuth(ctx context.Context) error {
 		WantRunningSet: true,
 	}
 
-	_, err = c.localClient.EditPrefs(ctx, maskedPrefs)
+	_, err = c.editPrefs(ctx, "enable running after auth", maskedPrefs)
 	if err != nil {
 		return fmt.Errorf("failed to enable running state: %v", err)
 	}
 
-	log.Println("✅ Running state enabled")
+	logging.Infof("✅ Running state enabled")
 	return nil
 }
 
 // waitForFullConnection waits for full connection establishment
 func (c *SimpleClient) waitForFullConnection(ctx context.Context) error {
-	log.Println("Waiting for full connection establishment...")
+	ctx = withStateReason(ctx, "wait for connection")
+	logging.Debugf("Waiting for full connection establishment...")
 
 	maxWaitSeconds := 240 // 4 minutes wait for connection
 	checkInterval := 2 * time.Second
@@ -893,17 +1189,19 @@ func (c *SimpleClient) waitForFullConnection(ctx context.Context) error {
 		default:
 		}
 
-		time.Sleep(checkInterval)
+		if err := sleepContext(ctx, checkInterval); err != nil {
+			return err
+		}
 
-		status, err := c.GetStatus(ctx)
+		status, err := c.GetStatusFresh(ctx)
 		if err != nil {
-			log.Printf("Status check failed %d: %v", i+1, err)
+			logging.Warnf("Status check failed %d: %v", i+1, err)
 			continue
 		}
 
 		// Print detailed status every 10 seconds
 		if i%10 == 0 || i < 3 {
-			log.Printf("Connection wait progress %d/%ds - State: %s, HaveNodeKey: %v, Online: %v",
+			logging.Debugf("Connection wait progress %d/%ds - State: %s, HaveNodeKey: %v, Online: %v",
 				(i+1)*2, maxWaitSeconds, status.BackendState, status.HaveNodeKey,
 				status.Self != nil && status.Self.Online)
 		}
@@ -911,27 +1209,27 @@ func (c *SimpleClient) waitForFullConnection(ctx context.Context) error {
 		switch status.BackendState {
 		case "Running":
 			if c.isLoginComplete(status) {
-				log.Printf("✅ Connection successful! Total time: %d seconds", (i+1)*2)
+				logging.Infof("✅ Connection successful! Total time: %d seconds", (i+1)*2)
 				c.logConnectionInfo(status)
 				return nil
 			} else {
 				// Running but no IP assigned, continue waiting
 				if i%20 == 0 {
-					log.Printf("State Running but IP not assigned, continuing to wait...")
+					logging.Debugf("State Running but IP not assigned, continuing to wait...")
 				}
 			}
 
 		case "Starting":
 			if i%20 == 0 {
-				log.Println("Starting connection...")
+				logging.Debugf("Starting connection...")
 			}
 
 		case "NeedsLogin":
 			// If has NodeKey but state is still NeedsLogin, may need to re-enable
 			if status.HaveNodeKey {
-				log.Println("Has NodeKey but state is NeedsLogin, trying to re-enable running state")
+				logging.Debugf("Has NodeKey but state is NeedsLogin, trying to re-enable running state")
 				if err := c.enableRunningAfterAuth(ctx); err != nil {
-					log.Printf("Re-enable failed: %v", err)
+					logging.Errorf("Re-enable failed: %v", err)
 				}
 			} else {
 				// Diagnose network issues
@@ -943,13 +1241,13 @@ func (c *SimpleClient) waitForFullConnection(ctx context.Context) error {
 			}
 
 		case "Stopped":
-			log.Println("Connection stopped, trying to re-enable")
+			logging.Debugf("Connection stopped, trying to re-enable")
 			if err := c.enableRunningAfterAuth(ctx); err != nil {
-				log.Printf("Re-enable failed: %v", err)
+				logging.Errorf("Re-enable failed: %v", err)
 			}
 
 		default:
-			log.Printf("Unknown state: %s", status.BackendState)
+			logging.Debugf("Unknown state: %s", status.BackendState)
 		}
 
 		// Timeout check
@@ -969,47 +1267,56 @@ func (c *SimpleClient) logConnectionInfo(status *ipnstate.Status) {
 		return
 	}
 
-	log.Printf("Connection successful: Node name=%s, Online=%v, IP count=%d, Peer count=%d",
+	logging.Infof("Connection successful: Node name=%s, Online=%v, IP count=%d, Peer count=%d",
 		status.Self.HostName, status.Self.Online, len(status.Self.TailscaleIPs), len(status.Peer))
 }
 
 // diagnoseNetworkIssues diagnoses network problems
 func (c *SimpleClient) diagnoseNetworkIssues(ctx context.Context) {
-	log.Println("Diagnosing network issues...")
+	logging.Debugf("Diagnosing network issues...")
 
 	// Check preferences
 	prefs, err := c.localClient.GetPrefs(ctx)
 	if err != nil {
-		log.Printf("Unable to get preferences: %v", err)
+		logging.Errorf("Unable to get preferences: %v", err)
 		return
 	}
 
-	log.Printf("Current configuration: ControlURL=%s, Hostname=%s, WantRunning=%v, LoggedOut=%v",
+	logging.Debugf("Current configuration: ControlURL=%s, Hostname=%s, WantRunning=%v, LoggedOut=%v",
 		prefs.ControlURL, prefs.Hostname, prefs.WantRunning, prefs.LoggedOut)
 
 	// Test control server connectivity
 	if err := c.checkHeadscaleReachability(); err != nil {
-		log.Printf("⚠️ Control server connectivity issue: %v", err)
+		logging.Warnf("⚠️ Control server connectivity issue: %v", err)
 	} else {
-		log.Println("✅ Control server connectivity normal")
+		logging.Debugf("✅ Control server connectivity normal")
 	}
 }
 
+// CheckHeadscaleReachability checks that the control server in the current prefs accepts TCP and HTTP connections
+func (c *SimpleClient) CheckHeadscaleReachability() error {
+	return c.checkHeadscaleReachability()
+}
+
 // checkHeadscaleReachability checks Headscale server reachability
 func (c *SimpleClient) checkHeadscaleReachability() error {
-	log.Println("Checking Headscale server reachability...")
+	logging.Debugf("Checking Headscale server reachability...")
 
 	prefs, err := c.localClient.GetPrefs(context.Background())
 	if err != nil {
 		return fmt.Errorf("unable to get preferences: %v", err)
 	}
 
-	controlURL := prefs.ControlURL
+	return c.checkControlURLReachability(prefs.ControlURL)
+}
+
+// checkControlURLReachability checks that the given control server accepts TCP and HTTP connections
+func (c *SimpleClient) checkControlURLReachability(controlURL string) error {
 	if controlURL == "" {
 		return fmt.Errorf("control URL not set")
 	}
 
-	log.Printf("Checking control URL: %s", controlURL)
+	logging.Debugf("Checking control URL: %s", controlURL)
 
 	// Try to parse URL
 	u, err := url.Parse(controlURL)
@@ -1017,10 +1324,18 @@ func (c *SimpleClient) checkHeadscaleReachability() error {
 		return fmt.Errorf("invalid control URL: %v", err)
 	}
 
-	// Try to establish TCP connection
-	conn, err := net.DialTimeout("tcp", u.Host, 10*time.Second)
+	// Try to establish TCP connection, using the scheme's default port when none is given
+	host := u.Host
+	if u.Port() == "" {
+		port := "443"
+		if u.Scheme == "http" {
+			port = "80"
+		}
+		host = net.JoinHostPort(u.Hostname(), port)
+	}
+	conn, err := net.DialTimeout("tcp", host, 10*time.Second)
 	if err != nil {
-		return fmt.Errorf("unable to connect to %s: %v", u.Host, err)
+		return fmt.Errorf("unable to connect to %s: %v", host, err)
 	}
 	defer conn.Close()
 
@@ -1032,15 +1347,15 @@ func (c *SimpleClient) checkHeadscaleReachability() error {
 	}
 	defer resp.Body.Close()
 
-	log.Printf("Network check successful: TCP=%s, HTTP=%d", u.Host, resp.StatusCode)
+	logging.Debugf("Network check successful: TCP=%s, HTTP=%d", host, resp.StatusCode)
 
 	return nil
 }
 
 // DebugAuthKey adds debug method: directly validate authentication key and simplest login attempt
 func (c *SimpleClient) DebugAuthKey(ctx context.Context, authKey, controlURL string) {
-	log.Println("Debugging authentication key...")
-	log.Printf("Debug info: Key length=%d, Control URL=%s", len(authKey), controlURL)
+	logging.Debugf("Debugging authentication key...")
+	logging.Debugf("Debug info: Key length=%d, Control URL=%s", len(authKey), controlURL)
 }
 
 // isLoginComplete checks if login is complete
@@ -1056,25 +1371,25 @@ func (c *SimpleClient) isLoginComplete(status *ipnstate.Status) bool {
 	return status.Self.Online
 }
 
-// validateOptions validates option parameters
+// validateOptions validates option parameters, errors wrap ErrInvalidOptions
 func (c *SimpleClient) validateOptions(options ClientOptions) error {
 	// Support "auto" mode (use existing authentication info)
 	if options.AuthKey == "" {
-		return fmt.Errorf("authentication key cannot be empty")
+		return fmt.Errorf("%w: authentication key cannot be empty", ErrInvalidOptions)
 	}
 
 	if options.ControlURL == "" {
-		return fmt.Errorf("control URL cannot be empty")
+		return fmt.Errorf("%w: control URL cannot be empty", ErrInvalidOptions)
 	}
 
 	// If in "auto" mode, skip length validation
 	if options.AuthKey != "auto" && len(options.AuthKey) < 20 {
-		return fmt.Errorf("authentication key format may be incorrect, too short")
+		return fmt.Errorf("%w: authentication key format may be incorrect, too short", ErrInvalidOptions)
 	}
 
 	for _, route := range options.AdvertiseRoutes {
 		if _, err := netip.ParsePrefix(route); err != nil {
-			return fmt.Errorf("invalid route format '%s': %v", route, err)
+			return fmt.Errorf("%w: invalid route format '%s': %v", ErrInvalidOptions, route, err)
 		}
 	}
 
@@ -1102,13 +1417,17 @@ func (c *SimpleClient) Up(ctx context.Context, authKey string) error {
 
 // AdvertiseRoutes 通告路由
 func (c *SimpleClient) AdvertiseRoutes(ctx context.Context, routes ...netip.Prefix) error {
+	defer c.invalidateStatus()
+
 	maskedPrefs := c.createRoutePrefs(routes, nil, "")
-	_, err := c.localClient.EditPrefs(ctx, maskedPrefs)
+	_, err := c.editPrefs(ctx, "advertise routes", maskedPrefs)
 	return err
 }
 
 // RemoveRoutes 移除通告的路由
 func (c *SimpleClient) RemoveRoutes(ctx context.Context, routes ...netip.Prefix) error {
+	defer c.invalidateStatus()
+
 	currentPrefs, err := c.localClient.GetPrefs(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to get current preferences: %v", err)
@@ -1129,7 +1448,7 @@ func (c *SimpleClient) RemoveRoutes(ctx context.Context, routes ...netip.Prefix)
 	prefs := ipn.NewPrefs()
 	prefs.AdvertiseRoutes = newRoutes
 
-	_, err = c.localClient.EditPrefs(ctx, &ipn.MaskedPrefs{
+	_, err = c.editPrefs(ctx, "remove routes", &ipn.MaskedPrefs{
 		Prefs:              *prefs,
 		AdvertiseRoutesSet: true,
 	})
@@ -1143,24 +1462,44 @@ func (c *SimpleClient) RemoveRoutes(ctx context.Context, routes ...netip.Prefix)
 
 // AcceptRoutes accepts routes from other nodes
 func (c *SimpleClient) AcceptRoutes(ctx context.Context) error {
+	defer c.invalidateStatus()
+
 	routeAll := true
 	maskedPrefs := c.createRoutePrefs(nil, &routeAll, "")
-	_, err := c.localClient.EditPrefs(ctx, maskedPrefs)
+	_, err := c.editPrefs(ctx, "accept routes", maskedPrefs)
 	return err
 }
 
 // RejectRoutes rejects routes from other nodes
 func (c *SimpleClient) RejectRoutes(ctx context.Context) error {
+	defer c.invalidateStatus()
+
 	routeAll := false
 	maskedPrefs := c.createRoutePrefs(nil, &routeAll, "")
-	_, err := c.localClient.EditPrefs(ctx, maskedPrefs)
+	_, err := c.editPrefs(ctx, "reject routes", maskedPrefs)
 	return err
 }
 
 // SetHostname sets the hostname
 func (c *SimpleClient) SetHostname(ctx context.Context, hostname string) error {
+	defer c.invalidateStatus()
+
 	maskedPrefs := c.createRoutePrefs(nil, nil, hostname)
-	_, err := c.localClient.EditPrefs(ctx, maskedPrefs)
+	_, err := c.editPrefs(ctx, "set hostname", maskedPrefs)
+	return err
+}
+
+// SetAcceptDNS sets whether to accept DNS configuration (including split DNS) from the control server
+func (c *SimpleClient) SetAcceptDNS(ctx context.Context, accept bool) error {
+	defer c.invalidateStatus()
+
+	maskedPrefs := &ipn.MaskedPrefs{
+		Prefs: ipn.Prefs{
+			CorpDNS: accept,
+		},
+		CorpDNSSet: true,
+	}
+	_, err := c.editPrefs(ctx, "set accept DNS", maskedPrefs)
 	return err
 }
 
@@ -1322,9 +1661,62 @@ func (c *SimpleClient) GetPrefs(ctx context.Context) (*ipn.Prefs, error) {
 	return c.localClient.GetPrefs(ctx)
 }
 
+// WhoIsResult is the tailnet identity that owns an IP address
+type WhoIsResult struct {
+	NodeID      string   // Stable node ID
+	NodeName    string   // MagicDNS name (FQDN without trailing dot)
+	Hostname    string   // Hostname reported by the node
+	Addresses   []string // Tailscale addresses of the node
+	LoginName   string   // Owner login name, empty for tagged nodes
+	DisplayName string   // Owner display name
+	Tags        []string // ACL tags of the node
+}
+
 // WhoIs queries IP ownership
-func (c *SimpleClient) WhoIs(ctx context.Context, remoteAddr string) (interface{}, error) {
-	return c.localClient.WhoIs(ctx, remoteAddr)
+// remoteAddr may be an IP or ip:port
+func (c *SimpleClient) WhoIs(ctx context.Context, remoteAddr string) (*WhoIsResult, error) {
+	resp, err := c.localClient.WhoIs(ctx, remoteAddr)
+	if err != nil {
+		return nil, err
+	}
+	if resp == nil || resp.Node == nil {
+		return nil, fmt.Errorf("no tailnet node owns %s", remoteAddr)
+	}
+
+	result := &WhoIsResult{
+		NodeID:   string(resp.Node.StableID),
+		NodeName: strings.TrimSuffix(resp.Node.Name, "."),
+		Tags:     append([]string(nil), resp.Node.Tags...),
+	}
+	if resp.Node.Hostinfo.Valid() {
+		result.Hostname = resp.Node.Hostinfo.Hostname()
+	}
+	if result.Hostname == "" {
+		result.Hostname = resp.Node.ComputedName
+	}
+	for _, addr := range resp.Node.Addresses {
+		result.Addresses = append(result.Addresses, addr.Addr().String())
+	}
+	if resp.UserProfile != nil {
+		result.LoginName = resp.UserProfile.LoginName
+		result.DisplayName = resp.UserProfile.DisplayName
+	}
+
+	return result, nil
+}
+
+// ResolvePeerTags returns the ACL tags of the tailnet node that owns ip
+// Untagged nodes return an empty slice
+func (c *SimpleClient) ResolvePeerTags(ctx context.Context, ip string) ([]string, error) {
+	if _, err := netip.ParseAddr(ip); err != nil {
+		return nil, fmt.Errorf("invalid IP address %q: %v", ip, err)
+	}
+
+	result, err := c.WhoIs(ctx, ip)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve peer %s: %v", ip, err)
+	}
+	return result.Tags, nil
 }
 
 // Ping tests connectivity
@@ -1339,7 +1731,7 @@ func (c *SimpleClient) Ping(ctx context.Context, target string) error {
 
 // QuickConnect quick connection - simplified connection method
 func (c *SimpleClient) QuickConnect(ctx context.Context, authKey, controlURL, hostname string) error {
-	log.Println("Quick connection mode")
+	logging.Debugf("Quick connection mode")
 
 	options := ClientOptions{
 		AuthKey:      authKey,
@@ -1352,9 +1744,19 @@ func (c *SimpleClient) QuickConnect(ctx context.Context, authKey, controlURL, ho
 	return c.UpWithOptions(ctx, options)
 }
 
+// Logout logs out and discards the local node key, keeping prefs and the interface
+func (c *SimpleClient) Logout(ctx context.Context) error {
+	defer c.invalidateStatus()
+
+	logging.Infof("Logging out and discarding node key...")
+	return c.localClient.Logout(ctx)
+}
+
 // ForceLogin forces re-login
 func (c *SimpleClient) ForceLogin(ctx context.Context, options ClientOptions) error {
-	log.Println("Starting forced re-login...")
+	defer c.invalidateStatus()
+
+	logging.Infof("Starting forced re-login...")
 
 	// Force logout - using helper method
 	prefs := ipn.NewPrefs()
@@ -1367,18 +1769,20 @@ func (c *SimpleClient) ForceLogin(ctx context.Context, options ClientOptions) er
 		LoggedOutSet:   true,
 	}
 
-	_, err := c.localClient.EditPrefs(ctx, maskedPrefs)
+	_, err := c.editPrefs(ctx, "force login", maskedPrefs)
 	if err != nil {
-		log.Printf("Force logout failed: %v", err)
+		logging.Errorf("Force logout failed: %v", err)
 	}
 
-	time.Sleep(3 * time.Second)
+	if err := sleepContext(ctx, 3*time.Second); err != nil {
+		return err
+	}
 	return c.UpWithOptions(ctx, options)
 }
 
 // disableTailscaleDNS 禁用 Tailscale DNS 覆盖，防止修改 /etc/resolv.conf
 func (c *SimpleClient) disableTailscaleDNS(ctx context.Context) error {
-	log.Println("禁用 Tailscale DNS 覆盖")
+	logging.Debugf("禁用 Tailscale DNS 覆盖")
 
 	// 创建 MaskedPrefs 来设置 CorpDNS: false
 	maskedPrefs := &ipn.MaskedPrefs{
@@ -1388,12 +1792,12 @@ func (c *SimpleClient) disableTailscaleDNS(ctx context.Context) error {
 		CorpDNSSet: true,
 	}
 
-	// 调用 localClient 的 EditPrefs 方法
-	_, err := c.localClient.EditPrefs(ctx, maskedPrefs)
+	// 通过 editPrefs 调用 EditPrefs，调试级别下记录配置差异
+	_, err := c.editPrefs(ctx, "disable DNS", maskedPrefs)
 	if err != nil {
 		return fmt.Errorf("设置 CorpDNS: false 失败: %v", err)
 	}
 
-	log.Println("✅ 成功禁用 Tailscale DNS 覆盖")
+	logging.Infof("✅ 成功禁用 Tailscale DNS 覆盖")
 	return nil
 }
@@ -0,0 +1,240 @@
+package tailscale
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"tailscale.com/ipn"
+	"tailscale.com/ipn/ipnstate"
+)
+
+// fakeLocalAPI 在 Unix socket 上模拟测试用到的 tailscaled LocalAPI，并统计 status 请求次数
+type fakeLocalAPI struct {
+	socketPath    string
+	statusQueries atomic.Int32
+}
+
+func newFakeLocalAPI(t *testing.T) *fakeLocalAPI {
+	t.Helper()
+	// Unix socket 路径不能超过 108 字节，t.TempDir() 可能超出
+	dir, err := os.MkdirTemp("", "ts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	api := &fakeLocalAPI{socketPath: filepath.Join(dir, "tailscaled.sock")}
+	listener, err := net.Listen("unix", api.socketPath)
+	if err != nil {
+		t.Fatalf("failed to listen on %s: %v", api.socketPath, err)
+	}
+
+	prefs := ipn.NewPrefs()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/localapi/v0/status", func(w http.ResponseWriter, r *http.Request) {
+		api.statusQueries.Add(1)
+		json.NewEncoder(w).Encode(&ipnstate.Status{BackendState: "Running"})
+	})
+	mux.HandleFunc("/localapi/v0/prefs", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPatch {
+			var edits ipn.MaskedPrefs
+			if err := json.NewDecoder(r.Body).Decode(&edits); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			prefs.ApplyEdits(&edits)
+		}
+		json.NewEncoder(w).Encode(prefs)
+	})
+
+	server := &http.Server{Handler: mux}
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Close() })
+	return api
+}
+
+func (api *fakeLocalAPI) queries() int {
+	return int(api.statusQueries.Load())
+}
+
+func TestStatusCacheExpiresAfterTTL(t *testing.T) {
+	api := newFakeLocalAPI(t)
+	client := NewSimpleClient(api.socketPath)
+	client.SetStatusCacheTTL(100 * time.Millisecond)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if _, err := client.GetStatus(ctx); err != nil {
+			t.Fatalf("GetStatus failed: %v", err)
+		}
+	}
+	if n := api.queries(); n != 1 {
+		t.Fatalf("expected cached GetStatus calls to query tailscaled once, got %d", n)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	if _, err := client.GetStatus(ctx); err != nil {
+		t.Fatalf("GetStatus failed: %v", err)
+	}
+	if n := api.queries(); n != 2 {
+		t.Fatalf("expected an expired cache to query tailscaled again, got %d queries", n)
+	}
+
+	// TTL 为 0 时关闭缓存
+	client.SetStatusCacheTTL(0)
+	for i := 0; i < 2; i++ {
+		if _, err := client.GetStatus(ctx); err != nil {
+			t.Fatalf("GetStatus failed: %v", err)
+		}
+	}
+	if n := api.queries(); n != 4 {
+		t.Fatalf("expected every GetStatus to query tailscaled without a cache, got %d queries", n)
+	}
+}
+
+func TestStatusCacheInvalidatedByEditPrefs(t *testing.T) {
+	api := newFakeLocalAPI(t)
+	client := NewSimpleClient(api.socketPath)
+	client.SetStatusCacheTTL(time.Hour)
+	ctx := context.Background()
+
+	if _, err := client.GetStatus(ctx); err != nil {
+		t.Fatalf("GetStatus failed: %v", err)
+	}
+	if err := client.AdvertiseRoutes(ctx, netip.MustParsePrefix("10.42.1.0/24")); err != nil {
+		t.Fatalf("AdvertiseRoutes failed: %v", err)
+	}
+	if _, err := client.GetStatus(ctx); err != nil {
+		t.Fatalf("GetStatus failed: %v", err)
+	}
+	if n := api.queries(); n != 2 {
+		t.Fatalf("expected GetStatus after EditPrefs to query tailscaled again, got %d queries", n)
+	}
+}
+
+func TestGetStatusFreshBypassesCache(t *testing.T) {
+	api := newFakeLocalAPI(t)
+	client := NewSimpleClient(api.socketPath)
+	client.SetStatusCacheTTL(time.Hour)
+	ctx := context.Background()
+
+	if _, err := client.GetStatus(ctx); err != nil {
+		t.Fatalf("GetStatus failed: %v", err)
+	}
+	if _, err := client.GetStatusFresh(ctx); err != nil {
+		t.Fatalf("GetStatusFresh failed: %v", err)
+	}
+	if n := api.queries(); n != 2 {
+		t.Fatalf("expected GetStatusFresh to query tailscaled despite a fresh cache, got %d queries", n)
+	}
+
+	// 实时结果同样写入缓存，之后的 GetStatus 直接使用
+	if _, err := client.GetStatus(ctx); err != nil {
+		t.Fatalf("GetStatus failed: %v", err)
+	}
+	if n := api.queries(); n != 2 {
+		t.Fatalf("expected GetStatus to be served from the refreshed cache, got %d queries", n)
+	}
+}
+
+// countingUp 返回依次给出 errs 中错误的登录函数，errs 用完后返回 nil，并记录调用时间
+func countingUp(errs ...error) (func(context.Context, ClientOptions) error, *[]time.Time) {
+	var calls []time.Time
+	return func(ctx context.Context, options ClientOptions) error {
+		calls = append(calls, time.Now())
+		if len(calls) <= len(errs) {
+			return errs[len(calls)-1]
+		}
+		return nil
+	}, &calls
+}
+
+func TestRetryUpRetriesWithBackoff(t *testing.T) {
+	failure := errors.New("control server unavailable")
+	up, calls := countingUp(failure, failure)
+
+	if err := retryUp(context.Background(), ClientOptions{MaxAttempts: 3, RetryWait: 20 * time.Millisecond}, up); err != nil {
+		t.Fatalf("expected the third attempt to succeed, got %v", err)
+	}
+	if len(*calls) != 3 {
+		t.Fatalf("expected 3 attempts, got %d", len(*calls))
+	}
+	// 第一次重试前等待 RetryWait，之后每次翻倍
+	if wait := (*calls)[1].Sub((*calls)[0]); wait < 20*time.Millisecond {
+		t.Errorf("expected at least 20ms before the first retry, got %v", wait)
+	}
+	if wait := (*calls)[2].Sub((*calls)[1]); wait < 40*time.Millisecond {
+		t.Errorf("expected at least 40ms before the second retry, got %v", wait)
+	}
+}
+
+func TestRetryUpGivesUpAfterMaxAttempts(t *testing.T) {
+	failure := errors.New("control server unavailable")
+	up, calls := countingUp(failure, failure, failure, failure)
+
+	err := retryUp(context.Background(), ClientOptions{MaxAttempts: 2, RetryWait: time.Millisecond}, up)
+	if err == nil || !strings.Contains(err.Error(), failure.Error()) {
+		t.Fatalf("expected the last error after all attempts failed, got %v", err)
+	}
+	if len(*calls) != 2 {
+		t.Fatalf("expected MaxAttempts to limit the attempts to 2, got %d", len(*calls))
+	}
+
+	// 未设置 MaxAttempts 时使用 DefaultConnectAttempts
+	up, calls = countingUp(failure, failure, failure, failure)
+	retryUp(context.Background(), ClientOptions{RetryWait: time.Millisecond}, up)
+	if len(*calls) != DefaultConnectAttempts {
+		t.Fatalf("expected %d attempts by default, got %d", DefaultConnectAttempts, len(*calls))
+	}
+}
+
+func TestRetryUpDefaultWaitHonorsContext(t *testing.T) {
+	up, calls := countingUp(errors.New("control server unavailable"))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// 未设置 RetryWait 时等待 DefaultConnectRetryWait，context 结束时立即返回
+	start := time.Now()
+	err := retryUp(ctx, ClientOptions{MaxAttempts: 2}, up)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the context error while waiting to retry, got %v", err)
+	}
+	if len(*calls) != 1 {
+		t.Fatalf("expected no retry before the default wait elapsed, got %d attempts", len(*calls))
+	}
+	if elapsed := time.Since(start); elapsed > DefaultConnectRetryWait/2 {
+		t.Errorf("expected the wait to stop with the context, took %v", elapsed)
+	}
+}
+
+func TestUpWithOptionsWithRetryStopsOnInvalidOptions(t *testing.T) {
+	api := newFakeLocalAPI(t)
+	client := NewSimpleClient(api.socketPath)
+
+	// 参数无效时重试也无法成功，不等待 RetryWait 直接返回
+	start := time.Now()
+	err := client.UpWithOptionsWithRetry(context.Background(), ClientOptions{
+		ControlURL:  "https://headscale.example.com",
+		MaxAttempts: 3,
+		RetryWait:   time.Hour,
+	})
+	if !errors.Is(err, ErrInvalidOptions) {
+		t.Fatalf("expected ErrInvalidOptions for a missing auth key, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected invalid options to fail without retrying, took %v", elapsed)
+	}
+	if n := api.queries(); n != 0 {
+		t.Errorf("expected invalid options to fail before contacting tailscaled, got %d status queries", n)
+	}
+}
@@ -16,24 +16,41 @@ import (
 type TailscaleClient interface {
 	// 基本连接管理
 	GetStatus(ctx context.Context) (*ipnstate.Status, error)
+	// GetStatusFresh 绕过状态缓存，供等待状态变化的循环使用
+	GetStatusFresh(ctx context.Context) (*ipnstate.Status, error)
 	GetIP(ctx context.Context) (netip.Addr, error)
 	IsConnected(ctx context.Context) bool
 	Up(ctx context.Context, authKey string) error
+	UpWithOptions(ctx context.Context, options ClientOptions) error
+	ForceLogin(ctx context.Context, options ClientOptions) error
+	Logout(ctx context.Context) error
 	Down(ctx context.Context) error
+	IsRunning(ctx context.Context) bool
+	GetLocalIP(ctx context.Context) (netip.Addr, error)
 
 	// 路由管理
 	AdvertiseRoutes(ctx context.Context, routes ...netip.Prefix) error
 	AdvertiseRoute(ctx context.Context, routes ...string) error
+	RemoveRoutes(ctx context.Context, routes ...netip.Prefix) error
 	AcceptRoutes(ctx context.Context) error
 
 	// 网络操作
 	Ping(ctx context.Context, target string) error
 	GetPeers(ctx context.Context) (map[string]*ipnstate.PeerStatus, error)
+	ResolvePeerTags(ctx context.Context, ip string) ([]string, error)
 
 	// 配置管理
 	GetPrefs(ctx context.Context) (*ipn.Prefs, error)
 	SetHostname(ctx context.Context, hostname string) error
+	SetAcceptDNS(ctx context.Context, accept bool) error
+	// SetPreferredDERP 固定 DERP home 区域，0 恢复自动选择
+	SetPreferredDERP(ctx context.Context, regionID int) error
 	SetTimeout(timeout time.Duration)
+	SetStatusCacheTTL(ttl time.Duration)
+
+	// 状态事件订阅
+	Subscribe(ch chan<- StateEvent)
+	Unsubscribe(ch chan<- StateEvent)
 
 	// 连接检查
 	CheckConnectivity(ctx context.Context) error
@@ -47,6 +64,8 @@ type TailscaleServer interface {
 	GetService(name string) (*Service, bool)
 	ListServices() []string
 	GetServiceStatus(name string) (string, error)
+	GetServiceDetails(name string) (*ServiceDetails, error)
+	ListServiceDetails() []*ServiceDetails
 
 	// 服务生命周期
 	StopAll(ctx context.Context) error
@@ -85,4 +104,6 @@ var (
 	ErrNoTailscaleIP       = fmt.Errorf("no tailscale IP assigned")
 	ErrConnectivityFailed  = fmt.Errorf("connectivity check failed")
 	ErrConfigRequired      = fmt.Errorf("config cannot be nil")
+	ErrUnknownDERPRegion   = fmt.Errorf("unknown DERP region")
+	ErrInvalidOptions      = fmt.Errorf("invalid client options")
 )
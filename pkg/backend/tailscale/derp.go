@@ -0,0 +1,321 @@
+package tailscale
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/netip"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"tailscale.com/net/stun"
+	"tailscale.com/tailcfg"
+)
+
+// derpProbeTimeout bounds the latency probe of a single DERP region
+const derpProbeTimeout = 3 * time.Second
+
+// DERPInfo describes the DERP view of this node
+type DERPInfo struct {
+	PreferredRegionID   int                 `json:"preferred_region_id"`   // Home DERP region, 0 if unknown
+	PreferredRegionCode string              `json:"preferred_region_code"` // Home DERP region code
+	Regions             []DERPRegionLatency `json:"regions"`               // Regions sorted by latency, unreachable last
+	Peers               []PeerPath          `json:"peers"`                 // Data path to each peer
+}
+
+// DERPRegionLatency is the measured latency to a DERP region
+type DERPRegionLatency struct {
+	RegionID   int           `json:"region_id"`
+	RegionCode string        `json:"region_code"`
+	RegionName string        `json:"region_name"`
+	Latency    time.Duration `json:"latency"`         // TCP connect time to the first reachable node
+	Error      string        `json:"error,omitempty"` // Set when no node of the region could be reached
+}
+
+// PeerPath describes how traffic to a peer is carried
+type PeerPath struct {
+	HostName      string    `json:"hostname"`
+	TailscaleIP   string    `json:"tailscale_ip"`
+	Online        bool      `json:"online"`
+	Direct        bool      `json:"direct"`                   // True when a direct UDP path is in use
+	CurAddr       string    `json:"cur_addr,omitempty"`       // Direct endpoint in use
+	Relay         string    `json:"relay,omitempty"`          // Peer home DERP region code
+	PeerRelay     string    `json:"peer_relay,omitempty"`     // Peer relay in use, if any
+	LastHandshake time.Time `json:"last_handshake,omitempty"` // Last WireGuard handshake, zero if none yet
+	LastWrite     time.Time `json:"last_write,omitempty"`     // Last packet sent to the peer, zero if none yet
+}
+
+// StaleHandshake reports whether this node sent traffic to an online peer within
+// threshold but has no handshake with it in that time. WireGuard handshakes on
+// demand and rekeys every two minutes while traffic flows, so a peer being sent
+// to without a recent handshake is not answering. Idle peers are not reported:
+// tailscaled configures peers lazily and an old or missing handshake is normal
+// when there is no traffic.
+func (p PeerPath) StaleHandshake(threshold time.Duration, now time.Time) bool {
+	if !p.Online || p.LastWrite.IsZero() || now.Sub(p.LastWrite) > threshold {
+		return false
+	}
+	return p.LastHandshake.IsZero() || now.Sub(p.LastHandshake) > threshold
+}
+
+// GetDERPInfo returns the preferred DERP region, per-region latencies and
+// whether each peer is reached directly or relayed
+func (c *SimpleClient) GetDERPInfo(ctx context.Context) (*DERPInfo, error) {
+	status, err := c.GetStatus(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get status: %v", err)
+	}
+
+	derpMap, err := c.localClient.CurrentDERPMap(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get DERP map: %v", err)
+	}
+
+	info := &DERPInfo{}
+	if status.Self != nil {
+		info.PreferredRegionCode = status.Self.Relay
+	}
+
+	if derpMap != nil {
+		for id, region := range derpMap.Regions {
+			if region != nil && region.RegionCode == info.PreferredRegionCode && info.PreferredRegionCode != "" {
+				info.PreferredRegionID = id
+			}
+		}
+		info.Regions = probeDERPRegions(ctx, derpMap)
+	}
+
+	for _, peer := range status.Peer {
+		path := PeerPath{
+			HostName:      peer.HostName,
+			Online:        peer.Online,
+			Direct:        peer.CurAddr != "",
+			CurAddr:       peer.CurAddr,
+			Relay:         peer.Relay,
+			PeerRelay:     peer.PeerRelay,
+			LastHandshake: peer.LastHandshake,
+			LastWrite:     peer.LastWrite,
+		}
+		if len(peer.TailscaleIPs) > 0 {
+			path.TailscaleIP = peer.TailscaleIPs[0].String()
+		}
+		info.Peers = append(info.Peers, path)
+	}
+	sort.Slice(info.Peers, func(i, j int) bool { return info.Peers[i].HostName < info.Peers[j].HostName })
+
+	return info, nil
+}
+
+// SetPreferredDERP pins the home DERP region of this node, 0 restores automatic selection.
+// Prefs have no field for the home region, so this uses the force-prefer-derp LocalAPI
+// debug action. The pin is kept in tailscaled memory only and is lost when tailscaled
+// restarts, so callers re-apply it after every login.
+// A region missing from the current DERP map is not pinned and ErrUnknownDERPRegion is returned.
+func (c *SimpleClient) SetPreferredDERP(ctx context.Context, regionID int) error {
+	defer c.invalidateStatus()
+
+	if regionID != 0 {
+		derpMap, err := c.localClient.CurrentDERPMap(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get DERP map: %v", err)
+		}
+		if derpMap == nil || derpMap.Regions[regionID] == nil {
+			return fmt.Errorf("%w %d, known regions: %v", ErrUnknownDERPRegion, regionID, knownDERPRegions(derpMap))
+		}
+	}
+
+	body, err := json.Marshal(regionID)
+	if err != nil {
+		return err
+	}
+	if err := c.localClient.DebugActionBody(ctx, "force-prefer-derp", bytes.NewReader(body)); err != nil {
+		return fmt.Errorf("failed to force preferred DERP region: %v", err)
+	}
+	return nil
+}
+
+// knownDERPRegions returns the sorted IDs of the regions in derpMap
+func knownDERPRegions(derpMap *tailcfg.DERPMap) []int {
+	var ids []int
+	if derpMap != nil {
+		for id, region := range derpMap.Regions {
+			if region != nil {
+				ids = append(ids, id)
+			}
+		}
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// probeDERPRegions measures the latency to every DERP region concurrently
+func probeDERPRegions(ctx context.Context, derpMap *tailcfg.DERPMap) []DERPRegionLatency {
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		results []DERPRegionLatency
+	)
+
+	for id, region := range derpMap.Regions {
+		if region == nil {
+			continue
+		}
+		wg.Add(1)
+		go func(id int, region *tailcfg.DERPRegion) {
+			defer wg.Done()
+
+			result := DERPRegionLatency{
+				RegionID:   id,
+				RegionCode: region.RegionCode,
+				RegionName: region.RegionName,
+			}
+			latency, err := probeDERPRegion(ctx, region)
+			if err != nil {
+				result.Error = err.Error()
+			} else {
+				result.Latency = latency
+			}
+
+			mu.Lock()
+			results = append(results, result)
+			mu.Unlock()
+		}(id, region)
+	}
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if (a.Error == "") != (b.Error == "") {
+			return a.Error == ""
+		}
+		if a.Latency != b.Latency {
+			return a.Latency < b.Latency
+		}
+		return a.RegionID < b.RegionID
+	})
+	return results
+}
+
+// probeDERPRegion returns the TCP connect time to the first reachable DERP node of region
+func probeDERPRegion(ctx context.Context, region *tailcfg.DERPRegion) (time.Duration, error) {
+	probeCtx, cancel := context.WithTimeout(ctx, derpProbeTimeout)
+	defer cancel()
+
+	var lastErr error
+	for _, node := range region.Nodes {
+		if node == nil || node.STUNOnly {
+			continue
+		}
+
+		host := node.HostName
+		if node.IPv4 != "" {
+			host = node.IPv4
+		}
+		port := node.DERPPort
+		if port == 0 {
+			port = 443
+		}
+
+		var dialer net.Dialer
+		start := time.Now()
+		conn, err := dialer.DialContext(probeCtx, "tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		latency := time.Since(start)
+		conn.Close()
+		return latency, nil
+	}
+
+	if lastErr == nil {
+		lastErr = fmt.Errorf("region %s has no DERP nodes", region.RegionCode)
+	}
+	return 0, lastErr
+}
+
+// ProbeSTUN sends a STUN binding request to the DERP nodes of the current DERP map,
+// preferred region first, and returns the public address reported by the first node
+// that answers. It checks that outbound UDP leaves this host, which tailscaled needs
+// for NAT traversal and direct WireGuard paths.
+func (c *SimpleClient) ProbeSTUN(ctx context.Context) (netip.AddrPort, error) {
+	derpMap, err := c.localClient.CurrentDERPMap(ctx)
+	if err != nil {
+		return netip.AddrPort{}, fmt.Errorf("failed to get DERP map: %v", err)
+	}
+	if derpMap == nil || len(derpMap.Regions) == 0 {
+		return netip.AddrPort{}, fmt.Errorf("DERP map is empty")
+	}
+
+	preferred := 0
+	if status, err := c.GetStatus(ctx); err == nil && status.Self != nil {
+		for id, region := range derpMap.Regions {
+			if region != nil && region.RegionCode == status.Self.Relay {
+				preferred = id
+			}
+		}
+	}
+	ids := knownDERPRegions(derpMap)
+	sort.SliceStable(ids, func(i, j int) bool { return ids[i] == preferred && ids[j] != preferred })
+
+	lastErr := fmt.Errorf("no DERP node serves STUN")
+	for _, id := range ids {
+		for _, node := range derpMap.Regions[id].Nodes {
+			if node == nil || node.STUNPort < 0 {
+				continue
+			}
+			addr, err := probeSTUNNode(ctx, node)
+			if err == nil {
+				return addr, nil
+			}
+			lastErr = err
+		}
+	}
+	return netip.AddrPort{}, lastErr
+}
+
+// probeSTUNNode sends one STUN binding request to node and waits for the response
+func probeSTUNNode(ctx context.Context, node *tailcfg.DERPNode) (netip.AddrPort, error) {
+	host := node.HostName
+	if node.IPv4 != "" {
+		host = node.IPv4
+	}
+	port := node.STUNPort
+	if port == 0 {
+		port = 3478
+	}
+	target := net.JoinHostPort(host, strconv.Itoa(port))
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp4", target)
+	if err != nil {
+		return netip.AddrPort{}, fmt.Errorf("unable to reach STUN server %s: %v", target, err)
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(derpProbeTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+
+	txID := stun.NewTxID()
+	if _, err := conn.Write(stun.Request(txID)); err != nil {
+		return netip.AddrPort{}, fmt.Errorf("failed to send STUN request to %s: %v", target, err)
+	}
+	buf := make([]byte, 1024)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return netip.AddrPort{}, fmt.Errorf("no STUN response from %s: %v", target, err)
+		}
+		gotID, addr, err := stun.ParseResponse(buf[:n])
+		if err == nil && gotID == txID {
+			return addr, nil
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package tailscale
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStaleHandshake(t *testing.T) {
+	now := time.Now()
+	threshold := 5 * time.Minute
+	recent := now.Add(-time.Minute)
+	old := now.Add(-time.Hour)
+
+	for _, tc := range []struct {
+		name string
+		peer PeerPath
+		want bool
+	}{
+		{"idle peer without a handshake", PeerPath{Online: true}, false},
+		{"idle peer with an old handshake", PeerPath{Online: true, LastHandshake: old, LastWrite: old}, false},
+		{"active peer with a recent handshake", PeerPath{Online: true, LastHandshake: recent, LastWrite: recent}, false},
+		{"sent to without any handshake", PeerPath{Online: true, LastWrite: recent}, true},
+		{"sent to with an old handshake", PeerPath{Online: true, LastHandshake: old, LastWrite: recent}, true},
+		{"offline peer", PeerPath{LastWrite: recent}, false},
+	} {
+		if got := tc.peer.StaleHandshake(threshold, now); got != tc.want {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package tailscale
+
+import (
+	"context"
+	"time"
+)
+
+// StateEvent describes a backend state transition observed by SimpleClient
+type StateEvent struct {
+	From   string    // Previous backend state, empty on the first observation
+	To     string    // New backend state
+	Time   time.Time // When the transition was observed
+	Reason string    // Operation that was running when the transition was observed
+}
+
+// stateReasonKey context key carrying the reason attached to state events
+type stateReasonKey struct{}
+
+// withStateReason attaches the operation name to state events emitted under ctx
+func withStateReason(ctx context.Context, reason string) context.Context {
+	return context.WithValue(ctx, stateReasonKey{}, reason)
+}
+
+// stateReason returns the operation name attached to ctx
+func stateReason(ctx context.Context) string {
+	if reason, ok := ctx.Value(stateReasonKey{}).(string); ok {
+		return reason
+	}
+	return "status poll"
+}
+
+// Subscribe registers ch to receive state events
+// Events are delivered without blocking: if ch is full the event is dropped for that subscriber
+func (c *SimpleClient) Subscribe(ch chan<- StateEvent) {
+	c.eventsMu.Lock()
+	defer c.eventsMu.Unlock()
+
+	c.subscribers = append(c.subscribers, ch)
+}
+
+// Unsubscribe stops delivering state events to ch
+func (c *SimpleClient) Unsubscribe(ch chan<- StateEvent) {
+	c.eventsMu.Lock()
+	defer c.eventsMu.Unlock()
+
+	for i, sub := range c.subscribers {
+		if sub == ch {
+			c.subscribers = append(c.subscribers[:i], c.subscribers[i+1:]...)
+			return
+		}
+	}
+}
+
+// observeState records the backend state and emits an event when it changed
+func (c *SimpleClient) observeState(ctx context.Context, state string) {
+	c.eventsMu.Lock()
+	defer c.eventsMu.Unlock()
+
+	if state == "" || state == c.lastState {
+		return
+	}
+
+	event := StateEvent{
+		From:   c.lastState,
+		To:     state,
+		Time:   time.Now(),
+		Reason: stateReason(ctx),
+	}
+	c.lastState = state
+
+	for _, sub := range c.subscribers {
+		select {
+		case sub <- event:
+		default:
+		}
+	}
+}
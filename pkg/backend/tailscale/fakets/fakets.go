@@ -0,0 +1,556 @@
+// Package fakets provides an in-memory tailscale.TailscaleClient for unit tests.
+//
+// The fake keeps a Status and Prefs that the client methods read and edit the
+// same way tailscaled would, and records every call so tests can assert the
+// sequence of operations without a real tailnet.
+package fakets
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/binrclab/headcni/pkg/backend/tailscale"
+	"tailscale.com/ipn"
+	"tailscale.com/ipn/ipnstate"
+	"tailscale.com/types/key"
+)
+
+var _ tailscale.TailscaleClient = (*Client)(nil)
+
+// Client is a fake tailscale.TailscaleClient backed by in-memory state
+type Client struct {
+	mu sync.Mutex
+
+	status   *ipnstate.Status
+	prefs    *ipn.Prefs
+	localIP  netip.Addr
+	peerTags map[string][]string
+	errors   map[string]error
+	hangs    map[string]chan struct{}
+	calls    []string
+
+	derpRegions   map[int]bool
+	preferredDERP int
+
+	subscribers []chan<- tailscale.StateEvent
+}
+
+// New returns a fake client that is Running with the given tailnet IP and a fresh node key
+func New(ip netip.Addr) *Client {
+	return &Client{
+		status: &ipnstate.Status{
+			BackendState: tailscale.BackendStateRunning,
+			HaveNodeKey:  true,
+			Self: &ipnstate.PeerStatus{
+				PublicKey:    key.NewNode().Public(),
+				TailscaleIPs: []netip.Addr{ip},
+				Online:       true,
+			},
+		},
+		prefs:    ipn.NewPrefs(),
+		peerTags: make(map[string][]string),
+		errors:   make(map[string]error),
+		hangs:    make(map[string]chan struct{}),
+	}
+}
+
+// Calls returns the names of the methods called so far, in order
+func (c *Client) Calls() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.calls...)
+}
+
+// ResetCalls clears the recorded calls
+func (c *Client) ResetCalls() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = nil
+}
+
+// SetError makes the named method return err, a nil err clears it
+func (c *Client) SetError(method string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err == nil {
+		delete(c.errors, method)
+		return
+	}
+	c.errors[method] = err
+}
+
+// SetBackendState sets the backend state and emits a state event to subscribers
+func (c *Client) SetBackendState(state string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setStateLocked(state, "fake")
+}
+
+// SetLocalIP sets the address returned by GetLocalIP
+func (c *Client) SetLocalIP(ip netip.Addr) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.localIP = ip
+}
+
+// SetPeerTags sets the tags returned by ResolvePeerTags for ip
+func (c *Client) SetPeerTags(ip string, tags []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.peerTags[ip] = tags
+}
+
+// AddPeer adds or replaces a peer returned by GetPeers, keyed by its public key
+func (c *Client) AddPeer(peer *ipnstate.PeerStatus) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.status.Peer == nil {
+		c.status.Peer = make(map[key.NodePublic]*ipnstate.PeerStatus)
+	}
+	c.status.Peer[peer.PublicKey] = peer
+}
+
+// SetKeyExpiry sets the node key expiry reported in Self, a zero expiry clears it
+func (c *Client) SetKeyExpiry(expiry time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if expiry.IsZero() {
+		c.status.Self.KeyExpiry = nil
+		return
+	}
+	c.status.Self.KeyExpiry = &expiry
+}
+
+// SetDERPRegions sets the regions of the DERP map, SetPreferredDERP rejects other regions
+// Until it is called every region is accepted
+func (c *Client) SetDERPRegions(ids ...int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.derpRegions = make(map[int]bool, len(ids))
+	for _, id := range ids {
+		c.derpRegions[id] = true
+	}
+}
+
+// PreferredDERP returns the pinned DERP home region, 0 when none is pinned
+func (c *Client) PreferredDERP() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.preferredDERP
+}
+
+// AdvertisedRoutes returns the currently advertised routes
+func (c *Client) AdvertisedRoutes() []netip.Prefix {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]netip.Prefix(nil), c.prefs.AdvertiseRoutes...)
+}
+
+// record appends the call and returns the error configured for it
+func (c *Client) record(method string) error {
+	c.calls = append(c.calls, method)
+	return c.errors[method]
+}
+
+// setStateLocked updates the backend state and notifies subscribers without blocking
+func (c *Client) setStateLocked(state, reason string) {
+	from := c.status.BackendState
+	if from == state {
+		return
+	}
+	c.status.BackendState = state
+
+	event := tailscale.StateEvent{From: from, To: state, Time: time.Now(), Reason: reason}
+	for _, ch := range c.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// login applies options as tailscaled would on a successful login
+// A node that was logged out gets a new node key
+func (c *Client) login(options tailscale.ClientOptions) error {
+	if options.AuthKey == "" {
+		return fmt.Errorf("authentication key cannot be empty")
+	}
+	if options.ControlURL != "" {
+		c.prefs.ControlURL = options.ControlURL
+	}
+	if options.Hostname != "" {
+		c.prefs.Hostname = options.Hostname
+	}
+	c.prefs.CorpDNS = options.AcceptDNS
+	c.prefs.RouteAll = options.AcceptRoutes
+	c.prefs.ShieldsUp = options.ShieldsUp
+	// Like tailscaled, routes given at login replace the advertised routes
+	if len(options.AdvertiseRoutes) > 0 {
+		routes := make([]netip.Prefix, 0, len(options.AdvertiseRoutes))
+		for _, route := range options.AdvertiseRoutes {
+			prefix, err := netip.ParsePrefix(route)
+			if err != nil {
+				return fmt.Errorf("invalid route format '%s': %v", route, err)
+			}
+			routes = append(routes, prefix)
+		}
+		c.prefs.AdvertiseRoutes = routes
+	}
+
+	if c.prefs.LoggedOut || !c.status.HaveNodeKey {
+		c.status.Self.PublicKey = key.NewNode().Public()
+		c.status.Self.KeyExpiry = nil
+	}
+	c.prefs.LoggedOut = false
+	c.prefs.WantRunning = true
+	c.status.HaveNodeKey = true
+	c.setStateLocked(tailscale.BackendStateRunning, "up")
+	return nil
+}
+
+// GetStatus returns a copy of the current status
+func (c *Client) GetStatus(ctx context.Context) (*ipnstate.Status, error) {
+	return c.getStatus("GetStatus")
+}
+
+// GetStatusFresh returns a copy of the current status; the fake never caches
+func (c *Client) GetStatusFresh(ctx context.Context) (*ipnstate.Status, error) {
+	return c.getStatus("GetStatusFresh")
+}
+
+// Hang makes the named status method block, ignoring its context, until release is called
+func (c *Client) Hang(method string) (release func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan struct{})
+	c.hangs[method] = ch
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			c.mu.Lock()
+			if c.hangs[method] == ch {
+				delete(c.hangs, method)
+			}
+			c.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// getStatus records the call as method and returns a copy of the current status
+func (c *Client) getStatus(method string) (*ipnstate.Status, error) {
+	c.mu.Lock()
+	hang := c.hangs[method]
+	c.mu.Unlock()
+	if hang != nil {
+		<-hang
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.record(method); err != nil {
+		return nil, err
+	}
+
+	status := *c.status
+	if c.status.Self != nil {
+		self := *c.status.Self
+		status.Self = &self
+	}
+	return &status, nil
+}
+
+// GetIP returns the first tailnet IP of this node
+func (c *Client) GetIP(ctx context.Context) (netip.Addr, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.record("GetIP"); err != nil {
+		return netip.Addr{}, err
+	}
+	if c.status.Self == nil || len(c.status.Self.TailscaleIPs) == 0 {
+		return netip.Addr{}, tailscale.ErrNoTailscaleIP
+	}
+	return c.status.Self.TailscaleIPs[0], nil
+}
+
+// GetLocalIP returns the address set with SetLocalIP
+func (c *Client) GetLocalIP(ctx context.Context) (netip.Addr, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.record("GetLocalIP"); err != nil {
+		return netip.Addr{}, err
+	}
+	if !c.localIP.IsValid() {
+		return netip.Addr{}, fmt.Errorf("no local IP")
+	}
+	return c.localIP, nil
+}
+
+// IsConnected reports whether the backend is Running
+func (c *Client) IsConnected(ctx context.Context) bool {
+	return c.IsRunning(ctx)
+}
+
+// IsRunning reports whether the backend is Running
+func (c *Client) IsRunning(ctx context.Context) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.record("IsRunning")
+	return c.status.BackendState == tailscale.BackendStateRunning
+}
+
+// Up logs in with authKey
+func (c *Client) Up(ctx context.Context, authKey string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.record("Up"); err != nil {
+		return err
+	}
+	return c.login(tailscale.ClientOptions{AuthKey: authKey})
+}
+
+// UpWithOptions logs in with options
+func (c *Client) UpWithOptions(ctx context.Context, options tailscale.ClientOptions) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.record("UpWithOptions"); err != nil {
+		return err
+	}
+	if err := c.login(options); err != nil {
+		return err
+	}
+	// Like SimpleClient, an unknown region does not fail the login
+	if options.PreferredDERP != 0 {
+		c.setPreferredDERPLocked(options.PreferredDERP)
+	}
+	return nil
+}
+
+// ForceLogin logs out and logs in again with options
+func (c *Client) ForceLogin(ctx context.Context, options tailscale.ClientOptions) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.record("ForceLogin"); err != nil {
+		return err
+	}
+	c.prefs.LoggedOut = true
+	return c.login(options)
+}
+
+// Logout discards the node key
+func (c *Client) Logout(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.record("Logout"); err != nil {
+		return err
+	}
+	c.prefs.LoggedOut = true
+	c.prefs.WantRunning = false
+	c.status.HaveNodeKey = false
+	c.setStateLocked(tailscale.BackendStateNeedsLogin, "logout")
+	return nil
+}
+
+// Down stops the backend without logging out
+func (c *Client) Down(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.record("Down"); err != nil {
+		return err
+	}
+	c.prefs.WantRunning = false
+	c.setStateLocked(tailscale.BackendStateStopped, "down")
+	return nil
+}
+
+// AdvertiseRoutes replaces the advertised routes
+func (c *Client) AdvertiseRoutes(ctx context.Context, routes ...netip.Prefix) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.record("AdvertiseRoutes"); err != nil {
+		return err
+	}
+	c.prefs.AdvertiseRoutes = append([]netip.Prefix(nil), routes...)
+	return nil
+}
+
+// AdvertiseRoute replaces the advertised routes with the parsed routes
+func (c *Client) AdvertiseRoute(ctx context.Context, routes ...string) error {
+	var prefixes []netip.Prefix
+	for _, route := range routes {
+		if route == "" {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(route)
+		if err != nil {
+			return fmt.Errorf("invalid route %s: %v", route, err)
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	return c.AdvertiseRoutes(ctx, prefixes...)
+}
+
+// RemoveRoutes stops advertising routes
+func (c *Client) RemoveRoutes(ctx context.Context, routes ...netip.Prefix) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.record("RemoveRoutes"); err != nil {
+		return err
+	}
+
+	toRemove := make(map[netip.Prefix]bool, len(routes))
+	for _, route := range routes {
+		toRemove[route] = true
+	}
+	var kept []netip.Prefix
+	for _, route := range c.prefs.AdvertiseRoutes {
+		if !toRemove[route] {
+			kept = append(kept, route)
+		}
+	}
+	c.prefs.AdvertiseRoutes = kept
+	return nil
+}
+
+// AcceptRoutes accepts routes from other nodes
+func (c *Client) AcceptRoutes(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.record("AcceptRoutes"); err != nil {
+		return err
+	}
+	c.prefs.RouteAll = true
+	return nil
+}
+
+// Ping records the call and returns the configured error
+func (c *Client) Ping(ctx context.Context, target string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.record("Ping")
+}
+
+// GetPeers returns the peers of the current status keyed by node key
+func (c *Client) GetPeers(ctx context.Context) (map[string]*ipnstate.PeerStatus, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.record("GetPeers"); err != nil {
+		return nil, err
+	}
+
+	peers := make(map[string]*ipnstate.PeerStatus, len(c.status.Peer))
+	for nodeKey, peer := range c.status.Peer {
+		peers[nodeKey.String()] = peer
+	}
+	return peers, nil
+}
+
+// ResolvePeerTags returns the tags set with SetPeerTags
+func (c *Client) ResolvePeerTags(ctx context.Context, ip string) ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.record("ResolvePeerTags"); err != nil {
+		return nil, err
+	}
+	return append([]string(nil), c.peerTags[ip]...), nil
+}
+
+// GetPrefs returns a copy of the current preferences
+func (c *Client) GetPrefs(ctx context.Context) (*ipn.Prefs, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.record("GetPrefs"); err != nil {
+		return nil, err
+	}
+	return c.prefs.Clone(), nil
+}
+
+// SetHostname sets the hostname preference
+func (c *Client) SetHostname(ctx context.Context, hostname string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.record("SetHostname"); err != nil {
+		return err
+	}
+	c.prefs.Hostname = hostname
+	return nil
+}
+
+// SetAcceptDNS sets the DNS preference
+func (c *Client) SetAcceptDNS(ctx context.Context, accept bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.record("SetAcceptDNS"); err != nil {
+		return err
+	}
+	c.prefs.CorpDNS = accept
+	return nil
+}
+
+// SetPreferredDERP pins the DERP home region, 0 restores automatic selection
+func (c *Client) SetPreferredDERP(ctx context.Context, regionID int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.record("SetPreferredDERP"); err != nil {
+		return err
+	}
+	return c.setPreferredDERPLocked(regionID)
+}
+
+// setPreferredDERPLocked pins regionID unless the DERP map does not know it
+func (c *Client) setPreferredDERPLocked(regionID int) error {
+	if regionID != 0 && c.derpRegions != nil && !c.derpRegions[regionID] {
+		return fmt.Errorf("%w %d", tailscale.ErrUnknownDERPRegion, regionID)
+	}
+	c.preferredDERP = regionID
+	return nil
+}
+
+// SetStatusCacheTTL records the call, the fake never caches
+func (c *Client) SetStatusCacheTTL(ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.record("SetStatusCacheTTL")
+}
+
+// SetTimeout records the call, the fake never blocks
+func (c *Client) SetTimeout(timeout time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.record("SetTimeout")
+}
+
+// CheckConnectivity fails unless the backend is Running
+func (c *Client) CheckConnectivity(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.record("CheckConnectivity"); err != nil {
+		return err
+	}
+	if c.status.BackendState != tailscale.BackendStateRunning {
+		return tailscale.ErrTailscaleNotRunning
+	}
+	return nil
+}
+
+// Subscribe registers ch to receive state events
+func (c *Client) Subscribe(ch chan<- tailscale.StateEvent) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.subscribers = append(c.subscribers, ch)
+}
+
+// Unsubscribe stops delivering state events to ch
+func (c *Client) Unsubscribe(ch chan<- tailscale.StateEvent) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, sub := range c.subscribers {
+		if sub == ch {
+			c.subscribers = append(c.subscribers[:i], c.subscribers[i+1:]...)
+			return
+		}
+	}
+}
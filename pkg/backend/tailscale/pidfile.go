@@ -0,0 +1,175 @@
+package tailscale
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/binrclab/headcni/pkg/utils/fsutil"
+)
+
+// procRoot /proc 挂载点，测试中替换为模拟目录
+var procRoot = "/proc"
+
+// tailscaled PID 文件内容为 "<pid> <starttime>"，starttime 是 /proc/<pid>/stat 第 22 列（开机以来的时钟滴答数）
+// daemon 容器重启后 PID 可能已被无关进程复用，只有 PID、进程名、--socket 参数和启动时间都一致才认为是我们启动的 tailscaled
+
+// pidFileRecord PID 文件记录的进程
+type pidFileRecord struct {
+	PID       int
+	StartTime uint64 // 为 0 表示旧格式的 PID 文件，没有记录启动时间
+}
+
+// writePIDFile 原子写入 pid 及其启动时间
+func writePIDFile(path string, pid int) error {
+	startTime, err := procStartTime(pid)
+	if err != nil {
+		return err
+	}
+	return fsutil.WriteFileAtomic(path, []byte(fmt.Sprintf("%d %d\n", pid, startTime)), 0644)
+}
+
+// readPIDFile 解析 PID 文件，兼容只有 PID 的旧格式
+func readPIDFile(path string) (pidFileRecord, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return pidFileRecord{}, err
+	}
+
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 || len(fields) > 2 {
+		return pidFileRecord{}, fmt.Errorf("malformed PID file %q", strings.TrimSpace(string(data)))
+	}
+	var record pidFileRecord
+	if record.PID, err = strconv.Atoi(fields[0]); err != nil || record.PID <= 0 {
+		return pidFileRecord{}, fmt.Errorf("invalid PID %q", fields[0])
+	}
+	if len(fields) == 2 {
+		if record.StartTime, err = strconv.ParseUint(fields[1], 10, 64); err != nil {
+			return pidFileRecord{}, fmt.Errorf("invalid start time %q", fields[1])
+		}
+	}
+	return record, nil
+}
+
+// procStartTime 读取进程的启动时间
+func procStartTime(pid int) (uint64, error) {
+	data, err := os.ReadFile(filepath.Join(procRoot, strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return 0, fmt.Errorf("process %d not found: %v", pid, err)
+	}
+	// 进程名可能包含空格和括号，从最后一个 ')' 之后开始按列切分，此后第一列是第 3 列（state）
+	end := bytes.LastIndexByte(data, ')')
+	if end < 0 {
+		return 0, fmt.Errorf("malformed stat of process %d", pid)
+	}
+	fields := strings.Fields(string(data[end+1:]))
+	if len(fields) < 20 {
+		return 0, fmt.Errorf("malformed stat of process %d", pid)
+	}
+	return strconv.ParseUint(fields[19], 10, 64)
+}
+
+// procHasSocketArg 检查进程命令行中的 --socket 是否为 socketPath
+func procHasSocketArg(pid int, socketPath string) (bool, error) {
+	data, err := os.ReadFile(filepath.Join(procRoot, strconv.Itoa(pid), "cmdline"))
+	if err != nil {
+		return false, err
+	}
+	args := strings.Split(strings.TrimRight(string(data), "\x00"), "\x00")
+	for i, arg := range args {
+		switch {
+		case (arg == "--socket" || arg == "-socket") && i+1 < len(args):
+			if args[i+1] == socketPath {
+				return true, nil
+			}
+		case arg == "--socket="+socketPath || arg == "-socket="+socketPath:
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// validatePIDFile 确认 PID 文件指向以 socketPath 运行的 tailscaled，返回其 PID；不一致时返回原因
+// 旧格式的 PID 文件没有启动时间，进程名和 --socket 参数一致时仍然接受
+func validatePIDFile(pidFile, socketPath string) (int, error) {
+	record, err := readPIDFile(pidFile)
+	if err != nil {
+		return 0, err
+	}
+
+	startTime, err := procStartTime(record.PID)
+	if err != nil {
+		return 0, err
+	}
+	if record.StartTime != 0 && record.StartTime != startTime {
+		return 0, fmt.Errorf("PID %d was reused (start time %d, recorded %d)", record.PID, startTime, record.StartTime)
+	}
+
+	comm, err := os.ReadFile(filepath.Join(procRoot, strconv.Itoa(record.PID), "comm"))
+	if err != nil {
+		return 0, fmt.Errorf("process %d not found: %v", record.PID, err)
+	}
+	if name := strings.TrimSpace(string(comm)); name != "tailscaled" {
+		return 0, fmt.Errorf("PID %d is %s, not tailscaled", record.PID, name)
+	}
+
+	ok, err := procHasSocketArg(record.PID, socketPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read command line of process %d: %v", record.PID, err)
+	}
+	if !ok {
+		return 0, fmt.Errorf("tailscaled PID %d is not running with --socket %s", record.PID, socketPath)
+	}
+	return record.PID, nil
+}
+
+// FindTailscaled 在 /proc 中查找以 socketPath 运行的 tailscaled 进程，返回其 PID；没有时返回错误
+// 用于 PID 文件丢失时识别仍在运行（或刚启动、尚未创建 socket）的 tailscaled
+func FindTailscaled(socketPath string) (int, error) {
+	comms, err := filepath.Glob(filepath.Join(procRoot, "[0-9]*", "comm"))
+	if err != nil {
+		return 0, err
+	}
+	for _, commPath := range comms {
+		comm, err := os.ReadFile(commPath)
+		if err != nil || strings.TrimSpace(string(comm)) != "tailscaled" {
+			continue
+		}
+		pid, err := strconv.Atoi(filepath.Base(filepath.Dir(commPath)))
+		if err != nil {
+			continue
+		}
+		if ok, err := procHasSocketArg(pid, socketPath); err == nil && ok {
+			return pid, nil
+		}
+	}
+	return 0, fmt.Errorf("no tailscaled running with --socket %s", socketPath)
+}
+
+// AdoptTailscaled 为 FindTailscaled 找到的进程写入 PID 文件，之后的 StartService 继承该进程而不是再启动一个
+func AdoptTailscaled(pidFile string, pid int) error {
+	return writePIDFile(pidFile, pid)
+}
+
+// cleanupStalePIDFile 删除不指向本服务 tailscaled 的 PID 文件，返回 PID 文件是否仍然有效
+// 在决定继承还是重新启动 tailscaled 之前调用，避免把复用了 PID 的无关进程当作 tailscaled 管理
+func (s *Service) cleanupStalePIDFile(pidFile string) bool {
+	if _, err := os.Stat(pidFile); os.IsNotExist(err) {
+		return false
+	}
+
+	if _, err := validatePIDFile(pidFile, s.SocketPath); err != nil {
+		if s.Options.Logf != nil {
+			s.Options.Logf("删除失效的 PID 文件 %s: %v", pidFile, err)
+		}
+		if err := os.Remove(pidFile); err != nil && !os.IsNotExist(err) && s.Options.Logf != nil {
+			s.Options.Logf("警告：无法删除 PID 文件: %v", err)
+		}
+		return false
+	}
+	return true
+}
@@ -0,0 +1,157 @@
+package tailscale
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeProc 在 procRoot 下模拟 /proc/<pid> 的 stat、comm 和 cmdline
+func fakeProc(t *testing.T, pid int, comm string, startTime uint64, args ...string) {
+	t.Helper()
+	dir := filepath.Join(procRoot, fmt.Sprintf("%d", pid))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	// 进程名带空格和括号，确认按最后一个 ')' 切分
+	stat := fmt.Sprintf("%d (%s) S 1 %d %d 0 -1 4194560 100 0 0 0 1 2 0 0 20 0 12 0 %d 123456 789\n", pid, comm+" (x)", pid, pid, startTime)
+	files := map[string]string{
+		"stat":    stat,
+		"comm":    comm + "\n",
+		"cmdline": strings.Join(args, "\x00") + "\x00",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCleanupStalePIDFile(t *testing.T) {
+	const socket = "/var/run/headcni/tailscale/headcni01.sock"
+
+	tests := []struct {
+		name      string
+		pidFile   string
+		comm      string
+		args      []string
+		wantValid bool
+	}{
+		{
+			name:      "our tailscaled",
+			pidFile:   "4242 1000",
+			comm:      "tailscaled",
+			args:      []string{"tailscaled", "--state", "/var/lib/headcni/tailscaled.state", "--socket", socket},
+			wantValid: true,
+		},
+		{
+			name:      "legacy PID file for our tailscaled",
+			pidFile:   "4242",
+			comm:      "tailscaled",
+			args:      []string{"tailscaled", "--socket=" + socket},
+			wantValid: true,
+		},
+		{
+			name:    "PID reused by another tailscaled",
+			pidFile: "4242 900",
+			comm:    "tailscaled",
+			args:    []string{"tailscaled", "--socket", socket},
+		},
+		{
+			name:    "PID reused by an unrelated process",
+			pidFile: "4242",
+			comm:    "nginx",
+			args:    []string{"nginx", "-g", "daemon off;"},
+		},
+		{
+			name:    "system tailscaled",
+			pidFile: "4242",
+			comm:    "tailscaled",
+			args:    []string{"tailscaled", "--socket", "/var/run/tailscale/tailscaled.sock"},
+		},
+		{
+			name:    "process gone",
+			pidFile: "5151 1000",
+			comm:    "tailscaled",
+			args:    []string{"tailscaled", "--socket", socket},
+		},
+		{
+			name:    "garbage",
+			pidFile: "not-a-pid",
+			comm:    "tailscaled",
+			args:    []string{"tailscaled", "--socket", socket},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oldProcRoot := procRoot
+			procRoot = t.TempDir()
+			t.Cleanup(func() { procRoot = oldProcRoot })
+			fakeProc(t, 4242, tt.comm, 1000, tt.args...)
+
+			pidFile := filepath.Join(t.TempDir(), "tailscaled.pid")
+			if err := os.WriteFile(pidFile, []byte(tt.pidFile+"\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			s := &Service{SocketPath: socket}
+			if got := s.cleanupStalePIDFile(pidFile); got != tt.wantValid {
+				t.Fatalf("cleanupStalePIDFile() = %v, want %v", got, tt.wantValid)
+			}
+			_, err := os.Stat(pidFile)
+			if exists := err == nil; exists != tt.wantValid {
+				t.Fatalf("PID file exists = %v, want %v", exists, tt.wantValid)
+			}
+		})
+	}
+}
+
+func TestWritePIDFileRecordsStartTime(t *testing.T) {
+	oldProcRoot := procRoot
+	procRoot = t.TempDir()
+	t.Cleanup(func() { procRoot = oldProcRoot })
+	fakeProc(t, 4242, "tailscaled", 31337, "tailscaled")
+
+	pidFile := filepath.Join(t.TempDir(), "tailscaled.pid")
+	if err := writePIDFile(pidFile, 4242); err != nil {
+		t.Fatalf("writePIDFile failed: %v", err)
+	}
+	record, err := readPIDFile(pidFile)
+	if err != nil {
+		t.Fatalf("readPIDFile failed: %v", err)
+	}
+	if record.PID != 4242 || record.StartTime != 31337 {
+		t.Fatalf("unexpected record %+v", record)
+	}
+}
+
+func TestFindTailscaledBySocket(t *testing.T) {
+	const socket = "/var/run/headcni/tailscale/headcni01.sock"
+	oldProcRoot := procRoot
+	procRoot = t.TempDir()
+	t.Cleanup(func() { procRoot = oldProcRoot })
+
+	// 主机 tailscaled 和名字里带 tailscaled 的其他进程都不算
+	fakeProc(t, 100, "tailscaled", 1000, "tailscaled", "--socket", "/var/run/tailscale/tailscaled.sock")
+	fakeProc(t, 200, "sh", 1000, "sh", "-c", "tailscaled --socket "+socket)
+	if _, err := FindTailscaled(socket); err == nil {
+		t.Fatal("expected no tailscaled for our socket")
+	}
+
+	fakeProc(t, 4242, "tailscaled", 31337, "tailscaled", "--state", "/var/lib/headcni/tailscaled.state", "--socket="+socket)
+	pid, err := FindTailscaled(socket)
+	if err != nil || pid != 4242 {
+		t.Fatalf("FindTailscaled() = %d, %v, want 4242", pid, err)
+	}
+
+	// 接管后 PID 文件通过校验
+	pidFile := filepath.Join(t.TempDir(), "tailscaled.pid")
+	if err := AdoptTailscaled(pidFile, pid); err != nil {
+		t.Fatalf("AdoptTailscaled failed: %v", err)
+	}
+	if got, err := validatePIDFile(pidFile, socket); err != nil || got != 4242 {
+		t.Fatalf("validatePIDFile() = %d, %v, want 4242", got, err)
+	}
+}
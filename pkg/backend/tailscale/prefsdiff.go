@@ -0,0 +1,96 @@
+package tailscale
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/binrclab/headcni/pkg/logging"
+	"go.uber.org/zap/zapcore"
+	"tailscale.com/ipn"
+)
+
+// prefsLogger logs the prefs audit trail, enabled with HEADCNI_LOG_LEVELS=prefs=debug or a global debug level
+var prefsLogger = logging.Named("prefs")
+
+// PrefsChange is a single prefs field changed by an EditPrefs call
+type PrefsChange struct {
+	Field string
+	Old   string
+	New   string
+}
+
+// String formats the change as field: old -> new
+func (c PrefsChange) String() string {
+	return fmt.Sprintf("%s: %s -> %s", c.Field, c.Old, c.New)
+}
+
+// diffPrefs returns the audited fields that differ between before and after
+func diffPrefs(before, after *ipn.Prefs) []PrefsChange {
+	var changes []PrefsChange
+	addString := func(field, old, new string) {
+		if old != new {
+			changes = append(changes, PrefsChange{Field: field, Old: fmt.Sprintf("%q", old), New: fmt.Sprintf("%q", new)})
+		}
+	}
+	addBool := func(field string, old, new bool) {
+		if old != new {
+			changes = append(changes, PrefsChange{Field: field, Old: fmt.Sprint(old), New: fmt.Sprint(new)})
+		}
+	}
+
+	addString("ControlURL", before.ControlURL, after.ControlURL)
+	addString("Hostname", before.Hostname, after.Hostname)
+	addBool("RouteAll", before.RouteAll, after.RouteAll)
+	if !slices.Equal(before.AdvertiseRoutes, after.AdvertiseRoutes) {
+		changes = append(changes, PrefsChange{Field: "AdvertiseRoutes", Old: formatRoutes(before.AdvertiseRoutes), New: formatRoutes(after.AdvertiseRoutes)})
+	}
+	addBool("CorpDNS", before.CorpDNS, after.CorpDNS)
+	addBool("ShieldsUp", before.ShieldsUp, after.ShieldsUp)
+	addBool("WantRunning", before.WantRunning, after.WantRunning)
+	addBool("LoggedOut", before.LoggedOut, after.LoggedOut)
+	return changes
+}
+
+// formatRoutes formats a route list as [a b c]
+func formatRoutes(routes []netip.Prefix) string {
+	parts := make([]string, len(routes))
+	for i, route := range routes {
+		parts[i] = route.String()
+	}
+	return "[" + strings.Join(parts, " ") + "]"
+}
+
+// editPrefs applies maskedPrefs through the LocalAPI, logging the resulting prefs diff at Debug level first
+// The current prefs are only fetched when the prefs logger is at Debug level, and a failure to fetch them
+// never blocks the edit, so the call behaves exactly like EditPrefs
+func (c *SimpleClient) editPrefs(ctx context.Context, op string, maskedPrefs *ipn.MaskedPrefs) (*ipn.Prefs, error) {
+	if prefsLogger.Enabled(zapcore.DebugLevel) {
+		c.logPrefsDiff(ctx, op, maskedPrefs)
+	}
+	return c.localClient.EditPrefs(ctx, maskedPrefs)
+}
+
+// logPrefsDiff logs which audited fields maskedPrefs would change relative to the current prefs
+func (c *SimpleClient) logPrefsDiff(ctx context.Context, op string, maskedPrefs *ipn.MaskedPrefs) {
+	current, err := c.localClient.GetPrefs(ctx)
+	if err != nil {
+		prefsLogger.Debugf("EditPrefs (%s): unable to fetch current prefs for diff: %v", op, err)
+		return
+	}
+
+	after := current.Clone()
+	after.ApplyEdits(maskedPrefs)
+	changes := diffPrefs(current, after)
+	if len(changes) == 0 {
+		prefsLogger.Debugf("EditPrefs (%s): no audited prefs change", op)
+		return
+	}
+	lines := make([]string, len(changes))
+	for i, change := range changes {
+		lines[i] = change.String()
+	}
+	prefsLogger.Debugf("EditPrefs (%s): %s", op, strings.Join(lines, ", "))
+}
@@ -0,0 +1,41 @@
+package tailscale
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+
+	"tailscale.com/ipn"
+)
+
+func TestDiffPrefs(t *testing.T) {
+	before := ipn.NewPrefs()
+	before.ControlURL = "https://headscale.example.com"
+	before.Hostname = "node-1"
+	before.WantRunning = true
+
+	after := before.Clone()
+	after.ApplyEdits(&ipn.MaskedPrefs{
+		Prefs: ipn.Prefs{
+			Hostname:        "k8s-node-1",
+			AdvertiseRoutes: []netip.Prefix{netip.MustParsePrefix("10.42.1.0/24")},
+			CorpDNS:         before.CorpDNS,
+		},
+		HostnameSet:        true,
+		AdvertiseRoutesSet: true,
+		CorpDNSSet:         true,
+	})
+
+	got := diffPrefs(before, after)
+	want := []PrefsChange{
+		{Field: "Hostname", Old: `"node-1"`, New: `"k8s-node-1"`},
+		{Field: "AdvertiseRoutes", Old: "[]", New: "[10.42.1.0/24]"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("diffPrefs() = %v, want %v", got, want)
+	}
+
+	if changes := diffPrefs(before, before.Clone()); len(changes) != 0 {
+		t.Fatalf("expected no changes for identical prefs, got %v", changes)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -15,6 +16,7 @@ import (
 
 	"github.com/binrclab/headcni/pkg/logging"
 	"github.com/vishvananda/netlink"
+	"tailscale.com/client/local"
 	"tailscale.com/tsnet"
 )
 
@@ -48,10 +50,20 @@ type Service struct {
 	// 配置选项
 	Options ServiceOptions
 
+	// 节点身份：首次启动时解析的配置目录，以及当时状态文件是否已存在，Restart 据此拒绝以新身份启动
+	identityDir   string
+	identityState bool
+
 	// 内部状态
 	mu sync.RWMutex
 }
 
+// ErrIdentityChanged 重启会使用不同的配置目录或丢失状态文件，从而注册新的节点身份
+var ErrIdentityChanged = fmt.Errorf("node identity would change on restart")
+
+// tsnetStateFileName tsnet.Server 在 Dir 下保存机器密钥和节点密钥的文件
+const tsnetStateFileName = "tailscaled.state"
+
 // verifyRunning 验证服务是否真的在运行
 func (s *Service) verifyRunning(ctx context.Context) error {
 	s.mu.RLock()
@@ -102,6 +114,15 @@ func (s *Service) verifyStandaloneTailscaled(ctx context.Context) error {
 		}
 	}
 
+	// userspace 模式没有 TUN 接口可供检查，以 LocalAPI socket 可连接作为就绪信号
+	if s.Options.Userspace {
+		conn, err := net.DialTimeout("unix", s.SocketPath, 2*time.Second)
+		if err != nil {
+			return fmt.Errorf("userspace tailscaled socket not responding: %v", err)
+		}
+		conn.Close()
+	}
+
 	return nil
 }
 
@@ -125,17 +146,35 @@ const (
 	ModeTSNet                                   // 直接使用TSNet
 )
 
+// String 返回模式名称，与 GetStatus、ServiceDetails 中的 mode 一致
+func (m ServiceMode) String() string {
+	switch m {
+	case ModeSystemTailscaled:
+		return "system"
+	case ModeStandaloneTailscaled:
+		return "standalone"
+	default:
+		return "tsnet"
+	}
+}
+
 // ServiceOptions 服务配置选项
 type ServiceOptions struct {
-	SocketPath string // 套接字路径
-	ConfigDir  string // 配置目录
-	AuthKey    string // 认证密钥
-	Hostname   string // 主机名
-	ControlURL string // 控制服务器URL（默认使用Tailscale官方）
-	Mode       ServiceMode
-	Logf       func(format string, args ...interface{})
-	StateFile  string // 状态文件路径
-	Interface  string // 网络接口名称
+	SocketPath    string // 套接字路径
+	ConfigDir     string // 配置目录
+	AuthKey       string // 认证密钥
+	Hostname      string // 主机名
+	ControlURL    string // 控制服务器URL（默认使用Tailscale官方）
+	Mode          ServiceMode
+	Logf          func(format string, args ...interface{})
+	StateFile     string // 状态文件路径
+	Interface     string // 网络接口名称
+	Userspace     bool   // 以 userspace-networking 运行 tailscaled，不创建 TUN 接口
+	ProxyAddr     string // userspace 模式下 SOCKS5/HTTP 代理的监听地址
+	LogFile       string // tailscaled 输出追加到的日志文件，为空时只保留在内存中用于启动失败时排查
+	LogMaxSizeMB  int    // 日志文件轮转前的最大大小，不大于 0 时使用 logging.RotatingFile 的默认值
+	LogMaxBackups int    // 保留的轮转文件数，不大于 0 时使用 logging.RotatingFile 的默认值
+	BindInterface string // tailscaled 流量的出口接口，仅独立 tailscaled 模式，为空时按主路由表选择
 }
 
 // NewServiceManager 创建新的服务管理器
@@ -267,6 +306,10 @@ func (s *Service) startWithTSNet(ctx context.Context) error {
 		return fmt.Errorf("failed to create config directory: %v", err)
 	}
 
+	if err := s.recordIdentity(); err != nil {
+		return err
+	}
+
 	// 创建TSNet服务器
 	tsnetServer := &tsnet.Server{
 		Dir:        s.ConfigDir,
@@ -292,6 +335,101 @@ func (s *Service) startWithTSNet(ctx context.Context) error {
 
 	s.SocketPath = s.getSocketPath()
 
+	// 新节点的状态文件在首次登录后才写入
+	return s.recordIdentity()
+}
+
+// stateFilePath 返回保存节点身份的状态文件路径
+func (s *Service) stateFilePath() string {
+	if s.Options.Mode == ModeTSNet {
+		return filepath.Join(s.ConfigDir, tsnetStateFileName)
+	}
+	return s.StateFile
+}
+
+// recordIdentity 首次启动时记录配置目录和状态文件，之后的启动必须与其一致
+func (s *Service) recordIdentity() error {
+	dir, err := filepath.Abs(s.ConfigDir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve config directory %s: %v", s.ConfigDir, err)
+	}
+	if s.identityDir == "" {
+		s.identityDir = dir
+	}
+	s.ConfigDir = s.identityDir
+	if !s.identityState {
+		if _, err := os.Stat(s.stateFilePath()); err == nil {
+			s.identityState = true
+		}
+	}
+	return nil
+}
+
+// checkIdentity 确认重启后仍使用首次启动时的配置目录，且已有的状态文件没有丢失
+func (s *Service) checkIdentity() error {
+	if s.identityDir == "" {
+		return nil
+	}
+	dir, err := filepath.Abs(s.Options.ConfigDir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve config directory %s: %v", s.Options.ConfigDir, err)
+	}
+	if dir != s.identityDir {
+		return fmt.Errorf("%w: config directory changed from %s to %s", ErrIdentityChanged, s.identityDir, dir)
+	}
+	if s.identityState {
+		if _, err := os.Stat(s.stateFilePath()); err != nil {
+			return fmt.Errorf("%w: state file %s is no longer readable: %v", ErrIdentityChanged, s.stateFilePath(), err)
+		}
+	}
+	return nil
+}
+
+// Restart 重启服务并保留节点身份
+// TSNet 模式下新的 tsnet.Server 复用首次启动时的 Dir，从其中的状态文件读取机器密钥和节点密钥，不会重新生成节点密钥；
+// Options.ConfigDir 在运行期间被修改或状态文件已丢失时拒绝重启并返回 ErrIdentityChanged，服务保持原状
+func (s *Service) Restart(ctx context.Context) error {
+	s.mu.RLock()
+	err := s.checkIdentity()
+	s.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	if err := s.Stop(ctx); err != nil {
+		return fmt.Errorf("failed to stop service: %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Stop 期间配置目录可能被修改
+	if err := s.checkIdentity(); err != nil {
+		return err
+	}
+
+	switch s.Options.Mode {
+	case ModeSystemTailscaled:
+		err = s.checkSystemTailscaled(ctx)
+	case ModeStandaloneTailscaled:
+		// 等待旧进程释放 socket
+		s.removeSocket()
+		if err := sleepContext(ctx, 2*time.Second); err != nil {
+			return err
+		}
+		err = s.startWithStandaloneTailscaled(ctx)
+	default:
+		s.TSNetServer = nil
+		err = s.startWithTSNet(ctx)
+	}
+	if err != nil {
+		s.LastError = err
+		return fmt.Errorf("failed to restart service: %v", err)
+	}
+
+	s.IsRunning = true
+	s.StartTime = time.Now()
+	s.LastError = nil
 	return nil
 }
 
@@ -374,15 +512,26 @@ func (s *Service) startWithStandaloneTailscaled(ctx context.Context) error {
 		return fmt.Errorf("failed to create config directory: %v", err)
 	}
 
+	if err := s.recordIdentity(); err != nil {
+		return err
+	}
+
 	// 设置socket路径
 	s.SocketPath = s.Options.SocketPath
 
+	// 绑定出口接口，接口不存在或没有地址时不启动 tailscaled
+	if s.Options.BindInterface != "" {
+		if err := s.setupBindInterface(); err != nil {
+			return fmt.Errorf("failed to bind tailscaled to %s: %v", s.Options.BindInterface, err)
+		}
+	}
+
 	// 启动tailscaled进程
 	if err := s.startTailscaledProcess(); err != nil {
 		return fmt.Errorf("failed to start tailscaled process: %v", err)
 	}
 
-	return nil
+	return s.recordIdentity()
 }
 
 // startTailscaledProcess 启动tailscaled进程
@@ -390,8 +539,8 @@ func (s *Service) startTailscaledProcess() error {
 	// 检查PID文件是否存在
 	pidFile := filepath.Join(s.ConfigDir, "tailscaled.pid")
 
-	// 如果PID文件存在，检查进程是否还在运行
-	if _, err := os.Stat(pidFile); err == nil {
+	// 先删除失效的PID文件，仍然有效时检查进程是否可以继承
+	if s.cleanupStalePIDFile(pidFile) {
 		if s.checkExistingProcess(pidFile) {
 			if s.Options.Logf != nil {
 				s.Options.Logf("发现现有tailscaled进程，继承管理 PID: %d", s.SystemTailscaledPID)
@@ -401,30 +550,33 @@ func (s *Service) startTailscaledProcess() error {
 	}
 
 	// 启动新的tailscaled进程
-	cmd := exec.Command("tailscaled",
-		"--state", s.StateFile,
-		"--socket", s.SocketPath,
-		"--tun", fmt.Sprintf("%s", s.Name),
-		"--port", "41645",
-		"--verbose", "1",
-		"--statedir", filepath.Dir(s.StateFile),
-	)
+	cmd := exec.Command("tailscaled", s.tailscaledArgs()...)
 
-	// 捕获输出用于调试
+	// 捕获输出用于调试；配置了日志文件时由 tailscaled 直接写入文件，daemon 重启后仍然保留
 	var stdout, stderr strings.Builder
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
+	logFile := s.openTailscaledLogFile()
+	if logFile != nil {
+		cmd.Stdout = logFile
+		cmd.Stderr = logFile
+	}
 
 	// 在后台运行
-	if err := cmd.Start(); err != nil {
+	err := cmd.Start()
+	if logFile != nil {
+		// 子进程持有自己的文件描述符
+		logFile.Close()
+	}
+	if err != nil {
 		return fmt.Errorf("failed to start tailscaled: %v", err)
 	}
 
 	s.SystemTailscaledPID = cmd.Process.Pid
 	s.SystemTailscaledCmd = cmd
 
-	// 写入PID文件
-	if err := os.WriteFile(pidFile, []byte(fmt.Sprintf("%d", cmd.Process.Pid)), 0644); err != nil {
+	// 写入PID文件，同时记录启动时间以便识别被复用的PID
+	if err := writePIDFile(pidFile, cmd.Process.Pid); err != nil {
 		if s.Options.Logf != nil {
 			s.Options.Logf("警告：无法写入PID文件: %v", err)
 		}
@@ -475,43 +627,66 @@ func (s *Service) startTailscaledProcess() error {
 	return fmt.Errorf("timeout waiting for tailscaled socket: %s", s.SocketPath)
 }
 
-// checkExistingProcess 检查现有进程
-func (s *Service) checkExistingProcess(pidFile string) bool {
-	// 读取PID文件
-	pidData, err := os.ReadFile(pidFile)
-	if err != nil {
-		return false
+// openTailscaledLogFile 以追加方式打开 tailscaled 日志文件，未配置或打开失败时返回 nil
+// tailscaled 直接持有文件描述符，daemon 重启后仍可写入；超过大小上限的内容由 RotateLogFile 轮转
+func (s *Service) openTailscaledLogFile() *os.File {
+	if s.Options.LogFile == "" {
+		return nil
 	}
-
-	pidStr := strings.TrimSpace(string(pidData))
-	pid, err := strconv.Atoi(pidStr)
+	if err := os.MkdirAll(filepath.Dir(s.Options.LogFile), 0755); err != nil {
+		logging.Warnf("Failed to create tailscaled log directory: %v", err)
+		return nil
+	}
+	if err := s.RotateLogFile(); err != nil {
+		logging.Warnf("Failed to rotate tailscaled log file: %v", err)
+	}
+	file, err := os.OpenFile(s.Options.LogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
 	if err != nil {
-		return false
+		logging.Warnf("Failed to open tailscaled log file %s: %v", s.Options.LogFile, err)
+		return nil
 	}
+	return file
+}
 
-	// 在 Pod 环境中，使用更安全的方式检查进程
-	// 检查进程是否存在 - 使用 os.FindProcess 替代 kill -0
-	process, err := os.FindProcess(pid)
-	if err != nil {
-		return false
+// RotateLogFile tailscaled 日志文件超过 LogMaxSizeMB 时复制到轮转文件后截断，最多保留 LogMaxBackups 个轮转文件
+// 未配置日志文件时不做任何操作
+func (s *Service) RotateLogFile() error {
+	if s.Options.LogFile == "" {
+		return nil
 	}
+	return logging.NewRotatingFile(s.Options.LogFile, s.Options.LogMaxSizeMB, s.Options.LogMaxBackups).CopyTruncate()
+}
 
-	// 尝试发送信号 0 来检查进程是否存在（在 Linux 上，os.Signal(nil) 不被支持）
-	// 使用其他方法检查进程状态
-	if err := process.Signal(syscall.Signal(0)); err != nil {
-		return false
+// tailscaledArgs 返回 tailscaled 的启动参数
+// userspace 模式不创建 TUN 接口，改为在 ProxyAddr 上提供 SOCKS5 和 HTTP 代理供出站流量使用
+func (s *Service) tailscaledArgs() []string {
+	tun := s.Name
+	if s.Options.Userspace {
+		tun = "userspace-networking"
 	}
 
-	// 在 Pod 环境中，ps 命令可能不可用，改用 /proc 文件系统
-	// 检查进程是否是tailscaled
-	commPath := fmt.Sprintf("/proc/%d/comm", pid)
-	commData, err := os.ReadFile(commPath)
-	if err != nil {
-		return false
+	args := []string{
+		"--state", s.StateFile,
+		"--socket", s.SocketPath,
+		"--tun", tun,
+		"--port", "41645",
+		"--verbose", "1",
+		"--statedir", filepath.Dir(s.StateFile),
+	}
+	if s.Options.Userspace && s.Options.ProxyAddr != "" {
+		args = append(args,
+			"--socks5-server", s.Options.ProxyAddr,
+			"--outbound-http-proxy-listen", s.Options.ProxyAddr,
+		)
 	}
+	return args
+}
 
-	processName := strings.TrimSpace(string(commData))
-	if processName != "tailscaled" {
+// checkExistingProcess 检查现有进程
+func (s *Service) checkExistingProcess(pidFile string) bool {
+	// 校验PID、进程名、--socket 参数和启动时间
+	pid, err := validatePIDFile(pidFile, s.SocketPath)
+	if err != nil {
 		return false
 	}
 
@@ -576,6 +751,10 @@ func (s *Service) checkExistingProcess(pidFile string) bool {
 
 	// 设置PID和socket路径 - 只有确认是我们自己启动的进程才设置
 	s.SystemTailscaledPID = pid
+	// 旧格式的PID文件补上启动时间
+	if err := writePIDFile(pidFile, pid); err != nil && s.Options.Logf != nil {
+		s.Options.Logf("警告：无法写入PID文件: %v", err)
+	}
 	if s.Options.Logf != nil {
 		s.Options.Logf("确认管理自己启动的 tailscaled 进程 (PID: %d, CWD: %s)", pid, processCwd)
 	}
@@ -731,6 +910,9 @@ func (s *Service) Stop(ctx context.Context) error {
 		if err := s.stopTailscaledProcess(); err != nil {
 			return fmt.Errorf("failed to stop tailscaled process: %v", err)
 		}
+		if s.Options.BindInterface != "" {
+			s.cleanupBindInterface()
+		}
 		if s.Options.Logf != nil {
 			s.Options.Logf("独立tailscaled已停止")
 		}
@@ -765,19 +947,11 @@ func (s *Service) GetStatus(ctx context.Context) (map[string]interface{}, error)
 		"is_running":  s.IsRunning,
 		"start_time":  s.StartTime,
 		"socket_path": s.SocketPath,
-		"mode":        "tsnet",
+		"mode":        s.Options.Mode.String(),
 	}
 
-	switch s.Options.Mode {
-	case ModeSystemTailscaled:
-		status["mode"] = "system"
-	case ModeStandaloneTailscaled:
-		status["mode"] = "standalone"
-		if s.SystemTailscaledPID > 0 {
-			status["pid"] = s.SystemTailscaledPID
-		}
-	case ModeTSNet:
-		status["mode"] = "tsnet"
+	if s.Options.Mode == ModeStandaloneTailscaled && s.SystemTailscaledPID > 0 {
+		status["pid"] = s.SystemTailscaledPID
 	}
 
 	if s.TSNetServer != nil {
@@ -832,9 +1006,13 @@ func (sm *ServiceManager) stopService(ctx context.Context, service *Service) err
 
 // cleanupService 清理服务资源
 func (sm *ServiceManager) cleanupService(service *Service) {
-	// 清理socket文件（如果存在）
-	if service.SocketPath != "" && !strings.HasPrefix(service.SocketPath, "tsnet://") {
-		os.Remove(service.SocketPath)
+	service.removeSocket()
+}
+
+// removeSocket 清理socket文件（如果存在）
+func (s *Service) removeSocket() {
+	if s.SocketPath != "" && !strings.HasPrefix(s.SocketPath, "tsnet://") {
+		os.Remove(s.SocketPath)
 	}
 }
 
@@ -928,27 +1106,106 @@ func (sm *ServiceManager) GetServiceStatus(name string) (string, error) {
 	return "running", nil
 }
 
-// RestartService 重启服务
-func (sm *ServiceManager) RestartService(ctx context.Context, name string) error {
+// ServiceDetails 服务的结构化状态，供 CLI 展示和指标打标签，无需解析 GetServiceStatus 的字符串
+type ServiceDetails struct {
+	Name         string        `json:"name"`
+	Mode         string        `json:"mode"`
+	Status       string        `json:"status"`
+	PID          int           `json:"pid,omitempty"`
+	SocketPath   string        `json:"socket_path"`
+	StartTime    time.Time     `json:"start_time"`
+	Uptime       time.Duration `json:"uptime"`
+	LastError    string        `json:"last_error,omitempty"`
+	BackendState string        `json:"backend_state,omitempty"`
+}
+
+// GetServiceDetails 获取服务的结构化状态，Status 与 GetServiceStatus 的返回值一致
+// BackendState 通过 LocalAPI 查询，tailscaled 未响应时为空
+func (sm *ServiceManager) GetServiceDetails(name string) (*ServiceDetails, error) {
 	service, exists := sm.GetService(name)
 	if !exists {
-		return fmt.Errorf("service %s not found", name)
+		return nil, fmt.Errorf("service %s not found", name)
 	}
 
-	// 保存配置
-	options := service.Options
+	status, err := sm.GetServiceStatus(name)
+	if err != nil {
+		return nil, err
+	}
 
-	// 停止服务
-	if err := sm.StopService(ctx, name); err != nil {
-		return fmt.Errorf("failed to stop service: %v", err)
+	service.mu.RLock()
+	defer service.mu.RUnlock()
+
+	details := &ServiceDetails{
+		Name:       name,
+		Mode:       service.Options.Mode.String(),
+		Status:     status,
+		SocketPath: service.SocketPath,
+		StartTime:  service.StartTime,
+	}
+	if service.Options.Mode == ModeStandaloneTailscaled {
+		details.PID = service.SystemTailscaledPID
+	}
+	if service.IsRunning && !service.StartTime.IsZero() {
+		details.Uptime = time.Since(service.StartTime).Truncate(time.Second)
+	}
+	if service.LastError != nil {
+		details.LastError = service.LastError.Error()
+	}
+	if status == ServiceStatusRunning {
+		details.BackendState = service.backendState()
+	}
+
+	return details, nil
+}
+
+// ListServiceDetails 按名称顺序返回所有服务的结构化状态
+func (sm *ServiceManager) ListServiceDetails() []*ServiceDetails {
+	names := sm.ListServices()
+	sort.Strings(names)
+
+	details := make([]*ServiceDetails, 0, len(names))
+	for _, name := range names {
+		if d, err := sm.GetServiceDetails(name); err == nil {
+			details = append(details, d)
+		}
+	}
+	return details
+}
+
+// backendState 通过 LocalAPI 查询 tailscaled 的 BackendState，查询失败返回空字符串，调用方需持有读锁
+func (s *Service) backendState() string {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	var localClient *local.Client
+	if s.TSNetServer != nil {
+		lc, err := s.TSNetServer.LocalClient()
+		if err != nil {
+			return ""
+		}
+		localClient = lc
+	} else {
+		if s.SocketPath == "" {
+			return ""
+		}
+		localClient = &local.Client{Socket: s.SocketPath}
 	}
 
-	// 等待一段时间
-	time.Sleep(2 * time.Second)
+	st, err := localClient.StatusWithoutPeers(ctx)
+	if err != nil {
+		return ""
+	}
+	return st.BackendState
+}
+
+// RestartService 重启服务，复用原有的节点身份，见 Service.Restart
+func (sm *ServiceManager) RestartService(ctx context.Context, name string) error {
+	service, exists := sm.GetService(name)
+	if !exists {
+		return fmt.Errorf("service %s not found", name)
+	}
 
-	// 重新启动
-	_, err := sm.StartService(ctx, name, options)
-	return err
+	return service.Restart(ctx)
 }
 
 // StopAll 停止所有服务
@@ -0,0 +1,173 @@
+package tailscale
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"tailscale.com/derp"
+	"tailscale.com/derp/derphttp"
+	"tailscale.com/net/netns"
+	"tailscale.com/tailcfg"
+	"tailscale.com/tstest/integration/testcontrol"
+	"tailscale.com/types/key"
+	"tailscale.com/types/logger"
+)
+
+// runTestDERP 在本机启动一个 DERP 服务器，节点有可用的 DERP 后才会进入 Running
+func runTestDERP(t *testing.T) *tailcfg.DERPMap {
+	t.Helper()
+	server := derp.NewServer(key.NewNode(), logger.Discard)
+	httpsrv := httptest.NewUnstartedServer(derphttp.Handler(server))
+	httpsrv.Config.TLSNextProto = make(map[string]func(*http.Server, *tls.Conn, http.Handler))
+	httpsrv.StartTLS()
+	t.Cleanup(func() {
+		httpsrv.CloseClientConnections()
+		httpsrv.Close()
+		server.Close()
+	})
+
+	return &tailcfg.DERPMap{
+		Regions: map[int]*tailcfg.DERPRegion{
+			1: {
+				RegionID:   1,
+				RegionCode: "test",
+				Nodes: []*tailcfg.DERPNode{{
+					Name:             "t1",
+					RegionID:         1,
+					HostName:         "127.0.0.1",
+					IPv4:             "127.0.0.1",
+					IPv6:             "none",
+					STUNPort:         -1,
+					DERPPort:         httpsrv.Listener.Addr().(*net.TCPAddr).Port,
+					InsecureForTests: true,
+				}},
+			},
+		},
+	}
+}
+
+// tsnetNodeKey 返回 TSNet 服务当前的节点密钥
+func tsnetNodeKey(t *testing.T, service *Service) key.NodePublic {
+	t.Helper()
+	localClient, err := service.TSNetServer.LocalClient()
+	if err != nil {
+		t.Fatalf("failed to get local client: %v", err)
+	}
+	status, err := localClient.Status(context.Background())
+	if err != nil {
+		t.Fatalf("failed to get status: %v", err)
+	}
+	if status.Self == nil || status.Self.PublicKey.IsZero() {
+		t.Fatalf("expected a node key, got status %s", status.BackendState)
+	}
+	return status.Self.PublicKey
+}
+
+func TestRestartTSNetPreservesNodeKey(t *testing.T) {
+	// 与 tsnet 自身的测试一致，测试中不使用 netns
+	netns.SetEnabled(false)
+	t.Cleanup(func() { netns.SetEnabled(true) })
+	control := &testcontrol.Server{
+		DERPMap: runTestDERP(t),
+		Logf:    t.Logf,
+	}
+	control.HTTPTestServer = httptest.NewServer(control)
+	t.Cleanup(control.HTTPTestServer.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	sm := NewServiceManager()
+	service, err := sm.StartService(ctx, "test", ServiceOptions{
+		ConfigDir:  t.TempDir(),
+		Hostname:   "node-1",
+		ControlURL: control.HTTPTestServer.URL,
+		Mode:       ModeTSNet,
+		Interface:  "test",
+		Logf:       func(string, ...interface{}) {},
+	})
+	if err != nil {
+		t.Fatalf("StartService failed: %v", err)
+	}
+	t.Cleanup(func() { sm.StopAll(context.Background()) })
+	nodeKey := tsnetNodeKey(t, service)
+
+	if err := sm.RestartService(ctx, "test"); err != nil {
+		t.Fatalf("RestartService failed: %v", err)
+	}
+	if got := tsnetNodeKey(t, service); got != nodeKey {
+		t.Fatalf("expected node key %v to survive the restart, got %v", nodeKey, got)
+	}
+
+	// 配置目录在运行期间变化时拒绝重启，服务保持运行
+	service.Options.ConfigDir = t.TempDir()
+	if err := service.Restart(ctx); !errors.Is(err, ErrIdentityChanged) {
+		t.Fatalf("expected ErrIdentityChanged, got %v", err)
+	}
+	if got := tsnetNodeKey(t, service); got != nodeKey {
+		t.Fatalf("expected the service to keep node key %v, got %v", nodeKey, got)
+	}
+}
+
+func TestServiceDetails(t *testing.T) {
+	api := newFakeLocalAPI(t)
+	sm := NewServiceManager()
+	sm.services["tailscaled"] = &Service{
+		Name:                "tailscaled",
+		SocketPath:          api.socketPath,
+		SystemTailscaledPID: os.Getpid(),
+		IsRunning:           true,
+		StartTime:           time.Now().Add(-time.Hour),
+		LastError:           errors.New("previous login failed"),
+		Options:             ServiceOptions{Mode: ModeStandaloneTailscaled},
+	}
+	sm.services["backup"] = &Service{
+		Name:       "backup",
+		SocketPath: api.socketPath,
+		StartTime:  time.Now().Add(-time.Hour),
+		Options:    ServiceOptions{Mode: ModeStandaloneTailscaled},
+	}
+
+	details, err := sm.GetServiceDetails("tailscaled")
+	if err != nil {
+		t.Fatalf("GetServiceDetails failed: %v", err)
+	}
+	if details.Status != ServiceStatusRunning || details.Mode != "standalone" || details.PID != os.Getpid() {
+		t.Errorf("unexpected details for a running service: %+v", details)
+	}
+	if details.BackendState != "Running" {
+		t.Errorf("expected the backend state from the LocalAPI, got %q", details.BackendState)
+	}
+	if details.Uptime < time.Hour || details.LastError != "previous login failed" {
+		t.Errorf("expected an uptime of at least 1h and the last error, got %v and %q", details.Uptime, details.LastError)
+	}
+
+	// 已停止的服务不查询 LocalAPI，也没有运行时长
+	queries := api.queries()
+	stopped, err := sm.GetServiceDetails("backup")
+	if err != nil {
+		t.Fatalf("GetServiceDetails failed: %v", err)
+	}
+	if stopped.Status != "stopped" || stopped.BackendState != "" || stopped.Uptime != 0 {
+		t.Errorf("unexpected details for a stopped service: %+v", stopped)
+	}
+	if api.queries() != queries {
+		t.Errorf("expected no LocalAPI query for a stopped service")
+	}
+
+	if _, err := sm.GetServiceDetails("missing"); err == nil {
+		t.Errorf("expected an error for an unknown service")
+	}
+
+	list := sm.ListServiceDetails()
+	if len(list) != 2 || list[0].Name != "backup" || list[1].Name != "tailscaled" {
+		t.Errorf("expected both services sorted by name, got %+v", list)
+	}
+}
@@ -0,0 +1,98 @@
+package tailscale
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/binrclab/headcni/pkg/utils/fsutil"
+	"tailscale.com/ipn"
+	"tailscale.com/tailcfg"
+	"tailscale.com/types/key"
+)
+
+// tailscaled 的 --state 文件是 JSON 格式的 map[StateKey][]byte（值为 base64），
+// "_current-profile" 指向当前 profile 的键，该键下保存包含节点私钥（Config 字段）的 Prefs
+
+// StateIdentity 状态文件中当前 profile 的节点身份
+type StateIdentity struct {
+	ProfileID  ipn.ProfileID
+	NodeID     tailcfg.StableNodeID
+	NodeKey    key.NodePublic
+	LoginName  string
+	ControlURL string
+}
+
+// String 返回用于日志的节点身份描述
+func (id *StateIdentity) String() string {
+	return fmt.Sprintf("node %s (key %s, profile %s, user %s, control %s)", id.NodeID, id.NodeKey.ShortString(), id.ProfileID, id.LoginName, id.ControlURL)
+}
+
+// ParseStateIdentity 解析 tailscaled 状态文件内容，返回当前 profile 的节点身份；未登录过的状态文件返回错误
+func ParseStateIdentity(data []byte) (*StateIdentity, error) {
+	var state map[ipn.StateKey][]byte
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("not a tailscaled state file: %v", err)
+	}
+
+	profileKey := ipn.StateKey(state[ipn.CurrentProfileStateKey])
+	if profileKey == "" {
+		return nil, fmt.Errorf("state has no current profile")
+	}
+	prefsData, ok := state[profileKey]
+	if !ok {
+		return nil, fmt.Errorf("current profile %s not found in state", profileKey)
+	}
+	prefs := ipn.NewPrefs()
+	if err := ipn.PrefsFromBytes(prefsData, prefs); err != nil {
+		return nil, fmt.Errorf("failed to parse prefs of profile %s: %v", profileKey, err)
+	}
+	if prefs.Persist == nil || prefs.Persist.PrivateNodeKey.IsZero() {
+		return nil, fmt.Errorf("profile %s has no node key (never logged in)", profileKey)
+	}
+
+	identity := &StateIdentity{
+		NodeID:     prefs.Persist.NodeID,
+		NodeKey:    prefs.Persist.PrivateNodeKey.Public(),
+		LoginName:  prefs.Persist.UserProfile.LoginName,
+		ControlURL: prefs.ControlURL,
+	}
+	// profile 列表只用于补充 ID，缺失时不影响导入
+	var profiles map[ipn.ProfileID]ipn.LoginProfile
+	if err := json.Unmarshal(state[ipn.KnownProfilesStateKey], &profiles); err == nil {
+		for id, profile := range profiles {
+			if profile.Key == profileKey {
+				identity.ProfileID = id
+				break
+			}
+		}
+	}
+	return identity, nil
+}
+
+// ImportState 把 src 处已有的 tailscaled 状态文件复制到 dst，使 tailscaled 以原节点身份启动而不是重新注册
+// 复制前校验 src 可以解析出节点身份；dst 已存在时不覆盖并返回错误
+func ImportState(src, dst string) (*StateIdentity, error) {
+	if _, err := os.Stat(dst); err == nil {
+		return nil, fmt.Errorf("state file %s already exists", dst)
+	}
+
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file %s: %v", src, err)
+	}
+	identity, err := ParseStateIdentity(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid state file %s: %v", src, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create state directory: %v", err)
+	}
+	// 状态文件包含节点私钥，只允许 root 读取
+	if err := fsutil.WriteFileAtomic(dst, data, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write state file %s: %v", dst, err)
+	}
+	return identity, nil
+}
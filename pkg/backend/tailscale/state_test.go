@@ -0,0 +1,99 @@
+package tailscale
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"tailscale.com/ipn"
+	"tailscale.com/tailcfg"
+	"tailscale.com/types/key"
+	"tailscale.com/types/persist"
+)
+
+// writeStateFile 按 tailscaled FileStore 的格式写入一个已登录的状态文件
+func writeStateFile(t *testing.T, path string, nodeKey key.NodePrivate) {
+	t.Helper()
+	prefs := ipn.NewPrefs()
+	prefs.ControlURL = "https://headscale.example.com"
+	prefs.Persist = &persist.Persist{
+		PrivateNodeKey: nodeKey,
+		NodeID:         "nABCDEF",
+		UserProfile:    tailcfg.UserProfile{LoginName: "k8s@"},
+	}
+	profiles, err := json.Marshal(map[ipn.ProfileID]ipn.LoginProfile{
+		"1a2b": {ID: "1a2b", Key: "profile-1a2b"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(map[ipn.StateKey][]byte{
+		ipn.CurrentProfileStateKey: []byte("profile-1a2b"),
+		ipn.KnownProfilesStateKey:  profiles,
+		"profile-1a2b":             prefs.ToBytes(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestImportState(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "host", "tailscaled.state")
+	dst := filepath.Join(dir, "headcni", "tailscaled.state")
+	if err := os.MkdirAll(filepath.Dir(src), 0755); err != nil {
+		t.Fatal(err)
+	}
+	nodeKey := key.NewNode()
+	writeStateFile(t, src, nodeKey)
+
+	identity, err := ImportState(src, dst)
+	if err != nil {
+		t.Fatalf("ImportState failed: %v", err)
+	}
+	if identity.NodeKey != nodeKey.Public() || identity.NodeID != "nABCDEF" || identity.ProfileID != "1a2b" ||
+		identity.LoginName != "k8s@" || identity.ControlURL != "https://headscale.example.com" {
+		t.Fatalf("unexpected identity %+v", identity)
+	}
+
+	info, err := os.Stat(dst)
+	if err != nil {
+		t.Fatalf("imported state missing: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Fatalf("imported state mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	// 已有的状态文件不会被覆盖
+	if _, err := ImportState(src, dst); err == nil {
+		t.Fatal("expected an error when the managed state file already exists")
+	}
+}
+
+func TestImportStateRejectsInvalidState(t *testing.T) {
+	dir := t.TempDir()
+	tests := map[string]string{
+		"not json":        "garbage",
+		"no profile":      `{}`,
+		"never logged in": `{"_current-profile":"cHJvZmlsZS0x","profile-1":"e30="}`,
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			src := filepath.Join(dir, name+".state")
+			dst := filepath.Join(dir, name+".imported")
+			if err := os.WriteFile(src, []byte(content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := ImportState(src, dst); err == nil {
+				t.Fatal("expected an error")
+			}
+			if _, err := os.Stat(dst); !os.IsNotExist(err) {
+				t.Fatalf("invalid state must not be imported: %v", err)
+			}
+		})
+	}
+}
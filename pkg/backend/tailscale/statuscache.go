@@ -0,0 +1,102 @@
+package tailscale
+
+import (
+	"context"
+	"time"
+
+	"tailscale.com/ipn/ipnstate"
+)
+
+// statusCall is an in-flight status query shared by concurrent GetStatus callers
+type statusCall struct {
+	done   chan struct{}
+	status *ipnstate.Status
+	err    error
+}
+
+// SetStatusCacheTTL enables caching of GetStatus results for ttl
+// A ttl of zero or less disables the cache, which is the default
+func (c *SimpleClient) SetStatusCacheTTL(ttl time.Duration) {
+	c.statusMu.Lock()
+	defer c.statusMu.Unlock()
+
+	c.statusTTL = ttl
+	if ttl <= 0 {
+		c.cachedStatus = nil
+	}
+}
+
+// cachedOrShared returns a cached status that is still fresh, or the in-flight query to wait on
+// If neither exists, a new call is registered and leader is true
+func (c *SimpleClient) cachedOrShared() (status *ipnstate.Status, call *statusCall, leader bool, generation uint64) {
+	c.statusMu.Lock()
+	defer c.statusMu.Unlock()
+
+	if c.cachedStatus != nil && time.Since(c.cachedAt) < c.statusTTL {
+		return c.cachedStatus, nil, false, c.statusGeneration
+	}
+	if c.statusCall != nil {
+		return nil, c.statusCall, false, c.statusGeneration
+	}
+	c.statusCall = &statusCall{done: make(chan struct{})}
+	return nil, c.statusCall, true, c.statusGeneration
+}
+
+// storeStatus caches status unless the cache was invalidated since generation was read
+func (c *SimpleClient) storeStatus(status *ipnstate.Status, generation uint64) {
+	c.statusMu.Lock()
+	defer c.statusMu.Unlock()
+
+	if c.statusTTL <= 0 || generation != c.statusGeneration {
+		return
+	}
+	c.cachedStatus = status
+	c.cachedAt = time.Now()
+}
+
+// invalidateStatus drops the cached status after an operation that changes tailscaled state
+func (c *SimpleClient) invalidateStatus() {
+	c.statusMu.Lock()
+	defer c.statusMu.Unlock()
+
+	c.cachedStatus = nil
+	c.statusGeneration++
+}
+
+// cachedStatusEnabled reports whether GetStatus may serve cached results
+func (c *SimpleClient) cachedStatusEnabled() bool {
+	c.statusMu.Lock()
+	defer c.statusMu.Unlock()
+
+	return c.statusTTL > 0
+}
+
+// getStatusCached serves GetStatus from the cache, coalescing concurrent queries into one round-trip
+// Callers waiting on another caller's query receive its error, including a cancellation of its context
+func (c *SimpleClient) getStatusCached(ctx context.Context) (*ipnstate.Status, error) {
+	status, call, leader, generation := c.cachedOrShared()
+	if status != nil {
+		return status, nil
+	}
+
+	if !leader {
+		select {
+		case <-call.done:
+			return call.status, call.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	call.status, call.err = c.fetchStatus(ctx)
+	if call.err == nil {
+		c.storeStatus(call.status, generation)
+	}
+
+	c.statusMu.Lock()
+	c.statusCall = nil
+	c.statusMu.Unlock()
+	close(call.done)
+
+	return call.status, call.err
+}
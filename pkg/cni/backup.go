@@ -0,0 +1,129 @@
+package cni
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/binrclab/headcni/pkg/utils/fsutil"
+)
+
+// BackupManifestName 备份清单文件名，保存在备份目录中
+// 不使用 .conf/.conflist/.json 后缀，避免容器运行时把它当作 CNI 配置加载
+const BackupManifestName = "headcni-backups.manifest"
+
+// BackupRecord 一次备份的记录
+type BackupRecord struct {
+	OriginalName string    `json:"original_name"`
+	BackupName   string    `json:"backup_name"`
+	Timestamp    time.Time `json:"timestamp"`
+	SHA256       string    `json:"sha256"`
+}
+
+// backupManifest 备份清单，恢复时以清单为准确定原始文件名并校验内容
+type backupManifest struct {
+	Backups []BackupRecord `json:"backups"`
+}
+
+// BackupRecords 返回备份清单中的记录
+func (cm *CNIConfigManager) BackupRecords() ([]BackupRecord, error) {
+	manifest, err := cm.readBackupManifest()
+	if err != nil {
+		return nil, err
+	}
+	return manifest.Backups, nil
+}
+
+// manifestPath 备份清单路径
+func (cm *CNIConfigManager) manifestPath() string {
+	return filepath.Join(cm.backupDir, BackupManifestName)
+}
+
+// readBackupManifest 读取备份清单，清单不存在时返回空清单
+func (cm *CNIConfigManager) readBackupManifest() (*backupManifest, error) {
+	data, err := os.ReadFile(cm.manifestPath())
+	if os.IsNotExist(err) {
+		return &backupManifest{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup manifest: %v", err)
+	}
+	manifest := &backupManifest{}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse backup manifest %s: %v", cm.manifestPath(), err)
+	}
+	return manifest, nil
+}
+
+// writeBackupManifest 原子写入备份清单，避免中断时留下不完整的清单
+func (cm *CNIConfigManager) writeBackupManifest(manifest *backupManifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal backup manifest: %v", err)
+	}
+	if err := fsutil.WriteFileAtomic(cm.manifestPath(), data, 0644); err != nil {
+		return fmt.Errorf("failed to write backup manifest: %v", err)
+	}
+	return nil
+}
+
+// recordBackup 把备份写入清单，同名备份被覆盖时替换原记录
+func (cm *CNIConfigManager) recordBackup(record BackupRecord) error {
+	manifest, err := cm.readBackupManifest()
+	if err != nil {
+		return err
+	}
+	manifest.Backups = removeBackupRecord(manifest.Backups, record.BackupName)
+	manifest.Backups = append(manifest.Backups, record)
+	return cm.writeBackupManifest(manifest)
+}
+
+// findBackupRecord 按备份文件名查找清单记录
+func (cm *CNIConfigManager) findBackupRecord(backupName string) (*BackupRecord, error) {
+	manifest, err := cm.readBackupManifest()
+	if err != nil {
+		return nil, err
+	}
+	for i := range manifest.Backups {
+		if manifest.Backups[i].BackupName == backupName {
+			return &manifest.Backups[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// forgetBackups 从清单中删除备份记录
+func (cm *CNIConfigManager) forgetBackups(backupNames ...string) error {
+	manifest, err := cm.readBackupManifest()
+	if err != nil {
+		return err
+	}
+	before := len(manifest.Backups)
+	for _, name := range backupNames {
+		manifest.Backups = removeBackupRecord(manifest.Backups, name)
+	}
+	if len(manifest.Backups) == before {
+		return nil
+	}
+	return cm.writeBackupManifest(manifest)
+}
+
+func removeBackupRecord(records []BackupRecord, backupName string) []BackupRecord {
+	kept := records[:0]
+	for _, record := range records {
+		if record.BackupName != backupName {
+			kept = append(kept, record)
+		}
+	}
+	return kept
+}
+
+// sha256Hex 返回内容的 sha256 十六进制摘要
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,75 @@
+package cni
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBackupManifestRestore(t *testing.T) {
+	dir := t.TempDir()
+	original := []byte(`{"cniVersion":"1.0.0","name":"flannel","plugins":[{"type":"flannel"}]}`)
+	if err := os.WriteFile(filepath.Join(dir, "10-flannel.conflist"), original, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cm := NewCNIConfigManager(dir, "10-headcni.conflist", filepath.Join(dir, "env.yaml"), nil)
+	if err := cm.backupExistingConfigs(); err != nil {
+		t.Fatalf("backupExistingConfigs failed: %v", err)
+	}
+
+	records, err := cm.BackupRecords()
+	if err != nil {
+		t.Fatalf("BackupRecords failed: %v", err)
+	}
+	if len(records) != 1 || records[0].OriginalName != "10-flannel.conflist" || records[0].SHA256 != sha256Hex(original) || records[0].Timestamp.IsZero() {
+		t.Fatalf("unexpected backup records %+v", records)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "10-flannel.conflist")); !os.IsNotExist(err) {
+		t.Fatalf("expected the original config to be moved, stat err: %v", err)
+	}
+
+	// 再次备份时清单不能被当作 CNI 配置处理
+	if err := cm.backupExistingConfigs(); err != nil {
+		t.Fatalf("backupExistingConfigs failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, BackupManifestName)); err != nil {
+		t.Fatalf("backup manifest missing: %v", err)
+	}
+
+	if err := cm.restoreBackup(records[0].BackupName); err != nil {
+		t.Fatalf("restoreBackup failed: %v", err)
+	}
+	restored, err := os.ReadFile(filepath.Join(dir, "10-flannel.conflist"))
+	if err != nil || string(restored) != string(original) {
+		t.Fatalf("restored config = %q, %v", restored, err)
+	}
+	if records, _ := cm.BackupRecords(); len(records) != 0 {
+		t.Fatalf("expected the restored backup to leave the manifest, got %+v", records)
+	}
+}
+
+func TestRestoreBackupRejectsCorruptedBackup(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "87-podman.conflist"), []byte(`{"name":"podman"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cm := NewCNIConfigManager(dir, "10-headcni.conflist", filepath.Join(dir, "env.yaml"), nil)
+	if err := cm.backupFile("87-podman.conflist"); err != nil {
+		t.Fatalf("backupFile failed: %v", err)
+	}
+	backupName := "87-podman.conflist.headcni_bak"
+	if err := os.WriteFile(filepath.Join(dir, backupName), []byte(`{"name":"tampered"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := cm.restoreBackup(backupName)
+	if err == nil || !strings.Contains(err.Error(), "corrupted") {
+		t.Fatalf("expected a checksum error, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "87-podman.conflist")); !os.IsNotExist(err) {
+		t.Fatalf("corrupted backup must not be restored, stat err: %v", err)
+	}
+}
@@ -20,6 +20,7 @@ type CNIRequest struct {
 	Namespace   string `json:"namespace"`
 	PodName     string `json:"pod_name"`
 	ContainerID string `json:"container_id"`
+	PodUID      string `json:"pod_uid,omitempty"`
 	PodIP       string `json:"pod_ip,omitempty"`
 	LocalPool   string `json:"local_pool,omitempty"`
 }
@@ -28,6 +29,8 @@ type CNIRequest struct {
 type CNIResponse struct {
 	Success bool        `json:"success"`
 	Error   string      `json:"error,omitempty"`
+	Code    uint        `json:"code,omitempty"`    // CNI 错误码，0 表示普通错误
+	Details string      `json:"details,omitempty"` // 错误的处理建议
 	Data    interface{} `json:"data,omitempty"`
 }
 
@@ -56,7 +59,7 @@ func (c *Client) AllocateIP(namespace, podName, containerID string) (string, err
 	}
 
 	if !resp.Success {
-		return "", fmt.Errorf("allocation failed: %s", resp.Error)
+		return "", resp.Err("allocation failed")
 	}
 
 	// 解析响应数据
@@ -131,7 +134,7 @@ func (c *Client) AllocateIPWithLocalPool(namespace, podName, containerID, localP
 	}
 
 	if !resp.Success {
-		return "", fmt.Errorf("allocation failed: %s", resp.Error)
+		return "", resp.Err("allocation failed")
 	}
 
 	// 解析响应数据
@@ -144,6 +147,23 @@ func (c *Client) AllocateIPWithLocalPool(namespace, podName, containerID, localP
 	return "", fmt.Errorf("invalid response data")
 }
 
+// ReportSubnetExhausted 通知 daemon host-local 已无可用地址
+// daemon 会立即触发一次泄漏回收，并返回带子网使用情况的 CNI 错误，插件应把该错误返回给运行时
+func (c *Client) ReportSubnetExhausted(namespace, podName, containerID string) error {
+	req := &CNIRequest{
+		Type:        "subnet_exhausted",
+		Namespace:   namespace,
+		PodName:     podName,
+		ContainerID: containerID,
+	}
+
+	resp, err := c.SendRequest(req)
+	if err != nil {
+		return err
+	}
+	return resp.Err("subnet exhausted")
+}
+
 // SendRequest 发送请求到 Daemon
 func (c *Client) SendRequest(req *CNIRequest) (*CNIResponse, error) {
 	// 构造请求体
@@ -10,7 +10,10 @@ import (
 	"time"
 
 	"github.com/binrclab/headcni/cmd/daemon/config"
+	"github.com/binrclab/headcni/pkg/ipam"
 	"github.com/binrclab/headcni/pkg/logging"
+	"github.com/binrclab/headcni/pkg/monitoring"
+	"github.com/binrclab/headcni/pkg/utils/fsutil"
 	"github.com/binrclab/yamlc"
 	"gopkg.in/yaml.v3"
 )
@@ -26,6 +29,12 @@ type CNIPlugin struct {
 	DataDir       string                   `json:"dataDir,omitempty"`
 	Delegate      *Delegate                `json:"delegate,omitempty"`
 	RuntimeConfig map[string]interface{}   `json:"runtimeConfig,omitempty"`
+	// PodRoutes cmdAdd 在容器网络命名空间中经 Pod 网关安装的额外路由，由 ParsePodRoutes 校验
+	PodRoutes []string `json:"pod_routes,omitempty"`
+	// LogFile、LogMaxSizeMB、LogMaxBackups 插件日志文件及其轮转限制，为空时使用默认值，见 NewPluginLoggerForConfig
+	LogFile       string `json:"logFile,omitempty"`
+	LogMaxSizeMB  int    `json:"logMaxSizeMB,omitempty"`
+	LogMaxBackups int    `json:"logMaxBackups,omitempty"`
 }
 
 type Delegate struct {
@@ -48,6 +57,8 @@ type CniEnv struct {
 	IPMasq   bool      `json:"ipmasq,omitempty"       yaml:"ipmasq"       comment:"IP masquerade configuration"`
 	Metadata *Metadata `json:"metadata,omitempty"     yaml:"metadata"     comment:"Metadata information"`
 	Routes   []Route   `json:"routes,omitempty"       yaml:"routes"       comment:"Routes configuration"`
+	Reserved []string  `json:"reserved,omitempty"     yaml:"reserved"     comment:"Reserved IPs and ranges excluded from allocation"`
+	IPAM     string    `json:"ipam,omitempty"         yaml:"ipam"         comment:"IPAM backend, kube-backed takes the pod IP from the daemon, host-local-delegate calls the upstream host-local"`
 	DNS      *DNS      `json:"dns,omitempty"          yaml:"dns"          comment:"DNS configuration"`
 	Policies *Policies `json:"policies,omitempty"     yaml:"policies"     comment:"Network policies"`
 }
@@ -65,9 +76,12 @@ type Route struct {
 }
 
 type DNS struct {
-	Nameservers []string `json:"nameservers,omitempty" yaml:"nameservers" comment:"DNS nameservers"`
-	Search      []string `json:"search,omitempty"      yaml:"search"      comment:"DNS search domains"`
-	Options     []string `json:"options,omitempty"     yaml:"options"     comment:"DNS options"`
+	Nameservers    []string `json:"nameservers,omitempty"     yaml:"nameservers"     comment:"DNS nameservers"`
+	Search         []string `json:"search,omitempty"          yaml:"search"          comment:"DNS search domains"`
+	Options        []string `json:"options,omitempty"         yaml:"options"         comment:"DNS options"`
+	SearchTemplate []string `json:"search_template,omitempty" yaml:"search_template" comment:"Per-pod search domain template, expanded by SearchForPod"`
+	ClusterDomain  string   `json:"cluster_domain,omitempty"  yaml:"cluster_domain"  comment:"Cluster domain substituted for {cluster_domain}"`
+	TailnetDomain  string   `json:"tailnet_domain,omitempty"  yaml:"tailnet_domain"  comment:"Tailnet base domain substituted for {tailnet_domain}"`
 }
 
 type Policies struct {
@@ -84,6 +98,8 @@ type CNIConfigManager struct {
 	cniEnvFile string
 	backupDir  string
 	logger     logging.Logger
+	// preservePlugins 保留在原位、不备份的外部 CNI 配置，见 SetPreservePlugins
+	preservePlugins []string
 }
 
 // NewCNIConfigManager 创建新的 CNI 配置管理器
@@ -136,7 +152,22 @@ func (cm *CNIConfigManager) GenerateConfigList(localCIDR string, cfg *config.Con
 			Search:      []string{defaultClusterDomain, fmt.Sprintf("svc.%s", defaultClusterDomain)},
 		}
 
-		if len(cfg.DNS.MagicDNS.SearchDomains) > 0 {
+		if len(cfg.DNS.SearchTemplate) > 0 {
+			// 模板决定完整的 search 列表和顺序，含 {namespace} 的项由插件按 Pod 展开，Search 只保留与 Pod 无关的项
+			if err := ValidateSearchTemplate(cfg.DNS.SearchTemplate, cfg.DNS.TailnetDomain); err != nil {
+				return nil, nil, fmt.Errorf("invalid dns.searchTemplate: %v", err)
+			}
+			cniEnv.DNS.SearchTemplate = cfg.DNS.SearchTemplate
+			cniEnv.DNS.ClusterDomain = defaultClusterDomain
+			cniEnv.DNS.TailnetDomain = cfg.DNS.TailnetDomain
+			var static []string
+			for _, entry := range cfg.DNS.SearchTemplate {
+				if !strings.Contains(entry, SearchTokenNamespace) {
+					static = append(static, entry)
+				}
+			}
+			cniEnv.DNS.Search = ExpandSearchTemplate(static, defaultClusterDomain, "", cfg.DNS.TailnetDomain)
+		} else if len(cfg.DNS.MagicDNS.SearchDomains) > 0 {
 			cniEnv.DNS.Search = append(cniEnv.DNS.Search, cfg.DNS.MagicDNS.SearchDomains...)
 		}
 		if len(cfg.DNS.MagicDNS.Options) > 0 {
@@ -147,6 +178,14 @@ func (cm *CNIConfigManager) GenerateConfigList(localCIDR string, cfg *config.Con
 		}
 	}
 	cniEnv.Policies = &Policies{}
+	if cfg.Network.EnableNetworkPolicy {
+		cniEnv.Policies = &Policies{
+			AllowHostAccess:     cfg.Network.Policy.HostAccessAllowed(),
+			AllowServiceAccess:  cfg.Network.Policy.ServiceAccessAllowed(),
+			AllowExternalAccess: cfg.Network.Policy.ExternalAccessAllowed(),
+			EgressAllowed:       cfg.Network.Policy.EgressAllowed,
+		}
+	}
 	// 处理 ServiceCIDR 和 LocalCIDR，支持 IPv4 和 IPv6
 	var serviceCIDRs []string
 	var localCIDRs []string
@@ -159,6 +198,10 @@ func (cm *CNIConfigManager) GenerateConfigList(localCIDR string, cfg *config.Con
 	}
 
 	cniEnv.MTU = cfg.Network.MTU
+	if cniEnv.MTU == 0 {
+		// 自动 MTU：先使用 tailnet 最小 MTU，连接建立后由 daemon 按实际接口 MTU 更新
+		cniEnv.MTU = config.MinTailnetMTU
+	}
 	cniEnv.NetWork = cfg.Network.PodCIDR.Base
 	cniEnv.Subnet = localCIDR
 
@@ -171,6 +214,17 @@ func (cm *CNIConfigManager) GenerateConfigList(localCIDR string, cfg *config.Con
 
 	cniEnv.IPMasq = cfg.Network.EnableNetworkPolicy
 
+	// 保留地址透传给插件，分配时跳过
+	if len(cfg.IPAM.Reserved) > 0 {
+		cniEnv.Reserved = cfg.IPAM.Reserved
+	}
+
+	// kube-backed 由 daemon 分配地址，插件使用 allocate 响应中的 IP，不再调用 host-local
+	// host-local-delegate 由插件把 ipam 块交给上游 host-local，ADD/DEL 分别对应 DelegateIPAMAdd/DelegateIPAMDel
+	if cfg.IPAM.Type == ipam.TypeKubeBacked || cfg.IPAM.Type == ipam.TypeHostLocalDelegate {
+		cniEnv.IPAM = cfg.IPAM.Type
+	}
+
 	// 设置元数据
 	cniEnv.Metadata = &Metadata{
 		GeneratedAt: time.Now().Format(time.RFC3339),
@@ -193,6 +247,11 @@ func (cm *CNIConfigManager) GenerateConfigList(localCIDR string, cfg *config.Con
 	// 创建 cni 插件配置
 	var cniPlugins []map[string]interface{}
 
+	var headcniIPAM map[string]interface{}
+	if cfg.IPAM.Type == ipam.TypeHostLocalDelegate {
+		headcniIPAM = HostLocalDelegateIPAM([]string{cniEnv.Subnet, cniEnv.IPv6Sub}, cfg.IPAM.LeakReconcile.DataDir)
+	}
+
 	// 将 headcniPlugin 转换为 map[string]interface{}
 	headcniPluginBytes, err := json.Marshal(CNIPlugin{
 		Type: "headcni",
@@ -200,6 +259,8 @@ func (cm *CNIConfigManager) GenerateConfigList(localCIDR string, cfg *config.Con
 			HairpinMode:      true,
 			IsDefaultGateway: true,
 		},
+		IPAM:      headcniIPAM,
+		PodRoutes: cfg.Network.PodRoutes,
 	})
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to marshal headcni plugin: %v", err)
@@ -210,6 +271,9 @@ func (cm *CNIConfigManager) GenerateConfigList(localCIDR string, cfg *config.Con
 		return nil, nil, fmt.Errorf("failed to unmarshal headcni plugin: %v", err)
 	}
 
+	// 保留的外部插件在前，headcni 链在其后
+	cniPlugins = append(cniPlugins, cm.preservedPlugins()...)
+
 	// 将 headcniPlugin 添加到插件列表
 	cniPlugins = append(cniPlugins, headcniPluginMap)
 
@@ -282,9 +346,9 @@ func (cm *CNIConfigManager) WriteConfigList(configList *CNIPlugin) error {
 		return fmt.Errorf("failed to marshal config: %v", err)
 	}
 
-	// 写入配置文件
+	// 原子写入配置文件，kubelet 不会读到写了一半的 configlist
 	configPath := filepath.Join(cm.configDir, cm.configName)
-	if err := os.WriteFile(configPath, configData, 0644); err != nil {
+	if err := fsutil.WriteFileAtomic(configPath, configData, 0644); err != nil {
 		return fmt.Errorf("failed to write config file: %v", err)
 	}
 
@@ -305,8 +369,8 @@ func (cm *CNIConfigManager) WriteCniEnv(cniEnv *CniEnv) error {
 		return fmt.Errorf("failed to generate YAML with yamlc: %v", err)
 	}
 
-	// 写入配置文件
-	if err := os.WriteFile(cm.cniEnvFile, yamlData, 0644); err != nil {
+	// 原子写入配置文件，插件不会读到写了一半的 env.yaml
+	if err := fsutil.WriteFileAtomic(cm.cniEnvFile, yamlData, 0644); err != nil {
 		return fmt.Errorf("failed to write cniEnv file: %v", err)
 	}
 
@@ -330,6 +394,23 @@ func (cm *CNIConfigManager) WriteConfigListAndEnv(configList *CNIPlugin, cniEnv
 	return nil
 }
 
+// UpdateCniEnvMTU 更新 cniEnv 中的 MTU，值未变化时不写文件
+func (cm *CNIConfigManager) UpdateCniEnvMTU(mtu int) (bool, error) {
+	cniEnv, err := cm.ReadCniEnv()
+	if err != nil {
+		return false, err
+	}
+	if cniEnv.MTU == mtu {
+		return false, nil
+	}
+
+	cniEnv.MTU = mtu
+	if err := cm.WriteCniEnv(cniEnv); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 // ReadCniEnv 读取 cniEnv 配置
 
 func (cm *CNIConfigManager) ReadCniEnv() (*CniEnv, error) {
@@ -386,8 +467,8 @@ func (cm *CNIConfigManager) BackupOtherConfigLists() error {
 		}
 
 		fileName := file.Name()
-		// 跳过当前配置文件和非 .conflist 文件
-		if fileName == cm.configName || !strings.HasSuffix(fileName, ".conflist") {
+		// 跳过当前配置文件、保留的配置和非 .conflist 文件
+		if fileName == cm.configName || !strings.HasSuffix(fileName, ".conflist") || cm.isPreserved(fileName) {
 			continue
 		}
 
@@ -438,6 +519,11 @@ func (cm *CNIConfigManager) backupExistingConfigs() error {
 			}
 		}
 
+		if shouldBackup && cm.isPreserved(fileName) {
+			logging.Debugf("Keeping preserved config file %s", fileName)
+			continue
+		}
+
 		if shouldBackup {
 			if err := cm.backupFile(fileName); err != nil {
 				return fmt.Errorf("failed to backup file %s: %v", fileName, err)
@@ -481,21 +567,39 @@ func (cm *CNIConfigManager) backupFile(fileName string) error {
 
 	// 写入备份文件
 	if err := os.WriteFile(backupPath, sourceData, 0644); err != nil {
+		monitoring.RecordCNIConfigBackup("backup", "failure")
 		return fmt.Errorf("failed to write backup file %s: %v", backupPath, err)
 	}
 
+	// 先记录清单再删除源文件，清单写入失败时源文件保持不变
+	record := BackupRecord{
+		OriginalName: fileName,
+		BackupName:   backupFileName,
+		Timestamp:    time.Now().UTC(),
+		SHA256:       sha256Hex(sourceData),
+	}
+	if err := cm.recordBackup(record); err != nil {
+		_ = os.Remove(backupPath)
+		monitoring.RecordCNIConfigBackup("backup", "failure")
+		return fmt.Errorf("failed to record backup of %s: %v", fileName, err)
+	}
+
 	// 删除源文件
 	if err := os.Remove(sourcePath); err != nil {
 		// 如果删除失败，尝试删除备份文件以保持一致性
 		_ = os.Remove(backupPath)
+		_ = cm.forgetBackups(backupFileName)
+		monitoring.RecordCNIConfigBackup("backup", "failure")
 		return fmt.Errorf("failed to remove source file %s: %v", sourcePath, err)
 	}
 
-	logging.Infof("Backed up config file: %s -> %s", fileName, backupFileName)
+	monitoring.RecordCNIConfigBackup("backup", "success")
+	logging.Infof("Backed up config file: %s -> %s (sha256 %s)", fileName, backupFileName, record.SHA256)
 	return nil
 }
 
 // restoreBackup 恢复备份文件
+// 清单中有记录时按记录恢复到原始文件名并校验 sha256；没有记录的旧备份按文件名推断原始文件名
 func (cm *CNIConfigManager) restoreBackup(backupFileName string) error {
 	backupPath := filepath.Join(cm.backupDir, backupFileName)
 
@@ -504,38 +608,41 @@ func (cm *CNIConfigManager) restoreBackup(backupFileName string) error {
 		return fmt.Errorf("backup file does not exist: %s", backupPath)
 	}
 
-	// 从备份文件名中提取原始文件名
-	// 格式: original_name.headcni_bak
-	parts := strings.Split(backupFileName, ".headcni_bak")
-	if len(parts) != 2 {
-		return fmt.Errorf("invalid backup file name format: %s", backupFileName)
+	record, err := cm.findBackupRecord(backupFileName)
+	if err != nil {
+		monitoring.RecordCNIConfigBackup("restore", "failure")
+		return err
 	}
-	originalFileName := parts[0]
 
-	// 恢复原始扩展名
-	originalExtensions := []string{".conflist", ".conf", ".json", ".yaml", ".yml"}
-	hasExtension := false
-	for _, ext := range originalExtensions {
-		if strings.HasSuffix(originalFileName, ext) {
-			hasExtension = true
-			break
+	var originalFileName string
+	if record != nil {
+		originalFileName = record.OriginalName
+	} else {
+		logging.Warnf("Backup %s is not in the backup manifest, deriving the original file name", backupFileName)
+		if originalFileName, err = legacyOriginalFileName(backupFileName); err != nil {
+			monitoring.RecordCNIConfigBackup("restore", "failure")
+			return err
 		}
 	}
-	// 如果没有找到扩展名，添加默认的 .conf
-	if !hasExtension {
-		originalFileName += ".conf"
-	}
 
 	restorePath := filepath.Join(cm.configDir, originalFileName)
 
 	// 读取备份文件
 	backupData, err := os.ReadFile(backupPath)
 	if err != nil {
+		monitoring.RecordCNIConfigBackup("restore", "failure")
 		return fmt.Errorf("failed to read backup file %s: %v", backupPath, err)
 	}
+	if record != nil {
+		if sum := sha256Hex(backupData); sum != record.SHA256 {
+			monitoring.RecordCNIConfigBackup("restore", "failure")
+			return fmt.Errorf("backup file %s is corrupted: sha256 %s, recorded %s", backupPath, sum, record.SHA256)
+		}
+	}
 
-	// 写入恢复文件
-	if err := os.WriteFile(restorePath, backupData, 0644); err != nil {
+	// 原子写入恢复文件，中途失败时不会留下被截断的 CNI 配置
+	if err := fsutil.WriteFileAtomic(restorePath, backupData, 0644); err != nil {
+		monitoring.RecordCNIConfigBackup("restore", "failure")
 		return fmt.Errorf("failed to write restore file %s: %v", restorePath, err)
 	}
 
@@ -543,11 +650,35 @@ func (cm *CNIConfigManager) restoreBackup(backupFileName string) error {
 	if err := os.Remove(backupPath); err != nil {
 		logging.Warnf("Failed to remove backup file %s: %v", backupPath, err)
 	}
+	if err := cm.forgetBackups(backupFileName); err != nil {
+		logging.Warnf("Failed to update backup manifest: %v", err)
+	}
 
+	monitoring.RecordCNIConfigBackup("restore", "success")
 	logging.Infof("Restored config file: %s -> %s", backupFileName, originalFileName)
 	return nil
 }
 
+// legacyOriginalFileName 从备份文件名中提取原始文件名
+// 格式: original_name.headcni_bak
+func legacyOriginalFileName(backupFileName string) (string, error) {
+	parts := strings.Split(backupFileName, ".headcni_bak")
+	if len(parts) != 2 {
+		return "", fmt.Errorf("invalid backup file name format: %s", backupFileName)
+	}
+	originalFileName := parts[0]
+
+	// 恢复原始扩展名
+	originalExtensions := []string{".conflist", ".conf", ".json", ".yaml", ".yml"}
+	for _, ext := range originalExtensions {
+		if strings.HasSuffix(originalFileName, ext) {
+			return originalFileName, nil
+		}
+	}
+	// 如果没有找到扩展名，添加默认的 .conf
+	return originalFileName + ".conf", nil
+}
+
 // listBackups 列出所有备份文件
 func (cm *CNIConfigManager) listBackups() ([]string, error) {
 	files, err := os.ReadDir(cm.backupDir)
@@ -605,14 +736,19 @@ func (cm *CNIConfigManager) cleanupOldBackups(keepCount int) error {
 	})
 
 	// 删除旧的备份文件
+	var removed []string
 	for i := keepCount; i < len(backupInfos); i++ {
 		backupPath := filepath.Join(cm.backupDir, backupInfos[i].name)
 		if err := os.Remove(backupPath); err != nil {
 			logging.Warnf("Failed to remove old backup %s: %v", backupInfos[i].name, err)
 		} else {
+			removed = append(removed, backupInfos[i].name)
 			logging.Debugf("Removed old backup: %s", backupInfos[i].name)
 		}
 	}
+	if err := cm.forgetBackups(removed...); err != nil {
+		logging.Warnf("Failed to update backup manifest: %v", err)
+	}
 
 	logging.Infof("Cleaned up %d old backup files", len(backupInfos)-keepCount)
 	return nil
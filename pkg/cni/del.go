@@ -0,0 +1,50 @@
+package cni
+
+import (
+	"fmt"
+
+	"github.com/binrclab/headcni/pkg/logging"
+	"github.com/containernetworking/cni/pkg/skel"
+)
+
+// DelSteps cmdDel 的清理步骤，均按容器 ID 定位资源，重复执行不会出错
+type DelSteps struct {
+	// TeardownNetns 进入容器网络命名空间删除网卡；netns 或网卡已不存在时返回的错误视为成功
+	TeardownNetns func() error
+	// ReleaseIPAM 按容器 ID 释放地址分配
+	ReleaseIPAM func() error
+	// CleanupHost 按容器 ID 清理主机侧状态（host veth、路由、结果缓存）；网卡已不存在视为成功
+	CleanupHost func() error
+}
+
+// RunDel 按 CNI 规范幂等地执行 DEL：节点清理时 Pod 的 netns 往往已被删除，
+// 此时跳过 netns 内的清理，照常释放地址和主机侧状态并返回成功，避免 kubelet 反复重试、Pod 卡在 Terminating
+// netns 仍存在但清理失败时直接返回错误且不释放地址，避免仍在使用的地址被分配给其他 Pod
+func RunDel(args *skel.CmdArgs, steps DelSteps) error {
+	if args.Netns != "" && steps.TeardownNetns != nil {
+		if err := steps.TeardownNetns(); err != nil {
+			if !IsNotFound(err) {
+				return fmt.Errorf("failed to tear down %s in %s: %v", args.IfName, args.Netns, err)
+			}
+			logging.Infof("Container %s: %v, skipping netns cleanup", args.ContainerID, err)
+		}
+	}
+
+	var releaseErr error
+	if steps.ReleaseIPAM != nil {
+		if err := steps.ReleaseIPAM(); err != nil {
+			releaseErr = fmt.Errorf("failed to release IPAM allocation of container %s: %v", args.ContainerID, err)
+		}
+	}
+
+	// 地址释放失败时仍然清理主机侧状态，重试时只需再次释放地址
+	if steps.CleanupHost != nil {
+		if err := steps.CleanupHost(); err != nil && !IsNotFound(err) {
+			if releaseErr != nil {
+				return fmt.Errorf("%v; failed to clean up host state: %v", releaseErr, err)
+			}
+			return fmt.Errorf("failed to clean up host state of container %s: %v", args.ContainerID, err)
+		}
+	}
+	return releaseErr
+}
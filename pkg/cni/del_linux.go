@@ -0,0 +1,26 @@
+//go:build linux
+// +build linux
+
+package cni
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/vishvananda/netlink"
+)
+
+// IsNotFound 判断 DEL 过程中的错误是否表示要清理的对象已不存在：
+// netns 路径不存在或已不是网络命名空间（卸载后残留的挂载点文件），以及网卡不存在
+// plugins/pkg/ip 的 ErrLinkNotFound 只能按错误信息匹配，引入该包会增加依赖
+func IsNotFound(err error) bool {
+	if err == nil {
+		return false
+	}
+	var notExist ns.NSPathNotExistErr
+	var notNS ns.NSPathNotNSErr
+	var linkNotFound netlink.LinkNotFoundError
+	return errors.As(err, &notExist) || errors.As(err, &notNS) ||
+		errors.As(err, &linkNotFound) || strings.Contains(strings.ToLower(err.Error()), "link not found")
+}
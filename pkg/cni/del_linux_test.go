@@ -0,0 +1,110 @@
+package cni
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/containernetworking/cni/pkg/skel"
+	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/vishvananda/netlink"
+)
+
+// delRecorder 记录 RunDel 调用了哪些清理步骤
+type delRecorder struct {
+	netnsPath string
+	calls     []string
+	teardown  error
+	release   error
+	host      error
+}
+
+func (r *delRecorder) steps() DelSteps {
+	return DelSteps{
+		TeardownNetns: func() error {
+			r.calls = append(r.calls, "teardown")
+			if r.teardown != nil {
+				return r.teardown
+			}
+			return ns.WithNetNSPath(r.netnsPath, func(ns.NetNS) error { return nil })
+		},
+		ReleaseIPAM: func() error {
+			r.calls = append(r.calls, "release")
+			return r.release
+		},
+		CleanupHost: func() error {
+			r.calls = append(r.calls, "host")
+			return r.host
+		},
+	}
+}
+
+// linkNotFound 返回 netlink 查找不存在的网卡时的错误
+func linkNotFound(t *testing.T) error {
+	t.Helper()
+	_, err := netlink.LinkByName("cni-gone0")
+	if !IsNotFound(err) {
+		t.Fatalf("expected a link not found error, got %v", err)
+	}
+	return err
+}
+
+func TestRunDelMissingNetns(t *testing.T) {
+	// 已卸载的 netns 会留下普通文件，与路径不存在一样视为 netns 已删除
+	leftover := filepath.Join(t.TempDir(), "cni-leftover")
+	if err := os.WriteFile(leftover, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{filepath.Join(t.TempDir(), "cni-missing"), leftover} {
+		r := &delRecorder{netnsPath: path, host: linkNotFound(t)}
+		args := &skel.CmdArgs{ContainerID: "abc123", Netns: path, IfName: "eth0"}
+		if err := RunDel(args, r.steps()); err != nil {
+			t.Fatalf("RunDel with netns %s returned %v", path, err)
+		}
+		if got := strings.Join(r.calls, ","); got != "teardown,release,host" {
+			t.Fatalf("expected all cleanup steps, got %s", got)
+		}
+
+		// 重复 DEL 同样成功
+		if err := RunDel(args, r.steps()); err != nil {
+			t.Fatalf("second RunDel returned %v", err)
+		}
+	}
+}
+
+func TestRunDelEmptyNetns(t *testing.T) {
+	r := &delRecorder{}
+	if err := RunDel(&skel.CmdArgs{ContainerID: "abc123", IfName: "eth0"}, r.steps()); err != nil {
+		t.Fatalf("RunDel returned %v", err)
+	}
+	if got := strings.Join(r.calls, ","); got != "release,host" {
+		t.Fatalf("expected netns teardown to be skipped, got %s", got)
+	}
+}
+
+func TestRunDelUnexpectedErrors(t *testing.T) {
+	args := &skel.CmdArgs{ContainerID: "abc123", Netns: "/var/run/netns/cni-abc123", IfName: "eth0"}
+
+	// netns 内清理失败时不释放地址
+	r := &delRecorder{teardown: errors.New("permission denied")}
+	if err := RunDel(args, r.steps()); err == nil {
+		t.Fatal("expected teardown error to be returned")
+	}
+	if got := strings.Join(r.calls, ","); got != "teardown" {
+		t.Fatalf("expected IPAM to be kept after a failed teardown, got %s", got)
+	}
+
+	// 地址释放失败时仍清理主机侧状态，并返回错误让运行时重试
+	r = &delRecorder{teardown: fmt.Errorf("failed to delete eth0: %w", linkNotFound(t)), release: errors.New("daemon unavailable")}
+	err := RunDel(args, r.steps())
+	if err == nil || !strings.Contains(err.Error(), "daemon unavailable") {
+		t.Fatalf("expected release error, got %v", err)
+	}
+	if got := strings.Join(r.calls, ","); got != "teardown,release,host" {
+		t.Fatalf("expected host cleanup after a failed release, got %s", got)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package cni
+
+// IsNotFound 非 Linux 平台不运行 CNI 插件，没有 netns 和网卡
+func IsNotFound(err error) bool {
+	return false
+}
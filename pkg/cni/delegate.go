@@ -0,0 +1,89 @@
+package cni
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/binrclab/headcni/pkg/ipam"
+	"github.com/containernetworking/cni/pkg/invoke"
+	"github.com/containernetworking/cni/pkg/types"
+	current "github.com/containernetworking/cni/pkg/types/100"
+)
+
+// DelegatedIPAMPlugin ipam.type 为 host-local-delegate 时调用的上游插件
+const DelegatedIPAMPlugin = "host-local"
+
+// HostLocalDelegateIPAM 生成写入 configlist 的 ipam 块：节点子网各一组 range，插件据此委托上游 host-local 分配
+func HostLocalDelegateIPAM(subnets []string, dataDir string) map[string]interface{} {
+	var ranges [][]map[string]interface{}
+	for _, subnet := range subnets {
+		if subnet != "" {
+			ranges = append(ranges, []map[string]interface{}{{"subnet": subnet}})
+		}
+	}
+	block := map[string]interface{}{
+		"type":   ipam.TypeHostLocalDelegate,
+		"ranges": ranges,
+	}
+	if dataDir != "" {
+		block["dataDir"] = dataDir
+	}
+	return block
+}
+
+// IPAMType 返回网络配置中 ipam 块的类型
+func IPAMType(stdinData []byte) (string, error) {
+	conf := &types.NetConf{}
+	if err := json.Unmarshal(stdinData, conf); err != nil {
+		return "", fmt.Errorf("failed to parse network configuration: %v", err)
+	}
+	return conf.IPAM.Type, nil
+}
+
+// DelegateIPAMAdd 把网络配置交给上游 host-local 分配地址，等同于 plugins/pkg/ipam.ExecAdd
+// ipam.type 改写为 host-local，其余字段原样传递；exec 为 nil 时在 CNI_PATH 中查找插件
+func DelegateIPAMAdd(ctx context.Context, stdinData []byte, exec invoke.Exec) (*current.Result, error) {
+	netconf, err := hostLocalNetConf(stdinData)
+	if err != nil {
+		return nil, err
+	}
+	result, err := invoke.DelegateAdd(ctx, DelegatedIPAMPlugin, netconf, exec)
+	if err != nil {
+		return nil, fmt.Errorf("%s failed to allocate: %w", DelegatedIPAMPlugin, err)
+	}
+	converted, err := current.NewResultFromResult(result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert %s result: %v", DelegatedIPAMPlugin, err)
+	}
+	if len(converted.IPs) == 0 {
+		return nil, fmt.Errorf("%s returned no IP addresses", DelegatedIPAMPlugin)
+	}
+	return converted, nil
+}
+
+// DelegateIPAMDel 让上游 host-local 按容器 ID 释放地址，等同于 plugins/pkg/ipam.ExecDel；host-local 对不存在的分配返回成功
+func DelegateIPAMDel(ctx context.Context, stdinData []byte, exec invoke.Exec) error {
+	netconf, err := hostLocalNetConf(stdinData)
+	if err != nil {
+		return err
+	}
+	if err := invoke.DelegateDel(ctx, DelegatedIPAMPlugin, netconf, exec); err != nil {
+		return fmt.Errorf("%s failed to release: %w", DelegatedIPAMPlugin, err)
+	}
+	return nil
+}
+
+// hostLocalNetConf 把网络配置的 ipam.type 改写为 host-local
+func hostLocalNetConf(stdinData []byte) ([]byte, error) {
+	var conf map[string]interface{}
+	if err := json.Unmarshal(stdinData, &conf); err != nil {
+		return nil, fmt.Errorf("failed to parse network configuration: %v", err)
+	}
+	block, ok := conf["ipam"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("network configuration has no ipam block")
+	}
+	block["type"] = DelegatedIPAMPlugin
+	return json.Marshal(conf)
+}
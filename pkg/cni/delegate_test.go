@@ -0,0 +1,88 @@
+package cni
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/containernetworking/cni/pkg/version"
+)
+
+// fakeExec 记录委托调用并返回固定的 host-local 结果
+type fakeExec struct {
+	plugin  string
+	command string
+	stdin   []byte
+	result  string
+}
+
+func (e *fakeExec) ExecPlugin(ctx context.Context, pluginPath string, stdinData []byte, environ []string) ([]byte, error) {
+	e.plugin = pluginPath
+	e.stdin = stdinData
+	for _, env := range environ {
+		if command, ok := strings.CutPrefix(env, "CNI_COMMAND="); ok {
+			e.command = command
+		}
+	}
+	return []byte(e.result), nil
+}
+
+func (e *fakeExec) FindInPath(plugin string, paths []string) (string, error) {
+	return "/opt/cni/bin/" + plugin, nil
+}
+
+func (e *fakeExec) Decode(jsonBytes []byte) (version.PluginInfo, error) {
+	return version.PluginSupports("1.0.0"), nil
+}
+
+const delegateNetConf = `{
+	"cniVersion": "1.0.0",
+	"name": "headcni",
+	"type": "headcni",
+	"ipam": {"type": "host-local-delegate", "ranges": [[{"subnet": "10.244.1.0/24"}]], "dataDir": "/var/lib/cni/networks"}
+}`
+
+func TestDelegateIPAMAdd(t *testing.T) {
+	t.Setenv("CNI_PATH", "/opt/cni/bin")
+	exec := &fakeExec{result: `{"cniVersion": "1.0.0", "ips": [{"address": "10.244.1.5/24", "gateway": "10.244.1.1"}]}`}
+
+	result, err := DelegateIPAMAdd(context.Background(), []byte(delegateNetConf), exec)
+	if err != nil {
+		t.Fatalf("DelegateIPAMAdd failed: %v", err)
+	}
+	if exec.plugin != "/opt/cni/bin/host-local" || exec.command != "ADD" {
+		t.Fatalf("expected ADD to invoke host-local, got %s %s", exec.command, exec.plugin)
+	}
+	if len(result.IPs) != 1 || result.IPs[0].Address.String() != "10.244.1.5/24" {
+		t.Fatalf("unexpected result %+v", result)
+	}
+
+	// ipam 块除类型外原样传递
+	var conf struct {
+		IPAM map[string]interface{} `json:"ipam"`
+	}
+	if err := json.Unmarshal(exec.stdin, &conf); err != nil {
+		t.Fatal(err)
+	}
+	if conf.IPAM["type"] != "host-local" || conf.IPAM["dataDir"] != "/var/lib/cni/networks" || fmt.Sprint(conf.IPAM["ranges"]) != "[[map[subnet:10.244.1.0/24]]]" {
+		t.Fatalf("unexpected ipam block passed to host-local: %v", conf.IPAM)
+	}
+}
+
+func TestDelegateIPAMDel(t *testing.T) {
+	t.Setenv("CNI_PATH", "/opt/cni/bin")
+	exec := &fakeExec{}
+
+	if err := DelegateIPAMDel(context.Background(), []byte(delegateNetConf), exec); err != nil {
+		t.Fatalf("DelegateIPAMDel failed: %v", err)
+	}
+	if exec.plugin != "/opt/cni/bin/host-local" || exec.command != "DEL" {
+		t.Fatalf("expected DEL to invoke host-local, got %s %s", exec.command, exec.plugin)
+	}
+
+	if ipamType, err := IPAMType([]byte(delegateNetConf)); err != nil || ipamType != "host-local-delegate" {
+		t.Fatalf("IPAMType() = %q, %v", ipamType, err)
+	}
+}
@@ -0,0 +1,107 @@
+package cni
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/binrclab/headcni/pkg/logging"
+)
+
+const (
+	// MaxDNSSearchDomains resolv.conf 中 search 列表的域名数上限（glibc 2.26 之前及 musl 的限制）
+	MaxDNSSearchDomains = 6
+	// MaxDNSSearchLength resolv.conf 中 search 行的字符数上限
+	MaxDNSSearchLength = 256
+
+	// 搜索域模板中的占位符
+	SearchTokenClusterDomain = "{cluster_domain}"
+	SearchTokenNamespace     = "{namespace}"
+	SearchTokenTailnetDomain = "{tailnet_domain}"
+)
+
+// searchDomainPattern 搜索域格式：由点分隔的小写 DNS 标签，不带首尾的点
+var searchDomainPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?(\.[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?)*$`)
+
+// searchTokenPattern 匹配模板中的占位符
+var searchTokenPattern = regexp.MustCompile(`\{[^{}]*\}`)
+
+// ValidateSearchTemplate 检查模板只使用已知的占位符；tailnetDomain 为空时不能使用 {tailnet_domain}
+func ValidateSearchTemplate(template []string, tailnetDomain string) error {
+	for _, entry := range template {
+		for _, token := range searchTokenPattern.FindAllString(entry, -1) {
+			switch token {
+			case SearchTokenClusterDomain, SearchTokenNamespace:
+			case SearchTokenTailnetDomain:
+				if tailnetDomain == "" {
+					return fmt.Errorf("search template %q uses %s but no tailnet domain is configured", entry, token)
+				}
+			default:
+				return fmt.Errorf("search template %q uses unknown token %s", entry, token)
+			}
+		}
+	}
+	return nil
+}
+
+// ExpandSearchTemplate 按 Pod 展开搜索域模板，返回写入 Pod resolv.conf 的 search 列表
+// 展开后不合法的域名（如 namespace 为空时的 ".svc.cluster.local"）和重复项被跳过，结果按 resolv.conf 的限制截断
+func ExpandSearchTemplate(template []string, clusterDomain, namespace, tailnetDomain string) []string {
+	replacer := strings.NewReplacer(
+		SearchTokenClusterDomain, clusterDomain,
+		SearchTokenNamespace, namespace,
+		SearchTokenTailnetDomain, tailnetDomain,
+	)
+
+	var domains []string
+	for _, entry := range template {
+		domain := strings.ToLower(replacer.Replace(entry))
+		if len(domain) > 253 || !searchDomainPattern.MatchString(domain) {
+			logging.Warnf("Skipping search domain %q expanded from template %q", domain, entry)
+			continue
+		}
+		domains = append(domains, domain)
+	}
+	return CapSearchDomains(domains)
+}
+
+// CapSearchDomains 去重并把 search 列表截断到 MaxDNSSearchDomains 个域名、MaxDNSSearchLength 个字符以内，保留靠前的域名
+func CapSearchDomains(domains []string) []string {
+	var capped []string
+	seen := make(map[string]bool, len(domains))
+	length := len("search")
+	for _, domain := range domains {
+		if seen[domain] {
+			continue
+		}
+		if len(capped) == MaxDNSSearchDomains || length+1+len(domain) > MaxDNSSearchLength {
+			logging.Warnf("DNS search list exceeds resolv.conf limits, dropping %q and later domains", domain)
+			break
+		}
+		seen[domain] = true
+		length += 1 + len(domain)
+		capped = append(capped, domain)
+	}
+	return capped
+}
+
+// SearchForPod 返回某个命名空间下 Pod 的 search 列表：配置了模板时按模板展开，否则使用 Search
+func (d *DNS) SearchForPod(namespace string) []string {
+	if d == nil {
+		return nil
+	}
+	if len(d.SearchTemplate) == 0 {
+		return CapSearchDomains(d.Search)
+	}
+	return ExpandSearchTemplate(d.SearchTemplate, d.ClusterDomain, namespace, d.TailnetDomain)
+}
+
+// PodNamespaceFromArgs 从 CNI_ARGS（如 "IgnoreUnknown=1;K8S_POD_NAMESPACE=default;K8S_POD_NAME=web"）中读取 Pod 的命名空间
+func PodNamespaceFromArgs(args string) string {
+	for _, pair := range strings.Split(args, ";") {
+		if key, value, ok := strings.Cut(pair, "="); ok && key == "K8S_POD_NAMESPACE" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,67 @@
+package cni
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSearchForPodExpandsTemplate(t *testing.T) {
+	dns := &DNS{
+		SearchTemplate: []string{
+			"{namespace}.svc.{cluster_domain}",
+			"svc.{cluster_domain}",
+			"{cluster_domain}",
+			"{namespace}.{tailnet_domain}",
+			"{tailnet_domain}",
+			"svc.{cluster_domain}",
+		},
+		ClusterDomain: "cluster.local",
+		TailnetDomain: "ts.example.com",
+	}
+
+	got := dns.SearchForPod(PodNamespaceFromArgs("IgnoreUnknown=1;K8S_POD_NAMESPACE=payments;K8S_POD_NAME=web-0"))
+	want := []string{"payments.svc.cluster.local", "svc.cluster.local", "cluster.local", "payments.ts.example.com", "ts.example.com"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("SearchForPod() = %v, want %v", got, want)
+	}
+
+	// 没有命名空间时跳过依赖 {namespace} 的项
+	got = dns.SearchForPod("")
+	want = []string{"svc.cluster.local", "cluster.local", "ts.example.com"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("SearchForPod(\"\") = %v, want %v", got, want)
+	}
+}
+
+func TestCapSearchDomains(t *testing.T) {
+	var domains []string
+	for i := 0; i < 8; i++ {
+		domains = append(domains, fmt.Sprintf("d%d.example.com", i))
+	}
+	if got := CapSearchDomains(domains); len(got) != MaxDNSSearchDomains || got[0] != "d0.example.com" {
+		t.Fatalf("expected the first %d domains, got %v", MaxDNSSearchDomains, got)
+	}
+
+	long := []string{strings.Repeat("a", 60) + ".example.com", strings.Repeat("b", 60) + ".example.com", strings.Repeat("c", 60) + ".example.com", strings.Repeat("d", 60) + ".example.com"}
+	got := CapSearchDomains(long)
+	if len(got) != 3 {
+		t.Fatalf("expected the search line to be capped at %d characters, got %v", MaxDNSSearchLength, got)
+	}
+	if length := len("search " + strings.Join(got, " ")); length > MaxDNSSearchLength {
+		t.Fatalf("search line is %d characters", length)
+	}
+}
+
+func TestValidateSearchTemplate(t *testing.T) {
+	if err := ValidateSearchTemplate([]string{"{namespace}.svc.{cluster_domain}", "{tailnet_domain}"}, "ts.example.com"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := ValidateSearchTemplate([]string{"{tailnet_domain}"}, ""); err == nil {
+		t.Fatal("expected an error when {tailnet_domain} is used without a tailnet domain")
+	}
+	if err := ValidateSearchTemplate([]string{"{pod}.svc.{cluster_domain}"}, ""); err == nil {
+		t.Fatal("expected an error for an unknown token")
+	}
+}
@@ -0,0 +1,54 @@
+package cni
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/containernetworking/cni/pkg/types"
+)
+
+// ErrCodeSubnetExhausted 节点 Pod CIDR 已无可用地址
+// CNI 规范保留 0-99 作为通用错误码，100 以上由插件定义；该值保持稳定，供自动扩缩容等控制器识别
+const ErrCodeSubnetExhausted uint = 120
+
+// subnetExhaustedDetails 子网耗尽时给出的处理建议
+const subnetExhaustedDetails = "consider a larger per-node CIDR or enabling IP reclamation (ipam.leakReconcile.enabled)"
+
+// NewSubnetExhaustedError 构造子网耗尽的 CNI 错误，total 小于 0 表示容量未知
+func NewSubnetExhaustedError(subnet string, used, total int) *types.Error {
+	msg := fmt.Sprintf("subnet %s exhausted: %d/%d addresses allocated", subnet, used, total)
+	if total < 0 {
+		msg = fmt.Sprintf("subnet %s exhausted: %d addresses allocated", subnet, used)
+	}
+	return types.NewError(ErrCodeSubnetExhausted, msg, subnetExhaustedDetails)
+}
+
+// IsSubnetExhausted 判断错误是否为子网耗尽
+func IsSubnetExhausted(err error) bool {
+	cniErr, ok := err.(*types.Error)
+	return ok && cniErr.Code == ErrCodeSubnetExhausted
+}
+
+// IsHostLocalExhausted 判断 host-local 插件返回的错误是否为地址耗尽
+// host-local 没有错误码，只能匹配其固定的错误信息
+func IsHostLocalExhausted(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "no IP addresses available in range set")
+}
+
+// SubnetExhaustedResponse 把子网耗尽错误编码为 daemon 响应
+func SubnetExhaustedResponse(err *types.Error) *CNIResponse {
+	return &CNIResponse{
+		Success: false,
+		Error:   err.Msg,
+		Code:    err.Code,
+		Details: err.Details,
+	}
+}
+
+// Err 把失败的响应转换为错误；带错误码时返回 CNI types.Error，插件可直接交给运行时
+func (r *CNIResponse) Err(prefix string) error {
+	if r.Code != 0 {
+		return types.NewError(r.Code, r.Error, r.Details)
+	}
+	return fmt.Errorf("%s: %s", prefix, r.Error)
+}
@@ -0,0 +1,42 @@
+package cni
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSubnetExhaustedErrorRoundTrip(t *testing.T) {
+	cniErr := NewSubnetExhaustedError("10.244.3.0/24", 253, 253)
+	if cniErr.Msg != "subnet 10.244.3.0/24 exhausted: 253/253 addresses allocated" {
+		t.Errorf("unexpected message: %q", cniErr.Msg)
+	}
+
+	// daemon 响应经过 JSON 编码后，插件侧仍能还原出相同错误码的 CNI 错误
+	data, err := json.Marshal(SubnetExhaustedResponse(cniErr))
+	if err != nil {
+		t.Fatalf("failed to encode response: %v", err)
+	}
+	var resp CNIResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	err = resp.Err("allocation failed")
+	if !IsSubnetExhausted(err) {
+		t.Fatalf("expected a subnet exhausted error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "larger per-node CIDR") {
+		t.Errorf("expected guidance in error, got %q", err.Error())
+	}
+
+	plain := (&CNIResponse{Error: "boom"}).Err("allocation failed")
+	if IsSubnetExhausted(plain) || plain.Error() != "allocation failed: boom" {
+		t.Errorf("unexpected plain error: %v", plain)
+	}
+
+	if !IsHostLocalExhausted(errors.New("failed to allocate for range 0: no IP addresses available in range set: 10.244.3.1-10.244.3.254")) {
+		t.Errorf("expected host-local exhaustion to be detected")
+	}
+}
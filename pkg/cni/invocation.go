@@ -0,0 +1,247 @@
+package cni
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/binrclab/headcni/pkg/utils/fsutil"
+	"github.com/containernetworking/cni/pkg/skel"
+)
+
+// InvocationDumpEnv 设置为目录时，CNI 插件把每次调用收到的 stdin 配置和 CNI_* 环境变量写入该目录，
+// 供 headcni diagnostics 收集并重放；kubelet/容器运行时调用插件时会传递自身的环境变量
+const InvocationDumpEnv = "HEADCNI_CNI_DUMP_DIR"
+
+// maxInvocationDumps 目录中保留的调用记录数，超出时删除最旧的记录
+const maxInvocationDumps = 200
+
+// maskedValue 替换敏感字段的值
+const maskedValue = "***"
+
+// secretKeyWords 字段名（不区分大小写）包含其中任一词时视为密钥，值被掩码
+var secretKeyWords = []string{"key", "secret", "token", "password", "passwd", "credential"}
+
+// Invocation 一次 CNI 调用的原始输入
+type Invocation struct {
+	Time        time.Time `json:"time"`
+	Command     string    `json:"command"`
+	ContainerID string    `json:"containerID"`
+	Netns       string    `json:"netns"`
+	IfName      string    `json:"ifName"`
+	Args        string    `json:"args"`
+	Path        string    `json:"path"`
+	// StdinData 插件收到的网络配置；没有需要掩码的字段时与原始输入逐字节一致
+	StdinData string `json:"stdinData"`
+	// Masked StdinData 或 Args 中有字段被掩码，重放前需要补上原值
+	Masked bool `json:"masked,omitempty"`
+}
+
+// NewInvocation 记录 command 调用的输入，掩码配置和 CNI_ARGS 中的密钥字段
+func NewInvocation(command string, args *skel.CmdArgs) *Invocation {
+	stdin, stdinMasked := maskConfigSecrets(args.StdinData)
+	cniArgs, argsMasked := maskArgsSecrets(args.Args)
+	return &Invocation{
+		Time:        time.Now(),
+		Command:     command,
+		ContainerID: args.ContainerID,
+		Netns:       args.Netns,
+		IfName:      args.IfName,
+		Args:        cniArgs,
+		Path:        args.Path,
+		StdinData:   string(stdin),
+		Masked:      stdinMasked || argsMasked,
+	}
+}
+
+// Env 返回重放本次调用所需的 CNI_* 环境变量
+func (inv *Invocation) Env() []string {
+	return []string{
+		"CNI_COMMAND=" + inv.Command,
+		"CNI_CONTAINERID=" + inv.ContainerID,
+		"CNI_NETNS=" + inv.Netns,
+		"CNI_IFNAME=" + inv.IfName,
+		"CNI_ARGS=" + inv.Args,
+		"CNI_PATH=" + inv.Path,
+	}
+}
+
+// PluginType 返回网络配置中的插件类型（type 字段），即被调用的插件二进制名
+func (inv *Invocation) PluginType() (string, error) {
+	var conf struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal([]byte(inv.StdinData), &conf); err != nil {
+		return "", fmt.Errorf("invalid network config: %v", err)
+	}
+	if conf.Type == "" {
+		return "", fmt.Errorf("network config has no type")
+	}
+	return conf.Type, nil
+}
+
+// DumpInvocationFromEnv 设置了 InvocationDumpEnv 时记录本次调用的输入，返回记录文件路径
+// 在 cmdAdd/cmdDel/cmdCheck 开头调用；未设置时不做任何事，写入失败时静默忽略，记录不能影响 CNI 调用结果
+func DumpInvocationFromEnv(command string, args *skel.CmdArgs) string {
+	dir := os.Getenv(InvocationDumpEnv)
+	if dir == "" {
+		return ""
+	}
+	path, err := DumpInvocation(dir, command, args)
+	if err != nil {
+		return ""
+	}
+	return path
+}
+
+// DumpInvocation 把本次调用的输入写入 dir 下的独立文件，并删除超出 maxInvocationDumps 的旧记录
+// 文件名以 UTC 时间开头，按名称排序即按调用时间排序
+func DumpInvocation(dir, command string, args *skel.CmdArgs) (string, error) {
+	inv := NewInvocation(command, args)
+	data, err := json.MarshalIndent(inv, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal invocation: %v", err)
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create invocation dump directory: %v", err)
+	}
+	name := fmt.Sprintf("%s-%s-%s.json", inv.Time.UTC().Format("20060102T150405.000000000"),
+		strings.ToLower(command), sanitizeContainerID(args.ContainerID))
+	path := filepath.Join(dir, name)
+	// 网络配置可能包含内部地址等信息，只允许 root 读取
+	if err := fsutil.WriteFileAtomic(path, data, 0600); err != nil {
+		return "", fmt.Errorf("failed to write invocation dump: %v", err)
+	}
+
+	pruneInvocations(dir, maxInvocationDumps)
+	return path, nil
+}
+
+// ReadInvocation 读取 DumpInvocation 写入的调用记录
+func ReadInvocation(path string) (*Invocation, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var inv Invocation
+	if err := json.Unmarshal(data, &inv); err != nil {
+		return nil, fmt.Errorf("invalid invocation dump %s: %v", path, err)
+	}
+	return &inv, nil
+}
+
+// ListInvocations 返回 dir 中的调用记录文件，最新的在前
+func ListInvocations(dir string) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(paths)))
+	return paths, nil
+}
+
+// pruneInvocations 只保留最新的 keep 条调用记录
+func pruneInvocations(dir string, keep int) {
+	paths, err := ListInvocations(dir)
+	if err != nil || len(paths) <= keep {
+		return
+	}
+	for _, path := range paths[keep:] {
+		os.Remove(path)
+	}
+}
+
+// sanitizeContainerID 把容器 ID 截断为 12 个字符并去掉不能出现在文件名中的字符
+func sanitizeContainerID(containerID string) string {
+	id := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return -1
+	}, containerID)
+	if len(id) > 12 {
+		id = id[:12]
+	}
+	if id == "" {
+		id = "unknown"
+	}
+	return id
+}
+
+// isSecretKey 判断字段名是否像密钥
+func isSecretKey(name string) bool {
+	name = strings.ToLower(name)
+	for _, word := range secretKeyWords {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// maskConfigSecrets 掩码网络配置中名称像密钥的字符串字段，返回结果以及是否有字段被掩码
+// 没有需要掩码的字段或配置不是 JSON 时原样返回，保留原始输入的字段顺序和格式
+func maskConfigSecrets(data []byte) ([]byte, bool) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var conf interface{}
+	if err := decoder.Decode(&conf); err != nil {
+		return data, false
+	}
+	if !maskValue(conf) {
+		return data, false
+	}
+	masked, err := json.Marshal(conf)
+	if err != nil {
+		return data, false
+	}
+	return masked, true
+}
+
+// maskValue 递归掩码 JSON 值中名称像密钥的字符串字段，返回是否有字段被掩码
+func maskValue(value interface{}) bool {
+	masked := false
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if s, ok := field.(string); ok && s != "" && isSecretKey(key) {
+				v[key] = maskedValue
+				masked = true
+				continue
+			}
+			if maskValue(field) {
+				masked = true
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if maskValue(item) {
+				masked = true
+			}
+		}
+	}
+	return masked
+}
+
+// maskArgsSecrets 掩码 CNI_ARGS（K=V;K2=V2）中名称像密钥的值
+func maskArgsSecrets(args string) (string, bool) {
+	if args == "" {
+		return args, false
+	}
+	pairs := strings.Split(args, ";")
+	masked := false
+	for i, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		if ok && value != "" && isSecretKey(key) {
+			pairs[i] = key + "=" + maskedValue
+			masked = true
+		}
+	}
+	return strings.Join(pairs, ";"), masked
+}
@@ -0,0 +1,114 @@
+package cni
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/containernetworking/cni/pkg/skel"
+)
+
+func TestDumpInvocationMasksSecrets(t *testing.T) {
+	dir := t.TempDir()
+	args := &skel.CmdArgs{
+		ContainerID: "0123456789abcdef0123",
+		Netns:       "/var/run/netns/cni-1234",
+		IfName:      "eth0",
+		Args:        "IgnoreUnknown=1;K8S_POD_NAMESPACE=default;K8S_POD_NAME=web-0;AUTH_TOKEN=abc",
+		Path:        "/opt/cni/bin",
+		StdinData:   []byte(`{"cniVersion":"1.0.0","name":"headcni","type":"headcni","headscale":{"apiKey":"hskey-123","url":"https://hs.example.com"},"mtu":1280}`),
+	}
+
+	path, err := DumpInvocation(dir, "ADD", args)
+	if err != nil {
+		t.Fatalf("DumpInvocation failed: %v", err)
+	}
+	if !strings.HasSuffix(path, "-add-0123456789ab.json") {
+		t.Fatalf("unexpected dump file name %s", path)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("expected a 0600 dump file, got %v, %v", info, err)
+	}
+
+	inv, err := ReadInvocation(path)
+	if err != nil {
+		t.Fatalf("ReadInvocation failed: %v", err)
+	}
+	if !inv.Masked || strings.Contains(inv.StdinData, "hskey-123") || strings.Contains(inv.Args, "abc") {
+		t.Fatalf("expected secrets to be masked, got %+v", inv)
+	}
+	// 网络相关字段不掩码
+	for _, want := range []string{`"url":"https://hs.example.com"`, `"mtu":1280`} {
+		if !strings.Contains(inv.StdinData, want) {
+			t.Fatalf("expected %s in %s", want, inv.StdinData)
+		}
+	}
+	if !strings.Contains(inv.Args, "K8S_POD_NAME=web-0") || !strings.Contains(inv.Args, "AUTH_TOKEN=***") {
+		t.Fatalf("unexpected CNI_ARGS %q", inv.Args)
+	}
+	if pluginType, err := inv.PluginType(); err != nil || pluginType != "headcni" {
+		t.Fatalf("PluginType() = %q, %v", pluginType, err)
+	}
+	env := strings.Join(inv.Env(), "\n")
+	for _, want := range []string{"CNI_COMMAND=ADD", "CNI_CONTAINERID=0123456789abcdef0123", "CNI_NETNS=/var/run/netns/cni-1234", "CNI_IFNAME=eth0", "CNI_PATH=/opt/cni/bin"} {
+		if !strings.Contains(env, want) {
+			t.Fatalf("expected %s in replay env:\n%s", want, env)
+		}
+	}
+}
+
+func TestDumpInvocationKeepsRawConfigWithoutSecrets(t *testing.T) {
+	stdin := "{\n  \"type\": \"headcni\",\n  \"name\": \"headcni\"\n}"
+	path, err := DumpInvocation(t.TempDir(), "DEL", &skel.CmdArgs{ContainerID: "abc", StdinData: []byte(stdin)})
+	if err != nil {
+		t.Fatalf("DumpInvocation failed: %v", err)
+	}
+	inv, err := ReadInvocation(path)
+	if err != nil {
+		t.Fatalf("ReadInvocation failed: %v", err)
+	}
+	if inv.Masked || inv.StdinData != stdin {
+		t.Fatalf("expected the raw config to be kept, got %q (masked %t)", inv.StdinData, inv.Masked)
+	}
+}
+
+func TestDumpInvocationPrunesOldDumps(t *testing.T) {
+	dir := t.TempDir()
+	for i := 0; i < maxInvocationDumps+5; i++ {
+		name := filepath.Join(dir, fmt.Sprintf("20200101T000000.%09d-add-old.json", i))
+		if err := os.WriteFile(name, []byte("{}"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path, err := DumpInvocation(dir, "ADD", &skel.CmdArgs{ContainerID: "new", StdinData: []byte(`{}`)})
+	if err != nil {
+		t.Fatalf("DumpInvocation failed: %v", err)
+	}
+	paths, err := ListInvocations(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != maxInvocationDumps || paths[0] != path {
+		t.Fatalf("expected %d dumps with the newest first, got %d (first %s)", maxInvocationDumps, len(paths), paths[0])
+	}
+	if _, err := os.Stat(filepath.Join(dir, "20200101T000000.000000000-add-old.json")); !os.IsNotExist(err) {
+		t.Fatalf("expected the oldest dump to be removed: %v", err)
+	}
+}
+
+func TestDumpInvocationFromEnv(t *testing.T) {
+	t.Setenv(InvocationDumpEnv, "")
+	if path := DumpInvocationFromEnv("ADD", &skel.CmdArgs{ContainerID: "abc"}); path != "" {
+		t.Fatalf("expected no dump without %s, got %s", InvocationDumpEnv, path)
+	}
+
+	dir := t.TempDir()
+	t.Setenv(InvocationDumpEnv, dir)
+	path := DumpInvocationFromEnv("CHECK", &skel.CmdArgs{ContainerID: "../../etc", StdinData: []byte(`{}`)})
+	if filepath.Dir(path) != dir || !strings.HasSuffix(path, "-check-etc.json") {
+		t.Fatalf("unexpected dump path %q", path)
+	}
+}
@@ -0,0 +1,84 @@
+package cni
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/binrclab/headcni/pkg/constants"
+	"github.com/binrclab/headcni/pkg/logging"
+)
+
+// PluginLogEntry CNI 插件日志文件中的一行
+type PluginLogEntry struct {
+	Time        time.Time `json:"ts"`
+	Level       string    `json:"level"`
+	Plugin      string    `json:"plugin"`
+	Command     string    `json:"command,omitempty"`
+	ContainerID string    `json:"containerID,omitempty"`
+	Message     string    `json:"msg"`
+}
+
+// PluginLogger CNI 插件日志器，每条日志作为一行 JSON 追加到日志文件，供 headcni logs 与 daemon 日志合并
+// 插件每次 ADD/DEL 都是独立进程，日志文件由 logging.RotatingFile 加锁写入并按大小轮转，
+// 并发调用的日志行不会交错，节点上频繁创建删除 Pod 时日志也不会无限增长
+// 写入失败时静默丢弃，日志不能影响 CNI 调用结果
+type PluginLogger struct {
+	file        *logging.RotatingFile
+	plugin      string
+	command     string
+	containerID string
+}
+
+// NewPluginLogger 创建插件日志器，path 为空时使用 constants.DefaultCNIPluginLogFile；
+// maxSizeMB、maxBackups 不大于 0 时使用 logging.RotatingFile 的默认值
+func NewPluginLogger(path, plugin string, maxSizeMB, maxBackups int) *PluginLogger {
+	if path == "" {
+		path = constants.DefaultCNIPluginLogFile
+	}
+	return &PluginLogger{file: logging.NewRotatingFile(path, maxSizeMB, maxBackups), plugin: plugin}
+}
+
+// NewPluginLoggerForConfig 按网络配置中的 logFile、logMaxSizeMB、logMaxBackups 创建插件日志器
+func NewPluginLoggerForConfig(conf *CNIPlugin, plugin string) *PluginLogger {
+	if conf == nil {
+		return NewPluginLogger("", plugin, 0, 0)
+	}
+	return NewPluginLogger(conf.LogFile, plugin, conf.LogMaxSizeMB, conf.LogMaxBackups)
+}
+
+// WithCommand 返回记录本次调用命令（ADD/DEL/CHECK）和容器 ID 的日志器
+func (l *PluginLogger) WithCommand(command, containerID string) *PluginLogger {
+	logger := *l
+	logger.command = command
+	logger.containerID = containerID
+	return &logger
+}
+
+func (l *PluginLogger) Infof(template string, args ...interface{}) {
+	l.write("INFO", template, args...)
+}
+
+func (l *PluginLogger) Warnf(template string, args ...interface{}) {
+	l.write("WARN", template, args...)
+}
+
+func (l *PluginLogger) Errorf(template string, args ...interface{}) {
+	l.write("ERROR", template, args...)
+}
+
+// write 序列化一条日志并追加到日志文件
+func (l *PluginLogger) write(level, template string, args ...interface{}) {
+	line, err := json.Marshal(&PluginLogEntry{
+		Time:        time.Now(),
+		Level:       level,
+		Plugin:      l.plugin,
+		Command:     l.command,
+		ContainerID: l.containerID,
+		Message:     fmt.Sprintf(template, args...),
+	})
+	if err != nil {
+		return
+	}
+	l.file.Write(append(line, '\n'))
+}
@@ -0,0 +1,51 @@
+package cni
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestPluginLoggerAppendsJSONLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "cni-plugin.log")
+	logger := NewPluginLogger(path, "headcni", 0, 0).WithCommand("ADD", "abc123")
+
+	// 模拟多个插件进程并发写入
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			logger.Infof("allocated IP for pod %d", i)
+		}(i)
+	}
+	wg.Wait()
+	logger.Errorf("failed to set up veth")
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open log file: %v", err)
+	}
+	defer file.Close()
+
+	var entries []PluginLogEntry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry PluginLogEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("line %q is not a JSON log entry: %v", scanner.Text(), err)
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) != 21 {
+		t.Fatalf("expected 21 log entries, got %d", len(entries))
+	}
+
+	last := entries[len(entries)-1]
+	if last.Level != "ERROR" || last.Plugin != "headcni" || last.Command != "ADD" || last.ContainerID != "abc123" || last.Time.IsZero() {
+		t.Fatalf("unexpected log entry %+v", last)
+	}
+}
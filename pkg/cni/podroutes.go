@@ -0,0 +1,32 @@
+package cni
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/binrclab/headcni/pkg/logging"
+)
+
+// ParsePodRoutes 校验 NetConf 中的 pod_routes 并返回需要在容器网络命名空间中安装的网段
+// 非法 CIDR 返回错误；与默认路由重复的 0.0.0.0/0、::/0 以及重复的网段被跳过
+func ParsePodRoutes(routes []string) ([]*net.IPNet, error) {
+	var parsed []*net.IPNet
+	seen := make(map[string]bool, len(routes))
+	for _, route := range routes {
+		_, dst, err := net.ParseCIDR(strings.TrimSpace(route))
+		if err != nil {
+			return nil, fmt.Errorf("invalid pod route %q: %v", route, err)
+		}
+		if ones, _ := dst.Mask.Size(); ones == 0 {
+			logging.Warnf("Skipping pod route %s, it duplicates the default route", route)
+			continue
+		}
+		if seen[dst.String()] {
+			continue
+		}
+		seen[dst.String()] = true
+		parsed = append(parsed, dst)
+	}
+	return parsed, nil
+}
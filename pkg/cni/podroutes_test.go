@@ -0,0 +1,17 @@
+package cni
+
+import "testing"
+
+func TestParsePodRoutes(t *testing.T) {
+	routes, err := ParsePodRoutes([]string{"192.168.100.0/24", " 10.20.0.1/16", "0.0.0.0/0", "::/0", "192.168.100.0/24"})
+	if err != nil {
+		t.Fatalf("ParsePodRoutes failed: %v", err)
+	}
+	if len(routes) != 2 || routes[0].String() != "192.168.100.0/24" || routes[1].String() != "10.20.0.0/16" {
+		t.Errorf("unexpected pod routes: %v", routes)
+	}
+
+	if _, err := ParsePodRoutes([]string{"192.168.100.0"}); err == nil {
+		t.Errorf("expected an error for a route without prefix length")
+	}
+}
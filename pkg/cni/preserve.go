@@ -0,0 +1,152 @@
+package cni
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/binrclab/headcni/pkg/logging"
+)
+
+// SetPreservePlugins 设置保留的外部 CNI：每项为配置目录中的文件名（如 10-calico.conflist）或插件类型（如 calico）
+// 匹配的配置文件不备份、保持原样，其中的插件加入生成的 configlist，排在 headcni 之前
+func (cm *CNIConfigManager) SetPreservePlugins(names []string) {
+	cm.preservePlugins = names
+}
+
+// isPreserved 配置文件是否按文件名或其中的插件类型被保留
+func (cm *CNIConfigManager) isPreserved(fileName string) bool {
+	if len(cm.preservePlugins) == 0 || fileName == cm.configName {
+		return false
+	}
+	for _, name := range cm.preservePlugins {
+		if name == fileName {
+			return true
+		}
+	}
+	plugins, err := readConfigPlugins(filepath.Join(cm.configDir, fileName))
+	if err != nil {
+		return false
+	}
+	for _, plugin := range plugins {
+		if cm.preservesType(plugin) {
+			return true
+		}
+	}
+	return false
+}
+
+// preservesType 插件类型是否在保留列表中
+func (cm *CNIConfigManager) preservesType(plugin map[string]interface{}) bool {
+	pluginType, _ := plugin["type"].(string)
+	for _, name := range cm.preservePlugins {
+		if pluginType != "" && name == pluginType {
+			return true
+		}
+	}
+	return false
+}
+
+// preservedPlugins 返回保留的配置中要加入链的插件，按文件名排序
+// 按文件名保留时加入文件中的全部插件，按类型保留时只加入该类型的插件
+func (cm *CNIConfigManager) preservedPlugins() []map[string]interface{} {
+	if len(cm.preservePlugins) == 0 {
+		return nil
+	}
+	files, err := os.ReadDir(cm.configDir)
+	if err != nil {
+		logging.Warnf("Failed to read config directory for preserved plugins: %v", err)
+		return nil
+	}
+	var names []string
+	for _, file := range files {
+		if !file.IsDir() && file.Name() != cm.configName && isCNIConfigFile(file.Name()) {
+			names = append(names, file.Name())
+		}
+	}
+	sort.Strings(names)
+
+	var chained []map[string]interface{}
+	for _, fileName := range names {
+		plugins, err := readConfigPlugins(filepath.Join(cm.configDir, fileName))
+		if err != nil {
+			continue
+		}
+		byName := false
+		for _, name := range cm.preservePlugins {
+			if name == fileName {
+				byName = true
+			}
+		}
+		matched := 0
+		for _, plugin := range plugins {
+			if byName || cm.preservesType(plugin) {
+				chained = append(chained, plugin)
+				matched++
+				logging.Infof("Chaining preserved plugin %v from %s", plugin["type"], fileName)
+			}
+		}
+		// 容器运行时只加载按文件名排序的第一个配置，排在 headcni 之前的保留文件会让 headcni 的链不生效
+		if matched > 0 && fileName < cm.configName {
+			logging.Warnf("Preserved config %s sorts before %s, the container runtime will load it instead of the headcni chain", fileName, cm.configName)
+		}
+	}
+	return chained
+}
+
+// PreservedPluginTypes 返回 names 对应的保留插件类型，按文件名保留时读取 configDir 中该文件的全部插件类型
+// 生成的 configlist 中这些插件排在 headcni 之前
+func PreservedPluginTypes(configDir string, names []string) map[string]bool {
+	types := make(map[string]bool)
+	for _, name := range names {
+		if !isCNIConfigFile(name) {
+			types[name] = true
+			continue
+		}
+		plugins, err := readConfigPlugins(filepath.Join(configDir, name))
+		if err != nil {
+			continue
+		}
+		for _, plugin := range plugins {
+			if pluginType, _ := plugin["type"].(string); pluginType != "" {
+				types[pluginType] = true
+			}
+		}
+	}
+	return types
+}
+
+// isCNIConfigFile 容器运行时会加载的 CNI 配置文件后缀
+func isCNIConfigFile(fileName string) bool {
+	return strings.HasSuffix(fileName, ".conflist") || strings.HasSuffix(fileName, ".conf") || strings.HasSuffix(fileName, ".json")
+}
+
+// readConfigPlugins 读取 configlist 中的插件，或把单个网络配置作为一个插件返回，去掉链中不需要的 cniVersion 和 name
+func readConfigPlugins(path string) ([]map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var conf map[string]interface{}
+	if err := json.Unmarshal(data, &conf); err != nil {
+		return nil, err
+	}
+
+	var plugins []map[string]interface{}
+	if list, ok := conf["plugins"].([]interface{}); ok {
+		for _, entry := range list {
+			if plugin, ok := entry.(map[string]interface{}); ok {
+				plugins = append(plugins, plugin)
+			}
+		}
+	} else if _, ok := conf["type"]; ok {
+		plugins = append(plugins, conf)
+	}
+	for _, plugin := range plugins {
+		delete(plugin, "cniVersion")
+		delete(plugin, "name")
+	}
+	return plugins, nil
+}
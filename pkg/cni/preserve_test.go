@@ -0,0 +1,60 @@
+package cni
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/binrclab/headcni/cmd/daemon/config"
+)
+
+func TestPreservePluginsKeepsAndChainsForeignCNI(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"20-calico.conflist": `{"cniVersion":"0.3.1","name":"k8s-pod-network","plugins":[{"type":"calico","ipam":{"type":"calico-ipam"}},{"type":"bandwidth"}]}`,
+		"30-cilium.conf":     `{"cniVersion":"0.3.1","name":"cilium","type":"cilium-cni"}`,
+		"87-podman.conflist": `{"cniVersion":"0.4.0","name":"podman","plugins":[{"type":"bridge"}]}`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cm := NewCNIConfigManager(dir, "10-headcni.conflist", filepath.Join(dir, "env.yaml"), nil)
+	// calico 按类型保留（同文件的 bandwidth 不加入链），cilium 按文件名保留
+	cm.SetPreservePlugins([]string{"calico", "30-cilium.conf"})
+	if err := cm.BackupOtherConfigLists(); err != nil {
+		t.Fatalf("BackupOtherConfigLists failed: %v", err)
+	}
+	if err := cm.backupExistingConfigs(); err != nil {
+		t.Fatalf("backupExistingConfigs failed: %v", err)
+	}
+
+	for name, content := range files {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		preserved := name != "87-podman.conflist"
+		if preserved && (err != nil || string(data) != content) {
+			t.Fatalf("preserved config %s was modified: %q, %v", name, data, err)
+		}
+		if !preserved && !os.IsNotExist(err) {
+			t.Fatalf("expected %s to be backed up, stat err: %v", name, err)
+		}
+	}
+
+	configList, _, err := cm.GenerateConfigList("10.244.1.0/24", &config.Config{}, "10.96.0.10", "cluster.local")
+	if err != nil {
+		t.Fatalf("GenerateConfigList failed: %v", err)
+	}
+	var types []string
+	for _, plugin := range configList.Plugins {
+		pluginType, _ := plugin["type"].(string)
+		types = append(types, pluginType)
+		if _, ok := plugin["cniVersion"]; ok {
+			t.Fatalf("chained plugin %s kept its cniVersion", pluginType)
+		}
+	}
+	if len(types) != 3 || types[0] != "calico" || types[1] != "cilium-cni" || types[2] != "headcni" {
+		t.Fatalf("unexpected plugin chain %v", types)
+	}
+}
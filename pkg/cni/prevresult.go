@@ -0,0 +1,113 @@
+package cni
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/containernetworking/cni/pkg/types"
+	current "github.com/containernetworking/cni/pkg/types/100"
+	"github.com/containernetworking/cni/pkg/version"
+)
+
+// ParsePrevResult 解析 stdin 配置中的 prevResult，并转换为当前版本的 Result
+// 链中前面的插件没有输出结果时返回 nil, nil
+func ParsePrevResult(stdinData []byte) (*current.Result, error) {
+	conf := &types.NetConf{}
+	if err := json.Unmarshal(stdinData, conf); err != nil {
+		return nil, fmt.Errorf("failed to parse network configuration: %v", err)
+	}
+	if err := version.ParsePrevResult(conf); err != nil {
+		return nil, err
+	}
+	if conf.PrevResult == nil {
+		return nil, nil
+	}
+
+	result, err := current.NewResultFromResult(conf.PrevResult)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert prevResult: %v", err)
+	}
+	return result, nil
+}
+
+// MergeResult 将本插件创建的网卡、地址、路由追加到 prevResult 之后，作为 cmdAdd 的输出
+// own 中 IP 的网卡索引按 prevResult 已有的网卡数量偏移；DNS 以 prevResult 为准，为空时使用 own
+func MergeResult(prev, own *current.Result) *current.Result {
+	if prev == nil {
+		return own
+	}
+	if own == nil {
+		return prev
+	}
+
+	merged := &current.Result{
+		CNIVersion: own.CNIVersion,
+		DNS:        prev.DNS,
+	}
+	if merged.CNIVersion == "" {
+		merged.CNIVersion = prev.CNIVersion
+	}
+
+	for _, iface := range prev.Interfaces {
+		merged.Interfaces = append(merged.Interfaces, iface.Copy())
+	}
+	for _, ip := range prev.IPs {
+		merged.IPs = append(merged.IPs, ip.Copy())
+	}
+	merged.Routes = append(merged.Routes, prev.Routes...)
+
+	offset := len(merged.Interfaces)
+	for _, iface := range own.Interfaces {
+		merged.Interfaces = append(merged.Interfaces, iface.Copy())
+	}
+	for _, ip := range own.IPs {
+		ipCopy := ip.Copy()
+		if ipCopy.Interface != nil {
+			ipCopy.Interface = current.Int(*ipCopy.Interface + offset)
+		}
+		merged.IPs = append(merged.IPs, ipCopy)
+	}
+	for _, route := range own.Routes {
+		if !containsRoute(merged.Routes, route) {
+			merged.Routes = append(merged.Routes, route)
+		}
+	}
+
+	if len(merged.DNS.Nameservers) == 0 && len(merged.DNS.Search) == 0 {
+		merged.DNS = own.DNS
+	}
+	return merged
+}
+
+// FindInterfaceResult 在 prevResult 中查找本插件创建的网卡及其地址，供 cmdCheck 校验、cmdDel 释放地址
+// sandbox 为空时不比较网络命名空间
+func FindInterfaceResult(result *current.Result, ifName, sandbox string) (*current.Interface, []*current.IPConfig, error) {
+	if result == nil {
+		return nil, nil, fmt.Errorf("prevResult is required")
+	}
+
+	for index, iface := range result.Interfaces {
+		if iface.Name != ifName || (sandbox != "" && iface.Sandbox != sandbox) {
+			continue
+		}
+
+		var ips []*current.IPConfig
+		for _, ip := range result.IPs {
+			if ip.Interface != nil && *ip.Interface == index {
+				ips = append(ips, ip)
+			}
+		}
+		return iface, ips, nil
+	}
+	return nil, nil, fmt.Errorf("interface %s not found in prevResult", ifName)
+}
+
+// containsRoute 判断路由列表中是否已有相同的路由
+func containsRoute(routes []*types.Route, route *types.Route) bool {
+	for _, existing := range routes {
+		if existing.String() == route.String() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+package cni
+
+import (
+	"net"
+	"testing"
+
+	current "github.com/containernetworking/cni/pkg/types/100"
+)
+
+func TestPrevResultChaining(t *testing.T) {
+	stdin := []byte(`{
+		"cniVersion": "1.0.0",
+		"name": "headcni",
+		"type": "headcni",
+		"prevResult": {
+			"cniVersion": "1.0.0",
+			"interfaces": [{"name": "lo", "sandbox": "/var/run/netns/test"}],
+			"ips": [{"address": "127.0.0.1/8", "interface": 0}],
+			"dns": {"nameservers": ["10.96.0.10"]}
+		}
+	}`)
+
+	prev, err := ParsePrevResult(stdin)
+	if err != nil {
+		t.Fatalf("ParsePrevResult failed: %v", err)
+	}
+	if prev == nil || len(prev.Interfaces) != 1 {
+		t.Fatalf("expected prevResult with one interface, got %+v", prev)
+	}
+
+	_, ipNet, _ := net.ParseCIDR("10.244.1.5/24")
+	ipNet.IP = net.ParseIP("10.244.1.5")
+	own := &current.Result{
+		CNIVersion: "1.0.0",
+		Interfaces: []*current.Interface{{Name: "eth0", Sandbox: "/var/run/netns/test"}},
+		IPs:        []*current.IPConfig{{Address: *ipNet, Interface: current.Int(0)}},
+	}
+
+	merged := MergeResult(prev, own)
+	if len(merged.Interfaces) != 2 || len(merged.IPs) != 2 {
+		t.Fatalf("expected 2 interfaces and 2 IPs, got %d and %d", len(merged.Interfaces), len(merged.IPs))
+	}
+	if len(merged.DNS.Nameservers) != 1 {
+		t.Errorf("expected DNS from prevResult to be kept, got %+v", merged.DNS)
+	}
+
+	iface, ips, err := FindInterfaceResult(merged, "eth0", "/var/run/netns/test")
+	if err != nil {
+		t.Fatalf("FindInterfaceResult failed: %v", err)
+	}
+	if iface.Name != "eth0" || len(ips) != 1 || !ips[0].Address.IP.Equal(ipNet.IP) {
+		t.Fatalf("unexpected entries for eth0: %+v %+v", iface, ips)
+	}
+
+	if prev, err := ParsePrevResult([]byte(`{"cniVersion":"1.0.0","name":"headcni","type":"headcni"}`)); err != nil || prev != nil {
+		t.Fatalf("expected no prevResult, got %+v, %v", prev, err)
+	}
+}
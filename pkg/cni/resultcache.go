@@ -0,0 +1,91 @@
+package cni
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/binrclab/headcni/pkg/constants"
+	"github.com/binrclab/headcni/pkg/utils/fsutil"
+)
+
+// ResultCache 按容器 ID + 网卡名缓存 cmdAdd 成功返回的 CNI Result
+// kubelet 对已配置网络的 sandbox 重复下发 ADD 时，插件应返回缓存结果而不是再分配一个 IP：
+// cmdAdd 分配前调用 Load，成功后调用 Save；cmdDel 调用 Delete
+type ResultCache struct {
+	dir string
+}
+
+// NewResultCache 创建结果缓存，dir 为空时使用默认目录
+func NewResultCache(dir string) *ResultCache {
+	if dir == "" {
+		dir = constants.DefaultCNIResultCacheDir
+	}
+	return &ResultCache{dir: dir}
+}
+
+// Load 返回缓存的 Result，未命中时返回 nil, nil
+// verify 用于确认网卡和 IP 仍然存在，校验失败时删除过期缓存并按未命中处理
+func (c *ResultCache) Load(containerID, ifName string, verify func(result []byte) error) ([]byte, error) {
+	path, err := c.path(containerID, ifName)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cached result: %v", err)
+	}
+
+	if verify != nil {
+		if err := verify(result); err != nil {
+			if removeErr := os.Remove(path); removeErr != nil && !os.IsNotExist(removeErr) {
+				return nil, fmt.Errorf("failed to remove stale cached result: %v", removeErr)
+			}
+			return nil, nil
+		}
+	}
+	return result, nil
+}
+
+// Save 保存 cmdAdd 的 Result，原子写入并 fsync，避免中断或断电后留下不完整的缓存
+func (c *ResultCache) Save(containerID, ifName string, result []byte) error {
+	path, err := c.path(containerID, ifName)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(c.dir, 0700); err != nil {
+		return fmt.Errorf("failed to create result cache directory: %v", err)
+	}
+	if err := fsutil.WriteFileAtomic(path, result, 0600); err != nil {
+		return fmt.Errorf("failed to save cached result: %v", err)
+	}
+	return nil
+}
+
+// Delete 删除缓存的 Result，缓存不存在时不报错
+func (c *ResultCache) Delete(containerID, ifName string) error {
+	path, err := c.path(containerID, ifName)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete cached result: %v", err)
+	}
+	return nil
+}
+
+// path 返回缓存文件路径，ID 和网卡名不允许包含路径分隔符
+func (c *ResultCache) path(containerID, ifName string) (string, error) {
+	for _, part := range []string{containerID, ifName} {
+		if part == "" || part == "." || part == ".." || strings.ContainsAny(part, `/\`) {
+			return "", fmt.Errorf("invalid cache key %q", part)
+		}
+	}
+	return filepath.Join(c.dir, containerID+"-"+ifName), nil
+}
@@ -0,0 +1,46 @@
+package cni
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestResultCache(t *testing.T) {
+	cache := NewResultCache(t.TempDir())
+	result := []byte(`{"cniVersion":"1.0.0","ips":[{"address":"10.244.1.5/24"}]}`)
+
+	if cached, err := cache.Load("abc123", "eth0", nil); err != nil || cached != nil {
+		t.Fatalf("expected cache miss, got %q, %v", cached, err)
+	}
+
+	if err := cache.Save("abc123", "eth0", result); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	cached, err := cache.Load("abc123", "eth0", func([]byte) error { return nil })
+	if err != nil || string(cached) != string(result) {
+		t.Fatalf("expected cached result, got %q, %v", cached, err)
+	}
+
+	// 网卡已不存在时丢弃缓存
+	stale := func([]byte) error { return errors.New("interface eth0 not found") }
+	if cached, err := cache.Load("abc123", "eth0", stale); err != nil || cached != nil {
+		t.Fatalf("expected stale entry to be dropped, got %q, %v", cached, err)
+	}
+	if cached, _ := cache.Load("abc123", "eth0", nil); cached != nil {
+		t.Fatalf("stale entry was not removed")
+	}
+
+	if err := cache.Save("abc123", "eth0", result); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if err := cache.Delete("abc123", "eth0"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := cache.Delete("abc123", "eth0"); err != nil {
+		t.Fatalf("Delete of missing entry failed: %v", err)
+	}
+
+	if err := cache.Save("../abc", "eth0", result); err == nil {
+		t.Fatalf("expected invalid container ID to be rejected")
+	}
+}
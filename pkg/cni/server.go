@@ -14,12 +14,13 @@ import (
 
 // Server 是 CNI 服务器（使用函数回调，去掉 Handler 接口以简化结构）
 type Server struct {
-	socketPath string
-	server     *http.Server
-	onAllocate func(*CNIRequest) *CNIResponse
-	onRelease  func(*CNIRequest) *CNIResponse
-	onStatus   func(*CNIRequest) *CNIResponse
-	onPodReady func(*CNIRequest) *CNIResponse
+	socketPath  string
+	server      *http.Server
+	onAllocate  func(*CNIRequest) *CNIResponse
+	onRelease   func(*CNIRequest) *CNIResponse
+	onStatus    func(*CNIRequest) *CNIResponse
+	onPodReady  func(*CNIRequest) *CNIResponse
+	onExhausted func(*CNIRequest) *CNIResponse
 }
 
 // NewServer 创建新的 CNI 服务器（使用默认回调）
@@ -61,6 +62,18 @@ func (s *Server) setDefaultCallbacks() {
 			return &CNIResponse{Success: true, Data: map[string]interface{}{"ready": true}}
 		}
 	}
+	if s.onExhausted == nil {
+		s.onExhausted = func(req *CNIRequest) *CNIResponse {
+			return SubnetExhaustedResponse(NewSubnetExhaustedError("unknown", 0, -1))
+		}
+	}
+}
+
+// OnSubnetExhausted 设置 subnet_exhausted 请求的回调，需在 Start 前调用
+func (s *Server) OnSubnetExhausted(fn func(*CNIRequest) *CNIResponse) {
+	if fn != nil {
+		s.onExhausted = fn
+	}
 }
 
 // Start 启动 CNI 服务器
@@ -200,6 +213,8 @@ func (s *Server) processCNIRequest(req *CNIRequest) *CNIResponse {
 		return s.onStatus(req)
 	case "pod_ready":
 		return s.onPodReady(req)
+	case "subnet_exhausted":
+		return s.onExhausted(req)
 	default:
 		return &CNIResponse{
 			Success: false,
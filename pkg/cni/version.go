@@ -0,0 +1,55 @@
+package cni
+
+import (
+	"fmt"
+
+	"github.com/containernetworking/cni/pkg/types"
+	current "github.com/containernetworking/cni/pkg/types/100"
+	"github.com/containernetworking/cni/pkg/version"
+)
+
+// SupportedVersions 插件支持的 CNI 规范版本，作为 skel.PluginMainFuncs 的版本信息
+var SupportedVersions = version.PluginSupports("0.3.1", "0.4.0", "1.0.0")
+
+// RequestedVersion 读取运行时在 stdin 配置中请求的 cniVersion
+// 未填写时按 CNI 规范视为 0.1.0，因而同样会被拒绝
+func RequestedVersion(stdinData []byte) (string, error) {
+	decoder := &version.ConfigDecoder{}
+	cniVersion, err := decoder.Decode(stdinData)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse cniVersion: %v", err)
+	}
+	for _, supported := range SupportedVersions.SupportedVersions() {
+		if cniVersion == supported {
+			return cniVersion, nil
+		}
+	}
+	return "", types.NewError(types.ErrIncompatibleCNIVersion, "incompatible CNI version",
+		fmt.Sprintf("config version %q is not supported, plugin supports %v", cniVersion, SupportedVersions.SupportedVersions()))
+}
+
+// ConvertResult 把内部使用的 1.0.0 结果转换为运行时请求的版本，cmdAdd 输出前调用
+// kubelet 要求结果版本与配置中的 cniVersion 一致
+func ConvertResult(result *current.Result, cniVersion string) (types.Result, error) {
+	if result == nil {
+		return nil, fmt.Errorf("result is required")
+	}
+	converted, err := result.GetAsVersion(cniVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert result to CNI version %s: %v", cniVersion, err)
+	}
+	return converted, nil
+}
+
+// PrintResultForConfig 按 stdin 配置请求的版本输出结果
+func PrintResultForConfig(stdinData []byte, result *current.Result) error {
+	cniVersion, err := RequestedVersion(stdinData)
+	if err != nil {
+		return err
+	}
+	converted, err := ConvertResult(result, cniVersion)
+	if err != nil {
+		return err
+	}
+	return converted.Print()
+}
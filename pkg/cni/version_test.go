@@ -0,0 +1,64 @@
+package cni
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"testing"
+
+	current "github.com/containernetworking/cni/pkg/types/100"
+	"github.com/containernetworking/cni/pkg/version"
+)
+
+func TestConvertResultRoundTrip(t *testing.T) {
+	_, ipNet, _ := net.ParseCIDR("10.244.1.5/24")
+	ipNet.IP = net.ParseIP("10.244.1.5")
+	result := &current.Result{
+		CNIVersion: "1.0.0",
+		Interfaces: []*current.Interface{{Name: "eth0", Sandbox: "/var/run/netns/test"}},
+		IPs:        []*current.IPConfig{{Address: *ipNet, Gateway: net.ParseIP("10.244.1.1"), Interface: current.Int(0)}},
+	}
+
+	for _, requested := range []string{"0.3.1", "0.4.0", "1.0.0"} {
+		stdin := []byte(fmt.Sprintf(`{"cniVersion":%q,"name":"cbr0","type":"headcni"}`, requested))
+
+		cniVersion, err := RequestedVersion(stdin)
+		if err != nil {
+			t.Fatalf("RequestedVersion(%s) failed: %v", requested, err)
+		}
+		converted, err := ConvertResult(result, cniVersion)
+		if err != nil {
+			t.Fatalf("ConvertResult(%s) failed: %v", requested, err)
+		}
+
+		data, err := json.Marshal(converted)
+		if err != nil {
+			t.Fatalf("failed to encode %s result: %v", requested, err)
+		}
+		var header struct {
+			CNIVersion string `json:"cniVersion"`
+		}
+		if err := json.Unmarshal(data, &header); err != nil || header.CNIVersion != requested {
+			t.Fatalf("expected output version %s, got %q (%v)", requested, header.CNIVersion, err)
+		}
+
+		decoded, err := version.NewResult(requested, data)
+		if err != nil {
+			t.Fatalf("failed to decode %s result: %v", requested, err)
+		}
+		back, err := current.NewResultFromResult(decoded)
+		if err != nil {
+			t.Fatalf("failed to convert %s result back: %v", requested, err)
+		}
+		if len(back.IPs) != 1 || !back.IPs[0].Address.IP.Equal(ipNet.IP) || !back.IPs[0].Gateway.Equal(result.IPs[0].Gateway) {
+			t.Errorf("%s result did not round-trip: %+v", requested, back.IPs)
+		}
+		if len(back.Interfaces) != 1 || back.Interfaces[0].Name != "eth0" {
+			t.Errorf("%s result lost interfaces: %+v", requested, back.Interfaces)
+		}
+	}
+
+	if _, err := RequestedVersion([]byte(`{"cniVersion":"0.2.0","name":"cbr0","type":"headcni"}`)); err == nil {
+		t.Errorf("expected unsupported version to be rejected")
+	}
+}
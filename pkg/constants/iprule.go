@@ -0,0 +1,14 @@
+package constants
+
+// HeadCNI daemon 在宿主机上添加的 ip rule 优先级，CLI 清理规则时使用同一组值
+const (
+	// RulePriorityPodCIDR to <podCIDR> lookup main，本节点 Pod 流量走主路由表
+	RulePriorityPodCIDR = 3151
+	// RulePriorityLocalIP from <localIP> lookup 52
+	RulePriorityLocalIP = 3152
+	// RulePriorityTailscaleIP from <tailscaleIP> lookup 53
+	RulePriorityTailscaleIP = 3153
+)
+
+// HeadcniRulePriorities HeadCNI 管理的全部 ip rule 优先级
+var HeadcniRulePriorities = []int{RulePriorityPodCIDR, RulePriorityLocalIP, RulePriorityTailscaleIP}
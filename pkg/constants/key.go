@@ -6,4 +6,30 @@ const (
 	HeadcniTailscaleIPAnnotationKey = "headcni.tailscale.ip"
 	HeadcniNodeKeyAnnotationKey     = "headcni.node.key"
 	HeadcniPodCIDRAnnotationKey     = "headcni.pod.cidr"
+
+	// 节点注解：守护进程版本、生效配置哈希和运行模式，用于观察升级进度
+	HeadcniVersionAnnotationKey    = "headcni.io/version"
+	HeadcniConfigHashAnnotationKey = "headcni.io/config-hash"
+	HeadcniModeAnnotationKey       = "headcni.io/mode"
+
+	// 节点注解：节点 Spec.PodCIDR 为空（Pod CIDR 由 HeadCNI IPAM 而非 kube-controller-manager 分配）时使用的 Pod CIDR
+	HeadcniPodCIDROverrideAnnotationKey = "headcni.io/pod-cidr"
+
+	// Pod 注解：请求固定 IP
+	HeadcniStaticIPAnnotationKey = "headcni.io/ip"
+
+	// Pod 注解：daemon 观察到 Pod IP 后写入分配的 IP 和所在节点的 Tailscale IP，供下游工具查询
+	HeadcniPodIPAnnotationKey           = "headcni.io/pod-ip"
+	HeadcniHostTailscaleIPAnnotationKey = "headcni.io/host-ts-ip"
 )
+
+// HeadcniManagedNodeAnnotationKeys daemon 写入的节点注解，卸载或停止时删除
+// 不包括用户设置的 headcni.io/pod-cidr
+var HeadcniManagedNodeAnnotationKeys = []string{
+	HeadcniTailscaleIPAnnotationKey,
+	HeadcniNodeKeyAnnotationKey,
+	HeadcniPodCIDRAnnotationKey,
+	HeadcniVersionAnnotationKey,
+	HeadcniConfigHashAnnotationKey,
+	HeadcniModeAnnotationKey,
+}
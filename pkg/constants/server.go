@@ -1,14 +1,42 @@
 package constants
 
 const DefaultSocketPath = "/var/run/headcni/daemon.sock"
+
+// DefaultControlSocketPath daemon 本地控制接口，仅 root 可访问，供 CLI 查询状态和触发操作
+const DefaultControlSocketPath = "/var/run/headcni/control.sock"
 const DefaultTailscaleServiceName = "headcni01"
+
+// ManagedInterfacePrefix HeadCNI 创建的 Tailscale 接口名前缀，清理接口时只删除以此开头的接口
+const ManagedInterfacePrefix = "headcni"
 const DefaultTailscaleDaemonDir = "/var/run/headcni/"
 const DefaultTailscaleDaemonSocketPath = "/var/run/headcni/headcni_tailscale.sock"
 const DefaultTailscaleHostSocketPath = "/var/run/tailscale/tailscaled.sock"
 const DefaultTailscaleDaemonStateDir = "/var/lib/headcni"
 const DefaultTailscaleDaemonStateFile = "/var/lib/headcni/tailscaled.state"
 
+// DefaultIdentityRotationFile 身份轮换进行中的标记文件，用于中断后继续完成轮换
+const DefaultIdentityRotationFile = "/var/lib/headcni/identity-rotation.json"
+
 // k8s cni default config
 const DefaultCNIConfigDir = "/etc/cni/net.d"
 const DefaultHeadCNIConfigFile = "10-headcni.conflist"
 const DefaultCNIEnvFile = "/var/lib/headcni/env.yaml"
+
+// DefaultCNIResultCacheDir CNI ADD 结果缓存目录，重复 ADD 时返回缓存结果
+const DefaultCNIResultCacheDir = "/var/lib/headcni/results"
+
+// DefaultManagedRoutesFile HeadCNI 通告过的路由，只有其中的路由允许被撤销
+const DefaultManagedRoutesFile = "/var/lib/headcni/managed-routes.json"
+
+// 节点上的日志文件，headcni logs 按时间合并显示
+const DefaultLogDir = "/var/log/headcni"
+const DefaultDaemonLogFile = "/var/log/headcni/headcni-daemon.log"
+
+// DefaultTailscaledLogFile daemon/router 模式下 daemon 启动的 tailscaled 的输出
+const DefaultTailscaledLogFile = "/var/log/headcni/tailscaled.log"
+
+// DefaultCNIPluginLogFile CNI 插件每次调用追加的 JSON 行日志
+const DefaultCNIPluginLogFile = "/var/log/headcni/cni-plugin.log"
+
+// DefaultCNIInvocationDir CNI 插件记录调用输入（HEADCNI_CNI_DUMP_DIR）的推荐目录，headcni diagnostics 默认从这里收集
+const DefaultCNIInvocationDir = "/var/log/headcni/cni-invocations"
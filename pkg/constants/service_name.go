@@ -6,4 +6,6 @@ const (
 	ServiceNamePodMonitoring   = "PodMonitoringService"
 	ServiceNameHeadscaleHealth = "HeadscaleHealthService"
 	ServiceNameTailscale       = "TailscaleService"
+	ServiceNameNetworkPolicy   = "NetworkPolicyService"
+	ServiceNameHousekeeping    = "HeadscaleHousekeepingService"
 )
@@ -0,0 +1,100 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/binrclab/headcni/cmd/daemon/config"
+	"github.com/binrclab/headcni/pkg/k8s"
+	"github.com/binrclab/headcni/pkg/logging"
+)
+
+// authKeySecretTimeout 读取 API 密钥 Secret 的超时时间
+const authKeySecretTimeout = 10 * time.Second
+
+// loadAuthKeyFromSecret 配置了 authKeySecretRef 时从 Secret 读取 API 密钥，覆盖 authKey、环境变量和命令行参数
+func (p *Preparer) loadAuthKeyFromSecret(cfg *config.Config) error {
+	ctx, cancel := context.WithTimeout(context.Background(), authKeySecretTimeout)
+	defer cancel()
+	return ResolveAuthKey(ctx, p.k8sClient, cfg)
+}
+
+// ResolveAuthKey 配置了 authKeySecretRef 时从 Secret 读取 API 密钥写入 cfg.Headscale.AuthKey
+// daemon 和 headcni drain 共用，未配置引用时不做任何修改
+func ResolveAuthKey(ctx context.Context, k8sClient k8s.Client, cfg *config.Config) error {
+	ref := cfg.Headscale.AuthKeySecretRef
+	if !ref.IsSet() {
+		return nil
+	}
+
+	key, err := k8sClient.GetSecretValue(ctx, ref.Namespace, ref.Name, ref.Key)
+	if err != nil {
+		return fmt.Errorf("failed to read headscale auth key from secret: %w", err)
+	}
+
+	if cfg.Headscale.AuthKey != "" && cfg.Headscale.AuthKey != key {
+		logging.Infof("Using Headscale API key from secret %s, ignoring authKey from config/env/flag", ref.Name)
+	}
+	cfg.Headscale.AuthKey = key
+	return nil
+}
+
+// resolveReloadedAuthKey Secret 中的密钥不在配置文件里，引用未变时沿用当前密钥，引用变化时重新读取
+func (p *Preparer) resolveReloadedAuthKey(newConfig *config.Config) error {
+	ref := newConfig.Headscale.AuthKeySecretRef
+	if !ref.IsSet() {
+		return nil
+	}
+	if ref == p.oldConfig.Headscale.AuthKeySecretRef {
+		newConfig.Headscale.AuthKey = p.oldConfig.Headscale.AuthKey
+		return nil
+	}
+	return p.loadAuthKeyFromSecret(newConfig)
+}
+
+// startAuthKeyWatch 监听 API 密钥 Secret，密钥轮换后无需重启即生效，会先停止已有的监听
+func (p *Preparer) startAuthKeyWatch() {
+	p.stopAuthKeyWatch()
+
+	ref := p.config.Headscale.AuthKeySecretRef
+	if !ref.IsSet() {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p.authKeyWatchCancel = cancel
+	go func() {
+		if err := p.k8sClient.WatchSecretValue(ctx, ref.Namespace, ref.Name, ref.Key, p.rotateAuthKey); err != nil {
+			logging.Errorf("Failed to watch Headscale API key secret %s: %v", ref.Name, err)
+		}
+	}()
+}
+
+// stopAuthKeyWatch 停止 API 密钥 Secret 监听
+func (p *Preparer) stopAuthKeyWatch() {
+	if p.authKeyWatchCancel != nil {
+		p.authKeyWatchCancel()
+		p.authKeyWatchCancel = nil
+	}
+}
+
+// rotateAuthKey Secret 中的密钥变化后更新配置和 Headscale 客户端
+// 其他 goroutine 可能正在读取当前配置，密钥写入配置副本后整体替换，不修改共享的配置
+func (p *Preparer) rotateAuthKey(key string) {
+	p.reloadMu.Lock()
+	defer p.reloadMu.Unlock()
+
+	current := p.GetConfig()
+	if key == current.Headscale.AuthKey {
+		return
+	}
+
+	rotated := *current
+	rotated.Headscale.AuthKey = key
+	p.setConfigs(&rotated, p.GetOldConfig())
+	if p.headscaleClient != nil {
+		p.headscaleClient.SetAuthKey(key)
+	}
+	logging.Infof("Headscale API key rotated from secret %s", rotated.Headscale.AuthKeySecretRef.Name)
+}
@@ -0,0 +1,262 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/binrclab/headcni/pkg/backend/tailscale"
+	"github.com/binrclab/headcni/pkg/constants"
+	"github.com/binrclab/headcni/pkg/logging"
+)
+
+// ControlInfo 控制接口 /info 的响应
+type ControlInfo struct {
+	Version     string                      `json:"version"`
+	NodeName    string                      `json:"node_name,omitempty"`
+	Mode        string                      `json:"mode,omitempty"`
+	DryRun      bool                        `json:"dry_run"`
+	TailscaleIP string                      `json:"tailscale_ip,omitempty"`
+	Tailscale   map[string]interface{}      `json:"tailscale,omitempty"`
+	Services    []*tailscale.ServiceDetails `json:"services,omitempty"`
+}
+
+// controlServer daemon 本地控制接口（Unix socket 上的 HTTP），提供状态查询、路由收敛、配置重载、健康快照、只读模式切换、IPAM 分配表和身份轮换
+// socket 权限为 0600，并通过 SO_PEERCRED 拒绝非 root 进程的连接
+type controlServer struct {
+	daemon     *Daemon
+	socketPath string
+	server     *http.Server
+}
+
+// newControlServer 创建控制接口服务器
+func newControlServer(d *Daemon, socketPath string) *controlServer {
+	return &controlServer{daemon: d, socketPath: socketPath}
+}
+
+// Start 监听控制 socket 并在后台处理请求
+func (c *controlServer) Start() error {
+	if err := os.MkdirAll(filepath.Dir(c.socketPath), 0755); err != nil {
+		return fmt.Errorf("failed to create control socket directory: %v", err)
+	}
+	if err := os.Remove(c.socketPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove existing control socket: %v", err)
+	}
+
+	listener, err := net.Listen("unix", c.socketPath)
+	if err != nil {
+		return fmt.Errorf("failed to listen on control socket: %v", err)
+	}
+	if err := os.Chmod(c.socketPath, 0600); err != nil {
+		listener.Close()
+		return fmt.Errorf("failed to set control socket permissions: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/info", c.handleInfo)
+	mux.HandleFunc("/health", c.handleHealth)
+	mux.HandleFunc("/reconcile", c.handleReconcile)
+	mux.HandleFunc("/reload", c.handleReload)
+	mux.HandleFunc(dryRunPath, c.handleDryRun)
+	mux.HandleFunc("/ipam", c.handleIPAM)
+	mux.HandleFunc(identityRotatePath, c.handleRotateIdentity)
+
+	c.server = &http.Server{Handler: mux}
+	go func() {
+		if err := c.server.Serve(&rootOnlyListener{Listener: listener}); err != nil && err != http.ErrServerClosed {
+			logging.Errorf("Control server error: %v", err)
+		}
+	}()
+
+	logging.Infof("Control server started on Unix socket: %s", c.socketPath)
+	return nil
+}
+
+// Stop 关闭控制接口并删除 socket 文件
+func (c *controlServer) Stop() error {
+	if c.server != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := c.server.Shutdown(ctx); err != nil {
+			logging.Warnf("Failed to shut down control server: %v", err)
+		}
+	}
+	if err := os.Remove(c.socketPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove control socket: %v", err)
+	}
+	return nil
+}
+
+// tailscaleService 返回已注册的 Tailscale 服务，未注册时为 nil
+func (c *controlServer) tailscaleService() *TailscaleService {
+	if c.daemon.serviceManager == nil {
+		return nil
+	}
+	svc, ok := c.daemon.serviceManager.GetService(constants.ServiceNameTailscale)
+	if !ok {
+		return nil
+	}
+	tsm, _ := svc.(*TailscaleService)
+	return tsm
+}
+
+// handleInfo 返回 daemon 版本、运行模式和 Tailscale 服务状态
+func (c *controlServer) handleInfo(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	info := ControlInfo{Version: Version}
+	if cfg := c.daemon.preparer.GetConfig(); cfg != nil {
+		info.Mode = cfg.Tailscale.Mode
+	}
+	if k8sClient := c.daemon.preparer.GetK8sClient(); k8sClient != nil {
+		if nodeName, err := k8sClient.GetCurrentNodeName(); err == nil {
+			info.NodeName = nodeName
+		}
+	}
+	if tsm := c.tailscaleService(); tsm != nil {
+		info.DryRun = tsm.DryRun()
+		info.Tailscale = tsm.GetServiceInfo()
+		if tsm.IsRunning() {
+			ctx, cancel := tsm.callContext()
+			if ip, err := tsm.preparer.GetTailscaleClient().GetIP(ctx); err == nil {
+				info.TailscaleIP = ip.String()
+			}
+			cancel()
+		}
+	}
+	if sm := c.daemon.preparer.GetTailscaleService(); sm != nil {
+		info.Services = sm.ListServiceDetails()
+	}
+	writeControlJSON(w, http.StatusOK, info)
+}
+
+// handleHealth 返回各服务的健康快照
+func (c *controlServer) handleHealth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeControlJSON(w, http.StatusOK, GetGlobalHealthManager().GetHealthStatus())
+}
+
+// handleReconcile 立即执行一次路由收敛
+func (c *controlServer) handleReconcile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	tsm := c.tailscaleService()
+	if tsm == nil {
+		http.Error(w, "tailscale service is not registered", http.StatusServiceUnavailable)
+		return
+	}
+	if err := tsm.ReconcileRoutes(); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	writeControlJSON(w, http.StatusOK, map[string]string{"status": "reconciled"})
+}
+
+// handleReload 重新加载配置，与 SIGHUP 的处理相同
+func (c *controlServer) handleReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := c.daemon.reloadConfigAndServices(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeControlJSON(w, http.StatusOK, map[string]string{"status": "reloaded"})
+}
+
+// handleDryRun 查看或切换只读模式
+func (c *controlServer) handleDryRun(w http.ResponseWriter, r *http.Request) {
+	tsm := c.tailscaleService()
+	if tsm == nil {
+		http.Error(w, "tailscale service is not registered", http.StatusServiceUnavailable)
+		return
+	}
+	tsm.handleDryRun(w, r)
+}
+
+// handleRotateIdentity 轮换本节点的 Tailscale 身份
+func (c *controlServer) handleRotateIdentity(w http.ResponseWriter, r *http.Request) {
+	tsm := c.tailscaleService()
+	if tsm == nil {
+		http.Error(w, "tailscale service is not registered", http.StatusServiceUnavailable)
+		return
+	}
+	tsm.handleRotateIdentity(w, r)
+}
+
+// handleIPAM 返回本节点当前的 IPAM 分配表
+func (c *controlServer) handleIPAM(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if c.daemon.serviceManager == nil {
+		http.Error(w, "cni service is not registered", http.StatusServiceUnavailable)
+		return
+	}
+	svc, ok := c.daemon.serviceManager.GetService(constants.ServiceNameCNI)
+	cniService, _ := svc.(*CNIService)
+	if !ok || cniService == nil {
+		http.Error(w, "cni service is not registered", http.StatusServiceUnavailable)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	table, err := cniService.AllocationTable(ctx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeControlJSON(w, http.StatusOK, table)
+}
+
+// writeControlJSON 以 JSON 写出响应
+func writeControlJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logging.Warnf("Failed to write control response: %v", err)
+	}
+}
+
+// rootOnlyListener 只接受 root 进程的连接，其余连接直接关闭
+type rootOnlyListener struct {
+	net.Listener
+}
+
+func (l *rootOnlyListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		uid, err := peerUID(conn)
+		if err != nil {
+			logging.Warnf("Rejected control connection: %v", err)
+			conn.Close()
+			continue
+		}
+		if uid != 0 {
+			logging.Warnf("Rejected control connection from uid %d", uid)
+			conn.Close()
+			continue
+		}
+		return conn, nil
+	}
+}
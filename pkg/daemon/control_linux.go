@@ -0,0 +1,34 @@
+//go:build linux
+// +build linux
+
+package daemon
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// peerUID 通过 SO_PEERCRED 获取 Unix socket 对端进程的 uid
+func peerUID(conn net.Conn) (uint32, error) {
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return 0, fmt.Errorf("not a unix socket connection")
+	}
+	raw, err := unixConn.SyscallConn()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get raw connection: %v", err)
+	}
+
+	var cred *syscall.Ucred
+	var credErr error
+	if err := raw.Control(func(fd uintptr) {
+		cred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	}); err != nil {
+		return 0, fmt.Errorf("failed to access socket: %v", err)
+	}
+	if credErr != nil {
+		return 0, fmt.Errorf("failed to get peer credentials: %v", credErr)
+	}
+	return cred.Uid, nil
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package daemon
+
+import (
+	"fmt"
+	"net"
+)
+
+// peerUID 非 Linux 平台无法获取对端凭据，拒绝所有连接
+func peerUID(conn net.Conn) (uint32, error) {
+	return 0, fmt.Errorf("peer credentials are not supported on this platform")
+}
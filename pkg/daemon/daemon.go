@@ -10,9 +10,13 @@ import (
 	"time"
 
 	"github.com/binrclab/headcni/cmd/daemon/config"
+	"github.com/binrclab/headcni/pkg/constants"
 	"github.com/binrclab/headcni/pkg/logging"
 )
 
+// Version 守护进程版本，由命令行入口在启动前设置
+var Version = "dev"
+
 // Daemon 是 HeadCNI 守护进程
 type Daemon struct {
 	// 配置和控制
@@ -24,6 +28,12 @@ type Daemon struct {
 
 	// 服务管理器
 	serviceManager *ServiceManager
+
+	// 本地控制接口
+	control *controlServer
+
+	// reloadMu 串行化 SIGHUP 和控制接口触发的配置重载
+	reloadMu sync.Mutex
 }
 
 func NewDaemon(
@@ -56,6 +66,13 @@ func (d *Daemon) StartWithContext(ctx context.Context) error {
 		return fmt.Errorf("failed to start services: %v", err)
 	}
 
+	// 启动本地控制接口，失败不影响 CNI 和路由功能
+	d.control = newControlServer(d, constants.DefaultControlSocketPath)
+	if err := d.control.Start(); err != nil {
+		logging.Warnf("Failed to start control server: %v", err)
+		d.control = nil
+	}
+
 	// 等待退出信号
 	qc := make(chan os.Signal, 1)
 	var sig os.Signal
@@ -70,6 +87,9 @@ func (d *Daemon) StartWithContext(ctx context.Context) error {
 
 	// 优雅关闭服务
 	logging.Infof("Received signal: %s, starting graceful shutdown...", sig.String())
+	if d.control != nil {
+		d.control.Stop()
+	}
 	d.serviceManager.StopAll()
 	logging.Infof("HeadCNI daemon stopped")
 
@@ -106,6 +126,9 @@ func (d *Daemon) GetServiceManager() *ServiceManager {
 
 // reloadConfigAndServices 重新加载配置和服务
 func (d *Daemon) reloadConfigAndServices() error {
+	d.reloadMu.Lock()
+	defer d.reloadMu.Unlock()
+
 	// 1. 重新加载配置
 	configChanged, err := d.preparer.ReloadConfig()
 	if err != nil {
@@ -0,0 +1,64 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/binrclab/headcni/pkg/logging"
+)
+
+// dryRunPath 控制接口上查看和切换只读模式的路径
+const dryRunPath = "/dry-run"
+
+// dryRunState 只读模式接口的请求和响应
+type dryRunState struct {
+	Enabled bool `json:"enabled"`
+}
+
+// [PUBLIC] SetDryRun 开启或关闭只读模式
+// 只读模式下路由收敛照常进行判断，但只记录将要执行的操作，不调用 EditPrefs、EnableRoute、RuleAdd/RuleDel 等修改接口
+func (tsm *TailscaleService) SetDryRun(enabled bool) {
+	if tsm.readOnly.Swap(enabled) == enabled {
+		return
+	}
+	if enabled {
+		logging.Infof("Dry-run mode enabled, route reconcile will only log planned changes")
+	} else {
+		logging.Infof("Dry-run mode disabled, pending route changes will be applied by the next reconcile")
+	}
+}
+
+// [PUBLIC] DryRun 是否处于只读模式
+func (tsm *TailscaleService) DryRun() bool {
+	return tsm.readOnly.Load()
+}
+
+// skipInDryRun 只读模式下记录将要执行的操作并返回 true，调用方跳过修改
+func (tsm *TailscaleService) skipInDryRun(format string, args ...interface{}) bool {
+	if !tsm.readOnly.Load() {
+		return false
+	}
+	logging.Infof("[dry-run] would "+format, args...)
+	return true
+}
+
+// handleDryRun 处理控制接口上的只读模式请求，GET 查询，POST 设置
+func (tsm *TailscaleService) handleDryRun(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var state dryRunState
+		if err := json.NewDecoder(r.Body).Decode(&state); err != nil {
+			http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+			return
+		}
+		tsm.SetDryRun(state.Enabled)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dryRunState{Enabled: tsm.DryRun()})
+}
@@ -0,0 +1,164 @@
+package daemon
+
+import (
+	"context"
+	"net/netip"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/binrclab/headcni/cmd/daemon/config"
+	"github.com/binrclab/headcni/pkg/backend/tailscale"
+	"github.com/binrclab/headcni/pkg/constants"
+	"github.com/binrclab/headcni/pkg/headscale"
+	"github.com/binrclab/headcni/pkg/k8s"
+	"github.com/binrclab/headcni/test/mocks"
+	coreV1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// 使用真实的 headscale.Client 和 tailscale.SimpleClient，对接进程内的模拟 Headscale 和 tailscaled，
+// 验证 host 模式下从启动到通告、批准路由，再到排空撤销路由的完整流程
+func TestDaemonAgainstMockControlPlane(t *testing.T) {
+	const podCIDR = "10.42.1.0/24"
+	tailscaleIP := netip.MustParseAddr("100.64.0.7")
+
+	hs := mocks.NewHeadscale("test-api-key")
+	defer hs.Close()
+
+	tsd, err := mocks.NewTailscaled(filepath.Join(t.TempDir(), "tailscaled.sock"), tailscaleIP)
+	if err != nil {
+		t.Fatalf("failed to start mock tailscaled: %v", err)
+	}
+	defer tsd.Close()
+	tsd.Join(hs, "7", "node-1", "headcni")
+
+	socketPath := hostSocketPath
+	hostSocketPath = tsd.SocketPath()
+	t.Cleanup(func() { hostSocketPath = socketPath })
+	timeout, interval := routeSyncTimeout, routeSyncPollInterval
+	routeSyncTimeout, routeSyncPollInterval = 10*time.Second, 100*time.Millisecond
+	t.Cleanup(func() { routeSyncTimeout, routeSyncPollInterval = timeout, interval })
+	t.Setenv("NODE_NAME", "node-1")
+
+	cfg, err := config.DefaultConfig()
+	if err != nil {
+		t.Fatalf("DefaultConfig failed: %v", err)
+	}
+	cfg.Tailscale.Mode = "host"
+	cfg.Network.PodCIDR.Base = "10.42.0.0/16"
+	cfg.Headscale.URL = hs.URL()
+	cfg.Headscale.AuthKey = "test-api-key"
+	cfg.Headscale.Retries = 0
+	cfg.Daemon.RemoveAnnotationsOnStop = true
+
+	headscaleClient, err := headscale.NewClient(&cfg.Headscale)
+	if err != nil {
+		t.Fatalf("failed to create headscale client: %v", err)
+	}
+	node := &coreV1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+		Spec:       coreV1.NodeSpec{PodCIDR: podCIDR},
+	}
+	clientset := fake.NewSimpleClientset(node)
+	preparer := &Preparer{
+		config:           cfg,
+		oldConfig:        cfg,
+		k8sClient:        k8s.NewClientForClientset(clientset, nil),
+		headscaleClient:  headscaleClient,
+		tailscaleClient:  tailscale.NewSimpleClient(tsd.SocketPath()),
+		tailscaleService: tailscale.NewServiceManager(),
+	}
+
+	tsm := NewTailscaleService(preparer)
+	tsm.managedRoutes = newManagedRouteRegistry(filepath.Join(t.TempDir(), "managed-routes.json"))
+	t.Cleanup(tsm.cancel)
+
+	if err := tsm.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	// 启动后 Pod CIDR 由 tailscaled 通告，并在 Headscale 中批准
+	prefix := netip.MustParsePrefix(podCIDR)
+	route := waitForMockRoute(t, hs, "7", podCIDR, func(route headscale.Route) bool {
+		return route.Advertised && route.Enabled
+	})
+	if routes := tsd.AdvertisedRoutes(); len(routes) != 1 || routes[0] != prefix {
+		t.Fatalf("expected tailscaled to advertise %s, got %v", podCIDR, routes)
+	}
+	if !tsd.Prefs().RouteAll {
+		t.Errorf("expected routes from other nodes to be accepted")
+	}
+	waitFor(t, "node annotation", func() bool {
+		updated, err := clientset.CoreV1().Nodes().Get(context.Background(), "node-1", metav1.GetOptions{})
+		return err == nil && updated.Annotations[constants.HeadcniPodCIDRAnnotationKey] == podCIDR
+	})
+
+	// 排空：撤销路由后停止服务，Headscale 中的路由不再通告也不再启用
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := tsm.WithdrawRoutes(ctx, podCIDR); err != nil {
+		t.Fatalf("WithdrawRoutes failed: %v", err)
+	}
+	if err := tsm.Stop(ctx); err != nil {
+		t.Logf("Stop reported: %v", err)
+	}
+	if tsm.IsRunning() {
+		t.Fatalf("expected the service to be stopped")
+	}
+
+	if routes := tsd.AdvertisedRoutes(); len(routes) != 0 {
+		t.Errorf("expected no advertised routes after shutdown, got %v", routes)
+	}
+	withdrawn, ok := hs.State().Route(route.ID)
+	if !ok || withdrawn.Advertised || withdrawn.Enabled {
+		t.Errorf("expected route %s to be withdrawn in Headscale, got %+v", podCIDR, withdrawn)
+	}
+	if tsm.managedRoutes.managed(prefix) {
+		t.Errorf("expected %s to no longer be managed after withdrawal", podCIDR)
+	}
+
+	// daemon.removeAnnotationsOnStop 开启时停止后不再保留 HeadCNI 注解
+	stopped, err := clientset.CoreV1().Nodes().Get(context.Background(), "node-1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get node: %v", err)
+	}
+	for _, key := range constants.HeadcniManagedNodeAnnotationKeys {
+		if value, ok := stopped.Annotations[key]; ok {
+			t.Errorf("expected annotation %s to be removed on stop, got %q", key, value)
+		}
+	}
+}
+
+// waitForMockRoute 等待模拟 Headscale 中节点的路由满足条件
+func waitForMockRoute(t *testing.T, hs *mocks.Headscale, nodeID, prefix string, ready func(headscale.Route) bool) headscale.Route {
+	t.Helper()
+	var found headscale.Route
+	waitFor(t, "route "+prefix, func() bool {
+		routes, err := hs.State().GetNodeRoutes(context.Background(), nodeID)
+		if err != nil {
+			return false
+		}
+		for _, route := range routes.Routes {
+			if route.Prefix == prefix && ready(route) {
+				found = route
+				return true
+			}
+		}
+		return false
+	})
+	return found
+}
+
+// waitFor 在 15 秒内轮询直到条件成立
+func waitFor(t *testing.T, description string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(15 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", description)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
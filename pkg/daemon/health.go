@@ -5,12 +5,23 @@ import (
 	"time"
 )
 
+const (
+	// HealthConditionRouteConflict 其他节点也通告本节点 Pod CIDR 时设置的健康条件
+	HealthConditionRouteConflict = "RouteConflict"
+	// HealthConditionSplitBrain tailscaled 认为已连接，但本地节点密钥未在 Headscale 中注册或已过期时设置的健康条件
+	HealthConditionSplitBrain = "SplitBrain"
+	// HealthConditionAwaitingRouteApproval 关闭 headscale.autoApproveRoutes 或设置 headscale.autoApproverTag 时，本节点通告的路由尚未在 Headscale 中启用
+	HealthConditionAwaitingRouteApproval = "AwaitingRouteApproval"
+)
+
 // HealthStatus 健康状态
 type HealthStatus struct {
 	Status    string                 `json:"status"`
 	Timestamp time.Time              `json:"timestamp"`
 	Services  map[string]ServiceInfo `json:"services"`
-	Uptime    time.Duration          `json:"uptime"`
+	// Conditions 不影响服务运行但需要人工处理的问题，存在时状态为 degraded
+	Conditions map[string]string `json:"conditions,omitempty"`
+	Uptime     time.Duration     `json:"uptime"`
 }
 
 // ServiceInfo 服务信息
@@ -23,9 +34,10 @@ type ServiceInfo struct {
 
 // GlobalHealthManager 全局健康管理器
 type GlobalHealthManager struct {
-	services  map[string]*ServiceInfo
-	startTime time.Time
-	mu        sync.RWMutex
+	services   map[string]*ServiceInfo
+	conditions map[string]string
+	startTime  time.Time
+	mu         sync.RWMutex
 }
 
 var (
@@ -37,8 +49,9 @@ var (
 func GetGlobalHealthManager() *GlobalHealthManager {
 	globalHealthOnce.Do(func() {
 		globalHealth = &GlobalHealthManager{
-			services:  make(map[string]*ServiceInfo),
-			startTime: time.Now(),
+			services:   make(map[string]*ServiceInfo),
+			conditions: make(map[string]string),
+			startTime:  time.Now(),
 		}
 	})
 	return globalHealth
@@ -78,6 +91,32 @@ func (h *GlobalHealthManager) UpdateServiceStatus(name string, running bool, err
 	}
 }
 
+// SetCondition 设置健康条件，例如路由冲突；同名条件覆盖原有描述
+func (h *GlobalHealthManager) SetCondition(name, message string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.conditions[name] = message
+}
+
+// ClearCondition 清除健康条件
+func (h *GlobalHealthManager) ClearCondition(name string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.conditions, name)
+}
+
+// GetServiceInfo 获取单个服务的健康信息
+func (h *GlobalHealthManager) GetServiceInfo(name string) (ServiceInfo, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	service, exists := h.services[name]
+	if !exists {
+		return ServiceInfo{}, false
+	}
+	return *service, true
+}
+
 // GetHealthStatus 获取整体健康状态
 func (h *GlobalHealthManager) GetHealthStatus() HealthStatus {
 	h.mu.RLock()
@@ -93,11 +132,23 @@ func (h *GlobalHealthManager) GetHealthStatus() HealthStatus {
 		}
 	}
 
+	var conditions map[string]string
+	if len(h.conditions) > 0 {
+		conditions = make(map[string]string, len(h.conditions))
+		for name, message := range h.conditions {
+			conditions[name] = message
+		}
+		if status == "healthy" {
+			status = "degraded"
+		}
+	}
+
 	return HealthStatus{
-		Status:    status,
-		Timestamp: time.Now(),
-		Services:  services,
-		Uptime:    time.Since(h.startTime),
+		Status:     status,
+		Timestamp:  time.Now(),
+		Services:   services,
+		Conditions: conditions,
+		Uptime:     time.Since(h.startTime),
 	}
 }
 
@@ -0,0 +1,274 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/binrclab/headcni/pkg/backend/tailscale"
+	"github.com/binrclab/headcni/pkg/constants"
+	"github.com/binrclab/headcni/pkg/logging"
+	"github.com/binrclab/headcni/pkg/utils/fsutil"
+)
+
+const (
+	// identityRotatePath 控制 socket 上的身份轮换接口
+	identityRotatePath = "/identity/rotate"
+
+	// identityRotationTimeout 单次身份轮换的总超时时间
+	identityRotationTimeout = 5 * time.Minute
+
+	// identityRotationKeyTTL 轮换使用的预授权密钥有效期
+	identityRotationKeyTTL = time.Hour
+)
+
+// identityRotation 持久化的轮换状态，登出旧身份前写入，轮换完成后删除
+type identityRotation struct {
+	OldNodeID  string    `json:"old_node_id"`
+	OldNodeKey string    `json:"old_node_key"`
+	StartedAt  time.Time `json:"started_at"`
+}
+
+// IdentityRotationResult 身份轮换结果
+type IdentityRotationResult struct {
+	OldNodeID   string `json:"old_node_id"`
+	NewNodeID   string `json:"new_node_id"`
+	OldNodeKey  string `json:"old_node_key"`
+	NewNodeKey  string `json:"new_node_key"`
+	TailscaleIP string `json:"tailscale_ip"`
+	OldExpired  bool   `json:"old_expired"` // Headscale 为新身份创建了新节点时，旧节点被设为过期
+}
+
+// [PUBLIC] RotateIdentity 轮换本节点的 Tailscale 身份
+// 流程：创建新的预授权密钥 -> 写入轮换标记 -> 登出并丢弃本地节点密钥（保留接口和配置）
+// -> 用新密钥重新认证并等待 Running -> 重新通告并批准路由 -> 使旧的 Headscale 节点过期 -> 删除标记
+// 登出前的任何失败都保持旧身份不变；登出后被中断时，daemon 重新登录后根据标记完成剩余步骤
+func (tsm *TailscaleService) RotateIdentity(ctx context.Context) (*IdentityRotationResult, error) {
+	if !tsm.rotationMu.TryLock() {
+		return nil, fmt.Errorf("identity rotation already in progress")
+	}
+	defer tsm.rotationMu.Unlock()
+
+	if tsm.preparer.GetHeadscaleClient() == nil {
+		return nil, fmt.Errorf("headscale client not available")
+	}
+	if _, err := os.Stat(constants.DefaultIdentityRotationFile); err == nil {
+		return nil, fmt.Errorf("a previous identity rotation has not completed yet")
+	}
+
+	tsClient := tsm.preparer.GetTailscaleClient()
+
+	// 1. 记录旧身份，要求当前处于 Running
+	_, oldNodeKey, err := tsm.getTailscaleInfo()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current identity: %v", err)
+	}
+	if !tsClient.IsRunning(ctx) || oldNodeKey == "" {
+		return nil, fmt.Errorf("tailscale is not running, refusing to rotate identity")
+	}
+	oldNodeID, err := tsm.getCurrentNodeID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current node ID: %v", err)
+	}
+
+	nodeName, err := tsm.preparer.GetK8sClient().GetCurrentNodeName()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current node name: %v", err)
+	}
+	podCIDRs, err := tsm.nodePodCIDRs(nodeName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Pod CIDR for node %s: %v", nodeName, err)
+	}
+
+	// 2. 创建新的预授权密钥
+	callCtx, cancel := context.WithTimeout(ctx, tsm.callTimeout())
+	preAuthResp, err := tsm.preparer.GetHeadscaleClient().CreatePreAuthKey(callCtx, tsm.newPreAuthKeyRequest(nodeName, identityRotationKeyTTL))
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pre-auth key: %v", err)
+	}
+	if preAuthResp.PreAuthKey.Key == "" {
+		return nil, fmt.Errorf("headscale returned an empty pre-auth key")
+	}
+
+	// 3. 写入轮换标记，之后被中断也能继续完成
+	rotation := &identityRotation{OldNodeID: oldNodeID, OldNodeKey: oldNodeKey, StartedAt: time.Now()}
+	if err := writeIdentityRotation(rotation); err != nil {
+		return nil, fmt.Errorf("failed to record identity rotation: %v", err)
+	}
+
+	logging.Infof("Rotating tailscale identity, old node: %s", oldNodeID)
+
+	// 4. 登出并丢弃本地节点密钥
+	if err := tsClient.Logout(ctx); err != nil {
+		// 未登出时旧身份仍然有效，撤销标记
+		if status, statusErr := tsClient.GetStatusFresh(ctx); statusErr == nil && status.Self != nil && status.Self.PublicKey.String() == oldNodeKey && status.BackendState == "Running" {
+			removeIdentityRotation()
+			return nil, fmt.Errorf("failed to log out, kept old identity: %v", err)
+		}
+		logging.Warnf("Logout returned an error, continuing with re-authentication: %v", err)
+	}
+
+	// 5. 使用新密钥重新认证，daemon 重新登录时也使用该密钥
+	tsm.setAuthKey(preAuthResp.PreAuthKey.Key, preAuthResp.PreAuthKey.Expiration)
+	if err := tsClient.ForceLogin(ctx, tailscale.ClientOptions{
+		AuthKey:      preAuthResp.PreAuthKey.Key,
+		Hostname:     tsm.getTailscaleEnv().hostName,
+		ControlURL:   tsm.preparer.GetConfig().Tailscale.URL,
+		ControlURLs:  tsm.preparer.GetConfig().Tailscale.FallbackURLs,
+		AcceptDNS:    tsm.preparer.GetConfig().Tailscale.AcceptDNS,
+		AcceptRoutes: true,
+		ShieldsUp:    false,
+	}); err != nil {
+		// 已无法回到旧身份，按常规登录流程继续前滚
+		logging.Warnf("Re-authentication with rotation key failed, falling back to regular login: %v", err)
+		if err := tsm.attemptLogin(); err != nil {
+			return nil, fmt.Errorf("failed to re-authenticate, rotation will resume after the daemon logs in again: %v", err)
+		}
+	}
+
+	tailscaleIP, newNodeKey, err := tsm.getTailscaleInfo()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get new identity: %v", err)
+	}
+	if newNodeKey == oldNodeKey {
+		return nil, fmt.Errorf("node key did not change after re-authentication")
+	}
+
+	// 6. 重新通告并批准路由
+	tsm.advertiseAndApproveRoutes(podCIDRs, tailscaleIP)
+	if err := tsm.uploadTailscaleInfo(tailscaleIP, newNodeKey); err != nil {
+		logging.Warnf("Failed to upload tailscale info: %v", err)
+	}
+
+	// 7. 使旧节点过期并删除标记
+	newNodeID, expired, err := tsm.finishIdentityRotation(ctx, rotation)
+	if err != nil {
+		return nil, err
+	}
+
+	logging.Infof("Tailscale identity rotated, old node: %s, new node: %s", oldNodeID, newNodeID)
+	return &IdentityRotationResult{
+		OldNodeID:   oldNodeID,
+		NewNodeID:   newNodeID,
+		OldNodeKey:  oldNodeKey,
+		NewNodeKey:  newNodeKey,
+		TailscaleIP: tailscaleIP.String(),
+		OldExpired:  expired,
+	}, nil
+}
+
+// finishIdentityRotation 新身份生效后使旧节点过期并删除轮换标记
+// Headscale 按机器密钥复用了原节点记录时，节点 ID 不变，不能使其过期
+func (tsm *TailscaleService) finishIdentityRotation(ctx context.Context, rotation *identityRotation) (string, bool, error) {
+	newNodeID, err := tsm.getCurrentNodeID()
+	if err != nil {
+		return "", false, fmt.Errorf("failed to get new node ID: %v", err)
+	}
+
+	expired := false
+	if newNodeID != rotation.OldNodeID {
+		callCtx, cancel := context.WithTimeout(ctx, tsm.callTimeout())
+		_, err := tsm.preparer.GetHeadscaleClient().ExpireNode(callCtx, rotation.OldNodeID)
+		cancel()
+		if err != nil {
+			return "", false, fmt.Errorf("failed to expire old node %s: %v", rotation.OldNodeID, err)
+		}
+		expired = true
+	} else {
+		logging.Infof("Headscale reused node %s for the new node key, not expiring it", newNodeID)
+	}
+
+	removeIdentityRotation()
+	return newNodeID, expired, nil
+}
+
+// resumeIdentityRotation 路由设置完成后检查是否有被中断的身份轮换
+// 节点密钥未变说明旧身份未登出，直接放弃本次轮换；否则完成剩余步骤
+func (tsm *TailscaleService) resumeIdentityRotation(nodeKey string) {
+	if !tsm.rotationMu.TryLock() {
+		return
+	}
+	defer tsm.rotationMu.Unlock()
+
+	rotation, err := readIdentityRotation()
+	if err != nil {
+		logging.Warnf("Failed to read identity rotation state: %v", err)
+		return
+	}
+	if rotation == nil {
+		return
+	}
+
+	if nodeKey == rotation.OldNodeKey {
+		logging.Warnf("Identity rotation started at %v was interrupted before logout, keeping old identity", rotation.StartedAt)
+		removeIdentityRotation()
+		return
+	}
+
+	logging.Infof("Resuming identity rotation started at %v", rotation.StartedAt)
+	if _, _, err := tsm.finishIdentityRotation(tsm.ctx, rotation); err != nil {
+		logging.Warnf("Failed to finish identity rotation: %v", err)
+	}
+}
+
+// handleRotateIdentity 处理 daemon socket 上的身份轮换请求
+func (tsm *TailscaleService) handleRotateIdentity(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), identityRotationTimeout)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "application/json")
+	result, err := tsm.RotateIdentity(ctx)
+	if err != nil {
+		logging.Errorf("Identity rotation failed: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
+// readIdentityRotation 读取轮换标记，不存在时返回 nil
+func readIdentityRotation() (*identityRotation, error) {
+	data, err := os.ReadFile(constants.DefaultIdentityRotationFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var rotation identityRotation
+	if err := json.Unmarshal(data, &rotation); err != nil {
+		return nil, fmt.Errorf("invalid identity rotation file: %v", err)
+	}
+	return &rotation, nil
+}
+
+// writeIdentityRotation 原子写入轮换标记
+func writeIdentityRotation(rotation *identityRotation) error {
+	data, err := json.Marshal(rotation)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(constants.DefaultIdentityRotationFile), 0755); err != nil {
+		return err
+	}
+	return fsutil.WriteFileAtomic(constants.DefaultIdentityRotationFile, data, 0600)
+}
+
+// removeIdentityRotation 删除轮换标记
+func removeIdentityRotation() {
+	if err := os.Remove(constants.DefaultIdentityRotationFile); err != nil && !os.IsNotExist(err) {
+		logging.Warnf("Failed to remove identity rotation state: %v", err)
+	}
+}
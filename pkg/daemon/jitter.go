@@ -0,0 +1,65 @@
+package daemon
+
+import (
+	"context"
+	"hash/fnv"
+	"math/rand"
+	"time"
+
+	"github.com/binrclab/headcni/cmd/daemon/config"
+	"github.com/binrclab/headcni/pkg/logging"
+)
+
+// loopJitterFraction 周期性检查每次等待时追加的最大随机抖动占间隔的比例
+const loopJitterFraction = 0.1
+
+// startupJitter 解析 headscale.startupJitter，未配置或无效时为 0
+func startupJitter(cfg *config.Config) time.Duration {
+	if cfg == nil || cfg.Headscale.StartupJitter == "" {
+		return 0
+	}
+	jitter, err := time.ParseDuration(cfg.Headscale.StartupJitter)
+	if err != nil || jitter <= 0 {
+		return 0
+	}
+	return jitter
+}
+
+// startupDelay 按节点名哈希在 [0, max) 内确定启动延迟
+// 同一节点每次重启得到相同的延迟，不同节点均匀错开
+func startupDelay(nodeName string, max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	h := fnv.New64a()
+	h.Write([]byte(nodeName))
+	return time.Duration(h.Sum64() % uint64(max))
+}
+
+// waitStartupDelay 首次访问 Headscale 前等待本节点的启动延迟，上下文取消时返回错误
+func waitStartupDelay(ctx context.Context, cfg *config.Config, nodeName, name string) error {
+	delay := startupDelay(nodeName, startupJitter(cfg))
+	if delay <= 0 {
+		return nil
+	}
+
+	logging.Infof("%s: delaying first Headscale request by %v (headscale.startupJitter)", name, delay.Round(time.Millisecond))
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// withJitter 返回 interval 加上 [0, interval*loopJitterFraction) 的随机抖动
+// 每个周期重新取值，避免同时启动的节点长期保持同步
+func withJitter(interval time.Duration) time.Duration {
+	max := int64(float64(interval) * loopJitterFraction)
+	if max <= 0 {
+		return interval
+	}
+	return interval + time.Duration(rand.Int63n(max))
+}
@@ -0,0 +1,137 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/binrclab/headcni/pkg/logging"
+	"github.com/binrclab/headcni/pkg/utils/fsutil"
+)
+
+// managedRouteRegistry 记录 HeadCNI 自己通告的路由（Pod CIDR 和配置的额外路由）
+// 撤销路由只允许针对其中的前缀，管理员手动通告的路由不会被收敛或误撤销
+// 持久化到状态目录，重启后仍能撤销上次运行通告、已从配置中删除的额外路由
+type managedRouteRegistry struct {
+	mu     sync.Mutex
+	path   string
+	routes map[netip.Prefix]bool
+}
+
+func newManagedRouteRegistry(path string) *managedRouteRegistry {
+	return &managedRouteRegistry{path: path}
+}
+
+// managed 前缀是否由 HeadCNI 通告
+func (r *managedRouteRegistry) managed(prefix netip.Prefix) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.loadLocked()
+	return r.routes[prefix.Masked()]
+}
+
+// checkRemovable 所有前缀都由 HeadCNI 通告时返回 nil，否则返回列出未管理前缀的错误
+func (r *managedRouteRegistry) checkRemovable(prefixes []netip.Prefix) error {
+	var unmanaged []netip.Prefix
+	for _, prefix := range prefixes {
+		if !r.managed(prefix) {
+			unmanaged = append(unmanaged, prefix)
+		}
+	}
+	if len(unmanaged) > 0 {
+		return fmt.Errorf("refusing to withdraw routes not advertised by HeadCNI: %v", unmanaged)
+	}
+	return nil
+}
+
+// add 记录 HeadCNI 通告的路由，在通告之前调用，中断后也不会遗漏
+func (r *managedRouteRegistry) add(prefixes ...netip.Prefix) {
+	r.update(func(routes map[netip.Prefix]bool) bool {
+		changed := false
+		for _, prefix := range prefixes {
+			if !routes[prefix.Masked()] {
+				routes[prefix.Masked()] = true
+				changed = true
+			}
+		}
+		return changed
+	})
+}
+
+// remove 路由撤销后不再由 HeadCNI 管理
+func (r *managedRouteRegistry) remove(prefixes ...netip.Prefix) {
+	r.update(func(routes map[netip.Prefix]bool) bool {
+		changed := false
+		for _, prefix := range prefixes {
+			if routes[prefix.Masked()] {
+				delete(routes, prefix.Masked())
+				changed = true
+			}
+		}
+		return changed
+	})
+}
+
+// update 修改并持久化，写入失败只记录日志，内存中的记录仍然生效
+func (r *managedRouteRegistry) update(change func(map[netip.Prefix]bool) bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.loadLocked()
+
+	if !change(r.routes) {
+		return
+	}
+	if err := r.saveLocked(); err != nil {
+		logging.Warnf("Failed to persist managed routes to %s: %v", r.path, err)
+	}
+}
+
+// loadLocked 首次使用时从文件加载，文件不存在时为空
+func (r *managedRouteRegistry) loadLocked() {
+	if r.routes != nil {
+		return
+	}
+	r.routes = make(map[netip.Prefix]bool)
+
+	data, err := os.ReadFile(r.path)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		logging.Warnf("Failed to read managed routes from %s: %v", r.path, err)
+		return
+	}
+
+	var prefixes []string
+	if err := json.Unmarshal(data, &prefixes); err != nil {
+		logging.Warnf("Ignoring invalid managed routes file %s: %v", r.path, err)
+		return
+	}
+	for _, value := range prefixes {
+		if prefix, err := netip.ParsePrefix(value); err == nil {
+			r.routes[prefix.Masked()] = true
+		}
+	}
+}
+
+// saveLocked 原子写入路由列表
+func (r *managedRouteRegistry) saveLocked() error {
+	prefixes := make([]string, 0, len(r.routes))
+	for prefix := range r.routes {
+		prefixes = append(prefixes, prefix.String())
+	}
+	sort.Strings(prefixes)
+
+	data, err := json.Marshal(prefixes)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+		return err
+	}
+	return fsutil.WriteFileAtomic(r.path, data, 0600)
+}
@@ -0,0 +1,253 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/binrclab/headcni/pkg/backend/tailscale"
+	"github.com/binrclab/headcni/pkg/headscale"
+	"github.com/binrclab/headcni/pkg/logging"
+	"github.com/binrclab/headcni/pkg/monitoring"
+)
+
+const (
+	// nodeKeyRefreshBefore 节点密钥剩余有效期小于该值时提前重新认证，与预授权密钥的提前刷新一致
+	nodeKeyRefreshBefore = 2 * time.Hour
+
+	// nodeKeyReauthKeyTTL 重新认证使用的预授权密钥有效期
+	nodeKeyReauthKeyTTL = time.Hour
+
+	// splitBrainReauthBaseDelay、splitBrainReauthMaxDelay 脑裂修复重新认证失败后的退避时长，每次失败翻倍
+	splitBrainReauthBaseDelay = 30 * time.Second
+	splitBrainReauthMaxDelay  = 30 * time.Minute
+)
+
+// reauthBackoff 记录重新认证连续失败的次数和下次允许尝试的时间
+// 每次重新认证都会在 Headscale 中创建预授权密钥，持续失败时不能在每个健康检查周期都重试
+type reauthBackoff struct {
+	mu       sync.Mutex
+	failures int
+	next     time.Time
+}
+
+// remaining 返回距离下次允许重新认证的时长，不大于 0 表示可以立即尝试
+func (b *reauthBackoff) remaining() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return time.Until(b.next)
+}
+
+// failed 记录一次失败并返回下次尝试前的等待时长
+func (b *reauthBackoff) failed() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delay := splitBrainReauthBaseDelay << min(b.failures, 10)
+	if delay > splitBrainReauthMaxDelay {
+		delay = splitBrainReauthMaxDelay
+	}
+	b.failures++
+	b.next = time.Now().Add(delay)
+	return delay
+}
+
+// reset 重新认证成功或不再需要时清除退避
+func (b *reauthBackoff) reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.next = time.Time{}
+}
+
+// nodeKeyExpiry 返回本节点节点密钥的过期时间，零值表示不过期
+// 优先使用 tailscaled 状态中的 KeyExpiry，没有时从 Headscale 节点信息中读取
+func (tsm *TailscaleService) nodeKeyExpiry() (time.Time, error) {
+	ctx, cancel := tsm.callContext()
+	defer cancel()
+
+	status, err := tsm.preparer.GetTailscaleClient().GetStatus(ctx)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get tailscale status: %v", err)
+	}
+	if status.Self != nil && status.Self.KeyExpiry != nil {
+		return *status.Self.KeyExpiry, nil
+	}
+
+	if tsm.preparer.GetHeadscaleClient() == nil {
+		return time.Time{}, nil
+	}
+	nodeID, err := tsm.getCurrentNodeID()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get current node ID: %v", err)
+	}
+	resp, err := tsm.preparer.GetHeadscaleClient().GetNode(ctx, nodeID)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get node %s: %v", nodeID, err)
+	}
+	// Headscale 对不过期的节点返回零值时间
+	if resp.Node.Expiry.Unix() <= 0 {
+		return time.Time{}, nil
+	}
+	return resp.Node.Expiry, nil
+}
+
+// checkNodeKeyExpiry 更新节点密钥过期指标，在节点密钥即将过期时重新认证
+// 节点密钥过期后 tailscaled 不会自行续期，节点会静默断开，直到下一次重连
+func (tsm *TailscaleService) checkNodeKeyExpiry() {
+	expiry, err := tsm.nodeKeyExpiry()
+	if err != nil {
+		logging.Warnf("Failed to get node key expiry: %v", err)
+		return
+	}
+	monitoring.UpdateNodeKeyExpiry(expiry)
+	if expiry.IsZero() {
+		return
+	}
+
+	expiresIn := time.Until(expiry)
+	if expiresIn > nodeKeyRefreshBefore {
+		logging.Debugf("Node key expires in %v", expiresIn)
+		return
+	}
+
+	logging.Infof("Node key expires in %v, reauthenticating early", expiresIn)
+	if err := tsm.reauthenticateNodeKey(); err != nil {
+		logging.Errorf("Failed to reauthenticate before node key expiry: %v", err)
+		return
+	}
+
+	if expiry, err := tsm.nodeKeyExpiry(); err == nil {
+		monitoring.UpdateNodeKeyExpiry(expiry)
+	}
+}
+
+// checkSplitBrain 检查本地 tailscaled 与 Headscale 是否一致
+// Headscale 删除或过期节点后，tailscaled 仍可能处于 Running 并持有旧的节点密钥，ping 会静默失败；
+// 发现本地节点密钥未注册或已过期时设置 SplitBrain 健康条件，并用新的预授权密钥重新认证，成功后清除
+// 重新认证失败后按指数退避，退避期间只保持健康条件；Headscale 不可达时无法判断，保持现状
+func (tsm *TailscaleService) checkSplitBrain() {
+	if tsm.preparer.GetHeadscaleClient() == nil {
+		return
+	}
+
+	ctx, cancel := tsm.callContext()
+	defer cancel()
+
+	status, err := tsm.preparer.GetTailscaleClient().GetStatusFresh(ctx)
+	if err != nil {
+		logging.Warnf("Failed to get tailscale status for split-brain check: %v", err)
+		return
+	}
+	// 未登录的节点由登录流程处理
+	if status.BackendState != tailscale.BackendStateRunning || !status.HaveNodeKey || status.Self == nil || status.Self.PublicKey.IsZero() {
+		return
+	}
+
+	nodeKey := status.Self.PublicKey.String()
+	node, err := tsm.preparer.GetHeadscaleClient().GetNodeByKey(ctx, nodeKey)
+	var reason string
+	switch {
+	case errors.Is(err, headscale.ErrNodeNotFound):
+		reason = fmt.Sprintf("node key %s is not registered in Headscale", nodeKey)
+	case err != nil:
+		logging.Warnf("Failed to look up node key %s in Headscale: %v", nodeKey, err)
+		return
+	case node.Expiry.Unix() > 0 && time.Now().After(node.Expiry):
+		reason = fmt.Sprintf("node %s (%s) with key %s expired in Headscale at %v", node.ID, node.GivenName, nodeKey, node.Expiry)
+	}
+
+	healthMgr := GetGlobalHealthManager()
+	if reason == "" {
+		healthMgr.ClearCondition(HealthConditionSplitBrain)
+		tsm.splitBrainBackoff.reset()
+		return
+	}
+
+	logging.Warnf("SPLIT BRAIN: tailscaled is %s but %s", status.BackendState, reason)
+	healthMgr.SetCondition(HealthConditionSplitBrain, reason)
+	if tsm.skipInDryRun("reauthenticate to repair split brain: %s", reason) {
+		return
+	}
+	if wait := tsm.splitBrainBackoff.remaining(); wait > 0 {
+		logging.Infof("Previous reauthentication after split brain failed, retrying in %v", wait.Round(time.Second))
+		return
+	}
+
+	if err := tsm.reauthenticateNodeKey(); err != nil {
+		delay := tsm.splitBrainBackoff.failed()
+		logging.Errorf("Failed to reauthenticate after split brain, retrying in %v: %v", delay, err)
+		return
+	}
+	tsm.splitBrainBackoff.reset()
+	healthMgr.ClearCondition(HealthConditionSplitBrain)
+	logging.Infof("Split brain repaired by reauthentication")
+}
+
+// reauthenticateNodeKey 用新的一次性预授权密钥强制重新登录，Headscale 按机器密钥复用节点记录并签发新的节点密钥
+// 与身份轮换共用 rotationMu，轮换进行中时跳过
+func (tsm *TailscaleService) reauthenticateNodeKey() error {
+	if !tsm.rotationMu.TryLock() {
+		return fmt.Errorf("identity rotation in progress")
+	}
+	defer tsm.rotationMu.Unlock()
+
+	if tsm.preparer.GetHeadscaleClient() == nil {
+		return fmt.Errorf("headscale client not available")
+	}
+
+	nodeName, err := tsm.preparer.GetK8sClient().GetCurrentNodeName()
+	if err != nil {
+		return fmt.Errorf("failed to get current node name: %v", err)
+	}
+	podCIDRs, err := tsm.nodePodCIDRs(nodeName)
+	if err != nil {
+		return fmt.Errorf("failed to get Pod CIDR for node %s: %v", nodeName, err)
+	}
+
+	ctx, cancel := context.WithTimeout(tsm.ctx, identityRotationTimeout)
+	defer cancel()
+
+	callCtx, callCancel := context.WithTimeout(ctx, tsm.callTimeout())
+	preAuthResp, err := tsm.preparer.GetHeadscaleClient().CreatePreAuthKey(callCtx, tsm.newPreAuthKeyRequest(nodeName, nodeKeyReauthKeyTTL))
+	callCancel()
+	if err != nil {
+		return fmt.Errorf("failed to create pre-auth key: %v", err)
+	}
+	if preAuthResp.PreAuthKey.Key == "" {
+		return fmt.Errorf("headscale returned an empty pre-auth key")
+	}
+
+	hostname := ""
+	if tailscaleEnv := tsm.getTailscaleEnv(); tailscaleEnv != nil {
+		hostname = tailscaleEnv.hostName
+	}
+
+	tsm.setAuthKey(preAuthResp.PreAuthKey.Key, preAuthResp.PreAuthKey.Expiration)
+	if err := tsm.preparer.GetTailscaleClient().ForceLogin(ctx, tailscale.ClientOptions{
+		AuthKey:      preAuthResp.PreAuthKey.Key,
+		Hostname:     hostname,
+		ControlURL:   tsm.preparer.GetConfig().Tailscale.URL,
+		ControlURLs:  tsm.preparer.GetConfig().Tailscale.FallbackURLs,
+		AcceptDNS:    tsm.preparer.GetConfig().Tailscale.AcceptDNS,
+		AcceptRoutes: true,
+		ShieldsUp:    false,
+	}); err != nil {
+		return fmt.Errorf("failed to reauthenticate: %v", err)
+	}
+
+	tailscaleIP, nodeKey, err := tsm.getTailscaleInfo()
+	if err != nil {
+		return fmt.Errorf("failed to get tailscale info after reauthentication: %v", err)
+	}
+
+	// 节点密钥已变化，重新通告路由并更新节点注解
+	tsm.advertiseAndApproveRoutes(podCIDRs, tailscaleIP)
+	if err := tsm.uploadTailscaleInfo(tailscaleIP, nodeKey); err != nil {
+		logging.Warnf("Failed to upload tailscale info: %v", err)
+	}
+
+	logging.Infof("Reauthenticated with a fresh pre-auth key, new node key: %s", nodeKey)
+	return nil
+}
@@ -0,0 +1,134 @@
+package daemon
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/binrclab/headcni/cmd/daemon/config"
+	"github.com/binrclab/headcni/pkg/backend/tailscale"
+	"github.com/binrclab/headcni/pkg/logging"
+)
+
+// orphanInterfacePollInterval 等待孤立网卡期间重新检查的间隔，测试中缩短
+var orphanInterfacePollInterval = time.Second
+
+// orphanInterfaceGracePeriod 返回删除孤立网卡前的等待时间（tailscale.orphanInterfaceGracePeriod）
+func (tsm *TailscaleService) orphanInterfaceGracePeriod() time.Duration {
+	if cfg := tsm.preparer.GetConfig(); cfg != nil && cfg.Tailscale.OrphanInterfaceGracePeriod != "" {
+		if grace, err := time.ParseDuration(cfg.Tailscale.OrphanInterfaceGracePeriod); err == nil && grace >= 0 {
+			return grace
+		}
+	}
+	return config.DefaultOrphanInterfaceGracePeriod
+}
+
+// findTailscaled 在 /proc 中查找以指定 socket 运行的 tailscaled，测试中替换为模拟的进程表
+var findTailscaled = tailscale.FindTailscaled
+
+// orphanInterfaceAction handleOrphanedInterface 等待结束后的处理方式
+type orphanInterfaceAction int
+
+const (
+	// orphanInterfaceAdopt 以本服务 socket 运行的 tailscaled 已创建 socket，接管该进程
+	orphanInterfaceAdopt orphanInterfaceAction = iota
+	// orphanInterfaceStateChanged 等待期间 socket、state 或 PID 文件出现，或网卡消失，按新状态处理
+	orphanInterfaceStateChanged
+	// orphanInterfaceKeep tailscaled 在运行但等待结束时仍未创建 socket，保留网卡
+	orphanInterfaceKeep
+	// orphanInterfaceCleanup 网卡确实是孤立的，删除后重新启动
+	orphanInterfaceCleanup
+)
+
+// orphanInterfaceDecision waitForOrphanedInterface 的结果
+type orphanInterfaceDecision struct {
+	action orphanInterfaceAction
+	// pid 接管或保留网卡时找到的 tailscaled PID
+	pid int
+	// 等待结束时的系统状态，orphanInterfaceStateChanged 时据此重新判断
+	socketExists, stateExists, processExists, interfaceExists bool
+}
+
+// [DAEMON] handleOrphanedInterface 处理网卡存在但 socket、state 和 PID 文件都不存在的情况
+// 启动较慢的节点上，网卡可能属于刚启动、尚未创建 socket 的 tailscaled，立即删除会导致重启循环，
+// 因此先等待宽限期，见 waitForOrphanedInterface
+func (tsm *TailscaleService) handleOrphanedInterface() error {
+	grace := tsm.orphanInterfaceGracePeriod()
+	socketPath := tsm.tailscaleEnv.socketPath
+	interfaceName := tsm.tailscaleEnv.tailscaleNic
+	logging.Infof("Interface %s exists but socket, state and PID files are missing, re-checking for %v before treating it as orphaned", interfaceName, grace)
+
+	decision, err := tsm.waitForOrphanedInterface(grace)
+	if err != nil {
+		return err
+	}
+	switch decision.action {
+	case orphanInterfaceAdopt:
+		return tsm.adoptTailscaled(decision.pid)
+	case orphanInterfaceStateChanged:
+		logging.Infof("Tailscale daemon state changed while waiting (socket: %t, state: %t, process: %t, interface: %t), not treating interface %s as orphaned",
+			decision.socketExists, decision.stateExists, decision.processExists, decision.interfaceExists, interfaceName)
+		return tsm.determineAndExecuteAction(decision.socketExists, decision.stateExists, decision.processExists, decision.interfaceExists)
+	case orphanInterfaceKeep:
+		// 删除网卡会破坏仍在启动的 tailscaled，留给下一次保活检查和看门狗处理
+		return fmt.Errorf("tailscaled PID %d is running with socket %s but has not created it within %v, keeping interface %s", decision.pid, socketPath, grace, interfaceName)
+	}
+
+	logging.Infof("Interface %s is still orphaned after %v and no tailscaled is running with socket %s, cleaning it up", interfaceName, grace, socketPath)
+	return tsm.cleanupInterfaceAndStartFresh()
+}
+
+// waitForOrphanedInterface 在宽限期内每秒重新检查孤立网卡，决定如何处理：
+// 以本服务 socket 运行的 tailscaled 创建 socket 后直接接管；其他文件出现时按新状态处理；
+// 等待结束后仍然只有网卡且没有这样的 tailscaled，才认为网卡是孤立的
+func (tsm *TailscaleService) waitForOrphanedInterface(grace time.Duration) (orphanInterfaceDecision, error) {
+	socketPath := tsm.tailscaleEnv.socketPath
+	deadline := time.Now().Add(grace)
+	for {
+		pid, findErr := findTailscaled(socketPath)
+		socketExists, stateExists, processExists, interfaceExists := tsm.checkSystemState()
+		decision := orphanInterfaceDecision{
+			pid:             pid,
+			socketExists:    socketExists,
+			stateExists:     stateExists,
+			processExists:   processExists,
+			interfaceExists: interfaceExists,
+		}
+
+		if findErr == nil && socketExists {
+			decision.action = orphanInterfaceAdopt
+			return decision, nil
+		}
+		if findErr != nil && (socketExists || stateExists || processExists || !interfaceExists) {
+			decision.action = orphanInterfaceStateChanged
+			return decision, nil
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			decision.action = orphanInterfaceCleanup
+			if findErr == nil {
+				decision.action = orphanInterfaceKeep
+			}
+			return decision, nil
+		}
+		wait := orphanInterfacePollInterval
+		if remaining < wait {
+			wait = remaining
+		}
+		select {
+		case <-tsm.ctx.Done():
+			return orphanInterfaceDecision{}, tsm.ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// [DAEMON] adoptTailscaled 接管以本服务 socket 运行但没有 PID 文件的 tailscaled：补写 PID 文件后复用现有数据启动，
+// StartService 据此继承该进程，网卡保持不变
+func (tsm *TailscaleService) adoptTailscaled(pid int) error {
+	if err := tailscale.AdoptTailscaled(tsm.tailscaleEnv.pidPath, pid); err != nil {
+		return fmt.Errorf("failed to adopt tailscaled PID %d: %v", pid, err)
+	}
+	logging.Infof("Adopting running tailscaled PID %d with socket %s instead of cleaning up interface %s", pid, tsm.tailscaleEnv.socketPath, tsm.tailscaleEnv.tailscaleNic)
+	return tsm.restartWithExistingData()
+}
@@ -0,0 +1,114 @@
+package daemon
+
+import (
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/binrclab/headcni/pkg/headscale/headscaletest"
+)
+
+// newOrphanInterfaceTest 构造只有网卡存在的 daemon 模式环境，findTailscaled 返回 pid 和 findErr
+func newOrphanInterfaceTest(t *testing.T, pid int, findErr error) (*TailscaleService, *TailscaleEnv) {
+	t.Helper()
+	tsm, _, _ := newTestTailscaleService(t, testNode(), netip.MustParseAddr("100.64.0.7"), headscaletest.New())
+	tsm.preparer.GetConfig().Tailscale.OrphanInterfaceGracePeriod = "200ms"
+
+	dir := t.TempDir()
+	netDir := filepath.Join(dir, "net")
+	if err := os.MkdirAll(filepath.Join(netDir, "headcni01"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	env := &TailscaleEnv{
+		isDaemon:     true,
+		configDir:    dir,
+		socketPath:   filepath.Join(dir, "tailscaled.sock"),
+		statePath:    filepath.Join(dir, "tailscaled.state"),
+		pidPath:      filepath.Join(dir, "tailscaled.pid"),
+		tailscaleNic: "headcni01",
+	}
+	tsm.setTailscaleEnv(env)
+
+	oldNet, oldFind, oldPoll := sysClassNet, findTailscaled, orphanInterfacePollInterval
+	sysClassNet, orphanInterfacePollInterval = netDir, 10*time.Millisecond
+	findTailscaled = func(socketPath string) (int, error) {
+		if socketPath != env.socketPath {
+			return 0, fmt.Errorf("unexpected socket %s", socketPath)
+		}
+		return pid, findErr
+	}
+	t.Cleanup(func() { sysClassNet, findTailscaled, orphanInterfacePollInterval = oldNet, oldFind, oldPoll })
+	return tsm, env
+}
+
+func TestOrphanedInterfaceAdoptsTailscaledOnceSocketAppears(t *testing.T) {
+	tsm, env := newOrphanInterfaceTest(t, 4242, nil)
+
+	// tailscaled 已在运行，等待期间创建 socket
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		os.WriteFile(env.socketPath, nil, 0600)
+	}()
+
+	decision, err := tsm.waitForOrphanedInterface(time.Second)
+	if err != nil {
+		t.Fatalf("waitForOrphanedInterface failed: %v", err)
+	}
+	if decision.action != orphanInterfaceAdopt || decision.pid != 4242 {
+		t.Fatalf("expected tailscaled PID 4242 to be adopted, got %+v", decision)
+	}
+}
+
+func TestOrphanedInterfaceKeptWhileTailscaledHasNoSocket(t *testing.T) {
+	tsm, _ := newOrphanInterfaceTest(t, 4242, nil)
+
+	start := time.Now()
+	err := tsm.handleOrphanedInterface()
+	if err == nil || !strings.Contains(err.Error(), "keeping interface headcni01") {
+		t.Fatalf("expected the interface to be kept for the running tailscaled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("expected to wait for the grace period, returned after %v", elapsed)
+	}
+	if _, err := os.Stat(filepath.Join(sysClassNet, "headcni01")); err != nil {
+		t.Errorf("expected interface headcni01 to be kept: %v", err)
+	}
+}
+
+func TestOrphanedInterfaceCleanedUpAfterGracePeriod(t *testing.T) {
+	tsm, _ := newOrphanInterfaceTest(t, 0, fmt.Errorf("no tailscaled running"))
+
+	start := time.Now()
+	decision, err := tsm.waitForOrphanedInterface(200 * time.Millisecond)
+	if err != nil {
+		t.Fatalf("waitForOrphanedInterface failed: %v", err)
+	}
+	if decision.action != orphanInterfaceCleanup {
+		t.Fatalf("expected the interface to be treated as orphaned, got %+v", decision)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("expected to wait for the grace period, returned after %v", elapsed)
+	}
+}
+
+func TestOrphanedInterfaceRecheckedWhenStateAppears(t *testing.T) {
+	tsm, env := newOrphanInterfaceTest(t, 0, fmt.Errorf("no tailscaled running"))
+
+	// 等待期间出现 state 文件，不再是孤立网卡
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		os.WriteFile(env.statePath, []byte("{}"), 0600)
+	}()
+
+	decision, err := tsm.waitForOrphanedInterface(time.Second)
+	if err != nil {
+		t.Fatalf("waitForOrphanedInterface failed: %v", err)
+	}
+	if decision.action != orphanInterfaceStateChanged || !decision.stateExists || !decision.interfaceExists {
+		t.Fatalf("expected the new state to be handled, got %+v", decision)
+	}
+}
@@ -0,0 +1,154 @@
+package daemon
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	coreV1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+
+	"github.com/binrclab/headcni/pkg/constants"
+	"github.com/binrclab/headcni/pkg/k8s"
+	"github.com/binrclab/headcni/pkg/logging"
+)
+
+// hostTailscaleIPTTL 节点 Tailscale IP 的缓存时间，避免每个 Pod 事件都查询 tailscaled
+const hostTailscaleIPTTL = 30 * time.Second
+
+// podAnnotator 实现 k8s.PodEventHandler，观察到 Pod IP 后写入 Pod IP 和节点 Tailscale IP 注解
+// CNI 插件生命周期短且通常没有 Pod 写权限，因此由 daemon 写入
+type podAnnotator struct {
+	preparer *Preparer
+	pods     k8s.PodInterface
+
+	mu         sync.Mutex
+	hostIP     string
+	hostIPTime time.Time
+}
+
+func (a *podAnnotator) OnPodAdd(pod *coreV1.Pod) error {
+	return a.annotate(pod)
+}
+
+func (a *podAnnotator) OnPodUpdate(oldPod, newPod *coreV1.Pod) error {
+	return a.annotate(newPod)
+}
+
+// OnPodDelete Pod 删除后注解随对象一起删除，无需处理
+func (a *podAnnotator) OnPodDelete(pod *coreV1.Pod) error {
+	return nil
+}
+
+// annotate 注解与当前 IP 一致时跳过，Pod 已删除或以同名重建时放弃写入
+func (a *podAnnotator) annotate(pod *coreV1.Pod) error {
+	if pod.Spec.HostNetwork || pod.DeletionTimestamp != nil {
+		return nil
+	}
+	if pod.Status.Phase == coreV1.PodSucceeded || pod.Status.Phase == coreV1.PodFailed {
+		return nil
+	}
+
+	podIP := podIPsAnnotation(pod)
+	if podIP == "" {
+		return nil
+	}
+
+	desired := map[string]string{constants.HeadcniPodIPAnnotationKey: podIP}
+	if hostIP := a.hostTailscaleIP(); hostIP != "" {
+		desired[constants.HeadcniHostTailscaleIPAnnotationKey] = hostIP
+	}
+
+	changed := false
+	for key, value := range desired {
+		if pod.Annotations[key] != value {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err := a.pods.PatchAnnotations(ctx, pod.Namespace, pod.Name, pod.UID, desired)
+	if apierrors.IsNotFound(err) || apierrors.IsConflict(err) {
+		logging.Debugf("Pod %s/%s was deleted or recreated, skipping IP annotations", pod.Namespace, pod.Name)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	logging.Debugf("Annotated pod %s/%s with IP %s", pod.Namespace, pod.Name, podIP)
+	return nil
+}
+
+// hostTailscaleIP 返回本节点的 Tailscale IP，优先 IPv4，tailscaled 不可用时返回上一次的值
+func (a *podAnnotator) hostTailscaleIP() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.hostIP != "" && time.Since(a.hostIPTime) < hostTailscaleIPTTL {
+		return a.hostIP
+	}
+
+	tsClient := a.preparer.GetTailscaleClient()
+	if tsClient == nil {
+		return a.hostIP
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	status, err := tsClient.GetStatus(ctx)
+	if err != nil || status.Self == nil || len(status.Self.TailscaleIPs) == 0 {
+		return a.hostIP
+	}
+
+	hostIP := status.Self.TailscaleIPs[0]
+	for _, addr := range status.Self.TailscaleIPs {
+		if addr.Is4() {
+			hostIP = addr
+			break
+		}
+	}
+	a.hostIP = hostIP.String()
+	a.hostIPTime = time.Now()
+	return a.hostIP
+}
+
+// podIPsAnnotation 返回 Pod 的全部 IP，双栈时以逗号分隔
+func podIPsAnnotation(pod *coreV1.Pod) string {
+	if len(pod.Status.PodIPs) == 0 {
+		return pod.Status.PodIP
+	}
+
+	ips := make([]string, 0, len(pod.Status.PodIPs))
+	for _, podIP := range pod.Status.PodIPs {
+		ips = append(ips, podIP.IP)
+	}
+	return strings.Join(ips, ",")
+}
+
+// startPodAnnotator 监听本节点的 Pod 并写入 IP 注解，需要 pods 的 list/watch/patch 权限
+func (s *PodMonitoringService) startPodAnnotator(ctx context.Context, k8sClient k8s.Client, nodeName string) {
+	if perms := k8sClient.GetPermissions(); perms == nil || !perms.CanListPods {
+		logging.Warnf("Skipping pod IP annotations: no permission to list pods")
+		return
+	}
+
+	annotatorCtx, cancel := context.WithCancel(ctx)
+	s.annotatorCancel = cancel
+
+	annotator := &podAnnotator{preparer: s.preparer, pods: k8sClient.Pods()}
+	go func() {
+		if err := annotator.pods.WatchByNode(annotatorCtx, nodeName, annotator); err != nil {
+			logging.Errorf("Pod IP annotator stopped: %v", err)
+		}
+	}()
+	logging.Infof("Pod IP annotator started for node %s", nodeName)
+}
@@ -0,0 +1,375 @@
+package daemon
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/binrclab/headcni/pkg/constants"
+	"github.com/binrclab/headcni/pkg/k8s"
+	"github.com/binrclab/headcni/pkg/logging"
+	coreV1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/util/retry"
+)
+
+// defaultPerNodePrefixLen 未配置 network.podCIDR.perNode 时的节点切片长度
+const defaultPerNodePrefixLen = 24
+
+// GetNodePodCIDR 获取节点用于 IPAM 和路由通告的 Pod CIDR
+// 节点上报的 Pod CIDR 等于集群聚合网段时（其他 IPAM 负责分配地址，没有节点切片），
+// 依次使用 headcni.pod.cidr 注解中的切片或按节点名确定性派生的切片，避免每个节点都通告整个聚合网段
+// 节点没有 Pod CIDR 且使用集群共享的 network.podCIDR.fallback 时，同样从中为每个节点派生切片
+func (p *Preparer) GetNodePodCIDR(nodeName string) (string, error) {
+	podCIDR, err := p.k8sClient.Nodes().GetPodCIDR(nodeName)
+	if err != nil {
+		fallback, shared, ok := p.fallbackPodCIDR(nodeName)
+		if !ok {
+			return "", err
+		}
+		if shared {
+			return p.nodeSlice(nodeName, fallback, "network.podCIDR.fallback")
+		}
+		podCIDR = fallback.String()
+	}
+
+	base, ok := p.aggregatePodCIDR(podCIDR)
+	if !ok {
+		return podCIDR, nil
+	}
+
+	if _, warned := p.aggregateWarned.LoadOrStore(nodeName, true); !warned {
+		logging.Warnf("Node %s reports Pod CIDR %s which equals the cluster-wide network.podCIDR.base; "+
+			"the node has no per-node slice (another IPAM assigns addresses). Advertising the aggregate from every "+
+			"node would create conflicting Headscale routes, so HeadCNI uses a per-node slice instead", nodeName, podCIDR)
+	}
+	return p.nodeSlice(nodeName, base, "aggregate")
+}
+
+// nodeSlice 返回节点在 base 中的切片：优先使用 headcni.pod.cidr 注解中已记录的切片，否则派生并认领新的切片
+func (p *Preparer) nodeSlice(nodeName string, base netip.Prefix, source string) (string, error) {
+	perNode := defaultPerNodePrefixLen
+	if value := p.GetConfig().Network.PodCIDR.PerNode; value != "" {
+		if size, err := strconv.Atoi(strings.TrimPrefix(value, "/")); err == nil {
+			perNode = size
+		}
+	}
+	if !base.Addr().Is4() || perNode <= base.Bits() || perNode > 32 {
+		return "", fmt.Errorf("node %s Pod CIDR %s (%s) is shared by all nodes and cannot be split into /%d slices", nodeName, base, source, perNode)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	node, err := p.k8sClient.Nodes().Get(ctx, nodeName)
+	if err != nil {
+		return "", fmt.Errorf("failed to get node %s: %w", nodeName, err)
+	}
+	if slice, ok := parseNodeSlice(node.Annotations[constants.HeadcniPodCIDRAnnotationKey], base); ok {
+		return slice.String(), nil
+	}
+
+	slice, err := p.deriveNodeSlice(ctx, nodeName, base, perNode)
+	if err != nil {
+		return "", err
+	}
+	logging.Warnf("Using derived Pod CIDR %s for node %s instead of %s %s", slice, nodeName, source, base)
+
+	// 记录到注解，节点重启后保持同一切片，其他节点派生时跳过该切片
+	if err := p.k8sClient.Nodes().UpdateAnnotations(nodeName, map[string]string{
+		constants.HeadcniPodCIDRAnnotationKey: slice.String(),
+	}); err != nil {
+		logging.Warnf("Failed to record derived Pod CIDR %s on node %s: %v", slice, nodeName, err)
+	}
+
+	return slice.String(), nil
+}
+
+// GetNodePodCIDRs 获取节点的全部 Pod CIDR：第一个与 GetNodePodCIDR 相同，其后是 Spec.PodCIDRs 中的其余网段
+// 其余网段中无效、重复或等于集群聚合网段的条目被跳过
+func (p *Preparer) GetNodePodCIDRs(nodeName string) ([]string, error) {
+	primary, err := p.GetNodePodCIDR(nodeName)
+	if err != nil {
+		return nil, err
+	}
+	podCIDRs := []string{primary}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	node, err := p.k8sClient.Nodes().Get(ctx, nodeName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get node %s: %w", nodeName, err)
+	}
+
+	seen := map[string]bool{primary: true}
+	specCIDRs := k8s.NodePodCIDRs(node)
+	for i, value := range specCIDRs {
+		// 第一个网段已由 GetNodePodCIDR 处理（可能被替换为节点切片）
+		if i == 0 {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(strings.TrimSpace(value))
+		if err != nil {
+			logging.Warnf("Ignoring invalid Pod CIDR %q of node %s: %v", value, nodeName, err)
+			continue
+		}
+		podCIDR := prefix.Masked().String()
+		if seen[podCIDR] {
+			continue
+		}
+		if _, aggregate := p.aggregatePodCIDR(podCIDR); aggregate {
+			logging.Warnf("Ignoring Pod CIDR %s of node %s: it equals the cluster-wide network.podCIDR.base", podCIDR, nodeName)
+			continue
+		}
+		seen[podCIDR] = true
+		podCIDRs = append(podCIDRs, podCIDR)
+	}
+	return podCIDRs, nil
+}
+
+// fallbackPodCIDR 节点 Spec.PodCIDR 为空时（kube-controller-manager 不分配 Pod CIDR，由 HeadCNI IPAM 管理网段），
+// 依次使用 headcni.io/pod-cidr 注解和 network.podCIDR.fallback 配置；无效的值记录警告后跳过
+// shared 表示值来自所有节点共用的配置，调用方需要从中派生节点切片
+func (p *Preparer) fallbackPodCIDR(nodeName string) (prefix netip.Prefix, shared bool, ok bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	node, err := p.k8sClient.Nodes().Get(ctx, nodeName)
+	if err != nil || len(k8s.NodePodCIDRs(node)) > 0 {
+		return netip.Prefix{}, false, false
+	}
+
+	candidates := []struct {
+		source, value string
+		shared        bool
+	}{
+		{"annotation " + constants.HeadcniPodCIDROverrideAnnotationKey, node.Annotations[constants.HeadcniPodCIDROverrideAnnotationKey], false},
+		{"network.podCIDR.fallback", p.GetConfig().Network.PodCIDR.Fallback, true},
+	}
+	for _, candidate := range candidates {
+		if candidate.value == "" {
+			continue
+		}
+		prefix, err := p.validFallbackPodCIDR(candidate.value)
+		if err != nil {
+			logging.Warnf("Ignoring Pod CIDR %q from %s for node %s: %v", candidate.value, candidate.source, nodeName, err)
+			continue
+		}
+		if _, logged := p.fallbackLogged.LoadOrStore(nodeName+"/"+prefix.String(), true); !logged {
+			logging.Infof("Node %s has no Pod CIDR in its spec, using %s from %s", nodeName, prefix, candidate.source)
+		}
+		return prefix, candidate.shared, true
+	}
+	return netip.Prefix{}, false, false
+}
+
+// validFallbackPodCIDR 校验回退的 Pod CIDR：必须是合法网段，且位于 network.podCIDR.base 内
+func (p *Preparer) validFallbackPodCIDR(value string) (netip.Prefix, error) {
+	prefix, err := netip.ParsePrefix(strings.TrimSpace(value))
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid CIDR: %v", err)
+	}
+	prefix = prefix.Masked()
+
+	for _, part := range strings.Split(p.GetConfig().Network.PodCIDR.Base, ",") {
+		base, err := netip.ParsePrefix(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		if base.Addr().Is4() == prefix.Addr().Is4() && base.Bits() <= prefix.Bits() && base.Contains(prefix.Addr()) {
+			return prefix, nil
+		}
+	}
+	return netip.Prefix{}, fmt.Errorf("outside network.podCIDR.base %s", p.GetConfig().Network.PodCIDR.Base)
+}
+
+// aggregatePodCIDR 判断节点 Pod CIDR 是否等于 network.podCIDR.base 中的某个网段
+func (p *Preparer) aggregatePodCIDR(podCIDR string) (netip.Prefix, bool) {
+	nodePrefix, err := netip.ParsePrefix(podCIDR)
+	if err != nil {
+		return netip.Prefix{}, false
+	}
+
+	for _, part := range strings.Split(p.GetConfig().Network.PodCIDR.Base, ",") {
+		base, err := netip.ParsePrefix(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		if base.Masked() == nodePrefix.Masked() {
+			return base.Masked(), true
+		}
+	}
+	return netip.Prefix{}, false
+}
+
+// nodeSliceConfigMap 记录派生节点切片归属的 ConfigMap，位于 daemon 命名空间，键为切片（"/" 替换为 "_"），值为节点名
+const nodeSliceConfigMap = "headcni-pod-cidr-slices"
+
+// deriveNodeSlice 按节点名哈希选取切片，与其他节点已占用的切片冲突时顺延
+// 切片通过 ConfigMap nodeSliceConfigMap 认领：写回携带读取时的 resourceVersion，多个节点同时派生时只有一个写入成功，
+// 其余节点重新读取后顺延到下一个空闲切片；已删除节点的认领会被回收
+func (p *Preparer) deriveNodeSlice(ctx context.Context, nodeName string, base netip.Prefix, perNode int) (netip.Prefix, error) {
+	nodes, err := p.k8sClient.Nodes().List(ctx, nil)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("failed to list nodes: %w", err)
+	}
+
+	existing := make(map[string]bool, len(nodes))
+	annotated := make(map[netip.Prefix]bool)
+	for _, node := range nodes {
+		existing[node.Name] = true
+		if node.Name == nodeName {
+			continue
+		}
+		if slice, ok := parseNodeSlice(node.Annotations[constants.HeadcniPodCIDRAnnotationKey], base); ok {
+			annotated[slice] = true
+		}
+	}
+
+	configMaps := p.k8sClient.ConfigMaps()
+	if configMaps == nil {
+		return netip.Prefix{}, fmt.Errorf("claiming a Pod CIDR slice requires configmaps permissions")
+	}
+	namespace := k8s.PodNamespace()
+	var claimed netip.Prefix
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		configMap, exists, err := loadNodeSlices(ctx, configMaps, namespace)
+		if err != nil {
+			return err
+		}
+
+		used := make(map[netip.Prefix]bool, len(annotated)+len(configMap.Data))
+		for slice := range annotated {
+			used[slice] = true
+		}
+		for key, owner := range configMap.Data {
+			slice, ok := parseNodeSlice(strings.Replace(key, "_", "/", 1), base)
+			if !ok || slice.Bits() != perNode {
+				continue
+			}
+			switch {
+			case owner == nodeName:
+				// 本节点已认领过，注解丢失时沿用
+				claimed = slice
+				return nil
+			case existing[owner]:
+				used[slice] = true
+			default:
+				logging.Infof("Reclaiming Pod CIDR slice %s of deleted node %s", slice, owner)
+				delete(configMap.Data, key)
+			}
+		}
+
+		slice, err := pickNodeSlice(nodeName, base, perNode, used)
+		if err != nil {
+			return err
+		}
+		configMap.Data[nodeSliceKey(slice)] = nodeName
+		if err := saveNodeSlices(ctx, configMaps, namespace, configMap, exists); err != nil {
+			return err
+		}
+		claimed = slice
+		return nil
+	})
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("failed to claim a /%d slice of %s for node %s: %w", perNode, base, nodeName, err)
+	}
+
+	// 写入后重新读取，确认认领仍归本节点
+	configMap, err := configMaps.Get(ctx, namespace, nodeSliceConfigMap)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("failed to verify Pod CIDR slice claim: %w", err)
+	}
+	if owner := configMap.Data[nodeSliceKey(claimed)]; owner != nodeName {
+		return netip.Prefix{}, fmt.Errorf("pod CIDR slice %s was claimed by node %s", claimed, owner)
+	}
+	return claimed, nil
+}
+
+// pickNodeSlice 从按节点名哈希确定的位置开始，返回第一个未被占用的 /perNode 切片
+func pickNodeSlice(nodeName string, base netip.Prefix, perNode int, used map[netip.Prefix]bool) (netip.Prefix, error) {
+	count := uint64(1) << uint(perNode-base.Bits())
+	h := fnv.New32a()
+	h.Write([]byte(nodeName))
+	start := uint64(h.Sum32()) % count
+
+	baseAddr := base.Addr().As4()
+	baseValue := binary.BigEndian.Uint32(baseAddr[:])
+	for i := uint64(0); i < count && i <= uint64(len(used)); i++ {
+		index := (start + i) % count
+		var addr [4]byte
+		binary.BigEndian.PutUint32(addr[:], baseValue+uint32(index<<uint(32-perNode)))
+		slice := netip.PrefixFrom(netip.AddrFrom4(addr), perNode)
+		if !used[slice] {
+			return slice, nil
+		}
+	}
+	return netip.Prefix{}, fmt.Errorf("no free /%d slice left in %s", perNode, base)
+}
+
+// nodeSliceKey 返回切片在 nodeSliceConfigMap 中的键，ConfigMap 的键不能包含 "/"
+func nodeSliceKey(slice netip.Prefix) string {
+	return strings.Replace(slice.String(), "/", "_", 1)
+}
+
+// loadNodeSlices 读取切片认领记录，不存在时返回待创建的空 ConfigMap
+func loadNodeSlices(ctx context.Context, configMaps k8s.ConfigMapInterface, namespace string) (*coreV1.ConfigMap, bool, error) {
+	configMap, err := configMaps.Get(ctx, namespace, nodeSliceConfigMap)
+	if apierrors.IsNotFound(err) {
+		return &coreV1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      nodeSliceConfigMap,
+				Namespace: namespace,
+				Labels:    map[string]string{"app.kubernetes.io/managed-by": "headcni"},
+			},
+			Data: make(map[string]string),
+		}, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if configMap.Data == nil {
+		configMap.Data = make(map[string]string)
+	}
+	return configMap, true, nil
+}
+
+// saveNodeSlices 写回切片认领记录；Update 携带读取时的 resourceVersion，被并发修改时返回 Conflict
+// 并发创建时 AlreadyExists 同样转换为 Conflict，由调用方重新读取后重试
+func saveNodeSlices(ctx context.Context, configMaps k8s.ConfigMapInterface, namespace string, configMap *coreV1.ConfigMap, exists bool) error {
+	if exists {
+		_, err := configMaps.Update(ctx, namespace, configMap)
+		return err
+	}
+
+	_, err := configMaps.Create(ctx, namespace, configMap)
+	if apierrors.IsAlreadyExists(err) {
+		return apierrors.NewConflict(schema.GroupResource{Resource: "configmaps"}, configMap.Name, err)
+	}
+	return err
+}
+
+// parseNodeSlice 解析注解中的节点切片，必须是 base 内比 base 更小的网段
+// 注解记录节点的全部 Pod CIDR（逗号分隔）时，切片是第一个网段
+func parseNodeSlice(value string, base netip.Prefix) (netip.Prefix, bool) {
+	value, _, _ = strings.Cut(value, ",")
+	if value == "" {
+		return netip.Prefix{}, false
+	}
+	slice, err := netip.ParsePrefix(value)
+	if err != nil {
+		return netip.Prefix{}, false
+	}
+	slice = slice.Masked()
+	if slice.Bits() <= base.Bits() || !base.Contains(slice.Addr()) {
+		return netip.Prefix{}, false
+	}
+	return slice, true
+}
@@ -0,0 +1,114 @@
+package daemon
+
+import (
+	"context"
+	"net/netip"
+	"path/filepath"
+	"testing"
+
+	"github.com/binrclab/headcni/cmd/daemon/config"
+	"github.com/binrclab/headcni/pkg/cni"
+	"github.com/binrclab/headcni/pkg/constants"
+	"github.com/binrclab/headcni/pkg/k8s"
+	"github.com/binrclab/headcni/pkg/logging"
+	coreV1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestDerivedNodeSliceIsClaimedInConfigMap(t *testing.T) {
+	t.Setenv("POD_NAMESPACE", "headcni")
+	base := netip.MustParsePrefix("10.42.0.0/16")
+	aggregate := func(name string) *coreV1.Node {
+		return &coreV1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}, Spec: coreV1.NodeSpec{PodCIDR: base.String()}}
+	}
+
+	// node-b 已认领 node-a 哈希到的切片，已删除的 node-z 占着下一个切片
+	hashed, err := pickNodeSlice("node-a", base, 24, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next, err := pickNodeSlice("node-a", base, 24, map[netip.Prefix]bool{hashed: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := &coreV1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: nodeSliceConfigMap, Namespace: "headcni"},
+		Data: map[string]string{
+			nodeSliceKey(hashed): "node-b",
+			nodeSliceKey(next):   "node-z",
+		},
+	}
+	clientset := fake.NewSimpleClientset(aggregate("node-a"), aggregate("node-b"), claims)
+
+	cfg, err := config.DefaultConfig()
+	if err != nil {
+		t.Fatalf("DefaultConfig failed: %v", err)
+	}
+	cfg.Network.PodCIDR.Base = base.String()
+	p := &Preparer{config: cfg, k8sClient: k8s.NewClientForClientset(clientset, &k8s.ClientConfig{
+		BasePermissions: &k8s.PermissionStatus{CanListNodes: true, CanGetNodes: true, CanListConfigMaps: true, CanGetConfigMaps: true},
+	})}
+
+	podCIDR, err := p.GetNodePodCIDR("node-a")
+	if err != nil {
+		t.Fatalf("GetNodePodCIDR failed: %v", err)
+	}
+	if podCIDR != next.String() {
+		t.Fatalf("expected node-a to take the reclaimed slice %s, got %s", next, podCIDR)
+	}
+
+	configMap, err := clientset.CoreV1().ConfigMaps("headcni").Get(context.Background(), nodeSliceConfigMap, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get claims: %v", err)
+	}
+	if owner := configMap.Data[nodeSliceKey(next)]; owner != "node-a" {
+		t.Errorf("expected slice %s to be claimed by node-a, got %q", next, owner)
+	}
+	if owner := configMap.Data[nodeSliceKey(hashed)]; owner != "node-b" {
+		t.Errorf("expected node-b to keep slice %s, got %q", hashed, owner)
+	}
+
+	// 注解丢失后沿用已认领的切片
+	node, err := clientset.CoreV1().Nodes().Get(context.Background(), "node-a", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	delete(node.Annotations, constants.HeadcniPodCIDRAnnotationKey)
+	if _, err := clientset.CoreV1().Nodes().Update(context.Background(), node, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if podCIDR, err := p.GetNodePodCIDR("node-a"); err != nil || podCIDR != next.String() {
+		t.Errorf("expected node-a to keep %s, got %s, %v", next, podCIDR, err)
+	}
+}
+
+func TestGenerateNodeCNIConfig(t *testing.T) {
+	node := &coreV1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}, Spec: coreV1.NodeSpec{
+		PodCIDR:  "10.42.1.0/24",
+		PodCIDRs: []string{"10.42.1.0/24", "10.42.200.0/24", "fd00:42:1::/64"},
+	}}
+	k8sClient := k8s.NewClientForClientset(fake.NewSimpleClientset(node), nil)
+
+	cfg, err := config.DefaultConfig()
+	if err != nil {
+		t.Fatalf("DefaultConfig failed: %v", err)
+	}
+	dir := t.TempDir()
+	cniConfigManager := cni.NewCNIConfigManager(dir, constants.DefaultHeadCNIConfigFile, filepath.Join(dir, "env.yaml"), logging.NewSimpleLogger())
+
+	// 集群中没有 DNS 服务时不猜测默认地址
+	if _, _, err := GenerateNodeCNIConfig(cfg, k8sClient, cniConfigManager, "node-1"); err == nil {
+		t.Fatalf("expected an error without a cluster DNS service IP")
+	}
+
+	cfg.DNS.ServiceIP = "10.43.0.10"
+	_, cniEnv, err := GenerateNodeCNIConfig(cfg, k8sClient, cniConfigManager, "node-1")
+	if err != nil {
+		t.Fatalf("GenerateNodeCNIConfig failed: %v", err)
+	}
+	// 第二个 IPv4 网段只通告路由，不能作为 IPv6 子网写入
+	if cniEnv.Subnet != "10.42.1.0/24" || cniEnv.IPv6Sub != "fd00:42:1::/64" {
+		t.Errorf("expected subnet 10.42.1.0/24 and IPv6 subnet fd00:42:1::/64, got %q and %q", cniEnv.Subnet, cniEnv.IPv6Sub)
+	}
+}
@@ -3,15 +3,18 @@ package daemon
 import (
 	"context"
 	"fmt"
+	"net"
 	"os"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/binrclab/headcni/cmd/daemon/config"
 	"github.com/binrclab/headcni/pkg/backend/tailscale"
 	"github.com/binrclab/headcni/pkg/cni"
 	"github.com/binrclab/headcni/pkg/constants"
 	"github.com/binrclab/headcni/pkg/headscale"
+	"github.com/binrclab/headcni/pkg/ipam"
 	"github.com/binrclab/headcni/pkg/k8s"
 	"github.com/binrclab/headcni/pkg/logging"
 	"github.com/binrclab/headcni/pkg/monitoring"
@@ -19,12 +22,16 @@ import (
 
 // Preparer 系统准备器，负责初始化和管理所有系统组件
 type Preparer struct {
+	// config 和 oldConfig 只整体替换，不原地修改；configMu 保护指针本身
 	config    *config.Config
 	oldConfig *config.Config
+	configMu  sync.RWMutex
+	// reloadMu 串行化配置重载和 API 密钥轮换，避免重载覆盖刚轮换的密钥
+	reloadMu sync.Mutex
 
 	// 客户端
-	headscaleClient *headscale.Client
-	tailscaleClient *tailscale.SimpleClient
+	headscaleClient headscale.HeadscaleAPI
+	tailscaleClient tailscale.TailscaleClient
 	k8sClient       k8s.Client
 
 	// 管理器
@@ -33,6 +40,14 @@ type Preparer struct {
 
 	// 状态 (暂时简化，后续可以扩展)
 
+	// 已提示过 Pod CIDR 为聚合网段的节点
+	aggregateWarned sync.Map
+	// 已记录过使用回退 Pod CIDR 的节点
+	fallbackLogged sync.Map
+
+	// 停止 API 密钥 Secret 监听
+	authKeyWatchCancel context.CancelFunc
+
 	// 清理函数
 	cleanupFuncs []func() error
 	mu           sync.Mutex
@@ -56,7 +71,17 @@ func NewPreparer(cfg *config.Config) (*Preparer, error) {
 // prepare 按顺序准备所有系统组件
 func (p *Preparer) prepare() error {
 	// 1. 准备 Kubernetes 客户端
-	k8sClient := k8s.NewClient(&k8s.ClientConfig{})
+	clientConfig := &k8s.ClientConfig{UseCache: true, DNSNamespaces: p.config.DNS.ServiceNamespaces}
+	// kube-backed IPAM 将分配记录保存在 ConfigMap 中，需要 ConfigMap 权限
+	if p.config.IPAM.Type == ipam.TypeKubeBacked {
+		clientConfig.BasePermissions = &k8s.PermissionStatus{
+			CanListNodes:      true,
+			CanGetNodes:       true,
+			CanListConfigMaps: true,
+			CanGetConfigMaps:  true,
+		}
+	}
+	k8sClient := k8s.NewClient(clientConfig)
 	if err := k8sClient.Connect(context.Background()); err != nil {
 		return fmt.Errorf("failed to connect to kubernetes: %w", err)
 	}
@@ -66,6 +91,13 @@ func (p *Preparer) prepare() error {
 		return fmt.Errorf("failed to start node informer: %w", err)
 	}
 
+	// 等待 informer 缓存同步，未同步时节点读取回退到 API
+	syncCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	if err := k8sClient.WaitForCacheSync(syncCtx); err != nil {
+		logging.Warnf("Informer cache not synced, node reads will hit the API server: %v", err)
+	}
+	cancel()
+
 	p.k8sClient = k8sClient
 	p.addCleanup(func() error {
 		k8sClient.Disconnect()
@@ -73,24 +105,37 @@ func (p *Preparer) prepare() error {
 	})
 	logging.Infof("Kubernetes client prepared successfully")
 
-	// 2. 准备 CNI 组件
-	cniConfigManager := cni.NewCNIConfigManager(
-		constants.DefaultCNIConfigDir,      // CNI 配置目录
-		constants.DefaultHeadCNIConfigFile, // CNI 配置文件名
-		constants.DefaultCNIEnvFile,        // CNI 环境配置文件名
-		logging.NewSimpleLogger(),
-	)
-	if err := p.checkCNIConfig(cniConfigManager); err != nil {
-		return fmt.Errorf("failed to initialize CNI config: %w", err)
+	// 2. 准备 CNI 组件，router 模式节点不承载 Pod，不生成 CNI 配置
+	if p.config.IsRouterMode() {
+		logging.Infof("Router mode, skipping CNI config generation")
+	} else {
+		cniConfigManager := cni.NewCNIConfigManager(
+			constants.DefaultCNIConfigDir,      // CNI 配置目录
+			constants.DefaultHeadCNIConfigFile, // CNI 配置文件名
+			constants.DefaultCNIEnvFile,        // CNI 环境配置文件名
+			logging.NewSimpleLogger(),
+		)
+		cniConfigManager.SetPreservePlugins(p.config.PreservePlugins)
+		if err := p.checkCNIConfig(cniConfigManager); err != nil {
+			return fmt.Errorf("failed to initialize CNI config: %w", err)
+		}
+		p.cniConfigManager = cniConfigManager
 	}
-	p.cniConfigManager = cniConfigManager
 
-	// 3. 准备 Headscale 客户端
+	// 3. 准备 Headscale 客户端，配置了 authKeySecretRef 时 API 密钥从 Secret 读取并监听轮换
+	if err := p.loadAuthKeyFromSecret(p.config); err != nil {
+		return err
+	}
 	headscaleClient, err := headscale.NewClient(&p.config.Headscale)
 	if err != nil {
 		return fmt.Errorf("failed to create headscale client: %w", err)
 	}
 	p.headscaleClient = headscaleClient
+	p.startAuthKeyWatch()
+	p.addCleanup(func() error {
+		p.stopAuthKeyWatch()
+		return nil
+	})
 
 	// 4. 准备 Tailscale 客户端
 	socketPath := p.determineTailscaleSocketPath()
@@ -99,6 +144,7 @@ func (p *Preparer) prepare() error {
 		p.config.Tailscale.Mode, socketPath)
 
 	tailscaleClient := tailscale.NewSimpleClient(socketPath)
+	tailscaleClient.SetStatusCacheTTL(p.config.StatusCacheDuration())
 	p.tailscaleClient = tailscaleClient
 
 	// 5. 准备 Tailscale 服务管理器
@@ -119,8 +165,8 @@ func (p *Preparer) prepare() error {
 func (p *Preparer) determineTailscaleSocketPath() string {
 	switch p.config.Tailscale.Mode {
 	case "host":
-		return constants.DefaultTailscaleHostSocketPath
-	case "daemon":
+		return hostSocketPath
+	case "daemon", "router":
 		// 如果配置中指定了自定义 socket 路径，优先使用
 		if p.config.Tailscale.Socket.Path != "" {
 			return p.config.Tailscale.Socket.Path
@@ -141,58 +187,152 @@ func (p *Preparer) checkCNIConfig(cniConfigManager *cni.CNIConfigManager) error
 	if err != nil {
 		return fmt.Errorf("failed to get current node: %w", err)
 	}
-	currentPodCIDR, err := p.k8sClient.Nodes().GetPodCIDR(node.Name)
-	if err != nil {
-		return fmt.Errorf("failed to get Pod CIDR for node %s: %w", node.Name, err)
-	}
-
-	logging.Infof("Current node Pod CIDR from Kubernetes API: %s", currentPodCIDR)
 
 	// 备份其他配置文件
 	if err := cniConfigManager.BackupOtherConfigLists(); err != nil {
 		logging.Warnf("Failed to backup existing configs: %v", err)
 	}
 
-	dnsServiceIP, clusterDomain := p.getK8sOrK3sDNSAndClusterDomain()
-
 	// 生成新的 CNI 配置
-	configList, cniEnv, err := cniConfigManager.GenerateConfigList(
-		currentPodCIDR, // Pod CIDR
-		p.config,
-		dnsServiceIP,
-		clusterDomain,
-	)
+	configList, cniEnv, err := p.generateNodeCNIConfig(cniConfigManager, node.Name, true)
 	if err != nil {
 		return fmt.Errorf("failed to generate config list: %w", err)
 	}
+	currentPodCIDR := cniEnv.Subnet
+	logging.Infof("Current node Pod CIDR from Kubernetes API: %s", currentPodCIDR)
+
 	// 写入配置文件
 	if err := cniConfigManager.WriteConfigListAndEnv(configList, cniEnv); err != nil {
 		return fmt.Errorf("failed to write config list: %w", err)
 	}
 
+	p.reconcileHostLocalSubnet(cniEnv.Subnet)
+
 	logging.Infof("Successfully initialized/updated CNI config - podCIDR: %s, serviceCIDR: %s, mtu: %d, configPath: %s",
 		currentPodCIDR, p.config.Network.ServiceCIDR, p.config.Network.MTU, cniConfigManager.GetConfigPath())
 
 	return nil
 }
 
-func (p *Preparer) getK8sOrK3sDNSAndClusterDomain() (string, string) {
-	// 使用 k8s 客户端获取 DNS 配置
-	var dnsServiceIP, clusterDomain string
+// GenerateNodeCNIConfig 按 daemon 的规则为节点生成 configlist 和 env，供 headcni install 在 daemon 启动前预先写入
+// 节点网段与 daemon 启动时相同（见 GetNodePodCIDR）；集群 DNS 服务 IP 只使用 dns.serviceIP 或从集群读取的值，
+// 都没有时返回错误，不猜测默认地址
+func GenerateNodeCNIConfig(cfg *config.Config, k8sClient k8s.Client, cniConfigManager *cni.CNIConfigManager, nodeName string) (*cni.CNIPlugin, *cni.CniEnv, error) {
+	p := &Preparer{config: cfg, oldConfig: cfg, k8sClient: k8sClient}
+	return p.generateNodeCNIConfig(cniConfigManager, nodeName, false)
+}
+
+// generateNodeCNIConfig 为节点生成 configlist 和 env：主网段由 GetNodePodCIDR 确定，主网段为 IPv4 时
+// 再加上节点的第一个 IPv6 网段作为 IPv6 子网；其余 IPv4 网段只通告路由，不写入 CNI 配置
+// defaultDNS 为 false 时集群 DNS 服务 IP 无法确定即返回错误
+func (p *Preparer) generateNodeCNIConfig(cniConfigManager *cni.CNIConfigManager, nodeName string, defaultDNS bool) (*cni.CNIPlugin, *cni.CniEnv, error) {
+	podCIDRs, err := p.GetNodePodCIDRs(nodeName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get Pod CIDR for node %s: %w", nodeName, err)
+	}
+	localCIDRs := podCIDRs[:1]
+	if primary := net.ParseIP(strings.Split(podCIDRs[0], "/")[0]); primary != nil && primary.To4() != nil {
+		for _, podCIDR := range podCIDRs[1:] {
+			if ip := net.ParseIP(strings.Split(podCIDR, "/")[0]); ip != nil && ip.To4() == nil {
+				localCIDRs = append(localCIDRs, podCIDR)
+				break
+			}
+		}
+	}
+
+	dnsServiceIP, ipSource, clusterDomain, domainSource := p.resolveClusterDNS()
+	if ipSource == "default" && !defaultDNS {
+		return nil, nil, fmt.Errorf("failed to find the cluster DNS service IP, set dns.serviceIP")
+	}
+	logging.Infof("Cluster DNS service IP %s (source: %s), cluster domain %s (source: %s)",
+		dnsServiceIP, ipSource, clusterDomain, domainSource)
+
+	return cniConfigManager.GenerateConfigList(strings.Join(localCIDRs, ","), p.config, dnsServiceIP, clusterDomain)
+}
+
+// hostLocalStoreDir 返回 CNI 网络对应的 host-local 存储目录，网络名称取自已写入的 CNI 配置
+func (p *Preparer) hostLocalStoreDir(dataDir string) string {
+	networkName := "cbr0"
+	if cniConfigManager := p.GetCNIConfigManager(); cniConfigManager != nil {
+		if configList, err := cniConfigManager.ReadConfigList(); err == nil && configList.Name != "" {
+			networkName = configList.Name
+		}
+	}
+	return ipam.HostLocalStoreDir(dataDir, networkName)
+}
+
+// kubeStore 按当前节点的 Pod CIDR 创建 kube-backed 分配存储
+func (p *Preparer) kubeStore() (*ipam.KubeStore, error) {
+	k8sClient := p.GetK8sClient()
+	if k8sClient == nil {
+		return nil, fmt.Errorf("kubernetes client not available")
+	}
+
+	nodeName, err := k8sClient.GetCurrentNodeName()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current node name: %v", err)
+	}
+	podCIDRStr, err := p.GetNodePodCIDR(nodeName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pod CIDR for node %s: %v", nodeName, err)
+	}
+	_, podCIDR, err := net.ParseCIDR(podCIDRStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pod CIDR %q: %v", podCIDRStr, err)
+	}
 
+	return ipam.NewKubeStore(k8sClient.ConfigMaps(), k8s.PodNamespace(), nodeName, podCIDR, p.GetConfig().IPAM.Reserved)
+}
+
+// reconcileHostLocalSubnet 在 env.yaml 写入新的子网后清理 host-local 存储中旧子网的分配
+// 插件按 env.yaml 的子网分配地址，旧子网遗留的记录不能再交给 Pod
+func (p *Preparer) reconcileHostLocalSubnet(subnet string) {
+	cfg := p.GetConfig()
+	if subnet == "" || cfg == nil || !ipam.UsesHostLocalStore(cfg.IPAM.Type) {
+		return
+	}
+	_, subnetNet, err := net.ParseCIDR(subnet)
+	if err != nil {
+		logging.Warnf("Invalid CNI subnet %q, skipping host-local store reconcile: %v", subnet, err)
+		return
+	}
+
+	storeDir := p.hostLocalStoreDir(cfg.IPAM.LeakReconcile.DataDir)
+	removed, err := ipam.ReconcileHostLocalSubnet(storeDir, subnetNet)
+	if err != nil {
+		logging.Warnf("Failed to reconcile host-local store %s with subnet %s: %v", storeDir, subnet, err)
+		return
+	}
+	if removed > 0 {
+		logging.Infof("Removed %d host-local allocations outside subnet %s from %s", removed, subnet, storeDir)
+	}
+}
+
+// resolveClusterDNS 确定写入 CNI 配置的 DNS 服务 IP 和集群域名，以及各自的来源（override、heuristic 或 default）
+// 优先使用 dns.serviceIP/dns.clusterDomain，未设置时按集群配置推断，推断失败时使用默认值
+func (p *Preparer) resolveClusterDNS() (dnsServiceIP, ipSource, clusterDomain, domainSource string) {
+	dnsServiceIP, ipSource = p.config.DNS.ServiceIP, "override"
+	clusterDomain, domainSource = p.config.DNS.ClusterDomain, "override"
+
+	// 使用 k8s 客户端获取 DNS 配置
 	if p.k8sClient != nil {
 		// 获取 DNS 服务 IP
-		if ip, err := p.k8sClient.GetDNSServiceIP(); err == nil {
-			dnsServiceIP = ip
+		if dnsServiceIP == "" {
+			if ip, err := p.k8sClient.GetDNSServiceIP(); err == nil {
+				dnsServiceIP, ipSource = ip, "heuristic"
+			}
 		}
 		// 获取集群域名
-		if domain, err := p.k8sClient.GetClusterDomain(); err == nil {
-			clusterDomain = domain
+		if clusterDomain == "" {
+			if domain, err := p.k8sClient.GetClusterDomain(); err == nil {
+				clusterDomain, domainSource = domain, "heuristic"
+			}
 		}
 	}
 
 	// 如果无法获取，根据环境设置默认值
 	if dnsServiceIP == "" {
+		ipSource = "default"
 		if p.isK3sEnvironment() {
 			dnsServiceIP = "10.43.0.10" // k3s 默认 DNS 服务 IP
 		} else {
@@ -201,10 +341,10 @@ func (p *Preparer) getK8sOrK3sDNSAndClusterDomain() (string, string) {
 	}
 
 	if clusterDomain == "" {
-		clusterDomain = "cluster.local" // 所有环境都使用相同的集群域名
+		clusterDomain, domainSource = "cluster.local", "default" // 所有环境都使用相同的集群域名
 	}
 
-	return dnsServiceIP, clusterDomain
+	return dnsServiceIP, ipSource, clusterDomain, domainSource
 }
 
 // isK3sEnvironment 检查是否为 k3s 环境
@@ -225,18 +365,34 @@ func (p *Preparer) addCleanup(cleanup func() error) {
 }
 
 // Getter 方法
-func (p *Preparer) GetHeadscaleClient() *headscale.Client       { return p.headscaleClient }
-func (p *Preparer) GetTailscaleClient() *tailscale.SimpleClient { return p.tailscaleClient }
-func (p *Preparer) GetK8sClient() k8s.Client                    { return p.k8sClient }
+func (p *Preparer) GetHeadscaleClient() headscale.HeadscaleAPI    { return p.headscaleClient }
+func (p *Preparer) GetTailscaleClient() tailscale.TailscaleClient { return p.tailscaleClient }
+func (p *Preparer) GetK8sClient() k8s.Client                      { return p.k8sClient }
 
 func (p *Preparer) GetCNIConfigManager() *cni.CNIConfigManager     { return p.cniConfigManager }
 func (p *Preparer) GetTailscaleService() *tailscale.ServiceManager { return p.tailscaleService }
 
 // GetConfig 获取配置
-func (p *Preparer) GetConfig() *config.Config { return p.config }
+func (p *Preparer) GetConfig() *config.Config {
+	p.configMu.RLock()
+	defer p.configMu.RUnlock()
+	return p.config
+}
 
 // GetOldConfig 获取旧配置
-func (p *Preparer) GetOldConfig() *config.Config { return p.oldConfig }
+func (p *Preparer) GetOldConfig() *config.Config {
+	p.configMu.RLock()
+	defer p.configMu.RUnlock()
+	return p.oldConfig
+}
+
+// setConfigs 替换当前配置和旧配置
+func (p *Preparer) setConfigs(cfg, oldConfig *config.Config) {
+	p.configMu.Lock()
+	defer p.configMu.Unlock()
+	p.config = cfg
+	p.oldConfig = oldConfig
+}
 
 // Shutdown 优雅关闭所有组件
 func (p *Preparer) Shutdown(ctx context.Context) error {
@@ -263,7 +419,9 @@ func (p *Preparer) registerServicesToHealthManager() {
 	// 注册所有服务
 	healthMgr.RegisterService(constants.ServiceNameMonitoring)
 	healthMgr.RegisterService(constants.ServiceNameCNI)
-	healthMgr.RegisterService(constants.ServiceNamePodMonitoring)
+	if !p.config.IsRouterMode() {
+		healthMgr.RegisterService(constants.ServiceNamePodMonitoring)
+	}
 	healthMgr.RegisterService(constants.ServiceNameHeadscaleHealth)
 	healthMgr.RegisterService(constants.ServiceNameTailscale)
 
@@ -278,15 +436,21 @@ func (p *Preparer) IsReady() bool {
 }
 
 func (p *Preparer) ReloadConfig() (bool, error) {
+	p.reloadMu.Lock()
+	defer p.reloadMu.Unlock()
+
 	// 保存旧配置用于对比
 	old2Config := p.oldConfig
-	p.oldConfig = p.config
+	p.setConfigs(p.config, p.config)
 
 	// 重新读取配置文件
 	newConfig, err := config.LoadConfig(p.config.ConfigPath)
 	if err != nil {
 		return false, fmt.Errorf("failed to reload config: %v", err)
 	}
+	if err := p.resolveReloadedAuthKey(newConfig); err != nil {
+		return false, fmt.Errorf("failed to reload config: %v", err)
+	}
 
 	// 检查配置变更
 	configChanged, changes := p.compareConfigs(p.oldConfig, newConfig)
@@ -303,6 +467,11 @@ func (p *Preparer) ReloadConfig() (bool, error) {
 			return false, fmt.Errorf("配置更新失败: %v", err)
 		}
 
+		// Secret 引用变化后改为监听新的 Secret
+		if p.oldConfig.Headscale.AuthKeySecretRef != p.config.Headscale.AuthKeySecretRef {
+			p.startAuthKeyWatch()
+		}
+
 		logging.Infof("配置重载成功，检测到 %d 项变更", len(changes))
 	} else {
 		logging.Infof("配置未发生变化")
@@ -317,7 +486,7 @@ func (p *Preparer) transactionalUpdate(newConfig *config.Config, changes []strin
 	backup := p.backupComponents(old2Config)
 
 	// 尝试应用新配置
-	p.config = newConfig
+	p.setConfigs(newConfig, p.oldConfig)
 
 	// 尝试重新创建受影响的组件
 	if err := p.recreateAffectedComponents(changes); err != nil {
@@ -334,8 +503,8 @@ func (p *Preparer) transactionalUpdate(newConfig *config.Config, changes []strin
 
 // componentBackup 组件备份结构
 type componentBackup struct {
-	headscaleClient  *headscale.Client
-	tailscaleClient  *tailscale.SimpleClient
+	headscaleClient  headscale.HeadscaleAPI
+	tailscaleClient  tailscale.TailscaleClient
 	cniConfigManager *cni.CNIConfigManager
 	config           *config.Config
 	oldConfig        *config.Config
@@ -358,8 +527,7 @@ func (p *Preparer) restoreComponents(backup *componentBackup) {
 	p.headscaleClient = backup.headscaleClient
 	p.tailscaleClient = backup.tailscaleClient
 	p.cniConfigManager = backup.cniConfigManager
-	p.config = backup.config
-	p.oldConfig = backup.oldConfig
+	p.setConfigs(backup.config, backup.oldConfig)
 	logging.Infof("组件回滚完成")
 }
 
@@ -380,6 +548,13 @@ func (p *Preparer) compareConfigs(oldConfig, newConfig *config.Config) (bool, []
 		hasChanges = true
 	}
 
+	if oldConfig.Headscale.AuthKeySecretRef != newConfig.Headscale.AuthKeySecretRef {
+		changes = append(changes, fmt.Sprintf("Headscale AuthKeySecretRef: %s/%s -> %s/%s",
+			oldConfig.Headscale.AuthKeySecretRef.Namespace, oldConfig.Headscale.AuthKeySecretRef.Name,
+			newConfig.Headscale.AuthKeySecretRef.Namespace, newConfig.Headscale.AuthKeySecretRef.Name))
+		hasChanges = true
+	}
+
 	if oldConfig.Headscale.Timeout != newConfig.Headscale.Timeout {
 		changes = append(changes, fmt.Sprintf("Headscale Timeout: %s -> %s",
 			oldConfig.Headscale.Timeout, newConfig.Headscale.Timeout))
@@ -392,6 +567,13 @@ func (p *Preparer) compareConfigs(oldConfig, newConfig *config.Config) (bool, []
 		hasChanges = true
 	}
 
+	if oldConfig.Headscale.CircuitBreaker != newConfig.Headscale.CircuitBreaker {
+		changes = append(changes, fmt.Sprintf("Headscale CircuitBreaker: %d/%s -> %d/%s",
+			oldConfig.Headscale.CircuitBreaker.FailureThreshold, oldConfig.Headscale.CircuitBreaker.Cooldown,
+			newConfig.Headscale.CircuitBreaker.FailureThreshold, newConfig.Headscale.CircuitBreaker.Cooldown))
+		hasChanges = true
+	}
+
 	// 比较 Tailscale 配置
 	if oldConfig.Tailscale.Mode != newConfig.Tailscale.Mode {
 		changes = append(changes, fmt.Sprintf("Tailscale Mode: %s -> %s",
@@ -430,12 +612,49 @@ func (p *Preparer) compareConfigs(oldConfig, newConfig *config.Config) (bool, []
 		hasChanges = true
 	}
 
+	if oldConfig.Network.PodCIDR.Fallback != newConfig.Network.PodCIDR.Fallback {
+		changes = append(changes, fmt.Sprintf("Network PodCIDR Fallback: %s -> %s",
+			oldConfig.Network.PodCIDR.Fallback, newConfig.Network.PodCIDR.Fallback))
+		hasChanges = true
+	}
+
 	if oldConfig.Network.EnableIPv6 != newConfig.Network.EnableIPv6 {
 		changes = append(changes, fmt.Sprintf("Network EnableIPv6: %t -> %t",
 			oldConfig.Network.EnableIPv6, newConfig.Network.EnableIPv6))
 		hasChanges = true
 	}
 
+	// DNS 覆盖写入 CNI 配置，按网络配置变更处理
+	if oldConfig.DNS.ServiceIP != newConfig.DNS.ServiceIP {
+		changes = append(changes, fmt.Sprintf("Network DNS ServiceIP: %s -> %s",
+			oldConfig.DNS.ServiceIP, newConfig.DNS.ServiceIP))
+		hasChanges = true
+	}
+
+	if oldConfig.DNS.ClusterDomain != newConfig.DNS.ClusterDomain {
+		changes = append(changes, fmt.Sprintf("Network DNS ClusterDomain: %s -> %s",
+			oldConfig.DNS.ClusterDomain, newConfig.DNS.ClusterDomain))
+		hasChanges = true
+	}
+
+	if strings.Join(oldConfig.DNS.SearchTemplate, ",") != strings.Join(newConfig.DNS.SearchTemplate, ",") {
+		changes = append(changes, fmt.Sprintf("Network DNS SearchTemplate: %v -> %v",
+			oldConfig.DNS.SearchTemplate, newConfig.DNS.SearchTemplate))
+		hasChanges = true
+	}
+
+	if oldConfig.DNS.TailnetDomain != newConfig.DNS.TailnetDomain {
+		changes = append(changes, fmt.Sprintf("Network DNS TailnetDomain: %s -> %s",
+			oldConfig.DNS.TailnetDomain, newConfig.DNS.TailnetDomain))
+		hasChanges = true
+	}
+
+	if strings.Join(oldConfig.PreservePlugins, ",") != strings.Join(newConfig.PreservePlugins, ",") {
+		changes = append(changes, fmt.Sprintf("Network PreservePlugins: %v -> %v",
+			oldConfig.PreservePlugins, newConfig.PreservePlugins))
+		hasChanges = true
+	}
+
 	// 比较监控配置
 	if oldConfig.Monitoring.Enabled != newConfig.Monitoring.Enabled {
 		changes = append(changes, fmt.Sprintf("Monitoring Enabled: %t -> %t",
@@ -500,6 +719,7 @@ func (p *Preparer) recreateAffectedComponents(changes []string) error {
 		logging.Infof("重新创建 Tailscale 客户端...")
 		socketPath := p.determineTailscaleSocketPath()
 		tailscaleClient := tailscale.NewSimpleClient(socketPath)
+		tailscaleClient.SetStatusCacheTTL(p.config.StatusCacheDuration())
 		p.tailscaleClient = tailscaleClient
 		logging.Infof("Tailscale 客户端重新创建成功，使用 socket: %s", socketPath)
 	}
@@ -521,6 +741,7 @@ func (p *Preparer) recreateAffectedComponents(changes []string) error {
 			constants.DefaultCNIEnvFile,
 			logging.NewSimpleLogger(),
 		)
+		cniConfigManager.SetPreservePlugins(p.config.PreservePlugins)
 		p.cniConfigManager = cniConfigManager
 		logging.Infof("CNI 配置管理器重新创建成功")
 	}
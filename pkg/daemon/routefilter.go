@@ -0,0 +1,187 @@
+package daemon
+
+import (
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/vishvananda/netlink"
+	"tailscale.com/tailcfg"
+
+	"github.com/binrclab/headcni/pkg/logging"
+)
+
+// peerRouteCache 缓存通告了子网路由的节点的 ACL 标签
+// 以节点 ID、标签和主路由组成的签名判断节点集合是否变化，变化时重新通过 WhoIs 查询
+type peerRouteCache struct {
+	mu        sync.Mutex
+	signature string
+	tags      map[tailcfg.StableNodeID][]string
+}
+
+// peerRoutes 单个节点当前作为主路由器的子网路由
+type peerRoutes struct {
+	id     tailcfg.StableNodeID
+	ip     string
+	tags   []string
+	routes []netip.Prefix
+}
+
+// reconcileAcceptedRoutes 配置 tailscale.acceptRoutesFromTags 后，删除 tailscaled 为不带这些标签的节点安装的子网路由
+// tailscaled 接受路由时不按标签过滤，共享 tailnet 中无关子网路由器的路由也会装入主机路由表
+func (tsm *TailscaleService) reconcileAcceptedRoutes() error {
+	allowed := tsm.preparer.GetConfig().Tailscale.AcceptRoutesFromTags
+	if len(allowed) == 0 {
+		return nil
+	}
+
+	tailscaleEnv := tsm.getTailscaleEnv()
+	if tailscaleEnv == nil || tailscaleEnv.tailscaleNic == "" {
+		return fmt.Errorf("tailscale interface is not configured")
+	}
+	link, err := netlink.LinkByName(tailscaleEnv.tailscaleNic)
+	if err != nil {
+		logging.Debugf("Tailscale interface %s does not exist yet, skipping accepted route reconcile", tailscaleEnv.tailscaleNic)
+		return nil
+	}
+
+	foreign, err := tsm.foreignPeerRoutes(allowed)
+	if err != nil {
+		return err
+	}
+	if len(foreign) == 0 {
+		return nil
+	}
+
+	// tailscaled 的路由不在 main 表中，按出口接口列出所有路由表（Table 0 即 RT_TABLE_UNSPEC）
+	routes, err := netlink.RouteListFiltered(netlink.FAMILY_ALL,
+		&netlink.Route{LinkIndex: link.Attrs().Index, Table: 0},
+		netlink.RT_FILTER_OIF|netlink.RT_FILTER_TABLE)
+	if err != nil {
+		return fmt.Errorf("failed to list routes on %s: %v", tailscaleEnv.tailscaleNic, err)
+	}
+
+	for _, route := range routes {
+		if route.Dst == nil {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(route.Dst.String())
+		if err != nil || !foreign[prefix] {
+			continue
+		}
+		if tsm.skipInDryRun("remove route %s (table %d) advertised by a peer without tags %v", prefix, route.Table, allowed) {
+			continue
+		}
+		if err := netlink.RouteDel(&route); err != nil {
+			logging.Warnf("Failed to remove route %s (table %d) from untrusted peer: %v", prefix, route.Table, err)
+			continue
+		}
+		logging.Infof("Removed route %s (table %d) advertised by a peer without tags %v", prefix, route.Table, allowed)
+	}
+	return nil
+}
+
+// foreignPeerRoutes 返回只由不带允许标签的节点通告的子网路由
+// 同一网段也由受信节点或标签查询失败的节点通告时保留，避免误删集群路由
+func (tsm *TailscaleService) foreignPeerRoutes(allowed []string) (map[netip.Prefix]bool, error) {
+	ctx, cancel := tsm.callContext()
+	defer cancel()
+
+	client := tsm.preparer.GetTailscaleClient()
+	peers, err := client.GetPeers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get peers: %v", err)
+	}
+
+	var advertisers []peerRoutes
+	for _, peer := range peers {
+		if peer.PrimaryRoutes == nil || peer.PrimaryRoutes.Len() == 0 || len(peer.TailscaleIPs) == 0 {
+			continue
+		}
+		entry := peerRoutes{id: peer.ID, ip: peer.TailscaleIPs[0].String()}
+		if peer.Tags != nil {
+			entry.tags = peer.Tags.AsSlice()
+		}
+		for _, route := range peer.PrimaryRoutes.All() {
+			// 默认路由只在选择出口节点时安装，不在此处过滤
+			if route.Bits() > 0 {
+				entry.routes = append(entry.routes, route)
+			}
+		}
+		if len(entry.routes) > 0 {
+			advertisers = append(advertisers, entry)
+		}
+	}
+
+	cache := &tsm.peerRoutes
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if signature := peerRoutesSignature(advertisers); signature != cache.signature || cache.tags == nil {
+		tags := make(map[tailcfg.StableNodeID][]string, len(advertisers))
+		complete := true
+		for _, peer := range advertisers {
+			peerTags, err := client.ResolvePeerTags(ctx, peer.ip)
+			if err != nil {
+				logging.Warnf("Failed to resolve tags of peer %s: %v", peer.ip, err)
+				complete = false
+				continue
+			}
+			tags[peer.id] = peerTags
+		}
+		cache.tags = tags
+		// 有查询失败时不记录签名，下次重新查询
+		cache.signature = ""
+		if complete {
+			cache.signature = signature
+		}
+	}
+
+	foreign := make(map[netip.Prefix]bool)
+	trusted := make(map[netip.Prefix]bool)
+	for _, peer := range advertisers {
+		peerTags, resolved := cache.tags[peer.id]
+		for _, route := range peer.routes {
+			if !resolved || hasAnyTag(peerTags, allowed) {
+				trusted[route] = true
+			} else {
+				foreign[route] = true
+			}
+		}
+	}
+	for route := range trusted {
+		delete(foreign, route)
+	}
+	return foreign, nil
+}
+
+// peerRoutesSignature 由节点 ID、状态中的标签和路由组成的稳定签名
+func peerRoutesSignature(advertisers []peerRoutes) string {
+	entries := make([]string, 0, len(advertisers))
+	for _, peer := range advertisers {
+		routes := make([]string, 0, len(peer.routes))
+		for _, route := range peer.routes {
+			routes = append(routes, route.String())
+		}
+		sort.Strings(routes)
+		tags := append([]string(nil), peer.tags...)
+		sort.Strings(tags)
+		entries = append(entries, string(peer.id)+"["+strings.Join(tags, ",")+"]="+strings.Join(routes, ","))
+	}
+	sort.Strings(entries)
+	return strings.Join(entries, ";")
+}
+
+// hasAnyTag 节点标签中是否包含任一允许的标签
+func hasAnyTag(tags, allowed []string) bool {
+	for _, tag := range tags {
+		for _, want := range allowed {
+			if tag == want {
+				return true
+			}
+		}
+	}
+	return false
+}
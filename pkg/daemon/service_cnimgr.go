@@ -2,7 +2,9 @@ package daemon
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
 	"net/netip"
 	"strings"
 	"sync"
@@ -11,24 +13,40 @@ import (
 	"github.com/binrclab/headcni/pkg/cni"
 	"github.com/binrclab/headcni/pkg/constants"
 	"github.com/binrclab/headcni/pkg/headscale"
+	"github.com/binrclab/headcni/pkg/ipam"
+	"github.com/binrclab/headcni/pkg/k8s"
 	"github.com/binrclab/headcni/pkg/logging"
+	"github.com/binrclab/headcni/pkg/monitoring"
+	"github.com/binrclab/headcni/pkg/networking"
+	"github.com/binrclab/headcni/pkg/utils"
+	"github.com/containernetworking/cni/pkg/types"
 )
 
 // CNIService CNI 管理服务
 type CNIService struct {
 	preparer  *Preparer
+	tailscale *TailscaleService
 	cniServer *cni.Server
 	running   bool
 	mu        sync.RWMutex
 }
 
 // NewCNIService 创建新的 CNI 服务
-func NewCNIService(preparer *Preparer) *CNIService {
-	return &CNIService{preparer: preparer}
+// tailscaleService 提供外部调用的上下文和超时，修复路由时用它通告 Pod CIDR 并遵循其只读模式
+func NewCNIService(preparer *Preparer, tailscaleService *TailscaleService) *CNIService {
+	return &CNIService{preparer: preparer, tailscale: tailscaleService}
 }
 
 func (s *CNIService) Name() string { return constants.ServiceNameCNI }
 
+// callContext 外部调用使用的上下文，与 TailscaleService 相同：daemon 停止时取消，并受 tailscale.callTimeout 限制
+func (s *CNIService) callContext() (context.Context, context.CancelFunc) {
+	if s.tailscale != nil {
+		return s.tailscale.callContext()
+	}
+	return context.WithTimeout(context.Background(), defaultCallTimeout)
+}
+
 func (s *CNIService) Start(ctx context.Context) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -143,13 +161,15 @@ func (s *CNIService) IsRunning() bool {
 
 // createCNIServerWithRouteValidation 创建带有路由验证的 CNI 服务器
 func (s *CNIService) createCNIServerWithRouteValidation() *cni.Server {
-	return cni.NewServerWithCallbacks(
+	server := cni.NewServerWithCallbacks(
 		constants.DefaultSocketPath,
 		s.handleAllocateWithValidation, // allocate 回调
 		s.handleReleaseWithValidation,  // release 回调
 		s.handleStatusWithValidation,   // status 回调
 		s.handlePodReadyWithValidation, // pod_ready 回调
 	)
+	server.OnSubnetExhausted(s.handleSubnetExhausted)
+	return server
 }
 
 // handleAllocateWithValidation 处理分配请求并验证路由
@@ -157,6 +177,14 @@ func (s *CNIService) handleAllocateWithValidation(req *cni.CNIRequest) *cni.CNIR
 	logging.Infof("CNI allocate request: namespace=%s, pod=%s, localPool=%s",
 		req.Namespace, req.PodName, req.LocalPool)
 
+	// router 模式节点不生成 CNI 配置，不应收到分配请求
+	if s.preparer.GetConfig().IsRouterMode() {
+		return &cni.CNIResponse{
+			Success: false,
+			Error:   "node runs in router mode and does not host pods",
+		}
+	}
+
 	// 如果请求包含 local pool CIDR，验证路由状态
 	if req.LocalPool != "" {
 		if err := s.validateRouteStatus(req.LocalPool); err != nil {
@@ -168,19 +196,438 @@ func (s *CNIService) handleAllocateWithValidation(req *cni.CNIRequest) *cni.CNIR
 		}
 	}
 
+	// Pod 通过 headcni.io/ip 注解请求固定 IP 时，校验后直接返回该地址
+	staticIP, err := s.staticIPForPod(req)
+	if err != nil {
+		logging.Warnf("Static IP request rejected for pod %s/%s: %v", req.Namespace, req.PodName, err)
+		return &cni.CNIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("static IP request rejected: %v", err),
+		}
+	}
+	// 分配结果必须落在节点实际的 Pod CIDR 内，防止 env.yaml 中的子网与节点 CIDR 漂移
+	allocatedIP := staticIP
+	if allocatedIP == nil && req.PodIP != "" {
+		allocatedIP = net.ParseIP(req.PodIP)
+	}
+	if allocatedIP != nil {
+		if err := s.validateIPInNodePodCIDR(allocatedIP); err != nil {
+			return s.rejectAllocatedIP(req, staticIP == nil, err)
+		}
+	}
+	// ipam.reserved 可能在地址分配之后才加入该地址，持有保留地址的 Pod 不能通过校验；静态 IP 已在 staticIPForPod 中校验
+	if staticIP == nil && allocatedIP != nil {
+		if err := s.validateIPNotReserved(allocatedIP, req.LocalPool); err != nil {
+			return s.rejectAllocatedIP(req, true, err)
+		}
+	}
+
+	// kube-backed 由 daemon 分配并记录到 ConfigMap，静态 IP 同样记录，避免被动态分配占用
+	if s.preparer.GetConfig().IPAM.Type == ipam.TypeKubeBacked {
+		return s.allocateFromKubeStore(req, staticIP)
+	}
+
+	// host-local 存储只记录容器 ID，另外保存 Pod 信息供 headcni ipam list 展示
+	s.recordPodMetadata(req)
+
+	if staticIP != nil {
+		logging.Infof("Pod %s/%s requests static IP %s", req.Namespace, req.PodName, staticIP)
+		// 静态地址不经过 host-local 分配，在 host-local 存储中占用该地址，避免被动态分配给其他 Pod
+		storeDir := s.preparer.hostLocalStoreDir(s.preparer.GetConfig().IPAM.LeakReconcile.DataDir)
+		if err := ipam.ReserveHostLocalIP(storeDir, staticIP, req.ContainerID); err != nil {
+			logging.Warnf("Static IP request rejected for pod %s/%s: %v", req.Namespace, req.PodName, err)
+			return &cni.CNIResponse{
+				Success: false,
+				Error:   fmt.Sprintf("static IP request rejected: %v", err),
+			}
+		}
+		return &cni.CNIResponse{
+			Success: true,
+			Data: map[string]interface{}{
+				"ip":     staticIP.String(),
+				"static": true,
+			},
+		}
+	}
+
 	// 执行默认的分配逻辑
 	return &cni.CNIResponse{
 		Success: true,
 	}
 }
 
+// rejectAllocatedIP 拒绝分配结果；allocated 为 true 时地址已由 host-local 分配，释放后再返回，避免每次被拒绝的 ADD 泄漏一个地址
+func (s *CNIService) rejectAllocatedIP(req *cni.CNIRequest, allocated bool, err error) *cni.CNIResponse {
+	logging.Warnf("Allocated IP rejected for pod %s/%s: %v", req.Namespace, req.PodName, err)
+
+	cfg := s.preparer.GetConfig()
+	if allocated && cfg.IPAM.Type != ipam.TypeKubeBacked && req.ContainerID != "" {
+		storeDir := s.preparer.hostLocalStoreDir(cfg.IPAM.LeakReconcile.DataDir)
+		if _, releaseErr := ipam.ReleaseHostLocalContainer(storeDir, req.ContainerID); releaseErr != nil {
+			logging.Warnf("Failed to release rejected IP %s: %v", req.PodIP, releaseErr)
+		}
+	}
+
+	return &cni.CNIResponse{
+		Success: false,
+		Error:   fmt.Sprintf("allocated IP rejected: %v", err),
+	}
+}
+
+// staticIPForPod 读取 Pod 的 headcni.io/ip 注解
+// 未设置注解时返回 nil；无权读取 Pod 时无法检查注解，记录警告后返回 nil；注解非法或不在 local pool 内时返回错误
+func (s *CNIService) staticIPForPod(req *cni.CNIRequest) (net.IP, error) {
+	if req.Namespace == "" || req.PodName == "" {
+		return nil, nil
+	}
+
+	k8sClient := s.preparer.GetK8sClient()
+	if k8sClient == nil {
+		return nil, nil
+	}
+	if perms := k8sClient.GetPermissions(); perms == nil || !perms.CanGetPods {
+		logging.Warnf("No permission to get pods, %s annotation of %s/%s is not applied", constants.HeadcniStaticIPAnnotationKey, req.Namespace, req.PodName)
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	pod, err := k8sClient.Pods().Get(ctx, req.Namespace, req.PodName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pod: %v", err)
+	}
+
+	ip, err := ipam.StaticIPFromAnnotations(pod.Annotations)
+	if err != nil || ip == nil {
+		return nil, err
+	}
+
+	if req.LocalPool != "" {
+		contains, err := utils.CIDRContains(req.LocalPool, ip)
+		if err != nil {
+			return nil, fmt.Errorf("invalid local pool: %v", err)
+		}
+		if !contains {
+			return nil, fmt.Errorf("IP %s is outside node pod CIDR %s", ip, req.LocalPool)
+		}
+	}
+
+	// 网络地址、网关、广播地址和 ipam.reserved 中的地址不能分配给 Pod
+	if err := s.validateIPNotReserved(ip, req.LocalPool); err != nil {
+		return nil, err
+	}
+	if err := s.validateStaticIPFree(ctx, ip, req.ContainerID); err != nil {
+		return nil, err
+	}
+
+	return ip, nil
+}
+
+// validateStaticIPFree 校验静态 IP 没有被本节点的其他容器占用，同一容器重复 ADD 时允许
+// 分配表读取失败时拒绝请求，避免两个 Pod 拿到同一个地址
+func (s *CNIService) validateStaticIPFree(ctx context.Context, ip net.IP, containerID string) error {
+	table, err := s.AllocationTable(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read IPAM allocations: %v", err)
+	}
+	for _, allocation := range table.Allocations {
+		if !ip.Equal(net.ParseIP(allocation.IP)) || allocation.ContainerID == containerID {
+			continue
+		}
+		if allocation.PodName != "" {
+			return fmt.Errorf("IP %s is already allocated to pod %s/%s", ip, allocation.PodNamespace, allocation.PodName)
+		}
+		return fmt.Errorf("IP %s is already allocated to container %s", ip, allocation.ContainerID)
+	}
+	return nil
+}
+
+// validateIPNotReserved 校验 IP 不是网络地址、网关、广播地址或 ipam.reserved 中的地址
+// localPool 为空时使用节点的 Pod CIDR，取不到子网时不校验
+func (s *CNIService) validateIPNotReserved(ip net.IP, localPool string) error {
+	subnet, err := s.podSubnet(localPool)
+	if err != nil || subnet == nil {
+		logging.Debugf("Node pod CIDR unavailable, skipping reserved address check for %s: %v", ip, err)
+		return nil
+	}
+	if !subnet.Contains(ip) {
+		return nil
+	}
+
+	cfg := s.preparer.GetConfig()
+	reserved := cfg.IPAM.Reserved
+	if cfg.IPAM.Type == ipam.TypeHostLocalDelegate {
+		// 上游 host-local 不读取 ipam.reserved
+		reserved = nil
+	}
+	excluded, err := ipam.ExcludedAddresses(cfg.IPAM.Type, subnet, reserved)
+	if err != nil {
+		logging.Debugf("Invalid ipam.reserved for subnet %s, skipping reserved address check: %v", subnet, err)
+		return nil
+	}
+	if excluded(ip) {
+		return fmt.Errorf("IP %s is reserved in subnet %s", ip, subnet)
+	}
+	return nil
+}
+
+// podSubnet 返回 local pool 或当前节点的 Pod CIDR
+func (s *CNIService) podSubnet(localPool string) (*net.IPNet, error) {
+	podCIDR := localPool
+	if podCIDR == "" {
+		k8sClient := s.preparer.GetK8sClient()
+		if k8sClient == nil {
+			return nil, nil
+		}
+		nodeName, err := k8sClient.GetCurrentNodeName()
+		if err != nil {
+			return nil, err
+		}
+		if podCIDR, err = s.preparer.GetNodePodCIDR(nodeName); err != nil {
+			return nil, err
+		}
+	}
+	_, subnet, err := net.ParseCIDR(podCIDR)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pod CIDR %q: %v", podCIDR, err)
+	}
+	return subnet, nil
+}
+
+// allocateFromKubeStore 从 ConfigMap 存储分配 IP，同一容器重复 ADD 时返回已有的地址
+func (s *CNIService) allocateFromKubeStore(req *cni.CNIRequest, staticIP net.IP) *cni.CNIResponse {
+	store, err := s.preparer.kubeStore()
+	if err != nil {
+		logging.Errorf("kube-backed IPAM unavailable for pod %s/%s: %v", req.Namespace, req.PodName, err)
+		return &cni.CNIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("kube-backed IPAM unavailable: %v", err),
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	allocation, err := store.Allocate(ctx, req.Namespace, req.PodName, req.ContainerID, staticIP)
+	if errors.Is(err, ipam.ErrPoolExhausted) {
+		logging.Warnf("kube-backed IPAM exhausted for pod %s/%s: %v", req.Namespace, req.PodName, err)
+		monitoring.RecordError("cni", "ipam_exhausted")
+		requestLeakReconcile()
+		return cni.SubnetExhaustedResponse(s.subnetExhaustedError(ctx))
+	}
+	if err != nil {
+		logging.Warnf("kube-backed IPAM allocation failed for pod %s/%s: %v", req.Namespace, req.PodName, err)
+		return &cni.CNIResponse{
+			Success: false,
+			Error:   fmt.Sprintf("IP allocation failed: %v", err),
+		}
+	}
+
+	logging.Infof("Allocated IP %s to pod %s/%s from configmap %s", allocation.IP, req.Namespace, req.PodName, store.ConfigMapName())
+	return &cni.CNIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"ip":     allocation.IP.String(),
+			"static": staticIP != nil,
+		},
+	}
+}
+
+// releaseFromKubeStore 删除容器在 ConfigMap 存储中的分配，失败只记录日志
+func (s *CNIService) releaseFromKubeStore(req *cni.CNIRequest) {
+	store, err := s.preparer.kubeStore()
+	if err != nil {
+		logging.Warnf("kube-backed IPAM unavailable, cannot release IP of pod %s/%s: %v", req.Namespace, req.PodName, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	allocation, err := store.Release(ctx, req.ContainerID)
+	if err != nil {
+		logging.Warnf("Failed to release IP of pod %s/%s: %v", req.Namespace, req.PodName, err)
+		return
+	}
+	if allocation == nil {
+		return
+	}
+
+	if req.PodIP == "" {
+		flushConntrackForIP(allocation.IP)
+	}
+	logging.Infof("Released IP %s of pod %s/%s from configmap %s", allocation.IP, req.Namespace, req.PodName, store.ConfigMapName())
+}
+
+// recordPodMetadata 记录容器对应的 Pod 信息，失败只记录日志，不影响分配
+func (s *CNIService) recordPodMetadata(req *cni.CNIRequest) {
+	if req.ContainerID == "" {
+		return
+	}
+	meta := ipam.PodMetadata{
+		ContainerID: req.ContainerID,
+		Namespace:   req.Namespace,
+		Name:        req.PodName,
+		UID:         req.PodUID,
+	}
+	if err := ipam.RecordPodMetadata(ipam.DefaultPodMetadataDir, meta); err != nil {
+		logging.Warnf("Failed to record pod metadata for %s/%s: %v", req.Namespace, req.PodName, err)
+	}
+}
+
+// AllocationTable 返回本节点当前的 IPAM 分配表
+func (s *CNIService) AllocationTable(ctx context.Context) (*ipam.AllocationTable, error) {
+	cfg := s.preparer.GetConfig()
+
+	if cfg.IPAM.Type == ipam.TypeKubeBacked {
+		store, err := s.preparer.kubeStore()
+		if err != nil {
+			return nil, err
+		}
+		allocations, err := store.List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return ipam.KubeAllocationTable(allocations, store.PodCIDR(), cfg.IPAM.Reserved)
+	}
+
+	storeDir := s.preparer.hostLocalStoreDir(cfg.IPAM.LeakReconcile.DataDir)
+
+	// 取不到节点 Pod CIDR 时仍列出分配，只是不统计容量
+	var subnet *net.IPNet
+	if k8sClient := s.preparer.GetK8sClient(); k8sClient != nil {
+		if nodeName, err := k8sClient.GetCurrentNodeName(); err == nil {
+			if podCIDR, err := s.preparer.GetNodePodCIDR(nodeName); err == nil {
+				_, subnet, _ = net.ParseCIDR(podCIDR)
+			}
+		}
+	}
+	if subnet == nil {
+		logging.Debugf("Node pod CIDR unavailable, listing IPAM allocations without capacity")
+	}
+
+	return ipam.HostLocalAllocationTable(storeDir, ipam.DefaultPodMetadataDir, subnet, cfg.IPAM.Reserved)
+}
+
+// handleSubnetExhausted 处理插件上报的 host-local 地址耗尽
+// 立即触发一次泄漏回收，使下一次调度前释放已删除 Pod 遗留的地址，并返回带子网使用情况的错误
+func (s *CNIService) handleSubnetExhausted(req *cni.CNIRequest) *cni.CNIResponse {
+	logging.Warnf("IPAM exhausted while allocating for pod %s/%s", req.Namespace, req.PodName)
+	monitoring.RecordError("cni", "ipam_exhausted")
+	requestLeakReconcile()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return cni.SubnetExhaustedResponse(s.subnetExhaustedError(ctx))
+}
+
+// subnetExhaustedError 按当前分配表生成子网耗尽错误，读取失败时不带使用情况
+func (s *CNIService) subnetExhaustedError(ctx context.Context) *types.Error {
+	table, err := s.AllocationTable(ctx)
+	if err != nil {
+		logging.Debugf("Failed to read IPAM allocation table: %v", err)
+		return cni.NewSubnetExhaustedError("unknown", 0, -1)
+	}
+	subnet := table.Subnet
+	if subnet == "" {
+		subnet = "unknown"
+	}
+	return cni.NewSubnetExhaustedError(subnet, table.Used, table.Total)
+}
+
+// validateIPInNodePodCIDR 校验 IP 属于当前节点的 Pod CIDR
+// 不属于时通过各节点的 Pod CIDR 找出实际拥有该 IP 的节点，便于定位配置漂移
+func (s *CNIService) validateIPInNodePodCIDR(ip net.IP) error {
+	k8sClient := s.preparer.GetK8sClient()
+	if k8sClient == nil {
+		return nil
+	}
+
+	node, err := k8sClient.GetCurrentNode()
+	if err != nil {
+		logging.Debugf("Failed to get current node, skipping Pod CIDR check for %s: %v", ip, err)
+		return nil
+	}
+	podCIDRs := k8s.NodePodCIDRs(node)
+	if len(podCIDRs) == 0 {
+		return nil
+	}
+	// 节点上报的是聚合网段时，使用 HeadCNI 为节点分配的切片
+	if _, aggregate := s.preparer.aggregatePodCIDR(podCIDRs[0]); aggregate {
+		slice, err := s.preparer.GetNodePodCIDR(node.Name)
+		if err != nil {
+			return err
+		}
+		podCIDRs = []string{slice}
+	}
+
+	for _, cidr := range podCIDRs {
+		contains, err := utils.CIDRContains(cidr, ip)
+		if err != nil {
+			return err
+		}
+		if contains {
+			return nil
+		}
+	}
+
+	owner := ""
+	if nodeCIDRs, err := k8sClient.Nodes().GetPodCIDRsByNode(); err == nil {
+		owner, _ = utils.NodeForPodIP(nodeCIDRs, ip)
+	}
+	if owner != "" {
+		return fmt.Errorf("IP %s is outside node %s pod CIDR %v and belongs to node %s", ip, node.Name, podCIDRs, owner)
+	}
+	return fmt.Errorf("IP %s is outside node %s pod CIDR %v", ip, node.Name, podCIDRs)
+}
+
 // handleReleaseWithValidation 处理释放请求
 func (s *CNIService) handleReleaseWithValidation(req *cni.CNIRequest) *cni.CNIResponse {
 	logging.Infof("CNI release request: namespace=%s, pod=%s", req.Namespace, req.PodName)
+
+	// 地址可能很快被复用，先清理旧 Pod 的 conntrack 条目
+	if req.PodIP != "" {
+		if ip := net.ParseIP(req.PodIP); ip != nil {
+			flushConntrackForIP(ip)
+		} else {
+			logging.Warnf("Invalid pod IP %q in release request for %s/%s", req.PodIP, req.Namespace, req.PodName)
+		}
+	}
+
+	if req.ContainerID != "" {
+		if s.preparer.GetConfig().IPAM.Type == ipam.TypeKubeBacked {
+			s.releaseFromKubeStore(req)
+		} else {
+			// daemon 占用的静态地址不会被 host-local 的 DEL 删除
+			storeDir := s.preparer.hostLocalStoreDir(s.preparer.GetConfig().IPAM.LeakReconcile.DataDir)
+			if _, err := ipam.ReleaseHostLocalContainer(storeDir, req.ContainerID); err != nil {
+				logging.Warnf("%v", err)
+			}
+		}
+	}
+	if err := ipam.RemovePodMetadata(ipam.DefaultPodMetadataDir, req.ContainerID); err != nil {
+		logging.Warnf("%v", err)
+	}
+
+	// 删除已释放 Pod 的策略链
+	requestPolicySync()
 	// 执行默认的释放逻辑
 	return &cni.CNIResponse{Success: true}
 }
 
+// flushConntrackForIP 清理指定 IP 的 conntrack 条目，失败只记录日志
+func flushConntrackForIP(ip net.IP) {
+	netMgr, err := networking.NewNetworkManager(&networking.Config{})
+	if err != nil {
+		logging.Warnf("Failed to create network manager: %v", err)
+		return
+	}
+	if err := netMgr.FlushConntrackForIP(ip); err != nil {
+		logging.Warnf("%v", err)
+	}
+}
+
 // handleStatusWithValidation 处理状态请求
 func (s *CNIService) handleStatusWithValidation(req *cni.CNIRequest) *cni.CNIResponse {
 	logging.Infof("CNI status request: namespace=%s, pod=%s", req.Namespace, req.PodName)
@@ -207,6 +654,9 @@ func (s *CNIService) handlePodReadyWithValidation(req *cni.CNIRequest) *cni.CNIR
 		}
 	}
 
+	// Pod 已有 IP，为其下发策略链
+	requestPolicySync()
+
 	// 执行默认的 Pod 就绪逻辑
 	return &cni.CNIResponse{
 		Success: true,
@@ -243,8 +693,10 @@ func (s *CNIService) validateRouteStatus(podLocalCIDR string) error {
 		}
 	}
 	time.Sleep(2 * time.Second)
-	// 4. 如果 Headscale 路由未开启，尝试自动开启
-	if !headscaleOK {
+	// 4. 如果 Headscale 路由未开启，尝试自动开启；关闭 headscale.autoApproveRoutes 或设置 headscale.autoApproverTag 时等待外部批准
+	if !headscaleOK && !s.preparer.GetConfig().AutoApproveRoutesEnabled() {
+		logging.Infof("Headscale route for CIDR %s is awaiting approval (route approval by the daemon is disabled)", podLocalCIDR)
+	} else if !headscaleOK {
 		logging.Infof("Headscale route not enabled for CIDR: %s, attempting to enable...", podLocalCIDR)
 		if err := s.enableHeadscaleRoute(podLocalCIDR); err != nil {
 			logging.Warnf("Failed to auto-enable Headscale route: %v", err)
@@ -274,7 +726,9 @@ func (s *CNIService) enableHeadscaleRoute(podLocalCIDR string) error {
 	}
 
 	// 获取所有路由
-	routesResp, err := headscaleClient.GetRoutes(context.Background())
+	ctx, cancel := s.callContext()
+	routesResp, err := headscaleClient.GetRoutes(ctx)
+	cancel()
 	if err != nil {
 		return fmt.Errorf("failed to get routes from Headscale: %v", err)
 	}
@@ -298,8 +752,14 @@ func (s *CNIService) enableHeadscaleRoute(podLocalCIDR string) error {
 		return nil
 	}
 
+	if s.tailscale != nil && s.tailscale.skipInDryRun("enable Headscale route %s (%s)", targetRoute.ID, podLocalCIDR) {
+		return nil
+	}
+
 	// 启用路由
-	if err := headscaleClient.EnableRoute(context.Background(), targetRoute.ID); err != nil {
+	ctx, cancel = s.callContext()
+	defer cancel()
+	if err := headscaleClient.EnableRoute(ctx, targetRoute.ID); err != nil {
 		return fmt.Errorf("failed to enable route %s: %v", targetRoute.ID, err)
 	}
 
@@ -311,43 +771,16 @@ func (s *CNIService) enableHeadscaleRoute(podLocalCIDR string) error {
 func (s *CNIService) applyTailscaleRoute(podLocalCIDR string) error {
 	logging.Infof("Applying Tailscale route for CIDR: %s", podLocalCIDR)
 
-	// 获取 Tailscale 客户端
-	tailscaleClient := s.preparer.GetTailscaleClient()
-	if tailscaleClient == nil {
-		return fmt.Errorf("Tailscale client not available")
-	}
-
-	// 解析新的 CIDR
 	newPrefix, err := netip.ParsePrefix(podLocalCIDR)
 	if err != nil {
 		return fmt.Errorf("invalid CIDR format %s: %v", podLocalCIDR, err)
 	}
-
-	// 获取当前已通告的路由
-	prefs, err := tailscaleClient.GetPrefs(context.Background())
-	if err != nil {
-		return fmt.Errorf("failed to get current preferences: %v", err)
+	if s.tailscale == nil {
+		return fmt.Errorf("Tailscale service not available")
 	}
-
-	// 检查路由是否已存在
-	for _, existingRoute := range prefs.AdvertiseRoutes {
-		if existingRoute.String() == podLocalCIDR {
-			logging.Infof("Route %s already exists in Tailscale", podLocalCIDR)
-			return nil
-		}
-	}
-
-	// 合并现有路由和新路由
-	mergedRoutes := make([]netip.Prefix, len(prefs.AdvertiseRoutes)+1)
-	copy(mergedRoutes, prefs.AdvertiseRoutes)
-	mergedRoutes[len(prefs.AdvertiseRoutes)] = newPrefix
-
-	logging.Infof("Merging routes: existing %d routes + new route %s = total %d routes",
-		len(prefs.AdvertiseRoutes), podLocalCIDR, len(mergedRoutes))
-
-	// 应用合并后的路由
-	if err := tailscaleClient.AdvertiseRoutes(context.Background(), mergedRoutes...); err != nil {
-		return fmt.Errorf("failed to advertise merged routes: %v", err)
+	// 经由 TailscaleService 登记为 HeadCNI 管理的路由，之后才能被收敛撤销
+	if err := s.tailscale.advertiseRoute(newPrefix); err != nil {
+		return err
 	}
 
 	logging.Infof("Successfully applied Tailscale route for CIDR: %s (merged with existing routes)", podLocalCIDR)
@@ -362,7 +795,9 @@ func (s *CNIService) checkTailscaleRouteStatus(podLocalCIDR string) (bool, error
 	}
 
 	// 获取 Tailscale 偏好设置
-	prefs, err := tailscaleClient.GetPrefs(context.Background())
+	ctx, cancel := s.callContext()
+	defer cancel()
+	prefs, err := tailscaleClient.GetPrefs(ctx)
 	if err != nil {
 		return false, fmt.Errorf("failed to get Tailscale preferences: %v", err)
 	}
@@ -388,7 +823,9 @@ func (s *CNIService) checkHeadscaleRouteStatus(podLocalCIDR string) (bool, error
 	}
 
 	// 获取所有路由
-	routes, err := headscaleClient.GetRoutes(context.Background())
+	ctx, cancel := s.callContext()
+	defer cancel()
+	routes, err := headscaleClient.GetRoutes(ctx)
 	if err != nil {
 		return false, fmt.Errorf("failed to get Headscale routes: %v", err)
 	}
@@ -0,0 +1,65 @@
+package daemon
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/binrclab/headcni/cmd/daemon/config"
+	"github.com/binrclab/headcni/pkg/cni"
+	"github.com/binrclab/headcni/pkg/ipam"
+	"github.com/binrclab/headcni/pkg/k8s"
+	coreV1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRejectedAllocationReleasesHostLocalIP(t *testing.T) {
+	t.Setenv("NODE_NAME", "node-1")
+
+	node := &coreV1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}, Spec: coreV1.NodeSpec{PodCIDR: "10.42.1.0/24"}}
+	cfg, err := config.DefaultConfig()
+	if err != nil {
+		t.Fatalf("DefaultConfig failed: %v", err)
+	}
+	cfg.IPAM.Type = ipam.TypeHostLocal
+	cfg.IPAM.Reserved = []string{"10.42.1.5"}
+	cfg.IPAM.LeakReconcile.DataDir = t.TempDir()
+	p := &Preparer{config: cfg, k8sClient: k8s.NewClientForClientset(fake.NewSimpleClientset(node), &k8s.ClientConfig{
+		BasePermissions: &k8s.PermissionStatus{CanListNodes: true, CanGetNodes: true},
+	})}
+	s := NewCNIService(p, nil)
+
+	storeDir := p.hostLocalStoreDir(cfg.IPAM.LeakReconcile.DataDir)
+	if err := os.MkdirAll(storeDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	// host-local 已经分配的保留地址和节点 Pod CIDR 外的地址
+	for _, alloc := range []struct{ ip, container string }{
+		{"10.42.1.5", "reserved"},
+		{"10.42.2.7", "outside"},
+		{"10.42.1.9", "other"},
+	} {
+		if err := os.WriteFile(filepath.Join(storeDir, alloc.ip), []byte(alloc.container+"\r\neth0"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, req := range []*cni.CNIRequest{
+		{Namespace: "default", PodName: "web-0", ContainerID: "reserved", PodIP: "10.42.1.5"},
+		{Namespace: "default", PodName: "web-1", ContainerID: "outside", PodIP: "10.42.2.7"},
+	} {
+		if resp := s.handleAllocateWithValidation(req); resp.Success {
+			t.Fatalf("expected %s to be rejected", req.PodIP)
+		}
+	}
+
+	allocations, err := ipam.ListHostLocalAllocations(storeDir)
+	if err != nil {
+		t.Fatalf("ListHostLocalAllocations failed: %v", err)
+	}
+	if len(allocations) != 1 || !allocations[0].IP.Equal(net.ParseIP("10.42.1.9")) {
+		t.Fatalf("expected rejected addresses to be free again, got %+v", allocations)
+	}
+}
@@ -111,7 +111,16 @@ func (s *HeadscaleHealthService) healthCheckLoop(ctx context.Context) {
 	// 健康检查间隔，默认5分钟
 	checkInterval := 5 * time.Minute
 
-	ticker := time.NewTicker(checkInterval)
+	// 与 Tailscale 服务使用相同的启动延迟，错开各节点首次访问 Headscale 的时间
+	if k8sClient := s.preparer.GetK8sClient(); k8sClient != nil {
+		if nodeName, err := k8sClient.GetCurrentNodeName(); err == nil {
+			if err := waitStartupDelay(ctx, s.preparer.GetConfig(), nodeName, s.Name()); err != nil {
+				return
+			}
+		}
+	}
+
+	ticker := time.NewTicker(withJitter(checkInterval))
 	defer ticker.Stop()
 
 	logging.Infof("Starting Headscale health check loop with interval: %v", checkInterval)
@@ -129,6 +138,7 @@ func (s *HeadscaleHealthService) healthCheckLoop(ctx context.Context) {
 			logging.Infof("Health check loop stopped due to context cancellation")
 			return
 		case <-ticker.C:
+			ticker.Reset(withJitter(checkInterval))
 			if err := s.performHealthCheck(ctx); err != nil {
 				s.mu.Lock()
 				currentFailures := s.consecutiveFailures
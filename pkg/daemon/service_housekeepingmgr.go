@@ -0,0 +1,227 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/binrclab/headcni/cmd/daemon/config"
+	"github.com/binrclab/headcni/pkg/constants"
+	"github.com/binrclab/headcni/pkg/headscale"
+	"github.com/binrclab/headcni/pkg/logging"
+)
+
+const (
+	housekeepingTimeout = 2 * time.Minute
+)
+
+// HeadscaleHousekeepingService 集群级 Headscale 清理服务
+// 每个节点都参与 Lease 选主，只有当选节点执行过期节点清理和孤立路由删除，避免多个节点重复删除
+// 清理只针对 HeadCNI 注册的节点（tailscale.user 用户下带 tag:node:<节点名> 标签），不影响 tailnet 中的其他设备
+// 服务始终处于运行状态，headscale.housekeeping.enabled 只决定是否参与选主，重载配置即可开启或关闭清理
+type HeadscaleHousekeepingService struct {
+	preparer *Preparer
+	running  bool
+	cancel   context.CancelFunc
+	done     chan struct{}
+	mu       sync.RWMutex
+}
+
+// NewHeadscaleHousekeepingService 创建新的 Headscale 清理服务
+func NewHeadscaleHousekeepingService(preparer *Preparer) *HeadscaleHousekeepingService {
+	return &HeadscaleHousekeepingService{preparer: preparer}
+}
+
+func (s *HeadscaleHousekeepingService) Name() string { return constants.ServiceNameHousekeeping }
+
+func (s *HeadscaleHousekeepingService) Start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.running {
+		return nil
+	}
+	if err := s.startElectionLocked(ctx); err != nil {
+		return err
+	}
+	s.running = true
+	return nil
+}
+
+// startElectionLocked 按当前配置开始参与选主，未开启清理时不做任何事，调用方需持有锁
+func (s *HeadscaleHousekeepingService) startElectionLocked(ctx context.Context) error {
+	healthMgr := GetGlobalHealthManager()
+	cfg := s.preparer.GetConfig()
+	if cfg == nil || !cfg.Headscale.Housekeeping.Enabled {
+		logging.Infof("Headscale housekeeping is disabled")
+		healthMgr.UpdateServiceStatus(s.Name(), true, nil)
+		return nil
+	}
+
+	k8sClient := s.preparer.GetK8sClient()
+	if k8sClient == nil {
+		err := fmt.Errorf("kubernetes client not available")
+		healthMgr.UpdateServiceStatus(s.Name(), false, err)
+		return err
+	}
+
+	interval := config.DefaultHousekeepingInterval
+	if cfg.Headscale.Housekeeping.Interval != "" {
+		parsed, err := time.ParseDuration(cfg.Headscale.Housekeeping.Interval)
+		if err != nil || parsed <= 0 {
+			logging.Warnf("Invalid headscale.housekeeping.interval %q, using %v", cfg.Headscale.Housekeeping.Interval, interval)
+		} else {
+			interval = parsed
+		}
+	}
+	leaseName := cfg.Headscale.Housekeeping.LeaseName
+	if leaseName == "" {
+		leaseName = config.DefaultHousekeepingLeaseName
+	}
+
+	electionCtx, cancel := context.WithCancel(ctx)
+	s.cancel = cancel
+	s.done = make(chan struct{})
+
+	go func(done chan struct{}) {
+		defer close(done)
+		// 非 leader 阻塞在选举中，不执行清理
+		err := k8sClient.RunLeaderElectedTask(electionCtx, leaseName, func(leaderCtx context.Context) {
+			s.housekeepingLoop(leaderCtx, interval)
+		})
+		if err != nil {
+			logging.Errorf("Headscale housekeeping leader election failed: %v", err)
+			GetGlobalHealthManager().UpdateServiceStatus(s.Name(), false, err)
+		}
+	}(s.done)
+
+	healthMgr.UpdateServiceStatus(s.Name(), true, nil)
+	logging.Infof("Headscale housekeeping service started, lease: %s, interval: %v", leaseName, interval)
+	return nil
+}
+
+func (s *HeadscaleHousekeepingService) Reload(ctx context.Context) error {
+	newConfig := s.preparer.GetConfig()
+	oldConfig := s.preparer.GetOldConfig()
+	if newConfig == nil {
+		return fmt.Errorf("failed to get new configuration")
+	}
+
+	if oldConfig != nil && newConfig.Headscale.Housekeeping == oldConfig.Headscale.Housekeeping {
+		return nil
+	}
+
+	logging.Infof("Headscale housekeeping configuration changed, restarting leader election")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stopElectionLocked(ctx)
+	return s.startElectionLocked(ctx)
+}
+
+func (s *HeadscaleHousekeepingService) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.running {
+		return nil
+	}
+	s.stopElectionLocked(ctx)
+	s.running = false
+
+	logging.Infof("Headscale housekeeping service stopped")
+	return nil
+}
+
+// stopElectionLocked 停止选主并等待清理循环退出，调用方需持有锁
+func (s *HeadscaleHousekeepingService) stopElectionLocked(ctx context.Context) {
+	cancel, done := s.cancel, s.done
+	s.cancel, s.done = nil, nil
+	if cancel == nil {
+		return
+	}
+
+	// 取消后释放 Lease，其他节点可立即接管
+	cancel()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		logging.Warnf("Timed out waiting for housekeeping leader election to stop")
+	}
+}
+
+func (s *HeadscaleHousekeepingService) IsRunning() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.running
+}
+
+// housekeepingLoop 当选期间周期性执行清理，失去领导权时 ctx 被取消
+func (s *HeadscaleHousekeepingService) housekeepingLoop(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	s.runHousekeeping(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.runHousekeeping(ctx)
+		}
+	}
+}
+
+// runHousekeeping 执行一次清理：删除过期节点和孤立路由
+func (s *HeadscaleHousekeepingService) runHousekeeping(ctx context.Context) {
+	headscaleClient := s.preparer.GetHeadscaleClient()
+	if headscaleClient == nil {
+		logging.Warnf("Headscale client not available, skipping housekeeping")
+		return
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, housekeepingTimeout)
+	defer cancel()
+
+	logging.Debugf("Running headscale housekeeping")
+
+	owned := s.headcniOwnedNodes()
+	var lastErr error
+	if err := headscaleClient.CleanupExpiredNodes(runCtx, owned); err != nil {
+		logging.Warnf("Failed to cleanup expired headscale nodes: %v", err)
+		lastErr = err
+	}
+
+	// 先删除过期节点，其路由随之成为孤立路由
+	pruned, err := headscaleClient.PruneOrphanRoutes(runCtx, owned)
+	if err != nil {
+		logging.Warnf("Failed to prune orphan headscale routes: %v", err)
+		lastErr = err
+	}
+	if pruned > 0 {
+		logging.Infof("Pruned %d orphan headscale routes", pruned)
+	}
+
+	GetGlobalHealthManager().UpdateServiceStatus(s.Name(), true, lastErr)
+}
+
+// headcniOwnedNodes 返回 HeadCNI 注册的节点的过滤器：属于 tailscale.user 用户（默认 default）且带有 tag:node:<节点名> 标签
+// 同一 tailnet 中的其他设备和同一用户下手动注册的设备都不会被清理
+func (s *HeadscaleHousekeepingService) headcniOwnedNodes() headscale.NodeFilter {
+	user := s.preparer.GetConfig().Tailscale.User
+	if user == "" {
+		user = "default"
+	}
+	return func(node headscale.Node) bool {
+		if node.User.Name != user {
+			return false
+		}
+		for _, tag := range node.ForcedTags {
+			if strings.HasPrefix(tag, nodeTagPrefix) {
+				return true
+			}
+		}
+		return false
+	}
+}
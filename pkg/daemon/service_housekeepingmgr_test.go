@@ -0,0 +1,89 @@
+package daemon
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/binrclab/headcni/cmd/daemon/config"
+	"github.com/binrclab/headcni/pkg/headscale"
+	"github.com/binrclab/headcni/pkg/headscale/headscaletest"
+	"github.com/binrclab/headcni/pkg/k8s"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestHousekeepingOnlyCleansHeadCNINodes(t *testing.T) {
+	cfg, err := config.DefaultConfig()
+	if err != nil {
+		t.Fatalf("DefaultConfig failed: %v", err)
+	}
+	cfg.Tailscale.User = "headcni"
+
+	expired := time.Now().Add(-time.Hour)
+	hs := headscaletest.New()
+	hs.AddNode(headscale.Node{ID: "1", User: headscale.User{Name: "headcni"}, ForcedTags: []string{"tag:node:node-1"}, Expiry: expired})
+	// 同一用户下手动注册的设备和其他用户的设备都不属于 HeadCNI
+	hs.AddNode(headscale.Node{ID: "2", User: headscale.User{Name: "headcni"}, Expiry: expired})
+	hs.AddNode(headscale.Node{ID: "3", User: headscale.User{Name: "alice"}, ForcedTags: []string{"tag:node:laptop"}, Expiry: expired})
+	hs.AddRoute(headscale.Route{ID: "10", Node: headscale.Node{ID: "9", User: headscale.User{Name: "headcni"}, ForcedTags: []string{"tag:node:node-9"}}, Prefix: "10.42.9.0/24"})
+	hs.AddRoute(headscale.Route{ID: "11", Node: headscale.Node{ID: "8", User: headscale.User{Name: "alice"}}, Prefix: "192.168.0.0/24"})
+
+	s := NewHeadscaleHousekeepingService(&Preparer{config: cfg, headscaleClient: hs})
+	s.runHousekeeping(context.Background())
+
+	if _, ok := hs.Node("1"); ok {
+		t.Errorf("expected expired HeadCNI node 1 to be deleted")
+	}
+	for _, id := range []string{"2", "3"} {
+		if _, ok := hs.Node(id); !ok {
+			t.Errorf("expected node %s outside HeadCNI to be kept", id)
+		}
+	}
+	routes, err := hs.GetRoutes(context.Background())
+	if err != nil {
+		t.Fatalf("GetRoutes failed: %v", err)
+	}
+	if len(routes.Routes) != 1 || routes.Routes[0].ID != "11" {
+		t.Errorf("expected only the orphan route of a foreign node to be kept, got %+v", routes.Routes)
+	}
+}
+
+func TestHousekeepingCanBeEnabledByReload(t *testing.T) {
+	t.Setenv("NODE_NAME", "node-1")
+	cfg, err := config.DefaultConfig()
+	if err != nil {
+		t.Fatalf("DefaultConfig failed: %v", err)
+	}
+	preparer := &Preparer{config: cfg, oldConfig: cfg, k8sClient: k8s.NewClientForClientset(fake.NewSimpleClientset(), nil)}
+	s := NewHeadscaleHousekeepingService(preparer)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := s.Start(ctx); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	// 关闭清理时服务也处于运行状态，否则 ReloadAll 不会重载它
+	if !s.IsRunning() {
+		t.Fatalf("expected the service to be running with housekeeping disabled")
+	}
+
+	enabled := *cfg
+	enabled.Headscale.Housekeeping.Enabled = true
+	preparer.config = &enabled
+	if err := s.Reload(ctx); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	s.mu.RLock()
+	electing := s.cancel != nil
+	s.mu.RUnlock()
+	if !electing {
+		t.Errorf("expected leader election to start after enabling housekeeping")
+	}
+
+	if err := s.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	if s.IsRunning() {
+		t.Errorf("expected the service to be stopped")
+	}
+}
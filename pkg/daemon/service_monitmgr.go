@@ -2,18 +2,30 @@ package daemon
 
 import (
 	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"os"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/binrclab/headcni/cmd/daemon/config"
 	"github.com/binrclab/headcni/pkg/constants"
+	"github.com/binrclab/headcni/pkg/headscale"
 	"github.com/binrclab/headcni/pkg/logging"
 	"github.com/binrclab/headcni/pkg/monitoring"
 )
 
+// podIPLookupTimeout 单次 Pod IP 查询访问 Headscale 的超时时间
+const podIPLookupTimeout = 10 * time.Second
+
 // MonitoringService 监控服务，实现 Service 接口
 type MonitoringService struct {
 	preparer   *Preparer
@@ -117,7 +129,10 @@ func (s *MonitoringService) Reload(ctx context.Context) error {
 	configChanged := false
 	if oldConfig != nil {
 		if newConfig.Monitoring.Port != oldConfig.Monitoring.Port ||
-			newConfig.Monitoring.Enabled != oldConfig.Monitoring.Enabled {
+			newConfig.Monitoring.Enabled != oldConfig.Monitoring.Enabled ||
+			newConfig.Monitoring.Path != oldConfig.Monitoring.Path ||
+			newConfig.Monitoring.TLS != oldConfig.Monitoring.TLS ||
+			newConfig.Monitoring.Auth != oldConfig.Monitoring.Auth {
 			configChanged = true
 		}
 	}
@@ -160,32 +175,55 @@ func (s *MonitoringService) getPort() int {
 	return port
 }
 
+// getPath 获取指标路径
+func (s *MonitoringService) getPath() string {
+	return s.preparer.GetConfig().MetricsPath()
+}
+
 // startHTTPServer 启动 HTTP 服务器
 func (s *MonitoringService) startHTTPServer() error {
 	port := s.getPort()
-	monitoringEnabled := s.preparer.GetConfig().Monitoring.Enabled
+	monitoringConfig := s.preparer.GetConfig().Monitoring
+	monitoringEnabled := monitoringConfig.Enabled
+
+	tlsConfig, err := buildMonitoringTLSConfig(monitoringConfig.TLS)
+	if err != nil {
+		return err
+	}
 
 	mux := http.NewServeMux()
 
-	// 健康检查端点始终可用
+	// 健康检查端点始终可用，且不需要认证（kubelet 探针使用）
 	mux.HandleFunc("/health", s.handleHealth)
 
+	// Pod IP 所在节点查询，基于 Headscale 路由，不访问 Kubernetes API
+	mux.Handle("/lookup/pod-ip", s.requireAuth(http.HandlerFunc(s.handlePodIPLookup)))
+
 	// Prometheus 指标端点根据配置决定
 	if monitoringEnabled {
-		mux.Handle("/metrics", monitoring.GetPrometheusHandler())
-		logging.Infof("HTTP server started on port %d with /health and /metrics endpoints", port)
+		mux.Handle(s.getPath(), s.requireAuth(monitoring.GetPrometheusHandler()))
+		logging.Infof("HTTP server started on port %d with /health and %s endpoints", port, s.getPath())
 	} else {
 		logging.Infof("HTTP server started on port %d with /health endpoint only (metrics disabled)", port)
 	}
 
 	s.httpServer = &http.Server{
-		Addr:    ":" + strconv.Itoa(port),
-		Handler: mux,
+		Addr:      ":" + strconv.Itoa(port),
+		Handler:   mux,
+		TLSConfig: tlsConfig,
 	}
 
 	// 在后台启动服务器
+	server := s.httpServer
 	go func() {
-		if err := s.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if server.TLSConfig != nil {
+			// 证书已加载到 TLSConfig
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			logging.Errorf("HTTP server error: %v", err)
 		}
 	}()
@@ -193,6 +231,79 @@ func (s *MonitoringService) startHTTPServer() error {
 	return nil
 }
 
+// buildMonitoringTLSConfig 根据配置加载服务端证书和客户端 CA，未配置证书时返回 nil
+// 客户端证书是可选的，/health 仍可在不带证书的情况下访问
+func buildMonitoringTLSConfig(cfg config.MonitoringTLSConfig) (*tls.Config, error) {
+	if cfg.CertFile == "" && cfg.KeyFile == "" {
+		return nil, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load monitoring TLS certificate: %v", err)
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if cfg.ClientCAFile != "" {
+		caPEM, err := os.ReadFile(cfg.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read monitoring client CA: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates found in monitoring client CA %s", cfg.ClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	return tlsConfig, nil
+}
+
+// requireAuth 对受保护端点做认证：持有已校验客户端证书或携带正确 bearer token 的请求放行
+// 未配置 token 和客户端 CA 时不做认证
+func (s *MonitoringService) requireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		monitoringConfig := s.preparer.GetConfig().Monitoring
+		tokenFile := monitoringConfig.Auth.BearerTokenFile
+		clientCA := monitoringConfig.TLS.ClientCAFile
+
+		if tokenFile == "" && clientCA == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if clientCA != "" && r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if tokenFile != "" {
+			// 每次读取文件，Secret 轮换后无需重启
+			token, err := os.ReadFile(tokenFile)
+			if err != nil {
+				logging.Errorf("Failed to read monitoring bearer token file: %v", err)
+				http.Error(w, "authentication unavailable", http.StatusInternalServerError)
+				return
+			}
+
+			expected := strings.TrimSpace(string(token))
+			provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if ok && expected != "" && subtle.ConstantTimeCompare([]byte(provided), []byte(expected)) == 1 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set("WWW-Authenticate", "Bearer")
+		}
+
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	})
+}
+
 // handleHealth 处理健康检查请求
 func (s *MonitoringService) handleHealth(w http.ResponseWriter, r *http.Request) {
 	// 使用全局健康管理器获取整体健康状态
@@ -201,8 +312,8 @@ func (s *MonitoringService) handleHealth(w http.ResponseWriter, r *http.Request)
 
 	w.Header().Set("Content-Type", "application/json")
 
-	// 根据健康状态设置 HTTP 状态码
-	if health.Status == "healthy" {
+	// 根据健康状态设置 HTTP 状态码，degraded 只表示存在需要处理的条件，服务仍然可用
+	if health.Status == "healthy" || health.Status == "degraded" {
 		w.WriteHeader(http.StatusOK)
 	} else {
 		w.WriteHeader(http.StatusServiceUnavailable)
@@ -211,6 +322,67 @@ func (s *MonitoringService) handleHealth(w http.ResponseWriter, r *http.Request)
 	json.NewEncoder(w).Encode(health)
 }
 
+// podIPLookupResponse Pod IP 所在节点查询结果
+type podIPLookupResponse struct {
+	IP          string   `json:"ip"`
+	Prefix      string   `json:"prefix"`
+	NodeID      string   `json:"node_id"`
+	NodeName    string   `json:"node_name"`
+	GivenName   string   `json:"given_name"`
+	IPAddresses []string `json:"ip_addresses"`
+	Online      bool     `json:"online"`
+}
+
+// handlePodIPLookup 处理 Pod IP 所在节点查询请求：GET /lookup/pod-ip?ip=10.244.1.5
+func (s *MonitoringService) handlePodIPLookup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ip := r.URL.Query().Get("ip")
+	if ip == "" {
+		http.Error(w, "missing ip query parameter", http.StatusBadRequest)
+		return
+	}
+	if net.ParseIP(ip) == nil {
+		http.Error(w, fmt.Sprintf("invalid IP address %q", ip), http.StatusBadRequest)
+		return
+	}
+
+	headscaleClient := s.preparer.GetHeadscaleClient()
+	if headscaleClient == nil {
+		http.Error(w, "headscale client not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), podIPLookupTimeout)
+	defer cancel()
+
+	node, prefix, err := headscaleClient.FindNodeForIP(ctx, ip)
+	if errors.Is(err, headscale.ErrNoRouteForIP) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		// Headscale 不可达或返回错误，与 IP 不存在区分开
+		logging.Warnf("Pod IP lookup for %s failed: %v", ip, err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(podIPLookupResponse{
+		IP:          ip,
+		Prefix:      prefix,
+		NodeID:      node.ID,
+		NodeName:    node.Name,
+		GivenName:   node.GivenName,
+		IPAddresses: node.IPAddresses,
+		Online:      node.Online,
+	})
+}
+
 // GetMetrics 获取监控指标（对外接口）
 func (s *MonitoringService) GetMetrics() map[string]interface{} {
 	s.mu.RLock()
@@ -0,0 +1,37 @@
+package daemon
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/binrclab/headcni/pkg/headscale"
+	"github.com/binrclab/headcni/pkg/headscale/headscaletest"
+)
+
+func TestPodIPLookupStatusCodes(t *testing.T) {
+	hs := headscaletest.New()
+	hs.AddNode(headscale.Node{ID: "7", Name: "node-1"})
+	hs.AddRoute(headscale.Route{ID: "3", Node: headscale.Node{ID: "7"}, Prefix: "10.42.1.0/24", Enabled: true})
+	s := NewMonitoringService(&Preparer{headscaleClient: hs})
+
+	lookup := func(ip string) int {
+		rec := httptest.NewRecorder()
+		s.handlePodIPLookup(rec, httptest.NewRequest(http.MethodGet, "/lookup/pod-ip?ip="+ip, nil))
+		return rec.Code
+	}
+
+	if code := lookup("10.42.1.5"); code != http.StatusOK {
+		t.Errorf("expected 200 for a routed IP, got %d", code)
+	}
+	if code := lookup("10.99.0.1"); code != http.StatusNotFound {
+		t.Errorf("expected 404 for an IP no route contains, got %d", code)
+	}
+
+	// Headscale 不可达时不能报告为不存在
+	hs.SetError("FindNodeForIP", fmt.Errorf("connection refused"))
+	if code := lookup("10.42.1.5"); code != http.StatusBadGateway {
+		t.Errorf("expected 502 when headscale fails, got %d", code)
+	}
+}
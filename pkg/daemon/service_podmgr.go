@@ -12,13 +12,16 @@ import (
 
 	"github.com/binrclab/headcni/pkg/constants"
 	"github.com/binrclab/headcni/pkg/headscale"
+	"github.com/binrclab/headcni/pkg/ipam"
 	"github.com/binrclab/headcni/pkg/k8s"
 	"github.com/binrclab/headcni/pkg/logging"
+	"github.com/binrclab/headcni/pkg/monitoring"
 )
 
 // PodMonitoringService Pod 状态监听服务
 type PodMonitoringService struct {
 	preparer  *Preparer
+	tailscale *TailscaleService
 	k8sClient k8s.Client
 	running   bool
 	mu        sync.RWMutex
@@ -27,18 +30,37 @@ type PodMonitoringService struct {
 	currentPodCIDR string
 	lastCheckTime  time.Time
 	checkInterval  time.Duration
+
+	// IP 泄漏回收
+	leakReconcileInterval    time.Duration
+	leakReconcileGracePeriod time.Duration
+
+	// 停止 Pod IP 注解写入
+	annotatorCancel context.CancelFunc
 }
 
 // NewPodMonitoringService 创建新的 Pod 监控服务
-func NewPodMonitoringService(preparer *Preparer) *PodMonitoringService {
+// tailscaleService 提供外部调用的上下文和超时，修复网络时也用它通告 Pod CIDR 路由
+func NewPodMonitoringService(preparer *Preparer, tailscaleService *TailscaleService) *PodMonitoringService {
 	return &PodMonitoringService{
-		preparer:      preparer,
-		checkInterval: 5 * time.Minute, // 每5分钟检查一次网络配置
+		preparer:                 preparer,
+		tailscale:                tailscaleService,
+		checkInterval:            5 * time.Minute,  // 每5分钟检查一次网络配置
+		leakReconcileInterval:    10 * time.Minute, // 默认每10分钟回收一次泄漏 IP
+		leakReconcileGracePeriod: 10 * time.Minute, // 分配至少存在10分钟才允许回收
 	}
 }
 
 func (s *PodMonitoringService) Name() string { return constants.ServiceNamePodMonitoring }
 
+// callContext 外部调用使用的上下文，与 TailscaleService 相同：daemon 停止时取消，并受 tailscale.callTimeout 限制
+func (s *PodMonitoringService) callContext() (context.Context, context.CancelFunc) {
+	if s.tailscale != nil {
+		return s.tailscale.callContext()
+	}
+	return context.WithTimeout(context.Background(), defaultCallTimeout)
+}
+
 func (s *PodMonitoringService) Reload(ctx context.Context) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -59,7 +81,9 @@ func (s *PodMonitoringService) Reload(ctx context.Context) error {
 	configChanged := false
 	oldConfig := s.preparer.GetOldConfig()
 	if oldConfig != nil {
-		if newConfig.Network.PodCIDR.Base != oldConfig.Network.PodCIDR.Base {
+		if newConfig.Network.PodCIDR.Base != oldConfig.Network.PodCIDR.Base ||
+			newConfig.IPAM.LeakReconcile != oldConfig.IPAM.LeakReconcile ||
+			newConfig.IPAM.PodAnnotations != oldConfig.IPAM.PodAnnotations {
 			configChanged = true
 		}
 	}
@@ -107,7 +131,9 @@ func (s *PodMonitoringService) Start(ctx context.Context) error {
 	}
 
 	// 获取当前节点信息
-	node, err := k8sClient.Nodes().Get(context.Background(), nodeName)
+	getCtx, cancel := s.callContext()
+	node, err := k8sClient.Nodes().Get(getCtx, nodeName)
+	cancel()
 	if err != nil {
 		// 更新健康状态为失败
 		healthMgr := GetGlobalHealthManager()
@@ -116,7 +142,7 @@ func (s *PodMonitoringService) Start(ctx context.Context) error {
 	}
 
 	// 记录当前 Pod CIDR
-	s.currentPodCIDR, err = k8sClient.Nodes().GetPodCIDR(nodeName)
+	s.currentPodCIDR, err = s.preparer.GetNodePodCIDR(nodeName)
 	if err != nil {
 		// 更新健康状态为失败
 		healthMgr := GetGlobalHealthManager()
@@ -134,6 +160,16 @@ func (s *PodMonitoringService) Start(ctx context.Context) error {
 	// 启动网络配置监控协程
 	go s.networkConfigMonitor(ctx)
 
+	// 启动 IP 泄漏回收协程（需显式开启）
+	if s.preparer.GetConfig().IPAM.LeakReconcile.Enabled {
+		go s.ipLeakReconcileLoop(ctx, nodeName)
+	}
+
+	// 启动 Pod IP 注解写入（需显式开启）
+	if s.preparer.GetConfig().IPAM.PodAnnotations {
+		s.startPodAnnotator(ctx, k8sClient, nodeName)
+	}
+
 	s.running = true
 
 	// 更新健康状态为成功
@@ -152,6 +188,10 @@ func (s *PodMonitoringService) Stop(ctx context.Context) error {
 	}
 
 	// 清理资源
+	if s.annotatorCancel != nil {
+		s.annotatorCancel()
+		s.annotatorCancel = nil
+	}
 	s.k8sClient = nil
 	s.running = false
 
@@ -234,7 +274,7 @@ func (s *PodMonitoringService) checkNetworkConfiguration(ctx context.Context) {
 	}
 
 	// 获取当前 Pod CIDR
-	currentPodCIDR, err := k8sClient.Nodes().GetPodCIDR(nodeName)
+	currentPodCIDR, err := s.preparer.GetNodePodCIDR(nodeName)
 	if err != nil {
 		logging.Errorf("Failed to get Pod CIDR for node %s: %v", nodeName, err)
 		return
@@ -311,7 +351,9 @@ func (s *PodMonitoringService) checkTailscaleRouteConfiguration(podCIDR string)
 	}
 
 	// 获取 Tailscale 偏好设置
-	prefs, err := tailscaleClient.GetPrefs(context.Background())
+	ctx, cancel := s.callContext()
+	defer cancel()
+	prefs, err := tailscaleClient.GetPrefs(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to get Tailscale preferences: %v", err)
 	}
@@ -335,7 +377,9 @@ func (s *PodMonitoringService) checkHeadscaleRouteStatus(podCIDR string) error {
 	}
 
 	// 获取所有路由
-	routes, err := headscaleClient.GetRoutes(context.Background())
+	ctx, cancel := s.callContext()
+	defer cancel()
+	routes, err := headscaleClient.GetRoutes(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to get Headscale routes: %v", err)
 	}
@@ -408,45 +452,19 @@ func (s *PodMonitoringService) attemptNetworkRepair(ctx context.Context, podCIDR
 func (s *PodMonitoringService) updateTailscaleRoutes(podCIDR string) error {
 	logging.Infof("Updating Tailscale routes for Pod CIDR: %s", podCIDR)
 
-	tailscaleClient := s.preparer.GetTailscaleClient()
-	if tailscaleClient == nil {
-		return fmt.Errorf("tailscale client not available")
-	}
-
-	// 1. 获取当前已通告的路由
-	prefs, err := tailscaleClient.GetPrefs(context.Background())
-	if err != nil {
-		return fmt.Errorf("failed to get current Tailscale preferences: %v", err)
-	}
-
-	// 2. 检查路由是否已存在
-	for _, existingRoute := range prefs.AdvertiseRoutes {
-		if existingRoute.String() == podCIDR {
-			logging.Infof("Route %s already exists in Tailscale, no update needed", podCIDR)
-			return nil
-		}
-	}
-
-	// 3. 解析新的 CIDR
 	newPrefix, err := netip.ParsePrefix(podCIDR)
 	if err != nil {
 		return fmt.Errorf("invalid CIDR format %s: %v", podCIDR, err)
 	}
-
-	// 4. 合并现有路由和新路由
-	mergedRoutes := make([]netip.Prefix, len(prefs.AdvertiseRoutes)+1)
-	copy(mergedRoutes, prefs.AdvertiseRoutes)
-	mergedRoutes[len(prefs.AdvertiseRoutes)] = newPrefix
-
-	logging.Infof("Merging routes: existing %d routes + new route %s = total %d routes",
-		len(prefs.AdvertiseRoutes), podCIDR, len(mergedRoutes))
-
-	// 5. 应用合并后的路由
-	if err := tailscaleClient.AdvertiseRoutes(context.Background(), mergedRoutes...); err != nil {
-		return fmt.Errorf("failed to advertise merged routes: %v", err)
+	if s.tailscale == nil {
+		return fmt.Errorf("tailscale service not available")
+	}
+	// 经由 TailscaleService 登记为 HeadCNI 管理的路由，之后才能被收敛撤销
+	if err := s.tailscale.advertiseRoute(newPrefix); err != nil {
+		return err
 	}
 
-	logging.Infof("Successfully updated Tailscale routes, added %s (total: %d routes)", podCIDR, len(mergedRoutes))
+	logging.Infof("Successfully updated Tailscale routes for Pod CIDR: %s", podCIDR)
 	return nil
 }
 
@@ -460,7 +478,9 @@ func (s *PodMonitoringService) updateHeadscaleRoutes(podCIDR string) error {
 	}
 
 	// 1. 获取所有路由
-	routesResp, err := headscaleClient.GetRoutes(context.Background())
+	ctx, cancel := s.callContext()
+	routesResp, err := headscaleClient.GetRoutes(ctx)
+	cancel()
 	if err != nil {
 		return fmt.Errorf("failed to get routes from Headscale: %v", err)
 	}
@@ -485,9 +505,18 @@ func (s *PodMonitoringService) updateHeadscaleRoutes(podCIDR string) error {
 		return nil
 	}
 
-	// 4. 启用路由
+	// 4. 启用路由，关闭 headscale.autoApproveRoutes 或设置 headscale.autoApproverTag 时等待外部批准
+	if !s.preparer.GetConfig().AutoApproveRoutesEnabled() {
+		logging.Infof("Route for CIDR %s is awaiting approval in Headscale (route approval by the daemon is disabled)", podCIDR)
+		return nil
+	}
+	if s.tailscale != nil && s.tailscale.skipInDryRun("enable Headscale route %s (%s)", targetRoute.ID, podCIDR) {
+		return nil
+	}
 	logging.Infof("Enabling route %s in Headscale", targetRoute.ID)
-	if err := headscaleClient.EnableRoute(context.Background(), targetRoute.ID); err != nil {
+	ctx, cancel = s.callContext()
+	defer cancel()
+	if err := headscaleClient.EnableRoute(ctx, targetRoute.ID); err != nil {
 		return fmt.Errorf("failed to enable route %s in Headscale: %v", targetRoute.ID, err)
 	}
 
@@ -530,7 +559,212 @@ func (s *PodMonitoringService) updateCNIConfiguration(podCIDR string) error {
 	if err := cniConfigManager.WriteCniEnv(cniEnv); err != nil {
 		return fmt.Errorf("failed to update CNI configuration: %v", err)
 	}
+	s.preparer.reconcileHostLocalSubnet(cniEnv.Subnet)
 
 	logging.Infof("Successfully updated CNI configuration for Pod CIDR: %s", podCIDR)
 	return nil
 }
+
+// leakReconcileCh 请求立即执行一次 IPAM 泄漏回收
+var leakReconcileCh = make(chan struct{}, 1)
+
+// requestLeakReconcile 请求立即回收泄漏的 IP，已有待处理请求时忽略
+func requestLeakReconcile() {
+	select {
+	case leakReconcileCh <- struct{}{}:
+	default:
+	}
+}
+
+// ipLeakReconcileLoop 定期回收没有对应 Pod 的 IP 分配
+// kubelet 异常重启时 CNI DEL 可能不会执行，遗留的分配最终会耗尽子网
+func (s *PodMonitoringService) ipLeakReconcileLoop(ctx context.Context, nodeName string) {
+	leakCfg := s.preparer.GetConfig().IPAM.LeakReconcile
+
+	interval := s.leakReconcileInterval
+	if leakCfg.Interval != "" {
+		if d, err := time.ParseDuration(leakCfg.Interval); err == nil && d > 0 {
+			interval = d
+		} else {
+			logging.Warnf("Invalid IPAM leak reconcile interval %q, using %v", leakCfg.Interval, interval)
+		}
+	}
+
+	gracePeriod := s.leakReconcileGracePeriod
+	if leakCfg.GracePeriod != "" {
+		if d, err := time.ParseDuration(leakCfg.GracePeriod); err == nil && d >= 0 {
+			gracePeriod = d
+		} else {
+			logging.Warnf("Invalid IPAM leak reconcile grace period %q, using %v", leakCfg.GracePeriod, gracePeriod)
+		}
+	}
+
+	logging.Infof("IPAM leak reconciler started (interval: %v, grace period: %v)", interval, gracePeriod)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			logging.Infof("IPAM leak reconciler stopped")
+			return
+		case <-ticker.C:
+		case <-leakReconcileCh:
+			logging.Infof("Running IPAM leak reconcile on request")
+		}
+
+		reclaimed, err := s.reconcileLeakedIPs(nodeName, leakCfg.DataDir, gracePeriod)
+		if err != nil {
+			logging.Warnf("IPAM leak reconcile failed: %v", err)
+			monitoring.RecordError("cni", "unknown")
+			continue
+		}
+		if reclaimed > 0 {
+			logging.Infof("IPAM leak reconciler reclaimed %d IPs", reclaimed)
+		}
+	}
+}
+
+// reconcileLeakedIPs 对比 IPAM 分配与节点上的 Pod，释放没有运行中 Pod 的分配
+func (s *PodMonitoringService) reconcileLeakedIPs(nodeName, dataDir string, gracePeriod time.Duration) (int, error) {
+	k8sClient := s.preparer.GetK8sClient()
+	if k8sClient == nil {
+		return 0, fmt.Errorf("kubernetes client not available")
+	}
+
+	// 没有 Pod 权限时无法判断 Pod 是否存在，跳过回收
+	if perms := k8sClient.GetPermissions(); perms == nil || !perms.CanListPods {
+		logging.Debugf("Skipping IPAM leak reconcile: no permission to list pods")
+		return 0, nil
+	}
+
+	if s.preparer.GetConfig().IPAM.Type == ipam.TypeKubeBacked {
+		return s.reconcileLeakedKubeIPs(nodeName, gracePeriod)
+	}
+
+	storeDir := s.preparer.hostLocalStoreDir(dataDir)
+
+	allocations, err := ipam.ListHostLocalAllocations(storeDir)
+	if err != nil {
+		return 0, err
+	}
+	if len(allocations) == 0 {
+		return 0, nil
+	}
+
+	// 先读取存储再列出 Pod，避免把列表之后才创建的分配误判为泄漏
+	liveIPs, err := s.livePodIPs(nodeName)
+	if err != nil {
+		return 0, err
+	}
+
+	reclaimed := 0
+	for _, allocation := range allocations {
+		if liveIPs[allocation.IP.String()] {
+			continue
+		}
+
+		// 创建中的 Pod 可能还没有上报 IP，宽限期内不回收
+		if time.Since(allocation.ModTime) < gracePeriod {
+			logging.Debugf("Skipping recent allocation %s (container %s) within grace period",
+				allocation.IP, allocation.ContainerID)
+			continue
+		}
+
+		if err := ipam.ReleaseHostLocalAllocation(allocation); err != nil {
+			logging.Warnf("%v", err)
+			continue
+		}
+		flushConntrackForIP(allocation.IP)
+		if err := ipam.RemovePodMetadata(ipam.DefaultPodMetadataDir, allocation.ContainerID); err != nil {
+			logging.Warnf("%v", err)
+		}
+		logging.Infof("Reclaimed leaked IP %s (container %s) on node %s",
+			allocation.IP, allocation.ContainerID, nodeName)
+		reclaimed++
+	}
+
+	monitoring.RecordReclaimedIPs(reclaimed)
+	return reclaimed, nil
+}
+
+// reconcileLeakedKubeIPs 对比 ConfigMap 中的分配与节点上的 Pod，释放没有运行中 Pod 的分配
+func (s *PodMonitoringService) reconcileLeakedKubeIPs(nodeName string, gracePeriod time.Duration) (int, error) {
+	store, err := s.preparer.kubeStore()
+	if err != nil {
+		return 0, fmt.Errorf("kube-backed IPAM unavailable: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	allocations, err := store.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if len(allocations) == 0 {
+		return 0, nil
+	}
+
+	// 先读取存储再列出 Pod，避免把列表之后才创建的分配误判为泄漏
+	liveIPs, err := s.livePodIPs(nodeName)
+	if err != nil {
+		return 0, err
+	}
+
+	reclaimed := 0
+	for _, allocation := range allocations {
+		if liveIPs[allocation.IP.String()] {
+			continue
+		}
+
+		// 创建中的 Pod 可能还没有上报 IP，宽限期内不回收
+		if time.Since(allocation.AllocatedAt) < gracePeriod {
+			logging.Debugf("Skipping recent allocation %s (pod %s/%s) within grace period",
+				allocation.IP, allocation.PodNamespace, allocation.PodName)
+			continue
+		}
+
+		released, err := store.Release(ctx, allocation.ContainerID)
+		if err != nil {
+			logging.Warnf("%v", err)
+			continue
+		}
+		if released == nil {
+			continue
+		}
+		flushConntrackForIP(released.IP)
+		logging.Infof("Reclaimed leaked IP %s (pod %s/%s, container %s) from configmap %s",
+			released.IP, released.PodNamespace, released.PodName, released.ContainerID, store.ConfigMapName())
+		reclaimed++
+	}
+
+	monitoring.RecordReclaimedIPs(reclaimed)
+	return reclaimed, nil
+}
+
+// livePodIPs 返回节点上运行中 Pod 的 IP，hostNetwork 和已结束的 Pod 不占用 Pod CIDR 地址
+func (s *PodMonitoringService) livePodIPs(nodeName string) (map[string]bool, error) {
+	pods, err := s.preparer.GetK8sClient().Pods().GetByNode(nodeName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods on node %s: %v", nodeName, err)
+	}
+
+	liveIPs := make(map[string]bool)
+	for _, pod := range pods {
+		if pod.Spec.HostNetwork {
+			continue
+		}
+		if pod.Status.Phase == coreV1.PodSucceeded || pod.Status.Phase == coreV1.PodFailed {
+			continue
+		}
+		for _, podIP := range pod.Status.PodIPs {
+			liveIPs[podIP.IP] = true
+		}
+		if pod.Status.PodIP != "" {
+			liveIPs[pod.Status.PodIP] = true
+		}
+	}
+	return liveIPs, nil
+}
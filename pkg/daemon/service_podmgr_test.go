@@ -0,0 +1,72 @@
+package daemon
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/binrclab/headcni/cmd/daemon/config"
+	"github.com/binrclab/headcni/pkg/ipam"
+	"github.com/binrclab/headcni/pkg/k8s"
+	coreV1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestReconcileLeakedKubeBackedIPs(t *testing.T) {
+	t.Setenv("NODE_NAME", "node-1")
+	t.Setenv("POD_NAMESPACE", "headcni")
+
+	node := &coreV1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}, Spec: coreV1.NodeSpec{PodCIDR: "10.42.1.0/24"}}
+	live := &coreV1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-0", Namespace: "default"},
+		Spec:       coreV1.PodSpec{NodeName: "node-1"},
+		Status:     coreV1.PodStatus{Phase: coreV1.PodRunning, PodIP: "10.42.1.10"},
+	}
+	clientset := fake.NewSimpleClientset(node, live)
+
+	cfg, err := config.DefaultConfig()
+	if err != nil {
+		t.Fatalf("DefaultConfig failed: %v", err)
+	}
+	cfg.IPAM.Type = ipam.TypeKubeBacked
+	p := &Preparer{config: cfg, k8sClient: k8s.NewClientForClientset(clientset, &k8s.ClientConfig{
+		BasePermissions: &k8s.PermissionStatus{CanListNodes: true, CanGetNodes: true, CanListPods: true, CanListConfigMaps: true, CanGetConfigMaps: true},
+	})}
+
+	store, err := p.kubeStore()
+	if err != nil {
+		t.Fatalf("kubeStore failed: %v", err)
+	}
+	ctx := context.Background()
+	for _, alloc := range []struct{ pod, container, ip string }{
+		{"web-0", "live", "10.42.1.10"},
+		{"web-1", "leaked", "10.42.1.11"},
+	} {
+		if _, err := store.Allocate(ctx, "default", alloc.pod, alloc.container, net.ParseIP(alloc.ip)); err != nil {
+			t.Fatalf("Allocate %s failed: %v", alloc.ip, err)
+		}
+	}
+
+	s := NewPodMonitoringService(p, nil)
+	// 宽限期内的分配不回收
+	if reclaimed, err := s.reconcileLeakedIPs("node-1", "", time.Hour); err != nil || reclaimed != 0 {
+		t.Fatalf("expected nothing reclaimed within the grace period, got %d, %v", reclaimed, err)
+	}
+
+	reclaimed, err := s.reconcileLeakedIPs("node-1", "", 0)
+	if err != nil {
+		t.Fatalf("reconcileLeakedIPs failed: %v", err)
+	}
+	if reclaimed != 1 {
+		t.Fatalf("expected 1 reclaimed IP, got %d", reclaimed)
+	}
+	allocations, err := store.List(ctx)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(allocations) != 1 || allocations[0].ContainerID != "live" {
+		t.Errorf("expected only the live pod's allocation to be kept, got %+v", allocations)
+	}
+}
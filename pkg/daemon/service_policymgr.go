@@ -0,0 +1,371 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/binrclab/headcni/pkg/constants"
+	"github.com/binrclab/headcni/pkg/logging"
+	"github.com/binrclab/headcni/pkg/policy"
+	coreV1 "k8s.io/api/core/v1"
+	"tailscale.com/ipn/ipnstate"
+)
+
+const (
+	defaultPolicyReconcileInterval = 30 * time.Second
+	policySyncTimeout              = 30 * time.Second
+)
+
+// policySyncCh CNI 请求处理完成后触发一次策略同步
+var policySyncCh = make(chan struct{}, 1)
+
+// requestPolicySync 请求立即同步网络策略，已有待处理请求时忽略
+func requestPolicySync() {
+	select {
+	case policySyncCh <- struct{}{}:
+	default:
+	}
+}
+
+// NetworkPolicyService Pod 网络策略服务
+// network.enableNetworkPolicy 和 network.policy.enforce 都开启时在宿主机上维护 iptables 隔离规则，并周期性校验，规则被清空时重新下发
+type NetworkPolicyService struct {
+	preparer *Preparer
+	enforcer *policy.Enforcer
+	podCIDR  *net.IPNet
+	running  bool
+	cancel   context.CancelFunc
+	done     chan struct{}
+	mu       sync.RWMutex
+}
+
+// NewNetworkPolicyService 创建新的网络策略服务
+func NewNetworkPolicyService(preparer *Preparer) *NetworkPolicyService {
+	return &NetworkPolicyService{preparer: preparer}
+}
+
+func (s *NetworkPolicyService) Name() string { return constants.ServiceNameNetworkPolicy }
+
+func (s *NetworkPolicyService) Start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.running {
+		return nil
+	}
+
+	// 未开启时服务同样处于运行状态但不下发规则，重载配置开启后由 Reload 重新启动
+	healthMgr := GetGlobalHealthManager()
+	cfg := s.preparer.GetConfig()
+	if cfg == nil || !cfg.Network.EnableNetworkPolicy {
+		logging.Infof("Network policy is disabled, not enforcing pod isolation")
+		s.running = true
+		healthMgr.UpdateServiceStatus(s.Name(), true, nil)
+		return nil
+	}
+	if !cfg.Network.Policy.Enforce {
+		logging.Infof("Network policy enforcement is off (network.policy.enforce), not enforcing pod isolation")
+		s.running = true
+		healthMgr.UpdateServiceStatus(s.Name(), true, nil)
+		return nil
+	}
+
+	if s.enforcer == nil {
+		enforcer, err := policy.NewEnforcer()
+		if err != nil {
+			healthMgr.UpdateServiceStatus(s.Name(), false, err)
+			return err
+		}
+		s.enforcer = enforcer
+	}
+
+	interval := defaultPolicyReconcileInterval
+	if cfg.Network.Policy.ReconcileInterval != "" {
+		parsed, err := time.ParseDuration(cfg.Network.Policy.ReconcileInterval)
+		if err != nil || parsed <= 0 {
+			logging.Warnf("Invalid network.policy.reconcileInterval %q, using %v", cfg.Network.Policy.ReconcileInterval, interval)
+		} else {
+			interval = parsed
+		}
+	}
+
+	loopCtx, cancel := context.WithCancel(ctx)
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	s.running = true
+
+	go s.reconcileLoop(loopCtx, interval, s.done)
+
+	healthMgr.UpdateServiceStatus(s.Name(), true, nil)
+	logging.Infof("Network policy service started, reconcile interval: %v", interval)
+	return nil
+}
+
+func (s *NetworkPolicyService) Reload(ctx context.Context) error {
+	newConfig := s.preparer.GetConfig()
+	oldConfig := s.preparer.GetOldConfig()
+	if newConfig == nil {
+		return fmt.Errorf("failed to get new configuration")
+	}
+
+	if oldConfig != nil && newConfig.Network.EnableNetworkPolicy == oldConfig.Network.EnableNetworkPolicy &&
+		newConfig.Network.Policy.Enforce == oldConfig.Network.Policy.Enforce &&
+		newConfig.Network.Policy.ReconcileInterval == oldConfig.Network.Policy.ReconcileInterval {
+		// 其余策略字段在下次同步时生效
+		requestPolicySync()
+		return nil
+	}
+
+	logging.Infof("Network policy configuration changed, restarting network policy service")
+	if err := s.Stop(ctx); err != nil {
+		logging.Errorf("Failed to stop network policy service during reload: %v", err)
+	}
+	return s.Start(ctx)
+}
+
+func (s *NetworkPolicyService) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return nil
+	}
+	cancel, done := s.cancel, s.done
+	s.cancel = nil
+	s.running = false
+	s.mu.Unlock()
+
+	// 同步过程需要持有锁，等待循环退出前必须先释放
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+
+	// 关闭策略后删除规则；守护进程退出时同样删除，避免残留规则在守护进程不在时阻断流量
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.enforcer != nil {
+		if err := s.enforcer.Cleanup(s.podCIDR); err != nil {
+			logging.Warnf("Failed to cleanup network policy rules: %v", err)
+		}
+	}
+
+	logging.Infof("Network policy service stopped")
+	return nil
+}
+
+func (s *NetworkPolicyService) IsRunning() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.running
+}
+
+// reconcileLoop 周期性或在 CNI 请求后同步策略
+func (s *NetworkPolicyService) reconcileLoop(ctx context.Context, interval time.Duration, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	s.syncOnce(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.syncOnce(ctx)
+		case <-policySyncCh:
+			s.syncOnce(ctx)
+		}
+	}
+}
+
+// syncOnce 执行一次同步并上报健康状态
+func (s *NetworkPolicyService) syncOnce(ctx context.Context) {
+	syncCtx, cancel := context.WithTimeout(ctx, policySyncTimeout)
+	defer cancel()
+
+	err := s.sync(syncCtx)
+	if err != nil {
+		logging.Errorf("Failed to sync network policy: %v", err)
+	}
+	GetGlobalHealthManager().UpdateServiceStatus(s.Name(), true, err)
+}
+
+// sync 收集期望状态并下发到宿主机
+func (s *NetworkPolicyService) sync(ctx context.Context) error {
+	state, err := s.buildState(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	if s.podCIDR != nil && s.podCIDR.String() != state.PodCIDR.String() {
+		// Pod CIDR 变化后旧的跳转规则不再匹配，先清理
+		if err := s.enforcer.Cleanup(s.podCIDR); err != nil {
+			logging.Warnf("Failed to cleanup rules for old pod CIDR %s: %v", s.podCIDR, err)
+		}
+	}
+	s.podCIDR = state.PodCIDR
+	s.mu.Unlock()
+
+	return s.enforcer.Apply(state)
+}
+
+// buildState 根据配置、Pod 列表和 tailnet 节点生成期望状态
+func (s *NetworkPolicyService) buildState(ctx context.Context) (*policy.State, error) {
+	cfg := s.preparer.GetConfig()
+	k8sClient := s.preparer.GetK8sClient()
+	if k8sClient == nil {
+		return nil, fmt.Errorf("kubernetes client not available")
+	}
+
+	permissions := k8sClient.GetPermissions()
+	if permissions != nil && !permissions.CanListPods {
+		return nil, fmt.Errorf("no permission to list pods, network policy requires list pods")
+	}
+
+	nodeName, err := k8sClient.GetCurrentNodeName()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current node name: %v", err)
+	}
+	podCIDRStr, err := s.preparer.GetNodePodCIDR(nodeName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pod CIDR for node %s: %v", nodeName, err)
+	}
+	_, podCIDR, err := net.ParseCIDR(podCIDRStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pod CIDR %q: %v", podCIDRStr, err)
+	}
+
+	pods, err := k8sClient.Pods().List(ctx, "", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %v", err)
+	}
+
+	state := &policy.State{
+		PodCIDR:      podCIDR,
+		ClusterCIDRs: parseCIDRs(cfg.Network.PodCIDR.Base),
+		ServiceCIDRs: parseCIDRs(cfg.Network.ServiceCIDR),
+		Egress: policy.EgressPolicy{
+			AllowHostAccess:     cfg.Network.Policy.HostAccessAllowed(),
+			AllowServiceAccess:  cfg.Network.Policy.ServiceAccessAllowed(),
+			AllowExternalAccess: cfg.Network.Policy.ExternalAccessAllowed(),
+			EgressAllowed:       cfg.Network.Policy.EgressAllowed,
+		},
+		NamespaceIPs:   make(map[string][]net.IP),
+		OpenNamespaces: make(map[string]bool),
+	}
+
+	openNamespaces := cfg.Network.Policy.OpenNamespaces
+	if len(openNamespaces) == 0 {
+		openNamespaces = []string{"kube-system"}
+	}
+	for _, ns := range openNamespaces {
+		state.OpenNamespaces[ns] = true
+	}
+
+	for _, pod := range pods {
+		if pod.Spec.HostNetwork || pod.Status.PodIP == "" ||
+			pod.Status.Phase == coreV1.PodSucceeded || pod.Status.Phase == coreV1.PodFailed {
+			continue
+		}
+		ip := net.ParseIP(pod.Status.PodIP)
+		if ip == nil {
+			continue
+		}
+
+		state.NamespaceIPs[pod.Namespace] = append(state.NamespaceIPs[pod.Namespace], ip)
+		if pod.Spec.NodeName == nodeName && podCIDR.Contains(ip) {
+			state.LocalPods = append(state.LocalPods, policy.PodEndpoint{
+				Namespace: pod.Namespace,
+				Name:      pod.Name,
+				IP:        ip,
+			})
+		}
+	}
+
+	if len(cfg.Network.Policy.AllowedTags) > 0 {
+		state.AllowedPeerIPs = s.allowedPeerIPs(ctx, cfg.Network.Policy.AllowedTags)
+	}
+
+	return state, nil
+}
+
+// allowedPeerIPs 返回带有任一允许 tag 的 tailnet 节点地址
+// Tailscale 不可用时返回空列表，即不放行任何 tailnet 节点
+func (s *NetworkPolicyService) allowedPeerIPs(ctx context.Context, allowedTags []string) []net.IP {
+	tsClient := s.preparer.GetTailscaleClient()
+	if tsClient == nil {
+		return nil
+	}
+
+	status, err := tsClient.GetStatus(ctx)
+	if err != nil {
+		logging.Warnf("Failed to get Tailscale status for network policy: %v", err)
+		return nil
+	}
+
+	peers := make([]*ipnstate.PeerStatus, 0, len(status.Peer))
+	for _, peer := range status.Peer {
+		peers = append(peers, peer)
+	}
+	return taggedPeerIPs(peers, allowedTags, func(ip string) ([]string, error) {
+		return tsClient.ResolvePeerTags(ctx, ip)
+	})
+}
+
+// taggedPeerIPs 返回带有任一允许 tag 的节点的 IPv4 地址
+// 策略链只写入 iptables，IPv6 地址无法加入规则，跳过
+func taggedPeerIPs(peers []*ipnstate.PeerStatus, allowedTags []string, resolveTags func(ip string) ([]string, error)) []net.IP {
+	allowed := make(map[string]bool, len(allowedTags))
+	for _, tag := range allowedTags {
+		allowed[tag] = true
+	}
+
+	var ips []net.IP
+	for _, peer := range peers {
+		var peerIPs []net.IP
+		for _, addr := range peer.TailscaleIPs {
+			if addr.Is4() {
+				peerIPs = append(peerIPs, net.IP(addr.AsSlice()))
+			}
+		}
+		if len(peerIPs) == 0 {
+			continue
+		}
+
+		tags, err := resolveTags(peerIPs[0].String())
+		if err != nil {
+			logging.Debugf("Failed to resolve tags for peer %s: %v", peer.HostName, err)
+			continue
+		}
+
+		for _, tag := range tags {
+			if allowed[tag] {
+				ips = append(ips, peerIPs...)
+				break
+			}
+		}
+	}
+
+	return ips
+}
+
+// parseCIDRs 解析逗号分隔的 CIDR 列表，忽略无法解析的条目
+func parseCIDRs(value string) []*net.IPNet {
+	var nets []*net.IPNet
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if _, ipNet, err := net.ParseCIDR(part); err == nil {
+			nets = append(nets, ipNet)
+		}
+	}
+	return nets
+}
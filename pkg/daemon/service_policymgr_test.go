@@ -0,0 +1,47 @@
+package daemon
+
+import (
+	"fmt"
+	"net/netip"
+	"testing"
+
+	"tailscale.com/ipn/ipnstate"
+)
+
+func TestTaggedPeerIPsSkipsIPv6(t *testing.T) {
+	peers := []*ipnstate.PeerStatus{
+		{
+			HostName:     "router-1",
+			TailscaleIPs: []netip.Addr{netip.MustParseAddr("fd7a:115c:a1e0::1"), netip.MustParseAddr("100.64.0.1")},
+		},
+		{
+			HostName:     "laptop",
+			TailscaleIPs: []netip.Addr{netip.MustParseAddr("100.64.0.2"), netip.MustParseAddr("fd7a:115c:a1e0::2")},
+		},
+		{
+			HostName:     "ipv6-only",
+			TailscaleIPs: []netip.Addr{netip.MustParseAddr("fd7a:115c:a1e0::3")},
+		},
+	}
+	tags := map[string][]string{
+		"100.64.0.1": {"tag:headcni-router"},
+		"100.64.0.2": {"tag:user"},
+	}
+	var resolved []string
+	resolve := func(ip string) ([]string, error) {
+		resolved = append(resolved, ip)
+		if peerTags, ok := tags[ip]; ok {
+			return peerTags, nil
+		}
+		return nil, fmt.Errorf("unknown peer %s", ip)
+	}
+
+	ips := taggedPeerIPs(peers, []string{"tag:headcni-router"}, resolve)
+	if len(ips) != 1 || ips[0].String() != "100.64.0.1" {
+		t.Fatalf("expected only the IPv4 address of the tagged dual-stack peer, got %v", ips)
+	}
+	// tag 通过 IPv4 地址解析，只有 IPv6 地址的节点不查询
+	if len(resolved) != 2 || resolved[0] != "100.64.0.1" || resolved[1] != "100.64.0.2" {
+		t.Errorf("expected tags to be resolved by IPv4 address, got %v", resolved)
+	}
+}
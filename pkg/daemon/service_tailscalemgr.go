@@ -2,15 +2,19 @@ package daemon
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"net/netip"
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/binrclab/headcni/cmd/daemon/config"
@@ -18,6 +22,7 @@ import (
 	"github.com/binrclab/headcni/pkg/constants"
 	"github.com/binrclab/headcni/pkg/headscale"
 	"github.com/binrclab/headcni/pkg/logging"
+	"github.com/binrclab/headcni/pkg/monitoring"
 	"github.com/binrclab/headcni/pkg/utils"
 	"github.com/vishvananda/netlink"
 	coreV1 "k8s.io/api/core/v1"
@@ -45,13 +50,26 @@ type TailscaleEnv struct {
 	tailscaleNic string
 }
 
+// defaultCallTimeout 未配置 tailscale.callTimeout 时单次调用的超时时间
+const defaultCallTimeout = 30 * time.Second
+
+// 未配置时的周期性检查间隔
+const (
+	defaultHealthCheckInterval = 30 * time.Second
+	defaultKeepAliveInterval   = 15 * time.Second
+	defaultRuleSyncInterval    = 30 * time.Second
+)
+
 // TailscaleService 管理 Tailscale 服务进程，实现 Service 接口
 type TailscaleService struct {
-	preparer           *Preparer
+	preparer    *Preparer
+	hostname    string
+	serviceName string
+
+	// authKeyMu 保护预授权密钥，登录流程、后台刷新和身份轮换会并发读写
+	authKeyMu          sync.RWMutex
 	authKey            string
 	authKeyExpiredTime time.Time
-	hostname           string
-	serviceName        string
 
 	// 状态管理
 	tailscaleEnv *TailscaleEnv
@@ -65,8 +83,9 @@ type TailscaleService struct {
 	retryInterval time.Duration
 	retryCount    int
 
-	// 健康检查
-	healthCheckInterval time.Duration
+	// 检查间隔变化通知，配置重载时关闭并替换
+	intervalsMu      sync.Mutex
+	intervalsChanged chan struct{}
 
 	// 控制
 	ctx       context.Context
@@ -74,6 +93,35 @@ type TailscaleService struct {
 	wg        sync.WaitGroup
 	mu        sync.Mutex // 保护 isRunning
 	isRunning bool
+
+	// stopStateWatch 停止后端状态事件订阅
+	stopStateWatch context.CancelFunc
+
+	// rotationMu 保证同一时间只有一次身份轮换
+	rotationMu sync.Mutex
+
+	// restartMu 串行化保活检查和看门狗对 tailscaled 的启动和重启，避免同时启动两个 tailscaled
+	restartMu sync.Mutex
+
+	// splitBrainBackoff 脑裂修复重新认证失败后的退避
+	splitBrainBackoff reauthBackoff
+
+	// peerRoutes 缓存通告路由的节点标签，节点或路由变化时重新查询
+	peerRoutes peerRouteCache
+
+	// managedRoutes HeadCNI 通告过的路由，收敛和撤销时只操作其中的前缀
+	managedRoutes *managedRouteRegistry
+
+	// routeConflictsMu 保护 routeConflicts
+	routeConflictsMu sync.Mutex
+	// routeConflicts 按前缀记录与其他节点冲突的 Pod CIDR 路由及冲突描述
+	routeConflicts map[string]string
+
+	// readOnly 只读模式，路由收敛只记录将要执行的操作
+	readOnly atomic.Bool
+
+	// startupDelayed 是否已等待过启动延迟，重载触发的重启不再等待
+	startupDelayed bool
 }
 
 // NewTailscaleService 创建新的 Tailscale 服务
@@ -82,14 +130,14 @@ func NewTailscaleService(
 ) *TailscaleService {
 	ctx, cancel := context.WithCancel(context.Background())
 	return &TailscaleService{
-		preparer:            preparer,
-		serviceName:         constants.DefaultTailscaleServiceName,
-		state:               TailscaleServiceStateInitializing,
-		maxRetries:          5,
-		retryInterval:       30 * time.Second,
-		healthCheckInterval: 30 * time.Second,
-		ctx:                 ctx,
-		cancel:              cancel,
+		preparer:      preparer,
+		serviceName:   constants.DefaultTailscaleServiceName,
+		state:         TailscaleServiceStateInitializing,
+		maxRetries:    5,
+		retryInterval: 30 * time.Second,
+		ctx:           ctx,
+		cancel:        cancel,
+		managedRoutes: newManagedRouteRegistry(constants.DefaultManagedRoutesFile),
 	}
 }
 
@@ -122,6 +170,80 @@ func (tsm *TailscaleService) updateHealthStatusWithLog(healthy bool, err error,
 	tsm.updateHealthStatus(healthy, err)
 }
 
+// callTimeout 返回单次 Headscale/Tailscale/K8s 调用的超时时间（tailscale.callTimeout）
+func (tsm *TailscaleService) callTimeout() time.Duration {
+	if cfg := tsm.preparer.GetConfig(); cfg != nil && cfg.Tailscale.CallTimeout != "" {
+		if timeout, err := time.ParseDuration(cfg.Tailscale.CallTimeout); err == nil && timeout > 0 {
+			return timeout
+		}
+	}
+	return defaultCallTimeout
+}
+
+// healthCheckInterval 返回健康检查间隔（tailscale.healthCheckInterval）
+func (tsm *TailscaleService) healthCheckInterval() time.Duration {
+	return configuredInterval(tsm.preparer.GetConfig(), func(ts config.TailscaleConfig) string { return ts.HealthCheckInterval }, defaultHealthCheckInterval)
+}
+
+// keepAliveInterval 返回 tailscaled 保活检查间隔（tailscale.keepAliveInterval）
+func (tsm *TailscaleService) keepAliveInterval() time.Duration {
+	return configuredInterval(tsm.preparer.GetConfig(), func(ts config.TailscaleConfig) string { return ts.KeepAliveInterval }, defaultKeepAliveInterval)
+}
+
+// ruleSyncInterval 返回 IP 规则维护间隔（tailscale.ruleSyncInterval）
+func (tsm *TailscaleService) ruleSyncInterval() time.Duration {
+	return configuredInterval(tsm.preparer.GetConfig(), func(ts config.TailscaleConfig) string { return ts.RuleSyncInterval }, defaultRuleSyncInterval)
+}
+
+// configuredInterval 解析配置中的检查间隔，未配置或无效时使用默认值，低于 config.MinCheckInterval 时取下限
+func configuredInterval(cfg *config.Config, field func(config.TailscaleConfig) string, fallback time.Duration) time.Duration {
+	interval := fallback
+	if cfg != nil {
+		if value, err := time.ParseDuration(field(cfg.Tailscale)); err == nil && value > 0 {
+			interval = value
+		}
+	}
+	if interval < config.MinCheckInterval {
+		interval = config.MinCheckInterval
+	}
+	return interval
+}
+
+// intervalWatch 返回检查间隔可能变化时被关闭的通道
+func (tsm *TailscaleService) intervalWatch() <-chan struct{} {
+	tsm.intervalsMu.Lock()
+	defer tsm.intervalsMu.Unlock()
+	if tsm.intervalsChanged == nil {
+		tsm.intervalsChanged = make(chan struct{})
+	}
+	return tsm.intervalsChanged
+}
+
+// notifyIntervalsChanged 通知各检查协程重新读取间隔
+func (tsm *TailscaleService) notifyIntervalsChanged() {
+	tsm.intervalsMu.Lock()
+	defer tsm.intervalsMu.Unlock()
+	if tsm.intervalsChanged != nil {
+		close(tsm.intervalsChanged)
+	}
+	tsm.intervalsChanged = make(chan struct{})
+}
+
+// resetTicker 间隔变化时按新间隔重置 ticker，返回生效的间隔
+func resetTicker(ticker *time.Ticker, current, next time.Duration, name string) time.Duration {
+	if next == current {
+		return current
+	}
+	ticker.Reset(withJitter(next))
+	logging.Infof("%s interval changed: %v -> %v", name, current, next)
+	return next
+}
+
+// callContext 返回单次调用使用的上下文，超时或服务停止时取消
+func (tsm *TailscaleService) callContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(tsm.ctx, tsm.callTimeout())
+}
+
 // getTailscaleEnv 获取 Tailscale 环境配置
 func (tsm *TailscaleService) getTailscaleEnv() *TailscaleEnv {
 	return tsm.tailscaleEnv
@@ -132,8 +254,25 @@ func (tsm *TailscaleService) setTailscaleEnv(tailscaleEnv *TailscaleEnv) {
 	tsm.tailscaleEnv = tailscaleEnv
 }
 
+// setAuthKey 保存从 Headscale 获取的预授权密钥
+func (tsm *TailscaleService) setAuthKey(key string, expiration time.Time) {
+	tsm.authKeyMu.Lock()
+	defer tsm.authKeyMu.Unlock()
+	tsm.authKey = key
+	tsm.authKeyExpiredTime = expiration
+}
+
+// currentAuthKey 返回当前的预授权密钥及其过期时间
+func (tsm *TailscaleService) currentAuthKey() (string, time.Time) {
+	tsm.authKeyMu.RLock()
+	defer tsm.authKeyMu.RUnlock()
+	return tsm.authKey, tsm.authKeyExpiredTime
+}
+
 // cleanupExpiredAuthKey 清理过期的认证密钥
 func (tsm *TailscaleService) cleanupExpiredAuthKey() {
+	tsm.authKeyMu.Lock()
+	defer tsm.authKeyMu.Unlock()
 	if tsm.authKey != "" && !tsm.authKeyExpiredTime.IsZero() && tsm.authKeyExpiredTime.Before(time.Now()) {
 		logging.Infof("清理过期的认证密钥 (过期时间: %v)", tsm.authKeyExpiredTime)
 		tsm.authKey = ""
@@ -143,39 +282,50 @@ func (tsm *TailscaleService) cleanupExpiredAuthKey() {
 
 // validateAuthKey 验证认证密钥是否有效
 func (tsm *TailscaleService) validateAuthKey() bool {
-	if tsm.authKey == "" {
+	return authKeyValid(tsm.currentAuthKey())
+}
+
+// authKeyValid 密钥非空且设置了尚未到达的过期时间
+func authKeyValid(key string, expiration time.Time) bool {
+	if key == "" {
 		return false
 	}
 
-	if tsm.authKeyExpiredTime.IsZero() {
+	if expiration.IsZero() {
 		return false
 	}
 
-	return tsm.authKeyExpiredTime.After(time.Now())
+	return expiration.After(time.Now())
 }
 
+// hostSocketPath host 模式下主机 tailscaled 的 socket，测试中替换为模拟的 tailscaled
+var hostSocketPath = constants.DefaultTailscaleHostSocketPath
+
+// sysClassNet 列出网络接口的 sysfs 目录，测试中替换为临时目录
+var sysClassNet = "/sys/class/net"
+
 // initTailscaleEnv 初始化 Tailscale 环境配置
 func (tsm *TailscaleService) initTailscaleEnv(node *coreV1.Node) *TailscaleEnv {
 	isHost := tsm.preparer.GetConfig().Tailscale.Mode == "host"
 	if isHost {
 		// 验证 host 模式的路径
-		configDir := filepath.Dir(constants.DefaultTailscaleHostSocketPath)
+		configDir := filepath.Dir(hostSocketPath)
 		if configDir == "" || configDir == "." {
-			logging.Warnf("Invalid config directory path derived from socket path: %s", constants.DefaultTailscaleHostSocketPath)
+			logging.Warnf("Invalid config directory path derived from socket path: %s", hostSocketPath)
 			configDir = "/var/run/headcni" // 使用默认路径作为后备
 		}
 
 		tailscaleEnv := &TailscaleEnv{
 			isDaemon:     isHost,
 			configDir:    configDir,
-			socketPath:   constants.DefaultTailscaleHostSocketPath,
+			socketPath:   hostSocketPath,
 			statePath:    "",
 			pidPath:      "",
 			hostNamePath: "",
 			hostName:     node.Name,
 			tailscaleNic: "tailscale0",
 		}
-		logging.Infof("Initialized host mode environment - ConfigDir: %s, SocketPath: %s", configDir, constants.DefaultTailscaleHostSocketPath)
+		logging.Infof("Initialized host mode environment - ConfigDir: %s, SocketPath: %s", configDir, hostSocketPath)
 		return tailscaleEnv
 	} else {
 		// 验证 daemon 模式的路径
@@ -216,11 +366,11 @@ func (tsm *TailscaleService) readHostNameInDomain(path string) string {
 		return tsm.preparer.GetConfig().Tailscale.Hostname.Prefix + "-" + utils.RandomBase32Low(5)
 	}
 
-	// 验证主机名格式的辅助函数
+	// 验证主机名格式的辅助函数：必须是 "<prefix>-<随机后缀>"，前缀变更后 node-12-xxxxx 不会被当作 node-1 的主机名
 	isValidHostname := func(hostname string) bool {
-		return strings.HasPrefix(hostname, tsm.preparer.GetConfig().Tailscale.Hostname.Prefix) &&
-			len(hostname) <= 63 &&
-			regexp.MustCompile("^[a-z0-9-]+$").MatchString(hostname)
+		suffix, ok := strings.CutPrefix(hostname, tsm.preparer.GetConfig().Tailscale.Hostname.Prefix+"-")
+		return ok && len(hostname) <= 63 &&
+			regexp.MustCompile("^[a-z0-9]+$").MatchString(suffix)
 	}
 
 	// 尝试读取现有主机名
@@ -232,11 +382,65 @@ func (tsm *TailscaleService) readHostNameInDomain(path string) string {
 	}
 
 	// 生成新主机名并写入文件
-	hostname := generateHostname()
+	hostname := tsm.generateUniqueHostname(generateHostname)
 	os.WriteFile(path, []byte(hostname), 0644)
 	return hostname
 }
 
+// 主机名生成重试次数，超过后改用更长的随机后缀
+const (
+	hostnameGenerateAttempts = 5
+	hostnameFallbackLength   = 10
+)
+
+// generateUniqueHostname 生成 tailnet 中未被使用的主机名
+// Headscale 不可达时无法校验，直接使用生成的主机名
+func (tsm *TailscaleService) generateUniqueHostname(generate func() string) string {
+	for attempt := 1; attempt <= hostnameGenerateAttempts; attempt++ {
+		hostname := generate()
+		taken, err := tsm.hostnameTaken(hostname)
+		if err != nil {
+			logging.Warnf("Failed to verify hostname %s against Headscale, using it anyway: %v", hostname, err)
+			return hostname
+		}
+		if !taken {
+			return hostname
+		}
+		logging.Warnf("Hostname %s already exists in Headscale (attempt %d/%d), regenerating",
+			hostname, attempt, hostnameGenerateAttempts)
+	}
+
+	// 多次冲突后追加更多随机字符，碰撞概率可忽略
+	prefix := tsm.preparer.GetConfig().Tailscale.Hostname.Prefix
+	// 主机名不超过 63 个字符，后缀至少保留一个字符；配置校验限制了前缀长度
+	hostname := prefix + "-" + utils.RandomBase32Low(max(1, min(hostnameFallbackLength, 63-len(prefix)-1)))
+	logging.Warnf("Falling back to hostname with extra entropy: %s", hostname)
+	return hostname
+}
+
+// hostnameTaken 检查 Headscale 中是否已有同名节点
+func (tsm *TailscaleService) hostnameTaken(hostname string) (bool, error) {
+	headscaleClient := tsm.preparer.GetHeadscaleClient()
+	if headscaleClient == nil {
+		return false, fmt.Errorf("headscale client not available")
+	}
+
+	ctx, cancel := tsm.callContext()
+	defer cancel()
+
+	nodes, err := headscaleClient.FindNodesByHostnamePrefix(ctx, hostname)
+	if err != nil {
+		return false, err
+	}
+
+	for _, node := range nodes {
+		if node.Name == hostname || node.GivenName == hostname {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 // Start 启动服务 (Service 接口)
 func (tsm *TailscaleService) Start(ctx context.Context) error {
 	tsm.mu.Lock()
@@ -246,6 +450,12 @@ func (tsm *TailscaleService) Start(ctx context.Context) error {
 		return nil
 	}
 
+	// Stop 会取消上下文，重新启动时需要新的上下文
+	if tsm.ctx == nil || tsm.ctx.Err() != nil {
+		tsm.ctx, tsm.cancel = context.WithCancel(context.Background())
+	}
+	tsm.SetDryRun(tsm.preparer.GetConfig().Daemon.DryRun)
+
 	// 获取当前节点信息
 	nodeName, err := tsm.preparer.GetK8sClient().GetCurrentNodeName()
 	if err != nil {
@@ -253,7 +463,17 @@ func (tsm *TailscaleService) Start(ctx context.Context) error {
 		return tsm.handleErrorWithLog(err, "Failed to get current node name: %w", err)
 	}
 
-	node, err := tsm.preparer.GetK8sClient().Nodes().Get(context.Background(), nodeName)
+	// 整个集群同时重启时错开各节点首次访问 Headscale 的时间
+	if !tsm.startupDelayed {
+		if err := waitStartupDelay(ctx, tsm.preparer.GetConfig(), nodeName, tsm.Name()); err != nil {
+			return fmt.Errorf("startup delay interrupted: %v", err)
+		}
+		tsm.startupDelayed = true
+	}
+
+	callCtx, cancel := tsm.callContext()
+	node, err := tsm.preparer.GetK8sClient().Nodes().Get(callCtx, nodeName)
+	cancel()
 	if err != nil {
 		tsm.updateHealthStatus(false, err)
 		return tsm.handleErrorWithLog(err, "Failed to get current node: %w", err)
@@ -265,19 +485,28 @@ func (tsm *TailscaleService) Start(ctx context.Context) error {
 	// Headscale.AuthKey 是用于调用 Headscale API 的密钥，不是 Tailscale 登录密钥
 	// 这里不需要设置 tsm.authKey，它会在需要时从 Headscale 获取
 
+	// 记录后端状态变化，启动阶段的状态变化同样需要记录
+	if tsm.stopStateWatch != nil {
+		tsm.stopStateWatch()
+	}
+	var watchCtx context.Context
+	watchCtx, tsm.stopStateWatch = context.WithCancel(tsm.ctx)
+	tsm.watchStateEvents(watchCtx)
+
 	// 根据配置模式选择启动方式
 	mode := tsm.preparer.GetConfig().Tailscale.Mode
 	var startErr error
 	switch mode {
 	case "host":
 		startErr = tsm.startHostModeWithRoutes(node)
-	case "daemon":
+	case "daemon", "router":
 		startErr = tsm.startDaemonModeWithRoutes(node)
 	default:
 		startErr = fmt.Errorf("unknown tailscale mode: %s", mode)
 	}
 
 	if startErr != nil {
+		tsm.stopStateWatch()
 		tsm.updateHealthStatus(false, startErr)
 		return fmt.Errorf("failed to start %s mode: %v", mode, startErr)
 	}
@@ -296,33 +525,46 @@ func (tsm *TailscaleService) Start(ctx context.Context) error {
 
 func (tsm *TailscaleService) Reload(ctx context.Context) error {
 	tsm.mu.Lock()
-	defer tsm.mu.Unlock()
 
 	logging.Infof("Reloading Tailscale service")
 
 	if !tsm.isRunning {
+		tsm.mu.Unlock()
 		return fmt.Errorf("service is not running")
 	}
 
-	// 获取新的配置
-	newConfig := tsm.preparer.GetConfig()
-	if newConfig != nil && newConfig.ConfigPath != "" {
-		if cfg, err := config.LoadConfig(newConfig.ConfigPath); err == nil {
-			newConfig = cfg
-		} else {
-			logging.Warnf("Failed to reload config, using existing config: %v", err)
+	change, reasons := tsm.classifyConfigChange()
+	switch change {
+	case configChangeNone:
+		tsm.mu.Unlock()
+		// tailscaled 重启后固定的 DERP 区域会丢失
+		if regionID := tsm.preparer.GetConfig().Tailscale.PreferredDERP; regionID != 0 {
+			tsm.applyPreferredDERP(regionID)
 		}
-	}
-
-	// 使用通用配置检查函数
-	if !tsm.checkConfigChanged() {
+		// 接口 MTU 可能已变化
+		tsm.syncPodMTU()
+		// 其他模块的配置变更同样改变配置哈希
+		tsm.reportDaemonInfo()
 		logging.Infof("Tailscale configuration unchanged, no reload needed")
 		return nil
+	case configChangeLive:
+		logging.Infof("Tailscale configuration changed (%s), applying in place", strings.Join(reasons, ", "))
+		err := tsm.applyLiveConfigChanges()
+		tsm.mu.Unlock()
+		if err == nil {
+			tsm.notifyIntervalsChanged()
+			tsm.syncPodMTU()
+			tsm.reportDaemonInfo()
+			logging.Infof("Tailscale service reloaded in place")
+			return nil
+		}
+		logging.Warnf("Failed to apply config changes in place, falling back to restart: %v", err)
+	default:
+		tsm.mu.Unlock()
+		logging.Infof("Tailscale configuration changed (%s), performing restart", strings.Join(reasons, ", "))
 	}
 
-	logging.Infof("Tailscale configuration changed, performing reload")
-
-	// 停止当前服务
+	// 停止当前服务（Stop/Start 自行加锁）
 	if err := tsm.Stop(ctx); err != nil {
 		logging.Errorf("Failed to stop service during reload: %v", err)
 	}
@@ -361,7 +603,9 @@ func (tsm *TailscaleService) Stop(ctx context.Context) error {
 
 	// 停止 Tailscale 服务
 	var err error
-	if stopErr := tsm.preparer.GetTailscaleService().StopService(context.Background(), tsm.serviceName); stopErr != nil {
+	stopCtx, cancel := context.WithTimeout(context.Background(), tsm.callTimeout())
+	defer cancel()
+	if stopErr := tsm.preparer.GetTailscaleService().StopService(stopCtx, tsm.serviceName); stopErr != nil {
 		logging.Errorf("Failed to stop tailscale service: %v", stopErr)
 		err = stopErr
 	}
@@ -371,6 +615,11 @@ func (tsm *TailscaleService) Stop(ctx context.Context) error {
 		tsm.cancel()
 	}
 
+	// 取消上下文后删除本节点的 HeadCNI 注解（daemon.removeAnnotationsOnStop），避免周期上报重新写入
+	if cfg := tsm.preparer.GetConfig(); cfg != nil && cfg.Daemon.RemoveAnnotationsOnStop {
+		tsm.removeNodeAnnotations()
+	}
+
 	tsm.isRunning = false
 	tsm.state = TailscaleServiceStateStopped
 
@@ -398,6 +647,9 @@ func (tsm *TailscaleService) startHostModeWithRoutes(node *coreV1.Node) error {
 	// 启动健康检查协程（包含等待就绪和路由设置）
 	go tsm.hostModeHealthCheck(node)
 
+	// 启动看门狗，检测 GetStatus 不再返回的 tailscaled
+	go tsm.tailscaledWatchdogLoop()
+
 	logging.Infof("Host mode started successfully")
 	return nil
 }
@@ -411,7 +663,9 @@ func (tsm *TailscaleService) checkTailscaledHealth() error {
 	}
 
 	// 获取状态之后 判断状态是否为Running
-	status, err := tsm.preparer.GetTailscaleClient().GetStatus(context.Background())
+	ctx, cancel := tsm.callContext()
+	defer cancel()
+	status, err := tsm.preparer.GetTailscaleClient().GetStatus(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to get tailscale status: %v", err)
 	}
@@ -429,15 +683,22 @@ func (tsm *TailscaleService) hostModeHealthCheck(node *coreV1.Node) {
 	hostReady := tsm.tryInitialSetup(node)
 
 	// 开始定期健康检查
-	logging.Infof("Starting periodic health checks...")
-	ticker := time.NewTicker(tsm.healthCheckInterval)
+	interval := tsm.healthCheckInterval()
+	logging.Infof("Starting periodic health checks every %v...", interval)
+	ticker := time.NewTicker(withJitter(interval))
 	defer ticker.Stop()
+	intervalChanged := tsm.intervalWatch()
 
 	for {
 		select {
 		case <-tsm.ctx.Done():
 			return
+		case <-intervalChanged:
+			intervalChanged = tsm.intervalWatch()
+			interval = resetTicker(ticker, interval, tsm.healthCheckInterval(), "Health check")
 		case <-ticker.C:
+			// 每个周期重新取抖动，避免各节点的检查重新同步
+			ticker.Reset(withJitter(interval))
 			// 执行健康检查
 			if err := tsm.performHealthCheck(); err != nil {
 				tsm.updateHealthStatusWithLog(false, err, "Host mode health check failed: %v", err)
@@ -478,7 +739,9 @@ func (tsm *TailscaleService) tryInitialSetup(node *coreV1.Node) bool {
 // [HOST] waitForHostReady 等待主机 tailscaled 准备就绪
 func (tsm *TailscaleService) waitForHostReady() error {
 	condition := func() (bool, error) {
-		status, err := tsm.preparer.GetTailscaleClient().GetStatus(context.Background())
+		ctx, cancel := tsm.callContext()
+		defer cancel()
+		status, err := tsm.preparer.GetTailscaleClient().GetStatusFresh(ctx)
 		if err != nil {
 			return false, err
 		}
@@ -502,6 +765,7 @@ func (tsm *TailscaleService) waitForHostReady() error {
 
 // performHealthCheck 通用健康检查函数（合并 host 和 daemon 模式）
 func (tsm *TailscaleService) performHealthCheck() error {
+	tsm.reportServiceDetails()
 
 	if err := tsm.checkTailscaledHealth(); err != nil {
 		return fmt.Errorf("host health check failed: %v", err)
@@ -513,7 +777,10 @@ func (tsm *TailscaleService) performHealthCheck() error {
 		// 不返回错误，继续运行
 	}
 
-	// 3. 检查 Headscale 路由状态
+	// 3. 检查本地节点密钥是否仍在 Headscale 中有效，不一致时重新认证
+	tsm.checkSplitBrain()
+
+	// 4. 检查 Headscale 路由状态
 	if err := tsm.checkHeadscaleRoutes(); err != nil {
 		logging.Warnf("Headscale routes check failed: %v", err)
 		// 不返回错误，继续运行
@@ -522,6 +789,19 @@ func (tsm *TailscaleService) performHealthCheck() error {
 	return nil
 }
 
+// reportServiceDetails 将 tailscaled 服务的模式和状态导出为指标，host 模式下服务未注册时跳过
+func (tsm *TailscaleService) reportServiceDetails() {
+	sm := tsm.preparer.GetTailscaleService()
+	if sm == nil {
+		return
+	}
+	details, err := sm.GetServiceDetails(tsm.serviceName)
+	if err != nil {
+		return
+	}
+	monitoring.UpdateTailscaleService(details.Name, details.Mode, details.Status, details.BackendState)
+}
+
 // =============================================================================
 // Daemon 模式相关函数
 // =============================================================================
@@ -535,29 +815,76 @@ func (tsm *TailscaleService) startDaemonModeWithRoutes(node *coreV1.Node) error
 		return fmt.Errorf("daemon config files check failed: %v", err)
 	}
 
-	// 2. 启动守护进程监控协程
+	// 2. 首次启动时导入已有的 tailscaled 状态，保活监控随后按已有状态启动 tailscaled
+	if err := tsm.importTailscaleState(); err != nil {
+		return err
+	}
+
+	// 3. 启动守护进程监控协程
 	go tsm.daemonModeTailscaledKeepAlive()
 
-	// 3. 启动健康检查协程（包含等待就绪和路由设置）
+	// 4. 启动健康检查协程（包含等待就绪和路由设置）
 	go tsm.daemonModeHealthCheck(node)
 
+	// 5. 启动看门狗，tailscaled 卡死时重启
+	go tsm.tailscaledWatchdogLoop()
+
 	logging.Infof("Daemon mode started successfully")
 	return nil
 }
 
+// [DAEMON] importTailscaleState 受管状态文件不存在时从 tailscale.importStateFrom 复制已有的状态文件
+// 导入失败时不启动 tailscaled，避免以新身份注册后在 Headscale 中留下原节点
+// 主机上的 tailscaled 仍在响应时拒绝导入：两个进程使用同一节点密钥会互相顶替连接
+func (tsm *TailscaleService) importTailscaleState() error {
+	cfg := tsm.preparer.GetConfig()
+	src := cfg.Tailscale.ImportStateFrom
+	if src == "" {
+		return nil
+	}
+	if _, err := os.Stat(tsm.tailscaleEnv.statePath); err == nil {
+		logging.Debugf("Managed state file %s exists, skipping import from %s", tsm.tailscaleEnv.statePath, src)
+		return nil
+	}
+	if conn, err := net.DialTimeout("unix", hostSocketPath, time.Second); err == nil {
+		conn.Close()
+		return fmt.Errorf("host tailscaled is still running at %s: stop it before importing its state from %s, "+
+			"two tailscaled processes with the same node key take over each other's connection", hostSocketPath, src)
+	}
+
+	identity, err := tailscale.ImportState(src, tsm.tailscaleEnv.statePath)
+	if err != nil {
+		return fmt.Errorf("failed to import tailscaled state: %v", err)
+	}
+	logging.Infof("Imported tailscaled state from %s: %s", src, identity)
+	if identity.ControlURL != "" && cfg.Tailscale.URL != "" && strings.TrimRight(identity.ControlURL, "/") != strings.TrimRight(cfg.Tailscale.URL, "/") {
+		logging.Warnf("Imported state was registered with %s but the daemon is configured for %s, the node may need to log in again", identity.ControlURL, cfg.Tailscale.URL)
+	}
+	return nil
+}
+
 // [DAEMON] daemonModeTailscaledKeepAlive 守护进程保活监控
 func (tsm *TailscaleService) daemonModeTailscaledKeepAlive() error {
-	ticker := time.NewTicker(15 * time.Second)
+	interval := tsm.keepAliveInterval()
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
+	intervalChanged := tsm.intervalWatch()
 
-	logging.Infof("Starting Tailscale daemon keep-alive monitor")
+	logging.Infof("Starting Tailscale daemon keep-alive monitor every %v", interval)
 
 	for {
 		select {
+		case <-intervalChanged:
+			intervalChanged = tsm.intervalWatch()
+			interval = resetTicker(ticker, interval, tsm.keepAliveInterval(), "Keep-alive")
 		case <-ticker.C:
-			if err := tsm.monitorAndMaintainTailscaled(); err != nil {
+			tsm.restartMu.Lock()
+			err := tsm.monitorAndMaintainTailscaled()
+			tsm.restartMu.Unlock()
+			if err != nil {
 				logging.Warnf("Tailscale daemon maintenance failed: %v", err)
 			}
+			tsm.rotateTailscaledLog()
 		case <-tsm.ctx.Done():
 			logging.Infof("Tailscale daemon keep-alive monitor stopped")
 			return nil
@@ -565,6 +892,17 @@ func (tsm *TailscaleService) daemonModeTailscaledKeepAlive() error {
 	}
 }
 
+// [DAEMON] rotateTailscaledLog 轮转 daemon 启动的 tailscaled 的日志文件，tailscaled 持续写入时日志不会无限增长
+func (tsm *TailscaleService) rotateTailscaledLog() {
+	service, ok := tsm.preparer.GetTailscaleService().GetService(tsm.serviceName)
+	if !ok {
+		return
+	}
+	if err := service.RotateLogFile(); err != nil {
+		logging.Warnf("Failed to rotate tailscaled log file: %v", err)
+	}
+}
+
 // [DAEMON] monitorAndMaintainTailscaled 监控并维护 Tailscale 守护进程
 func (tsm *TailscaleService) monitorAndMaintainTailscaled() error {
 	// 检查系统状态
@@ -588,10 +926,10 @@ func (tsm *TailscaleService) checkSystemState() (socketExists, stateExists, proc
 		logging.Debugf("State file exists: %s", tsm.tailscaleEnv.statePath)
 	}
 
-	// 检查进程文件
+	// 检查进程文件，内容为 "<pid> <starttime>"（旧版本只有 PID）
 	if pidData, err := os.ReadFile(tsm.tailscaleEnv.pidPath); err == nil {
-		if pidStr := strings.TrimSpace(string(pidData)); pidStr != "" {
-			if pid, err := strconv.Atoi(pidStr); err == nil {
+		if fields := strings.Fields(string(pidData)); len(fields) > 0 {
+			if pid, err := strconv.Atoi(fields[0]); err == nil {
 				if process, err := os.FindProcess(pid); err == nil {
 					if err := process.Signal(os.Signal(nil)); err == nil {
 						processExists = true
@@ -602,13 +940,19 @@ func (tsm *TailscaleService) checkSystemState() (socketExists, stateExists, proc
 		}
 	}
 
+	// userspace 模式不创建网络接口，进程存在即视为接口就绪
+	if tsm.preparer.GetConfig().UsesUserspaceNetworking() {
+		interfaceExists = processExists
+		return
+	}
+
 	// 检查网络接口
 	interfaceName := tsm.tailscaleEnv.tailscaleNic
 	if interfaceName == "" {
 		logging.Debugf("No interface name specified, skipping interface check")
 		return
 	}
-	if _, err := os.Stat(fmt.Sprintf("/sys/class/net/%s", interfaceName)); err == nil {
+	if _, err := os.Stat(filepath.Join(sysClassNet, interfaceName)); err == nil {
 		interfaceExists = true
 		logging.Debugf("Tailscale interface exists: %s", interfaceName)
 	}
@@ -646,10 +990,9 @@ func (tsm *TailscaleService) determineAndExecuteAction(socketExists, stateExists
 		return nil
 	}
 
-	// 情况5: 文件不存在但接口存在 - 清理接口后重新启动
+	// 情况5: 文件不存在但接口存在 - 优先接管正在启动的 tailscaled，等待宽限期后仍是孤立接口才清理并重新启动
 	if !socketExists && !stateExists && !processExists && interfaceExists {
-		logging.Infof("Files missing but interface exists, cleaning up interface and starting fresh")
-		return tsm.cleanupInterfaceAndStartFresh()
+		return tsm.handleOrphanedInterface()
 	}
 
 	// 情况6: 其他异常情况，需要清理和重建
@@ -665,15 +1008,19 @@ func (tsm *TailscaleService) startFreshTailscaled() error {
 	tsm.cleanupTailscaleFiles()
 
 	// 启动新的 tailscaled 进程
-	_, err := tsm.preparer.GetTailscaleService().StartService(context.Background(), tsm.serviceName, tailscale.ServiceOptions{
-		Hostname:   tsm.tailscaleEnv.hostName,
-		Interface:  tsm.tailscaleEnv.tailscaleNic,
-		AuthKey:    "", // 空字符串表示使用现有认证
-		ControlURL: tsm.preparer.GetConfig().Tailscale.URL,
-		SocketPath: tsm.tailscaleEnv.socketPath,
-		StateFile:  tsm.tailscaleEnv.statePath,
-		ConfigDir:  tsm.tailscaleEnv.configDir, // 添加配置目录字段
-		Mode:       tailscale.ModeStandaloneTailscaled,
+	_, err := tsm.preparer.GetTailscaleService().StartService(tsm.ctx, tsm.serviceName, tailscale.ServiceOptions{
+		Hostname:      tsm.tailscaleEnv.hostName,
+		Interface:     tsm.tailscaleEnv.tailscaleNic,
+		AuthKey:       "", // 空字符串表示使用现有认证
+		ControlURL:    tsm.preparer.GetConfig().Tailscale.URL,
+		SocketPath:    tsm.tailscaleEnv.socketPath,
+		StateFile:     tsm.tailscaleEnv.statePath,
+		ConfigDir:     tsm.tailscaleEnv.configDir, // 添加配置目录字段
+		Mode:          tailscale.ModeStandaloneTailscaled,
+		Userspace:     tsm.preparer.GetConfig().UsesUserspaceNetworking(),
+		ProxyAddr:     tsm.preparer.GetConfig().Tailscale.UserspaceProxyAddr,
+		LogFile:       constants.DefaultTailscaledLogFile,
+		BindInterface: tsm.preparer.GetConfig().Tailscale.BindInterface,
 	})
 	if err != nil {
 		return fmt.Errorf("failed to start tailscale service: %v", err)
@@ -689,7 +1036,7 @@ func (tsm *TailscaleService) cleanupAndRestartTailscaled() error {
 	tsm.cleanupTailscaleFiles()
 
 	// 重启服务
-	if err := tsm.preparer.GetTailscaleService().StopService(context.Background(), tsm.serviceName); err != nil {
+	if err := tsm.preparer.GetTailscaleService().StopService(tsm.ctx, tsm.serviceName); err != nil {
 		logging.Warnf("Failed to stop existing service: %v", err)
 	}
 
@@ -747,15 +1094,19 @@ func (tsm *TailscaleService) restartWithExistingData() error {
 	logging.Infof("Restarting Tailscale daemon with existing data")
 
 	// 直接启动服务，复用现有的 socket、state、pid 文件
-	_, err := tsm.preparer.GetTailscaleService().StartService(context.Background(), tsm.serviceName, tailscale.ServiceOptions{
-		Hostname:   tsm.tailscaleEnv.hostName,
-		Interface:  tsm.tailscaleEnv.tailscaleNic,
-		AuthKey:    "", // 空字符串表示使用现有认证
-		ControlURL: tsm.preparer.GetConfig().Tailscale.URL,
-		SocketPath: tsm.tailscaleEnv.socketPath,
-		StateFile:  tsm.tailscaleEnv.statePath,
-		ConfigDir:  tsm.tailscaleEnv.configDir, // 添加配置目录字段
-		Mode:       tailscale.ModeStandaloneTailscaled,
+	_, err := tsm.preparer.GetTailscaleService().StartService(tsm.ctx, tsm.serviceName, tailscale.ServiceOptions{
+		Hostname:      tsm.tailscaleEnv.hostName,
+		Interface:     tsm.tailscaleEnv.tailscaleNic,
+		AuthKey:       "", // 空字符串表示使用现有认证
+		ControlURL:    tsm.preparer.GetConfig().Tailscale.URL,
+		SocketPath:    tsm.tailscaleEnv.socketPath,
+		StateFile:     tsm.tailscaleEnv.statePath,
+		ConfigDir:     tsm.tailscaleEnv.configDir, // 添加配置目录字段
+		Mode:          tailscale.ModeStandaloneTailscaled,
+		Userspace:     tsm.preparer.GetConfig().UsesUserspaceNetworking(),
+		ProxyAddr:     tsm.preparer.GetConfig().Tailscale.UserspaceProxyAddr,
+		LogFile:       constants.DefaultTailscaledLogFile,
+		BindInterface: tsm.preparer.GetConfig().Tailscale.BindInterface,
 	})
 	if err != nil {
 		return fmt.Errorf("failed to restart with existing data: %v", err)
@@ -791,28 +1142,9 @@ func (tsm *TailscaleService) cleanupTailscaleInterface() error {
 	}
 
 	// 安全检查：避免误删系统接口
-	protectedInterfaces := []string{
-		"tailscale0", // 主机 Tailscale 接口
-		"eth0",       // 主要网络接口
-		"ens",        // 现代 Linux 网络接口前缀
-		"eno",        // 板载网络接口
-		"enp",        // PCI 网络接口
-		"lo",         // 回环接口
-		"docker0",    // Docker 网桥
-		"br-",        // Docker 网桥前缀
-		"veth",       // 虚拟以太网接口
-		"cali",       // Calico 接口
-		"flannel",    // Flannel 接口
-		"cni0",       // CNI 接口
-		"weave",      // Weave 接口
-	}
-
-	// 检查是否为受保护的系统接口
-	for _, protected := range protectedInterfaces {
-		if strings.HasPrefix(interfaceName, protected) || interfaceName == protected {
-			logging.Warnf("Skipping cleanup of protected system interface: %s", interfaceName)
-			return nil
-		}
+	if err := checkInterfaceRemovable(interfaceName, tsm.preparer.GetConfig().Tailscale.ProtectedInterfacePrefixes); err != nil {
+		logging.Warnf("Skipping cleanup of interface %s: %v", interfaceName, err)
+		return nil
 	}
 
 	// 在 Pod 环境中，ip 命令可能不可用，改用 netlink
@@ -840,6 +1172,36 @@ func (tsm *TailscaleService) cleanupTailscaleInterface() error {
 	return nil
 }
 
+// protectedInterfacePrefixes 清理时永远不会删除的系统接口名前缀，tailscale.protectedInterfacePrefixes 追加到该列表
+var protectedInterfacePrefixes = []string{
+	"tailscale0", // 主机 Tailscale 接口
+	"eth0",       // 主要网络接口
+	"ens",        // 现代 Linux 网络接口前缀
+	"eno",        // 板载网络接口
+	"enp",        // PCI 网络接口
+	"lo",         // 回环接口
+	"docker0",    // Docker 网桥
+	"br-",        // Docker 网桥前缀
+	"veth",       // 虚拟以太网接口
+	"cali",       // Calico 接口
+	"flannel",    // Flannel 接口
+	"cni0",       // CNI 接口
+	"weave",      // Weave 接口
+}
+
+// checkInterfaceRemovable 接口名必须符合 HeadCNI 的命名（以 headcni 开头），且不匹配任何受保护前缀，才允许删除
+func checkInterfaceRemovable(name string, extraProtected []string) error {
+	for _, protected := range append(append([]string(nil), protectedInterfacePrefixes...), extraProtected...) {
+		if protected != "" && strings.HasPrefix(name, protected) {
+			return fmt.Errorf("interface matches protected prefix %q", protected)
+		}
+	}
+	if !strings.HasPrefix(name, constants.ManagedInterfacePrefix) {
+		return fmt.Errorf("interface is not managed by HeadCNI (name must start with %q)", constants.ManagedInterfacePrefix)
+	}
+	return nil
+}
+
 // [DAEMON] checkDaemonConfigFiles 检查 daemon 模式配置文件
 func (tsm *TailscaleService) checkDaemonConfigFiles(configDir string) error {
 	logging.Infof("Checking daemon config files in: %s", configDir)
@@ -863,19 +1225,231 @@ func (tsm *TailscaleService) checkDaemonConfigFiles(configDir string) error {
 	return nil
 }
 
+// configChange 配置变更类型
+type configChange int
+
+const (
+	configChangeNone    configChange = iota // 无变更
+	configChangeLive                        // 可通过 EditPrefs 在线应用
+	configChangeRestart                     // 需要重启 tailscaled
+)
+
 // checkConfigChanged 检查配置是否发生变化（通用函数）
 func (tsm *TailscaleService) checkConfigChanged() bool {
+	change, _ := tsm.classifyConfigChange()
+	return change != configChangeNone
+}
+
+// classifyConfigChange 对比新旧配置并对变更分类，返回变更类型和变更项
+// 模式、socket、控制面地址、MTU、网卡名、userspace 设置和用户变更需要重启，其余变更在线应用
+func (tsm *TailscaleService) classifyConfigChange() (configChange, []string) {
 	newConfig := tsm.preparer.GetConfig()
 	oldConfig := tsm.preparer.GetOldConfig()
 
 	if oldConfig == nil || newConfig == nil {
-		return false
+		return configChangeNone, nil
+	}
+
+	oldTS, newTS := oldConfig.Tailscale, newConfig.Tailscale
+
+	var restart []string
+	if newTS.Mode != oldTS.Mode {
+		restart = append(restart, "tailscale.mode")
+	}
+	if newTS.Socket.Path != oldTS.Socket.Path {
+		restart = append(restart, "tailscale.socket.path")
+	}
+	if newTS.URL != oldTS.URL {
+		restart = append(restart, "tailscale.url")
+	}
+	if newConfig.Headscale.URL != oldConfig.Headscale.URL {
+		restart = append(restart, "headscale.url")
+	}
+	if newTS.MTU != oldTS.MTU {
+		restart = append(restart, "tailscale.mtu")
+	}
+	if newTS.InterfaceName != oldTS.InterfaceName {
+		restart = append(restart, "tailscale.interfaceName")
+	}
+	if newTS.Userspace != oldTS.Userspace {
+		restart = append(restart, "tailscale.userspace")
+	}
+	if newTS.UserspaceProxyAddr != oldTS.UserspaceProxyAddr {
+		restart = append(restart, "tailscale.userspaceProxyAddr")
+	}
+	if newTS.BindInterface != oldTS.BindInterface {
+		restart = append(restart, "tailscale.bindInterface")
+	}
+	// 用户由预授权密钥决定，需要重新认证
+	if newTS.User != oldTS.User {
+		restart = append(restart, "tailscale.user")
+	}
+	if len(restart) > 0 {
+		return configChangeRestart, restart
+	}
+
+	var live []string
+	if newConfig.Daemon.DryRun != oldConfig.Daemon.DryRun {
+		live = append(live, "daemon.dryRun")
+	}
+	// 下一次路由收敛或健康检查时生效
+	if newConfig.AutoApproveRoutesEnabled() != oldConfig.AutoApproveRoutesEnabled() {
+		live = append(live, "headscale.autoApproveRoutes")
+	}
+	if newConfig.Headscale.AutoApproverTag != oldConfig.Headscale.AutoApproverTag {
+		live = append(live, "headscale.autoApproverTag")
+	}
+	// 只在停止时读取
+	if newConfig.Daemon.RemoveAnnotationsOnStop != oldConfig.Daemon.RemoveAnnotationsOnStop {
+		live = append(live, "daemon.removeAnnotationsOnStop")
+	}
+	if newTS.AcceptDNS != oldTS.AcceptDNS {
+		live = append(live, "tailscale.acceptDNS")
+	}
+	if newTS.Hostname.Prefix != oldTS.Hostname.Prefix {
+		live = append(live, "tailscale.hostname.prefix")
+	}
+	// 标签通过 SetNodeTags 直接收敛，不需要重新认证
+	if strings.Join(newTS.Tags, ",") != strings.Join(oldTS.Tags, ",") {
+		live = append(live, "tailscale.tags")
+	}
+	if newTS.ManagedTagPrefix != oldTS.ManagedTagPrefix {
+		live = append(live, "tailscale.managedTagPrefix")
+	}
+	if strings.Join(newTS.AdvertiseExtraRoutes, ",") != strings.Join(oldTS.AdvertiseExtraRoutes, ",") {
+		live = append(live, "tailscale.advertiseExtraRoutes")
+	}
+	// 下次登录时读取
+	if newTS.AdvertiseOnConnect != oldTS.AdvertiseOnConnect {
+		live = append(live, "tailscale.advertiseOnConnect")
+	}
+	// 下次批准路由时读取
+	if newTS.RouteConflictPolicy != oldTS.RouteConflictPolicy {
+		live = append(live, "tailscale.routeConflictPolicy")
+	}
+	if newTS.PreferredDERP != oldTS.PreferredDERP {
+		live = append(live, "tailscale.preferredDERP")
+	}
+	if strings.Join(newTS.AcceptRoutesFromTags, ",") != strings.Join(oldTS.AcceptRoutesFromTags, ",") {
+		live = append(live, "tailscale.acceptRoutesFromTags")
+	}
+	// 只在首次启动时读取
+	if newTS.ImportStateFrom != oldTS.ImportStateFrom {
+		live = append(live, "tailscale.importStateFrom")
+	}
+	// 清理接口时读取当前配置
+	if strings.Join(newTS.ProtectedInterfacePrefixes, ",") != strings.Join(oldTS.ProtectedInterfacePrefixes, ",") {
+		live = append(live, "tailscale.protectedInterfacePrefixes")
+	}
+	// 检查间隔由各检查协程在收到通知后重新读取
+	if newTS.HealthCheckInterval != oldTS.HealthCheckInterval {
+		live = append(live, "tailscale.healthCheckInterval")
+	}
+	if newTS.KeepAliveInterval != oldTS.KeepAliveInterval {
+		live = append(live, "tailscale.keepAliveInterval")
+	}
+	if newTS.RuleSyncInterval != oldTS.RuleSyncInterval {
+		live = append(live, "tailscale.ruleSyncInterval")
+	}
+	if newTS.StatusCacheTTL != oldTS.StatusCacheTTL {
+		live = append(live, "tailscale.statusCacheTTL")
+	}
+	// 看门狗每个周期重新读取
+	if newTS.WatchdogTimeout != oldTS.WatchdogTimeout || newTS.WatchdogThreshold != oldTS.WatchdogThreshold {
+		live = append(live, "tailscale.watchdog")
+	}
+	// 下一次发现孤立网卡时读取
+	if newTS.OrphanInterfaceGracePeriod != oldTS.OrphanInterfaceGracePeriod {
+		live = append(live, "tailscale.orphanInterfaceGracePeriod")
+	}
+	if len(live) > 0 {
+		return configChangeLive, live
+	}
+
+	return configChangeNone, nil
+}
+
+// applyLiveConfigChanges 通过 EditPrefs 在线应用配置变更，不中断 tailnet 连接
+// 调用方需持有 tsm.mu
+func (tsm *TailscaleService) applyLiveConfigChanges() error {
+	newConfig := tsm.preparer.GetConfig()
+	oldConfig := tsm.preparer.GetOldConfig()
+	tailscaleClient := tsm.preparer.GetTailscaleClient()
+	if tailscaleClient == nil {
+		return fmt.Errorf("tailscale client not available")
+	}
+
+	ctx, cancel := tsm.callContext()
+	defer cancel()
+
+	// 只在配置项变化时覆盖通过 daemon socket 切换的状态
+	if newConfig.Daemon.DryRun != oldConfig.Daemon.DryRun {
+		tsm.SetDryRun(newConfig.Daemon.DryRun)
+	}
+
+	if newConfig.Tailscale.StatusCacheTTL != oldConfig.Tailscale.StatusCacheTTL {
+		tailscaleClient.SetStatusCacheTTL(newConfig.StatusCacheDuration())
+		logging.Infof("Applied tailscale.statusCacheTTL=%v", newConfig.StatusCacheDuration())
+	}
+
+	if newConfig.Tailscale.AcceptDNS != oldConfig.Tailscale.AcceptDNS {
+		if err := tailscaleClient.SetAcceptDNS(ctx, newConfig.Tailscale.AcceptDNS); err != nil {
+			return fmt.Errorf("failed to set accept DNS: %v", err)
+		}
+		logging.Infof("Applied tailscale.acceptDNS=%v", newConfig.Tailscale.AcceptDNS)
+	}
+
+	if newConfig.Tailscale.Hostname.Prefix != oldConfig.Tailscale.Hostname.Prefix {
+		tailscaleEnv := tsm.getTailscaleEnv()
+		if tailscaleEnv == nil {
+			return fmt.Errorf("tailscale environment not initialized")
+		}
+
+		// 旧主机名不再匹配新前缀，readHostNameInDomain 会重新生成并持久化
+		hostname := tsm.readHostNameInDomain(tailscaleEnv.hostNamePath)
+		if err := tailscaleClient.SetHostname(ctx, hostname); err != nil {
+			return fmt.Errorf("failed to set hostname: %v", err)
+		}
+		tailscaleEnv.hostName = hostname
+		logging.Infof("Applied tailscale hostname %s", hostname)
+	}
+
+	// 固定的 DERP 区域只保存在 tailscaled 内存中，每次重载都重新应用
+	if newConfig.Tailscale.PreferredDERP != 0 || oldConfig.Tailscale.PreferredDERP != 0 {
+		tsm.applyPreferredDERP(newConfig.Tailscale.PreferredDERP)
+	}
+
+	if strings.Join(newConfig.Tailscale.Tags, ",") != strings.Join(oldConfig.Tailscale.Tags, ",") ||
+		newConfig.Tailscale.ManagedTagPrefix != oldConfig.Tailscale.ManagedTagPrefix ||
+		newConfig.Headscale.AutoApproverTag != oldConfig.Headscale.AutoApproverTag {
+		// 旧的 autoApprovers 标签不一定匹配 managedTagPrefix，不移除时 Headscale 会继续自动批准路由
+		var retired []string
+		if tag := oldConfig.Headscale.AutoApproverTag; tag != "" && tag != newConfig.Headscale.AutoApproverTag {
+			retired = append(retired, tag)
+		}
+		if err := tsm.reconcileNodeTags(retired...); err != nil {
+			return fmt.Errorf("failed to reconcile node tags: %v", err)
+		}
+	}
+
+	// 重新确认接受路由，并将通告路由收敛到 Pod CIDR 加额外路由，已删除的额外路由随之撤销
+	if err := tsm.acceptRoutes(ctx); err != nil {
+		return fmt.Errorf("failed to accept routes: %v", err)
+	}
+	if err := tsm.reconcileAcceptedRoutes(); err != nil {
+		logging.Warnf("Failed to reconcile accepted routes: %v", err)
+	}
+	podCIDRs, err := tsm.nodePodCIDRs(tsm.hostname)
+	if err != nil {
+		return fmt.Errorf("failed to get Pod CIDR for node %s: %v", tsm.hostname, err)
+	}
+	if len(podCIDRs) > 0 || tsm.preparer.GetConfig().IsRouterMode() {
+		if err := tsm.ensureTailscaleRoute(podCIDRs...); err != nil {
+			return fmt.Errorf("failed to ensure advertised routes %v: %v", podCIDRs, err)
+		}
 	}
 
-	// 检查关键配置是否变更
-	return newConfig.Tailscale.Mode != oldConfig.Tailscale.Mode ||
-		newConfig.Tailscale.Socket.Path != oldConfig.Tailscale.Socket.Path ||
-		newConfig.Headscale.URL != oldConfig.Headscale.URL
+	return nil
 }
 
 // handleErrorWithLog 通用错误处理函数，消除重复的错误处理模式
@@ -894,16 +1468,24 @@ func (tsm *TailscaleService) daemonModeHealthCheck(node *coreV1.Node) {
 	daemonReady := tsm.tryDaemonInitialSetup(node)
 
 	// 开始定期健康检查
-	logging.Infof("Starting periodic health checks...")
-	ticker := time.NewTicker(tsm.healthCheckInterval)
+	interval := tsm.healthCheckInterval()
+	logging.Infof("Starting periodic health checks every %v...", interval)
+	ticker := time.NewTicker(withJitter(interval))
 	defer ticker.Stop()
+	intervalChanged := tsm.intervalWatch()
 
 	for {
 		select {
 		case <-tsm.ctx.Done():
 			return
+		case <-intervalChanged:
+			intervalChanged = tsm.intervalWatch()
+			interval = resetTicker(ticker, interval, tsm.healthCheckInterval(), "Health check")
 		case <-ticker.C:
+			// 每个周期重新取抖动，避免各节点的检查重新同步
+			ticker.Reset(withJitter(interval))
 			// 执行健康检查
+			tsm.reconcileInterfaceMTU()
 			if err := tsm.performHealthCheck(); err != nil {
 				tsm.updateHealthStatusWithLog(false, err, "Daemon mode health check failed: %v", err)
 				// 如果未就绪，尝试重新设置
@@ -943,7 +1525,9 @@ func (tsm *TailscaleService) tryDaemonInitialSetup(node *coreV1.Node) bool {
 // [DAEMON] waitForDaemonReady 等待 daemon 模式 tailscaled 准备就绪
 func (tsm *TailscaleService) waitForDaemonReady() error {
 	condition := func() (bool, error) {
-		status, err := tsm.preparer.GetTailscaleClient().GetStatus(context.Background())
+		ctx, cancel := tsm.callContext()
+		defer cancel()
+		status, err := tsm.preparer.GetTailscaleClient().GetStatusFresh(ctx)
 		if err != nil {
 			return false, err
 		}
@@ -978,16 +1562,19 @@ func (tsm *TailscaleService) waitForDaemonReady() error {
 func (tsm *TailscaleService) setupAndManageRoutes(node *coreV1.Node) error {
 	logging.Infof("Setting up and managing routes for node: %s", node.Name)
 
-	podLocalCIDR, err := tsm.preparer.GetK8sClient().Nodes().GetPodCIDR(node.Name)
+	routerMode := tsm.preparer.GetConfig().IsRouterMode()
+	podCIDRs, err := tsm.nodePodCIDRs(node.Name)
 	if err != nil {
 		return fmt.Errorf("failed to get Pod CIDR for node %s: %w", node.Name, err)
 	}
-	if podLocalCIDR == "" {
+	if routerMode {
+		logging.Infof("Node %s runs in router mode, advertising routes: %v", node.Name, tsm.preparer.GetConfig().Tailscale.AdvertiseExtraRoutes)
+	} else if len(podCIDRs) == 0 {
 		return fmt.Errorf("no Pod CIDR found for node %s", node.Name)
+	} else {
+		logging.Infof("Node %s Pod CIDRs: %v", node.Name, podCIDRs)
 	}
 
-	logging.Infof("Node %s Pod CIDR: %s", node.Name, podLocalCIDR)
-
 	// 1. 获取 Tailscale IP 和节点密钥
 	tailscaleIP, nodeKey, err := tsm.getTailscaleInfo()
 	if err != nil {
@@ -996,77 +1583,219 @@ func (tsm *TailscaleService) setupAndManageRoutes(node *coreV1.Node) error {
 
 	logging.Infof("Tailscale IP: %s, Node Key: %s...", tailscaleIP.String(), nodeKey[:min(10, len(nodeKey))])
 
-	// 2. 设置客户端路由偏好
-	if err := tsm.setupClientRoutePreferences(); err != nil {
-		logging.Warnf("Failed to setup client route preferences: %v", err)
-		// 不返回错误，继续执行
-	}
+	// 2-5. 设置路由偏好、通告并批准路由
+	tsm.advertiseAndApproveRoutes(podCIDRs, tailscaleIP)
 
-	// 3. 配置路由通告（通过 manageHeadscaleRoutes 处理）
-	if err := tsm.manageHeadscaleRoutes(podLocalCIDR, tailscaleIP.String()); err != nil {
-		logging.Warnf("Failed to configure route advertisement: %v", err)
+	// 6. 上传 Tailscale 信息和守护进程版本信息到节点注解
+	if err := tsm.uploadTailscaleInfo(tailscaleIP, nodeKey); err != nil {
+		logging.Warnf("Failed to upload tailscale info: %v", err)
 		// 不返回错误，继续执行
 	}
+	tsm.reportDaemonInfo()
 
-	// 4. 等待路由同步到 Headscale
-	if err := tsm.waitForRouteSync(podLocalCIDR); err != nil {
-		logging.Warnf("Route sync failed: %v", err)
-		// 不返回错误，继续执行
-	}
+	// 上次身份轮换被中断时完成收尾
+	tsm.resumeIdentityRotation(nodeKey)
 
-	// 5. 管理 Headscale 路由（批准路由）
-	if err := tsm.manageHeadscaleRoutes(podLocalCIDR, tailscaleIP.String()); err != nil {
-		logging.Warnf("Failed to manage headscale routes: %v", err)
-		// 不返回错误，继续执行
+	// 7. 清理同主机名的陈旧节点注册（需在配置中开启）
+	if tsm.preparer.GetConfig().Headscale.ReconcileDuplicates {
+		if err := tsm.reconcileDuplicateNodes(); err != nil {
+			logging.Warnf("Failed to reconcile duplicate headscale nodes: %v", err)
+			// 不返回错误，继续执行
+		}
 	}
 
-	// 6. 上传 Tailscale 信息到节点注解
-	if err := tsm.uploadTailscaleInfo(tailscaleIP, nodeKey); err != nil {
-		logging.Warnf("Failed to upload tailscale info: %v", err)
+	// 8. 将节点的 ACL 标签收敛到配置
+	if err := tsm.reconcileNodeTags(); err != nil {
+		logging.Warnf("Failed to reconcile headscale node tags: %v", err)
 		// 不返回错误，继续执行
 	}
 
-	// 启动规则监控和维护
-	go tsm.monitorAndMaintainRules()
+	// 启动规则监控和维护，router 模式节点没有 Pod，userspace 模式没有内核接口，都不需要 Pod IP 规则
+	switch {
+	case routerMode:
+	case tsm.preparer.GetConfig().UsesUserspaceNetworking():
+		logging.Infof("Userspace networking enabled, pods reach the tailnet through the proxy on %s", tsm.preparer.GetConfig().Tailscale.UserspaceProxyAddr)
+	default:
+		go tsm.monitorAndMaintainRules()
+	}
 
 	logging.Infof("Route setup completed")
 	return nil
 }
 
-func isSameNetwork(ip1, ip2 net.IP) bool {
-	// 如果都是IPv4，检查前2个字节是否相同 (相当于/16网段)
-	if ip1.To4() != nil && ip2.To4() != nil {
-		ip1v4 := ip1.To4()
-		ip2v4 := ip2.To4()
-		return ip1v4[0] == ip2v4[0] && ip1v4[1] == ip2v4[1]
+// advertiseAndApproveRoutes 设置客户端路由偏好，通告路由并在 Headscale 中批准，失败只记录日志
+// podCIDRs 为空（router 模式）时只通告并批准配置的额外路由；不再属于节点的 Pod CIDR 随通告路由的收敛撤销
+func (tsm *TailscaleService) advertiseAndApproveRoutes(podCIDRs []string, tailscaleIP net.IP) {
+	// 设置客户端路由偏好
+	if err := tsm.setupClientRoutePreferences(); err != nil {
+		logging.Warnf("Failed to setup client route preferences: %v", err)
 	}
-	return false
-}
 
-func (tsm *TailscaleService) addIPRuleInHost() error {
-	//ip rule add from <tailscale_ip> lookup 53 priority 153
-	//ip rule add to <pod_local_cidr> table main priority 152
-	tailscaleIP, err := tsm.preparer.GetTailscaleClient().GetIP(context.Background())
-	if err != nil {
-		logging.Warnf("Failed to get tailscale ip: %v", err)
-		return err
+	if len(podCIDRs) == 0 {
+		tsm.advertiseAndApproveExtraRoutes()
+		return
 	}
 
-	nodeName, err := tsm.preparer.GetK8sClient().GetCurrentNodeName()
-	if err != nil {
+	// 通告 Pod CIDR 和配置的额外路由
+	if err := tsm.ensureTailscaleRoute(podCIDRs...); err != nil {
+		logging.Warnf("Failed to advertise routes: %v", err)
+	}
+
+	// 配置路由通告（通过 manageHeadscaleRoutes 处理）
+	if err := tsm.manageHeadscaleRoutes(podCIDRs, tailscaleIP.String()); err != nil {
+		logging.Warnf("Failed to configure route advertisement: %v", err)
+	}
+
+	// 等待路由同步到 Headscale
+	for _, podCIDR := range podCIDRs {
+		if err := tsm.waitForRouteSync(podCIDR); err != nil {
+			logging.Warnf("Route sync failed: %v", err)
+		}
+	}
+
+	// 管理 Headscale 路由（批准路由）
+	if err := tsm.manageHeadscaleRoutes(podCIDRs, tailscaleIP.String()); err != nil {
+		logging.Warnf("Failed to manage headscale routes: %v", err)
+	}
+}
+
+// [PUBLIC] ReconcileRoutes 立即执行一次路由收敛：通告并批准路由，非 router 模式下同时维护主机 IP 规则
+// 与身份轮换共用 rotationMu，轮换进行中时返回错误
+func (tsm *TailscaleService) ReconcileRoutes() error {
+	if !tsm.IsRunning() {
+		return fmt.Errorf("tailscale service is not running")
+	}
+	if !tsm.rotationMu.TryLock() {
+		return fmt.Errorf("identity rotation in progress")
+	}
+	defer tsm.rotationMu.Unlock()
+
+	nodeName, err := tsm.preparer.GetK8sClient().GetCurrentNodeName()
+	if err != nil {
+		return fmt.Errorf("failed to get current node name: %v", err)
+	}
+	podCIDRs, err := tsm.nodePodCIDRs(nodeName)
+	if err != nil {
+		return fmt.Errorf("failed to get Pod CIDR for node %s: %v", nodeName, err)
+	}
+	tailscaleIP, _, err := tsm.getTailscaleInfo()
+	if err != nil {
+		return fmt.Errorf("failed to get tailscale info: %v", err)
+	}
+
+	logging.Infof("Reconciling routes on request")
+	tsm.advertiseAndApproveRoutes(podCIDRs, tailscaleIP)
+	if tsm.preparer.GetConfig().IsRouterMode() {
+		return nil
+	}
+	if err := tsm.addIPRuleInHost(); err != nil {
+		return fmt.Errorf("failed to add ip rule in host: %v", err)
+	}
+	return nil
+}
+
+// advertiseAndApproveExtraRoutes 通告配置的额外路由，等待同步到 Headscale 后批准本节点的额外路由
+func (tsm *TailscaleService) advertiseAndApproveExtraRoutes() {
+	extraRoutes := extraAdvertiseRoutes(tsm.preparer.GetConfig())
+	if err := tsm.reconcileAdvertisedRoutes(extraRoutes); err != nil {
+		logging.Warnf("Failed to advertise routes: %v", err)
+	}
+	if len(extraRoutes) == 0 {
+		return
+	}
+
+	if err := tsm.waitForRouteSync(extraRoutes[0].String()); err != nil {
+		logging.Warnf("Route sync failed: %v", err)
+	}
+
+	ctx, cancel := tsm.callContext()
+	defer cancel()
+	routes, err := tsm.preparer.GetHeadscaleClient().GetRoutes(ctx)
+	if err != nil {
+		logging.Warnf("Failed to get headscale routes: %v", err)
+		return
+	}
+	if err := tsm.enableExtraRoutes(ctx, routes.Routes); err != nil {
+		logging.Warnf("Failed to enable extra routes: %v", err)
+	}
+}
+
+// nodePodCIDRs 获取节点通告的全部 Pod CIDR，router 模式节点不承载 Pod，返回 nil
+func (tsm *TailscaleService) nodePodCIDRs(nodeName string) ([]string, error) {
+	if tsm.preparer.GetConfig().IsRouterMode() {
+		return nil, nil
+	}
+	return tsm.preparer.GetNodePodCIDRs(nodeName)
+}
+
+func isSameNetwork(ip1, ip2 net.IP) bool {
+	// 如果都是IPv4，检查前2个字节是否相同 (相当于/16网段)
+	if ip1.To4() != nil && ip2.To4() != nil {
+		ip1v4 := ip1.To4()
+		ip2v4 := ip2.To4()
+		return ip1v4[0] == ip2v4[0] && ip1v4[1] == ip2v4[1]
+	}
+	return false
+}
+
+func (tsm *TailscaleService) addIPRuleInHost() error {
+	//ip rule add from <tailscale_ip> lookup 53 priority 153
+	//ip rule add to <pod_local_cidr> table main priority 3151
+	//ip rule add iif <tailscale_nic> to <pod_local_cidr> table main priority 3151
+	// userspace 模式没有内核接口，入站流量由 tailscaled 在用户态转发，不需要策略路由
+	if tsm.preparer.GetConfig().UsesUserspaceNetworking() {
+		logging.Debugf("Userspace networking enabled, skipping IP rule installation")
+		return nil
+	}
+
+	tailscaleEnv := tsm.getTailscaleEnv()
+	if tailscaleEnv == nil || tailscaleEnv.tailscaleNic == "" {
+		return fmt.Errorf("tailscale interface is not configured")
+	}
+	tailscaleNic := tailscaleEnv.tailscaleNic
+
+	// 接口尚未创建时不安装规则，避免规则指向不存在的接口
+	link, err := netlink.LinkByName(tailscaleNic)
+	if err != nil {
+		logging.Infof("Tailscale interface %s does not exist yet, skipping IP rule installation", tailscaleNic)
+		return nil
+	}
+
+	ctx, cancel := tsm.callContext()
+	defer cancel()
+	tailscaleIP, err := tsm.preparer.GetTailscaleClient().GetIP(ctx)
+	if err != nil {
+		logging.Warnf("Failed to get tailscale ip: %v", err)
+		return err
+	}
+
+	if err := validateTailscaleNic(link, tailscaleIP); err != nil {
+		return err
+	}
+
+	nodeName, err := tsm.preparer.GetK8sClient().GetCurrentNodeName()
+	if err != nil {
 		tsm.updateHealthStatus(false, err)
 		return tsm.handleErrorWithLog(err, "Failed to get current node name: %w", err)
 	}
-	podLocalCIDR, err := tsm.preparer.GetK8sClient().Nodes().GetPodCIDR(nodeName)
+	podCIDRs, err := tsm.preparer.GetNodePodCIDRs(nodeName)
 	if err != nil {
 		logging.Warnf("Failed to get pod local cidr: %v", err)
 		return err
 	}
-	// 将 podLocalCIDR 转换为 *net.IPNet
-	_, podLocalCIDRNet, err := net.ParseCIDR(podLocalCIDR)
-	if err != nil {
-		logging.Warnf("Failed to parse pod local cidr: %v", err)
-		return err
+	// 规则只维护 IPv4 网段
+	var podCIDRNets []*net.IPNet
+	for _, podCIDR := range podCIDRs {
+		_, podCIDRNet, err := net.ParseCIDR(podCIDR)
+		if err != nil {
+			logging.Warnf("Failed to parse pod local cidr: %v", err)
+			return err
+		}
+		if podCIDRNet.IP.To4() == nil {
+			logging.Debugf("Skipping policy rule for IPv6 Pod CIDR %s", podCIDR)
+			continue
+		}
+		podCIDRNets = append(podCIDRNets, podCIDRNet)
 	}
 
 	// 检查当前规则列表
@@ -1077,49 +1806,123 @@ func (tsm *TailscaleService) addIPRuleInHost() error {
 	}
 
 	// 检查机器上是否有tailscale0的ip
-	localIP, err := tsm.preparer.GetTailscaleClient().GetLocalIP(context.Background())
+	localIP, err := tsm.preparer.GetTailscaleClient().GetLocalIP(ctx)
 	if err == nil {
 		if localIP.String() != tailscaleIP.String() {
-			if err := tsm.manageRule(rules, localIP, nil, 52, 3152, "from"); err != nil {
+			if err := tsm.manageRule(rules, localIP, nil, "", 52, constants.RulePriorityLocalIP, "from"); err != nil {
 				logging.Warnf("Failed to add local IP rule: %v", err)
 			}
 		}
 	}
 
 	// 添加两个规则（并行执行，互不影响）
-	if err := tsm.manageRule(rules, tailscaleIP, nil, 53, 3153, "from"); err != nil {
+	if err := tsm.manageRule(rules, tailscaleIP, nil, "", 53, constants.RulePriorityTailscaleIP, "from"); err != nil {
 		logging.Warnf("Failed to add tailscale IP rule: %v", err)
 	}
 
-	if err := tsm.manageRule(rules, netip.Addr{}, podLocalCIDRNet, 254, 3151, "to"); err != nil {
-		logging.Warnf("Failed to add pod CIDR rule: %v", err)
+	for _, rule := range podCIDRRules(podCIDRNets, tailscaleNic) {
+		if err := tsm.manageRule(rules, netip.Addr{}, rule.Dst, rule.IifName, rule.Table, rule.Priority, "to"); err != nil {
+			logging.Warnf("Failed to add pod CIDR rule for %s: %v", rule.Dst, err)
+		}
 	}
+	tsm.deleteStalePodCIDRRules(rules, podCIDRNets, tailscaleNic)
 
 	return nil
 }
 
+// podCIDRRules 返回目的为本节点 Pod CIDR 的 lookup main 规则（优先级 3151）
+// 未限定接口的规则让本机和网桥发往本地 Pod 的流量查 main 表，不会落到 tailscale 的 table 52；
+// 限定 tailscale 接口的规则匹配从 tailnet 进入的流量
+func podCIDRRules(podCIDRNets []*net.IPNet, tailscaleNic string) []netlink.Rule {
+	var rules []netlink.Rule
+	for _, podCIDRNet := range podCIDRNets {
+		for _, iif := range []string{"", tailscaleNic} {
+			rule := *netlink.NewRule()
+			rule.Dst = podCIDRNet
+			rule.IifName = iif
+			rule.Table = 254
+			rule.Priority = constants.RulePriorityPodCIDR
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// deleteStalePodCIDRRules 删除目的网段已不属于本节点的 Pod CIDR 规则（Pod CIDR 从节点上移除后）
+func (tsm *TailscaleService) deleteStalePodCIDRRules(existingRules []netlink.Rule, podCIDRNets []*net.IPNet, iif string) {
+	current := make(map[string]bool, len(podCIDRNets))
+	for _, podCIDRNet := range podCIDRNets {
+		current[podCIDRNet.String()] = true
+	}
+
+	for _, rule := range existingRules {
+		if rule.Priority != constants.RulePriorityPodCIDR || rule.Table != 254 || rule.Dst == nil || rule.Src != nil ||
+			(rule.IifName != "" && rule.IifName != iif) || current[rule.Dst.String()] {
+			continue
+		}
+		if tsm.skipInDryRun("delete stale pod CIDR rule: to %s table main priority %d", rule.Dst, rule.Priority) {
+			continue
+		}
+		stale := rule
+		if err := netlink.RuleDel(&stale); err != nil {
+			logging.Warnf("Failed to delete stale pod CIDR rule to %s: %v", rule.Dst, err)
+		} else {
+			logging.Infof("Deleted stale pod CIDR rule: to %s table main priority %d", rule.Dst, rule.Priority)
+		}
+	}
+}
+
+// validateTailscaleNic 校验配置的接口确实是 tailscaled 创建的接口（持有本机 tailscale IP）
+func validateTailscaleNic(link netlink.Link, tailscaleIP netip.Addr) error {
+	name := link.Attrs().Name
+	addrs, err := netlink.AddrList(link, netlink.FAMILY_ALL)
+	if err != nil {
+		return fmt.Errorf("failed to list addresses of interface %s: %v", name, err)
+	}
+	for _, addr := range addrs {
+		if addr.IP.Equal(tailscaleIP.AsSlice()) {
+			return nil
+		}
+	}
+
+	// 找出实际持有 tailscale IP 的接口，便于排查配置
+	if allAddrs, err := netlink.AddrList(nil, netlink.FAMILY_ALL); err == nil {
+		for _, addr := range allAddrs {
+			if !addr.IP.Equal(tailscaleIP.AsSlice()) {
+				continue
+			}
+			if owner, err := netlink.LinkByIndex(addr.LinkIndex); err == nil {
+				return fmt.Errorf("interface %s does not carry tailscale IP %s, tailscaled created %s (check tailscale.interfaceName)",
+					name, tailscaleIP, owner.Attrs().Name)
+			}
+		}
+	}
+	return fmt.Errorf("interface %s does not carry tailscale IP %s (check tailscale.interfaceName)", name, tailscaleIP)
+}
+
 // 通用的规则管理函数
-func (tsm *TailscaleService) manageRule(existingRules []netlink.Rule, srcIP netip.Addr, dstNet *net.IPNet, table, priority int, ruleType string) error {
+// iif 仅用于 to 规则，非空时规则只匹配从该接口进入的流量
+func (tsm *TailscaleService) manageRule(existingRules []netlink.Rule, srcIP netip.Addr, dstNet *net.IPNet, iif string, table, priority int, ruleType string) error {
 	// 检查是否已存在完全匹配的规则
 	for _, rule := range existingRules {
-		if tsm.isRuleMatch(rule, srcIP, dstNet, table, priority, ruleType) {
+		if tsm.isRuleMatch(rule, srcIP, dstNet, iif, table, priority, ruleType) {
 			logging.Infof("%s rule already exists: %s %s lookup %d priority %d",
 				strings.Title(ruleType), ruleType, tsm.getRuleDescription(srcIP, dstNet), table, priority)
 			return nil
 		}
 	}
 
-	// 删除同网段的旧规则
+	// 删除同网段的旧 from 规则；to 规则按目的网段和接口区分，过期的由 deleteStalePodCIDRRules 删除
 	if err := tsm.deleteOldRules(existingRules, srcIP, dstNet, table, priority, ruleType); err != nil {
 		return err
 	}
 
 	// 添加新规则
-	return tsm.addNewRule(srcIP, dstNet, table, priority, ruleType)
+	return tsm.addNewRule(srcIP, dstNet, iif, table, priority, ruleType)
 }
 
 // 检查规则是否匹配
-func (tsm *TailscaleService) isRuleMatch(rule netlink.Rule, srcIP netip.Addr, dstNet *net.IPNet, table, priority int, ruleType string) bool {
+func (tsm *TailscaleService) isRuleMatch(rule netlink.Rule, srcIP netip.Addr, dstNet *net.IPNet, iif string, table, priority int, ruleType string) bool {
 	if rule.Priority != priority || rule.Table != table {
 		return false
 	}
@@ -1135,6 +1938,7 @@ func (tsm *TailscaleService) isRuleMatch(rule netlink.Rule, srcIP netip.Addr, ds
 			dstNet != nil &&
 			rule.Dst.IP.Equal(dstNet.IP) &&
 			rule.Dst.Mask.String() == dstNet.Mask.String() &&
+			rule.IifName == iif &&
 			rule.Src == nil
 	}
 }
@@ -1152,19 +1956,15 @@ func (tsm *TailscaleService) deleteOldRules(existingRules []netlink.Rule, srcIP
 					logging.Infof("Found old from rule in same network to delete: from %s lookup %d priority %d",
 						rule.Src.IP.String(), table, priority)
 				}
-			} else if ruleType == "to" && rule.Dst != nil && rule.Src == nil {
-				if rule.Dst.String() == dstNet.String() {
-					ruleCopy := rule
-					rulesToDelete = append(rulesToDelete, &ruleCopy)
-					logging.Infof("Found old to rule to delete: to %s table main priority %d",
-						rule.Dst.String(), priority)
-				}
 			}
 		}
 	}
 
 	// 删除旧规则
 	for _, rule := range rulesToDelete {
+		if tsm.skipInDryRun("delete old %s rule %v", ruleType, rule) {
+			continue
+		}
 		if err := netlink.RuleDel(rule); err != nil {
 			logging.Warnf("Failed to delete old %s rule %v: %v", ruleType, rule, err)
 		} else {
@@ -1177,7 +1977,7 @@ func (tsm *TailscaleService) deleteOldRules(existingRules []netlink.Rule, srcIP
 }
 
 // 添加新规则
-func (tsm *TailscaleService) addNewRule(srcIP netip.Addr, dstNet *net.IPNet, table, priority int, ruleType string) error {
+func (tsm *TailscaleService) addNewRule(srcIP netip.Addr, dstNet *net.IPNet, iif string, table, priority int, ruleType string) error {
 	newRule := netlink.NewRule()
 	newRule.Table = table
 	newRule.Priority = priority
@@ -1196,6 +1996,7 @@ func (tsm *TailscaleService) addNewRule(srcIP netip.Addr, dstNet *net.IPNet, tab
 			IP:   dstNet.IP,
 			Mask: dstNet.Mask,
 		}
+		newRule.IifName = iif
 	}
 
 	var tableName string
@@ -1204,6 +2005,10 @@ func (tsm *TailscaleService) addNewRule(srcIP netip.Addr, dstNet *net.IPNet, tab
 	} else {
 		tableName = fmt.Sprintf("%d", table)
 	}
+	if tsm.skipInDryRun("add %s rule: %s %s lookup %s priority %d",
+		ruleType, ruleType, tsm.getRuleDescription(srcIP, dstNet), tableName, priority) {
+		return nil
+	}
 	logging.Infof("Attempting to add %s rule: %s %s lookup %s priority %d",
 		ruleType, ruleType, tsm.getRuleDescription(srcIP, dstNet), tableName, priority)
 
@@ -1229,7 +2034,7 @@ func (tsm *TailscaleService) cleanupIPRules() error {
 	}
 
 	// 清理我们添加的规则（优先级 3151, 3152, 3153）
-	prioritiesToClean := []int{3151, 3152, 3153}
+	prioritiesToClean := constants.HeadcniRulePriorities
 
 	for _, priority := range prioritiesToClean {
 		for _, rule := range rules {
@@ -1263,33 +2068,194 @@ func (tsm *TailscaleService) monitorAndMaintainRules() {
 	if err := tsm.addIPRuleInHost(); err != nil {
 		logging.Warnf("Failed first time to add ip rule in host: %v", err)
 	}
+	tsm.syncPodMTU()
 
-	ticker := time.NewTicker(30 * time.Second)
+	interval := tsm.ruleSyncInterval()
+	ticker := time.NewTicker(withJitter(interval))
 	defer ticker.Stop()
+	intervalChanged := tsm.intervalWatch()
 
 	for {
 		select {
+		case <-intervalChanged:
+			intervalChanged = tsm.intervalWatch()
+			interval = resetTicker(ticker, interval, tsm.ruleSyncInterval(), "Rule sync")
 		case <-ticker.C:
+			ticker.Reset(withJitter(interval))
 			if err := tsm.addIPRuleInHost(); err != nil {
 				logging.Warnf("Failed to add ip rule in host: %v", err)
 			}
+			tsm.syncPodMTU()
+			// tailscaled 在网络图变化时会重新安装路由，需要周期性收敛
+			if err := tsm.reconcileAcceptedRoutes(); err != nil {
+				logging.Warnf("Failed to reconcile accepted routes: %v", err)
+			}
 		case <-tsm.ctx.Done():
 			return
 		}
 	}
 }
 
+// watchStateEvents 订阅 Tailscale 后端状态变化，输出结构化日志并记录指标，直到 ctx 结束
+// 返回前已完成订阅，随后启动 tailscaled 期间的状态变化不会丢失
+func (tsm *TailscaleService) watchStateEvents(ctx context.Context) {
+	client := tsm.preparer.GetTailscaleClient()
+	if client == nil {
+		return
+	}
+
+	events := make(chan tailscale.StateEvent, 16)
+	client.Subscribe(events)
+	go tsm.consumeStateEvents(ctx, client, events)
+}
+
+// consumeStateEvents 处理订阅到的状态事件，ctx 结束时取消订阅
+func (tsm *TailscaleService) consumeStateEvents(ctx context.Context, client tailscale.TailscaleClient, events chan tailscale.StateEvent) {
+	defer client.Unsubscribe(events)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-events:
+			if logger := logging.GetLogger(); logger != nil {
+				logger.Infow("Tailscale backend state changed",
+					"from", event.From, "to", event.To, "reason", event.Reason, "time", event.Time.Format(time.RFC3339))
+			} else {
+				logging.Infof("Tailscale backend state changed: from=%s to=%s reason=%s", event.From, event.To, event.Reason)
+			}
+			monitoring.RecordTailscaleStateTransition(event.From, event.To)
+		}
+	}
+}
+
+// computePodMTU 根据 tailscale 接口 MTU 和出口接口 MTU 计算安全的 Pod MTU
+// Pod MTU 不超过 tailscale 接口 MTU，也不超过出口 MTU 减去 WireGuard 开销，最低 1280
+func (tsm *TailscaleService) computePodMTU() (int, error) {
+	tailscaleEnv := tsm.getTailscaleEnv()
+	if tailscaleEnv == nil || tailscaleEnv.tailscaleNic == "" {
+		return 0, fmt.Errorf("tailscale interface is not configured")
+	}
+
+	link, err := netlink.LinkByName(tailscaleEnv.tailscaleNic)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get tailscale interface %s: %v", tailscaleEnv.tailscaleNic, err)
+	}
+
+	mtu := link.Attrs().MTU
+	if pathMTU := defaultRouteMTU(); pathMTU > 0 {
+		if limit := pathMTU - (config.DefaultPathMTU - config.MaxWireGuardMTU); limit < mtu {
+			mtu = limit
+		}
+	}
+	if mtu < config.MinTailnetMTU {
+		mtu = config.MinTailnetMTU
+	}
+	return mtu, nil
+}
+
+// reconcileInterfaceMTU 检查 HeadCNI 管理的 tailscale 接口 MTU，被其他程序修改时重置为 tailscale.mtu
+// tailscale.mtu 被清空时重置为 tailscaled 的默认 MTU；只在 daemon/router 模式的健康检查中调用，不处理主机的 tailscale0
+func (tsm *TailscaleService) reconcileInterfaceMTU() {
+	cfg := tsm.preparer.GetConfig()
+	if cfg.UsesUserspaceNetworking() {
+		return
+	}
+	tailscaleEnv := tsm.getTailscaleEnv()
+	if tailscaleEnv == nil || tailscaleEnv.tailscaleNic == "" {
+		return
+	}
+	name := tailscaleEnv.tailscaleNic
+	if err := checkInterfaceRemovable(name, cfg.Tailscale.ProtectedInterfacePrefixes); err != nil {
+		logging.Debugf("Skipping MTU reconcile of interface %s: %v", name, err)
+		return
+	}
+
+	link, err := netlink.LinkByName(name)
+	if err != nil {
+		logging.Debugf("Tailscale interface %s does not exist yet, skipping MTU reconcile", name)
+		return
+	}
+
+	observed, expected := link.Attrs().MTU, cfg.TailnetMTU()
+	monitoring.UpdateInterfaceMTU(name, observed)
+	if observed == expected {
+		return
+	}
+
+	if tsm.skipInDryRun("reset MTU of interface %s from %d to %d", name, observed, expected) {
+		return
+	}
+	if err := netlink.LinkSetMTU(link, expected); err != nil {
+		logging.Warnf("Failed to reset MTU of interface %s from %d to %d: %v", name, observed, expected, err)
+		monitoring.RecordError("tailscale", "mtu_reset")
+		return
+	}
+	logging.Warnf("MTU of interface %s drifted to %d, reset to %d", name, observed, expected)
+	monitoring.RecordInterfaceMTUReset(name)
+	monitoring.UpdateInterfaceMTU(name, expected)
+}
+
+// defaultRouteMTU 返回默认路由出口接口的 MTU，无法确定时返回 0
+func defaultRouteMTU() int {
+	routes, err := netlink.RouteList(nil, netlink.FAMILY_V4)
+	if err != nil {
+		return 0
+	}
+	for _, route := range routes {
+		if route.Dst != nil && route.Dst.String() != "0.0.0.0/0" {
+			continue
+		}
+		link, err := netlink.LinkByIndex(route.LinkIndex)
+		if err != nil {
+			continue
+		}
+		return link.Attrs().MTU
+	}
+	return 0
+}
+
+// syncPodMTU network.mtu 为 0（自动）时将计算出的 Pod MTU 写入 CNI env
+// 只影响之后创建的 Pod，已有 Pod 的 veth 保持原 MTU
+func (tsm *TailscaleService) syncPodMTU() {
+	if tsm.preparer.GetConfig().Network.MTU != 0 {
+		return
+	}
+
+	cniConfigManager := tsm.preparer.GetCNIConfigManager()
+	if cniConfigManager == nil {
+		return
+	}
+
+	mtu, err := tsm.computePodMTU()
+	if err != nil {
+		logging.Debugf("Skipping automatic pod MTU: %v", err)
+		return
+	}
+
+	changed, err := cniConfigManager.UpdateCniEnvMTU(mtu)
+	if err != nil {
+		logging.Warnf("Failed to update pod MTU in CNI env: %v", err)
+		return
+	}
+	if changed {
+		logging.Infof("Automatic pod MTU set to %d", mtu)
+	}
+}
+
 // getTailscaleInfo 获取 Tailscale IP 和节点密钥
 // [PUBLIC] getTailscaleInfo 获取 Tailscale 信息
 func (tsm *TailscaleService) getTailscaleInfo() (net.IP, string, error) {
 	// 获取 Tailscale 状态
-	ipnState, err := tsm.preparer.GetTailscaleClient().GetStatus(context.Background())
+	ctx, cancel := tsm.callContext()
+	defer cancel()
+	ipnState, err := tsm.preparer.GetTailscaleClient().GetStatus(ctx)
 	if err != nil {
 		return nil, "", fmt.Errorf("failed to get tailscale status: %v", err)
 	}
 
 	// 获取 Tailscale IP
-	tailscaleIP, err := tsm.preparer.GetTailscaleClient().GetIP(context.Background())
+	tailscaleIP, err := tsm.preparer.GetTailscaleClient().GetIP(ctx)
 	if err != nil {
 		return nil, "", fmt.Errorf("failed to get tailscale ip: %v", err)
 	}
@@ -1340,7 +2306,9 @@ func (tsm *TailscaleService) tryLoginWithExistingCredentials() error {
 	logging.Infof("尝试使用现有认证信息登录")
 
 	// 首先检查当前状态
-	status, err := tsm.preparer.GetTailscaleClient().GetStatus(context.Background())
+	ctx, cancel := tsm.callContext()
+	defer cancel()
+	status, err := tsm.preparer.GetTailscaleClient().GetStatusFresh(ctx)
 	if err != nil {
 		return fmt.Errorf("无法获取当前状态: %v", err)
 	}
@@ -1350,6 +2318,10 @@ func (tsm *TailscaleService) tryLoginWithExistingCredentials() error {
 	// 如果已经有有效的连接，直接返回成功
 	if status.BackendState == "Running" && status.Self != nil && len(status.Self.TailscaleIPs) > 0 {
 		logging.Infof("✅ 已经处于运行状态且有有效IP: %v", status.Self.TailscaleIPs)
+		// 不经过 UpWithOptions，需单独固定 DERP 区域
+		if regionID := tsm.preparer.GetConfig().Tailscale.PreferredDERP; regionID != 0 {
+			tsm.applyPreferredDERP(regionID)
+		}
 		return nil
 	}
 
@@ -1358,13 +2330,16 @@ func (tsm *TailscaleService) tryLoginWithExistingCredentials() error {
 		logging.Infof("检测到现有NodeKey，尝试启用运行状态")
 
 		// 使用 "auto" 模式尝试连接
-		err := tsm.preparer.GetTailscaleClient().UpWithOptions(context.Background(), tailscale.ClientOptions{
-			AcceptDNS:    tsm.preparer.GetConfig().Tailscale.AcceptDNS,
-			AuthKey:      "auto", // 使用已保存的认证信息
-			Hostname:     tsm.tailscaleEnv.hostName,
-			ControlURL:   tsm.preparer.GetConfig().Tailscale.URL,
-			AcceptRoutes: true,
-			ShieldsUp:    false,
+		err := tsm.preparer.GetTailscaleClient().UpWithOptions(tsm.ctx, tailscale.ClientOptions{
+			AcceptDNS:       tsm.preparer.GetConfig().Tailscale.AcceptDNS,
+			AuthKey:         "auto", // 使用已保存的认证信息
+			Hostname:        tsm.tailscaleEnv.hostName,
+			ControlURL:      tsm.preparer.GetConfig().Tailscale.URL,
+			ControlURLs:     tsm.preparer.GetConfig().Tailscale.FallbackURLs,
+			AcceptRoutes:    true,
+			ShieldsUp:       false,
+			AdvertiseRoutes: tsm.connectAdvertiseRoutes(),
+			PreferredDERP:   tsm.preparer.GetConfig().Tailscale.PreferredDERP,
 		})
 
 		if err == nil {
@@ -1384,18 +2359,22 @@ func (tsm *TailscaleService) tryLoginWithExistingCredentials() error {
 func (tsm *TailscaleService) tryLoginWithAuthKey() error {
 	logging.Infof("尝试使用认证密钥登录")
 
-	// 验证认证密钥是否仍然有效
-	if !tsm.validateAuthKey() {
+	// 读取一次密钥快照，避免后台刷新在校验和登录之间替换密钥
+	authKey, expiration := tsm.currentAuthKey()
+	if !authKeyValid(authKey, expiration) {
 		return fmt.Errorf("认证密钥已过期或无效")
 	}
 
-	err := tsm.preparer.GetTailscaleClient().UpWithOptions(context.Background(), tailscale.ClientOptions{
-		AcceptDNS:    tsm.preparer.GetConfig().Tailscale.AcceptDNS,
-		AuthKey:      tsm.authKey,
-		Hostname:     tsm.tailscaleEnv.hostName,
-		ControlURL:   tsm.preparer.GetConfig().Tailscale.URL,
-		AcceptRoutes: true,
-		ShieldsUp:    false,
+	err := tsm.preparer.GetTailscaleClient().UpWithOptions(tsm.ctx, tailscale.ClientOptions{
+		AcceptDNS:       tsm.preparer.GetConfig().Tailscale.AcceptDNS,
+		AuthKey:         authKey,
+		Hostname:        tsm.tailscaleEnv.hostName,
+		ControlURL:      tsm.preparer.GetConfig().Tailscale.URL,
+		ControlURLs:     tsm.preparer.GetConfig().Tailscale.FallbackURLs,
+		AcceptRoutes:    true,
+		ShieldsUp:       false,
+		AdvertiseRoutes: tsm.connectAdvertiseRoutes(),
+		PreferredDERP:   tsm.preparer.GetConfig().Tailscale.PreferredDERP,
 	})
 
 	if err == nil {
@@ -1407,22 +2386,28 @@ func (tsm *TailscaleService) tryLoginWithAuthKey() error {
 	return err
 }
 
-// refreshAuthKeyFromHeadscale 从 Headscale 获取新的认证密钥
-func (tsm *TailscaleService) refreshAuthKeyFromHeadscale() error {
-	logging.Infof("从 Headscale 刷新认证密钥")
-
-	// 获取当前节点信息以确定用户
-	node, err := tsm.preparer.GetK8sClient().GetCurrentNode()
-	if err != nil {
-		return fmt.Errorf("无法获取当前节点: %v", err)
-	}
-
+// newPreAuthKeyRequest 生成本节点的一次性预授权密钥请求
+func (tsm *TailscaleService) newPreAuthKeyRequest(nodeName string, ttl time.Duration) *headscale.CreatePreAuthKeyRequest {
 	// 从节点标签或注解中获取用户信息，如果没有则使用默认用户
 	user := tsm.preparer.GetConfig().Tailscale.User
 	if user == "" {
 		user = "default" // 默认用户
 	}
 
+	return &headscale.CreatePreAuthKeyRequest{
+		User:       user,
+		Reusable:   false, // 一次性使用
+		Ephemeral:  false, // 非临时节点
+		AclTags:    tsm.desiredNodeTags(nodeName),
+		Expiration: time.Now().Add(ttl),
+	}
+}
+
+// nodeTagPrefix HeadCNI 为每个节点添加的节点名标签前缀
+const nodeTagPrefix = "tag:node:"
+
+// desiredNodeTags 返回节点应有的 ACL 标签：配置的标签、autoApprovers 标签加上节点名标签
+func (tsm *TailscaleService) desiredNodeTags(nodeName string) []string {
 	// Headscale 要求 tag 必须以 "tag:" 开头
 	aclTags := make([]string, 0)
 	for _, tag := range tsm.preparer.GetConfig().Tailscale.Tags {
@@ -1432,29 +2417,40 @@ func (tsm *TailscaleService) refreshAuthKeyFromHeadscale() error {
 		aclTags = append(aclTags, tag)
 	}
 
+	// 注册时即带上 autoApprovers 标签，通告的路由由 Headscale 直接批准
+	if tag := tsm.preparer.GetConfig().Headscale.AutoApproverTag; tag != "" && !slices.Contains(aclTags, tag) {
+		aclTags = append(aclTags, tag)
+	}
+
 	// 添加节点标签，确保格式正确
-	if node.Name != "" {
-		nodeTag := fmt.Sprintf("tag:node:%s", node.Name)
-		aclTags = append(aclTags, nodeTag)
+	if nodeName != "" {
+		aclTags = append(aclTags, nodeTagPrefix+nodeName)
 	}
+	return aclTags
+}
 
-	logging.Infof("为用户创建预授权密钥: %s, 标签: %v", user, aclTags)
+// refreshAuthKeyFromHeadscale 从 Headscale 获取新的认证密钥
+func (tsm *TailscaleService) refreshAuthKeyFromHeadscale() error {
+	logging.Infof("从 Headscale 刷新认证密钥")
 
-	// 创建预授权密钥请求
-	preAuthKeyReq := &headscale.CreatePreAuthKeyRequest{
-		User:       user,
-		Reusable:   false, // 一次性使用
-		Ephemeral:  false, // 非临时节点
-		AclTags:    aclTags,
-		Expiration: time.Now().Add(24 * time.Hour), // 24小时有效期
+	// 获取当前节点信息以确定用户
+	node, err := tsm.preparer.GetK8sClient().GetCurrentNode()
+	if err != nil {
+		return fmt.Errorf("无法获取当前节点: %v", err)
 	}
 
+	// 创建预授权密钥请求
+	preAuthKeyReq := tsm.newPreAuthKeyRequest(node.Name, 24*time.Hour)
+	logging.Infof("为用户创建预授权密钥: %s, 标签: %v", preAuthKeyReq.User, preAuthKeyReq.AclTags)
+
 	// 从 Headscale 创建新的预授权密钥，带重试机制
 	var preAuthResp *headscale.CreatePreAuthKeyResponse
 	maxRetries := 3
 	for attempt := 1; attempt <= maxRetries; attempt++ {
 		var err error
-		preAuthResp, err = tsm.preparer.GetHeadscaleClient().CreatePreAuthKey(context.Background(), preAuthKeyReq)
+		ctx, cancel := tsm.callContext()
+		preAuthResp, err = tsm.preparer.GetHeadscaleClient().CreatePreAuthKey(ctx, preAuthKeyReq)
+		cancel()
 		if err == nil {
 			break
 		}
@@ -1464,7 +2460,11 @@ func (tsm *TailscaleService) refreshAuthKeyFromHeadscale() error {
 		}
 
 		logging.Warnf("尝试 %d 失败: %v, 5秒后重试...", attempt, err)
-		time.Sleep(5 * time.Second)
+		select {
+		case <-tsm.ctx.Done():
+			return fmt.Errorf("从 Headscale 创建预授权密钥被取消: %v", tsm.ctx.Err())
+		case <-time.After(5 * time.Second):
+		}
 	}
 
 	if preAuthResp.PreAuthKey.Key == "" {
@@ -1472,18 +2472,20 @@ func (tsm *TailscaleService) refreshAuthKeyFromHeadscale() error {
 	}
 
 	// 更新本地的 authKey
-	tsm.authKey = preAuthResp.PreAuthKey.Key
-	tsm.authKeyExpiredTime = preAuthResp.PreAuthKey.Expiration
-	logging.Infof("✅ 成功从 Headscale 刷新认证密钥，过期时间: %v", tsm.authKeyExpiredTime)
+	tsm.setAuthKey(preAuthResp.PreAuthKey.Key, preAuthResp.PreAuthKey.Expiration)
+	logging.Infof("✅ 成功从 Headscale 刷新认证密钥，过期时间: %v", preAuthResp.PreAuthKey.Expiration)
 
 	// 使用新的认证密钥尝试登录
-	return tsm.preparer.GetTailscaleClient().UpWithOptions(context.Background(), tailscale.ClientOptions{
-		AuthKey:      tsm.authKey,
-		Hostname:     tsm.tailscaleEnv.hostName,
-		ControlURL:   tsm.preparer.GetConfig().Tailscale.URL,
-		AcceptDNS:    tsm.preparer.GetConfig().Tailscale.AcceptDNS,
-		AcceptRoutes: true,
-		ShieldsUp:    false,
+	return tsm.preparer.GetTailscaleClient().UpWithOptions(tsm.ctx, tailscale.ClientOptions{
+		AuthKey:         preAuthResp.PreAuthKey.Key,
+		Hostname:        tsm.tailscaleEnv.hostName,
+		ControlURL:      tsm.preparer.GetConfig().Tailscale.URL,
+		ControlURLs:     tsm.preparer.GetConfig().Tailscale.FallbackURLs,
+		AcceptDNS:       tsm.preparer.GetConfig().Tailscale.AcceptDNS,
+		AcceptRoutes:    true,
+		ShieldsUp:       false,
+		AdvertiseRoutes: tsm.connectAdvertiseRoutes(),
+		PreferredDERP:   tsm.preparer.GetConfig().Tailscale.PreferredDERP,
 	})
 }
 
@@ -1494,158 +2496,510 @@ func (tsm *TailscaleService) checkLocalPodCIDRApplied() error {
 		return fmt.Errorf("failed to get current node: %v", err)
 	}
 
-	podLocalCIDR, err := tsm.preparer.GetK8sClient().Nodes().GetPodCIDR(node.Name)
+	podCIDRs, err := tsm.nodePodCIDRs(node.Name)
 	if err != nil {
 		return fmt.Errorf("failed to get Pod CIDR for node %s: %w", node.Name, err)
 	}
-	if podLocalCIDR == "" {
+	if len(podCIDRs) == 0 && !tsm.preparer.GetConfig().IsRouterMode() {
 		return fmt.Errorf("no Pod CIDR found for node %s", node.Name)
 	}
 
 	// 检查并应用路由
-	if err := tsm.ensureTailscaleRoute(podLocalCIDR); err != nil {
+	if err := tsm.ensureTailscaleRoute(podCIDRs...); err != nil {
 		return fmt.Errorf("failed to ensure Tailscale route: %v", err)
 	}
 
 	return nil
 }
 
-// ensureTailscaleRoute 确保 Tailscale 路由存在（检查并应用）
+// ensureTailscaleRoute 确保通告路由为节点的 Pod CIDR 加配置的额外路由，没有 Pod CIDR（router 模式）时只通告额外路由
+// 节点上已移除的 Pod CIDR 不在期望路由中，由收敛撤销
 // [PUBLIC] ensureTailscaleRoute 确保 Tailscale 路由存在
-func (tsm *TailscaleService) ensureTailscaleRoute(podLocalCIDR string) error {
-	// 获取 Tailscale 客户端
-	tailscaleClient := tsm.preparer.GetTailscaleClient()
-	if tailscaleClient == nil {
-		return fmt.Errorf("tailscale client not available")
-	}
-
-	// 获取 Tailscale 偏好设置
-	prefs, err := tailscaleClient.GetPrefs(context.Background())
+func (tsm *TailscaleService) ensureTailscaleRoute(podCIDRs ...string) error {
+	desired, err := tsm.desiredAdvertiseRoutes(podCIDRs)
 	if err != nil {
-		return fmt.Errorf("failed to get Tailscale preferences: %v", err)
+		return err
 	}
+	return tsm.reconcileAdvertisedRoutes(desired)
+}
 
-	// 检查是否已在通告路由中
-	for _, advertiseRoute := range prefs.AdvertiseRoutes {
-		if advertiseRoute.String() == podLocalCIDR {
-			logging.Debugf("Pod CIDR %s already exists in Tailscale advertised routes", podLocalCIDR)
-			return nil
+// desiredAdvertiseRoutes 返回应通告的路由：Pod CIDR 加配置的额外路由，podCIDRs 为空时只有额外路由
+func (tsm *TailscaleService) desiredAdvertiseRoutes(podCIDRs []string) ([]netip.Prefix, error) {
+	desired := make([]netip.Prefix, 0, len(podCIDRs))
+	for _, podCIDR := range podCIDRs {
+		podPrefix, err := netip.ParsePrefix(podCIDR)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR format %s: %v", podCIDR, err)
 		}
+		desired = append(desired, podPrefix.Masked())
 	}
-
-	// 如果不存在，则应用路由
-	logging.Infof("Pod CIDR %s not found in Tailscale routes, applying...", podLocalCIDR)
-	return tsm.applyTailscaleRoute(podLocalCIDR)
+	return append(desired, extraAdvertiseRoutes(tsm.preparer.GetConfig())...), nil
 }
 
-// applyTailscaleRoute 在 Tailscale 中应用指定 CIDR 的路由（合并现有路由）
-// [PUBLIC] applyTailscaleRoute 应用 Tailscale 路由
-func (tsm *TailscaleService) applyTailscaleRoute(podLocalCIDR string) error {
-	logging.Infof("Applying Tailscale route for CIDR: %s", podLocalCIDR)
-
-	// 获取 Tailscale 客户端
-	tailscaleClient := tsm.preparer.GetTailscaleClient()
-	if tailscaleClient == nil {
-		return fmt.Errorf("Tailscale client not available")
+// connectAdvertiseRoutes 返回登录时随首次偏好通告的路由（tailscale.advertiseOnConnect）
+// 登录偏好会整体替换通告路由，因此保留当前已通告的路由，多余的 HeadCNI 路由由连接后的收敛撤销
+// 未开启、只读模式或 Pod CIDR 尚不可知时返回 nil，由连接后的路由设置通告
+func (tsm *TailscaleService) connectAdvertiseRoutes() []string {
+	cfg := tsm.preparer.GetConfig()
+	if !cfg.Tailscale.AdvertiseOnConnect || tsm.readOnly.Load() {
+		return nil
 	}
 
-	// 解析新的 CIDR
-	newPrefix, err := netip.ParsePrefix(podLocalCIDR)
-	if err != nil {
-		return fmt.Errorf("invalid CIDR format %s: %v", podLocalCIDR, err)
+	podCIDRs, err := tsm.nodePodCIDRs(tsm.hostname)
+	if err != nil || (len(podCIDRs) == 0 && !cfg.IsRouterMode()) {
+		logging.Infof("Pod CIDR of node %s not known yet, advertising routes after connect", tsm.hostname)
+		return nil
+	}
+	desired, err := tsm.desiredAdvertiseRoutes(podCIDRs)
+	if err != nil || len(desired) == 0 {
+		return nil
 	}
 
-	// 获取当前已通告的路由
-	prefs, err := tailscaleClient.GetPrefs(context.Background())
+	ctx, cancel := tsm.callContext()
+	defer cancel()
+	prefs, err := tsm.preparer.GetTailscaleClient().GetPrefs(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to get current preferences: %v", err)
+		logging.Warnf("Failed to get Tailscale preferences, advertising routes after connect: %v", err)
+		return nil
 	}
+	_, current, routes := diffPrefixes(prefs.AdvertiseRoutes, desired)
+	routes = append(routes, current...)
 
-	// 合并现有路由和新路由
-	mergedRoutes := make([]netip.Prefix, len(prefs.AdvertiseRoutes)+1)
-	copy(mergedRoutes, prefs.AdvertiseRoutes)
-	mergedRoutes[len(prefs.AdvertiseRoutes)] = newPrefix
-
-	logging.Infof("Merging routes: existing %d routes + new route %s = total %d routes",
-		len(prefs.AdvertiseRoutes), podLocalCIDR, len(mergedRoutes))
+	// 先登记再通告，与 reconcileAdvertisedRoutes 一致
+	tsm.managedRoutes.add(desired...)
 
-	// 应用合并后的路由
-	if err := tailscaleClient.AdvertiseRoutes(context.Background(), mergedRoutes...); err != nil {
-		return fmt.Errorf("failed to advertise merged routes: %v", err)
+	advertise := make([]string, 0, len(routes))
+	for _, route := range routes {
+		advertise = append(advertise, route.String())
 	}
-
-	logging.Infof("Successfully applied Tailscale route for CIDR: %s (merged with existing routes)", podLocalCIDR)
-	return nil
+	logging.Infof("Advertising routes at connect time: %v", advertise)
+	return advertise
 }
 
-// [PUBLIC] checkHeadscaleRoutes 检查 Headscale 路由状态
-func (tsm *TailscaleService) checkHeadscaleRoutes() error {
+// reconcileAdvertisedRoutes 将通告路由收敛到 desired
+// 与当前偏好一致时不调用 EditPrefs，避免周期性健康检查反复修改偏好
+// 只删除 HeadCNI 通告过的路由，管理员手动通告的路由原样保留
+func (tsm *TailscaleService) reconcileAdvertisedRoutes(desired []netip.Prefix) error {
+	tailscaleClient := tsm.preparer.GetTailscaleClient()
+	if tailscaleClient == nil {
+		return fmt.Errorf("tailscale client not available")
+	}
+
+	ctx, cancel := tsm.callContext()
+	defer cancel()
+	prefs, err := tailscaleClient.GetPrefs(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get Tailscale preferences: %v", err)
+	}
+
+	added, stale, routes := diffPrefixes(prefs.AdvertiseRoutes, desired)
+	var removed []netip.Prefix
+	for _, route := range stale {
+		if tsm.managedRoutes.managed(route) {
+			removed = append(removed, route)
+			continue
+		}
+		logging.Debugf("Keeping route %s not advertised by HeadCNI", route)
+		routes = append(routes, route)
+	}
+	if len(added) == 0 && len(removed) == 0 {
+		// 升级前或由其他路径通告的期望路由同样登记，之后从配置中删除时才能撤销
+		tsm.managedRoutes.add(desired...)
+		logging.Debugf("Advertised routes already up to date: %v", routes)
+		return nil
+	}
+
+	if tsm.skipInDryRun("advertise routes %v (add %v, remove %v)", routes, added, removed) {
+		return nil
+	}
+
+	// 先登记再通告，通告中途失败时下次仍可撤销
+	tsm.managedRoutes.add(desired...)
+
+	logging.Infof("Reconciling advertised routes: add %v, remove %v", added, removed)
+	if err := tailscaleClient.AdvertiseRoutes(ctx, routes...); err != nil {
+		return fmt.Errorf("failed to advertise routes %v: %v", routes, err)
+	}
+	tsm.managedRoutes.remove(removed...)
+
+	logging.Infof("Advertised routes updated: %v", routes)
+	return nil
+}
+
+// advertiseRoute 在当前通告的路由中追加 prefix，已通告时只登记
+// CNI 服务和 Pod 监控修复路由时调用，与 reconcileAdvertisedRoutes 一样先登记再通告
+func (tsm *TailscaleService) advertiseRoute(prefix netip.Prefix) error {
+	tailscaleClient := tsm.preparer.GetTailscaleClient()
+	if tailscaleClient == nil {
+		return fmt.Errorf("tailscale client not available")
+	}
+
+	ctx, cancel := tsm.callContext()
+	defer cancel()
+	prefs, err := tailscaleClient.GetPrefs(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get Tailscale preferences: %v", err)
+	}
+
+	added, _, routes := diffPrefixes(prefs.AdvertiseRoutes, append(append([]netip.Prefix(nil), prefs.AdvertiseRoutes...), prefix))
+	if len(added) == 0 {
+		tsm.managedRoutes.add(prefix)
+		logging.Infof("Route %s already advertised", prefix)
+		return nil
+	}
+
+	if tsm.skipInDryRun("advertise routes %v (add %v)", routes, added) {
+		return nil
+	}
+	tsm.managedRoutes.add(prefix)
+
+	logging.Infof("Advertising route %s along with %d existing routes", prefix, len(prefs.AdvertiseRoutes))
+	if err := tailscaleClient.AdvertiseRoutes(ctx, routes...); err != nil {
+		return fmt.Errorf("failed to advertise routes %v: %v", routes, err)
+	}
+	return nil
+}
+
+// diffPrefixes 对比当前和期望的路由，返回需要新增、删除的路由以及去重后的期望集合
+func diffPrefixes(current, desired []netip.Prefix) (added, removed, routes []netip.Prefix) {
+	want := make(map[netip.Prefix]bool, len(desired))
+	for _, route := range desired {
+		if !want[route] {
+			want[route] = true
+			routes = append(routes, route)
+		}
+	}
+
+	have := make(map[netip.Prefix]bool, len(current))
+	for _, route := range current {
+		have[route] = true
+		if !want[route] {
+			removed = append(removed, route)
+		}
+	}
+	for _, route := range routes {
+		if !have[route] {
+			added = append(added, route)
+		}
+	}
+	return added, removed, routes
+}
+
+// [PUBLIC] checkHeadscaleRoutes 检查 Headscale 路由状态
+func (tsm *TailscaleService) checkHeadscaleRoutes() error {
 	// 获取当前节点信息
 	node, err := tsm.preparer.GetK8sClient().GetCurrentNode()
 	if err != nil {
 		return fmt.Errorf("failed to get current node: %w", err)
 	}
 
-	podLocalCIDR, err := tsm.preparer.GetK8sClient().Nodes().GetPodCIDR(node.Name)
+	podCIDRs, err := tsm.nodePodCIDRs(node.Name)
 	if err != nil {
 		return fmt.Errorf("failed to get Pod CIDR for node %s: %w", node.Name, err)
 	}
 
 	// 检查 Headscale 路由
-	routes, err := tsm.preparer.GetHeadscaleClient().GetRoutes(context.Background())
+	ctx, cancel := tsm.callContext()
+	defer cancel()
+	routes, err := tsm.preparer.GetHeadscaleClient().GetRoutes(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to get headscale routes: %v", err)
 	}
 
-	// 查找本地 Pod CIDR 路由
-	for _, route := range routes.Routes {
-		if route.Prefix == podLocalCIDR {
-			if !route.Enabled {
-				// 启用路由
-				if err := tsm.preparer.GetHeadscaleClient().EnableRoute(context.Background(), route.ID); err != nil {
-					return fmt.Errorf("failed to enable route %s: %v", route.Prefix, err)
-				}
-				logging.Infof("Enabled route for local Pod CIDR: %s", podLocalCIDR)
-			}
-			return nil
+	// 启用本节点通告的额外路由
+	if err := tsm.enableExtraRoutes(ctx, routes.Routes); err != nil {
+		logging.Warnf("Failed to enable extra routes: %v", err)
+	}
+	tsm.updateRouteApprovalCondition(ctx, routes.Routes, podCIDRs)
+	if len(podCIDRs) == 0 {
+		return nil
+	}
+
+	tailscaleIP, err := tsm.preparer.GetTailscaleClient().GetIP(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get tailscale IP: %v", err)
+	}
+
+	var missing []string
+	for _, podCIDR := range podCIDRs {
+		found, err := tsm.checkPodCIDRRoute(ctx, podCIDR, tailscaleIP.String(), routes.Routes)
+		if err != nil {
+			return err
+		}
+		if !found {
+			missing = append(missing, podCIDR)
 		}
 	}
+	if len(missing) > 0 {
+		return fmt.Errorf("route for local Pod CIDR not found: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
 
-	return fmt.Errorf("route for local Pod CIDR not found: %s", podLocalCIDR)
+// checkPodCIDRRoute 查找本节点的 Pod CIDR 路由并在未启用时启用，其他节点通告的同一网段不算；返回是否找到该路由
+func (tsm *TailscaleService) checkPodCIDRRoute(ctx context.Context, podCIDR, tailscaleIP string, routes []headscale.Route) (bool, error) {
+	for _, route := range routes {
+		if route.Prefix != podCIDR || !nodeHasIP(route.Node, tailscaleIP) {
+			continue
+		}
+		if tsm.yieldConflictingRoute(route, routes) || route.Enabled {
+			return true, nil
+		}
+		if tsm.awaitRouteApproval(route) {
+			return true, nil
+		}
+		if tsm.skipInDryRun("enable route %s (%s) in Headscale", route.Prefix, route.ID) {
+			return true, nil
+		}
+		// 启用路由
+		if err := tsm.preparer.GetHeadscaleClient().EnableRoute(ctx, route.ID); err != nil {
+			return true, fmt.Errorf("failed to enable route %s: %v", route.Prefix, err)
+		}
+		logging.Infof("Enabled route for local Pod CIDR: %s", podCIDR)
+		return true, nil
+	}
+	return false, nil
+}
+
+// enableExtraRoutes 在 Headscale 中批准本节点通告的额外路由
+// 同一网段可能由多个子网路由器通告，只处理本节点的路由
+func (tsm *TailscaleService) enableExtraRoutes(ctx context.Context, routes []headscale.Route) error {
+	extraRoutes := extraAdvertiseRoutes(tsm.preparer.GetConfig())
+	if len(extraRoutes) == 0 {
+		return nil
+	}
+
+	tailscaleIP, err := tsm.preparer.GetTailscaleClient().GetIP(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get tailscale IP: %v", err)
+	}
+
+	wanted := make(map[string]bool, len(extraRoutes))
+	for _, route := range extraRoutes {
+		wanted[route.String()] = true
+	}
+
+	for _, route := range routes {
+		if !wanted[route.Prefix] || route.Enabled || !nodeHasIP(route.Node, tailscaleIP.String()) {
+			continue
+		}
+		if tsm.awaitRouteApproval(route) {
+			continue
+		}
+		if tsm.skipInDryRun("enable extra route %s (%s) in Headscale", route.Prefix, route.ID) {
+			continue
+		}
+		if err := tsm.preparer.GetHeadscaleClient().EnableRoute(ctx, route.ID); err != nil {
+			return fmt.Errorf("failed to enable route %s: %v", route.Prefix, err)
+		}
+		logging.Infof("Enabled extra route: %s", route.Prefix)
+	}
+	return nil
+}
+
+// yieldConflictingRoute 检查本节点的 Pod CIDR 路由是否同时被其他节点通告，更新冲突指标和健康条件
+// 返回 true 表示按 tailscale.routeConflictPolicy 不应启用本节点的路由：策略为 yield、
+// 本节点不是主路由且其他节点的同一前缀路由已启用
+func (tsm *TailscaleService) yieldConflictingRoute(ours headscale.Route, routes []headscale.Route) bool {
+	var others []headscale.Route
+	for _, route := range routes {
+		if route.Prefix == ours.Prefix && route.Advertised && route.Node.ID != ours.Node.ID {
+			others = append(others, route)
+		}
+	}
+
+	if len(others) == 0 {
+		tsm.recordRouteConflict(ours.Prefix, "")
+		return false
+	}
+
+	otherNodes := make([]string, 0, len(others))
+	serving := false
+	for _, route := range others {
+		otherNodes = append(otherNodes, fmt.Sprintf("%s (%s, route %s, enabled=%t, primary=%t)",
+			route.Node.ID, route.Node.GivenName, route.ID, route.Enabled, route.IsPrimary))
+		serving = serving || route.Enabled
+	}
+	message := fmt.Sprintf("prefix %s is advertised by this node %s (route %s) and by node(s) %s",
+		ours.Prefix, ours.Node.ID, ours.ID, strings.Join(otherNodes, ", "))
+	logging.Warnf("ROUTE CONFLICT: %s; traffic for this prefix will flap between the nodes until one of them stops advertising it", message)
+	tsm.recordRouteConflict(ours.Prefix, message)
+
+	cfg := tsm.preparer.GetConfig()
+	if cfg == nil || cfg.Tailscale.RouteConflictPolicy != config.RouteConflictPolicyYield || ours.IsPrimary || !serving {
+		return false
+	}
+	if !ours.Enabled {
+		logging.Warnf("Not enabling route %s (%s): another node already serves it and tailscale.routeConflictPolicy is %s",
+			ours.Prefix, ours.ID, config.RouteConflictPolicyYield)
+	}
+	return true
+}
+
+// recordRouteConflict 记录或清除（message 为空）某个前缀的路由冲突，按全部冲突前缀更新冲突指标和健康条件
+// 节点有多个 Pod CIDR 时，一个前缀的冲突消除不会清除其他前缀的冲突
+func (tsm *TailscaleService) recordRouteConflict(prefix, message string) {
+	tsm.routeConflictsMu.Lock()
+	defer tsm.routeConflictsMu.Unlock()
+	if tsm.routeConflicts == nil {
+		tsm.routeConflicts = make(map[string]string)
+	}
+	if message == "" {
+		delete(tsm.routeConflicts, prefix)
+	} else {
+		tsm.routeConflicts[prefix] = message
+	}
+
+	monitoring.UpdateRouteConflicts(len(tsm.routeConflicts))
+	healthMgr := GetGlobalHealthManager()
+	if len(tsm.routeConflicts) == 0 {
+		healthMgr.ClearCondition(HealthConditionRouteConflict)
+		return
+	}
+	messages := make([]string, 0, len(tsm.routeConflicts))
+	for _, conflict := range tsm.routeConflicts {
+		messages = append(messages, conflict)
+	}
+	sort.Strings(messages)
+	healthMgr.SetCondition(HealthConditionRouteConflict, strings.Join(messages, "; "))
+}
+
+// nodeHasIP 判断 Headscale 节点是否拥有指定的 tailnet 地址
+func nodeHasIP(node headscale.Node, ip string) bool {
+	for _, nodeIP := range node.IPAddresses {
+		if nodeIP == ip {
+			return true
+		}
+	}
+	return false
+}
+
+// extraAdvertiseRoutes 解析 tailscale.advertiseExtraRoutes，忽略无法解析的条目
+func extraAdvertiseRoutes(cfg *config.Config) []netip.Prefix {
+	if cfg == nil {
+		return nil
+	}
+
+	var routes []netip.Prefix
+	for _, value := range cfg.Tailscale.AdvertiseExtraRoutes {
+		route, err := netip.ParsePrefix(strings.TrimSpace(value))
+		if err != nil {
+			logging.Warnf("Ignoring invalid tailscale.advertiseExtraRoutes entry %q: %v", value, err)
+			continue
+		}
+		routes = append(routes, route.Masked())
+	}
+	return routes
 }
 
 // [PUBLIC] manageHeadscaleRoutes 管理 Headscale 路由（合并配置和管理的功能）
-func (tsm *TailscaleService) manageHeadscaleRoutes(podLocalCIDR, tailscaleIP string) error {
-	logging.Infof("Managing Headscale routes for CIDR: %s, IP: %s", podLocalCIDR, tailscaleIP)
+func (tsm *TailscaleService) manageHeadscaleRoutes(podCIDRs []string, tailscaleIP string) error {
+	logging.Infof("Managing Headscale routes for CIDRs: %v, IP: %s", podCIDRs, tailscaleIP)
+	wanted := make(map[string]bool, len(podCIDRs))
+	for _, podCIDR := range podCIDRs {
+		wanted[podCIDR] = true
+	}
 
 	// 获取所有路由
-	routes, err := tsm.preparer.GetHeadscaleClient().GetRoutes(context.Background())
+	ctx, cancel := tsm.callContext()
+	routes, err := tsm.preparer.GetHeadscaleClient().GetRoutes(ctx)
+	cancel()
 	if err != nil {
 		return fmt.Errorf("failed to get routes: %v", err)
 	}
 
-	// 处理每个路由
+	// 只处理本节点通告的 Pod CIDR 路由，其他节点的路由（包括同一网段的陈旧注册）由各自的 daemon 负责
 	for _, route := range routes.Routes {
-		if route.Prefix == podLocalCIDR {
-			if route.Enabled {
-				logging.Infof("Route %s is already enabled for our node", route.Prefix)
-			} else {
-				logging.Infof("Enabling route %s for our node", route.Prefix)
-				if err := tsm.preparer.GetHeadscaleClient().EnableRoute(context.Background(), route.ID); err != nil {
-					logging.Warnf("Failed to enable route %s: %v", route.ID, err)
-				}
-			}
-		} else {
-			logging.Infof("Enabling route %s for our node", route.Prefix)
-			if err := tsm.preparer.GetHeadscaleClient().EnableRoute(context.Background(), route.ID); err != nil {
-				logging.Warnf("Failed to enable route %s: %v", route.ID, err)
-			}
+		if !wanted[route.Prefix] || !nodeHasIP(route.Node, tailscaleIP) {
+			continue
+		}
+		if tsm.yieldConflictingRoute(route, routes.Routes) {
+			continue
+		}
+		if route.Enabled {
+			logging.Infof("Route %s is already enabled for our node", route.Prefix)
+			continue
+		}
+		if tsm.awaitRouteApproval(route) {
+			continue
+		}
+		logging.Infof("Enabling route %s for our node", route.Prefix)
+		if err := tsm.enableHeadscaleRoute(route.ID); err != nil {
+			logging.Warnf("Failed to enable route %s: %v", route.ID, err)
 		}
 	}
 
+	// 启用本节点通告的额外路由
+	ctx, cancel = tsm.callContext()
+	defer cancel()
+	if err := tsm.enableExtraRoutes(ctx, routes.Routes); err != nil {
+		logging.Warnf("Failed to enable extra routes: %v", err)
+	}
+	tsm.updateRouteApprovalCondition(ctx, routes.Routes, podCIDRs)
+
 	return nil
 }
 
+// [PUBLIC] enableHeadscaleRoute 启用 Headscale 路由（单次调用超时）
+func (tsm *TailscaleService) enableHeadscaleRoute(routeID string) error {
+	if tsm.skipInDryRun("enable route %s in Headscale", routeID) {
+		return nil
+	}
+	ctx, cancel := tsm.callContext()
+	defer cancel()
+	return tsm.preparer.GetHeadscaleClient().EnableRoute(ctx, routeID)
+}
+
+// awaitRouteApproval 关闭 headscale.autoApproveRoutes 或设置 headscale.autoApproverTag 时返回 true，路由只通告不批准，等待外部（管理员或 ACL autoApprovers）启用
+func (tsm *TailscaleService) awaitRouteApproval(route headscale.Route) bool {
+	if tsm.preparer.GetConfig().AutoApproveRoutesEnabled() {
+		return false
+	}
+	logging.Debugf("Route %s (%s) is awaiting approval in Headscale (route approval by the daemon is disabled)", route.Prefix, route.ID)
+	return true
+}
+
+// updateRouteApprovalCondition 关闭 headscale.autoApproveRoutes 或设置 headscale.autoApproverTag 时，把本节点已通告但尚未启用的 Pod CIDR 和额外路由
+// 记录为 AwaitingRouteApproval 健康条件；开启自动批准或全部路由已启用时清除该条件
+func (tsm *TailscaleService) updateRouteApprovalCondition(ctx context.Context, routes []headscale.Route, podCIDRs []string) {
+	healthMgr := GetGlobalHealthManager()
+	if tsm.preparer.GetConfig().AutoApproveRoutesEnabled() {
+		healthMgr.ClearCondition(HealthConditionAwaitingRouteApproval)
+		return
+	}
+
+	wanted := make(map[string]bool, len(podCIDRs))
+	for _, podCIDR := range podCIDRs {
+		wanted[podCIDR] = true
+	}
+	for _, route := range extraAdvertiseRoutes(tsm.preparer.GetConfig()) {
+		wanted[route.String()] = true
+	}
+	if len(wanted) == 0 {
+		healthMgr.ClearCondition(HealthConditionAwaitingRouteApproval)
+		return
+	}
+
+	tailscaleIP, err := tsm.preparer.GetTailscaleClient().GetIP(ctx)
+	if err != nil {
+		logging.Warnf("Failed to get tailscale IP for route approval status: %v", err)
+		return
+	}
+
+	var awaiting []string
+	for _, route := range routes {
+		if wanted[route.Prefix] && !route.Enabled && nodeHasIP(route.Node, tailscaleIP.String()) {
+			awaiting = append(awaiting, route.Prefix)
+		}
+	}
+	if len(awaiting) == 0 {
+		healthMgr.ClearCondition(HealthConditionAwaitingRouteApproval)
+		return
+	}
+	sort.Strings(awaiting)
+	healthMgr.SetCondition(HealthConditionAwaitingRouteApproval, fmt.Sprintf("routes awaiting approval in Headscale: %s", strings.Join(awaiting, ", ")))
+}
+
 // uploadTailscaleInfo 上传 Tailscale 信息到节点注解
 // [PUBLIC] uploadTailscaleInfo 上传 Tailscale 信息到 Headscale
 func (tsm *TailscaleService) uploadTailscaleInfo(tailscaleIP net.IP, nodeKey string) error {
@@ -1653,7 +3007,7 @@ func (tsm *TailscaleService) uploadTailscaleInfo(tailscaleIP net.IP, nodeKey str
 	if err != nil {
 		return fmt.Errorf("failed to get current node: %v", err)
 	}
-	podLocalCIDR, err := tsm.preparer.GetK8sClient().Nodes().GetPodCIDR(node.Name)
+	podCIDRs, err := tsm.nodePodCIDRs(node.Name)
 	if err != nil {
 		return fmt.Errorf("failed to get Pod CIDR for node %s: %w", node.Name, err)
 	}
@@ -1662,12 +3016,63 @@ func (tsm *TailscaleService) uploadTailscaleInfo(tailscaleIP net.IP, nodeKey str
 	annotations := map[string]string{
 		constants.HeadcniTailscaleIPAnnotationKey: tailscaleIP.String(),
 		constants.HeadcniNodeKeyAnnotationKey:     nodeKey,
-		constants.HeadcniPodCIDRAnnotationKey:     podLocalCIDR,
+	}
+	// 记录全部 Pod CIDR（逗号分隔），第一个是用于 IPAM 的网段
+	if len(podCIDRs) > 0 {
+		annotations[constants.HeadcniPodCIDRAnnotationKey] = strings.Join(podCIDRs, ",")
 	}
 
 	return tsm.preparer.GetK8sClient().Nodes().UpdateAnnotations(node.Name, annotations)
 }
 
+// uploadDaemonInfo 上传守护进程版本、生效配置哈希和运行模式到节点注解
+func (tsm *TailscaleService) uploadDaemonInfo() error {
+	cfg := tsm.preparer.GetConfig()
+	if cfg == nil {
+		return fmt.Errorf("configuration not available")
+	}
+
+	configHash, err := cfg.Hash()
+	if err != nil {
+		return fmt.Errorf("failed to hash config: %v", err)
+	}
+
+	nodeName, err := tsm.preparer.GetK8sClient().GetCurrentNodeName()
+	if err != nil {
+		return fmt.Errorf("failed to get current node name: %v", err)
+	}
+
+	annotations := map[string]string{
+		constants.HeadcniVersionAnnotationKey:    Version,
+		constants.HeadcniConfigHashAnnotationKey: configHash,
+		constants.HeadcniModeAnnotationKey:       cfg.Tailscale.Mode,
+	}
+
+	return tsm.preparer.GetK8sClient().Nodes().UpdateAnnotations(nodeName, annotations)
+}
+
+// removeNodeAnnotations 删除 daemon 写入的节点注解，失败只记录日志
+func (tsm *TailscaleService) removeNodeAnnotations() {
+	k8sClient := tsm.preparer.GetK8sClient()
+	nodeName, err := k8sClient.GetCurrentNodeName()
+	if err != nil {
+		logging.Warnf("Failed to remove node annotations: %v", err)
+		return
+	}
+	if err := k8sClient.Nodes().RemoveAnnotations(nodeName, constants.HeadcniManagedNodeAnnotationKeys); err != nil {
+		logging.Warnf("Failed to remove HeadCNI annotations from node %s: %v", nodeName, err)
+		return
+	}
+	logging.Infof("Removed HeadCNI annotations from node %s", nodeName)
+}
+
+// reportDaemonInfo 上传守护进程信息，失败只记录日志
+func (tsm *TailscaleService) reportDaemonInfo() {
+	if err := tsm.uploadDaemonInfo(); err != nil {
+		logging.Warnf("Failed to upload daemon info: %v", err)
+	}
+}
+
 // [PUBLIC] GetState 获取服务状态
 func (tsm *TailscaleService) GetState() TailscaleServiceState {
 	tsm.stateMu.RLock()
@@ -1680,12 +3085,17 @@ func (tsm *TailscaleService) GetServiceInfo() map[string]interface{} {
 	tsm.stateMu.RLock()
 	defer tsm.stateMu.RUnlock()
 
+	// error 序列化为 JSON 时没有内容，转换为字符串
+	lastError := ""
+	if tsm.lastError != nil {
+		lastError = tsm.lastError.Error()
+	}
 	return map[string]interface{}{
 		"state":       string(tsm.state),
 		"hostname":    tsm.hostname,
 		"serviceName": tsm.serviceName,
 		"startTime":   tsm.startTime,
-		"lastError":   tsm.lastError,
+		"lastError":   lastError,
 		"retryCount":  tsm.retryCount,
 	}
 }
@@ -1693,13 +3103,15 @@ func (tsm *TailscaleService) GetServiceInfo() map[string]interface{} {
 // [PUBLIC] getCurrentNodeID 获取当前节点 ID
 func (tsm *TailscaleService) getCurrentNodeID() (string, error) {
 	// 获取当前节点的 Tailscale IP
-	tailscaleIP, err := tsm.preparer.GetTailscaleClient().GetIP(context.Background())
+	ctx, cancel := tsm.callContext()
+	defer cancel()
+	tailscaleIP, err := tsm.preparer.GetTailscaleClient().GetIP(ctx)
 	if err != nil {
 		return "", fmt.Errorf("failed to get tailscale IP: %v", err)
 	}
 
 	// 从 Headscale 获取所有节点，找到匹配的节点
-	nodes, err := tsm.preparer.GetHeadscaleClient().ListNodes(context.Background(), "")
+	nodes, err := tsm.preparer.GetHeadscaleClient().ListNodes(ctx, "")
 	if err != nil {
 		return "", fmt.Errorf("failed to list nodes: %v", err)
 	}
@@ -1715,22 +3127,157 @@ func (tsm *TailscaleService) getCurrentNodeID() (string, error) {
 	return "", fmt.Errorf("node with IP %s not found in Headscale", tailscaleIP.String())
 }
 
+// [PUBLIC] reconcileDuplicateNodes 删除与当前节点同主机名的离线陈旧注册
+// 节点重新注册后 Headscale 中会残留旧节点，影响 getCurrentNodeID 按 IP 匹配的结果
+func (tsm *TailscaleService) reconcileDuplicateNodes() error {
+	tailscaleEnv := tsm.getTailscaleEnv()
+	if tailscaleEnv == nil || tailscaleEnv.hostName == "" {
+		return fmt.Errorf("tailscale hostname is unknown")
+	}
+
+	nodeID, err := tsm.getCurrentNodeID()
+	if err != nil {
+		return fmt.Errorf("failed to get current node ID: %v", err)
+	}
+
+	ctx, cancel := tsm.callContext()
+	defer cancel()
+
+	return tsm.preparer.GetHeadscaleClient().ReconcileDuplicateNodes(ctx, tailscaleEnv.hostName, nodeID)
+}
+
+// [PUBLIC] reconcileNodeTags 将 Headscale 节点的 ForcedTags 收敛到配置的标签
+// 预授权密钥只在创建时带上标签，节点加入后修改配置不会生效，因此连接后对比并调用 SetNodeTags
+// 只增删 HeadCNI 管理的标签（配置的标签、节点名标签和 managedTagPrefix 前缀的标签），其他标签保持不变
+// retired 为 HeadCNI 之前添加、已从配置中移除的标签（如修改前的 autoApproverTag），不在期望列表中时一并移除
+func (tsm *TailscaleService) reconcileNodeTags(retired ...string) error {
+	nodeName, err := tsm.preparer.GetK8sClient().GetCurrentNodeName()
+	if err != nil {
+		return fmt.Errorf("failed to get current node name: %v", err)
+	}
+
+	nodeID, err := tsm.getCurrentNodeID()
+	if err != nil {
+		return fmt.Errorf("failed to get current node ID: %v", err)
+	}
+
+	ctx, cancel := tsm.callContext()
+	defer cancel()
+
+	nodeResp, err := tsm.preparer.GetHeadscaleClient().GetNode(ctx, nodeID)
+	if err != nil {
+		return fmt.Errorf("failed to get node %s: %v", nodeID, err)
+	}
+	current := nodeResp.Node.ForcedTags
+
+	desired := tsm.desiredNodeTags(nodeName)
+	tags, changed := mergeManagedTags(current, desired, tsm.preparer.GetConfig().Tailscale.ManagedTagPrefix, retired)
+	if !changed {
+		logging.Debugf("Headscale node %s tags already up to date: %v", nodeID, current)
+		return nil
+	}
+
+	if _, err := tsm.preparer.GetHeadscaleClient().SetNodeTags(ctx, nodeID, tags); err != nil {
+		return fmt.Errorf("failed to set tags on node %s: %v", nodeID, err)
+	}
+	logging.Infof("Updated headscale node %s tags: %v -> %v", nodeID, current, tags)
+	return nil
+}
+
+// mergeManagedTags 用期望标签替换当前标签中由 HeadCNI 管理的部分，返回结果以及是否有变化
+// 节点名标签、managedPrefix 前缀的标签和 retired 中的标签视为 HeadCNI 添加的，不在期望列表中时移除
+func mergeManagedTags(current, desired []string, managedPrefix string, retired []string) ([]string, bool) {
+	wanted := make(map[string]bool, len(desired))
+	for _, tag := range desired {
+		wanted[tag] = true
+	}
+	managed := func(tag string) bool {
+		return strings.HasPrefix(tag, nodeTagPrefix) || (managedPrefix != "" && strings.HasPrefix(tag, managedPrefix)) || slices.Contains(retired, tag)
+	}
+
+	tags := make([]string, 0, len(current)+len(desired))
+	seen := make(map[string]bool, len(current)+len(desired))
+	changed := false
+	for _, tag := range current {
+		if seen[tag] {
+			continue
+		}
+		if managed(tag) && !wanted[tag] {
+			changed = true
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	for _, tag := range desired {
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+			changed = true
+		}
+	}
+	return tags, changed
+}
+
 // [PUBLIC] setupClientRoutePreferences 设置客户端路由偏好
 func (tsm *TailscaleService) setupClientRoutePreferences() error {
 	logging.Infof("Setting up client route preferences")
 
 	// 设置接受路由
-	if err := tsm.preparer.GetTailscaleClient().AcceptRoutes(context.Background()); err != nil {
+	ctx, cancel := tsm.callContext()
+	defer cancel()
+	if err := tsm.acceptRoutes(ctx); err != nil {
 		return fmt.Errorf("failed to accept routes: %v", err)
 	}
+	if err := tsm.reconcileAcceptedRoutes(); err != nil {
+		logging.Warnf("Failed to reconcile accepted routes: %v", err)
+	}
 
 	logging.Infof("Client route preferences set successfully")
 	return nil
 }
 
+// applyPreferredDERP 固定本节点的 DERP home 区域，0 恢复自动选择
+// 区域不在 DERP 地图中或固定失败时只记录告警，不影响连接
+func (tsm *TailscaleService) applyPreferredDERP(regionID int) {
+	ctx, cancel := tsm.callContext()
+	defer cancel()
+
+	err := tsm.preparer.GetTailscaleClient().SetPreferredDERP(ctx, regionID)
+	switch {
+	case errors.Is(err, tailscale.ErrUnknownDERPRegion):
+		logging.Warnf("tailscale.preferredDERP=%d 不在控制服务器下发的 DERP 地图中，保持当前 DERP 区域: %v", regionID, err)
+	case err != nil:
+		logging.Warnf("固定 DERP 区域 %d 失败: %v", regionID, err)
+	case regionID == 0:
+		logging.Infof("Applied tailscale.preferredDERP=0, DERP region selected automatically")
+	default:
+		logging.Infof("Applied tailscale.preferredDERP=%d", regionID)
+	}
+}
+
+// acceptRoutes 通过 EditPrefs 接受其他节点通告的路由，只读模式下跳过
+func (tsm *TailscaleService) acceptRoutes(ctx context.Context) error {
+	if tsm.skipInDryRun("accept routes from peers") {
+		return nil
+	}
+	return tsm.preparer.GetTailscaleClient().AcceptRoutes(ctx)
+}
+
+// 等待路由同步到 Headscale 的超时时间和轮询间隔，测试中可以缩短
+var (
+	routeSyncTimeout      = 75 * time.Second
+	routeSyncPollInterval = 5 * time.Second
+)
+
 // waitForRouteSync 等待路由同步到 Headscale
 // [PUBLIC] waitForRouteSync 等待路由同步
 func (tsm *TailscaleService) waitForRouteSync(podLocalCIDR string) error {
+	// 只读模式下路由可能并未通告，不等待
+	if tsm.skipInDryRun("wait for route %s to sync to Headscale", podLocalCIDR) {
+		return nil
+	}
+
 	// 获取当前节点 ID
 	nodeID, err := tsm.getCurrentNodeID()
 	if err != nil {
@@ -1739,7 +3286,9 @@ func (tsm *TailscaleService) waitForRouteSync(podLocalCIDR string) error {
 
 	condition := func() (bool, error) {
 		// 检查路由是否已同步
-		allRoutes, err := tsm.preparer.GetHeadscaleClient().GetRoutes(context.Background())
+		ctx, cancel := tsm.callContext()
+		defer cancel()
+		allRoutes, err := tsm.preparer.GetHeadscaleClient().GetRoutes(ctx)
 		if err != nil {
 			return false, err
 		}
@@ -1755,10 +3304,73 @@ func (tsm *TailscaleService) waitForRouteSync(podLocalCIDR string) error {
 		return false, nil
 	}
 
-	return tsm.waitForCondition(condition, 75*time.Second, 5*time.Second, 15, fmt.Sprintf("route %s to sync to Headscale", podLocalCIDR))
+	return tsm.waitForCondition(condition, routeSyncTimeout, routeSyncPollInterval, int(routeSyncTimeout/routeSyncPollInterval), fmt.Sprintf("route %s to sync to Headscale", podLocalCIDR))
+}
+
+// routeWithdrawalTimeout ctx 未设置截止时间时等待路由撤销的超时时间
+const routeWithdrawalTimeout = 75 * time.Second
+
+// routeWithdrawalInterval 等待路由撤销时查询 Headscale 的间隔
+const routeWithdrawalInterval = 5 * time.Second
+
+// [PUBLIC] WithdrawRoutes 撤销通告的路由，并等待 Headscale 确认撤销
+// 节点排空前调用，确保流量不再被引到本节点；任一前缀不是 HeadCNI 通告的路由时拒绝撤销
+func (tsm *TailscaleService) WithdrawRoutes(ctx context.Context, prefixes ...string) error {
+	routes := make([]netip.Prefix, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		route, err := netip.ParsePrefix(prefix)
+		if err != nil {
+			return fmt.Errorf("invalid CIDR format %s: %v", prefix, err)
+		}
+		routes = append(routes, route)
+	}
+	if err := tsm.managedRoutes.checkRemovable(routes); err != nil {
+		return err
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, tsm.callTimeout())
+	err := tsm.preparer.GetTailscaleClient().RemoveRoutes(callCtx, routes...)
+	cancel()
+	if err != nil {
+		return fmt.Errorf("failed to remove advertised routes: %v", err)
+	}
+	tsm.managedRoutes.remove(routes...)
+
+	for _, prefix := range prefixes {
+		if err := tsm.waitForRouteWithdrawal(ctx, prefix); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// [PUBLIC] waitForRouteWithdrawal 等待路由从 Headscale 撤销（waitForRouteSync 的反向操作）
+// 路由不再同时处于通告和启用状态即视为撤销完成
+func (tsm *TailscaleService) waitForRouteWithdrawal(ctx context.Context, prefix string) error {
+	nodeID, err := tsm.getCurrentNodeID()
+	if err != nil {
+		return fmt.Errorf("failed to get current node ID: %v", err)
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, routeWithdrawalTimeout)
+		defer cancel()
+	}
+
+	logging.Infof("Waiting for route %s to be withdrawn from Headscale...", prefix)
+
+	err = headscale.WaitForRoutesWithdrawn(ctx, tsm.preparer.GetHeadscaleClient(), nodeID, []string{prefix},
+		routeWithdrawalInterval, tsm.callTimeout())
+	if err != nil {
+		return fmt.Errorf("route %s withdrawal not confirmed: %v", prefix, err)
+	}
+	logging.Infof("Route %s withdrawn from Headscale", prefix)
+	return nil
 }
 
 // waitForCondition 通用等待条件函数，消除重复的等待逻辑
+// 超时后返回的错误附带 condition 最近一次返回的错误，便于区分认证失败和尚未就绪
 func (tsm *TailscaleService) waitForCondition(
 	condition func() (bool, error),
 	timeout time.Duration,
@@ -1768,19 +3380,21 @@ func (tsm *TailscaleService) waitForCondition(
 ) error {
 	logging.Infof("Waiting for %s...", description)
 
-	timeoutCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	timeoutCtx, cancel := context.WithTimeout(tsm.ctx, timeout)
 	defer cancel()
 
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
+	var lastErr error
 	for i := 0; i < maxRetries; i++ {
 		select {
 		case <-timeoutCtx.Done():
-			return fmt.Errorf("%s timeout after %d attempts", description, i+1)
+			return waitConditionError(fmt.Sprintf("%s timeout after %d attempts", description, i+1), lastErr)
 		case <-ticker.C:
 			ready, err := condition()
 			if err != nil {
+				lastErr = err
 				logging.Debugf("%s not ready (attempt %d/%d): %v", description, i+1, maxRetries, err)
 				continue
 			}
@@ -1794,7 +3408,15 @@ func (tsm *TailscaleService) waitForCondition(
 		}
 	}
 
-	return fmt.Errorf("%s not ready after %d attempts", description, maxRetries)
+	return waitConditionError(fmt.Sprintf("%s not ready after %d attempts", description, maxRetries), lastErr)
+}
+
+// waitConditionError 组合等待失败的原因和最近一次条件错误
+func waitConditionError(reason string, lastErr error) error {
+	if lastErr == nil {
+		return fmt.Errorf("%s", reason)
+	}
+	return fmt.Errorf("%s, last error: %v", reason, lastErr)
 }
 
 // min 返回两个整数中较小的一个
@@ -1829,29 +3451,32 @@ func validateAndEnsureDir(path string, defaultPath string) string {
 // Tailscale Service Implementation
 // =============================================================================
 
-// monitorAuthKeyExpiration 监控认证密钥过期时间，在即将过期时自动刷新
+// monitorAuthKeyExpiration 监控预授权密钥和节点密钥的过期时间，在即将过期时自动刷新
 func (tsm *TailscaleService) monitorAuthKeyExpiration(ctx context.Context) {
 	ticker := time.NewTicker(1 * time.Hour) // 每小时检查一次
 	defer ticker.Stop()
 
+	tsm.checkNodeKeyExpiry()
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
 			tsm.checkAndRefreshAuthKeyIfNeeded()
+			tsm.checkNodeKeyExpiry()
 		}
 	}
 }
 
 // checkAndRefreshAuthKeyIfNeeded 检查并在需要时刷新认证密钥
 func (tsm *TailscaleService) checkAndRefreshAuthKeyIfNeeded() {
-	if !tsm.validateAuthKey() {
+	authKey, expiration := tsm.currentAuthKey()
+	if !authKeyValid(authKey, expiration) {
 		return
 	}
 
 	// 如果密钥在 2 小时内过期，提前刷新
-	expiresIn := time.Until(tsm.authKeyExpiredTime)
+	expiresIn := time.Until(expiration)
 	if expiresIn > 0 && expiresIn < 2*time.Hour {
 		logging.Infof("Auth key expires in %v, refreshing early", expiresIn)
 
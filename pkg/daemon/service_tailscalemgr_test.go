@@ -0,0 +1,1251 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/binrclab/headcni/cmd/daemon/config"
+	"github.com/binrclab/headcni/pkg/backend/tailscale/fakets"
+	"github.com/binrclab/headcni/pkg/constants"
+	"github.com/binrclab/headcni/pkg/headscale"
+	"github.com/binrclab/headcni/pkg/headscale/headscaletest"
+	"github.com/binrclab/headcni/pkg/k8s"
+	coreV1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"tailscale.com/ipn/ipnstate"
+	"tailscale.com/tailcfg"
+	"tailscale.com/types/key"
+	"tailscale.com/types/views"
+)
+
+// newTestTailscaleService 使用 fake Tailscale 客户端、fake clientset 和模拟的 Headscale 创建服务
+// 同时缩短路由同步的等待时间
+func newTestTailscaleService(t *testing.T, node *coreV1.Node, tailscaleIP netip.Addr, hs *headscaletest.Client) (*TailscaleService, *fakets.Client, *fake.Clientset) {
+	t.Helper()
+	t.Setenv("NODE_NAME", node.Name)
+
+	timeout, interval := routeSyncTimeout, routeSyncPollInterval
+	routeSyncTimeout, routeSyncPollInterval = time.Second, 10*time.Millisecond
+	t.Cleanup(func() { routeSyncTimeout, routeSyncPollInterval = timeout, interval })
+
+	cfg, err := config.DefaultConfig()
+	if err != nil {
+		t.Fatalf("DefaultConfig failed: %v", err)
+	}
+	cfg.Network.PodCIDR.Base = "10.42.0.0/16"
+
+	clientset := fake.NewSimpleClientset(node)
+	tsClient := fakets.New(tailscaleIP)
+	preparer := &Preparer{
+		config:          cfg,
+		oldConfig:       cfg,
+		k8sClient:       k8s.NewClientForClientset(clientset, nil),
+		headscaleClient: hs,
+		tailscaleClient: tsClient,
+	}
+
+	tsm := NewTailscaleService(preparer)
+	tsm.managedRoutes = newManagedRouteRegistry(filepath.Join(t.TempDir(), "managed-routes.json"))
+	t.Cleanup(tsm.cancel)
+	return tsm, tsClient, clientset
+}
+
+func TestSetupAndManageRoutesAdvertisesAndApprovesPodCIDR(t *testing.T) {
+	tailscaleIP := netip.MustParseAddr("100.64.0.7")
+	node := &coreV1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+		Spec:       coreV1.NodeSpec{PodCIDR: "10.42.1.0/24"},
+	}
+	hs := headscaletest.New()
+	hs.AddNode(headscale.Node{ID: "7", IPAddresses: []string{tailscaleIP.String()}})
+	hs.AddRoute(headscale.Route{ID: "3", Node: headscale.Node{ID: "7"}, Prefix: "10.42.1.0/24", Advertised: true})
+
+	tsm, tsClient, clientset := newTestTailscaleService(t, node, tailscaleIP, hs)
+
+	if err := tsm.setupAndManageRoutes(node); err != nil {
+		t.Fatalf("setupAndManageRoutes failed: %v", err)
+	}
+
+	// 先接受路由，再按当前偏好收敛通告路由
+	calls := tsClient.Calls()
+	acceptAt, advertiseAt := indexOf(calls, "AcceptRoutes"), indexOf(calls, "AdvertiseRoutes")
+	if acceptAt < 0 || advertiseAt < 0 || acceptAt > advertiseAt {
+		t.Fatalf("expected AcceptRoutes before AdvertiseRoutes, got calls %v", calls)
+	}
+	if prefsAt := indexOf(calls, "GetPrefs"); prefsAt < 0 || prefsAt > advertiseAt {
+		t.Fatalf("expected GetPrefs before AdvertiseRoutes, got calls %v", calls)
+	}
+
+	routes := tsClient.AdvertisedRoutes()
+	if len(routes) != 1 || routes[0] != netip.MustParsePrefix("10.42.1.0/24") {
+		t.Fatalf("expected only the Pod CIDR to be advertised, got %v", routes)
+	}
+
+	if enabled := hs.EnabledRoutes(); len(enabled) == 0 || enabled[0] != "3" {
+		t.Fatalf("expected route 3 to be enabled in Headscale, got %v", enabled)
+	}
+
+	updated, err := clientset.CoreV1().Nodes().Get(context.Background(), node.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get node: %v", err)
+	}
+	if got := updated.Annotations[constants.HeadcniTailscaleIPAnnotationKey]; got != tailscaleIP.String() {
+		t.Fatalf("expected tailscale IP annotation %s, got %q", tailscaleIP, got)
+	}
+	if got := updated.Annotations[constants.HeadcniPodCIDRAnnotationKey]; got != "10.42.1.0/24" {
+		t.Fatalf("expected Pod CIDR annotation 10.42.1.0/24, got %q", got)
+	}
+}
+
+func TestSetupAndManageRoutesWithMultiplePodCIDRs(t *testing.T) {
+	tailscaleIP := netip.MustParseAddr("100.64.0.7")
+	node := &coreV1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+		Spec: coreV1.NodeSpec{
+			PodCIDR: "10.42.1.0/24",
+			// 重复项和集群聚合网段被跳过
+			PodCIDRs: []string{"10.42.1.0/24", "10.43.1.0/24", "10.42.1.0/24", "10.42.0.0/16"},
+		},
+	}
+	hs := headscaletest.New()
+	hs.AddNode(headscale.Node{ID: "7", IPAddresses: []string{tailscaleIP.String()}})
+	hs.AddRoute(headscale.Route{ID: "3", Node: headscale.Node{ID: "7"}, Prefix: "10.42.1.0/24", Advertised: true})
+	hs.AddRoute(headscale.Route{ID: "13", Node: headscale.Node{ID: "7"}, Prefix: "10.43.1.0/24", Advertised: true})
+
+	tsm, tsClient, clientset := newTestTailscaleService(t, node, tailscaleIP, hs)
+
+	if err := tsm.setupAndManageRoutes(node); err != nil {
+		t.Fatalf("setupAndManageRoutes failed: %v", err)
+	}
+
+	want := []netip.Prefix{netip.MustParsePrefix("10.42.1.0/24"), netip.MustParsePrefix("10.43.1.0/24")}
+	if routes := tsClient.AdvertisedRoutes(); len(routes) != 2 || indexOf(routes, want[0]) < 0 || indexOf(routes, want[1]) < 0 {
+		t.Fatalf("expected both Pod CIDRs to be advertised, got %v", routes)
+	}
+	if enabled := hs.EnabledRoutes(); len(enabled) != 2 || indexOf(enabled, "3") < 0 || indexOf(enabled, "13") < 0 {
+		t.Fatalf("expected routes 3 and 13 to be enabled in Headscale, got %v", enabled)
+	}
+
+	updated, err := clientset.CoreV1().Nodes().Get(context.Background(), node.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get node: %v", err)
+	}
+	if got := updated.Annotations[constants.HeadcniPodCIDRAnnotationKey]; got != "10.42.1.0/24,10.43.1.0/24" {
+		t.Fatalf("expected Pod CIDR annotation with both ranges, got %q", got)
+	}
+
+	// 节点上移除的 Pod CIDR 在下一次收敛时撤销
+	updated.Spec.PodCIDRs = []string{"10.42.1.0/24"}
+	if _, err := clientset.CoreV1().Nodes().Update(context.Background(), updated, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("failed to update node: %v", err)
+	}
+	if err := tsm.checkLocalPodCIDRApplied(); err != nil {
+		t.Fatalf("checkLocalPodCIDRApplied failed: %v", err)
+	}
+	if routes := tsClient.AdvertisedRoutes(); len(routes) != 1 || routes[0] != want[0] {
+		t.Fatalf("expected the removed Pod CIDR to be withdrawn, got %v", routes)
+	}
+}
+
+func TestSetupAndManageRoutesDryRunDoesNotMutate(t *testing.T) {
+	tailscaleIP := netip.MustParseAddr("100.64.0.7")
+	node := testNode()
+	hs := headscaletest.New()
+	hs.AddNode(headscale.Node{ID: "7", IPAddresses: []string{tailscaleIP.String()}})
+	hs.AddRoute(headscale.Route{ID: "3", Node: headscale.Node{ID: "7"}, Prefix: "10.42.1.0/24", Advertised: true})
+
+	tsm, tsClient, _ := newTestTailscaleService(t, node, tailscaleIP, hs)
+	tsm.SetDryRun(true)
+
+	if err := tsm.setupAndManageRoutes(node); err != nil {
+		t.Fatalf("setupAndManageRoutes failed: %v", err)
+	}
+
+	calls := tsClient.Calls()
+	for _, method := range []string{"AcceptRoutes", "AdvertiseRoutes"} {
+		if indexOf(calls, method) >= 0 {
+			t.Fatalf("expected no %s call in dry-run mode, got calls %v", method, calls)
+		}
+	}
+	if indexOf(calls, "GetPrefs") < 0 {
+		t.Fatalf("expected the reconciler to still read prefs in dry-run mode, got calls %v", calls)
+	}
+	if enabled := hs.EnabledRoutes(); len(enabled) != 0 {
+		t.Fatalf("expected no routes to be enabled in dry-run mode, got %v", enabled)
+	}
+
+	// CNI 服务和 Pod 监控的修复路径同样不修改
+	cniService := NewCNIService(tsm.preparer, tsm)
+	podService := NewPodMonitoringService(tsm.preparer, tsm)
+	if err := cniService.applyTailscaleRoute("10.42.1.0/24"); err != nil {
+		t.Fatalf("applyTailscaleRoute failed: %v", err)
+	}
+	if err := cniService.enableHeadscaleRoute("10.42.1.0/24"); err != nil {
+		t.Fatalf("enableHeadscaleRoute failed: %v", err)
+	}
+	if err := podService.updateTailscaleRoutes("10.42.1.0/24"); err != nil {
+		t.Fatalf("updateTailscaleRoutes failed: %v", err)
+	}
+	if err := podService.updateHeadscaleRoutes("10.42.1.0/24"); err != nil {
+		t.Fatalf("updateHeadscaleRoutes failed: %v", err)
+	}
+	if routes := tsClient.AdvertisedRoutes(); len(routes) != 0 {
+		t.Fatalf("expected no routes to be advertised by repair paths in dry-run mode, got %v", routes)
+	}
+	if enabled := hs.EnabledRoutes(); len(enabled) != 0 {
+		t.Fatalf("expected no routes to be enabled by repair paths in dry-run mode, got %v", enabled)
+	}
+
+	// 关闭只读模式后下一次收敛正常应用
+	tsm.SetDryRun(false)
+	if err := tsm.ensureTailscaleRoute("10.42.1.0/24"); err != nil {
+		t.Fatalf("ensureTailscaleRoute failed: %v", err)
+	}
+	if routes := tsClient.AdvertisedRoutes(); len(routes) != 1 {
+		t.Fatalf("expected the Pod CIDR to be advertised after leaving dry-run mode, got %v", routes)
+	}
+}
+
+// newMultiNodeHeadscale 构造两个节点的 Headscale 状态：本节点 7 和另一个节点 8
+// 节点 8 除了自己的 Pod CIDR，还残留一条与本节点相同网段的路由
+func newMultiNodeHeadscale(ours, other netip.Addr, ourRouteEnabled bool) *headscaletest.Client {
+	hs := headscaletest.New()
+	hs.AddNode(headscale.Node{ID: "7", IPAddresses: []string{ours.String()}})
+	hs.AddNode(headscale.Node{ID: "8", IPAddresses: []string{other.String()}})
+	hs.AddRoute(headscale.Route{ID: "4", Node: headscale.Node{ID: "8"}, Prefix: "10.42.2.0/24", Advertised: true})
+	hs.AddRoute(headscale.Route{ID: "5", Node: headscale.Node{ID: "8"}, Prefix: "10.42.1.0/24", Advertised: true})
+	hs.AddRoute(headscale.Route{ID: "3", Node: headscale.Node{ID: "7"}, Prefix: "10.42.1.0/24", Advertised: true, Enabled: ourRouteEnabled})
+	return hs
+}
+
+func testNode() *coreV1.Node {
+	return &coreV1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+		Spec:       coreV1.NodeSpec{PodCIDR: "10.42.1.0/24"},
+	}
+}
+
+func TestManageHeadscaleRoutesOnlyEnablesOwnRoutes(t *testing.T) {
+	ours, other := netip.MustParseAddr("100.64.0.7"), netip.MustParseAddr("100.64.0.8")
+	hs := newMultiNodeHeadscale(ours, other, false)
+	// 本节点旧 Pod CIDR 的陈旧路由也不应被启用
+	hs.AddRoute(headscale.Route{ID: "6", Node: headscale.Node{ID: "7"}, Prefix: "10.42.9.0/24", Advertised: true})
+
+	tsm, _, _ := newTestTailscaleService(t, testNode(), ours, hs)
+
+	if err := tsm.manageHeadscaleRoutes([]string{"10.42.1.0/24"}, ours.String()); err != nil {
+		t.Fatalf("manageHeadscaleRoutes failed: %v", err)
+	}
+
+	if enabled := hs.EnabledRoutes(); len(enabled) != 1 || enabled[0] != "3" {
+		t.Fatalf("expected only route 3 to be enabled, got %v", enabled)
+	}
+	for _, id := range []string{"4", "5", "6"} {
+		if route, _ := hs.Route(id); route.Enabled {
+			t.Fatalf("route %s of another node or prefix was enabled", id)
+		}
+	}
+
+	// 已启用时不再重复启用
+	hs.ResetCalls()
+	if err := tsm.manageHeadscaleRoutes([]string{"10.42.1.0/24"}, ours.String()); err != nil {
+		t.Fatalf("manageHeadscaleRoutes failed: %v", err)
+	}
+	if enabled := hs.EnabledRoutes(); len(enabled) != 0 {
+		t.Fatalf("expected no routes to be enabled again, got %v", enabled)
+	}
+}
+
+func TestManageHeadscaleRoutesRouteConflictPolicy(t *testing.T) {
+	ours, other := netip.MustParseAddr("100.64.0.7"), netip.MustParseAddr("100.64.0.8")
+	t.Cleanup(func() { GetGlobalHealthManager().ClearCondition(HealthConditionRouteConflict) })
+
+	tests := []struct {
+		name        string
+		policy      string
+		otherServes bool
+		wantEnabled bool
+	}{
+		{name: "warn enables ours", policy: config.RouteConflictPolicyWarn, otherServes: true, wantEnabled: true},
+		{name: "yield while other serves", policy: config.RouteConflictPolicyYield, otherServes: true, wantEnabled: false},
+		{name: "yield when other is not enabled", policy: config.RouteConflictPolicyYield, otherServes: false, wantEnabled: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hs := newMultiNodeHeadscale(ours, other, false)
+			hs.AddRoute(headscale.Route{ID: "5", Node: headscale.Node{ID: "8"}, Prefix: "10.42.1.0/24",
+				Advertised: true, Enabled: tt.otherServes, IsPrimary: tt.otherServes})
+
+			tsm, _, _ := newTestTailscaleService(t, testNode(), ours, hs)
+			tsm.preparer.config.Tailscale.RouteConflictPolicy = tt.policy
+
+			if err := tsm.manageHeadscaleRoutes([]string{"10.42.1.0/24"}, ours.String()); err != nil {
+				t.Fatalf("manageHeadscaleRoutes failed: %v", err)
+			}
+			if route, _ := hs.Route("3"); route.Enabled != tt.wantEnabled {
+				t.Fatalf("expected our route enabled=%t, got %t", tt.wantEnabled, route.Enabled)
+			}
+
+			condition := GetGlobalHealthManager().GetHealthStatus().Conditions[HealthConditionRouteConflict]
+			if !strings.Contains(condition, "this node 7 ") || !strings.Contains(condition, "node(s) 8 ") {
+				t.Fatalf("expected a route conflict condition naming both nodes, got %q", condition)
+			}
+		})
+	}
+
+	// 冲突消除后清除健康条件
+	hs := newMultiNodeHeadscale(ours, other, true)
+	hs.AddRoute(headscale.Route{ID: "5", Node: headscale.Node{ID: "8"}, Prefix: "10.42.1.0/24"})
+	tsm, _, _ := newTestTailscaleService(t, testNode(), ours, hs)
+	if err := tsm.manageHeadscaleRoutes([]string{"10.42.1.0/24"}, ours.String()); err != nil {
+		t.Fatalf("manageHeadscaleRoutes failed: %v", err)
+	}
+	if conditions := GetGlobalHealthManager().GetHealthStatus().Conditions; len(conditions) != 0 {
+		t.Fatalf("expected the route conflict condition to be cleared, got %v", conditions)
+	}
+}
+
+func TestAutoApproveRoutesDisabledAwaitsApproval(t *testing.T) {
+	ours, other := netip.MustParseAddr("100.64.0.7"), netip.MustParseAddr("100.64.0.8")
+	t.Cleanup(func() { GetGlobalHealthManager().ClearCondition(HealthConditionAwaitingRouteApproval) })
+
+	hs := newMultiNodeHeadscale(ours, other, false)
+	tsm, _, _ := newTestTailscaleService(t, testNode(), ours, hs)
+	autoApprove := false
+	tsm.preparer.config.Headscale.AutoApproveRoutes = &autoApprove
+
+	if err := tsm.manageHeadscaleRoutes([]string{"10.42.1.0/24"}, ours.String()); err != nil {
+		t.Fatalf("manageHeadscaleRoutes failed: %v", err)
+	}
+	// 路由已通告但未批准时健康检查不报错
+	if err := tsm.checkHeadscaleRoutes(); err != nil {
+		t.Fatalf("checkHeadscaleRoutes failed: %v", err)
+	}
+	if enabled := hs.EnabledRoutes(); len(enabled) != 0 {
+		t.Fatalf("expected no routes to be enabled, got %v", enabled)
+	}
+	condition := GetGlobalHealthManager().GetHealthStatus().Conditions[HealthConditionAwaitingRouteApproval]
+	if !strings.Contains(condition, "10.42.1.0/24") {
+		t.Fatalf("expected an awaiting approval condition for 10.42.1.0/24, got %q", condition)
+	}
+
+	// 外部批准后清除健康条件
+	hs.AddRoute(headscale.Route{ID: "3", Node: headscale.Node{ID: "7"}, Prefix: "10.42.1.0/24", Advertised: true, Enabled: true})
+	if err := tsm.checkHeadscaleRoutes(); err != nil {
+		t.Fatalf("checkHeadscaleRoutes failed: %v", err)
+	}
+	if _, ok := GetGlobalHealthManager().GetHealthStatus().Conditions[HealthConditionAwaitingRouteApproval]; ok {
+		t.Fatal("expected the awaiting approval condition to be cleared")
+	}
+}
+
+func TestCheckHeadscaleRoutes(t *testing.T) {
+	ours, other := netip.MustParseAddr("100.64.0.7"), netip.MustParseAddr("100.64.0.8")
+
+	tests := []struct {
+		name        string
+		headscale   func() *headscaletest.Client
+		wantErr     string
+		wantEnabled []string
+	}{
+		{
+			name:        "enables own disabled route",
+			headscale:   func() *headscaletest.Client { return newMultiNodeHeadscale(ours, other, false) },
+			wantEnabled: []string{"3"},
+		},
+		{
+			name:      "own route already enabled",
+			headscale: func() *headscaletest.Client { return newMultiNodeHeadscale(ours, other, true) },
+		},
+		{
+			name: "same prefix only advertised by another node",
+			headscale: func() *headscaletest.Client {
+				hs := headscaletest.New()
+				hs.AddNode(headscale.Node{ID: "7", IPAddresses: []string{ours.String()}})
+				hs.AddNode(headscale.Node{ID: "8", IPAddresses: []string{other.String()}})
+				hs.AddRoute(headscale.Route{ID: "5", Node: headscale.Node{ID: "8"}, Prefix: "10.42.1.0/24", Advertised: true})
+				return hs
+			},
+			wantErr: "route for local Pod CIDR not found",
+		},
+		{
+			name: "headscale unavailable",
+			headscale: func() *headscaletest.Client {
+				hs := newMultiNodeHeadscale(ours, other, false)
+				hs.SetError("GetRoutes", fmt.Errorf("connection refused"))
+				return hs
+			},
+			wantErr: "failed to get headscale routes",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hs := tt.headscale()
+			hs.ResetCalls()
+			tsm, _, _ := newTestTailscaleService(t, testNode(), ours, hs)
+
+			err := tsm.checkHeadscaleRoutes()
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("checkHeadscaleRoutes failed: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+
+			enabled := hs.EnabledRoutes()
+			if fmt.Sprint(enabled) != fmt.Sprint(tt.wantEnabled) {
+				t.Fatalf("expected enabled routes %v, got %v", tt.wantEnabled, enabled)
+			}
+		})
+	}
+}
+
+func TestWaitForRouteSync(t *testing.T) {
+	ours, other := netip.MustParseAddr("100.64.0.7"), netip.MustParseAddr("100.64.0.8")
+
+	t.Run("own route synced", func(t *testing.T) {
+		tsm, _, _ := newTestTailscaleService(t, testNode(), ours, newMultiNodeHeadscale(ours, other, false))
+		if err := tsm.waitForRouteSync("10.42.1.0/24"); err != nil {
+			t.Fatalf("waitForRouteSync failed: %v", err)
+		}
+	})
+
+	t.Run("route appears after a few polls", func(t *testing.T) {
+		hs := headscaletest.New()
+		hs.AddNode(headscale.Node{ID: "7", IPAddresses: []string{ours.String()}})
+		tsm, _, _ := newTestTailscaleService(t, testNode(), ours, hs)
+
+		timer := time.AfterFunc(50*time.Millisecond, func() {
+			hs.AddRoute(headscale.Route{ID: "3", Node: headscale.Node{ID: "7"}, Prefix: "10.42.1.0/24", Advertised: true})
+		})
+		defer timer.Stop()
+
+		if err := tsm.waitForRouteSync("10.42.1.0/24"); err != nil {
+			t.Fatalf("waitForRouteSync failed: %v", err)
+		}
+	})
+
+	t.Run("same prefix only advertised by another node", func(t *testing.T) {
+		hs := headscaletest.New()
+		hs.AddNode(headscale.Node{ID: "7", IPAddresses: []string{ours.String()}})
+		hs.AddNode(headscale.Node{ID: "8", IPAddresses: []string{other.String()}})
+		hs.AddRoute(headscale.Route{ID: "5", Node: headscale.Node{ID: "8"}, Prefix: "10.42.1.0/24", Advertised: true})
+		tsm, _, _ := newTestTailscaleService(t, testNode(), ours, hs)
+
+		if err := tsm.waitForRouteSync("10.42.1.0/24"); err == nil {
+			t.Fatalf("expected waitForRouteSync to time out when only another node advertises the prefix")
+		}
+	})
+
+	t.Run("node not registered", func(t *testing.T) {
+		tsm, _, _ := newTestTailscaleService(t, testNode(), ours, headscaletest.New())
+		if err := tsm.waitForRouteSync("10.42.1.0/24"); err == nil || !strings.Contains(err.Error(), "failed to get current node ID") {
+			t.Fatalf("expected current node ID error, got %v", err)
+		}
+	})
+}
+
+func TestReconcileNodeTagsOnlyManagesOwnTags(t *testing.T) {
+	ours := netip.MustParseAddr("100.64.0.7")
+	hs := headscaletest.New()
+	hs.AddNode(headscale.Node{
+		ID:          "7",
+		IPAddresses: []string{ours.String()},
+		// tag:ops 由管理员添加；tag:headcni-legacy 和旧节点名标签由 HeadCNI 添加，已不在配置中
+		ForcedTags: []string{"tag:ops", "tag:headcni-legacy", "tag:node:old-name", "tag:control-server"},
+	})
+
+	tsm, _, _ := newTestTailscaleService(t, testNode(), ours, hs)
+	tsm.preparer.GetConfig().Tailscale.Tags = []string{"tag:control-server", "headcni"}
+
+	if err := tsm.reconcileNodeTags(); err != nil {
+		t.Fatalf("reconcileNodeTags failed: %v", err)
+	}
+
+	node, _ := hs.Node("7")
+	want := []string{"tag:ops", "tag:control-server", "tag:headcni", "tag:node:node-1"}
+	if fmt.Sprint(node.ForcedTags) != fmt.Sprint(want) {
+		t.Fatalf("expected tags %v, got %v", want, node.ForcedTags)
+	}
+
+	// 已收敛时不再调用 SetNodeTags
+	hs.ResetCalls()
+	if err := tsm.reconcileNodeTags(); err != nil {
+		t.Fatalf("reconcileNodeTags failed: %v", err)
+	}
+	if indexOf(hs.Calls(), "SetNodeTags") >= 0 {
+		t.Fatalf("expected no SetNodeTags call when tags are up to date, got calls %v", hs.Calls())
+	}
+}
+
+func TestAutoApproverTagLeavesApprovalToHeadscale(t *testing.T) {
+	ours, other := netip.MustParseAddr("100.64.0.7"), netip.MustParseAddr("100.64.0.8")
+	t.Cleanup(func() { GetGlobalHealthManager().ClearCondition(HealthConditionAwaitingRouteApproval) })
+
+	hs := newMultiNodeHeadscale(ours, other, false)
+	tsm, _, _ := newTestTailscaleService(t, testNode(), ours, hs)
+	tsm.preparer.GetConfig().Tailscale.Tags = []string{"tag:control-server"}
+	tsm.preparer.GetConfig().Headscale.AutoApproverTag = "tag:headcni-router"
+
+	if err := tsm.reconcileNodeTags(); err != nil {
+		t.Fatalf("reconcileNodeTags failed: %v", err)
+	}
+	node, _ := hs.Node("7")
+	want := []string{"tag:control-server", "tag:headcni-router", "tag:node:node-1"}
+	if fmt.Sprint(node.ForcedTags) != fmt.Sprint(want) {
+		t.Fatalf("expected tags %v, got %v", want, node.ForcedTags)
+	}
+
+	if err := tsm.manageHeadscaleRoutes([]string{"10.42.1.0/24"}, ours.String()); err != nil {
+		t.Fatalf("manageHeadscaleRoutes failed: %v", err)
+	}
+	if indexOf(hs.Calls(), "EnableRoute") >= 0 {
+		t.Fatalf("expected no EnableRoute call with an autoApprovers tag, got calls %v", hs.Calls())
+	}
+
+	// 清空 autoApproverTag 后旧标签不匹配 managedTagPrefix 也要移除，否则 Headscale 会继续自动批准路由
+	oldConfig := *tsm.preparer.GetConfig()
+	newConfig := oldConfig
+	newConfig.Headscale.AutoApproverTag = ""
+	tsm.preparer.oldConfig, tsm.preparer.config = &oldConfig, &newConfig
+	tsm.hostname = "node-1"
+	if err := tsm.applyLiveConfigChanges(); err != nil {
+		t.Fatalf("applyLiveConfigChanges failed: %v", err)
+	}
+	node, _ = hs.Node("7")
+	want = []string{"tag:control-server", "tag:node:node-1"}
+	if fmt.Sprint(node.ForcedTags) != fmt.Sprint(want) {
+		t.Fatalf("expected tags %v after clearing the autoApprovers tag, got %v", want, node.ForcedTags)
+	}
+}
+
+func TestManagedRoutesGuardsOperatorRoutes(t *testing.T) {
+	tsm, tsClient, _ := newTestTailscaleService(t, testNode(), netip.MustParseAddr("100.64.0.7"), headscaletest.New())
+
+	// 192.168.50.0/24 由管理员手动通告
+	operator := netip.MustParsePrefix("192.168.50.0/24")
+	if err := tsClient.AdvertiseRoutes(context.Background(), operator); err != nil {
+		t.Fatalf("AdvertiseRoutes failed: %v", err)
+	}
+
+	podCIDR := netip.MustParsePrefix("10.42.1.0/24")
+	extra := netip.MustParsePrefix("10.96.0.0/12")
+	if err := tsm.reconcileAdvertisedRoutes([]netip.Prefix{podCIDR, extra}); err != nil {
+		t.Fatalf("reconcileAdvertisedRoutes failed: %v", err)
+	}
+	// 额外路由从配置中删除后被撤销，管理员的路由保留
+	if err := tsm.reconcileAdvertisedRoutes([]netip.Prefix{podCIDR}); err != nil {
+		t.Fatalf("reconcileAdvertisedRoutes failed: %v", err)
+	}
+	routes := tsClient.AdvertisedRoutes()
+	if indexOf(routes, extra) >= 0 || indexOf(routes, podCIDR) < 0 || indexOf(routes, operator) < 0 {
+		t.Fatalf("expected routes [%s %s], got %v", podCIDR, operator, routes)
+	}
+
+	if err := tsm.WithdrawRoutes(context.Background(), operator.String()); err == nil {
+		t.Fatalf("expected withdrawing unmanaged route %s to fail", operator)
+	}
+	if indexOf(tsClient.AdvertisedRoutes(), operator) < 0 {
+		t.Fatalf("unmanaged route %s was withdrawn", operator)
+	}
+}
+
+func TestRepairedRoutesAreManaged(t *testing.T) {
+	tsm, tsClient, _ := newTestTailscaleService(t, testNode(), netip.MustParseAddr("100.64.0.7"), headscaletest.New())
+
+	// 升级前已通告的 Pod CIDR：偏好已一致时同样登记
+	podCIDR := netip.MustParsePrefix("10.42.1.0/24")
+	if err := tsClient.AdvertiseRoutes(context.Background(), podCIDR); err != nil {
+		t.Fatalf("AdvertiseRoutes failed: %v", err)
+	}
+	if err := tsm.reconcileAdvertisedRoutes([]netip.Prefix{podCIDR}); err != nil {
+		t.Fatalf("reconcileAdvertisedRoutes failed: %v", err)
+	}
+	if !tsm.managedRoutes.managed(podCIDR) {
+		t.Errorf("expected already advertised route %s to be registered", podCIDR)
+	}
+
+	// CNI 服务和 Pod 监控修复时通告的路由之后可以被收敛撤销
+	repaired := netip.MustParsePrefix("10.42.9.0/24")
+	cniService := NewCNIService(tsm.preparer, tsm)
+	if err := cniService.applyTailscaleRoute(repaired.String()); err != nil {
+		t.Fatalf("applyTailscaleRoute failed: %v", err)
+	}
+	if err := NewPodMonitoringService(tsm.preparer, tsm).updateTailscaleRoutes(repaired.String()); err != nil {
+		t.Fatalf("updateTailscaleRoutes failed: %v", err)
+	}
+	routes := tsClient.AdvertisedRoutes()
+	if len(routes) != 2 || indexOf(routes, repaired) < 0 {
+		t.Fatalf("expected %s to be advertised once alongside %s, got %v", repaired, podCIDR, routes)
+	}
+	if err := tsm.reconcileAdvertisedRoutes([]netip.Prefix{podCIDR}); err != nil {
+		t.Fatalf("reconcileAdvertisedRoutes failed: %v", err)
+	}
+	if indexOf(tsClient.AdvertisedRoutes(), repaired) >= 0 {
+		t.Errorf("expected repaired route %s to be withdrawn by reconcile, got %v", repaired, tsClient.AdvertisedRoutes())
+	}
+}
+
+func TestCheckNodeKeyExpiryReauthenticatesEarly(t *testing.T) {
+	tailscaleIP := netip.MustParseAddr("100.64.0.7")
+	hs := headscaletest.New()
+	hs.AddNode(headscale.Node{ID: "7", IPAddresses: []string{tailscaleIP.String()}})
+	hs.AddRoute(headscale.Route{ID: "3", Node: headscale.Node{ID: "7"}, Prefix: "10.42.1.0/24", Advertised: true})
+	tsm, tsClient, _ := newTestTailscaleService(t, testNode(), tailscaleIP, hs)
+
+	// 距离过期还很久时不重新认证
+	tsClient.SetKeyExpiry(time.Now().Add(48 * time.Hour))
+	tsm.checkNodeKeyExpiry()
+	if indexOf(tsClient.Calls(), "ForceLogin") >= 0 {
+		t.Fatalf("expected no reauthentication, got calls %v", tsClient.Calls())
+	}
+
+	status, _ := tsClient.GetStatus(context.Background())
+	oldKey := status.Self.PublicKey
+	tsClient.SetKeyExpiry(time.Now().Add(30 * time.Minute))
+	tsm.checkNodeKeyExpiry()
+
+	if indexOf(hs.Calls(), "CreatePreAuthKey") < 0 || indexOf(tsClient.Calls(), "ForceLogin") < 0 {
+		t.Fatalf("expected reauthentication with a fresh pre-auth key, got calls %v and %v", hs.Calls(), tsClient.Calls())
+	}
+	status, _ = tsClient.GetStatus(context.Background())
+	if status.Self.PublicKey == oldKey {
+		t.Fatalf("expected a new node key after reauthentication")
+	}
+}
+
+func TestCheckSplitBrainReauthenticates(t *testing.T) {
+	t.Cleanup(func() { GetGlobalHealthManager().ClearCondition(HealthConditionSplitBrain) })
+	tailscaleIP := netip.MustParseAddr("100.64.0.7")
+	hs := headscaletest.New()
+	tsm, tsClient, _ := newTestTailscaleService(t, testNode(), tailscaleIP, hs)
+	status, _ := tsClient.GetStatus(context.Background())
+	oldKey := status.Self.PublicKey
+	hs.AddNode(headscale.Node{ID: "7", NodeKey: oldKey.String(), IPAddresses: []string{tailscaleIP.String()}})
+
+	// 节点密钥已注册且未过期
+	tsm.checkSplitBrain()
+	if indexOf(tsClient.Calls(), "ForceLogin") >= 0 {
+		t.Fatalf("expected no reauthentication, got calls %v", tsClient.Calls())
+	}
+
+	// Headscale 已过期该节点，重新认证失败时保留健康条件
+	hs.AddNode(headscale.Node{ID: "7", NodeKey: oldKey.String(), IPAddresses: []string{tailscaleIP.String()}, Expiry: time.Now().Add(-time.Minute)})
+	tsClient.SetError("ForceLogin", fmt.Errorf("control server unavailable"))
+	tsm.checkSplitBrain()
+	if _, ok := GetGlobalHealthManager().GetHealthStatus().Conditions[HealthConditionSplitBrain]; !ok {
+		t.Fatalf("expected %s condition while the node is expired in Headscale", HealthConditionSplitBrain)
+	}
+
+	// 失败后退避，下一个检查周期不再创建预授权密钥
+	preAuthKeys := func() int {
+		n := 0
+		for _, call := range hs.Calls() {
+			if call == "CreatePreAuthKey" {
+				n++
+			}
+		}
+		return n
+	}
+	before := preAuthKeys()
+	tsm.checkSplitBrain()
+	if after := preAuthKeys(); after != before {
+		t.Fatalf("expected no reauthentication during backoff, got %d new pre-auth keys", after-before)
+	}
+	if wait := tsm.splitBrainBackoff.remaining(); wait <= 0 || wait > splitBrainReauthBaseDelay {
+		t.Fatalf("expected a backoff of up to %v after the first failure, got %v", splitBrainReauthBaseDelay, wait)
+	}
+	if delay := tsm.splitBrainBackoff.failed(); delay != 2*splitBrainReauthBaseDelay {
+		t.Fatalf("expected the backoff to double, got %v", delay)
+	}
+	tsm.splitBrainBackoff.next = time.Time{}
+
+	// Headscale 已删除该节点
+	if err := hs.DeleteNode(context.Background(), "7"); err != nil {
+		t.Fatalf("DeleteNode failed: %v", err)
+	}
+	tsClient.SetError("ForceLogin", nil)
+	tsm.checkSplitBrain()
+	if indexOf(hs.Calls(), "CreatePreAuthKey") < 0 || indexOf(tsClient.Calls(), "ForceLogin") < 0 {
+		t.Fatalf("expected reauthentication with a fresh pre-auth key, got calls %v and %v", hs.Calls(), tsClient.Calls())
+	}
+	status, _ = tsClient.GetStatus(context.Background())
+	if status.Self.PublicKey == oldKey {
+		t.Fatalf("expected a new node key after reauthentication")
+	}
+	if condition, ok := GetGlobalHealthManager().GetHealthStatus().Conditions[HealthConditionSplitBrain]; ok {
+		t.Fatalf("expected %s condition cleared after reauthentication, got %q", HealthConditionSplitBrain, condition)
+	}
+	if tsm.splitBrainBackoff.failures != 0 {
+		t.Fatalf("expected the backoff reset after reauthentication, got %d failures", tsm.splitBrainBackoff.failures)
+	}
+}
+
+func TestCheckInterfaceRemovable(t *testing.T) {
+	extra := []string{"bond", "net1"}
+	for _, tc := range []struct {
+		name      string
+		removable bool
+	}{
+		{"headcni01", true},
+		{"headcni-test", true},
+		{"eth0", false},
+		{"cali12345", false},
+		{"bond0", false},
+		{"net1", false},
+		// 不匹配任何受保护前缀，但不是 HeadCNI 创建的接口
+		{"wg0", false},
+	} {
+		err := checkInterfaceRemovable(tc.name, extra)
+		if (err == nil) != tc.removable {
+			t.Errorf("checkInterfaceRemovable(%q) = %v, expected removable=%v", tc.name, err, tc.removable)
+		}
+	}
+}
+
+func TestControlServerToggleDryRun(t *testing.T) {
+	tsm, _, _ := newTestTailscaleService(t, testNode(), netip.MustParseAddr("100.64.0.7"), headscaletest.New())
+	sm := NewServiceManager()
+	sm.RegisterService(tsm)
+	d := NewDaemon(tsm.preparer.GetConfig(), tsm.preparer, sm)
+
+	socketPath := filepath.Join(t.TempDir(), "control.sock")
+	control := newControlServer(d, socketPath)
+	if err := control.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	t.Cleanup(func() { control.Stop() })
+
+	info, err := os.Stat(socketPath)
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if mode := info.Mode().Perm(); mode != 0600 {
+		t.Errorf("control socket mode = %o, expected 600", mode)
+	}
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socketPath)
+		},
+	}}
+	resp, err := client.Post("http://unix"+dryRunPath, "application/json", strings.NewReader(`{"enabled":true}`))
+	if os.Geteuid() != 0 {
+		// 非 root 连接会被直接关闭
+		if err == nil {
+			resp.Body.Close()
+			t.Fatalf("expected non-root connection to be rejected")
+		}
+		return
+	}
+	if err != nil {
+		t.Fatalf("POST %s failed: %v", dryRunPath, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("POST %s returned %s", dryRunPath, resp.Status)
+	}
+	if !tsm.DryRun() {
+		t.Errorf("expected dry-run to be enabled through the control socket")
+	}
+
+	// 身份轮换只在控制 socket 上提供
+	resp, err = client.Get("http://unix" + identityRotatePath)
+	if err != nil {
+		t.Fatalf("GET %s failed: %v", identityRotatePath, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("GET %s returned %s, expected the rotation handler to reject it", identityRotatePath, resp.Status)
+	}
+}
+
+func TestStartupDelayIsDeterministicAndBounded(t *testing.T) {
+	max := 30 * time.Second
+	delays := make(map[time.Duration]bool)
+	for i := 0; i < 20; i++ {
+		name := fmt.Sprintf("node-%d", i)
+		delay := startupDelay(name, max)
+		if delay < 0 || delay >= max {
+			t.Fatalf("startupDelay(%q) = %v, expected within [0, %v)", name, delay, max)
+		}
+		if again := startupDelay(name, max); again != delay {
+			t.Fatalf("startupDelay(%q) changed between calls: %v != %v", name, delay, again)
+		}
+		delays[delay] = true
+	}
+	if len(delays) < 10 {
+		t.Errorf("expected node delays to be spread out, got %d distinct values", len(delays))
+	}
+	if delay := startupDelay("node-1", 0); delay != 0 {
+		t.Errorf("expected no delay when startup jitter is disabled, got %v", delay)
+	}
+
+	for i := 0; i < 100; i++ {
+		if interval := withJitter(10 * time.Second); interval < 10*time.Second || interval >= 11*time.Second {
+			t.Fatalf("withJitter(10s) = %v, expected within [10s, 11s)", interval)
+		}
+	}
+}
+
+func TestForeignPeerRoutesKeepsClusterRoutes(t *testing.T) {
+	tsm, tsClient, _ := newTestTailscaleService(t, testNode(), netip.MustParseAddr("100.64.0.7"), headscaletest.New())
+
+	addPeer := func(id, ip string, tags []string, routes ...string) {
+		prefixes := make([]netip.Prefix, 0, len(routes))
+		for _, route := range routes {
+			prefixes = append(prefixes, netip.MustParsePrefix(route))
+		}
+		primary := views.SliceOf(prefixes)
+		tsClient.AddPeer(&ipnstate.PeerStatus{
+			ID:            tailcfg.StableNodeID(id),
+			PublicKey:     key.NewNode().Public(),
+			TailscaleIPs:  []netip.Addr{netip.MustParseAddr(ip)},
+			PrimaryRoutes: &primary,
+		})
+		tsClient.SetPeerTags(ip, tags)
+	}
+	addPeer("cluster", "100.64.0.8", []string{"tag:headcni"}, "10.42.2.0/24", "10.50.0.0/16")
+	// 共享 tailnet 中的无关子网路由器，其中一个网段与集群节点重复
+	addPeer("office", "100.64.0.9", []string{"tag:office"}, "192.168.0.0/16", "10.50.0.0/16", "0.0.0.0/0")
+
+	foreign, err := tsm.foreignPeerRoutes([]string{"tag:headcni"})
+	if err != nil {
+		t.Fatalf("foreignPeerRoutes failed: %v", err)
+	}
+	if len(foreign) != 1 || !foreign[netip.MustParsePrefix("192.168.0.0/16")] {
+		t.Fatalf("expected only 192.168.0.0/16 to be foreign, got %v", foreign)
+	}
+
+	// 节点集合不变时复用缓存的标签
+	tsClient.ResetCalls()
+	if _, err := tsm.foreignPeerRoutes([]string{"tag:headcni"}); err != nil {
+		t.Fatalf("foreignPeerRoutes failed: %v", err)
+	}
+	if indexOf(tsClient.Calls(), "ResolvePeerTags") >= 0 {
+		t.Fatalf("expected cached peer tags, got calls %v", tsClient.Calls())
+	}
+}
+
+func TestPodCIDRRulesMatchLocalTraffic(t *testing.T) {
+	_, podCIDR, _ := net.ParseCIDR("10.42.1.0/24")
+	rules := podCIDRRules([]*net.IPNet{podCIDR}, "tailscale0")
+
+	// 与内核相同：未设置 iif 的规则匹配所有入口，本机发出的流量入口为 lo
+	lookupMain := func(iif string, dst net.IP) bool {
+		for _, rule := range rules {
+			if rule.Table == 254 && rule.Priority == 3151 && rule.Dst.Contains(dst) &&
+				(rule.IifName == "" || rule.IifName == iif) {
+				return true
+			}
+		}
+		return false
+	}
+
+	podIP := net.ParseIP("10.42.1.10")
+	for _, iif := range []string{"lo", "cni0", "tailscale0"} {
+		if !lookupMain(iif, podIP) {
+			t.Errorf("expected traffic from %s to local pods to use the main table", iif)
+		}
+	}
+	if lookupMain("lo", net.ParseIP("10.42.2.10")) {
+		t.Error("expected traffic outside the pod CIDR not to match")
+	}
+
+	scoped := false
+	for _, rule := range rules {
+		scoped = scoped || rule.IifName == "tailscale0"
+	}
+	if !scoped {
+		t.Errorf("expected a rule scoped to the tailscale interface, got %v", rules)
+	}
+}
+
+func TestReconcileDuplicateNodesMatchesExactHostname(t *testing.T) {
+	ours := netip.MustParseAddr("100.64.0.7")
+	now := time.Now()
+	hs := headscaletest.New()
+	hs.AddNode(headscale.Node{ID: "7", Name: "worker-1", IPAddresses: []string{ours.String()}, LastSeen: now, Online: true})
+	// 同主机名的旧注册和 Headscale 冲突时改名的旧注册
+	hs.AddNode(headscale.Node{ID: "3", Name: "worker-1", LastSeen: now.Add(-time.Hour)})
+	hs.AddNode(headscale.Node{ID: "4", Name: "worker-1", GivenName: "worker-1-x7k2p9qa", LastSeen: now.Add(-time.Hour)})
+	// 主机名只是以 worker-1 开头的其他节点
+	hs.AddNode(headscale.Node{ID: "12", Name: "worker-12", LastSeen: now.Add(-time.Hour)})
+	hs.AddNode(headscale.Node{ID: "13", Name: "worker-1-db", LastSeen: now.Add(-time.Hour)})
+
+	tsm, _, _ := newTestTailscaleService(t, testNode(), ours, hs)
+	tsm.setTailscaleEnv(&TailscaleEnv{hostName: "worker-1"})
+
+	if err := tsm.reconcileDuplicateNodes(); err != nil {
+		t.Fatalf("reconcileDuplicateNodes failed: %v", err)
+	}
+	for id, kept := range map[string]bool{"7": true, "3": false, "4": false, "12": true, "13": true} {
+		if _, ok := hs.Node(id); ok != kept {
+			t.Errorf("node %s: expected kept=%t", id, kept)
+		}
+	}
+}
+
+func TestReadHostNameRequiresExactPrefix(t *testing.T) {
+	tsm, _, _ := newTestTailscaleService(t, testNode(), netip.MustParseAddr("100.64.0.7"), headscaletest.New())
+	path := filepath.Join(t.TempDir(), "hostname")
+	if err := os.WriteFile(path, []byte("node-12-abcde"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tsm.preparer.GetConfig().Tailscale.Hostname.Prefix = "node-12"
+	if hostname := tsm.readHostNameInDomain(path); hostname != "node-12-abcde" {
+		t.Fatalf("expected the saved hostname to be kept, got %s", hostname)
+	}
+
+	// 前缀改为 node-1 后，node-12-abcde 只是以 node-1 开头，需要重新生成
+	tsm.preparer.GetConfig().Tailscale.Hostname.Prefix = "node-1"
+	hostname := tsm.readHostNameInDomain(path)
+	if hostname == "node-12-abcde" || !strings.HasPrefix(hostname, "node-1-") {
+		t.Fatalf("expected a new node-1 hostname, got %s", hostname)
+	}
+	if saved, _ := os.ReadFile(path); string(saved) != hostname {
+		t.Fatalf("expected the new hostname to be saved, got %s", saved)
+	}
+}
+
+func TestUniqueHostnameFallbackKeepsSuffix(t *testing.T) {
+	hs := headscaletest.New()
+	hs.AddNode(headscale.Node{ID: "7", Name: "taken"})
+	tsm, _, _ := newTestTailscaleService(t, testNode(), netip.MustParseAddr("100.64.0.7"), hs)
+
+	// 生成的主机名一直冲突时改用更长的后缀，前缀再长也至少保留一个随机字符
+	for _, prefix := range []string{"node", strings.Repeat("a", 62), strings.Repeat("a", 70)} {
+		tsm.preparer.GetConfig().Tailscale.Hostname.Prefix = prefix
+		hostname := tsm.generateUniqueHostname(func() string { return "taken" })
+		suffix, ok := strings.CutPrefix(hostname, prefix+"-")
+		if !ok || suffix == "" {
+			t.Fatalf("expected a random suffix after prefix of length %d, got %s", len(prefix), hostname)
+		}
+		if len(prefix) < 62 && len(hostname) > 63 {
+			t.Fatalf("expected hostname within 63 characters, got %s", hostname)
+		}
+	}
+}
+
+func indexOf[T comparable](values []T, value T) int {
+	for i, v := range values {
+		if v == value {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestWaitForConditionReportsLastError(t *testing.T) {
+	tsm, _, _ := newTestTailscaleService(t, testNode(), netip.MustParseAddr("100.64.0.7"), headscaletest.New())
+
+	attempts := 0
+	err := tsm.waitForCondition(func() (bool, error) {
+		attempts++
+		if attempts == 1 {
+			return false, fmt.Errorf("auth key rejected")
+		}
+		return false, nil
+	}, time.Second, 5*time.Millisecond, 3, "test condition")
+	if err == nil || !strings.Contains(err.Error(), "not ready after 3 attempts, last error: auth key rejected") {
+		t.Fatalf("expected the last condition error in the timeout, got %v", err)
+	}
+
+	err = tsm.waitForCondition(func() (bool, error) { return false, nil }, time.Second, 5*time.Millisecond, 2, "test condition")
+	if err == nil || strings.Contains(err.Error(), "last error") {
+		t.Fatalf("expected a plain timeout without a condition error, got %v", err)
+	}
+}
+
+func TestAuthKeyConcurrentRefreshAndLogin(t *testing.T) {
+	tsm, tsClient, _ := newTestTailscaleService(t, testNode(), netip.MustParseAddr("100.64.0.7"), headscaletest.New())
+	tsm.setTailscaleEnv(&TailscaleEnv{hostName: "node-1"})
+	tsm.setAuthKey("initial-key", time.Now().Add(24*time.Hour))
+
+	// 后台刷新、登录和过期清理并发访问密钥，需配合 -race 运行
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				if err := tsm.refreshAuthKeyFromHeadscale(); err != nil {
+					t.Errorf("refreshAuthKeyFromHeadscale failed: %v", err)
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				if err := tsm.tryLoginWithAuthKey(); err != nil {
+					t.Errorf("tryLoginWithAuthKey failed: %v", err)
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				tsm.cleanupExpiredAuthKey()
+				tsm.checkAndRefreshAuthKeyIfNeeded()
+			}
+		}()
+	}
+	wg.Wait()
+
+	authKey, expiration := tsm.currentAuthKey()
+	if !authKeyValid(authKey, expiration) {
+		t.Fatalf("expected a valid auth key after concurrent refreshes, got %q (expires %v)", authKey, expiration)
+	}
+	if indexOf(tsClient.Calls(), "UpWithOptions") < 0 {
+		t.Fatalf("expected login attempts, got calls %v", tsClient.Calls())
+	}
+}
+
+func TestConnectAdvertiseRoutesAtLogin(t *testing.T) {
+	node := &coreV1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+		Spec:       coreV1.NodeSpec{PodCIDR: "10.42.1.0/24"},
+	}
+	tsm, tsClient, _ := newTestTailscaleService(t, node, netip.MustParseAddr("100.64.0.7"), headscaletest.New())
+	cfg := tsm.preparer.GetConfig()
+	cfg.Tailscale.AdvertiseExtraRoutes = []string{"192.168.100.0/24"}
+	tsm.hostname = node.Name
+	tsm.setTailscaleEnv(&TailscaleEnv{hostName: node.Name})
+	tsm.setAuthKey("key", time.Now().Add(time.Hour))
+
+	// 未开启时登录不带路由
+	if err := tsm.tryLoginWithAuthKey(); err != nil {
+		t.Fatalf("tryLoginWithAuthKey failed: %v", err)
+	}
+	if routes := tsClient.AdvertisedRoutes(); len(routes) != 0 {
+		t.Fatalf("expected no routes at login by default, got %v", routes)
+	}
+
+	cfg.Tailscale.AdvertiseOnConnect = true
+	if err := tsm.tryLoginWithAuthKey(); err != nil {
+		t.Fatalf("tryLoginWithAuthKey failed: %v", err)
+	}
+	routes := tsClient.AdvertisedRoutes()
+	if len(routes) != 2 || routes[0] != netip.MustParsePrefix("10.42.1.0/24") || routes[1] != netip.MustParsePrefix("192.168.100.0/24") {
+		t.Fatalf("expected Pod CIDR and extra route at login, got %v", routes)
+	}
+	if !tsm.managedRoutes.managed(netip.MustParsePrefix("10.42.1.0/24")) {
+		t.Fatalf("expected routes advertised at login to be recorded as managed")
+	}
+
+	// 连接后的收敛无需再次修改偏好
+	tsClient.ResetCalls()
+	if err := tsm.ensureTailscaleRoute("10.42.1.0/24"); err != nil {
+		t.Fatalf("ensureTailscaleRoute failed: %v", err)
+	}
+	if indexOf(tsClient.Calls(), "AdvertiseRoutes") >= 0 {
+		t.Fatalf("expected no separate advertise after connect, got calls %v", tsClient.Calls())
+	}
+
+	// Pod CIDR 未知时回退为连接后通告
+	tsm.hostname = "node-without-cidr"
+	if advertise := tsm.connectAdvertiseRoutes(); advertise != nil {
+		t.Fatalf("expected fallback to post-connect advertise, got %v", advertise)
+	}
+}
+
+func TestGetNodePodCIDRFallback(t *testing.T) {
+	node := &coreV1.Node{ObjectMeta: metav1.ObjectMeta{
+		Name:        "node-1",
+		Annotations: map[string]string{constants.HeadcniPodCIDROverrideAnnotationKey: "10.42.7.0/24"},
+	}}
+	tsm, _, clientset := newTestTailscaleService(t, node, netip.MustParseAddr("100.64.0.7"), headscaletest.New())
+	t.Setenv("POD_NAMESPACE", "headcni")
+	preparer := tsm.preparer
+	// 派生切片需要在 ConfigMap 中认领
+	preparer.k8sClient = k8s.NewClientForClientset(clientset, &k8s.ClientConfig{
+		BasePermissions: &k8s.PermissionStatus{CanListNodes: true, CanGetNodes: true, CanListConfigMaps: true, CanGetConfigMaps: true},
+	})
+	preparer.config.Network.PodCIDR.Fallback = "10.42.128.0/17"
+	shared := netip.MustParsePrefix("10.42.128.0/17")
+
+	setNode := func(mutate func(*coreV1.Node)) {
+		current, err := clientset.CoreV1().Nodes().Get(context.Background(), "node-1", metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("failed to get node: %v", err)
+		}
+		mutate(current)
+		if _, err := clientset.CoreV1().Nodes().Update(context.Background(), current, metav1.UpdateOptions{}); err != nil {
+			t.Fatalf("failed to update node: %v", err)
+		}
+	}
+
+	for _, tc := range []struct {
+		name       string
+		annotation string
+		specCIDR   string
+		want       string
+	}{
+		{"annotation preferred over config", "10.42.7.0/24", "", "10.42.7.0/24"},
+		// 配置的回退网段由所有节点共用，节点使用从中派生的 /24 切片
+		{"annotation outside base falls through to config", "192.168.7.0/24", "", "shared"},
+		{"invalid annotation falls through to config", "not-a-cidr", "", "shared"},
+		{"API value wins", "10.42.7.0/24", "10.42.1.0/24", "10.42.1.0/24"},
+	} {
+		setNode(func(n *coreV1.Node) {
+			n.Annotations[constants.HeadcniPodCIDROverrideAnnotationKey] = tc.annotation
+			n.Spec.PodCIDR = tc.specCIDR
+		})
+		got, err := preparer.GetNodePodCIDR("node-1")
+		if tc.want == "shared" {
+			slice, parseErr := netip.ParsePrefix(got)
+			if err != nil || parseErr != nil || slice.Bits() != 24 || !shared.Contains(slice.Addr()) {
+				t.Errorf("%s: expected a /24 slice of %s, got %q (%v)", tc.name, shared, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("%s: expected %s, got %q (%v)", tc.name, tc.want, got, err)
+		}
+	}
+
+	// 回退网段不能再切分时报错，不把同一网段分给每个节点
+	setNode(func(n *coreV1.Node) {
+		n.Annotations[constants.HeadcniPodCIDROverrideAnnotationKey] = ""
+		delete(n.Annotations, constants.HeadcniPodCIDRAnnotationKey)
+		n.Spec.PodCIDR = ""
+	})
+	preparer.config.Network.PodCIDR.Fallback = "10.42.9.0/24"
+	if got, err := preparer.GetNodePodCIDR("node-1"); err == nil {
+		t.Errorf("expected an error for a fallback that cannot be split, got %q", got)
+	}
+
+	setNode(func(n *coreV1.Node) {
+		n.Annotations[constants.HeadcniPodCIDROverrideAnnotationKey] = ""
+		n.Spec.PodCIDR = ""
+	})
+	preparer.config.Network.PodCIDR.Fallback = ""
+	if got, err := preparer.GetNodePodCIDR("node-1"); err == nil {
+		t.Errorf("expected an error without any Pod CIDR source, got %q", got)
+	}
+}
+
+func TestPreferredDERPPinnedAtLoginAndReload(t *testing.T) {
+	tsm, tsClient, _ := newTestTailscaleService(t, testNode(), netip.MustParseAddr("100.64.0.7"), headscaletest.New())
+	tsClient.SetDERPRegions(1, 2)
+	tsm.setTailscaleEnv(&TailscaleEnv{hostName: "node-1"})
+	tsm.setAuthKey("key", time.Now().Add(time.Hour))
+
+	oldConfig := *tsm.preparer.GetConfig()
+	tsm.preparer.oldConfig = &oldConfig
+	cfg := tsm.preparer.GetConfig()
+	cfg.Tailscale.PreferredDERP = 2
+	if change, reasons := tsm.classifyConfigChange(); change != configChangeLive || indexOf(reasons, "tailscale.preferredDERP") < 0 {
+		t.Fatalf("expected preferredDERP to be a live change, got %v %v", change, reasons)
+	}
+
+	if err := tsm.tryLoginWithAuthKey(); err != nil {
+		t.Fatalf("tryLoginWithAuthKey failed: %v", err)
+	}
+	if got := tsClient.PreferredDERP(); got != 2 {
+		t.Fatalf("expected DERP region 2 pinned at login, got %d", got)
+	}
+
+	// 未知区域只告警，保持当前区域
+	tsm.applyPreferredDERP(9)
+	if got := tsClient.PreferredDERP(); got != 2 {
+		t.Fatalf("expected unknown region to keep region 2, got %d", got)
+	}
+
+	tsm.applyPreferredDERP(0)
+	if got := tsClient.PreferredDERP(); got != 0 {
+		t.Fatalf("expected automatic selection restored, got %d", got)
+	}
+}
+
+func TestWatchdogDetectsWedgedTailscaled(t *testing.T) {
+	tsm, tsClient, _ := newTestTailscaleService(t, testNode(), netip.MustParseAddr("100.64.0.7"), headscaletest.New())
+	cfg := tsm.preparer.GetConfig()
+	cfg.Tailscale.Mode = "host"
+	cfg.Tailscale.WatchdogTimeout = "20ms"
+	cfg.Tailscale.WatchdogThreshold = 2
+	tsm.updateHealthStatus(true, nil)
+
+	var watchdog tailscaledWatchdog
+	tsm.checkTailscaledWedged(&watchdog)
+	if watchdog.consecutive != 0 {
+		t.Fatalf("expected a responsive tailscaled to reset the counter, got %d", watchdog.consecutive)
+	}
+
+	// 卡死的调用不遵守 context，看门狗仍需按时返回
+	release := tsClient.Hang("GetStatusFresh")
+	defer release()
+	start := time.Now()
+	tsm.checkTailscaledWedged(&watchdog)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("probe blocked for %v", elapsed)
+	}
+	if info, _ := GetGlobalHealthManager().GetServiceInfo(tsm.Name()); !info.Running {
+		t.Fatalf("expected a single timeout below the threshold to keep the service healthy, got %+v", info)
+	}
+
+	tsm.checkTailscaledWedged(&watchdog)
+	info, _ := GetGlobalHealthManager().GetServiceInfo(tsm.Name())
+	if info.Running || !strings.Contains(info.Error, "wedged") {
+		t.Fatalf("expected host mode to surface a wedged tailscaled as unhealthy, got %+v", info)
+	}
+
+	// 恢复后计数清零
+	release()
+	deadline := time.Now().Add(time.Second)
+	for watchdog.inFlight.Load() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	tsm.checkTailscaledWedged(&watchdog)
+	if watchdog.consecutive != 0 {
+		t.Fatalf("expected the counter to reset once tailscaled responds, got %d", watchdog.consecutive)
+	}
+}
+
+func TestImportTailscaleStateRefusesWhileHostTailscaledRuns(t *testing.T) {
+	tsm, _, _ := newTestTailscaleService(t, testNode(), netip.MustParseAddr("100.64.0.7"), headscaletest.New())
+	dir := t.TempDir()
+	tsm.setTailscaleEnv(&TailscaleEnv{statePath: filepath.Join(dir, "tailscaled.state")})
+	tsm.preparer.GetConfig().Tailscale.ImportStateFrom = filepath.Join(dir, "host.state")
+
+	// Unix socket 路径不能超过 108 字节，t.TempDir() 可能超出
+	socketDir, err := os.MkdirTemp("", "hs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(socketDir) })
+	socketPath := hostSocketPath
+	hostSocketPath = filepath.Join(socketDir, "tailscaled.sock")
+	t.Cleanup(func() { hostSocketPath = socketPath })
+
+	listener, err := net.Listen("unix", hostSocketPath)
+	if err != nil {
+		t.Fatalf("failed to listen on %s: %v", hostSocketPath, err)
+	}
+	err = tsm.importTailscaleState()
+	if err == nil || !strings.Contains(err.Error(), "still running") {
+		t.Fatalf("expected import to be refused while host tailscaled responds, got %v", err)
+	}
+
+	// 主机 tailscaled 停止后继续导入，源文件不存在时导入失败
+	listener.Close()
+	err = tsm.importTailscaleState()
+	if err == nil || strings.Contains(err.Error(), "still running") {
+		t.Fatalf("expected the import itself to run once host tailscaled stopped, got %v", err)
+	}
+}
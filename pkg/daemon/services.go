@@ -9,6 +9,16 @@ import (
 )
 
 // Service 定义了可管理服务的接口
+//
+// 实现约定：
+//   - Name 返回唯一且固定的服务名，同名服务注册时后者替换前者
+//   - Start 必须幂等，服务已运行时直接返回 nil
+//   - Stop 在服务未运行时调用必须安全，直接返回 nil
+//   - Reload 仅在服务运行时被调用，失败时守护进程会回退为 Stop + Start
+//   - IsRunning 不得阻塞，ServiceManager 依据它决定是否调用 Start/Reload/Stop
+//
+// 服务可以通过 GetGlobalHealthManager().UpdateServiceStatus 上报更详细的健康状态；
+// 未自行上报的服务由 ServiceManager 根据 Start/Stop 结果汇总
 type Service interface {
 	Name() string
 	Start(ctx context.Context) error
@@ -17,9 +27,33 @@ type Service interface {
 	IsRunning() bool
 }
 
+var (
+	customServices   []Service
+	customServicesMu sync.Mutex
+)
+
+// RegisterService 注册自定义服务，使其加入守护进程的生命周期
+// 必须在 InitDaemon 之前调用；自定义服务在内置服务之后按注册顺序启动，停止时逆序
+func RegisterService(svc Service) {
+	customServicesMu.Lock()
+	defer customServicesMu.Unlock()
+
+	customServices = append(customServices, svc)
+}
+
+// registeredServices 返回已注册的自定义服务
+func registeredServices() []Service {
+	customServicesMu.Lock()
+	defer customServicesMu.Unlock()
+
+	return append([]Service(nil), customServices...)
+}
+
 // ServiceManager 管理多个服务
+// 按注册顺序启动和重载，按相反顺序停止
 type ServiceManager struct {
 	services map[string]Service
+	order    []string
 	mu       sync.RWMutex
 }
 
@@ -34,32 +68,54 @@ func NewServiceManager() *ServiceManager {
 func (sm *ServiceManager) RegisterService(svc Service) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
-	sm.services[svc.Name()] = svc
+
+	name := svc.Name()
+	if _, exists := sm.services[name]; !exists {
+		sm.order = append(sm.order, name)
+		GetGlobalHealthManager().RegisterService(name)
+	} else {
+		logging.Warnf("Service %s already registered, replacing it", name)
+	}
+	sm.services[name] = svc
 }
 
-// StartAll 启动所有注册的服务
+// StartAll 按注册顺序启动所有服务，任一服务启动失败时逆序停止已启动的服务
 func (sm *ServiceManager) StartAll(ctx context.Context) error {
 	sm.mu.RLock()
 	defer sm.mu.RUnlock()
 
-	for name, svc := range sm.services {
-		if !svc.IsRunning() {
-			logging.Infof("Starting service: %s", name)
-			if err := svc.Start(ctx); err != nil {
-				return fmt.Errorf("failed to start service %s: %v", name, err)
-			}
+	healthMgr := GetGlobalHealthManager()
+	var started []string
+	for _, name := range sm.order {
+		svc := sm.services[name]
+		if svc.IsRunning() {
+			continue
+		}
+
+		logging.Infof("Starting service: %s", name)
+		if err := svc.Start(ctx); err != nil {
+			healthMgr.UpdateServiceStatus(name, false, err)
+			sm.stopServices(started)
+			return fmt.Errorf("failed to start service %s: %v", name, err)
+		}
+		started = append(started, name)
+
+		// 服务未自行上报健康状态时由这里汇总
+		if info, ok := healthMgr.GetServiceInfo(name); !ok || (!info.Running && info.Error == "") {
+			healthMgr.UpdateServiceStatus(name, svc.IsRunning(), nil)
 		}
 	}
 	return nil
 }
 
-// ReloadAll 重载所有注册的服务
+// ReloadAll 按注册顺序重载所有服务
 func (sm *ServiceManager) ReloadAll(ctx context.Context) error {
 	sm.mu.RLock()
 	defer sm.mu.RUnlock()
 
 	var errs []error
-	for name, svc := range sm.services {
+	for _, name := range sm.order {
+		svc := sm.services[name]
 		if svc.IsRunning() {
 			logging.Infof("Reloading service: %s", name)
 			if err := svc.Reload(ctx); err != nil {
@@ -73,22 +129,52 @@ func (sm *ServiceManager) ReloadAll(ctx context.Context) error {
 	return nil
 }
 
-// StopAll 停止所有注册的服务
+// StopAll 按注册的相反顺序停止所有服务
 func (sm *ServiceManager) StopAll() error {
 	sm.mu.RLock()
 	defer sm.mu.RUnlock()
 
+	return sm.stopServices(sm.order)
+}
+
+// stopServices 逆序停止指定服务，调用方需持有锁
+func (sm *ServiceManager) stopServices(names []string) error {
+	healthMgr := GetGlobalHealthManager()
+
 	var errs []error
-	for name, svc := range sm.services {
-		if svc.IsRunning() {
-			logging.Infof("Stopping service: %s", name)
-			if err := svc.Stop(context.Background()); err != nil {
-				errs = append(errs, fmt.Errorf("failed to stop service %s: %v", name, err))
-			}
+	for i := len(names) - 1; i >= 0; i-- {
+		name := names[i]
+		svc := sm.services[name]
+		if !svc.IsRunning() {
+			continue
 		}
+
+		logging.Infof("Stopping service: %s", name)
+		err := svc.Stop(context.Background())
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to stop service %s: %v", name, err))
+		}
+		healthMgr.UpdateServiceStatus(name, false, err)
 	}
 	if len(errs) > 0 {
 		return fmt.Errorf("errors stopping services: %v", errs)
 	}
 	return nil
 }
+
+// ServiceNames 按启动顺序返回已注册的服务名
+func (sm *ServiceManager) ServiceNames() []string {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	return append([]string(nil), sm.order...)
+}
+
+// GetService 按名称返回已注册的服务
+func (sm *ServiceManager) GetService(name string) (Service, bool) {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	svc, ok := sm.services[name]
+	return svc, ok
+}
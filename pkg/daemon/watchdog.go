@@ -0,0 +1,142 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/binrclab/headcni/cmd/daemon/config"
+	"github.com/binrclab/headcni/pkg/backend/tailscale"
+	"github.com/binrclab/headcni/pkg/logging"
+	"github.com/binrclab/headcni/pkg/monitoring"
+)
+
+// tailscaledWatchdog 检测 socket 和进程都在、但 GetStatus 既不返回也不报错的 tailscaled
+// 调用的超时依赖 LocalAPI 遵守 context，卡死的 tailscaled 会让调用一直阻塞，因此在调用之外单独计时
+type tailscaledWatchdog struct {
+	inFlight    atomic.Bool // 上一次探测的 GetStatus 仍未返回
+	consecutive int         // 连续超时次数
+}
+
+// probe 以 timeout 为硬超时调用一次 GetStatusFresh（绕过状态缓存），返回调用是否超时
+// 调用返回错误（如 socket 不存在）不算超时，由保活检查处理；上一次调用仍未返回时不再发起新的调用，直接记为超时
+func (w *tailscaledWatchdog) probe(ctx context.Context, client tailscale.TailscaleClient, timeout time.Duration) bool {
+	if !w.inFlight.CompareAndSwap(false, true) {
+		return true
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer w.inFlight.Store(false)
+		callCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		client.GetStatusFresh(callCtx)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// watchdogTimeout 返回探测的硬超时（tailscale.watchdogTimeout）
+func (tsm *TailscaleService) watchdogTimeout() time.Duration {
+	if cfg := tsm.preparer.GetConfig(); cfg != nil && cfg.Tailscale.WatchdogTimeout != "" {
+		if timeout, err := time.ParseDuration(cfg.Tailscale.WatchdogTimeout); err == nil && timeout > 0 {
+			return timeout
+		}
+	}
+	return config.DefaultWatchdogTimeout
+}
+
+// watchdogThreshold 返回判定卡死所需的连续超时次数（tailscale.watchdogThreshold）
+func (tsm *TailscaleService) watchdogThreshold() int {
+	if cfg := tsm.preparer.GetConfig(); cfg != nil && cfg.Tailscale.WatchdogThreshold > 0 {
+		return cfg.Tailscale.WatchdogThreshold
+	}
+	return config.DefaultWatchdogThreshold
+}
+
+// tailscaledWatchdogLoop 看门狗协程，按健康检查间隔探测 tailscaled
+// 与健康检查分开运行，健康检查被卡死的调用阻塞时仍能发现问题
+func (tsm *TailscaleService) tailscaledWatchdogLoop() {
+	var watchdog tailscaledWatchdog
+	interval := tsm.healthCheckInterval()
+	ticker := time.NewTicker(withJitter(interval))
+	defer ticker.Stop()
+	intervalChanged := tsm.intervalWatch()
+
+	logging.Infof("Starting tailscaled watchdog every %v (timeout %v, threshold %d)", interval, tsm.watchdogTimeout(), tsm.watchdogThreshold())
+
+	for {
+		select {
+		case <-tsm.ctx.Done():
+			logging.Infof("Tailscaled watchdog stopped")
+			return
+		case <-intervalChanged:
+			intervalChanged = tsm.intervalWatch()
+			interval = resetTicker(ticker, interval, tsm.healthCheckInterval(), "Watchdog")
+		case <-ticker.C:
+			ticker.Reset(withJitter(interval))
+			tsm.checkTailscaledWedged(&watchdog)
+		}
+	}
+}
+
+// checkTailscaledWedged 执行一次探测，连续超时达到阈值时认为 tailscaled 卡死
+// daemon 模式用现有状态重启 tailscaled，节点身份不变；host 模式不管理 tailscaled 进程，只把服务标记为不健康，直到探测恢复
+func (tsm *TailscaleService) checkTailscaledWedged(watchdog *tailscaledWatchdog) {
+	timeout, threshold := tsm.watchdogTimeout(), tsm.watchdogThreshold()
+	if !watchdog.probe(tsm.ctx, tsm.preparer.GetTailscaleClient(), timeout) {
+		if watchdog.consecutive >= threshold {
+			logging.Infof("Tailscaled responds to GetStatus again")
+		}
+		watchdog.consecutive = 0
+		return
+	}
+	if tsm.ctx.Err() != nil {
+		return
+	}
+
+	watchdog.consecutive++
+	logging.Warnf("Tailscaled GetStatus did not return within %v (%d/%d)", timeout, watchdog.consecutive, threshold)
+	if watchdog.consecutive < threshold {
+		return
+	}
+
+	mode := tsm.preparer.GetConfig().Tailscale.Mode
+	err := fmt.Errorf("tailscaled is wedged: GetStatus did not return within %v %d times in a row", timeout, watchdog.consecutive)
+	if mode == "host" {
+		if watchdog.consecutive == threshold {
+			monitoring.RecordTailscaledHang(mode)
+		}
+		tsm.updateHealthStatusWithLog(false, err, "Host tailscaled unhealthy: %v", err)
+		return
+	}
+
+	monitoring.RecordTailscaledHang(mode)
+	tsm.updateHealthStatusWithLog(false, err, "%v, restarting tailscaled", err)
+	watchdog.consecutive = 0
+	if err := tsm.restartWedgedTailscaled(); err != nil {
+		logging.Errorf("Failed to restart wedged tailscaled: %v", err)
+	}
+}
+
+// restartWedgedTailscaled 停止卡死的 tailscaled 并用现有状态文件重新启动
+// 卡死不代表状态损坏，删除状态文件会让节点以新身份注册并在 Headscale 中留下原节点
+// 先停止进程再获取 restartMu：socket 关闭后，阻塞在 LocalAPI 调用上的保活检查随之返回并释放 restartMu
+func (tsm *TailscaleService) restartWedgedTailscaled() error {
+	if err := tsm.preparer.GetTailscaleService().StopService(tsm.ctx, tsm.serviceName); err != nil {
+		logging.Warnf("Failed to stop wedged tailscaled: %v", err)
+	}
+
+	tsm.restartMu.Lock()
+	defer tsm.restartMu.Unlock()
+	return tsm.restartWithExistingData()
+}
@@ -49,12 +49,24 @@ func ProvideDaemonFromPreparer(cfg *config.Config, preparer *Preparer) (*Daemon,
 
 	serviceManager := NewServiceManager()
 
-	serviceManager.RegisterService(NewCNIService(preparer))
-	serviceManager.RegisterService(NewPodMonitoringService(preparer))
+	tailscaleService := NewTailscaleService(preparer)
+	serviceManager.RegisterService(NewCNIService(preparer, tailscaleService))
+	// router 模式节点不承载 Pod，不需要 Pod 监控和网络策略
+	if !cfg.IsRouterMode() {
+		serviceManager.RegisterService(NewPodMonitoringService(preparer, tailscaleService))
+	}
 	serviceManager.RegisterService(NewHeadscaleHealthService(preparer))
-	serviceManager.RegisterService(NewTailscaleService(preparer))
+	serviceManager.RegisterService(tailscaleService)
+	if !cfg.IsRouterMode() {
+		serviceManager.RegisterService(NewNetworkPolicyService(preparer))
+	}
+	serviceManager.RegisterService(NewHeadscaleHousekeepingService(preparer))
 	serviceManager.RegisterService(NewMonitoringService(preparer))
 
+	for _, svc := range registeredServices() {
+		serviceManager.RegisterService(svc)
+	}
+
 	daemon := NewDaemon(cfg, preparer, serviceManager)
 
 	return daemon, nil
@@ -0,0 +1,35 @@
+package headscale
+
+import "context"
+
+var _ HeadscaleAPI = (*Client)(nil)
+
+// HeadscaleAPI daemon 和 CLI 使用的 Headscale API
+// NewClient 返回的 *Client 实现该接口，单元测试中可替换为 headscaletest.Client
+type HeadscaleAPI interface {
+	// 节点管理
+	ListNodes(ctx context.Context, user string) (*ListNodesResponse, error)
+	GetNode(ctx context.Context, nodeID string) (*GetNodeResponse, error)
+	GetNodeByKey(ctx context.Context, nodeKey string) (*Node, error)
+	SetNodeTags(ctx context.Context, nodeID string, tags []string) (*SetTagsResponse, error)
+	DeleteNode(ctx context.Context, nodeID string) error
+	ExpireNode(ctx context.Context, nodeID string) (*GetNodeResponse, error)
+	GetNodeRoutes(ctx context.Context, nodeID string) (*GetNodeRoutesResponse, error)
+	FindNodesByHostnamePrefix(ctx context.Context, prefix string) ([]Node, error)
+	FindNodeForIP(ctx context.Context, ip string) (*Node, string, error)
+	ReconcileDuplicateNodes(ctx context.Context, prefix, keepNodeID string) error
+	CleanupExpiredNodes(ctx context.Context, owned NodeFilter) error
+
+	// 预授权密钥管理
+	CreatePreAuthKey(ctx context.Context, req *CreatePreAuthKeyRequest) (*CreatePreAuthKeyResponse, error)
+
+	// 路由管理
+	GetRoutes(ctx context.Context) (*GetRoutesResponse, error)
+	EnableRoute(ctx context.Context, routeID string) error
+	DisableRoute(ctx context.Context, routeID string) error
+	ApproveRoute(ctx context.Context, nodeID, routePrefix string) error
+	PruneOrphanRoutes(ctx context.Context, owned NodeFilter) (int, error)
+
+	// 认证：API 密钥轮换后替换，之后的请求使用新密钥
+	SetAuthKey(key string)
+}
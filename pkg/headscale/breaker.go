@@ -0,0 +1,146 @@
+package headscale
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/binrclab/headcni/pkg/logging"
+	"github.com/binrclab/headcni/pkg/monitoring"
+)
+
+const (
+	// DefaultBreakerFailureThreshold 连续失败多少次后打开熔断器
+	DefaultBreakerFailureThreshold = 5
+	// DefaultBreakerCooldown 熔断器打开后放行探测请求前的冷却时间
+	DefaultBreakerCooldown = 30 * time.Second
+)
+
+// ErrCircuitOpen 熔断器打开期间请求被直接拒绝
+var ErrCircuitOpen = errors.New("headscale circuit breaker is open")
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerHalfOpen
+	breakerOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerHalfOpen:
+		return "half-open"
+	case breakerOpen:
+		return "open"
+	default:
+		return "closed"
+	}
+}
+
+// circuitBreaker Headscale 请求熔断器
+// 连续失败达到阈值后打开，冷却期内直接拒绝请求；冷却结束后只放行一个探测请求，
+// 探测成功则关闭，失败则重新打开
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	state     breakerState
+	failures  int
+	openedAt  time.Time
+	probing   bool
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	if threshold <= 0 {
+		threshold = DefaultBreakerFailureThreshold
+	}
+	if cooldown <= 0 {
+		cooldown = DefaultBreakerCooldown
+	}
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown}
+}
+
+// allow 判断是否放行请求，拒绝时返回包装了 ErrCircuitOpen 的错误
+func (b *circuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if remaining := b.cooldown - time.Since(b.openedAt); remaining > 0 {
+			monitoring.RecordHeadscaleRequestRejected()
+			return fmt.Errorf("%w, retry in %v", ErrCircuitOpen, remaining.Round(time.Millisecond))
+		}
+		b.setState(breakerHalfOpen)
+		b.probing = true
+		return nil
+	case breakerHalfOpen:
+		if b.probing {
+			monitoring.RecordHeadscaleRequestRejected()
+			return fmt.Errorf("%w, probe in progress", ErrCircuitOpen)
+		}
+		b.probing = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+// record 记录请求结果，Headscale 有响应（包括 4xx）视为成功
+func (b *circuitBreaker) record(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if success {
+		b.failures = 0
+		b.probing = false
+		if b.state != breakerClosed {
+			b.setState(breakerClosed)
+		}
+		return
+	}
+
+	switch b.state {
+	case breakerClosed:
+		b.failures++
+		if b.failures >= b.threshold {
+			b.open()
+		}
+	case breakerHalfOpen:
+		if b.probing {
+			b.probing = false
+			b.open()
+		}
+	}
+}
+
+// release 请求因 ctx 取消而结束，不计入结果，允许下一个探测请求
+func (b *circuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == breakerHalfOpen {
+		b.probing = false
+	}
+}
+
+func (b *circuitBreaker) open() {
+	b.openedAt = time.Now()
+	b.setState(breakerOpen)
+}
+
+func (b *circuitBreaker) setState(state breakerState) {
+	from := b.state
+	b.state = state
+	monitoring.UpdateHeadscaleCircuitBreaker(state.String())
+
+	switch state {
+	case breakerOpen:
+		logging.Warnf("Headscale circuit breaker opened after %d consecutive failures, failing fast for %v", b.failures, b.cooldown)
+	case breakerHalfOpen:
+		logging.Infof("Headscale circuit breaker half-open, letting a probe request through")
+	case breakerClosed:
+		logging.Infof("Headscale circuit breaker closed (was %s)", from)
+	}
+}
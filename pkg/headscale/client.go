@@ -4,11 +4,14 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"net/netip"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/binrclab/headcni/cmd/daemon/config"
@@ -21,9 +24,11 @@ import (
 // Client 是 Headscale 客户端
 type Client struct {
 	baseURL         string
+	authKeyMu       sync.RWMutex
 	authKey         string
 	retryCount      int
 	retryableClient *retryablehttp.Client
+	breaker         *circuitBreaker
 }
 
 // API 响应结构体
@@ -214,11 +219,26 @@ func NewClient(cfg *config.HeadscaleConfig) (*Client, error) {
 		retries = 3 // 默认重试3次
 	}
 
+	// 解析熔断配置
+	cooldown := DefaultBreakerCooldown
+	if cfg.CircuitBreaker.Cooldown != "" {
+		if d, err := time.ParseDuration(cfg.CircuitBreaker.Cooldown); err == nil {
+			cooldown = d
+		}
+	}
+
 	client := &Client{
 		baseURL:         strings.TrimSuffix(cfg.URL, "/"),
 		authKey:         cfg.AuthKey,
 		retryableClient: newRetryableClient(retries, timeout),
 		retryCount:      retries,
+		breaker:         newCircuitBreaker(cfg.CircuitBreaker.FailureThreshold, cooldown),
+	}
+
+	// headscale 组件日志为 debug 时记录每次 API 调用的请求和响应
+	if tracingEnabled() {
+		enableRequestTracing(client.retryableClient)
+		logging.Infof("Headscale API request tracing enabled")
 	}
 
 	logging.Infof("Headscale client initialized - URL: %s, Timeout: %v, Retries: %d",
@@ -227,6 +247,19 @@ func NewClient(cfg *config.HeadscaleConfig) (*Client, error) {
 	return client, nil
 }
 
+// SetAuthKey 替换 API 密钥，用于 Secret 中的密钥轮换，进行中的请求不受影响
+func (c *Client) SetAuthKey(key string) {
+	c.authKeyMu.Lock()
+	defer c.authKeyMu.Unlock()
+	c.authKey = key
+}
+
+func (c *Client) getAuthKey() string {
+	c.authKeyMu.RLock()
+	defer c.authKeyMu.RUnlock()
+	return c.authKey
+}
+
 // retryableLogger 适配 zap.SugaredLogger 到 go-retryablehttp 的 Logger 接口
 type retryableLogger struct {
 	logger *zap.SugaredLogger
@@ -300,26 +333,41 @@ func (c *Client) doRequest(ctx context.Context, method, path string, body interf
 		bodyReader = bytes.NewReader(jsonBody)
 	}
 
+	if c.retryableClient.ResponseLogHook != nil {
+		ctx = withRequestTrace(ctx)
+	}
+
 	req, err := retryablehttp.NewRequestWithContext(ctx, method, fmt.Sprintf("%s%s", c.baseURL, path), bodyReader)
 	if err != nil {
 		return fmt.Errorf("failed to create request: %v", err)
 	}
 
 	// 添加认证头
-	if c.authKey != "" {
-		req.Header.Set("Authorization", "Bearer "+c.authKey)
+	if authKey := c.getAuthKey(); authKey != "" {
+		req.Header.Set("Authorization", "Bearer "+authKey)
 	}
 
 	if body != nil {
 		req.Header.Set("Content-Type", "application/json")
 	}
 
+	// Headscale 不可用时熔断器直接拒绝，避免每次都等待完整的重试
+	if err := c.breaker.allow(); err != nil {
+		return err
+	}
+
 	// 直接使用已配置的 retryableClient，不需要重复配置
 	resp, err := c.retryableClient.Do(req)
 	if err != nil {
+		if ctx.Err() != nil {
+			c.breaker.release()
+		} else {
+			c.breaker.record(false)
+		}
 		return fmt.Errorf("request failed after retries: %v", err)
 	}
 	defer resp.Body.Close()
+	c.breaker.record(resp.StatusCode < 500)
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		bodyBytes, _ := io.ReadAll(resp.Body)
@@ -616,7 +664,10 @@ func (c *Client) ValidateNodeKey(ctx context.Context, nodeKey string) (bool, err
 	return false, nil
 }
 
-// GetNodeByKey 通过节点密钥获取节点信息
+// ErrNodeNotFound Headscale 中没有对应的节点
+var ErrNodeNotFound = errors.New("node not found")
+
+// GetNodeByKey 通过节点密钥获取节点信息，节点不存在时返回 ErrNodeNotFound
 func (c *Client) GetNodeByKey(ctx context.Context, nodeKey string) (*Node, error) {
 	nodes, err := c.ListNodes(ctx, "")
 	if err != nil {
@@ -629,18 +680,26 @@ func (c *Client) GetNodeByKey(ctx context.Context, nodeKey string) (*Node, error
 		}
 	}
 
-	return nil, fmt.Errorf("node with key %s not found", nodeKey)
+	return nil, fmt.Errorf("node with key %s: %w", nodeKey, ErrNodeNotFound)
+}
+
+// NodeFilter 判断节点是否在清理范围内
+type NodeFilter func(node Node) bool
+
+// matches owned 为 nil 时匹配所有节点
+func (f NodeFilter) matches(node Node) bool {
+	return f == nil || f(node)
 }
 
-// CleanupExpiredNodes 清理过期的节点
-func (c *Client) CleanupExpiredNodes(ctx context.Context) error {
+// CleanupExpiredNodes 清理 owned 匹配的过期节点，owned 为 nil 时清理所有过期节点
+func (c *Client) CleanupExpiredNodes(ctx context.Context, owned NodeFilter) error {
 	nodes, err := c.ListNodes(ctx, "")
 	if err != nil {
 		return err
 	}
 
 	for _, node := range nodes.Nodes {
-		if !node.Expiry.IsZero() && time.Now().After(node.Expiry) {
+		if !node.Expiry.IsZero() && time.Now().After(node.Expiry) && owned.matches(node) {
 			if err := c.DeleteNode(ctx, node.ID); err != nil {
 				return fmt.Errorf("failed to delete expired node %s: %v", node.ID, err)
 			}
@@ -650,6 +709,186 @@ func (c *Client) CleanupExpiredNodes(ctx context.Context) error {
 	return nil
 }
 
+// PruneOrphanRoutes 删除所属节点被 owned 匹配的孤立路由，返回删除的数量，owned 为 nil 时不限节点
+// 孤立路由指所属节点已不存在，或既未通告也未启用的路由；节点重新通告时 Headscale 会重新创建
+func (c *Client) PruneOrphanRoutes(ctx context.Context, owned NodeFilter) (int, error) {
+	nodes, err := c.ListNodes(ctx, "")
+	if err != nil {
+		return 0, fmt.Errorf("failed to list nodes: %v", err)
+	}
+	routes, err := c.GetRoutes(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get routes: %v", err)
+	}
+
+	existing := make(map[string]bool, len(nodes.Nodes))
+	for _, node := range nodes.Nodes {
+		existing[node.ID] = true
+	}
+
+	pruned := 0
+	var errs []string
+	for _, route := range routes.Routes {
+		if !owned.matches(route.Node) || (existing[route.Node.ID] && (route.Advertised || route.Enabled)) {
+			continue
+		}
+
+		if err := c.DeleteRoute(ctx, route.ID); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", route.ID, err))
+			continue
+		}
+		pruned++
+		logging.Infof("Deleted orphan headscale route %s (%s) of node %s", route.ID, route.Prefix, route.Node.ID)
+	}
+
+	if len(errs) > 0 {
+		return pruned, fmt.Errorf("failed to delete orphan routes: %s", strings.Join(errs, "; "))
+	}
+	return pruned, nil
+}
+
+// givenNameSuffixLength Headscale 主机名冲突时给 givenName 追加的随机后缀长度（"-" 之后）
+const givenNameSuffixLength = 8
+
+// MatchesHostname 判断节点名是否属于 hostname：完全相同，或为 Headscale 冲突时生成的 "<hostname>-<8 位小写字母数字>"
+// worker-1 不会匹配 worker-12 或 worker-1-db
+func MatchesHostname(name, hostname string) bool {
+	if name == hostname {
+		return true
+	}
+	suffix, ok := strings.CutPrefix(name, hostname+"-")
+	if !ok || len(suffix) != givenNameSuffixLength {
+		return false
+	}
+	for _, r := range suffix {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// FindNodesByHostnamePrefix 查找主机名（name 或 givenName）以 prefix 开头的节点
+// 结果可能包含 worker-12 这类只是前缀相同的节点，需要同一主机名时用 MatchesHostname 过滤
+func (c *Client) FindNodesByHostnamePrefix(ctx context.Context, prefix string) ([]Node, error) {
+	if prefix == "" {
+		return nil, fmt.Errorf("hostname prefix is empty")
+	}
+
+	nodes, err := c.ListNodes(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []Node
+	for _, node := range nodes.Nodes {
+		if strings.HasPrefix(node.Name, prefix) || strings.HasPrefix(node.GivenName, prefix) {
+			matched = append(matched, node)
+		}
+	}
+
+	return matched, nil
+}
+
+// ReconcileDuplicateNodes 删除与 keepNodeID 同主机名的陈旧注册
+// 在以 prefix 开头的节点中只处理与 prefix 主机名相同的节点（见 MatchesHostname），worker-1 不会删除 worker-12；
+// 只删除离线且 LastSeen 早于保留节点的重复节点，在线节点一律保留
+func (c *Client) ReconcileDuplicateNodes(ctx context.Context, prefix, keepNodeID string) error {
+	if keepNodeID == "" {
+		return fmt.Errorf("node ID to keep is empty")
+	}
+
+	candidates, err := c.FindNodesByHostnamePrefix(ctx, prefix)
+	if err != nil {
+		return fmt.Errorf("failed to find nodes with prefix %s: %v", prefix, err)
+	}
+	var nodes []Node
+	for _, node := range candidates {
+		if MatchesHostname(node.Name, prefix) || MatchesHostname(node.GivenName, prefix) {
+			nodes = append(nodes, node)
+		}
+	}
+
+	var keep *Node
+	for i := range nodes {
+		if nodes[i].ID == keepNodeID {
+			keep = &nodes[i]
+			break
+		}
+	}
+	if keep == nil {
+		return fmt.Errorf("node %s not found among nodes with hostname %s", keepNodeID, prefix)
+	}
+
+	var errs []string
+	for _, node := range nodes {
+		if node.ID == keepNodeID || node.Online {
+			continue
+		}
+		if !node.LastSeen.Before(keep.LastSeen) {
+			continue
+		}
+
+		if err := c.DeleteNode(ctx, node.ID); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", node.ID, err))
+			continue
+		}
+		logging.Infof("Deleted stale headscale node %s (%s), last seen %s",
+			node.ID, node.Name, node.LastSeen.Format(time.RFC3339))
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to delete duplicate nodes: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// ErrNoRouteForIP 没有已启用的路由包含查询的 IP
+var ErrNoRouteForIP = errors.New("no enabled route contains the IP")
+
+// FindNodeForIP 根据各节点通告的路由查找承载 ip 的节点，返回节点和匹配的路由前缀
+// 只考虑已启用的路由；多个前缀包含 ip 时取最长匹配，长度相同时优先主路由；没有匹配的路由时返回 ErrNoRouteForIP
+func (c *Client) FindNodeForIP(ctx context.Context, ip string) (*Node, string, error) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid IP address %q: %v", ip, err)
+	}
+	addr = addr.Unmap()
+
+	routes, err := c.GetRoutes(ctx)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get routes: %v", err)
+	}
+
+	var (
+		best       *Route
+		bestPrefix netip.Prefix
+	)
+	for i := range routes.Routes {
+		route := &routes.Routes[i]
+		if !route.Enabled {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(route.Prefix)
+		// 出口节点路由 0.0.0.0/0 和 ::/0 匹配所有地址，不代表 Pod 所在节点
+		if err != nil || prefix.Bits() == 0 || !prefix.Contains(addr) {
+			continue
+		}
+
+		if best == nil || prefix.Bits() > bestPrefix.Bits() ||
+			(prefix.Bits() == bestPrefix.Bits() && route.IsPrimary && !best.IsPrimary) {
+			best, bestPrefix = route, prefix
+		}
+	}
+
+	if best == nil {
+		return nil, "", fmt.Errorf("%w: %s", ErrNoRouteForIP, ip)
+	}
+
+	node := best.Node
+	return &node, best.Prefix, nil
+}
+
 // ListAllRoutes 获取所有路由
 func (c *Client) ListAllRoutes(ctx context.Context) (*ListAllRoutesResponse, error) {
 	var result ListAllRoutesResponse
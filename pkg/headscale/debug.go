@@ -0,0 +1,136 @@
+package headscale
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/binrclab/headcni/pkg/logging"
+	"github.com/hashicorp/go-retryablehttp"
+	"go.uber.org/zap/zapcore"
+)
+
+// maxTraceBodyBytes 调试日志中记录的请求体和响应体最大长度
+const maxTraceBodyBytes = 2048
+
+// apiLogger headscale 组件日志器，HEADCNI_LOG_LEVELS=headscale=debug 时记录完整的 API 请求和响应
+var apiLogger = logging.Named("headscale")
+
+var (
+	// sensitiveBodyPattern 匹配请求体和响应体中的密钥字段，截断后未闭合的值也会被匹配
+	sensitiveBodyPattern = regexp.MustCompile(`(?i)("(?:key|apiKey|authKey|preAuthKey)"\s*:\s*)"[^"]*("|$)`)
+
+	// sensitiveHeaders 需要屏蔽的请求头
+	sensitiveHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie"}
+
+	// sensitiveQueryParams 需要屏蔽的查询参数
+	sensitiveQueryParams = []string{"key", "authKey", "apiKey"}
+)
+
+type requestTraceKey struct{}
+
+// requestTrace 记录当前尝试的开始时间，每次重试时重置
+type requestTrace struct {
+	start time.Time
+}
+
+// withRequestTrace 为请求附加耗时记录
+func withRequestTrace(ctx context.Context) context.Context {
+	return context.WithValue(ctx, requestTraceKey{}, &requestTrace{})
+}
+
+// tracingEnabled headscale 组件日志为 debug 时启用请求跟踪
+func tracingEnabled() bool {
+	return apiLogger.Enabled(zapcore.DebugLevel)
+}
+
+// enableRequestTracing 在 retryablehttp 客户端上安装请求和响应日志钩子
+func enableRequestTracing(client *retryablehttp.Client) {
+	client.RequestLogHook = logTraceRequest
+	client.ResponseLogHook = logTraceResponse
+}
+
+// logTraceRequest 在每次尝试前记录方法、路径、请求头和请求体
+func logTraceRequest(_ retryablehttp.Logger, req *http.Request, attempt int) {
+	if trace, ok := req.Context().Value(requestTraceKey{}).(*requestTrace); ok {
+		trace.start = time.Now()
+	}
+
+	var body []byte
+	if req.GetBody != nil {
+		if reader, err := req.GetBody(); err == nil {
+			body, _ = io.ReadAll(io.LimitReader(reader, maxTraceBodyBytes+1))
+			reader.Close()
+		}
+	}
+
+	apiLogger.Debugf("Headscale request: %s %s (attempt %d) headers=%v body=%s",
+		req.Method, redactURL(req.URL), attempt+1, redactHeaders(req.Header), formatTraceBody(body))
+}
+
+// logTraceResponse 记录状态码、耗时和响应体，读取的部分会放回响应体供调用方解析
+func logTraceResponse(_ retryablehttp.Logger, resp *http.Response) {
+	var duration time.Duration
+	if trace, ok := resp.Request.Context().Value(requestTraceKey{}).(*requestTrace); ok && !trace.start.IsZero() {
+		duration = time.Since(trace.start)
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxTraceBodyBytes+1))
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+
+	apiLogger.Debugf("Headscale response: %s %s status=%d duration=%v body=%s",
+		resp.Request.Method, redactURL(resp.Request.URL), resp.StatusCode, duration, formatTraceBody(body))
+}
+
+// formatTraceBody 屏蔽密钥字段并截断过长的内容
+func formatTraceBody(body []byte) string {
+	if len(body) == 0 {
+		return "<empty>"
+	}
+
+	truncated := len(body) > maxTraceBodyBytes
+	if truncated {
+		body = body[:maxTraceBodyBytes]
+	}
+	masked := sensitiveBodyPattern.ReplaceAllString(string(body), `$1"***"`)
+	if truncated {
+		masked += "...(truncated)"
+	}
+	return masked
+}
+
+// redactHeaders 返回屏蔽了认证信息的请求头副本
+func redactHeaders(header http.Header) http.Header {
+	redacted := header.Clone()
+	for _, name := range sensitiveHeaders {
+		if redacted.Get(name) != "" {
+			redacted.Set(name, "***")
+		}
+	}
+	return redacted
+}
+
+// redactURL 返回路径和屏蔽了密钥参数的查询字符串
+func redactURL(u *url.URL) string {
+	if u.RawQuery == "" {
+		return u.Path
+	}
+
+	query := u.Query()
+	for _, name := range sensitiveQueryParams {
+		for key := range query {
+			if strings.EqualFold(key, name) {
+				query.Set(key, "***")
+			}
+		}
+	}
+	return u.Path + "?" + strings.ReplaceAll(query.Encode(), "%2A%2A%2A", "***")
+}
@@ -0,0 +1,564 @@
+// Package headscaletest 提供单元测试使用的内存版 headscale.HeadscaleAPI
+// 测试预先构造节点和路由，修改类调用按 Headscale 的方式作用在这些数据上，并记录每次调用，便于断言 daemon 操作了哪些路由
+package headscaletest
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/binrclab/headcni/pkg/headscale"
+)
+
+var _ headscale.HeadscaleAPI = (*Client)(nil)
+
+// Client 基于内存中节点和路由的 headscale.HeadscaleAPI 模拟实现
+type Client struct {
+	mu sync.Mutex
+
+	nodes   []headscale.Node
+	routes  []headscale.Route
+	keys    int
+	errors  map[string]error
+	calls   []string
+	enabled []string
+	authKey string
+}
+
+// New 创建空的模拟客户端
+func New() *Client {
+	return &Client{errors: make(map[string]error)}
+}
+
+// AddNode 添加节点，已有相同 ID 的节点时替换
+func (c *Client) AddNode(node headscale.Node) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := range c.nodes {
+		if c.nodes[i].ID == node.ID {
+			c.nodes[i] = node
+			return
+		}
+	}
+	c.nodes = append(c.nodes, node)
+}
+
+// AddRoute 添加路由，已有相同 ID 的路由时替换
+// 路由只设置了节点 ID 时从已添加的节点中补全节点信息
+func (c *Client) AddRoute(route headscale.Route) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if node := c.nodeLocked(route.Node.ID); node != nil {
+		route.Node = *node
+	}
+	for i := range c.routes {
+		if c.routes[i].ID == route.ID {
+			c.routes[i] = route
+			return
+		}
+	}
+	c.routes = append(c.routes, route)
+}
+
+// SetAdvertisedRoutes 按 Headscale 处理节点 map 请求变化的方式更新节点通告的前缀
+// 新前缀成为已通告但未启用的路由，已有前缀重新标记为已通告，不再通告的路由标记为未通告并禁用
+func (c *Client) SetAdvertisedRoutes(nodeID string, prefixes []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	advertised := make(map[string]bool, len(prefixes))
+	for _, prefix := range prefixes {
+		advertised[prefix] = true
+	}
+
+	known := make(map[string]bool)
+	for i := range c.routes {
+		route := &c.routes[i]
+		if route.Node.ID != nodeID {
+			continue
+		}
+		known[route.Prefix] = true
+		if advertised[route.Prefix] {
+			route.Advertised = true
+		} else {
+			route.Advertised = false
+			route.Enabled = false
+		}
+	}
+
+	var node headscale.Node
+	if found := c.nodeLocked(nodeID); found != nil {
+		node = *found
+	} else {
+		node.ID = nodeID
+	}
+	for _, prefix := range prefixes {
+		if known[prefix] {
+			continue
+		}
+		known[prefix] = true
+		c.routes = append(c.routes, headscale.Route{
+			ID:         c.nextRouteIDLocked(),
+			Node:       node,
+			Prefix:     prefix,
+			Advertised: true,
+			CreatedAt:  time.Now(),
+		})
+	}
+}
+
+// Route 返回指定 ID 的路由
+func (c *Client) Route(routeID string) (headscale.Route, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if route := c.routeLocked(routeID); route != nil {
+		return *route, true
+	}
+	return headscale.Route{}, false
+}
+
+// Node 返回指定 ID 的节点
+func (c *Client) Node(nodeID string) (headscale.Node, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if node := c.nodeLocked(nodeID); node != nil {
+		return *node, true
+	}
+	return headscale.Node{}, false
+}
+
+// EnabledRoutes 按调用顺序返回传给 EnableRoute 和 ApproveRoute 的路由 ID
+func (c *Client) EnabledRoutes() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.enabled...)
+}
+
+// Calls 按调用顺序返回已调用的方法名
+func (c *Client) Calls() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.calls...)
+}
+
+// ResetCalls 清空已记录的调用和启用的路由
+func (c *Client) ResetCalls() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = nil
+	c.enabled = nil
+}
+
+// AuthKey 返回最近一次传给 SetAuthKey 的密钥
+func (c *Client) AuthKey() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.authKey
+}
+
+// SetError 使指定方法返回 err，err 为 nil 时清除
+func (c *Client) SetError(method string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err == nil {
+		delete(c.errors, method)
+		return
+	}
+	c.errors[method] = err
+}
+
+// record 记录调用并返回为该方法设置的错误
+func (c *Client) record(method string) error {
+	c.calls = append(c.calls, method)
+	return c.errors[method]
+}
+
+func (c *Client) nodeLocked(nodeID string) *headscale.Node {
+	for i := range c.nodes {
+		if c.nodes[i].ID == nodeID {
+			return &c.nodes[i]
+		}
+	}
+	return nil
+}
+
+// nextRouteIDLocked 返回比现有路由 ID 都大的数字 ID
+func (c *Client) nextRouteIDLocked() string {
+	next := 1
+	for _, route := range c.routes {
+		if id, err := strconv.Atoi(route.ID); err == nil && id >= next {
+			next = id + 1
+		}
+	}
+	return strconv.Itoa(next)
+}
+
+func (c *Client) routeLocked(routeID string) *headscale.Route {
+	for i := range c.routes {
+		if c.routes[i].ID == routeID {
+			return &c.routes[i]
+		}
+	}
+	return nil
+}
+
+// deleteNodeLocked 与 Headscale 相同，删除节点及其路由
+func (c *Client) deleteNodeLocked(nodeID string) error {
+	index := -1
+	for i := range c.nodes {
+		if c.nodes[i].ID == nodeID {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return fmt.Errorf("node %s not found", nodeID)
+	}
+	c.nodes = append(c.nodes[:index], c.nodes[index+1:]...)
+
+	routes := c.routes[:0]
+	for _, route := range c.routes {
+		if route.Node.ID != nodeID {
+			routes = append(routes, route)
+		}
+	}
+	c.routes = routes
+	return nil
+}
+
+func (c *Client) setRouteEnabledLocked(routeID string, enabled bool) error {
+	route := c.routeLocked(routeID)
+	if route == nil {
+		return fmt.Errorf("route %s not found", routeID)
+	}
+	route.Enabled = enabled
+	if enabled {
+		c.enabled = append(c.enabled, routeID)
+	}
+	return nil
+}
+
+func (c *Client) ListNodes(ctx context.Context, user string) (*headscale.ListNodesResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.record("ListNodes"); err != nil {
+		return nil, err
+	}
+
+	result := &headscale.ListNodesResponse{}
+	for _, node := range c.nodes {
+		if user == "" || node.User.Name == user {
+			result.Nodes = append(result.Nodes, node)
+		}
+	}
+	return result, nil
+}
+
+func (c *Client) GetNode(ctx context.Context, nodeID string) (*headscale.GetNodeResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.record("GetNode"); err != nil {
+		return nil, err
+	}
+
+	node := c.nodeLocked(nodeID)
+	if node == nil {
+		return nil, fmt.Errorf("node %s not found", nodeID)
+	}
+	return &headscale.GetNodeResponse{Node: *node}, nil
+}
+
+// GetNodeByKey 返回以 nodeKey 注册的节点，不存在时返回包装 headscale.ErrNodeNotFound 的错误
+func (c *Client) GetNodeByKey(ctx context.Context, nodeKey string) (*headscale.Node, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.record("GetNodeByKey"); err != nil {
+		return nil, err
+	}
+
+	for i := range c.nodes {
+		if c.nodes[i].NodeKey == nodeKey {
+			node := c.nodes[i]
+			return &node, nil
+		}
+	}
+	return nil, fmt.Errorf("node with key %s: %w", nodeKey, headscale.ErrNodeNotFound)
+}
+
+// SetNodeTags 替换节点的强制标签
+func (c *Client) SetNodeTags(ctx context.Context, nodeID string, tags []string) (*headscale.SetTagsResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.record("SetNodeTags"); err != nil {
+		return nil, err
+	}
+
+	node := c.nodeLocked(nodeID)
+	if node == nil {
+		return nil, fmt.Errorf("node %s not found", nodeID)
+	}
+	node.ForcedTags = append([]string(nil), tags...)
+	return &headscale.SetTagsResponse{Node: *node}, nil
+}
+
+func (c *Client) DeleteNode(ctx context.Context, nodeID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.record("DeleteNode"); err != nil {
+		return err
+	}
+	return c.deleteNodeLocked(nodeID)
+}
+
+func (c *Client) ExpireNode(ctx context.Context, nodeID string) (*headscale.GetNodeResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.record("ExpireNode"); err != nil {
+		return nil, err
+	}
+
+	node := c.nodeLocked(nodeID)
+	if node == nil {
+		return nil, fmt.Errorf("node %s not found", nodeID)
+	}
+	node.Expiry = time.Now()
+	return &headscale.GetNodeResponse{Node: *node}, nil
+}
+
+func (c *Client) GetNodeRoutes(ctx context.Context, nodeID string) (*headscale.GetNodeRoutesResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.record("GetNodeRoutes"); err != nil {
+		return nil, err
+	}
+
+	result := &headscale.GetNodeRoutesResponse{}
+	for _, route := range c.routes {
+		if route.Node.ID == nodeID {
+			result.Routes = append(result.Routes, route)
+		}
+	}
+	return result, nil
+}
+
+func (c *Client) FindNodesByHostnamePrefix(ctx context.Context, prefix string) ([]headscale.Node, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.record("FindNodesByHostnamePrefix"); err != nil {
+		return nil, err
+	}
+	if prefix == "" {
+		return nil, fmt.Errorf("hostname prefix is empty")
+	}
+
+	var matched []headscale.Node
+	for _, node := range c.nodes {
+		if strings.HasPrefix(node.Name, prefix) || strings.HasPrefix(node.GivenName, prefix) {
+			matched = append(matched, node)
+		}
+	}
+	return matched, nil
+}
+
+// FindNodeForIP 返回包含 ip 的最长已启用非默认路由所属的节点
+func (c *Client) FindNodeForIP(ctx context.Context, ip string) (*headscale.Node, string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.record("FindNodeForIP"); err != nil {
+		return nil, "", err
+	}
+
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid IP address %q: %v", ip, err)
+	}
+	addr = addr.Unmap()
+
+	var (
+		best     *headscale.Route
+		bestBits int
+	)
+	for i := range c.routes {
+		route := &c.routes[i]
+		prefix, err := netip.ParsePrefix(route.Prefix)
+		if !route.Enabled || err != nil || prefix.Bits() == 0 || !prefix.Contains(addr) {
+			continue
+		}
+		if best == nil || prefix.Bits() > bestBits ||
+			(prefix.Bits() == bestBits && route.IsPrimary && !best.IsPrimary) {
+			best, bestBits = route, prefix.Bits()
+		}
+	}
+	if best == nil {
+		return nil, "", fmt.Errorf("%w: %s", headscale.ErrNoRouteForIP, ip)
+	}
+
+	node := best.Node
+	return &node, best.Prefix, nil
+}
+
+// ReconcileDuplicateNodes 删除与 prefix 同主机名（见 headscale.MatchesHostname）、离线且 LastSeen 早于 keepNodeID 的节点
+func (c *Client) ReconcileDuplicateNodes(ctx context.Context, prefix, keepNodeID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.record("ReconcileDuplicateNodes"); err != nil {
+		return err
+	}
+
+	keep := c.nodeLocked(keepNodeID)
+	if keep == nil {
+		return fmt.Errorf("node %s not found among nodes with hostname %s", keepNodeID, prefix)
+	}
+	keepLastSeen := keep.LastSeen
+
+	var stale []string
+	for _, node := range c.nodes {
+		if node.ID == keepNodeID || node.Online || !node.LastSeen.Before(keepLastSeen) {
+			continue
+		}
+		if headscale.MatchesHostname(node.Name, prefix) || headscale.MatchesHostname(node.GivenName, prefix) {
+			stale = append(stale, node.ID)
+		}
+	}
+	for _, nodeID := range stale {
+		if err := c.deleteNodeLocked(nodeID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CleanupExpiredNodes 删除 owned 匹配且已过期的节点
+func (c *Client) CleanupExpiredNodes(ctx context.Context, owned headscale.NodeFilter) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.record("CleanupExpiredNodes"); err != nil {
+		return err
+	}
+
+	var expired []string
+	for _, node := range c.nodes {
+		if !node.Expiry.IsZero() && time.Now().After(node.Expiry) && (owned == nil || owned(node)) {
+			expired = append(expired, node.ID)
+		}
+	}
+	for _, nodeID := range expired {
+		if err := c.deleteNodeLocked(nodeID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Client) CreatePreAuthKey(ctx context.Context, req *headscale.CreatePreAuthKeyRequest) (*headscale.CreatePreAuthKeyResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.record("CreatePreAuthKey"); err != nil {
+		return nil, err
+	}
+
+	c.keys++
+	return &headscale.CreatePreAuthKeyResponse{
+		PreAuthKey: headscale.PreAuthKey{
+			User:       req.User,
+			ID:         strconv.Itoa(c.keys),
+			Key:        fmt.Sprintf("fake-preauthkey-%d", c.keys),
+			Reusable:   req.Reusable,
+			Ephemeral:  req.Ephemeral,
+			Expiration: req.Expiration,
+			CreatedAt:  time.Now(),
+			AclTags:    req.AclTags,
+		},
+	}, nil
+}
+
+func (c *Client) GetRoutes(ctx context.Context) (*headscale.GetRoutesResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.record("GetRoutes"); err != nil {
+		return nil, err
+	}
+	return &headscale.GetRoutesResponse{Routes: append([]headscale.Route(nil), c.routes...)}, nil
+}
+
+func (c *Client) EnableRoute(ctx context.Context, routeID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.record("EnableRoute"); err != nil {
+		return err
+	}
+	return c.setRouteEnabledLocked(routeID, true)
+}
+
+func (c *Client) DisableRoute(ctx context.Context, routeID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.record("DisableRoute"); err != nil {
+		return err
+	}
+	return c.setRouteEnabledLocked(routeID, false)
+}
+
+// DeleteRoute 删除路由
+func (c *Client) DeleteRoute(ctx context.Context, routeID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.record("DeleteRoute"); err != nil {
+		return err
+	}
+
+	for i := range c.routes {
+		if c.routes[i].ID == routeID {
+			c.routes = append(c.routes[:i], c.routes[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("route %s not found", routeID)
+}
+
+func (c *Client) ApproveRoute(ctx context.Context, nodeID, routePrefix string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.record("ApproveRoute"); err != nil {
+		return err
+	}
+
+	for _, route := range c.routes {
+		if route.Node.ID == nodeID && route.Prefix == routePrefix {
+			return c.setRouteEnabledLocked(route.ID, true)
+		}
+	}
+	return fmt.Errorf("route %s not found for node %s", routePrefix, nodeID)
+}
+
+// PruneOrphanRoutes 删除 owned 匹配节点的孤儿路由：节点已不存在，或既未通告也未启用
+func (c *Client) PruneOrphanRoutes(ctx context.Context, owned headscale.NodeFilter) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.record("PruneOrphanRoutes"); err != nil {
+		return 0, err
+	}
+
+	routes := c.routes[:0]
+	pruned := 0
+	for _, route := range c.routes {
+		if (owned != nil && !owned(route.Node)) || (c.nodeLocked(route.Node.ID) != nil && (route.Advertised || route.Enabled)) {
+			routes = append(routes, route)
+			continue
+		}
+		pruned++
+	}
+	c.routes = routes
+	return pruned, nil
+}
+
+// SetAuthKey 保存密钥，见 AuthKey
+func (c *Client) SetAuthKey(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.authKey = key
+}
@@ -0,0 +1,49 @@
+package headscale
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// WaitForRoutesWithdrawn 轮询节点路由，直到 prefixes 在 Headscale 中都不再处于通告且启用的状态
+// daemon 撤销路由和 headcni drain 共用；每次查询使用 callTimeout，ctx 结束时返回的错误附带最近一次查询错误
+func WaitForRoutesWithdrawn(ctx context.Context, client HeadscaleAPI, nodeID string, prefixes []string, interval, callTimeout time.Duration) error {
+	pending := make(map[string]bool, len(prefixes))
+	for _, prefix := range prefixes {
+		pending[prefix] = true
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var lastErr error
+	for {
+		callCtx, cancel := context.WithTimeout(ctx, callTimeout)
+		nodeRoutes, err := client.GetNodeRoutes(callCtx, nodeID)
+		cancel()
+
+		if err != nil {
+			lastErr = err
+		} else {
+			serving := 0
+			for _, route := range nodeRoutes.Routes {
+				if pending[route.Prefix] && route.Advertised && route.Enabled {
+					serving++
+				}
+			}
+			if serving == 0 {
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			if lastErr != nil {
+				return fmt.Errorf("%v (last error: %v)", ctx.Err(), lastErr)
+			}
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
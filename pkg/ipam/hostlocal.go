@@ -0,0 +1,194 @@
+package ipam
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/binrclab/headcni/pkg/utils/fsutil"
+	"k8s.io/klog/v2"
+)
+
+// host-local IPAM 插件默认的数据目录
+const DefaultHostLocalDataDir = "/var/lib/cni/networks"
+
+// HostLocalAllocation host-local 存储中的一条分配记录
+// host-local 以 IP 作为文件名，文件内容为 "<containerID>\r\n<ifname>"
+type HostLocalAllocation struct {
+	IP          net.IP    `json:"ip"`
+	ContainerID string    `json:"container_id"`
+	IfName      string    `json:"ifname,omitempty"`
+	ModTime     time.Time `json:"mod_time"`
+	Path        string    `json:"path"`
+}
+
+// HostLocalStoreDir 返回指定网络的 host-local 存储目录
+func HostLocalStoreDir(dataDir, network string) string {
+	if dataDir == "" {
+		dataDir = DefaultHostLocalDataDir
+	}
+	return filepath.Join(dataDir, network)
+}
+
+// ListHostLocalAllocations 列出 host-local 存储中的所有分配
+func ListHostLocalAllocations(storeDir string) ([]HostLocalAllocation, error) {
+	entries, err := os.ReadDir(storeDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read host-local store %s: %v", storeDir, err)
+	}
+
+	var allocations []HostLocalAllocation
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		// 跳过 lock、last_reserved_ip.N 等非分配文件
+		ip := net.ParseIP(entry.Name())
+		if ip == nil {
+			continue
+		}
+
+		path := filepath.Join(storeDir, entry.Name())
+		info, err := entry.Info()
+		if err != nil {
+			klog.Warningf("Failed to stat host-local allocation %s: %v", path, err)
+			continue
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			klog.Warningf("Failed to read host-local allocation %s: %v", path, err)
+			continue
+		}
+
+		allocation := HostLocalAllocation{
+			IP:      ip,
+			ModTime: info.ModTime(),
+			Path:    path,
+		}
+		lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+		allocation.ContainerID = strings.TrimSpace(lines[0])
+		if len(lines) > 1 {
+			allocation.IfName = strings.TrimSpace(lines[1])
+		}
+
+		allocations = append(allocations, allocation)
+	}
+
+	return allocations, nil
+}
+
+// ReleaseHostLocalAllocation 删除 host-local 存储中的一条分配
+func ReleaseHostLocalAllocation(allocation HostLocalAllocation) error {
+	if err := os.Remove(allocation.Path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to release host-local allocation %s: %v", allocation.IP, err)
+	}
+	klog.Infof("Released host-local allocation %s (container %s)", allocation.IP, allocation.ContainerID)
+	return nil
+}
+
+// ReserveHostLocalIP 在 host-local 存储中为容器占用指定 IP，之后 host-local 的动态分配会跳过该地址
+// 文件已被同一容器占用时视为成功，被其他容器占用时返回错误
+func ReserveHostLocalIP(storeDir string, ip net.IP, containerID string) error {
+	if err := os.MkdirAll(storeDir, 0755); err != nil {
+		return fmt.Errorf("failed to create host-local store %s: %v", storeDir, err)
+	}
+
+	path := filepath.Join(storeDir, ip.String())
+	// 与 host-local 相同使用 O_EXCL 创建，并发分配同一地址时只有一方成功
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_EXCL|os.O_CREATE, 0644)
+	if os.IsExist(err) {
+		data, readErr := os.ReadFile(path)
+		if readErr != nil {
+			return fmt.Errorf("failed to read host-local allocation %s: %v", ip, readErr)
+		}
+		owner := strings.TrimSpace(strings.Split(string(data), "\n")[0])
+		if owner == containerID {
+			return nil
+		}
+		return fmt.Errorf("IP %s is already allocated to container %s", ip, owner)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to reserve host-local allocation %s: %v", ip, err)
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(containerID); err != nil {
+		os.Remove(path)
+		return fmt.Errorf("failed to reserve host-local allocation %s: %v", ip, err)
+	}
+	klog.Infof("Reserved host-local allocation %s for container %s", ip, containerID)
+	return nil
+}
+
+// ReleaseHostLocalContainer 删除 host-local 存储中属于指定容器的分配，返回删除的数量
+func ReleaseHostLocalContainer(storeDir, containerID string) (int, error) {
+	allocations, err := ListHostLocalAllocations(storeDir)
+	if err != nil {
+		return 0, err
+	}
+	released := 0
+	for _, allocation := range allocations {
+		if allocation.ContainerID != containerID {
+			continue
+		}
+		if err := ReleaseHostLocalAllocation(allocation); err != nil {
+			return released, err
+		}
+		released++
+	}
+	return released, nil
+}
+
+// hostLocalSubnetFile 记录 host-local 存储所属的子网，文件名不是 IP，host-local 和 ListHostLocalAllocations 都会跳过
+const hostLocalSubnetFile = "headcni-subnet"
+
+// ReconcileHostLocalSubnet 比较 host-local 存储记录的子网与当前 Pod CIDR，返回清理的分配数
+// daemon 在 Pod CIDR 变更后改写 env.yaml，旧子网遗留的分配不能再交给 Pod，删除后记录新的子网；
+// 旧版本存储没有子网记录，同样清理不在当前子网内的分配
+func ReconcileHostLocalSubnet(storeDir string, subnet *net.IPNet) (int, error) {
+	current := subnet.String()
+	subnetPath := filepath.Join(storeDir, hostLocalSubnetFile)
+
+	data, err := os.ReadFile(subnetPath)
+	if err != nil && !os.IsNotExist(err) {
+		return 0, fmt.Errorf("failed to read host-local store subnet: %v", err)
+	}
+	recorded := strings.TrimSpace(string(data))
+	if recorded != "" && recorded != current {
+		klog.Warningf("Pod CIDR changed from %s to %s, migrating host-local store %s", recorded, current, storeDir)
+	}
+
+	allocations, err := ListHostLocalAllocations(storeDir)
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, allocation := range allocations {
+		if subnet.Contains(allocation.IP) {
+			continue
+		}
+		if err := ReleaseHostLocalAllocation(allocation); err != nil {
+			klog.Warningf("%v", err)
+			continue
+		}
+		removed++
+	}
+
+	if recorded != current {
+		if err := os.MkdirAll(storeDir, 0755); err != nil {
+			return removed, fmt.Errorf("failed to create host-local store %s: %v", storeDir, err)
+		}
+		if err := fsutil.WriteFileAtomic(subnetPath, []byte(current+"\n"), 0644); err != nil {
+			return removed, fmt.Errorf("failed to record host-local store subnet: %v", err)
+		}
+	}
+	return removed, nil
+}
@@ -0,0 +1,267 @@
+package ipam
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/binrclab/headcni/pkg/utils/fsutil"
+)
+
+// DefaultPodMetadataDir 记录 host-local 分配对应的 Pod 信息，host-local 存储本身只有容器 ID
+const DefaultPodMetadataDir = "/var/lib/headcni/ipam-pods"
+
+// maxEnumerableHostBits 子网主机位不超过该值时逐个统计可用地址，更大的子网不计算容量
+const maxEnumerableHostBits = 16
+
+// PodMetadata 一次分配对应的 Pod 信息
+type PodMetadata struct {
+	ContainerID string    `json:"container_id"`
+	Namespace   string    `json:"namespace,omitempty"`
+	Name        string    `json:"name,omitempty"`
+	UID         string    `json:"uid,omitempty"`
+	RecordedAt  time.Time `json:"recorded_at"`
+}
+
+// AllocationEntry 分配表中的一条记录
+type AllocationEntry struct {
+	IP           string    `json:"ip"`
+	ContainerID  string    `json:"container_id"`
+	IfName       string    `json:"ifname,omitempty"`
+	PodNamespace string    `json:"pod_namespace,omitempty"`
+	PodName      string    `json:"pod_name,omitempty"`
+	PodUID       string    `json:"pod_uid,omitempty"`
+	AllocatedAt  time.Time `json:"allocated_at"`
+}
+
+// AllocationTable 节点当前的 IPAM 分配表
+// Total 为 -1 时表示子网过大，不统计容量
+type AllocationTable struct {
+	Type        string            `json:"type"`
+	Subnet      string            `json:"subnet,omitempty"`
+	Total       int               `json:"total"`
+	Used        int               `json:"used"`
+	Free        int               `json:"free"`
+	Allocations []AllocationEntry `json:"allocations"`
+}
+
+// RecordPodMetadata 保存容器对应的 Pod 信息，先写临时文件再重命名，避免读到半个文件
+func RecordPodMetadata(dir string, meta PodMetadata) error {
+	if meta.ContainerID == "" {
+		return fmt.Errorf("container ID is required")
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create pod metadata directory: %v", err)
+	}
+	if meta.RecordedAt.IsZero() {
+		meta.RecordedAt = time.Now()
+	}
+
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return fmt.Errorf("failed to encode pod metadata: %v", err)
+	}
+
+	if err := fsutil.WriteFileAtomic(podMetadataPath(dir, meta.ContainerID), data, 0600); err != nil {
+		return fmt.Errorf("failed to save pod metadata: %v", err)
+	}
+	return nil
+}
+
+// RemovePodMetadata 删除容器对应的 Pod 信息，不存在时不报错
+func RemovePodMetadata(dir, containerID string) error {
+	if containerID == "" {
+		return nil
+	}
+	if err := os.Remove(podMetadataPath(dir, containerID)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove pod metadata of container %s: %v", containerID, err)
+	}
+	return nil
+}
+
+// LoadPodMetadata 读取全部 Pod 信息，按容器 ID 索引；无法解析的文件被忽略
+func LoadPodMetadata(dir string) (map[string]PodMetadata, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]PodMetadata{}, nil
+		}
+		return nil, fmt.Errorf("failed to read pod metadata directory %s: %v", dir, err)
+	}
+
+	metadata := make(map[string]PodMetadata, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		var meta PodMetadata
+		if err := json.Unmarshal(data, &meta); err != nil || meta.ContainerID == "" {
+			continue
+		}
+		metadata[meta.ContainerID] = meta
+	}
+	return metadata, nil
+}
+
+// podMetadataPath 容器 ID 由运行时生成，只取文件名部分防止路径穿越
+func podMetadataPath(dir, containerID string) string {
+	return filepath.Join(dir, filepath.Base(containerID)+".json")
+}
+
+// HostLocalAllocationTable 读取 host-local 存储生成分配表，并补充记录的 Pod 信息
+// subnet 为 nil 时只列出分配，不统计容量
+func HostLocalAllocationTable(storeDir, metadataDir string, subnet *net.IPNet, reserved []string) (*AllocationTable, error) {
+	allocations, err := ListHostLocalAllocations(storeDir)
+	if err != nil {
+		return nil, err
+	}
+	metadata, err := LoadPodMetadata(metadataDir)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]AllocationEntry, 0, len(allocations))
+	for _, allocation := range allocations {
+		entry := AllocationEntry{
+			IP:          allocation.IP.String(),
+			ContainerID: allocation.ContainerID,
+			IfName:      allocation.IfName,
+			AllocatedAt: allocation.ModTime,
+		}
+		if meta, ok := metadata[allocation.ContainerID]; ok {
+			entry.PodNamespace = meta.Namespace
+			entry.PodName = meta.Name
+			entry.PodUID = meta.UID
+		}
+		entries = append(entries, entry)
+	}
+
+	return newAllocationTable(TypeHostLocal, subnet, reserved, entries)
+}
+
+// KubeAllocationTable 由 kube-backed 存储的分配生成分配表
+func KubeAllocationTable(allocations []*IPAllocation, subnet *net.IPNet, reserved []string) (*AllocationTable, error) {
+	entries := make([]AllocationEntry, 0, len(allocations))
+	for _, allocation := range allocations {
+		entries = append(entries, AllocationEntry{
+			IP:           allocation.IP.String(),
+			ContainerID:  allocation.ContainerID,
+			PodNamespace: allocation.PodNamespace,
+			PodName:      allocation.PodName,
+			AllocatedAt:  allocation.AllocatedAt,
+		})
+	}
+
+	return newAllocationTable(TypeKubeBacked, subnet, reserved, entries)
+}
+
+// newAllocationTable 按 IP 排序并统计子网的已用和空闲地址
+func newAllocationTable(ipamType string, subnet *net.IPNet, reserved []string, entries []AllocationEntry) (*AllocationTable, error) {
+	sort.Slice(entries, func(i, j int) bool {
+		return string(normalizeIP(net.ParseIP(entries[i].IP))) < string(normalizeIP(net.ParseIP(entries[j].IP)))
+	})
+
+	table := &AllocationTable{
+		Type:        ipamType,
+		Total:       -1,
+		Used:        len(entries),
+		Free:        -1,
+		Allocations: entries,
+	}
+	if subnet == nil {
+		return table, nil
+	}
+	table.Subnet = subnet.String()
+
+	excluded, err := ExcludedAddresses(ipamType, subnet, reserved)
+	if err != nil {
+		return nil, err
+	}
+	if total, ok := usableAddresses(subnet, excluded); ok {
+		table.Total = total
+		table.Free = total - table.Used
+		if table.Free < 0 {
+			table.Free = 0
+		}
+	}
+	return table, nil
+}
+
+// ExcludedAddresses 返回不会分配给 Pod 的地址判断函数
+// kube-backed 沿用地址池的规则；host-local 排除网络地址、网关（第一个地址）和 IPv4 广播地址
+func ExcludedAddresses(ipamType string, subnet *net.IPNet, reserved []string) (func(net.IP) bool, error) {
+	ranges, err := ParseReservedRanges(subnet, reserved)
+	if err != nil {
+		return nil, err
+	}
+
+	if ipamType == TypeKubeBacked {
+		pool, err := NewLocalIPPool(subnet)
+		if err != nil {
+			return nil, err
+		}
+		pool.SetReservedRanges(ranges)
+		return pool.IsReserved, nil
+	}
+
+	first := normalizeIP(subnet.IP.Mask(subnet.Mask))
+	gateway := SubnetGateway(subnet)
+	broadcast := lastIP(subnet)
+	isIPv4 := len(first) == net.IPv4len
+	return func(ip net.IP) bool {
+		if ip.Equal(first) || ip.Equal(gateway) || (isIPv4 && ip.Equal(broadcast)) {
+			return true
+		}
+		for _, r := range ranges {
+			if r.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+// SubnetGateway 返回子网的 Pod 网关地址，即网络地址之后的第一个地址
+func SubnetGateway(subnet *net.IPNet) net.IP {
+	return nextIP(normalizeIP(subnet.IP.Mask(subnet.Mask)))
+}
+
+// usableAddresses 统计子网中可分配给 Pod 的地址数，子网过大时不统计
+func usableAddresses(subnet *net.IPNet, excluded func(net.IP) bool) (int, bool) {
+	ones, bits := subnet.Mask.Size()
+	if bits-ones > maxEnumerableHostBits {
+		return 0, false
+	}
+
+	last := lastIP(subnet)
+	count := 0
+	for ip := normalizeIP(subnet.IP.Mask(subnet.Mask)); ; ip = nextIP(ip) {
+		if !excluded(ip) {
+			count++
+		}
+		if ip.Equal(last) {
+			return count, true
+		}
+	}
+}
+
+// nextIP 返回下一个地址
+func nextIP(ip net.IP) net.IP {
+	next := make(net.IP, len(ip))
+	copy(next, ip)
+	for i := len(next) - 1; i >= 0; i-- {
+		next[i]++
+		if next[i] != 0 {
+			break
+		}
+	}
+	return next
+}
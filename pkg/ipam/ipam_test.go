@@ -2,9 +2,24 @@ package ipam
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/binrclab/headcni/pkg/k8s"
+	coreV1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 )
 
 func TestNewIPAMManager(t *testing.T) {
@@ -128,3 +143,394 @@ func TestStatistics(t *testing.T) {
 		t.Errorf("Expected nodeName 'test-node', got '%s'", stats.NodeName)
 	}
 }
+
+func TestPodCIDRChangeBetweenAllocations(t *testing.T) {
+	storeDir := HostLocalStoreDir(t.TempDir(), "cbr0")
+	if err := os.MkdirAll(storeDir, 0755); err != nil {
+		t.Fatalf("Failed to create store dir: %v", err)
+	}
+
+	_, oldCIDR, _ := net.ParseCIDR("10.244.1.0/24")
+	_, newCIDR, _ := net.ParseCIDR("10.244.2.0/24")
+
+	writeAllocation := func(ip, containerID string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(storeDir, ip), []byte(containerID+"\r\neth0"), 0644); err != nil {
+			t.Fatalf("Failed to write allocation %s: %v", ip, err)
+		}
+	}
+
+	// 首次运行记录子网，子网内的分配保留
+	writeAllocation("10.244.1.5", "container-a")
+	if removed, err := ReconcileHostLocalSubnet(storeDir, oldCIDR); err != nil || removed != 0 {
+		t.Fatalf("Expected no allocations to be removed, got %d, %v", removed, err)
+	}
+	data, err := os.ReadFile(filepath.Join(storeDir, hostLocalSubnetFile))
+	if err != nil || strings.TrimSpace(string(data)) != oldCIDR.String() {
+		t.Fatalf("Expected store subnet %s, got %q, %v", oldCIDR, data, err)
+	}
+
+	// daemon 改写 Pod CIDR 后，旧子网的分配被清理，新子网的分配保留
+	writeAllocation("10.244.2.7", "container-b")
+	removed, err := ReconcileHostLocalSubnet(storeDir, newCIDR)
+	if err != nil || removed != 1 {
+		t.Fatalf("Expected 1 stale allocation to be removed, got %d, %v", removed, err)
+	}
+	allocations, err := ListHostLocalAllocations(storeDir)
+	if err != nil {
+		t.Fatalf("Failed to list allocations: %v", err)
+	}
+	if len(allocations) != 1 || allocations[0].IP.String() != "10.244.2.7" {
+		t.Fatalf("Expected only 10.244.2.7 to remain, got %+v", allocations)
+	}
+	data, err = os.ReadFile(filepath.Join(storeDir, hostLocalSubnetFile))
+	if err != nil || strings.TrimSpace(string(data)) != newCIDR.String() {
+		t.Errorf("Expected store subnet %s, got %q, %v", newCIDR, data, err)
+	}
+}
+
+func TestListHostLocalAllocations(t *testing.T) {
+	storeDir := HostLocalStoreDir(t.TempDir(), "cbr0")
+	if err := os.MkdirAll(storeDir, 0755); err != nil {
+		t.Fatalf("Failed to create store dir: %v", err)
+	}
+
+	files := map[string]string{
+		"10.244.0.5":         "container-a\r\neth0",
+		"10.244.0.6":         "container-b",
+		"last_reserved_ip.0": "10.244.0.6",
+		"lock":               "",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(storeDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	allocations, err := ListHostLocalAllocations(storeDir)
+	if err != nil {
+		t.Fatalf("Failed to list allocations: %v", err)
+	}
+	if len(allocations) != 2 {
+		t.Fatalf("Expected 2 allocations, got %d", len(allocations))
+	}
+
+	for _, allocation := range allocations {
+		switch allocation.IP.String() {
+		case "10.244.0.5":
+			if allocation.ContainerID != "container-a" || allocation.IfName != "eth0" {
+				t.Errorf("Unexpected allocation %+v", allocation)
+			}
+		case "10.244.0.6":
+			if allocation.ContainerID != "container-b" {
+				t.Errorf("Unexpected allocation %+v", allocation)
+			}
+		default:
+			t.Errorf("Unexpected allocation IP %s", allocation.IP)
+		}
+
+		if err := ReleaseHostLocalAllocation(allocation); err != nil {
+			t.Errorf("Failed to release allocation: %v", err)
+		}
+	}
+
+	allocations, err = ListHostLocalAllocations(storeDir)
+	if err != nil {
+		t.Fatalf("Failed to list allocations: %v", err)
+	}
+	if len(allocations) != 0 {
+		t.Errorf("Expected all allocations to be released, got %d", len(allocations))
+	}
+}
+
+func TestHostLocalAllocationTable(t *testing.T) {
+	storeDir := HostLocalStoreDir(t.TempDir(), "cbr0")
+	metadataDir := t.TempDir()
+	if err := os.MkdirAll(storeDir, 0755); err != nil {
+		t.Fatalf("Failed to create store dir: %v", err)
+	}
+
+	files := map[string]string{
+		"10.244.0.6": "container-b\r\neth0",
+		"10.244.0.5": "container-a\r\neth0",
+		"lock":       "",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(storeDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	meta := PodMetadata{ContainerID: "container-a", Namespace: "default", Name: "web", UID: "uid-a"}
+	if err := RecordPodMetadata(metadataDir, meta); err != nil {
+		t.Fatalf("Failed to record pod metadata: %v", err)
+	}
+
+	_, subnet, _ := net.ParseCIDR("10.244.0.0/28")
+	table, err := HostLocalAllocationTable(storeDir, metadataDir, subnet, []string{"10.244.0.10-10.244.0.11"})
+	if err != nil {
+		t.Fatalf("Failed to build allocation table: %v", err)
+	}
+
+	// 16 个地址去掉网络地址、网关、广播地址和 2 个保留地址
+	if table.Total != 11 || table.Used != 2 || table.Free != 9 {
+		t.Errorf("Expected total=11 used=2 free=9, got total=%d used=%d free=%d", table.Total, table.Used, table.Free)
+	}
+	if len(table.Allocations) != 2 || table.Allocations[0].IP != "10.244.0.5" {
+		t.Fatalf("Expected allocations sorted by IP, got %+v", table.Allocations)
+	}
+	first := table.Allocations[0]
+	if first.PodNamespace != "default" || first.PodName != "web" || first.PodUID != "uid-a" {
+		t.Errorf("Expected pod metadata on first allocation, got %+v", first)
+	}
+	if second := table.Allocations[1]; second.PodName != "" {
+		t.Errorf("Expected no pod metadata on second allocation, got %+v", second)
+	}
+
+	if err := RemovePodMetadata(metadataDir, "container-a"); err != nil {
+		t.Fatalf("Failed to remove pod metadata: %v", err)
+	}
+	metadata, err := LoadPodMetadata(metadataDir)
+	if err != nil {
+		t.Fatalf("Failed to load pod metadata: %v", err)
+	}
+	if len(metadata) != 0 {
+		t.Errorf("Expected pod metadata to be removed, got %+v", metadata)
+	}
+}
+
+func TestReservedRanges(t *testing.T) {
+	storageDir := t.TempDir()
+	t.Setenv("HEADCNI_STORAGE_PATH", storageDir)
+
+	_, podCIDR, _ := net.ParseCIDR("10.244.5.0/28")
+
+	// 超出子网的配置必须被拒绝
+	invalid := [][]string{
+		{"10.244.6.1"},
+		{"10.244.5.10-10.244.5.20"},
+		{"10.244.5.9-10.244.5.4"},
+		{"10.244.4.0/23"},
+		{"not-an-ip"},
+	}
+	for _, entries := range invalid {
+		if _, err := ParseReservedRanges(podCIDR, entries); err == nil {
+			t.Errorf("Expected reserved entries %v to be rejected", entries)
+		}
+	}
+
+	// 包含网络地址和广播地址的范围是合法的
+	ranges, err := ParseReservedRanges(podCIDR, []string{"10.244.5.0-10.244.5.5", "10.244.5.14/31"})
+	if err != nil {
+		t.Fatalf("Failed to parse reserved ranges: %v", err)
+	}
+	if len(ranges) != 2 || !ranges[1].End.Equal(net.ParseIP("10.244.5.15")) {
+		t.Fatalf("Unexpected reserved ranges: %v", ranges)
+	}
+
+	ranges, err = ParseReservedRanges(podCIDR, []string{"10.244.5.0-10.244.5.5", "10.244.5.8", "10.244.5.14/31"})
+	if err != nil {
+		t.Fatalf("Failed to parse reserved ranges: %v", err)
+	}
+	pool, err := NewLocalIPPool(podCIDR)
+	if err != nil {
+		t.Fatalf("Failed to create local IP pool: %v", err)
+	}
+	pool.SetReservedRanges(ranges)
+
+	// 可分配地址只剩 .6 .7 .9 .10 .11 .12 .13
+	expected := map[string]bool{
+		"10.244.5.6": true, "10.244.5.7": true, "10.244.5.9": true, "10.244.5.10": true,
+		"10.244.5.11": true, "10.244.5.12": true, "10.244.5.13": true,
+	}
+
+	available := len(expected)
+	for i := 0; i < available; i++ {
+		ip, err := pool.AllocateNext(StrategySequential)
+		if err != nil {
+			t.Fatalf("Failed to allocate IP %d: %v", i, err)
+		}
+		if !expected[ip.String()] {
+			t.Errorf("Allocated unexpected IP %s", ip)
+		}
+		delete(expected, ip.String())
+	}
+
+	if _, err := pool.AllocateNext(StrategySequential); !errors.Is(err, ErrPoolExhausted) {
+		t.Errorf("Expected allocation to fail when only reserved addresses remain, got %v", err)
+	}
+
+	for _, s := range []string{"10.244.5.0", "10.244.5.4", "10.244.5.8", "10.244.5.15"} {
+		if !pool.IsReserved(net.ParseIP(s).To4()) {
+			t.Errorf("Expected %s to be reserved", s)
+		}
+	}
+	if pool.IsReserved(net.ParseIP("10.244.5.9").To4()) {
+		t.Error("Expected 10.244.5.9 not to be reserved")
+	}
+}
+
+func TestStaticIPAllocation(t *testing.T) {
+	if ip, err := StaticIPFromAnnotations(map[string]string{}); ip != nil || err != nil {
+		t.Fatalf("Expected no static IP without annotation, got %v, %v", ip, err)
+	}
+	if _, err := StaticIPFromAnnotations(map[string]string{"headcni.io/ip": "10.244.5.300"}); err == nil {
+		t.Error("Expected invalid annotation to be rejected")
+	}
+	ip, err := StaticIPFromAnnotations(map[string]string{"headcni.io/ip": " 10.244.5.50 "})
+	if err != nil || !ip.Equal(net.ParseIP("10.244.5.50")) {
+		t.Fatalf("Failed to parse annotation: %v, %v", ip, err)
+	}
+
+	// host-local 模式下静态地址记录在 host-local 存储中，动态分配会跳过
+	storeDir := HostLocalStoreDir(t.TempDir(), "cbr0")
+	if err := ReserveHostLocalIP(storeDir, ip, "container-a"); err != nil {
+		t.Fatalf("ReserveHostLocalIP failed: %v", err)
+	}
+	allocations, err := ListHostLocalAllocations(storeDir)
+	if err != nil || len(allocations) != 1 || !allocations[0].IP.Equal(ip) || allocations[0].ContainerID != "container-a" {
+		t.Fatalf("Expected %s to be recorded for container-a, got %+v, %v", ip, allocations, err)
+	}
+
+	// 同一容器重复 ADD 成功，其他容器不能占用
+	if err := ReserveHostLocalIP(storeDir, ip, "container-a"); err != nil {
+		t.Errorf("Expected repeated reservation to succeed: %v", err)
+	}
+	if err := ReserveHostLocalIP(storeDir, ip, "container-b"); err == nil {
+		t.Errorf("Expected %s to stay allocated to container-a", ip)
+	}
+
+	// DEL 之后地址可被其他 Pod 使用
+	if released, err := ReleaseHostLocalContainer(storeDir, "container-a"); err != nil || released != 1 {
+		t.Fatalf("Expected one released allocation, got %d, %v", released, err)
+	}
+	if err := ReserveHostLocalIP(storeDir, ip, "container-b"); err != nil {
+		t.Errorf("Expected released static IP to be reusable: %v", err)
+	}
+}
+
+func TestKubeStoreAllocation(t *testing.T) {
+	_, podCIDR, _ := net.ParseCIDR("10.244.1.0/24")
+	clientset := fake.NewSimpleClientset()
+	client := k8s.NewClientForClientset(clientset, &k8s.ClientConfig{
+		BasePermissions: &k8s.PermissionStatus{CanListConfigMaps: true, CanGetConfigMaps: true},
+	})
+	store, err := NewKubeStore(client.ConfigMaps(), "headcni", "node-1", podCIDR, []string{"10.244.1.5"})
+	if err != nil {
+		t.Fatalf("NewKubeStore failed: %v", err)
+	}
+	ctx := context.Background()
+
+	first, err := store.Allocate(ctx, "default", "web-0", "container-1", nil)
+	if err != nil {
+		t.Fatalf("Allocate failed: %v", err)
+	}
+	if first.IP.String() != "10.244.1.4" {
+		t.Errorf("Expected first IP 10.244.1.4, got %s", first.IP)
+	}
+
+	// 同一容器重复 ADD 返回已有分配
+	again, err := store.Allocate(ctx, "default", "web-0", "container-1", nil)
+	if err != nil {
+		t.Fatalf("Repeated Allocate failed: %v", err)
+	}
+	if !again.IP.Equal(first.IP) {
+		t.Errorf("Expected repeated allocation to return %s, got %s", first.IP, again.IP)
+	}
+
+	// 模拟另一个 ADD 在读取和写回之间占用了 10.244.1.6：第一次 Update 返回 Conflict，重试后不会重复分配
+	conflicted := false
+	clientset.PrependReactor("update", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if conflicted {
+			return false, nil, nil
+		}
+		conflicted = true
+		current, err := clientset.Tracker().Get(schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}, "headcni", store.ConfigMapName())
+		if err != nil {
+			return true, nil, err
+		}
+		configMap := current.(*coreV1.ConfigMap).DeepCopy()
+		configMap.Data["10.244.1.6"] = `{"ip":"10.244.1.6","pod_namespace":"default","pod_name":"web-1","container_id":"container-2"}`
+		if err := clientset.Tracker().Update(schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}, configMap, "headcni"); err != nil {
+			return true, nil, err
+		}
+		return true, nil, apierrors.NewConflict(schema.GroupResource{Resource: "configmaps"}, store.ConfigMapName(), fmt.Errorf("modified"))
+	})
+
+	second, err := store.Allocate(ctx, "default", "web-2", "container-3", nil)
+	if err != nil {
+		t.Fatalf("Allocate after conflict failed: %v", err)
+	}
+	if !conflicted {
+		t.Fatal("Expected the conflict reactor to run")
+	}
+	// 10.244.1.5 为保留地址，10.244.1.6 已被并发的 ADD 占用
+	if second.IP.String() != "10.244.1.7" {
+		t.Errorf("Expected 10.244.1.7 after conflict, got %s", second.IP)
+	}
+
+	// 静态 IP 已被占用时拒绝
+	if _, err := store.Allocate(ctx, "default", "web-3", "container-4", net.ParseIP("10.244.1.6")); err == nil {
+		t.Error("Expected allocating a taken static IP to fail")
+	}
+
+	released, err := store.Release(ctx, "container-1")
+	if err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	if released == nil || !released.IP.Equal(first.IP) {
+		t.Errorf("Expected to release %s, got %v", first.IP, released)
+	}
+
+	allocations, err := store.List(ctx)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	var ips []string
+	for _, allocation := range allocations {
+		ips = append(ips, allocation.IP.String())
+	}
+	if strings.Join(ips, ",") != "10.244.1.6,10.244.1.7" {
+		t.Errorf("Unexpected allocations after release: %v", ips)
+	}
+}
+
+func TestKubeStoreIPv6Keys(t *testing.T) {
+	_, podCIDR, _ := net.ParseCIDR("fd00:10:244:1::/120")
+	clientset := fake.NewSimpleClientset()
+	client := k8s.NewClientForClientset(clientset, &k8s.ClientConfig{
+		BasePermissions: &k8s.PermissionStatus{CanListConfigMaps: true, CanGetConfigMaps: true},
+	})
+	store, err := NewKubeStore(client.ConfigMaps(), "headcni", "node-1", podCIDR, nil)
+	if err != nil {
+		t.Fatalf("NewKubeStore failed: %v", err)
+	}
+	ctx := context.Background()
+
+	allocation, err := store.Allocate(ctx, "default", "web-0", "container-1", net.ParseIP("fd00:10:244:1::20"))
+	if err != nil {
+		t.Fatalf("Allocate failed: %v", err)
+	}
+
+	// ConfigMap 的键只允许 [-._a-zA-Z0-9]
+	configMap, err := clientset.CoreV1().ConfigMaps("headcni").Get(ctx, store.ConfigMapName(), metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get configmap: %v", err)
+	}
+	validKey := regexp.MustCompile(`^[-._a-zA-Z0-9]+$`)
+	for key := range configMap.Data {
+		if !validKey.MatchString(key) {
+			t.Errorf("Invalid configmap key %q", key)
+		}
+		if ip := ipFromAllocationKey(key); !ip.Equal(allocation.IP) {
+			t.Errorf("Expected key %q to decode to %s, got %v", key, allocation.IP, ip)
+		}
+	}
+
+	allocations, err := store.List(ctx)
+	if err != nil || len(allocations) != 1 || !allocations[0].IP.Equal(net.ParseIP("fd00:10:244:1::20")) {
+		t.Fatalf("Expected the IPv6 allocation to be listed, got %v, %v", allocations, err)
+	}
+	if released, err := store.Release(ctx, "container-1"); err != nil || released == nil {
+		t.Fatalf("Expected the IPv6 allocation to be released, got %v, %v", released, err)
+	}
+}
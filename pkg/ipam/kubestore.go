@@ -0,0 +1,294 @@
+package ipam
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/binrclab/headcni/pkg/k8s"
+	coreV1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog/v2"
+)
+
+// IPAM 后端类型，对应 IPAMConfig.Type
+const (
+	TypeHostLocal  = "host-local"
+	TypeKubeBacked = "kube-backed"
+	// TypeHostLocalDelegate 插件把网络配置的 ipam 块交给上游 host-local 二进制分配，不使用内置的分配策略
+	TypeHostLocalDelegate = "host-local-delegate"
+)
+
+// UsesHostLocalStore 分配记录是否保存在节点上的 host-local 存储目录，委托给上游 host-local 时同样如此
+func UsesHostLocalStore(ipamType string) bool {
+	return ipamType != TypeKubeBacked
+}
+
+// kubeStoreNodeLabel 标识 ConfigMap 所属节点，便于 kubectl 按标签查询
+const kubeStoreNodeLabel = "headcni.io/ipam-node"
+
+// KubeStore 将节点的 IP 分配保存在 ConfigMap headcni-ipam-<node> 中，DataDir 丢失后分配仍然保留
+// 键为 IP，值为 JSON 编码的 IPAllocation；更新携带 resourceVersion，并发分配冲突时重新读取后重试
+type KubeStore struct {
+	configMaps k8s.ConfigMapInterface
+	namespace  string
+	nodeName   string
+	podCIDR    *net.IPNet
+	reserved   []IPRange
+}
+
+// NewKubeStore 创建节点的 ConfigMap 分配存储，reserved 为不分配的地址（格式同 IPAMConfig.Reserved）
+func NewKubeStore(configMaps k8s.ConfigMapInterface, namespace, nodeName string, podCIDR *net.IPNet, reserved []string) (*KubeStore, error) {
+	if configMaps == nil {
+		return nil, fmt.Errorf("configmap client not available")
+	}
+	if podCIDR == nil {
+		return nil, fmt.Errorf("pod CIDR is required")
+	}
+
+	ranges, err := ParseReservedRanges(podCIDR, reserved)
+	if err != nil {
+		return nil, err
+	}
+
+	return &KubeStore{
+		configMaps: configMaps,
+		namespace:  namespace,
+		nodeName:   nodeName,
+		podCIDR:    podCIDR,
+		reserved:   ranges,
+	}, nil
+}
+
+// ConfigMapName 返回保存本节点分配的 ConfigMap 名称
+func (s *KubeStore) ConfigMapName() string {
+	return "headcni-ipam-" + s.nodeName
+}
+
+// PodCIDR 返回存储管理的节点 Pod CIDR
+func (s *KubeStore) PodCIDR() *net.IPNet {
+	return s.podCIDR
+}
+
+// Allocate 为容器分配 IP，requested 非空时分配指定地址（静态 IP）
+// 同一容器重复分配时返回已有的分配，不会占用第二个地址
+func (s *KubeStore) Allocate(ctx context.Context, podNamespace, podName, containerID string, requested net.IP) (*IPAllocation, error) {
+	if containerID == "" {
+		return nil, fmt.Errorf("container ID is required")
+	}
+
+	var result *IPAllocation
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		configMap, exists, err := s.load(ctx)
+		if err != nil {
+			return err
+		}
+		allocations, err := decodeAllocations(configMap)
+		if err != nil {
+			return err
+		}
+
+		for _, allocation := range allocations {
+			if allocation.ContainerID == containerID {
+				if requested != nil && !allocation.IP.Equal(normalizeIP(requested)) {
+					return fmt.Errorf("container %s already holds IP %s", containerID, allocation.IP)
+				}
+				result = allocation
+				return nil
+			}
+		}
+
+		pool, err := s.newPool(allocations)
+		if err != nil {
+			return err
+		}
+
+		var ip net.IP
+		if requested != nil {
+			ip = normalizeIP(requested)
+			if err := pool.AllocateSpecific(ip); err != nil {
+				return err
+			}
+		} else if ip, err = pool.AllocateNext(StrategySequential); err != nil {
+			return err
+		}
+
+		allocation := &IPAllocation{
+			IP:           ip,
+			PodNamespace: podNamespace,
+			PodName:      podName,
+			ContainerID:  containerID,
+			NodeName:     s.nodeName,
+			AllocatedAt:  time.Now(),
+		}
+		data, err := json.Marshal(allocation)
+		if err != nil {
+			return fmt.Errorf("failed to encode allocation: %v", err)
+		}
+		configMap.Data[allocationKey(ip)] = string(data)
+
+		if err := s.save(ctx, configMap, exists); err != nil {
+			return err
+		}
+		result = allocation
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to allocate IP for container %s: %w", containerID, err)
+	}
+
+	klog.V(2).Infof("Allocated IP %s to pod %s/%s (container %s) in configmap %s/%s",
+		result.IP, podNamespace, podName, containerID, s.namespace, s.ConfigMapName())
+	return result, nil
+}
+
+// Release 释放容器的分配，返回被释放的分配；容器没有分配时返回 nil, nil
+func (s *KubeStore) Release(ctx context.Context, containerID string) (*IPAllocation, error) {
+	var released *IPAllocation
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		released = nil
+
+		configMap, exists, err := s.load(ctx)
+		if err != nil || !exists {
+			return err
+		}
+		allocations, err := decodeAllocations(configMap)
+		if err != nil {
+			return err
+		}
+
+		for key, allocation := range allocations {
+			if allocation.ContainerID == containerID {
+				delete(configMap.Data, key)
+				released = allocation
+				break
+			}
+		}
+		if released == nil {
+			return nil
+		}
+		return s.save(ctx, configMap, true)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to release IP of container %s: %w", containerID, err)
+	}
+	return released, nil
+}
+
+// List 返回本节点的全部分配，按 IP 排序
+func (s *KubeStore) List(ctx context.Context) ([]*IPAllocation, error) {
+	configMap, _, err := s.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	allocations, err := decodeAllocations(configMap)
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]*IPAllocation, 0, len(allocations))
+	for _, allocation := range allocations {
+		list = append(list, allocation)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return string(list[i].IP.To16()) < string(list[j].IP.To16())
+	})
+	return list, nil
+}
+
+// load 读取 ConfigMap，不存在时返回待创建的空 ConfigMap
+func (s *KubeStore) load(ctx context.Context) (*coreV1.ConfigMap, bool, error) {
+	configMap, err := s.configMaps.Get(ctx, s.namespace, s.ConfigMapName())
+	if apierrors.IsNotFound(err) {
+		return &coreV1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      s.ConfigMapName(),
+				Namespace: s.namespace,
+				Labels: map[string]string{
+					"app.kubernetes.io/managed-by": "headcni",
+					kubeStoreNodeLabel:             s.nodeName,
+				},
+			},
+			Data: make(map[string]string),
+		}, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if configMap.Data == nil {
+		configMap.Data = make(map[string]string)
+	}
+	return configMap, true, nil
+}
+
+// save 写回 ConfigMap；Update 携带读取时的 resourceVersion，被并发修改时返回 Conflict
+// 并发创建时 AlreadyExists 同样转换为 Conflict，由调用方重新读取后重试
+func (s *KubeStore) save(ctx context.Context, configMap *coreV1.ConfigMap, exists bool) error {
+	if exists {
+		_, err := s.configMaps.Update(ctx, s.namespace, configMap)
+		return err
+	}
+
+	_, err := s.configMaps.Create(ctx, s.namespace, configMap)
+	if apierrors.IsAlreadyExists(err) {
+		return apierrors.NewConflict(schema.GroupResource{Resource: "configmaps"}, configMap.Name, err)
+	}
+	return err
+}
+
+// newPool 基于节点 Pod CIDR 构建地址池，并标记已分配的地址
+func (s *KubeStore) newPool(allocations map[string]*IPAllocation) (*LocalIPPool, error) {
+	pool, err := NewLocalIPPool(s.podCIDR)
+	if err != nil {
+		return nil, err
+	}
+	pool.SetReservedRanges(s.reserved)
+
+	for key, allocation := range allocations {
+		if !s.podCIDR.Contains(allocation.IP) {
+			klog.Warningf("Ignoring allocation %s outside pod CIDR %s in configmap %s/%s",
+				key, s.podCIDR, s.namespace, s.ConfigMapName())
+			continue
+		}
+		pool.allocatedIPs[allocation.IP.String()] = true
+	}
+	return pool, nil
+}
+
+// allocationKey 返回分配在 ConfigMap 中的键
+// ConfigMap 的键只允许 [-._a-zA-Z0-9]，IPv6 地址中的 ":" 替换为 "-"
+func allocationKey(ip net.IP) string {
+	return strings.ReplaceAll(ip.String(), ":", "-")
+}
+
+// ipFromAllocationKey 从 ConfigMap 的键还原 IP，键无效时返回 nil
+func ipFromAllocationKey(key string) net.IP {
+	if strings.Contains(key, ".") {
+		return normalizeIP(net.ParseIP(key))
+	}
+	return normalizeIP(net.ParseIP(strings.ReplaceAll(key, "-", ":")))
+}
+
+// decodeAllocations 解析 ConfigMap 中的分配，键由 allocationKey 编码
+func decodeAllocations(configMap *coreV1.ConfigMap) (map[string]*IPAllocation, error) {
+	allocations := make(map[string]*IPAllocation, len(configMap.Data))
+	for key, value := range configMap.Data {
+		var allocation IPAllocation
+		if err := json.Unmarshal([]byte(value), &allocation); err != nil {
+			return nil, fmt.Errorf("invalid allocation %s in configmap %s/%s: %v", key, configMap.Namespace, configMap.Name, err)
+		}
+		allocation.IP = normalizeIP(allocation.IP)
+		if allocation.IP == nil {
+			allocation.IP = ipFromAllocationKey(key)
+		}
+		allocations[key] = &allocation
+	}
+	return allocations, nil
+}
@@ -3,6 +3,7 @@ package ipam
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"os"
@@ -10,6 +11,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/binrclab/headcni/pkg/utils/fsutil"
 	"k8s.io/klog/v2"
 )
 
@@ -39,6 +41,9 @@ type IPAllocation struct {
 	Metadata     map[string]string `json:"metadata,omitempty"`
 }
 
+// ErrPoolExhausted 地址池中已没有可分配的地址
+var ErrPoolExhausted = errors.New("no available IP in pool")
+
 type AllocationStrategy int
 
 const (
@@ -52,7 +57,9 @@ type LocalIPPool struct {
 	nextIP       net.IP
 	allocatedIPs map[string]bool // IP -> allocated
 	reservedIPs  map[string]bool // 保留 IP（网关等）
-	mutex        sync.RWMutex
+	// 用户配置的保留地址范围
+	reservedRanges []IPRange
+	mutex          sync.RWMutex
 }
 
 func NewIPAMManager(nodeName string, podCIDR *net.IPNet) (*IPAMManager, error) {
@@ -219,7 +226,9 @@ func (p *LocalIPPool) AllocateNext(strategy AllocationStrategy) (net.IP, error)
 }
 
 func (p *LocalIPPool) allocateSequential() (net.IP, error) {
-	maxAttempts := 256 // 最大尝试次数，避免死循环
+	// 最大尝试次数为地址池大小，避免死循环；保留范围可能跨越大量地址
+	ones, bits := p.cidr.Mask.Size()
+	maxAttempts := 1 << min(bits-ones, 16)
 	attempt := 0
 
 	startIP := make(net.IP, len(p.nextIP))
@@ -246,7 +255,7 @@ func (p *LocalIPPool) allocateSequential() (net.IP, error) {
 		}
 	}
 
-	return nil, fmt.Errorf("no available IP in pool")
+	return nil, ErrPoolExhausted
 }
 
 func (p *LocalIPPool) allocateRandom() (net.IP, error) {
@@ -276,7 +285,7 @@ func (p *LocalIPPool) allocateRandom() (net.IP, error) {
 		}
 	}
 
-	return nil, fmt.Errorf("no available IP found with random allocation")
+	return nil, fmt.Errorf("%w (random allocation)", ErrPoolExhausted)
 }
 
 func (p *LocalIPPool) allocateDensePack() (net.IP, error) {
@@ -303,7 +312,7 @@ func (p *LocalIPPool) allocateDensePack() (net.IP, error) {
 		}
 	}
 
-	return nil, fmt.Errorf("no available IP found with dense pack allocation")
+	return nil, fmt.Errorf("%w (dense pack allocation)", ErrPoolExhausted)
 }
 
 func (p *LocalIPPool) isIPAvailable(ip net.IP) bool {
@@ -320,7 +329,7 @@ func (p *LocalIPPool) isIPAvailable(ip net.IP) bool {
 	}
 
 	// 检查是否保留
-	if p.reservedIPs[ipStr] {
+	if p.isReserved(ip) {
 		return false
 	}
 
@@ -389,7 +398,7 @@ func (m *IPAMManager) saveToLocal(ctx context.Context, allocation *IPAllocation)
 		return err
 	}
 
-	return os.WriteFile(filePath, data, 0644)
+	return fsutil.WriteFileAtomic(filePath, data, 0644)
 }
 
 func (m *IPAMManager) deleteFromLocal(ctx context.Context, podKey string) error {
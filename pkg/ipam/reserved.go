@@ -0,0 +1,148 @@
+package ipam
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// IPRange 闭区间 IP 范围 [Start, End]
+type IPRange struct {
+	Start net.IP `json:"start"`
+	End   net.IP `json:"end"`
+}
+
+// Contains 判断 IP 是否在范围内
+func (r IPRange) Contains(ip net.IP) bool {
+	ip = normalizeIP(ip)
+	if ip == nil {
+		return false
+	}
+	return bytes.Compare(ip, normalizeIP(r.Start)) >= 0 && bytes.Compare(ip, normalizeIP(r.End)) <= 0
+}
+
+// String 返回范围的字符串表示
+func (r IPRange) String() string {
+	if r.Start.Equal(r.End) {
+		return r.Start.String()
+	}
+	return fmt.Sprintf("%s-%s", r.Start, r.End)
+}
+
+// ParseReservedRanges 解析保留地址配置，每一项可以是单个 IP、起止范围（a-b）或 CIDR
+// 所有地址必须位于 subnet 内；网络地址和广播地址本身已被保留，包含它们的范围同样合法
+func ParseReservedRanges(subnet *net.IPNet, entries []string) ([]IPRange, error) {
+	var ranges []IPRange
+
+	for i, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		r, err := parseReservedEntry(entry)
+		if err != nil {
+			return nil, fmt.Errorf("reserved[%d] %q: %v", i, entry, err)
+		}
+
+		if !subnet.Contains(r.Start) || !subnet.Contains(r.End) {
+			return nil, fmt.Errorf("reserved[%d] %q: not inside subnet %s", i, entry, subnet)
+		}
+
+		ranges = append(ranges, r)
+	}
+
+	return ranges, nil
+}
+
+// parseReservedEntry 解析单条保留地址
+func parseReservedEntry(entry string) (IPRange, error) {
+	// CIDR 形式
+	if strings.Contains(entry, "/") {
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			return IPRange{}, fmt.Errorf("invalid CIDR: %v", err)
+		}
+		return IPRange{Start: normalizeIP(ipNet.IP), End: lastIP(ipNet)}, nil
+	}
+
+	// 范围形式
+	if strings.Contains(entry, "-") {
+		parts := strings.SplitN(entry, "-", 2)
+		start := normalizeIP(net.ParseIP(strings.TrimSpace(parts[0])))
+		end := normalizeIP(net.ParseIP(strings.TrimSpace(parts[1])))
+		if start == nil || end == nil {
+			return IPRange{}, fmt.Errorf("invalid IP range")
+		}
+		if len(start) != len(end) {
+			return IPRange{}, fmt.Errorf("range mixes IPv4 and IPv6")
+		}
+		if bytes.Compare(start, end) > 0 {
+			return IPRange{}, fmt.Errorf("range start is after range end")
+		}
+		return IPRange{Start: start, End: end}, nil
+	}
+
+	// 单个 IP
+	ip := normalizeIP(net.ParseIP(entry))
+	if ip == nil {
+		return IPRange{}, fmt.Errorf("invalid IP address")
+	}
+	return IPRange{Start: ip, End: ip}, nil
+}
+
+// normalizeIP IPv4 统一为 4 字节表示，便于比较
+func normalizeIP(ip net.IP) net.IP {
+	if ip == nil {
+		return nil
+	}
+	if v4 := ip.To4(); v4 != nil {
+		return v4
+	}
+	return ip.To16()
+}
+
+// lastIP 返回网段的最后一个地址（IPv4 即广播地址）
+func lastIP(ipNet *net.IPNet) net.IP {
+	ip := normalizeIP(ipNet.IP)
+	mask := ipNet.Mask
+	if len(mask) != len(ip) {
+		mask = mask[len(mask)-len(ip):]
+	}
+
+	last := make(net.IP, len(ip))
+	for i := range ip {
+		last[i] = ip[i] | ^mask[i]
+	}
+	return last
+}
+
+// SetReservedRanges 设置地址池的保留范围
+func (p *LocalIPPool) SetReservedRanges(ranges []IPRange) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.reservedRanges = ranges
+}
+
+// IsReserved 判断 IP 是否为保留地址
+func (p *LocalIPPool) IsReserved(ip net.IP) bool {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	return p.isReserved(ip)
+}
+
+// isReserved 调用方需持有锁
+func (p *LocalIPPool) isReserved(ip net.IP) bool {
+	if p.reservedIPs[ip.String()] {
+		return true
+	}
+	for _, r := range p.reservedRanges {
+		if r.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
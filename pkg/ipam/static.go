@@ -0,0 +1,45 @@
+package ipam
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/binrclab/headcni/pkg/constants"
+)
+
+// StaticIPFromAnnotations 从 Pod 注解中解析静态 IP 请求
+// 未设置注解时返回 nil, nil
+func StaticIPFromAnnotations(annotations map[string]string) (net.IP, error) {
+	value, ok := annotations[constants.HeadcniStaticIPAnnotationKey]
+	if !ok {
+		return nil, nil
+	}
+
+	value = strings.TrimSpace(value)
+	ip := normalizeIP(net.ParseIP(value))
+	if ip == nil {
+		return nil, fmt.Errorf("invalid %s annotation %q: not an IP address", constants.HeadcniStaticIPAnnotationKey, value)
+	}
+	return ip, nil
+}
+
+// AllocateSpecific 从地址池中分配指定 IP
+func (p *LocalIPPool) AllocateSpecific(ip net.IP) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	ip = normalizeIP(ip)
+	if !p.cidr.Contains(ip) {
+		return fmt.Errorf("IP %s is outside pool %s", ip.String(), p.cidr.String())
+	}
+	if p.isReserved(ip) {
+		return fmt.Errorf("IP %s is reserved", ip.String())
+	}
+	if p.allocatedIPs[ip.String()] {
+		return fmt.Errorf("IP %s is already allocated", ip.String())
+	}
+
+	p.allocatedIPs[ip.String()] = true
+	return nil
+}
@@ -2,20 +2,26 @@ package k8s
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"net"
 	"os"
 	"strings"
 	"sync"
 	"time"
 
 	coreV1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/util/retry"
 	"k8s.io/client-go/util/workqueue"
 	"k8s.io/klog/v2"
 )
@@ -32,8 +38,17 @@ type ClientConfig struct {
 	WorkQueueConfig *WorkQueueConfig `json:"workQueueConfig,omitempty"`
 	// 基础权限设置 - 在 NewClient 时可以预设
 	BasePermissions *PermissionStatus `json:"basePermissions,omitempty"`
+	// 注解/标签更新遇到冲突或临时错误（429/5xx）时的最大重试次数
+	MaxRetries int `json:"maxRetries,omitempty"`
+	// UseCache 节点 informer 同步后从缓存读取节点，缓存未命中时回退到 API 读取
+	UseCache bool `json:"useCache,omitempty"`
+	// DNSNamespaces 查找 DNS 服务和 NodeLocal DNSCache 的命名空间，为空时使用 DefaultDNSNamespaces
+	DNSNamespaces []string `json:"dnsNamespaces,omitempty"`
 }
 
+// defaultMaxRetries 未配置 MaxRetries 时的默认重试次数
+const defaultMaxRetries = 5
+
 // WorkQueueConfig 工作队列配置
 type WorkQueueConfig struct {
 	MaxRetries  int                   `json:"maxRetries,omitempty"`
@@ -43,7 +58,7 @@ type WorkQueueConfig struct {
 // client Kubernetes 客户端实现
 type client struct {
 	config     *ClientConfig
-	clientset  *kubernetes.Clientset
+	clientset  kubernetes.Interface
 	restConfig *rest.Config
 
 	// 权限状态
@@ -74,6 +89,10 @@ func NewClient(config *ClientConfig) Client {
 		}
 	}
 
+	if config.MaxRetries <= 0 {
+		config.MaxRetries = defaultMaxRetries
+	}
+
 	if config.WorkQueueConfig == nil {
 		config.WorkQueueConfig = &WorkQueueConfig{
 			MaxRetries: 5,
@@ -99,6 +118,18 @@ func NewClient(config *ClientConfig) Client {
 	}
 }
 
+// NewClientForClientset 基于已有的 clientset 创建已连接的客户端，跳过 Connect，便于测试注入 fake clientset
+func NewClientForClientset(clientset kubernetes.Interface, config *ClientConfig) Client {
+	c := NewClient(config).(*client)
+	c.clientset = clientset
+	c.isConnected = true
+
+	// 与 Connect 一致，使用预设的基础权限
+	permissions := *c.config.BasePermissions
+	c.permissions = &permissions
+	return c
+}
+
 // Connect 连接到 Kubernetes 集群
 func (c *client) Connect(ctx context.Context) error {
 	c.mu.Lock()
@@ -527,11 +558,11 @@ func (c *client) ConfigMaps() ConfigMapInterface {
 		klog.Errorf("Permission denied for ConfigMaps interface: %v", err)
 		return nil
 	}
-	return nil
+	return &configMapClient{client: c}
 }
 
 // getClientset 获取 clientset（内部使用）
-func (c *client) getClientset() *kubernetes.Clientset {
+func (c *client) getClientset() kubernetes.Interface {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
@@ -562,6 +593,11 @@ func (nc *nodeClient) Get(ctx context.Context, name string) (*coreV1.Node, error
 		return nil, fmt.Errorf("no permission to get nodes")
 	}
 
+	// 缓存已同步时优先从 informer 缓存读取
+	if node, ok := nc.client.cachedNode(name); ok {
+		return node, nil
+	}
+
 	// 获取节点
 	node, err := clientset.CoreV1().Nodes().Get(ctx, name, metav1.GetOptions{})
 	if err != nil {
@@ -571,6 +607,62 @@ func (nc *nodeClient) Get(ctx context.Context, name string) (*coreV1.Node, error
 	return node, nil
 }
 
+// cachedNode 从已同步的节点 informer 缓存中读取节点，返回副本
+func (c *client) cachedNode(name string) (*coreV1.Node, bool) {
+	if !c.config.UseCache {
+		return nil, false
+	}
+
+	c.mu.RLock()
+	informer := c.nodeInformer
+	c.mu.RUnlock()
+	if informer == nil || !informer.HasSynced() {
+		return nil, false
+	}
+
+	obj, exists, err := informer.GetStore().GetByKey(name)
+	if err != nil || !exists {
+		return nil, false
+	}
+	node, ok := obj.(*coreV1.Node)
+	if !ok {
+		return nil, false
+	}
+	return node.DeepCopy(), true
+}
+
+// cachedPods 从已同步的 Pod informer 缓存中读取 namespace 下的 Pod（为空时为所有命名空间），返回副本
+func (c *client) cachedPods(namespace string) ([]*coreV1.Pod, bool) {
+	if !c.config.UseCache {
+		return nil, false
+	}
+
+	c.mu.RLock()
+	informer := c.podInformer
+	c.mu.RUnlock()
+	if informer == nil || !informer.HasSynced() {
+		return nil, false
+	}
+
+	var objs []interface{}
+	if namespace == "" {
+		objs = informer.GetStore().List()
+	} else {
+		var err error
+		objs, err = informer.GetIndexer().ByIndex(cache.NamespaceIndex, namespace)
+		if err != nil {
+			return nil, false
+		}
+	}
+	pods := make([]*coreV1.Pod, 0, len(objs))
+	for _, obj := range objs {
+		if pod, ok := obj.(*coreV1.Pod); ok {
+			pods = append(pods, pod.DeepCopy())
+		}
+	}
+	return pods, true
+}
+
 func (nc *nodeClient) List(ctx context.Context, opts *ListOptions) ([]*coreV1.Node, error) {
 	clientset := nc.client.getClientset()
 	if clientset == nil {
@@ -713,50 +805,116 @@ func (nc *nodeClient) GetAllPodCIDRs() ([]string, error) {
 	return podCIDRs, nil
 }
 
-func (nc *nodeClient) UpdateAnnotations(name string, annotations map[string]string) error {
+// GetPodCIDRsByNode 返回每个节点的 Pod CIDR 列表
+func (nc *nodeClient) GetPodCIDRsByNode() (map[string][]string, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	// 获取当前节点
-	node, err := nc.Get(ctx, name)
+	nodes, err := nc.List(ctx, nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// 更新注解
-	if node.Annotations == nil {
-		node.Annotations = make(map[string]string)
+	podCIDRs := make(map[string][]string, len(nodes))
+	for _, node := range nodes {
+		podCIDRs[node.Name] = NodePodCIDRs(node)
+	}
+
+	return podCIDRs, nil
+}
+
+// NodePodCIDRs 返回节点的 Pod CIDR，PodCIDRs 为空时使用 PodCIDR
+func NodePodCIDRs(node *coreV1.Node) []string {
+	if len(node.Spec.PodCIDRs) > 0 {
+		return node.Spec.PodCIDRs
 	}
-	for k, v := range annotations {
-		node.Annotations[k] = v
+	if node.Spec.PodCIDR != "" {
+		return []string{node.Spec.PodCIDR}
 	}
+	return nil
+}
 
-	// 保存更新
-	_, err = nc.Update(ctx, node)
-	return err
+func (nc *nodeClient) UpdateAnnotations(name string, annotations map[string]string) error {
+	return nc.patchMetadata(name, "annotations", annotations)
 }
 
 func (nc *nodeClient) UpdateLabels(name string, labels map[string]string) error {
+	return nc.patchMetadata(name, "labels", labels)
+}
+
+// RemoveAnnotations 删除节点注解中指定的键，键不存在时不报错
+// merge patch 中值为 null 的键会被删除，其他键保持不变
+func (nc *nodeClient) RemoveAnnotations(name string, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	values := make(map[string]interface{}, len(keys))
+	for _, key := range keys {
+		values[key] = nil
+	}
+	return nc.patchMetadata(name, "annotations", values)
+}
+
+// patchMetadata 以 JSON merge patch 只更新 metadata.<field> 中指定的键
+// 不经过读-改-写，不会覆盖其他控制器同时写入的键
+func (nc *nodeClient) patchMetadata(name, field string, values interface{}) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	// 获取当前节点
-	node, err := nc.Get(ctx, name)
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			field: values,
+		},
+	})
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to build %s patch for node %s: %w", field, name, err)
 	}
 
-	// 更新标签
-	if node.Labels == nil {
-		node.Labels = make(map[string]string)
-	}
-	for k, v := range labels {
-		node.Labels[k] = v
-	}
+	return nc.client.retryUpdate(func() error {
+		_, err := nc.Patch(ctx, name, types.MergePatchType, patch)
+		return err
+	})
+}
 
-	// 保存更新
-	_, err = nc.Update(ctx, node)
-	return err
+// isTransientError 限流和服务端临时错误，退避后可以重试
+func isTransientError(err error) bool {
+	return apierrors.IsTooManyRequests(err) ||
+		apierrors.IsServerTimeout(err) ||
+		apierrors.IsTimeout(err) ||
+		apierrors.IsInternalError(err) ||
+		apierrors.IsServiceUnavailable(err) ||
+		apierrors.IsUnexpectedServerError(err)
+}
+
+// retryUpdate 重试更新操作：冲突时立即重试，临时错误按指数退避重试
+// 读-改-写操作的 fn 必须在每次调用时重新获取对象
+func (c *client) retryUpdate(fn func() error) error {
+	steps := c.config.MaxRetries + 1
+	conflictBackoff := retry.DefaultRetry
+	conflictBackoff.Steps = steps
+	transientBackoff := wait.Backoff{
+		Steps:    steps,
+		Duration: 200 * time.Millisecond,
+		Factor:   2.0,
+		Jitter:   0.1,
+		Cap:      5 * time.Second,
+	}
+
+	return retry.OnError(transientBackoff, isTransientError, func() error {
+		return retry.RetryOnConflict(conflictBackoff, fn)
+	})
+}
+
+// retryTransient 只对临时错误按指数退避重试，冲突直接返回
+func (c *client) retryTransient(fn func() error) error {
+	transientBackoff := wait.Backoff{
+		Steps:    c.config.MaxRetries + 1,
+		Duration: 200 * time.Millisecond,
+		Factor:   2.0,
+		Jitter:   0.1,
+		Cap:      5 * time.Second,
+	}
+	return retry.OnError(transientBackoff, isTransientError, fn)
 }
 
 // serviceClient 服务客户端实现
@@ -914,46 +1072,50 @@ func (sc *serviceClient) UpdateAnnotations(namespace, name string, annotations m
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	// 获取当前服务
-	service, err := sc.Get(ctx, namespace, name)
-	if err != nil {
-		return err
-	}
+	return sc.client.retryUpdate(func() error {
+		// 每次尝试都重新获取当前服务
+		service, err := sc.Get(ctx, namespace, name)
+		if err != nil {
+			return err
+		}
 
-	// 更新注解
-	if service.Annotations == nil {
-		service.Annotations = make(map[string]string)
-	}
-	for k, v := range annotations {
-		service.Annotations[k] = v
-	}
+		// 更新注解
+		if service.Annotations == nil {
+			service.Annotations = make(map[string]string)
+		}
+		for k, v := range annotations {
+			service.Annotations[k] = v
+		}
 
-	// 保存更新
-	_, err = sc.Update(ctx, namespace, service)
-	return err
+		// 保存更新
+		_, err = sc.Update(ctx, namespace, service)
+		return err
+	})
 }
 
 func (sc *serviceClient) UpdateLabels(namespace, name string, labels map[string]string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	// 获取当前服务
-	service, err := sc.Get(ctx, namespace, name)
-	if err != nil {
-		return err
-	}
+	return sc.client.retryUpdate(func() error {
+		// 每次尝试都重新获取当前服务
+		service, err := sc.Get(ctx, namespace, name)
+		if err != nil {
+			return err
+		}
 
-	// 更新标签
-	if service.Labels == nil {
-		service.Labels = make(map[string]string)
-	}
-	for k, v := range labels {
-		service.Labels[k] = v
-	}
+		// 更新标签
+		if service.Labels == nil {
+			service.Labels = make(map[string]string)
+		}
+		for k, v := range labels {
+			service.Labels[k] = v
+		}
 
-	// 保存更新
-	_, err = sc.Update(ctx, namespace, service)
-	return err
+		// 保存更新
+		_, err = sc.Update(ctx, namespace, service)
+		return err
+	})
 }
 
 func (sc *serviceClient) GetEndpoints(namespace, name string) ([]string, error) {
@@ -1031,6 +1193,13 @@ func (pc *podClient) List(ctx context.Context, namespace string, opts *ListOptio
 		return nil, fmt.Errorf("no permission to list pods")
 	}
 
+	// 无过滤条件且缓存已同步时从 informer 缓存读取，周期性全量列出 Pod 不访问 API
+	if opts == nil {
+		if pods, ok := pc.client.cachedPods(namespace); ok {
+			return pods, nil
+		}
+	}
+
 	// 构建列表选项
 	listOpts := metav1.ListOptions{}
 	if opts != nil {
@@ -1162,6 +1331,86 @@ func (pc *podClient) GetPodIP(namespace, name string) (string, error) {
 	return pod.Status.PodIP, nil
 }
 
+// PatchAnnotations 以 JSON merge patch 只更新 Pod 注解中指定的键
+// uid 非空时作为前置条件，Pod 被删除后以同名重建时返回 Conflict，不会写到新 Pod 上
+func (pc *podClient) PatchAnnotations(ctx context.Context, namespace, name string, uid types.UID, annotations map[string]string) error {
+	clientset := pc.client.getClientset()
+	if clientset == nil {
+		return fmt.Errorf("client not connected")
+	}
+
+	metadata := map[string]interface{}{
+		"annotations": annotations,
+	}
+	if uid != "" {
+		metadata["uid"] = uid
+	}
+	patch, err := json.Marshal(map[string]interface{}{"metadata": metadata})
+	if err != nil {
+		return fmt.Errorf("failed to build annotations patch for pod %s/%s: %w", namespace, name, err)
+	}
+
+	return pc.client.retryTransient(func() error {
+		_, err := clientset.CoreV1().Pods(namespace).Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to patch pod %s/%s: %w", namespace, name, err)
+		}
+		return nil
+	})
+}
+
+// WatchByNode 监听调度到指定节点的 Pod 并回调 handler，阻塞直到 ctx 结束
+// 启动时已有的 Pod 以 OnPodAdd 回调；handler 返回的错误只记录日志
+func (pc *podClient) WatchByNode(ctx context.Context, nodeName string, handler PodEventHandler) error {
+	clientset := pc.client.getClientset()
+	if clientset == nil {
+		return fmt.Errorf("client not connected")
+	}
+
+	factory := informers.NewSharedInformerFactoryWithOptions(clientset, pc.client.config.ResyncPeriod,
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.FieldSelector = fields.OneTermEqualSelector("spec.nodeName", nodeName).String()
+		}))
+	informer := factory.Core().V1().Pods().Informer()
+
+	if _, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if pod, ok := obj.(*coreV1.Pod); ok {
+				if err := handler.OnPodAdd(pod); err != nil {
+					klog.Warningf("Failed to handle add of pod %s/%s: %v", pod.Namespace, pod.Name, err)
+				}
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldPod, ok1 := oldObj.(*coreV1.Pod)
+			newPod, ok2 := newObj.(*coreV1.Pod)
+			if ok1 && ok2 {
+				if err := handler.OnPodUpdate(oldPod, newPod); err != nil {
+					klog.Warningf("Failed to handle update of pod %s/%s: %v", newPod.Namespace, newPod.Name, err)
+				}
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if pod, ok := obj.(*coreV1.Pod); ok {
+				if err := handler.OnPodDelete(pod); err != nil {
+					klog.Warningf("Failed to handle delete of pod %s/%s: %v", pod.Namespace, pod.Name, err)
+				}
+			}
+		},
+	}); err != nil {
+		return fmt.Errorf("failed to watch pods on node %s: %w", nodeName, err)
+	}
+
+	klog.Infof("Watching pods on node %s", nodeName)
+	factory.Start(ctx.Done())
+	<-ctx.Done()
+	factory.Shutdown()
+	return nil
+}
+
 // configMapClient ConfigMap 客户端实现
 type configMapClient struct {
 	client *client
@@ -1312,7 +1561,26 @@ func (cmc *configMapClient) UpdateData(namespace, name string, data map[string]s
 	return err
 }
 
+// DefaultDNSNamespaces 未配置 DNSNamespaces 时查找 DNS 服务的命名空间（按优先级排序）
+var DefaultDNSNamespaces = []string{"kube-system", "kube-dns", "coredns", "openshift-dns"}
+
+// DefaultNodeLocalDNSIP NodeLocal DNSCache 惯用的链路本地监听地址
+const DefaultNodeLocalDNSIP = "169.254.20.10"
+
+// nodeLocalDNSConfigMapNames NodeLocal DNSCache 的 ConfigMap 名称，与其 DaemonSet 同名
+var nodeLocalDNSConfigMapNames = []string{"node-local-dns", "nodelocaldns"}
+
+// dnsNamespaces 返回查找 DNS 服务的命名空间
+func (c *client) dnsNamespaces() []string {
+	if c.config != nil && len(c.config.DNSNamespaces) > 0 {
+		return c.config.DNSNamespaces
+	}
+	return DefaultDNSNamespaces
+}
+
 // GetDNSServiceIP 获取 DNS 服务 IP
+// 部署了 NodeLocal DNSCache 时优先返回其链路本地地址，否则依次在 DNS 命名空间中查找 DNS 服务
+// 都找不到或没有 services 权限时返回错误
 func (c *client) GetDNSServiceIP() (string, error) {
 	if !c.isConnected {
 		return "", fmt.Errorf("client not connected")
@@ -1323,10 +1591,19 @@ func (c *client) GetDNSServiceIP() (string, error) {
 		return "", fmt.Errorf("client not connected")
 	}
 
+	namespaces := c.dnsNamespaces()
+
+	// NodeLocal DNSCache 在每个节点上监听链路本地地址，Pod 直接使用可避免经过 kube-proxy
+	if c.permissions == nil || c.permissions.CanGetConfigMaps {
+		if ip, namespace := findNodeLocalDNSIP(clientset, namespaces); ip != "" {
+			klog.Infof("Found NodeLocal DNSCache in namespace %s, using %s", namespace, ip)
+			return ip, nil
+		}
+	}
+
 	// 检查是否有权限获取服务
 	if c.permissions != nil && !c.permissions.CanGetServices {
-		klog.Warningf("No permission to get services, returning default DNS IP")
-		return "10.96.0.10", nil
+		return "", fmt.Errorf("no permission to get services")
 	}
 
 	// 定义可能的 DNS 服务名称（按优先级排序）
@@ -1336,34 +1613,80 @@ func (c *client) GetDNSServiceIP() (string, error) {
 		"rke2-coredns-rke2-coredns",    // RKE2
 		"k3s-coredns",                  // k3s
 		"rancher-coredns",              // Rancher
+		"dns-default",                  // OpenShift
 		"aws-node-termination-handler", // EKS
 	}
 
 	// 尝试获取 DNS 服务
-	for _, serviceName := range dnsServiceNames {
-		if dnsService, err := clientset.CoreV1().Services("kube-system").Get(context.Background(), serviceName, metav1.GetOptions{}); err == nil {
-			// 检查服务是否有正确的标签或注解标识为 DNS 服务
-			if isDNSService(dnsService) {
-				if len(dnsService.Spec.ClusterIPs) > 0 {
-					return dnsService.Spec.ClusterIPs[0], nil
-				} else if dnsService.Spec.ClusterIP != "" {
-					return dnsService.Spec.ClusterIP, nil
+	for _, namespace := range namespaces {
+		for _, serviceName := range dnsServiceNames {
+			if dnsService, err := clientset.CoreV1().Services(namespace).Get(context.Background(), serviceName, metav1.GetOptions{}); err == nil {
+				// 检查服务是否有正确的标签或注解标识为 DNS 服务
+				if isDNSService(dnsService) {
+					if len(dnsService.Spec.ClusterIPs) > 0 {
+						return dnsService.Spec.ClusterIPs[0], nil
+					} else if dnsService.Spec.ClusterIP != "" {
+						return dnsService.Spec.ClusterIP, nil
+					}
 				}
 			}
 		}
 	}
 
 	// 如果通过服务名找不到，尝试通过标签选择器查找
-	if dnsService, err := findDNSServiceBySelector(clientset); err == nil && dnsService != nil {
-		if len(dnsService.Spec.ClusterIPs) > 0 {
-			return dnsService.Spec.ClusterIPs[0], nil
-		} else if dnsService.Spec.ClusterIP != "" {
-			return dnsService.Spec.ClusterIP, nil
+	for _, namespace := range namespaces {
+		if dnsService, err := findDNSServiceBySelector(clientset, namespace); err == nil && dnsService != nil {
+			if len(dnsService.Spec.ClusterIPs) > 0 {
+				return dnsService.Spec.ClusterIPs[0], nil
+			} else if dnsService.Spec.ClusterIP != "" {
+				return dnsService.Spec.ClusterIP, nil
+			}
 		}
 	}
 
-	// 如果都找不到，返回默认值
-	return "10.96.0.10", nil
+	// 找不到时返回错误，由调用方决定是否使用默认值
+	return "", fmt.Errorf("no DNS service found in namespaces %s", strings.Join(namespaces, ", "))
+}
+
+// findNodeLocalDNSIP 查找 NodeLocal DNSCache 的 ConfigMap，返回其监听的链路本地地址和所在命名空间
+// 卸载后可能只残留 ConfigMap，同名 DaemonSet 不存在或没有就绪的 Pod 时不使用，否则 Pod 的 DNS 查询会发往无人监听的地址
+func findNodeLocalDNSIP(clientset kubernetes.Interface, namespaces []string) (string, string) {
+	for _, namespace := range namespaces {
+		for _, name := range nodeLocalDNSConfigMapNames {
+			configMap, err := clientset.CoreV1().ConfigMaps(namespace).Get(context.Background(), name, metav1.GetOptions{})
+			if err != nil {
+				continue
+			}
+			daemonSet, err := clientset.AppsV1().DaemonSets(namespace).Get(context.Background(), name, metav1.GetOptions{})
+			if err != nil {
+				klog.Infof("Ignoring NodeLocal DNSCache ConfigMap %s/%s: DaemonSet not found: %v", namespace, name, err)
+				continue
+			}
+			if daemonSet.Status.NumberReady == 0 {
+				klog.Infof("Ignoring NodeLocal DNSCache ConfigMap %s/%s: DaemonSet has no ready pods", namespace, name)
+				continue
+			}
+			return parseNodeLocalDNSBind(configMap.Data["Corefile"]), namespace
+		}
+	}
+	return "", ""
+}
+
+// parseNodeLocalDNSBind 从 NodeLocal DNSCache 的 Corefile 中解析 bind 的链路本地地址
+// 官方清单中地址由占位符在部署时替换，未找到链路本地地址时使用 DefaultNodeLocalDNSIP
+func parseNodeLocalDNSBind(corefile string) string {
+	for _, line := range strings.Split(corefile, "\n") {
+		parts := strings.Fields(line)
+		if len(parts) < 2 || parts[0] != "bind" {
+			continue
+		}
+		for _, part := range parts[1:] {
+			if ip := net.ParseIP(part); ip != nil && ip.To4() != nil && ip.IsLinkLocalUnicast() {
+				return ip.String()
+			}
+		}
+	}
+	return DefaultNodeLocalDNSIP
 }
 
 // isDNSService 检查服务是否为 DNS 服务
@@ -1401,11 +1724,11 @@ func isDNSService(service *coreV1.Service) bool {
 	return false
 }
 
-// findDNSServiceBySelector 通过标签选择器查找 DNS 服务
-func findDNSServiceBySelector(clientset *kubernetes.Clientset) (*coreV1.Service, error) {
+// findDNSServiceBySelector 通过标签选择器在 namespace 中查找 DNS 服务
+func findDNSServiceBySelector(clientset kubernetes.Interface, namespace string) (*coreV1.Service, error) {
 	// 尝试通过标签选择器查找
 	selector := "k8s-app in (kube-dns,coredns)"
-	services, err := clientset.CoreV1().Services("kube-system").List(context.Background(), metav1.ListOptions{
+	services, err := clientset.CoreV1().Services(namespace).List(context.Background(), metav1.ListOptions{
 		LabelSelector: selector,
 	})
 	if err != nil {
@@ -1480,23 +1803,44 @@ func (c *client) GetClusterDomain() (string, error) {
 }
 
 // parseCorefileDomain 从 Corefile 解析集群域名
+// kubernetes 插件一行可列出多个 zone（如 kubernetes cluster.local in-addr.arpa ip6.arpa {），
+// 返回第一个正向 zone，跳过反向解析 zone、端口和块起始符
 func parseCorefileDomain(corefile string) string {
 	lines := strings.Split(corefile, "\n")
 	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if strings.HasPrefix(line, "kubernetes") {
-			// 查找 cluster.local 或类似的域名
-			parts := strings.Fields(line)
-			for _, part := range parts {
-				if strings.Contains(part, ".") && !strings.HasPrefix(part, "in-addr.arpa") {
-					return part
-				}
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		parts := strings.Fields(strings.ReplaceAll(line, "{", " "))
+		if len(parts) == 0 || parts[0] != "kubernetes" {
+			continue
+		}
+		for _, part := range parts[1:] {
+			if domain := corefileForwardZone(part); domain != "" {
+				return domain
 			}
 		}
 	}
 	return ""
 }
 
+// corefileForwardZone 规范化 kubernetes 插件的 zone 参数，反向解析 zone 或无效参数返回空
+func corefileForwardZone(zone string) string {
+	zone = strings.TrimPrefix(zone, "dns://")
+	if host, _, err := net.SplitHostPort(zone); err == nil {
+		zone = host
+	}
+	zone = strings.ToLower(strings.TrimSuffix(zone, "."))
+	if zone == "" || strings.Contains(zone, "/") {
+		return ""
+	}
+	if zone == "in-addr.arpa" || zone == "ip6.arpa" ||
+		strings.HasSuffix(zone, ".in-addr.arpa") || strings.HasSuffix(zone, ".ip6.arpa") {
+		return ""
+	}
+	return zone
+}
+
 // parseKubeletConfigDomain 从 kubelet 配置解析集群域名
 func parseKubeletConfigDomain(config string) string {
 	lines := strings.Split(config, "\n")
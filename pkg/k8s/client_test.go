@@ -0,0 +1,472 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	appsV1 "k8s.io/api/apps/v1"
+	coreV1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// newFakeNodeClient 基于 fake clientset 创建节点客户端
+func newFakeNodeClient(nodes ...*coreV1.Node) (*nodeClient, *fake.Clientset) {
+	clientset := fake.NewSimpleClientset()
+	for _, node := range nodes {
+		clientset.Tracker().Add(node)
+	}
+
+	c := &client{
+		config:      &ClientConfig{MaxRetries: defaultMaxRetries},
+		clientset:   clientset,
+		isConnected: true,
+	}
+	return &nodeClient{client: c}, clientset
+}
+
+func TestUpdateAnnotationsKeepsUnrelatedKeys(t *testing.T) {
+	nc, clientset := newFakeNodeClient(&coreV1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "node-1",
+			Annotations: map[string]string{
+				"scheduler.example.com/score": "42",
+				"headcni.io/version":          "v1",
+			},
+		},
+	})
+
+	if err := nc.UpdateAnnotations("node-1", map[string]string{"headcni.io/version": "v2"}); err != nil {
+		t.Fatalf("UpdateAnnotations failed: %v", err)
+	}
+
+	node, err := clientset.CoreV1().Nodes().Get(context.Background(), "node-1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get node: %v", err)
+	}
+	if got := node.Annotations["headcni.io/version"]; got != "v2" {
+		t.Errorf("expected headcni.io/version=v2, got %q", got)
+	}
+	if got := node.Annotations["scheduler.example.com/score"]; got != "42" {
+		t.Errorf("expected unrelated annotation to survive, got %q", got)
+	}
+}
+
+func TestUpdateLabelsKeepsUnrelatedKeys(t *testing.T) {
+	nc, clientset := newFakeNodeClient(&coreV1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "node-1",
+			Labels: map[string]string{"kubernetes.io/hostname": "node-1"},
+		},
+	})
+
+	if err := nc.UpdateLabels("node-1", map[string]string{"headcni.io/ready": "true"}); err != nil {
+		t.Fatalf("UpdateLabels failed: %v", err)
+	}
+
+	node, err := clientset.CoreV1().Nodes().Get(context.Background(), "node-1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get node: %v", err)
+	}
+	if got := node.Labels["headcni.io/ready"]; got != "true" {
+		t.Errorf("expected headcni.io/ready=true, got %q", got)
+	}
+	if got := node.Labels["kubernetes.io/hostname"]; got != "node-1" {
+		t.Errorf("expected unrelated label to survive, got %q", got)
+	}
+}
+
+func TestRemoveAnnotationsKeepsUnrelatedKeys(t *testing.T) {
+	nc, clientset := newFakeNodeClient(&coreV1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "node-1",
+			Annotations: map[string]string{
+				"scheduler.example.com/score": "42",
+				"headcni.io/version":          "v1",
+				"headcni.tailscale.ip":        "100.64.0.1",
+			},
+		},
+	})
+
+	// 不存在的键不影响删除
+	if err := nc.RemoveAnnotations("node-1", []string{"headcni.io/version", "headcni.tailscale.ip", "headcni.io/mode"}); err != nil {
+		t.Fatalf("RemoveAnnotations failed: %v", err)
+	}
+
+	node, err := clientset.CoreV1().Nodes().Get(context.Background(), "node-1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get node: %v", err)
+	}
+	for _, key := range []string{"headcni.io/version", "headcni.tailscale.ip"} {
+		if _, ok := node.Annotations[key]; ok {
+			t.Errorf("expected annotation %s to be removed", key)
+		}
+	}
+	if got := node.Annotations["scheduler.example.com/score"]; got != "42" {
+		t.Errorf("expected unrelated annotation to survive, got %q", got)
+	}
+}
+
+func TestGetReadsFromSyncedNodeCache(t *testing.T) {
+	nc, clientset := newFakeNodeClient(&coreV1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+		Spec:       coreV1.NodeSpec{PodCIDR: "10.244.1.0/24"},
+	})
+	nc.client.config.UseCache = true
+	nc.client.config.WorkQueueConfig = &WorkQueueConfig{}
+	nc.client.permissions = &PermissionStatus{CanListNodes: true, CanGetNodes: true}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := nc.client.StartInformers(ctx); err != nil {
+		t.Fatalf("StartInformers failed: %v", err)
+	}
+	if err := nc.client.WaitForCacheSync(ctx); err != nil {
+		t.Fatalf("WaitForCacheSync failed: %v", err)
+	}
+
+	// 缓存命中时不访问 API
+	clientset.ClearActions()
+	cidr, err := nc.GetPodCIDR("node-1")
+	if err != nil {
+		t.Fatalf("GetPodCIDR failed: %v", err)
+	}
+	if cidr != "10.244.1.0/24" {
+		t.Errorf("expected Pod CIDR 10.244.1.0/24, got %q", cidr)
+	}
+	if n := countGetActions(clientset); n != 0 {
+		t.Errorf("expected cached read, got %d API get calls", n)
+	}
+
+	// 缓存未命中回退到 API
+	if _, err := nc.Get(ctx, "node-2"); err == nil {
+		t.Errorf("expected node-2 to be missing")
+	}
+	if n := countGetActions(clientset); n != 1 {
+		t.Errorf("expected fallback API get on cache miss, got %d calls", n)
+	}
+
+	// 关闭缓存后强制从 API 读取
+	nc.client.config.UseCache = false
+	clientset.ClearActions()
+	if _, err := nc.Get(ctx, "node-1"); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if n := countGetActions(clientset); n != 1 {
+		t.Errorf("expected live read with UseCache disabled, got %d calls", n)
+	}
+}
+
+func TestPodListReadsFromSyncedPodCache(t *testing.T) {
+	nc, clientset := newFakeNodeClient()
+	for _, pod := range []*coreV1.Pod{
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web-0"}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system", Name: "coredns-0"}},
+	} {
+		clientset.Tracker().Add(pod)
+	}
+	nc.client.config.UseCache = true
+	nc.client.config.WorkQueueConfig = &WorkQueueConfig{}
+	nc.client.permissions = &PermissionStatus{CanListPods: true}
+	pc := &podClient{client: nc.client}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := nc.client.StartInformers(ctx); err != nil {
+		t.Fatalf("StartInformers failed: %v", err)
+	}
+	if err := nc.client.WaitForCacheSync(ctx); err != nil {
+		t.Fatalf("WaitForCacheSync failed: %v", err)
+	}
+
+	// 无过滤条件时从缓存读取
+	clientset.ClearActions()
+	pods, err := pc.List(ctx, "", nil)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(pods) != 2 {
+		t.Errorf("expected 2 pods, got %d", len(pods))
+	}
+	pods, err = pc.List(ctx, "kube-system", nil)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(pods) != 1 || pods[0].Name != "coredns-0" {
+		t.Errorf("expected only coredns-0 in kube-system, got %v", pods)
+	}
+	if n := len(clientset.Actions()); n != 0 {
+		t.Errorf("expected cached list, got %d API calls", n)
+	}
+
+	// 带过滤条件时访问 API
+	if _, err := pc.List(ctx, "", &ListOptions{LabelSelector: "app=web"}); err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if n := len(clientset.Actions()); n != 1 {
+		t.Errorf("expected an API list with a selector, got %d calls", n)
+	}
+}
+
+// countGetActions 统计 fake clientset 上的节点 get 请求次数
+func countGetActions(clientset *fake.Clientset) int {
+	count := 0
+	for _, action := range clientset.Actions() {
+		if action.GetVerb() == "get" && action.GetResource().Resource == "nodes" {
+			count++
+		}
+	}
+	return count
+}
+
+func TestWatchSecretValuePicksUpRotation(t *testing.T) {
+	nc, clientset := newFakeNodeClient()
+	secret := &coreV1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "headcni-auth", Namespace: "headcni"},
+		Data:       map[string][]byte{"auth-key": []byte("key-1")},
+	}
+	clientset.Tracker().Add(secret)
+	// 同命名空间的其他 Secret 不触发回调
+	clientset.Tracker().Add(&coreV1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "headcni"},
+		Data:       map[string][]byte{"auth-key": []byte("other-key")},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	value, err := nc.client.GetSecretValue(ctx, "headcni", "headcni-auth", "auth-key")
+	if err != nil {
+		t.Fatalf("GetSecretValue failed: %v", err)
+	}
+	if value != "key-1" {
+		t.Errorf("expected key-1, got %q", value)
+	}
+	if _, err := nc.client.GetSecretValue(ctx, "headcni", "headcni-auth", "missing"); err == nil {
+		t.Errorf("expected error for missing key")
+	}
+
+	values := make(chan string, 10)
+	go nc.client.WatchSecretValue(ctx, "headcni", "headcni-auth", "auth-key", func(value string) {
+		values <- value
+	})
+
+	expectValue := func(want string) {
+		t.Helper()
+		select {
+		case got := <-values:
+			if got != want {
+				t.Errorf("expected %q, got %q", want, got)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %q", want)
+		}
+	}
+
+	// 启动时以当前值回调一次
+	expectValue("key-1")
+
+	// 空值被忽略，保留上一次的密钥
+	secret.Data["auth-key"] = nil
+	if _, err := clientset.CoreV1().Secrets("headcni").Update(ctx, secret, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("failed to update secret: %v", err)
+	}
+	secret.Data["auth-key"] = []byte("key-2")
+	if _, err := clientset.CoreV1().Secrets("headcni").Update(ctx, secret, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("failed to update secret: %v", err)
+	}
+	expectValue("key-2")
+
+	select {
+	case got := <-values:
+		t.Errorf("unexpected callback with %q", got)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestPatchPodAnnotations(t *testing.T) {
+	nc, clientset := newFakeNodeClient()
+	clientset.Tracker().Add(&coreV1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "web-0",
+			Namespace:   "default",
+			UID:         "uid-1",
+			Annotations: map[string]string{"prometheus.io/scrape": "true"},
+		},
+	})
+	pc := &podClient{client: nc.client}
+	ctx := context.Background()
+
+	if err := pc.PatchAnnotations(ctx, "default", "web-0", "uid-1", map[string]string{"headcni.io/pod-ip": "10.244.1.5"}); err != nil {
+		t.Fatalf("PatchAnnotations failed: %v", err)
+	}
+	pod, err := clientset.CoreV1().Pods("default").Get(ctx, "web-0", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get pod: %v", err)
+	}
+	if got := pod.Annotations["headcni.io/pod-ip"]; got != "10.244.1.5" {
+		t.Errorf("expected headcni.io/pod-ip=10.244.1.5, got %q", got)
+	}
+	if got := pod.Annotations["prometheus.io/scrape"]; got != "true" {
+		t.Errorf("expected unrelated annotation to survive, got %q", got)
+	}
+
+	// Pod 已删除时返回可识别的 NotFound
+	err = pc.PatchAnnotations(ctx, "default", "web-1", "uid-2", map[string]string{"headcni.io/pod-ip": "10.244.1.6"})
+	if !apierrors.IsNotFound(err) {
+		t.Errorf("expected NotFound for deleted pod, got %v", err)
+	}
+}
+
+func TestParseCorefileDomain(t *testing.T) {
+	tests := []struct {
+		name     string
+		corefile string
+		want     string
+	}{
+		{
+			name: "kubeadm",
+			corefile: `.:53 {
+    errors
+    health {
+       lameduck 5s
+    }
+    ready
+    kubernetes cluster.local in-addr.arpa ip6.arpa {
+       pods insecure
+       fallthrough in-addr.arpa ip6.arpa
+       ttl 30
+    }
+    forward . /etc/resolv.conf
+    cache 30
+}`,
+			want: "cluster.local",
+		},
+		{
+			name: "k3s",
+			corefile: `.:53 {
+    errors
+    health
+    ready
+    kubernetes cluster.local in-addr.arpa ip6.arpa {
+      pods insecure
+      fallthrough in-addr.arpa ip6.arpa
+    }
+    hosts /etc/coredns/NodeHosts {
+      ttl 60
+      reload 15s
+      fallthrough
+    }
+    prometheus :9153
+    forward . /etc/resolv.conf
+}`,
+			want: "cluster.local",
+		},
+		{
+			name: "rke2",
+			corefile: `.:53 {
+    errors
+    health  {
+        lameduck 5s
+    }
+    ready
+    kubernetes   cluster.local  cluster.local in-addr.arpa ip6.arpa {
+        pods insecure
+        fallthrough in-addr.arpa ip6.arpa
+        ttl 30
+    }
+    prometheus   0.0.0.0:9153
+    forward   . /etc/resolv.conf
+}`,
+			want: "cluster.local",
+		},
+		{
+			name: "custom domain with reverse zones first",
+			corefile: `.:53 {
+    kubernetes 10.in-addr.arpa ip6.arpa corp.example.com. {
+        pods verified
+    }
+}`,
+			want: "corp.example.com",
+		},
+		{
+			name: "custom single-label domain without block",
+			corefile: `cluster:53 {
+    # kubernetes example.local
+    kubernetes cluster
+}`,
+			want: "cluster",
+		},
+		{
+			name: "reverse zones only",
+			corefile: `.:53 {
+    kubernetes in-addr.arpa ip6.arpa{
+    }
+}`,
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := parseCorefileDomain(tt.corefile); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestGetDNSServiceIPSearchesNamespaces(t *testing.T) {
+	dnsService := &coreV1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "coredns", Namespace: "coredns", Labels: map[string]string{"k8s-app": "kube-dns"}},
+		Spec:       coreV1.ServiceSpec{ClusterIP: "10.100.0.10", Ports: []coreV1.ServicePort{{Name: "dns", Port: 53}}},
+	}
+	nc, clientset := newFakeNodeClient()
+	clientset.Tracker().Add(dnsService)
+
+	ip, err := nc.client.GetDNSServiceIP()
+	if err != nil || ip != "10.100.0.10" {
+		t.Fatalf("expected DNS service outside kube-system to be found, got %q (%v)", ip, err)
+	}
+
+	// 配置的命名空间列表中不包含服务所在命名空间时报错，不猜测默认地址
+	nc.client.config.DNSNamespaces = []string{"kube-system"}
+	if ip, err := nc.client.GetDNSServiceIP(); err == nil {
+		t.Errorf("expected an error when the DNS namespace is not searched, got %q", ip)
+	}
+
+	// 存在 NodeLocal DNSCache 时优先使用其链路本地地址
+	nc.client.config.DNSNamespaces = nil
+	clientset.Tracker().Add(&coreV1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-local-dns", Namespace: "kube-system"},
+		Data: map[string]string{"Corefile": `cluster.local:53 {
+    errors
+    cache {
+        success 9984 30
+        denial 9984 5
+    }
+    reload
+    loop
+    bind 169.254.25.10 10.100.0.10
+    forward . __PILLAR__CLUSTER__DNS__ {
+        force_tcp
+    }
+    health 169.254.25.10:8080
+}`},
+	})
+	// 只有 ConfigMap 没有运行中的 DaemonSet 时不使用
+	if ip, _ := nc.client.GetDNSServiceIP(); ip != "10.100.0.10" {
+		t.Errorf("expected the cluster DNS service without a NodeLocal DNSCache DaemonSet, got %q", ip)
+	}
+	clientset.Tracker().Add(&appsV1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-local-dns", Namespace: "kube-system"},
+		Status:     appsV1.DaemonSetStatus{NumberReady: 3},
+	})
+	if ip, _ := nc.client.GetDNSServiceIP(); ip != "169.254.25.10" {
+		t.Errorf("expected NodeLocal DNSCache address, got %q", ip)
+	}
+
+	if ip := parseNodeLocalDNSBind("bind __PILLAR__LOCAL__DNS__ __PILLAR__DNS__SERVER__"); ip != DefaultNodeLocalDNSIP {
+		t.Errorf("expected default NodeLocal address for an unrendered template, got %q", ip)
+	}
+}
@@ -54,6 +54,13 @@ type Client interface {
 
 	// 权限管理
 	GetPermissions() *PermissionStatus
+
+	// 选主：只有当选节点运行 fn，阻塞直到 ctx 结束
+	RunLeaderElectedTask(ctx context.Context, name string, fn func(ctx context.Context)) error
+
+	// Secret：namespace 为空时使用 daemon 所在命名空间
+	GetSecretValue(ctx context.Context, namespace, name, key string) (string, error)
+	WatchSecretValue(ctx context.Context, namespace, name, key string, onChange func(value string)) error
 }
 
 // =============================================================================
@@ -73,8 +80,10 @@ type NodeInterface interface {
 	// 特殊操作
 	GetPodCIDR(name string) (string, error)
 	GetAllPodCIDRs() ([]string, error)
+	GetPodCIDRsByNode() (map[string][]string, error)
 	UpdateAnnotations(name string, annotations map[string]string) error
 	UpdateLabels(name string, labels map[string]string) error
+	RemoveAnnotations(name string, keys []string) error
 }
 
 // ServiceInterface 服务操作接口
@@ -105,6 +114,8 @@ type PodInterface interface {
 	GetByNode(nodeName string) ([]*coreV1.Pod, error)
 	GetByLabel(namespace, labelKey, labelValue string) ([]*coreV1.Pod, error)
 	GetPodIP(namespace, name string) (string, error)
+	PatchAnnotations(ctx context.Context, namespace, name string, uid types.UID, annotations map[string]string) error
+	WatchByNode(ctx context.Context, nodeName string, handler PodEventHandler) error
 }
 
 // ConfigMapInterface ConfigMap 操作接口
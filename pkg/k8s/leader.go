@@ -0,0 +1,94 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// defaultNamespace 未设置 POD_NAMESPACE 时 Lease 和 Secret 所在的命名空间
+	defaultNamespace = "kube-system"
+
+	leaseDuration = 15 * time.Second
+	renewDeadline = 10 * time.Second
+	retryPeriod   = 2 * time.Second
+)
+
+// RunLeaderElectedTask 通过 Lease 选主，只有当选的节点运行 fn
+// 失去领导权时取消传给 fn 的 ctx，随后重新参与选举，直到 ctx 结束才返回
+func (c *client) RunLeaderElectedTask(ctx context.Context, name string, fn func(ctx context.Context)) error {
+	clientset := c.getClientset()
+	if clientset == nil {
+		return fmt.Errorf("client not connected")
+	}
+
+	identity, err := c.GetCurrentNodeName()
+	if err != nil {
+		return fmt.Errorf("failed to get leader election identity: %w", err)
+	}
+
+	namespace := PodNamespace()
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Client: clientset.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{
+			Identity: identity,
+		},
+	}
+
+	electionConfig := leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		LeaseDuration:   leaseDuration,
+		RenewDeadline:   renewDeadline,
+		RetryPeriod:     retryPeriod,
+		ReleaseOnCancel: true,
+		Name:            name,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(leaderCtx context.Context) {
+				klog.Infof("Acquired leadership of %s/%s as %s", namespace, name, identity)
+				fn(leaderCtx)
+			},
+			OnStoppedLeading: func() {
+				klog.Infof("Released leadership of %s/%s", namespace, name)
+			},
+			OnNewLeader: func(leader string) {
+				if leader != identity {
+					klog.V(2).Infof("Leader of %s/%s is %s", namespace, name, leader)
+				}
+			},
+		},
+	}
+
+	// Run 在失去领导权后返回，重新创建选举器再次参与选举
+	for {
+		elector, err := leaderelection.NewLeaderElector(electionConfig)
+		if err != nil {
+			return fmt.Errorf("failed to create leader elector for %s: %w", name, err)
+		}
+		elector.Run(ctx)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(retryPeriod):
+		}
+	}
+}
+
+// PodNamespace 返回 daemon 所在的命名空间（POD_NAMESPACE），未设置时为 kube-system
+func PodNamespace() string {
+	if namespace := os.Getenv("POD_NAMESPACE"); namespace != "" {
+		return namespace
+	}
+	return defaultNamespace
+}
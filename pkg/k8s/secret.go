@@ -0,0 +1,96 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+
+	coreV1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+// GetSecretValue 读取 Secret 中指定 key 的值
+func (c *client) GetSecretValue(ctx context.Context, namespace, name, key string) (string, error) {
+	clientset := c.getClientset()
+	if clientset == nil {
+		return "", fmt.Errorf("client not connected")
+	}
+	if namespace == "" {
+		namespace = PodNamespace()
+	}
+
+	secret, err := clientset.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to get secret %s/%s: %w", namespace, name, err)
+	}
+	return secretValue(secret, key)
+}
+
+// WatchSecretValue 监听 Secret 中指定 key 的值，值变化时调用 onChange，阻塞直到 ctx 结束
+// 只 list/watch 指定名称的 Secret；启动时会以当前值回调一次，Secret 被删除或 key 为空时保留上一次的值
+func (c *client) WatchSecretValue(ctx context.Context, namespace, name, key string, onChange func(value string)) error {
+	clientset := c.getClientset()
+	if clientset == nil {
+		return fmt.Errorf("client not connected")
+	}
+	if namespace == "" {
+		namespace = PodNamespace()
+	}
+
+	factory := informers.NewSharedInformerFactoryWithOptions(clientset, c.config.ResyncPeriod,
+		informers.WithNamespace(namespace),
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.FieldSelector = fields.OneTermEqualSelector("metadata.name", name).String()
+		}))
+	informer := factory.Core().V1().Secrets().Informer()
+
+	// 事件处理在 informer 内串行执行，last 无需加锁
+	var last string
+	handle := func(obj interface{}) {
+		secret, ok := obj.(*coreV1.Secret)
+		if !ok || secret.Name != name {
+			return
+		}
+		value, err := secretValue(secret, key)
+		if err != nil {
+			klog.Warningf("Ignoring update of secret %s/%s: %v", namespace, name, err)
+			return
+		}
+		if value == last {
+			return
+		}
+		last = value
+		onChange(value)
+	}
+
+	if _, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    handle,
+		UpdateFunc: func(_, newObj interface{}) { handle(newObj) },
+		DeleteFunc: func(interface{}) {
+			klog.Warningf("Secret %s/%s was deleted, keeping the last known value", namespace, name)
+		},
+	}); err != nil {
+		return fmt.Errorf("failed to watch secret %s/%s: %w", namespace, name, err)
+	}
+
+	klog.Infof("Watching secret %s/%s key %s", namespace, name, key)
+	factory.Start(ctx.Done())
+	<-ctx.Done()
+	factory.Shutdown()
+	return nil
+}
+
+// secretValue 返回 Secret 中 key 对应的非空值
+func secretValue(secret *coreV1.Secret, key string) (string, error) {
+	value, ok := secret.Data[key]
+	if !ok {
+		return "", fmt.Errorf("key %s not found in secret %s/%s", key, secret.Namespace, secret.Name)
+	}
+	if len(value) == 0 {
+		return "", fmt.Errorf("key %s is empty in secret %s/%s", key, secret.Namespace, secret.Name)
+	}
+	return string(value), nil
+}
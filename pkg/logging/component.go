@@ -0,0 +1,140 @@
+package logging
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// ComponentLevelsEnv 按组件设置日志级别的环境变量，如 "headscale=debug,ipam=warn"
+const ComponentLevelsEnv = "HEADCNI_LOG_LEVELS"
+
+var (
+	// baseLogger 核心级别为全局级别与各组件级别中的最低值，globalLogger 和组件日志器都从它派生
+	baseLogger      *zap.Logger
+	globalLevel     zapcore.LevelEnabler
+	componentLevels map[string]zapcore.Level
+	componentCache  sync.Map
+)
+
+// ParseComponentLevels 解析 "component=level" 列表，以逗号分隔
+func ParseComponentLevels(spec string) (map[string]zapcore.Level, error) {
+	levels := make(map[string]zapcore.Level)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		name, value, ok := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid component log level %q, expected component=level", entry)
+		}
+
+		var level zapcore.Level
+		if err := level.UnmarshalText([]byte(strings.TrimSpace(value))); err != nil {
+			return nil, fmt.Errorf("invalid log level for component %s: %v", name, err)
+		}
+		levels[name] = level
+	}
+	return levels, nil
+}
+
+// lowestLevel 返回全局级别与各组件级别中最低的级别，作为 zap 核心的级别
+func lowestLevel(global zapcore.LevelEnabler, components map[string]zapcore.Level) zapcore.Level {
+	lowest := zapcore.LevelOf(global)
+	for _, level := range components {
+		if level < lowest {
+			lowest = level
+		}
+	}
+	return lowest
+}
+
+// ComponentLogger 组件日志器，未单独设置级别时沿用全局级别
+type ComponentLogger struct {
+	name string
+}
+
+// Named 返回指定组件的日志器，可在 Init 之前调用
+func Named(name string) *ComponentLogger {
+	return &ComponentLogger{name: name}
+}
+
+// Enabled 判断组件是否输出 level 级别的日志
+// 未初始化 zap 日志时，只有单独设置为 debug 的组件输出调试日志
+func (l *ComponentLogger) Enabled(level zapcore.Level) bool {
+	if componentLevel, ok := componentLevels[l.name]; ok {
+		return componentLevel.Enabled(level)
+	}
+	if globalLevel != nil {
+		return globalLevel.Enabled(level)
+	}
+	return level >= zapcore.InfoLevel
+}
+
+// sugar 获取组件对应的 zap logger，未初始化时返回 nil
+func (l *ComponentLogger) sugar() *zap.SugaredLogger {
+	if baseLogger == nil {
+		return nil
+	}
+	if logger, ok := componentCache.Load(l.name); ok {
+		return logger.(*zap.SugaredLogger)
+	}
+
+	var level zapcore.LevelEnabler = globalLevel
+	if componentLevel, ok := componentLevels[l.name]; ok {
+		level = componentLevel
+	}
+	logger := baseLogger.Named(l.name).WithOptions(zap.IncreaseLevel(level)).Sugar()
+	actual, _ := componentCache.LoadOrStore(l.name, logger)
+	return actual.(*zap.SugaredLogger)
+}
+
+func (l *ComponentLogger) Debugf(template string, args ...interface{}) {
+	if !l.Enabled(zapcore.DebugLevel) {
+		return
+	}
+	if logger := l.sugar(); logger != nil {
+		logger.Debugf(template, args...)
+	} else {
+		logWithCaller("DEBUG", "["+l.name+"] "+template, args...)
+	}
+}
+
+func (l *ComponentLogger) Infof(template string, args ...interface{}) {
+	if !l.Enabled(zapcore.InfoLevel) {
+		return
+	}
+	if logger := l.sugar(); logger != nil {
+		logger.Infof(template, args...)
+	} else {
+		logWithCaller("INFO", "["+l.name+"] "+template, args...)
+	}
+}
+
+func (l *ComponentLogger) Warnf(template string, args ...interface{}) {
+	if !l.Enabled(zapcore.WarnLevel) {
+		return
+	}
+	if logger := l.sugar(); logger != nil {
+		logger.Warnf(template, args...)
+	} else {
+		logWithCaller("WARN", "["+l.name+"] "+template, args...)
+	}
+}
+
+func (l *ComponentLogger) Errorf(template string, args ...interface{}) {
+	if !l.Enabled(zapcore.ErrorLevel) {
+		return
+	}
+	if logger := l.sugar(); logger != nil {
+		logger.Errorf(template, args...)
+	} else {
+		logWithCaller("ERROR", "["+l.name+"] "+template, args...)
+	}
+}
@@ -33,6 +33,8 @@ type Config struct {
 	CallSkip int
 	// EnableConsole 是否同时输出到控制台
 	EnableConsole bool
+	// ComponentLevels 按组件覆盖的日志级别，与 HEADCNI_LOG_LEVELS 合并，此处优先
+	ComponentLevels map[string]zapcore.Level
 }
 
 // DefaultConfig 返回默认配置
@@ -94,4 +96,10 @@ func (c *Config) WithCallSkip(callSkip int) *Config {
 func (c *Config) WithConsole(enable bool) *Config {
 	c.EnableConsole = enable
 	return c
+}
+
+// WithComponentLevels 设置按组件覆盖的日志级别
+func (c *Config) WithComponentLevels(levels map[string]zapcore.Level) *Config {
+	c.ComponentLevels = levels
+	return c
 } 
\ No newline at end of file
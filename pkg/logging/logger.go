@@ -78,6 +78,22 @@ func Init(config *Config) error {
 	if config == nil {
 		config = DefaultConfig()
 	}
+	if config.Level == nil {
+		config.Level = zapcore.InfoLevel
+	}
+
+	// 合并环境变量和配置中的组件日志级别
+	levels, err := ParseComponentLevels(os.Getenv(ComponentLevelsEnv))
+	if err != nil {
+		return fmt.Errorf("invalid %s: %v", ComponentLevelsEnv, err)
+	}
+	for name, level := range config.ComponentLevels {
+		levels[name] = level
+	}
+	config.ComponentLevels = levels
+	if baseLogger == nil {
+		componentLevels = levels
+	}
 
 	if config.LogFile == "" {
 		// 如果没有指定日志文件，使用简单日志器
@@ -2,6 +2,9 @@ package logging
 
 import (
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 
 	"go.uber.org/zap/zapcore"
@@ -107,4 +110,105 @@ func TestBackwardCompatibility(t *testing.T) {
 
 	// 测试带级别的初始化
 	InitZapLogWithLevel("", zapcore.InfoLevel) // 应该不会出错
-} 
\ No newline at end of file
+} 
+func TestParseComponentLevels(t *testing.T) {
+	levels, err := ParseComponentLevels(" headscale=debug, ipam=WARN ,")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(levels) != 2 || levels["headscale"] != zapcore.DebugLevel || levels["ipam"] != zapcore.WarnLevel {
+		t.Errorf("Unexpected component levels: %v", levels)
+	}
+
+	for _, spec := range []string{"headscale", "=debug", "headscale=verbose"} {
+		if _, err := ParseComponentLevels(spec); err == nil {
+			t.Errorf("Expected error for %q", spec)
+		}
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cni-plugin.log")
+	file := NewRotatingFile(path, 1, 2)
+	file.maxSize = 100
+
+	// 多个写入方并发追加，每行 20 字节，每个文件最多 5 行
+	line := []byte("0123456789abcdefghi\n")
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 3; j++ {
+				if _, err := file.Write(line); err != nil {
+					t.Errorf("Write failed: %v", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	total := 0
+	for _, p := range append(file.BackupPaths(), path) {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", p, err)
+		}
+		if int64(len(data)) > file.maxSize {
+			t.Errorf("Expected %s to stay within %d bytes, got %d", p, file.maxSize, len(data))
+		}
+		if strings.Count(string(data), string(line)) != len(data)/len(line) {
+			t.Errorf("Expected only whole lines in %s, got %q", p, data)
+		}
+		total += len(data) / len(line)
+	}
+	if total != 12 {
+		t.Errorf("Expected 12 lines across the log files, got %d", total)
+	}
+	if backups := file.BackupPaths(); len(backups) != 2 || backups[0] != path+".2" || backups[1] != path+".1" {
+		t.Errorf("Expected backups %s.2 and %s.1, got %v", path, path, backups)
+	}
+
+	// 超过保留数的旧文件被丢弃
+	for i := 0; i < 10; i++ {
+		file.Write(line)
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("Expected at most 2 backups, found %s.3", path)
+	}
+}
+
+func TestRotatingFileCopyTruncate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tailscaled.log")
+	file := NewRotatingFile(path, 1, 2)
+	file.maxSize = 100
+
+	// 模拟 tailscaled：持有自己的 O_APPEND 描述符持续写入
+	writer, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+
+	old := strings.Repeat("old line\n", 12)
+	writer.WriteString(old)
+	if err := file.CopyTruncate(); err != nil {
+		t.Fatalf("CopyTruncate failed: %v", err)
+	}
+	writer.WriteString("new line\n")
+
+	if data, err := os.ReadFile(path + ".1"); err != nil || string(data) != old {
+		t.Errorf("Expected the old content in %s.1, got %q (%v)", path, data, err)
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != "new line\n" {
+		t.Errorf("Expected the writer to continue at the start of %s, got %q (%v)", path, data, err)
+	}
+
+	// 未超过上限时不轮转
+	if err := file.CopyTruncate(); err != nil {
+		t.Fatalf("CopyTruncate failed: %v", err)
+	}
+	if backups := file.BackupPaths(); len(backups) != 1 {
+		t.Errorf("Expected a single backup, got %v", backups)
+	}
+}
@@ -0,0 +1,157 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// DefaultRotatingFileMaxSizeMB RotatingFile 默认单个文件最大大小（MB）
+	DefaultRotatingFileMaxSizeMB = 10
+	// DefaultRotatingFileMaxBackups RotatingFile 默认保留的轮转文件数
+	DefaultRotatingFileMaxBackups = 3
+)
+
+// RotatingFile 按大小轮转的日志文件，可由多个独立进程同时追加，如每次 ADD/DEL 启动的 CNI 插件
+// 每次写入都持有 <path>.lock 的排他锁：检查大小、必要时轮转，再以 O_APPEND 追加整段数据，
+// 进程之间的轮转和写入不会交错。轮转后的文件依次为 <path>.1（最新）到 <path>.<maxBackups>，
+// 磁盘占用最多为 maxSize * (maxBackups + 1)
+type RotatingFile struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+}
+
+// NewRotatingFile 创建轮转日志文件，maxSizeMB、maxBackups 不大于 0 时使用默认值
+func NewRotatingFile(path string, maxSizeMB, maxBackups int) *RotatingFile {
+	if maxSizeMB <= 0 {
+		maxSizeMB = DefaultRotatingFileMaxSizeMB
+	}
+	if maxBackups <= 0 {
+		maxBackups = DefaultRotatingFileMaxBackups
+	}
+	return &RotatingFile{
+		path:       path,
+		maxSize:    int64(maxSizeMB) * 1024 * 1024,
+		maxBackups: maxBackups,
+	}
+}
+
+// Path 返回当前写入的日志文件路径
+func (f *RotatingFile) Path() string {
+	return f.path
+}
+
+// BackupPaths 返回已存在的轮转文件，从最旧到最新
+func (f *RotatingFile) BackupPaths() []string {
+	var paths []string
+	for i := f.maxBackups; i >= 1; i-- {
+		if _, err := os.Stat(f.backupPath(i)); err == nil {
+			paths = append(paths, f.backupPath(i))
+		}
+	}
+	return paths
+}
+
+// Write 追加 p，写入后超过大小上限时先轮转；p 应为完整的一行或多行
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	if err := os.MkdirAll(filepath.Dir(f.path), 0755); err != nil {
+		return 0, fmt.Errorf("failed to create log directory: %v", err)
+	}
+
+	lock, err := os.OpenFile(f.path+".lock", os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open lock file: %v", err)
+	}
+	defer lock.Close()
+	if err := lockFile(lock); err != nil {
+		return 0, fmt.Errorf("failed to lock %s: %v", lock.Name(), err)
+	}
+	defer unlockFile(lock)
+
+	if info, err := os.Stat(f.path); err == nil && info.Size() > 0 && info.Size()+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open log file: %v", err)
+	}
+	defer file.Close()
+	return file.Write(p)
+}
+
+// CopyTruncate 当前文件超过大小上限时复制到 <path>.1 后截断，用于由其他进程通过自己的 O_APPEND 描述符写入的文件，
+// 如 tailscaled 的 stdout/stderr；重命名会让写入方继续写入已轮转的文件，截断后写入方从文件开头继续追加
+func (f *RotatingFile) CopyTruncate() error {
+	info, err := os.Stat(f.path)
+	if err != nil || info.Size() <= f.maxSize {
+		return nil
+	}
+
+	lock, err := os.OpenFile(f.path+".lock", os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open lock file: %v", err)
+	}
+	defer lock.Close()
+	if err := lockFile(lock); err != nil {
+		return fmt.Errorf("failed to lock %s: %v", lock.Name(), err)
+	}
+	defer unlockFile(lock)
+
+	if err := f.shiftBackups(); err != nil {
+		return err
+	}
+	src, err := os.Open(f.path)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %v", err)
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(f.backupPath(1), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create rotated log file: %v", err)
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return fmt.Errorf("failed to copy log file: %v", err)
+	}
+	if err := dst.Close(); err != nil {
+		return fmt.Errorf("failed to copy log file: %v", err)
+	}
+	if err := os.Truncate(f.path, 0); err != nil {
+		return fmt.Errorf("failed to truncate log file: %v", err)
+	}
+	return nil
+}
+
+// rotate 依次后移轮转文件并丢弃最旧的一个，调用方需持有锁
+func (f *RotatingFile) rotate() error {
+	if err := f.shiftBackups(); err != nil {
+		return err
+	}
+	if err := os.Rename(f.path, f.backupPath(1)); err != nil {
+		return fmt.Errorf("failed to rotate log file: %v", err)
+	}
+	return nil
+}
+
+// shiftBackups 丢弃最旧的轮转文件并把其余的依次后移，空出 <path>.1，调用方需持有锁
+func (f *RotatingFile) shiftBackups() error {
+	if err := os.Remove(f.backupPath(f.maxBackups)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove oldest log file: %v", err)
+	}
+	for i := f.maxBackups - 1; i >= 1; i-- {
+		if err := os.Rename(f.backupPath(i), f.backupPath(i+1)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to rotate log file: %v", err)
+		}
+	}
+	return nil
+}
+
+func (f *RotatingFile) backupPath(index int) string {
+	return fmt.Sprintf("%s.%d", f.path, index)
+}
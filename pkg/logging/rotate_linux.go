@@ -0,0 +1,18 @@
+//go:build linux
+// +build linux
+
+package logging
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile 获取排他 flock，其他进程写入期间阻塞等待
+func lockFile(file *os.File) error {
+	return syscall.Flock(int(file.Fd()), syscall.LOCK_EX)
+}
+
+func unlockFile(file *os.File) error {
+	return syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+// +build !linux
+
+package logging
+
+import "os"
+
+// lockFile 非 Linux 平台不运行 CNI 插件，不做跨进程加锁
+func lockFile(file *os.File) error {
+	return nil
+}
+
+func unlockFile(file *os.File) error {
+	return nil
+}
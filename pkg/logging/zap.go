@@ -75,11 +75,11 @@ func createZapLogger(config *Config) *zap.SugaredLogger {
 		EncodeName:     zapcore.FullNameEncoder,
 	}
 
-	// 创建核心
+	// 创建核心，级别取全局和各组件级别中的最低值，全局 logger 再提升回全局级别
 	core := zapcore.NewCore(
 		zapcore.NewConsoleEncoder(encoderConfig),
 		zapcore.NewMultiWriteSyncer(writers...),
-		config.Level,
+		lowestLevel(config.Level, config.ComponentLevels),
 	)
 
 	// 创建 logger 并添加选项
@@ -91,5 +91,8 @@ func createZapLogger(config *Config) *zap.SugaredLogger {
 		zap.Development(),
 	)
 
-	return logger.Sugar()
+	baseLogger = logger
+	globalLevel = config.Level
+
+	return logger.WithOptions(zap.IncreaseLevel(config.Level)).Sugar()
 }
@@ -1,7 +1,9 @@
 package monitoring
 
 import (
+	"math"
 	"net/http"
+	"sync/atomic"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -43,6 +45,13 @@ var (
 		},
 	)
 
+	ipamReclaimedIPs = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "tailscale_cni_ipam_reclaimed_ips_total",
+			Help: "Number of leaked IP allocations reclaimed by the IPAM reconciler",
+		},
+	)
+
 	// Tailscale 连接指标
 	tailscaleConnectionStatus = promauto.NewGauge(
 		prometheus.GaugeOpts{
@@ -51,6 +60,14 @@ var (
 		},
 	)
 
+	tailscaleStateTransitions = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "tailscale_cni_backend_state_transitions_total",
+			Help: "Number of Tailscale backend state transitions",
+		},
+		[]string{"from", "to"},
+	)
+
 	tailscalePeerCount = promauto.NewGauge(
 		prometheus.GaugeOpts{
 			Name: "tailscale_cni_peer_count",
@@ -58,6 +75,89 @@ var (
 		},
 	)
 
+	// tailscaleServiceInfo 每个 Tailscale 服务一条序列，标签为当前模式和状态，值恒为 1
+	tailscaleServiceInfo = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "tailscale_cni_service_info",
+			Help: "Mode and state of each Tailscale service managed by HeadCNI (always 1)",
+		},
+		[]string{"service", "mode", "status", "backend_state"},
+	)
+
+	tailscaleInterfaceMTU = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "tailscale_cni_interface_mtu",
+			Help: "Observed MTU of the HeadCNI-managed Tailscale interface",
+		},
+		[]string{"interface"},
+	)
+
+	tailscaleInterfaceMTUResets = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "tailscale_cni_interface_mtu_resets_total",
+			Help: "Number of times the Tailscale interface MTU drifted and was reset",
+		},
+		[]string{"interface"},
+	)
+
+	// nodeKeyExpiry 节点密钥过期时间（UnixNano），0 表示不过期或未知
+	nodeKeyExpiry atomic.Int64
+
+	_ = promauto.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name: "headcni_auth_key_expiry_seconds",
+			Help: "Seconds until the node key expires (+Inf when the node key does not expire)",
+		},
+		func() float64 {
+			expiry := nodeKeyExpiry.Load()
+			if expiry == 0 {
+				return math.Inf(1)
+			}
+			return time.Until(time.Unix(0, expiry)).Seconds()
+		},
+	)
+
+	// Headscale 熔断器指标
+	headscaleCircuitBreakerState = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "tailscale_cni_headscale_circuit_breaker_state",
+			Help: "Headscale circuit breaker state (0=closed, 1=half-open, 2=open)",
+		},
+	)
+
+	headscaleCircuitBreakerRejected = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "tailscale_cni_headscale_circuit_breaker_rejected_total",
+			Help: "Number of Headscale requests rejected while the circuit breaker was open",
+		},
+	)
+
+	// routeConflicts 本节点通告且同时被其他节点通告的路由数
+	routeConflicts = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "tailscale_cni_route_conflicts",
+			Help: "Number of routes advertised by this node that other nodes also advertise",
+		},
+	)
+
+	// cniConfigBackups CNI 配置目录中的备份与恢复操作
+	cniConfigBackups = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "headcni_cni_config_backups_total",
+			Help: "Number of CNI config file backup and restore operations",
+		},
+		[]string{"operation", "result"},
+	)
+
+	// tailscaledHangs 看门狗检测到 tailscaled 卡死的次数
+	tailscaledHangs = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "headcni_tailscaled_hangs_total",
+			Help: "Number of times the watchdog found tailscaled wedged (GetStatus not returning)",
+		},
+		[]string{"mode"},
+	)
+
 	// 系统健康指标
 	systemHealthStatus = promauto.NewGaugeVec(
 		prometheus.GaugeOpts{
@@ -95,6 +195,13 @@ func UpdateIPAMMetrics(allocated, total int) {
 	}
 }
 
+// RecordReclaimedIPs 记录回收的泄漏 IP 数量
+func RecordReclaimedIPs(count int) {
+	if count > 0 {
+		ipamReclaimedIPs.Add(float64(count))
+	}
+}
+
 func UpdateTailscaleMetrics(connected bool, peerCount int) {
 	if connected {
 		tailscaleConnectionStatus.Set(1)
@@ -104,6 +211,71 @@ func UpdateTailscaleMetrics(connected bool, peerCount int) {
 	tailscalePeerCount.Set(float64(peerCount))
 }
 
+// RecordTailscaleStateTransition 记录 Tailscale 后端状态变化
+func RecordTailscaleStateTransition(from, to string) {
+	if from == "" {
+		from = "unknown"
+	}
+	tailscaleStateTransitions.WithLabelValues(from, to).Inc()
+}
+
+// UpdateTailscaleService 记录服务当前的模式和状态，并删除该服务旧标签组合的序列
+func UpdateTailscaleService(service, mode, status, backendState string) {
+	tailscaleServiceInfo.DeletePartialMatch(prometheus.Labels{"service": service})
+	tailscaleServiceInfo.WithLabelValues(service, mode, status, backendState).Set(1)
+}
+
+// UpdateInterfaceMTU 记录观察到的 Tailscale 接口 MTU
+func UpdateInterfaceMTU(name string, mtu int) {
+	tailscaleInterfaceMTU.WithLabelValues(name).Set(float64(mtu))
+}
+
+// RecordInterfaceMTUReset 记录一次接口 MTU 漂移后的重置
+func RecordInterfaceMTUReset(name string) {
+	tailscaleInterfaceMTUResets.WithLabelValues(name).Inc()
+}
+
+// UpdateNodeKeyExpiry 记录节点密钥过期时间，零值表示不过期
+func UpdateNodeKeyExpiry(expiry time.Time) {
+	if expiry.IsZero() {
+		nodeKeyExpiry.Store(0)
+		return
+	}
+	nodeKeyExpiry.Store(expiry.UnixNano())
+}
+
+// UpdateHeadscaleCircuitBreaker 更新 Headscale 熔断器状态（closed、half-open、open）
+func UpdateHeadscaleCircuitBreaker(state string) {
+	switch state {
+	case "open":
+		headscaleCircuitBreakerState.Set(2)
+	case "half-open":
+		headscaleCircuitBreakerState.Set(1)
+	default:
+		headscaleCircuitBreakerState.Set(0)
+	}
+}
+
+// RecordHeadscaleRequestRejected 记录被熔断器拒绝的 Headscale 请求
+func RecordHeadscaleRequestRejected() {
+	headscaleCircuitBreakerRejected.Inc()
+}
+
+// UpdateRouteConflicts 记录与其他节点重叠的本节点路由数
+func UpdateRouteConflicts(count int) {
+	routeConflicts.Set(float64(count))
+}
+
+// RecordCNIConfigBackup 记录一次 CNI 配置备份（operation=backup）或恢复（operation=restore）
+func RecordCNIConfigBackup(operation, result string) {
+	cniConfigBackups.WithLabelValues(operation, result).Inc()
+}
+
+// RecordTailscaledHang 记录一次看门狗检测到的 tailscaled 卡死，mode 为 tailscale 模式
+func RecordTailscaledHang(mode string) {
+	tailscaledHangs.WithLabelValues(mode).Inc()
+}
+
 // 更新系统健康状态
 func UpdateSystemHealth(component string, healthy bool) {
 	if healthy {
@@ -130,6 +302,7 @@ func InitMetrics() {
 	ipamPoolUtilization.Set(0)
 	tailscaleConnectionStatus.Set(0)
 	tailscalePeerCount.Set(0)
+	headscaleCircuitBreakerState.Set(0)
 
 	// 初始化系统健康状态
 	components := []string{"cni", "tailscale", "headscale", "k8s", "overall"}
@@ -157,6 +330,7 @@ func ResetMetrics() {
 	ipamPoolUtilization.Set(0)
 	tailscaleConnectionStatus.Set(0)
 	tailscalePeerCount.Set(0)
+	headscaleCircuitBreakerState.Set(0)
 
 	// 重置系统健康状态
 	components := []string{"cni", "tailscale", "headscale", "k8s", "overall"}
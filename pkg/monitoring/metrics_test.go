@@ -0,0 +1,57 @@
+package monitoring
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// serviceInfoSeries 返回 tailscale_cni_service_info 中属于 service 的序列标签
+func serviceInfoSeries(t *testing.T, service string) []map[string]string {
+	t.Helper()
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("Gather failed: %v", err)
+	}
+
+	var series []map[string]string
+	for _, family := range families {
+		if family.GetName() != "tailscale_cni_service_info" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := make(map[string]string)
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			if labels["service"] == service {
+				if value := metric.GetGauge().GetValue(); value != 1 {
+					t.Errorf("expected service info value 1, got %v", value)
+				}
+				series = append(series, labels)
+			}
+		}
+	}
+	return series
+}
+
+func TestUpdateTailscaleServiceReplacesSeries(t *testing.T) {
+	UpdateTailscaleService("tailscaled", "standalone", "running", "Starting")
+	UpdateTailscaleService("other", "tsnet", "running", "Running")
+
+	// 状态变化时只保留当前标签组合，其他服务的序列不受影响
+	UpdateTailscaleService("tailscaled", "standalone", "running", "Running")
+	series := serviceInfoSeries(t, "tailscaled")
+	if len(series) != 1 || series[0]["mode"] != "standalone" || series[0]["status"] != "running" || series[0]["backend_state"] != "Running" {
+		t.Fatalf("expected a single current series for tailscaled, got %v", series)
+	}
+	if other := serviceInfoSeries(t, "other"); len(other) != 1 {
+		t.Fatalf("expected the other service to keep its series, got %v", other)
+	}
+
+	UpdateTailscaleService("tailscaled", "standalone", "disconnected", "")
+	series = serviceInfoSeries(t, "tailscaled")
+	if len(series) != 1 || series[0]["status"] != "disconnected" || series[0]["backend_state"] != "" {
+		t.Fatalf("expected the disconnected series to replace the running one, got %v", series)
+	}
+}
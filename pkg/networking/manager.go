@@ -4,9 +4,11 @@ package networking
 import (
 	"crypto/sha1"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"net"
 	"os"
+	"syscall"
 
 	"github.com/containernetworking/plugins/pkg/ns"
 	"github.com/vishvananda/netlink"
@@ -161,6 +163,40 @@ func (nm *NetworkManager) SetupPodNetwork(netnsPath, containerIfName string, pod
 	})
 }
 
+// AddPodRoutes 在容器网络命名空间中为 pod_routes 安装经 Pod 网关的路由，宿主机再经 tailscale 接口转发到子网路由器
+// 与网关地址族不同的网段被跳过；使用 RouteReplace，重复 ADD 时不会报错。网络命名空间销毁时路由随之删除，DEL 无需清理
+func (nm *NetworkManager) AddPodRoutes(netnsPath, containerIfName string, gateway net.IP, routes []*net.IPNet) error {
+	if len(routes) == 0 {
+		return nil
+	}
+	gatewayIsV4 := gateway.To4() != nil
+
+	return ns.WithNetNSPath(netnsPath, func(hostNS ns.NetNS) error {
+		contVeth, err := netlink.LinkByName(containerIfName)
+		if err != nil {
+			return fmt.Errorf("failed to lookup %s: %v", containerIfName, err)
+		}
+
+		for _, dst := range routes {
+			if (dst.IP.To4() != nil) != gatewayIsV4 {
+				klog.V(4).Infof("Skipping pod route %s, gateway %s is of a different address family", dst.String(), gateway.String())
+				continue
+			}
+			route := &netlink.Route{
+				LinkIndex: contVeth.Attrs().Index,
+				Scope:     netlink.SCOPE_UNIVERSE,
+				Dst:       dst,
+				Gw:        gateway,
+			}
+			if err := netlink.RouteReplace(route); err != nil {
+				return fmt.Errorf("failed to add pod route %s via %s: %v", dst.String(), gateway.String(), err)
+			}
+			klog.V(4).Infof("Added pod route: %s via %s", dst.String(), gateway.String())
+		}
+		return nil
+	})
+}
+
 // SetupHostRoute 配置宿主机路由
 func (nm *NetworkManager) SetupHostRoute(hostVethName string, podIP net.IP) error {
 	// 获取宿主机上的veth接口
@@ -216,6 +252,51 @@ func (nm *NetworkManager) CleanupHostRoute(hostVethName string) error {
 	return nil
 }
 
+// FlushConntrackForIP 删除与指定 IP 相关的 conntrack 条目（原方向或应答方向的源/目的地址）
+// IP 被复用前调用，避免新 Pod 收到属于旧 Pod 连接的应答；未加载 conntrack 模块时直接返回
+func (nm *NetworkManager) FlushConntrackForIP(ip net.IP) error {
+	if ip == nil {
+		return fmt.Errorf("IP is empty")
+	}
+
+	family := netlink.InetFamily(netlink.FAMILY_V4)
+	mask := net.CIDRMask(32, 32)
+	if ip.To4() == nil {
+		family = netlink.InetFamily(netlink.FAMILY_V6)
+		mask = net.CIDRMask(128, 128)
+	} else {
+		ip = ip.To4()
+	}
+	hostNet := &net.IPNet{IP: ip, Mask: mask}
+
+	// 多个过滤器之间为“或”关系，任一方向涉及该 IP 的条目都会被删除
+	var filters []netlink.CustomConntrackFilter
+	for _, tp := range []netlink.ConntrackFilterType{
+		netlink.ConntrackOrigSrcIP,
+		netlink.ConntrackOrigDstIP,
+		netlink.ConntrackReplySrcIP,
+		netlink.ConntrackReplyDstIP,
+	} {
+		filter := &netlink.ConntrackFilter{}
+		if err := filter.AddIPNet(tp, hostNet); err != nil {
+			return fmt.Errorf("failed to build conntrack filter: %v", err)
+		}
+		filters = append(filters, filter)
+	}
+
+	deleted, err := netlink.ConntrackDeleteFilters(netlink.ConntrackTable, family, filters...)
+	if err != nil {
+		if errors.Is(err, syscall.EPROTONOSUPPORT) || errors.Is(err, syscall.ENOENT) {
+			klog.V(4).Infof("Conntrack is not available, skipping flush for %s: %v", ip.String(), err)
+			return nil
+		}
+		return fmt.Errorf("failed to flush conntrack entries for %s: %v", ip.String(), err)
+	}
+
+	klog.V(4).Infof("Flushed %d conntrack entries for %s", deleted, ip.String())
+	return nil
+}
+
 // VethNameForWorkload 生成veth名称
 func (nm *NetworkManager) VethNameForWorkload(namespace, podname string) string {
 	// A SHA1 is always 20 bytes long, and so is sufficient for generating the
@@ -0,0 +1,402 @@
+package policy
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/coreos/go-iptables/iptables"
+	"k8s.io/klog/v2"
+)
+
+// 策略链名称
+// FORWARD 中匹配 Pod CIDR 的流量跳转到 ChainPolicy，INPUT 中来自 Pod CIDR 的流量跳转到 ChainPolicyInput
+// 每个本节点 Pod 各有一条出向链（HCNI-E-<hash>）和入向链（HCNI-I-<hash>）
+const (
+	ChainPolicy      = "HEADCNI-POLICY"
+	ChainPolicyInput = "HEADCNI-POLICY-INPUT"
+
+	chainPodPrefix     = "HCNI-"
+	chainEgressPrefix  = "HCNI-E-"
+	chainIngressPrefix = "HCNI-I-"
+
+	tableFilter = "filter"
+)
+
+// tailnetCIDR Tailscale 分配给节点的 CGNAT 地址段
+var tailnetCIDR = &net.IPNet{IP: net.IPv4(100, 64, 0, 0).To4(), Mask: net.CIDRMask(10, 32)}
+
+// EgressPolicy Pod 出向策略，对应 CNI 配置中的 policies
+type EgressPolicy struct {
+	AllowHostAccess     bool // 允许访问本节点
+	AllowServiceAccess  bool // 允许访问 Service CIDR
+	AllowExternalAccess bool // 允许访问集群和 tailnet 以外的地址
+	EgressAllowed       bool // 允许所有出向流量（包括其他命名空间的 Pod）
+}
+
+// PodEndpoint Pod 地址
+type PodEndpoint struct {
+	Namespace string
+	Name      string
+	IP        net.IP
+}
+
+// State 策略期望状态
+type State struct {
+	// 本节点 Pod CIDR，规则只作用于该网段
+	PodCIDR *net.IPNet
+	// 集群 Pod CIDR 和 Service CIDR，用于区分集群内外流量
+	ClusterCIDRs []*net.IPNet
+	ServiceCIDRs []*net.IPNet
+
+	Egress EgressPolicy
+
+	// 本节点 Pod，每个 Pod 生成独立的出向/入向链
+	LocalPods []PodEndpoint
+	// 全集群按命名空间划分的 Pod IP，同命名空间互通
+	NamespaceIPs map[string][]net.IP
+	// 按 ACL tag 放行的 tailnet 节点地址
+	AllowedPeerIPs []net.IP
+	// 这些命名空间中的 Pod 接受来自任意 Pod 的入向流量
+	OpenNamespaces map[string]bool
+}
+
+// iptablesInterface Enforcer 使用的 iptables 操作
+type iptablesInterface interface {
+	Exists(table, chain string, rulespec ...string) (bool, error)
+	Insert(table, chain string, pos int, rulespec ...string) error
+	Append(table, chain string, rulespec ...string) error
+	DeleteIfExists(table, chain string, rulespec ...string) error
+	List(table, chain string) ([]string, error)
+	ListChains(table string) ([]string, error)
+	ClearChain(table, chain string) error
+	ClearAndDeleteChain(table, chain string) error
+}
+
+// chainRules 一条链及其规则
+type chainRules struct {
+	name  string
+	rules [][]string
+}
+
+// Enforcer 在宿主机上以 iptables 链实现 Pod 网络策略
+type Enforcer struct {
+	ipt      iptablesInterface
+	mu       sync.Mutex
+	lastHash string
+}
+
+// NewEnforcer 创建 iptables 策略执行器
+func NewEnforcer() (*Enforcer, error) {
+	ipt, err := iptables.New()
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize iptables: %v", err)
+	}
+	return newEnforcer(ipt), nil
+}
+
+func newEnforcer(ipt iptablesInterface) *Enforcer {
+	return &Enforcer{ipt: ipt}
+}
+
+// Apply 使宿主机规则与期望状态一致
+// 期望状态未变化且规则完好时不做任何修改；规则被清空或篡改时重新下发
+func (e *Enforcer) Apply(state *State) error {
+	if state == nil || state.PodCIDR == nil {
+		return fmt.Errorf("pod CIDR is required")
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	chains, jumps := buildRules(state)
+	hash := rulesHash(chains, jumps)
+
+	if hash == e.lastHash {
+		intact, err := e.verify(chains, jumps)
+		if err != nil {
+			return err
+		}
+		if intact {
+			return nil
+		}
+		klog.Warningf("Network policy rules were modified externally, re-applying")
+	}
+
+	if err := e.program(chains, jumps); err != nil {
+		e.lastHash = ""
+		return err
+	}
+
+	e.lastHash = hash
+	klog.Infof("Applied network policy rules for %d local pods", len(state.LocalPods))
+	return nil
+}
+
+// Cleanup 删除所有策略规则和链
+func (e *Enforcer) Cleanup(podCIDR *net.IPNet) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var errs []string
+	if podCIDR != nil {
+		for _, jump := range jumpRules(podCIDR) {
+			if err := e.ipt.DeleteIfExists(tableFilter, jump.name, jump.rules[0]...); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+	}
+
+	existing, err := e.ipt.ListChains(tableFilter)
+	if err != nil {
+		return fmt.Errorf("failed to list iptables chains: %v", err)
+	}
+	for _, chain := range existing {
+		if isManagedChain(chain) {
+			if err := e.ipt.ClearAndDeleteChain(tableFilter, chain); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+	}
+
+	e.lastHash = ""
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to cleanup network policy rules: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// program 下发所有链和跳转规则，并删除不再需要的链
+func (e *Enforcer) program(chains []chainRules, jumps []chainRules) error {
+	// 先填充 Pod 链，再填充入口链，避免入口链引用不存在的链
+	for i := len(chains) - 1; i >= 0; i-- {
+		chain := chains[i]
+		if err := e.ipt.ClearChain(tableFilter, chain.name); err != nil {
+			return fmt.Errorf("failed to prepare chain %s: %v", chain.name, err)
+		}
+		for _, rule := range chain.rules {
+			if err := e.ipt.Append(tableFilter, chain.name, rule...); err != nil {
+				return fmt.Errorf("failed to append rule to %s: %v", chain.name, err)
+			}
+		}
+	}
+
+	for _, jump := range jumps {
+		exists, err := e.ipt.Exists(tableFilter, jump.name, jump.rules[0]...)
+		if err != nil {
+			return fmt.Errorf("failed to check jump in %s: %v", jump.name, err)
+		}
+		if !exists {
+			if err := e.ipt.Insert(tableFilter, jump.name, 1, jump.rules[0]...); err != nil {
+				return fmt.Errorf("failed to insert jump in %s: %v", jump.name, err)
+			}
+		}
+	}
+
+	desired := make(map[string]bool, len(chains))
+	for _, chain := range chains {
+		desired[chain.name] = true
+	}
+
+	existing, err := e.ipt.ListChains(tableFilter)
+	if err != nil {
+		return fmt.Errorf("failed to list iptables chains: %v", err)
+	}
+	for _, chain := range existing {
+		if isManagedChain(chain) && !desired[chain] {
+			if err := e.ipt.ClearAndDeleteChain(tableFilter, chain); err != nil {
+				klog.Warningf("Failed to delete stale chain %s: %v", chain, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// verify 检查跳转规则存在且每条链的规则数与期望一致
+func (e *Enforcer) verify(chains []chainRules, jumps []chainRules) (bool, error) {
+	for _, jump := range jumps {
+		exists, err := e.ipt.Exists(tableFilter, jump.name, jump.rules[0]...)
+		if err != nil {
+			return false, fmt.Errorf("failed to check jump in %s: %v", jump.name, err)
+		}
+		if !exists {
+			return false, nil
+		}
+	}
+
+	for _, chain := range chains {
+		rules, err := e.ipt.List(tableFilter, chain.name)
+		if err != nil {
+			// 链不存在
+			return false, nil
+		}
+		// List 的第一行是 -N <chain>
+		if len(rules)-1 != len(chain.rules) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// buildRules 根据期望状态生成链规则和跳转规则
+// chains 中入口链在前，Pod 链在后
+func buildRules(state *State) ([]chainRules, []chainRules) {
+	pods := append([]PodEndpoint(nil), state.LocalPods...)
+	sort.Slice(pods, func(i, j int) bool { return pods[i].IP.String() < pods[j].IP.String() })
+
+	policyChain := chainRules{name: ChainPolicy}
+	policyChain.rules = append(policyChain.rules,
+		[]string{"-m", "conntrack", "--ctstate", "ESTABLISHED,RELATED", "-j", "ACCEPT"})
+
+	var podChains []chainRules
+	for _, pod := range pods {
+		if pod.IP == nil || !state.PodCIDR.Contains(pod.IP) {
+			continue
+		}
+
+		egress := chainRules{name: podChainName(chainEgressPrefix, pod.IP), rules: egressRules(state, pod)}
+		ingress := chainRules{name: podChainName(chainIngressPrefix, pod.IP), rules: ingressRules(state, pod)}
+		podChains = append(podChains, egress, ingress)
+
+		// 先检查出向，允许时 RETURN 回到入口链继续检查目的 Pod 的入向
+		policyChain.rules = append(policyChain.rules,
+			[]string{"-s", hostCIDR(pod.IP), "-j", egress.name},
+			[]string{"-d", hostCIDR(pod.IP), "-j", ingress.name})
+	}
+
+	inputChain := chainRules{name: ChainPolicyInput}
+	if !state.Egress.AllowHostAccess {
+		inputChain.rules = append(inputChain.rules,
+			[]string{"-m", "conntrack", "--ctstate", "ESTABLISHED,RELATED", "-j", "ACCEPT"},
+			[]string{"-j", "DROP"})
+	}
+
+	chains := append([]chainRules{policyChain, inputChain}, podChains...)
+	return chains, jumpRules(state.PodCIDR)
+}
+
+// jumpRules 从内置链跳转到策略链的规则
+func jumpRules(podCIDR *net.IPNet) []chainRules {
+	cidr := podCIDR.String()
+	return []chainRules{
+		{name: "FORWARD", rules: [][]string{{"-s", cidr, "-j", ChainPolicy}}},
+		{name: "FORWARD", rules: [][]string{{"-d", cidr, "-j", ChainPolicy}}},
+		{name: "INPUT", rules: [][]string{{"-s", cidr, "-j", ChainPolicyInput}}},
+	}
+}
+
+// egressRules Pod 出向规则：允许的流量 RETURN，其余 DROP
+func egressRules(state *State, pod PodEndpoint) [][]string {
+	var rules [][]string
+
+	// 同命名空间互通
+	for _, ip := range namespacePeers(state, pod) {
+		rules = append(rules, []string{"-d", hostCIDR(ip), "-j", "RETURN"})
+	}
+
+	// 按 tag 放行的 tailnet 节点
+	for _, ip := range state.AllowedPeerIPs {
+		rules = append(rules, []string{"-d", hostCIDR(ip), "-j", "RETURN"})
+	}
+
+	// Service 流量在 PREROUTING 已被 DNAT，按原始目的地址匹配
+	if state.Egress.AllowServiceAccess {
+		for _, cidr := range state.ServiceCIDRs {
+			rules = append(rules, []string{"-m", "conntrack", "--ctstate", "DNAT", "--ctorigdst", cidr.String(), "-j", "RETURN"})
+		}
+	}
+
+	if state.Egress.EgressAllowed {
+		rules = append(rules, []string{"-j", "RETURN"})
+		return rules
+	}
+
+	// 集群内和 tailnet 内的其余地址不属于外部访问
+	for _, cidr := range append(append([]*net.IPNet{}, state.ClusterCIDRs...), state.ServiceCIDRs...) {
+		rules = append(rules, []string{"-d", cidr.String(), "-j", "DROP"})
+	}
+	rules = append(rules, []string{"-d", tailnetCIDR.String(), "-j", "DROP"})
+
+	if state.Egress.AllowExternalAccess {
+		rules = append(rules, []string{"-j", "RETURN"})
+	} else {
+		rules = append(rules, []string{"-j", "DROP"})
+	}
+	return rules
+}
+
+// ingressRules Pod 入向规则：只允许同命名空间 Pod 和按 tag 放行的 tailnet 节点
+// 宿主机发起的流量（如 kubelet 探针）经过 OUTPUT 链，不受影响
+func ingressRules(state *State, pod PodEndpoint) [][]string {
+	if state.OpenNamespaces[pod.Namespace] {
+		return [][]string{{"-j", "RETURN"}}
+	}
+
+	var rules [][]string
+
+	for _, ip := range namespacePeers(state, pod) {
+		rules = append(rules, []string{"-s", hostCIDR(ip), "-j", "RETURN"})
+	}
+	for _, ip := range state.AllowedPeerIPs {
+		rules = append(rules, []string{"-s", hostCIDR(ip), "-j", "RETURN"})
+	}
+
+	rules = append(rules, []string{"-j", "DROP"})
+	return rules
+}
+
+// namespacePeers 返回与 Pod 同命名空间的其他 Pod IP（已排序去重）
+func namespacePeers(state *State, pod PodEndpoint) []net.IP {
+	seen := make(map[string]bool)
+	var peers []net.IP
+	for _, ip := range state.NamespaceIPs[pod.Namespace] {
+		if ip == nil || ip.Equal(pod.IP) || seen[ip.String()] {
+			continue
+		}
+		seen[ip.String()] = true
+		peers = append(peers, ip)
+	}
+	sort.Slice(peers, func(i, j int) bool { return peers[i].String() < peers[j].String() })
+	return peers
+}
+
+// podChainName 根据 Pod IP 生成链名（iptables 链名最长 28 字符）
+func podChainName(prefix string, ip net.IP) string {
+	sum := sha1.Sum([]byte(ip.String()))
+	return prefix + strings.ToUpper(hex.EncodeToString(sum[:])[:16])
+}
+
+// isManagedChain 是否为本执行器管理的链
+func isManagedChain(chain string) bool {
+	return chain == ChainPolicy || chain == ChainPolicyInput || strings.HasPrefix(chain, chainPodPrefix)
+}
+
+// hostCIDR 返回单个地址的 CIDR 表示
+func hostCIDR(ip net.IP) string {
+	if ip.To4() != nil {
+		return ip.String() + "/32"
+	}
+	return ip.String() + "/128"
+}
+
+// rulesHash 计算规则集摘要，用于判断期望状态是否变化
+func rulesHash(chains []chainRules, jumps []chainRules) string {
+	h := sha1.New()
+	for _, set := range [][]chainRules{chains, jumps} {
+		for _, chain := range set {
+			h.Write([]byte(chain.name))
+			for _, rule := range chain.rules {
+				h.Write([]byte{0})
+				h.Write([]byte(strings.Join(rule, " ")))
+			}
+			h.Write([]byte{1})
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,176 @@
+package policy
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+)
+
+// fakeIPTables 内存中的 filter 表
+type fakeIPTables struct {
+	chains map[string][]string
+}
+
+func newFakeIPTables() *fakeIPTables {
+	return &fakeIPTables{chains: map[string][]string{"INPUT": {}, "FORWARD": {}}}
+}
+
+func (f *fakeIPTables) Exists(table, chain string, rulespec ...string) (bool, error) {
+	rule := strings.Join(rulespec, " ")
+	for _, r := range f.chains[chain] {
+		if r == rule {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (f *fakeIPTables) Insert(table, chain string, pos int, rulespec ...string) error {
+	f.chains[chain] = append([]string{strings.Join(rulespec, " ")}, f.chains[chain]...)
+	return nil
+}
+
+func (f *fakeIPTables) Append(table, chain string, rulespec ...string) error {
+	if _, ok := f.chains[chain]; !ok {
+		return fmt.Errorf("chain %s does not exist", chain)
+	}
+	f.chains[chain] = append(f.chains[chain], strings.Join(rulespec, " "))
+	return nil
+}
+
+func (f *fakeIPTables) DeleteIfExists(table, chain string, rulespec ...string) error {
+	rule := strings.Join(rulespec, " ")
+	var kept []string
+	for _, r := range f.chains[chain] {
+		if r != rule {
+			kept = append(kept, r)
+		}
+	}
+	f.chains[chain] = kept
+	return nil
+}
+
+func (f *fakeIPTables) List(table, chain string) ([]string, error) {
+	rules, ok := f.chains[chain]
+	if !ok {
+		return nil, fmt.Errorf("chain %s does not exist", chain)
+	}
+	return append([]string{"-N " + chain}, rules...), nil
+}
+
+func (f *fakeIPTables) ListChains(table string) ([]string, error) {
+	var names []string
+	for name := range f.chains {
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func (f *fakeIPTables) ClearChain(table, chain string) error {
+	f.chains[chain] = []string{}
+	return nil
+}
+
+func (f *fakeIPTables) ClearAndDeleteChain(table, chain string) error {
+	delete(f.chains, chain)
+	return nil
+}
+
+func mustCIDR(t *testing.T, s string) *net.IPNet {
+	t.Helper()
+	_, ipNet, err := net.ParseCIDR(s)
+	if err != nil {
+		t.Fatalf("ParseCIDR(%q): %v", s, err)
+	}
+	return ipNet
+}
+
+func TestEnforcerApply(t *testing.T) {
+	ipt := newFakeIPTables()
+	e := newEnforcer(ipt)
+
+	podA := PodEndpoint{Namespace: "team-a", Name: "a", IP: net.ParseIP("10.244.1.10")}
+	podB := PodEndpoint{Namespace: "team-b", Name: "b", IP: net.ParseIP("10.244.1.11")}
+	state := &State{
+		PodCIDR:      mustCIDR(t, "10.244.1.0/24"),
+		ClusterCIDRs: []*net.IPNet{mustCIDR(t, "10.244.0.0/16")},
+		ServiceCIDRs: []*net.IPNet{mustCIDR(t, "10.96.0.0/12")},
+		Egress:       EgressPolicy{AllowServiceAccess: true},
+		LocalPods:    []PodEndpoint{podA, podB},
+		NamespaceIPs: map[string][]net.IP{
+			"team-a": {podA.IP, net.ParseIP("10.244.2.20")},
+			"team-b": {podB.IP},
+		},
+		AllowedPeerIPs: []net.IP{net.ParseIP("100.64.0.7")},
+	}
+
+	if err := e.Apply(state); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	egressA := ipt.chains[podChainName(chainEgressPrefix, podA.IP)]
+	for _, want := range []string{
+		"-d 10.244.2.20/32 -j RETURN",
+		"-d 100.64.0.7/32 -j RETURN",
+		"-m conntrack --ctstate DNAT --ctorigdst 10.96.0.0/12 -j RETURN",
+		"-d 10.244.0.0/16 -j DROP",
+	} {
+		if !contains(egressA, want) {
+			t.Errorf("egress chain of pod a missing %q, got %v", want, egressA)
+		}
+	}
+	if last := egressA[len(egressA)-1]; last != "-j DROP" {
+		t.Errorf("egress chain of pod a should end with DROP, got %q", last)
+	}
+
+	ingressB := ipt.chains[podChainName(chainIngressPrefix, podB.IP)]
+	if contains(ingressB, "-s 10.244.1.10/32 -j RETURN") {
+		t.Errorf("pod b must not accept traffic from another namespace, got %v", ingressB)
+	}
+	if !contains(ipt.chains["FORWARD"], "-s 10.244.1.0/24 -j "+ChainPolicy) {
+		t.Errorf("FORWARD jump missing, got %v", ipt.chains["FORWARD"])
+	}
+	if !contains(ipt.chains[ChainPolicyInput], "-j DROP") {
+		t.Errorf("host access should be dropped, got %v", ipt.chains[ChainPolicyInput])
+	}
+
+	// 规则被清空后重新下发
+	ipt.chains[ChainPolicy] = []string{}
+	if err := e.Apply(state); err != nil {
+		t.Fatalf("re-Apply failed: %v", err)
+	}
+	if !contains(ipt.chains[ChainPolicy], "-s 10.244.1.10/32 -j "+podChainName(chainEgressPrefix, podA.IP)) {
+		t.Errorf("flushed chain was not restored, got %v", ipt.chains[ChainPolicy])
+	}
+
+	// Pod 删除后其链被移除
+	state.LocalPods = []PodEndpoint{podA}
+	if err := e.Apply(state); err != nil {
+		t.Fatalf("Apply after pod removal failed: %v", err)
+	}
+	if _, ok := ipt.chains[podChainName(chainIngressPrefix, podB.IP)]; ok {
+		t.Errorf("stale chain of pod b was not removed")
+	}
+
+	if err := e.Cleanup(state.PodCIDR); err != nil {
+		t.Fatalf("Cleanup failed: %v", err)
+	}
+	for name := range ipt.chains {
+		if isManagedChain(name) {
+			t.Errorf("chain %s left after cleanup", name)
+		}
+	}
+	if len(ipt.chains["FORWARD"]) != 0 || len(ipt.chains["INPUT"]) != 0 {
+		t.Errorf("jump rules left after cleanup: %v %v", ipt.chains["FORWARD"], ipt.chains["INPUT"])
+	}
+}
+
+func contains(rules []string, rule string) bool {
+	for _, r := range rules {
+		if r == rule {
+			return true
+		}
+	}
+	return false
+}
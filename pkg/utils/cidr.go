@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"fmt"
+	"net"
+	"sort"
+)
+
+// CIDRContains 判断 ip 是否属于 cidr
+func CIDRContains(cidr string, ip net.IP) (bool, error) {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return false, fmt.Errorf("invalid CIDR %q: %v", cidr, err)
+	}
+	if ip == nil {
+		return false, fmt.Errorf("invalid IP")
+	}
+	return ipNet.Contains(ip), nil
+}
+
+// NodeForPodIP 根据各节点的 Pod CIDR 查找拥有 ip 的节点
+// 多个节点的 CIDR 都包含 ip 时取前缀最长的，未找到时返回空字符串
+func NodeForPodIP(nodeCIDRs map[string][]string, ip net.IP) (string, error) {
+	if ip == nil {
+		return "", fmt.Errorf("invalid IP")
+	}
+
+	// 按节点名排序，前缀长度相同时结果稳定
+	names := make([]string, 0, len(nodeCIDRs))
+	for name := range nodeCIDRs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	owner, bestBits := "", -1
+	for _, name := range names {
+		for _, cidr := range nodeCIDRs[name] {
+			_, ipNet, err := net.ParseCIDR(cidr)
+			if err != nil || !ipNet.Contains(ip) {
+				continue
+			}
+			if bits, _ := ipNet.Mask.Size(); bits > bestBits {
+				owner, bestBits = name, bits
+			}
+		}
+	}
+	return owner, nil
+}
@@ -0,0 +1,39 @@
+package fsutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "10-headcni.conflist")
+	if err := os.WriteFile(path, []byte("old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WriteFileAtomic(path, []byte(`{"name":"headcni"}`), 0644); err != nil {
+		t.Fatalf("WriteFileAtomic failed: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != `{"name":"headcni"}` {
+		t.Fatalf("file content = %q, %v", data, err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0644 {
+		t.Fatalf("unexpected mode: %v, %v", info.Mode(), err)
+	}
+
+	// 不留下临时文件
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected only the target file, got %d entries", len(entries))
+	}
+
+	if err := WriteFileAtomic(filepath.Join(dir, "missing", "env.yaml"), []byte("x"), 0644); err == nil {
+		t.Fatal("expected an error when the directory does not exist")
+	}
+}
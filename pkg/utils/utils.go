@@ -103,6 +103,28 @@ func GetIfaceAddr(iface *net.Interface) ([]netlink.Addr, error) {
 	}, syscall.AF_INET)
 }
 
+// GetDefaultGatewaySubnets 获取默认网关接口上配置的 IPv4 子网，没有默认路由时返回空
+func GetDefaultGatewaySubnets() ([]*net.IPNet, error) {
+	iface, err := GetDefaultGatewayInterface()
+	if err != nil || iface == nil {
+		return nil, err
+	}
+
+	addrs, err := GetIfaceAddr(iface)
+	if err != nil {
+		return nil, err
+	}
+
+	var subnets []*net.IPNet
+	for _, addr := range addrs {
+		if addr.IPNet == nil {
+			continue
+		}
+		subnets = append(subnets, &net.IPNet{IP: addr.IP.Mask(addr.Mask), Mask: addr.Mask})
+	}
+	return subnets, nil
+}
+
 // NewHardwareAddr 生成新的硬件地址
 func NewHardwareAddr() (net.HardwareAddr, error) {
 	hardwareAddr := make(net.HardwareAddr, 6)
@@ -0,0 +1,165 @@
+// Package mocks 提供 daemon 所依赖控制面的进程内替身，使 Preparer 和 TailscaleService 能在 CI 中端到端运行
+// Headscale 基于 headscaletest.Client 提供 headscale.Client 用到的 /api/v1 REST 接口；
+// Tailscaled 在 unix socket 上提供 tailscale.SimpleClient 用到的 LocalAPI
+// Tailscaled 加入 Headscale 后注册节点，并像真实的控制协议一样让 Headscale 中的路由与通告的路由保持一致
+package mocks
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/binrclab/headcni/pkg/headscale"
+	"github.com/binrclab/headcni/pkg/headscale/headscaletest"
+)
+
+// Headscale 进程内的 Headscale API 服务
+type Headscale struct {
+	state  *headscaletest.Client
+	server *httptest.Server
+	apiKey string
+}
+
+// NewHeadscale 启动服务，只接受以 apiKey 作为 bearer token 的请求
+func NewHeadscale(apiKey string) *Headscale {
+	h := &Headscale{state: headscaletest.New(), apiKey: apiKey}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/apikey", h.listAPIKeys)
+	mux.HandleFunc("GET /api/v1/node", h.listNodes)
+	mux.HandleFunc("GET /api/v1/node/{id}", h.getNode)
+	mux.HandleFunc("DELETE /api/v1/node/{id}", h.deleteNode)
+	mux.HandleFunc("POST /api/v1/node/{id}/expire", h.expireNode)
+	mux.HandleFunc("GET /api/v1/node/{id}/routes", h.getNodeRoutes)
+	mux.HandleFunc("POST /api/v1/node/{id}/tags", h.setNodeTags)
+	mux.HandleFunc("POST /api/v1/preauthkey", h.createPreAuthKey)
+	mux.HandleFunc("GET /api/v1/routes", h.getRoutes)
+	mux.HandleFunc("DELETE /api/v1/routes/{id}", h.deleteRoute)
+	mux.HandleFunc("POST /api/v1/routes/{id}/enable", h.enableRoute)
+	mux.HandleFunc("POST /api/v1/routes/{id}/disable", h.disableRoute)
+
+	h.server = httptest.NewServer(h.authenticate(mux))
+	return h
+}
+
+// URL 返回填入 headscale.url 的服务地址
+func (h *Headscale) URL() string {
+	return h.server.URL
+}
+
+// State 返回服务背后的节点和路由
+// 测试用它预置节点和路由、注入错误并断言发生的调用
+func (h *Headscale) State() *headscaletest.Client {
+	return h.state
+}
+
+// Close 关闭服务
+func (h *Headscale) Close() {
+	h.server.Close()
+}
+
+// authenticate 与 Headscale 相同，拒绝不带正确 bearer token 的请求
+func (h *Headscale) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.apiKey != "" && r.Header.Get("Authorization") != "Bearer "+h.apiKey {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *Headscale) listAPIKeys(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, &headscale.ListApiKeysResponse{})
+}
+
+func (h *Headscale) listNodes(w http.ResponseWriter, r *http.Request) {
+	result, err := h.state.ListNodes(r.Context(), r.URL.Query().Get("user"))
+	writeResult(w, result, err)
+}
+
+func (h *Headscale) getNode(w http.ResponseWriter, r *http.Request) {
+	result, err := h.state.GetNode(r.Context(), r.PathValue("id"))
+	writeResult(w, result, err)
+}
+
+func (h *Headscale) deleteNode(w http.ResponseWriter, r *http.Request) {
+	writeResult(w, struct{}{}, h.state.DeleteNode(r.Context(), r.PathValue("id")))
+}
+
+func (h *Headscale) expireNode(w http.ResponseWriter, r *http.Request) {
+	result, err := h.state.ExpireNode(r.Context(), r.PathValue("id"))
+	writeResult(w, result, err)
+}
+
+func (h *Headscale) getNodeRoutes(w http.ResponseWriter, r *http.Request) {
+	result, err := h.state.GetNodeRoutes(r.Context(), r.PathValue("id"))
+	writeResult(w, result, err)
+}
+
+func (h *Headscale) setNodeTags(w http.ResponseWriter, r *http.Request) {
+	var req headscale.SetTagsRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	result, err := h.state.SetNodeTags(r.Context(), r.PathValue("id"), req.Tags)
+	writeResult(w, result, err)
+}
+
+func (h *Headscale) createPreAuthKey(w http.ResponseWriter, r *http.Request) {
+	var req headscale.CreatePreAuthKeyRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	result, err := h.state.CreatePreAuthKey(r.Context(), &req)
+	writeResult(w, result, err)
+}
+
+func (h *Headscale) getRoutes(w http.ResponseWriter, r *http.Request) {
+	result, err := h.state.GetRoutes(r.Context())
+	writeResult(w, result, err)
+}
+
+func (h *Headscale) deleteRoute(w http.ResponseWriter, r *http.Request) {
+	writeResult(w, struct{}{}, h.state.DeleteRoute(r.Context(), r.PathValue("id")))
+}
+
+func (h *Headscale) enableRoute(w http.ResponseWriter, r *http.Request) {
+	writeResult(w, struct{}{}, h.state.EnableRoute(r.Context(), r.PathValue("id")))
+}
+
+func (h *Headscale) disableRoute(w http.ResponseWriter, r *http.Request) {
+	writeResult(w, struct{}{}, h.state.DisableRoute(r.Context(), r.PathValue("id")))
+}
+
+// readJSON 解析请求体，格式错误时返回 400
+func readJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// writeResult 以 JSON 写出 result，出错时返回 Headscale 对应的状态码
+// 节点或路由不存在时为 404，其他错误为 500，客户端会重试
+func writeResult(w http.ResponseWriter, result interface{}, err error) {
+	if err != nil {
+		status := http.StatusInternalServerError
+		if strings.Contains(err.Error(), "not found") {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	writeJSON(w, result)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
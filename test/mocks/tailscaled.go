@@ -0,0 +1,229 @@
+package mocks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/binrclab/headcni/pkg/backend/tailscale"
+	"github.com/binrclab/headcni/pkg/headscale"
+	"tailscale.com/ipn"
+	"tailscale.com/ipn/ipnstate"
+	"tailscale.com/types/key"
+)
+
+// Tailscaled 在 unix socket 上响应 tailscaled LocalAPI 请求
+// 启动时即已登录并处于 Running 状态，使用给定的 tailnet IP；按 tailscaled 的方式应用 prefs 修改，并通过 status 和 prefs 返回
+type Tailscaled struct {
+	mu sync.Mutex
+
+	state    string
+	ip       netip.Addr
+	nodeKey  key.NodePublic
+	prefs    *ipn.Prefs
+	requests []string
+
+	control *Headscale
+	nodeID  string
+
+	socketPath string
+	listener   net.Listener
+	server     *http.Server
+}
+
+// NewTailscaled 监听 socketPath 并提供服务，直到调用 Close
+func NewTailscaled(socketPath string, ip netip.Addr) (*Tailscaled, error) {
+	os.Remove(socketPath)
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %v", socketPath, err)
+	}
+
+	d := &Tailscaled{
+		state:      tailscale.BackendStateRunning,
+		ip:         ip,
+		nodeKey:    key.NewNode().Public(),
+		prefs:      ipn.NewPrefs(),
+		socketPath: socketPath,
+		listener:   listener,
+	}
+	d.prefs.WantRunning = true
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /localapi/v0/status", d.handleStatus)
+	mux.HandleFunc("GET /localapi/v0/prefs", d.handlePrefs)
+	mux.HandleFunc("PATCH /localapi/v0/prefs", d.handleEditPrefs)
+	mux.HandleFunc("POST /localapi/v0/start", d.handleStart)
+	mux.HandleFunc("POST /localapi/v0/logout", d.handleLogout)
+
+	d.server = &http.Server{Handler: d.record(mux)}
+	go d.server.Serve(listener)
+	return d, nil
+}
+
+// SocketPath 返回传给 tailscale.NewSimpleClient 的 socket 路径
+func (d *Tailscaled) SocketPath() string {
+	return d.socketPath
+}
+
+// NodeKey 返回 status 中报告的节点公钥
+func (d *Tailscaled) NodeKey() key.NodePublic {
+	return d.nodeKey
+}
+
+// Join 以 nodeID、主机名、tailnet IP 和节点公钥在控制服务器注册节点
+// 之后控制服务器中的路由与本节点通告的路由保持一致
+func (d *Tailscaled) Join(control *Headscale, nodeID, hostname, user string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	control.State().AddNode(headscale.Node{
+		ID:          nodeID,
+		NodeKey:     d.nodeKey.String(),
+		IPAddresses: []string{d.ip.String()},
+		Name:        hostname,
+		GivenName:   hostname,
+		User:        headscale.User{Name: user},
+		CreatedAt:   time.Now(),
+		LastSeen:    time.Now(),
+		Online:      true,
+	})
+	d.control, d.nodeID = control, nodeID
+	d.syncRoutesLocked()
+}
+
+// Prefs 返回当前 prefs 的副本
+func (d *Tailscaled) Prefs() *ipn.Prefs {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.prefs.Clone()
+}
+
+// AdvertisedRoutes 返回当前 prefs 中通告的路由
+func (d *Tailscaled) AdvertisedRoutes() []netip.Prefix {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]netip.Prefix(nil), d.prefs.AdvertiseRoutes...)
+}
+
+// SetBackendState 设置 status 中报告的状态
+func (d *Tailscaled) SetBackendState(state string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.state = state
+}
+
+// Requests 按顺序返回已处理的 LocalAPI 请求，格式为 "METHOD /path"
+func (d *Tailscaled) Requests() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.requests...)
+}
+
+// Close 停止服务并删除 socket
+func (d *Tailscaled) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := d.server.Shutdown(ctx)
+	os.Remove(d.socketPath)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// record 处理请求前将其追加到 Requests
+func (d *Tailscaled) record(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d.mu.Lock()
+		d.requests = append(d.requests, r.Method+" "+r.URL.Path)
+		d.mu.Unlock()
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (d *Tailscaled) handleStatus(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	status := &ipnstate.Status{
+		BackendState: d.state,
+		HaveNodeKey:  true,
+		Self: &ipnstate.PeerStatus{
+			PublicKey: d.nodeKey,
+			HostName:  d.prefs.Hostname,
+			Online:    d.state == tailscale.BackendStateRunning,
+		},
+	}
+	// tailscaled 登录后才会报告地址
+	if d.state == tailscale.BackendStateRunning {
+		status.TailscaleIPs = []netip.Addr{d.ip}
+		status.Self.TailscaleIPs = []netip.Addr{d.ip}
+	}
+	writeJSON(w, status)
+}
+
+func (d *Tailscaled) handlePrefs(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	writeJSON(w, d.prefs)
+}
+
+func (d *Tailscaled) handleEditPrefs(w http.ResponseWriter, r *http.Request) {
+	var edits ipn.MaskedPrefs
+	if !readJSON(w, r, &edits) {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.prefs.ApplyEdits(&edits)
+	if edits.AdvertiseRoutesSet {
+		d.syncRoutesLocked()
+	}
+	writeJSON(w, d.prefs)
+}
+
+func (d *Tailscaled) handleStart(w http.ResponseWriter, r *http.Request) {
+	var opts ipn.Options
+	if !readJSON(w, r, &opts) {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if opts.UpdatePrefs != nil {
+		d.prefs = opts.UpdatePrefs.Clone()
+		d.syncRoutesLocked()
+	}
+	if opts.AuthKey != "" {
+		d.state = tailscale.BackendStateRunning
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (d *Tailscaled) handleLogout(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.state = tailscale.BackendStateNeedsLogin
+	d.prefs.WantRunning = false
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// syncRoutesLocked 将通告的路由同步到已加入的控制服务器
+func (d *Tailscaled) syncRoutesLocked() {
+	if d.control == nil {
+		return
+	}
+	prefixes := make([]string, 0, len(d.prefs.AdvertiseRoutes))
+	for _, route := range d.prefs.AdvertiseRoutes {
+		prefixes = append(prefixes, route.String())
+	}
+	d.control.State().SetAdvertisedRoutes(d.nodeID, prefixes)
+}